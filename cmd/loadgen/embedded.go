@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
+	"github.com/ricirt/event-driven-arch/internal/provider"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+	"github.com/ricirt/event-driven-arch/internal/worker"
+)
+
+// embeddedConfig sizes the in-process service of the embedded target.
+type embeddedConfig struct {
+	Workers         int
+	ChannelRate     int
+	ProviderLatency time.Duration
+	FailureRate     float64
+}
+
+func (c embeddedConfig) validate() error {
+	switch {
+	case c.Workers < 1:
+		return fmt.Errorf("-workers must be at least 1, got %d", c.Workers)
+	case c.ChannelRate < 1:
+		return fmt.Errorf("-channel-rate must be at least 1, got %d", c.ChannelRate)
+	case c.ProviderLatency < 0:
+		return fmt.Errorf("-provider-latency must not be negative, got %s", c.ProviderLatency)
+	case c.FailureRate < 0 || c.FailureRate > 1:
+		return fmt.Errorf("-provider-failure-rate must be from 0 to 1, got %g", c.FailureRate)
+	}
+	return nil
+}
+
+// embeddedRetryBackoff keeps retries of mock provider failures within a
+// short run; the server's default waits minutes.
+var embeddedRetryBackoff = []time.Duration{100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond}
+
+// embeddedTarget runs the service, queue, workers and retry worker in
+// process, on the in-memory repository and a mock provider.
+type embeddedTarget struct {
+	svc    *service.NotificationService
+	repo   *repository.MockNotificationRepository
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	pool   *worker.Pool
+}
+
+func newEmbeddedTarget(ctx context.Context, c embeddedConfig) *embeddedTarget {
+	logger := zap.NewNop()
+	repo := repository.NewMockNotificationRepository()
+	q := queue.New()
+	cfg := &config.Config{
+		SMSWorkers:      c.Workers,
+		RetryBackoff:    embeddedRetryBackoff,
+		ProviderTimeout: 10 * time.Second,
+	}
+	prov := &mockProvider{latency: c.ProviderLatency, failureRate: c.FailureRate}
+	pool := worker.NewPool(cfg, q, repo, prov, ratelimiter.New(c.ChannelRate, nil), logger, worker.Hooks{})
+	retries := worker.NewRetryWorker(repo, q, 50*time.Millisecond, lookupChunk, events.Nop{}, worker.RetryHooks{}, logger)
+
+	ctx, cancel := context.WithCancel(ctx)
+	t := &embeddedTarget{
+		svc:    service.NewNotificationService(repo, q, logger, service.Options{MaxLookupIDs: lookupChunk}),
+		repo:   repo,
+		cancel: cancel,
+		pool:   pool,
+	}
+	pool.Start(ctx)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		retries.Run(ctx)
+	}()
+	return t
+}
+
+func (t *embeddedTarget) Create(ctx context.Context, req domain.CreateNotificationRequest) (string, outcome, error) {
+	n, res, err := t.svc.Create(ctx, req, "")
+	switch {
+	case errors.Is(err, domain.ErrQueueFull):
+		return "", queueFull, nil
+	case err != nil:
+		return "", 0, err
+	case res.Deferred:
+		return "", queueFull, nil
+	}
+	return n.ID, created, nil
+}
+
+func (t *embeddedTarget) Lookup(ctx context.Context, ids []string) ([]*domain.Notification, error) {
+	return t.repo.GetByIDs(ctx, ids)
+}
+
+func (t *embeddedTarget) Close() {
+	t.cancel()
+	t.pool.Wait()
+	t.wg.Wait()
+}
+
+// mockProvider accepts every send after latency, failing failureRate of
+// them with a retryable error.
+type mockProvider struct {
+	latency     time.Duration
+	failureRate float64
+	mu          sync.Mutex
+	seq         int
+}
+
+func (p *mockProvider) Send(ctx context.Context, n *domain.Notification) (*provider.SendResponse, error) {
+	if p.latency > 0 {
+		timer := time.NewTimer(p.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if p.failureRate > 0 && rand.Float64() < p.failureRate {
+		return nil, errors.New("mock provider: simulated failure")
+	}
+	p.mu.Lock()
+	p.seq++
+	id := fmt.Sprintf("loadgen-%d", p.seq)
+	p.mu.Unlock()
+	return &provider.SendResponse{MessageID: id, Status: "accepted", Timestamp: time.Now().UTC().Format(time.RFC3339)}, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// loadConfig is the load to generate.
+type loadConfig struct {
+	Rate          float64
+	Duration      time.Duration
+	Concurrency   int
+	Channels      mix[domain.Channel]
+	Priorities    mix[domain.Priority]
+	DryRun        bool
+	SettleTimeout time.Duration
+	PollInterval  time.Duration
+}
+
+func (c loadConfig) validate() error {
+	switch {
+	case c.Rate <= 0:
+		return fmt.Errorf("-rate must be above 0, got %g", c.Rate)
+	case c.Duration <= 0:
+		return fmt.Errorf("-duration must be above 0, got %s", c.Duration)
+	case c.Concurrency < 1:
+		return fmt.Errorf("-concurrency must be at least 1, got %d", c.Concurrency)
+	case c.SettleTimeout < 0:
+		return fmt.Errorf("-settle-timeout must not be negative, got %s", c.SettleTimeout)
+	case c.PollInterval <= 0:
+		return fmt.Errorf("-poll-interval must be above 0, got %s", c.PollInterval)
+	}
+	return nil
+}
+
+// request builds the i-th synthetic notification. Its content is unique per
+// run, so the dedup window never folds two of them together, and its
+// recipient is reserved for fiction: a 555-01xx number or an example.com
+// address.
+func (c loadConfig) request(i int, run string) domain.CreateNotificationRequest {
+	req := domain.CreateNotificationRequest{
+		Channel:  c.Channels.pick(rand.Float64()),
+		Priority: c.Priorities.pick(rand.Float64()),
+		Content:  fmt.Sprintf("loadgen %s message %d", run, i),
+		DryRun:   c.DryRun,
+	}
+	switch req.Channel {
+	case domain.ChannelSMS:
+		req.Recipient = fmt.Sprintf("+1555010%04d", i%10000)
+	case domain.ChannelEmail:
+		req.Recipient = fmt.Sprintf("loadgen-%d@example.com", i)
+	default:
+		req.Recipient = fmt.Sprintf("loadgen-%s-%d", run, i)
+	}
+	return req
+}
+
+// generate creates notifications at cfg.Rate for cfg.Duration, then waits
+// for the created ones to settle. Cancelling ctx stops creating and reports
+// what was seen so far.
+func generate(ctx context.Context, t target, cfg loadConfig) (*report, error) {
+	rep := &report{Statuses: map[domain.Status]int{}}
+	run := time.Now().UTC().Format("20060102T150405")
+
+	genCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	// A burst of one spaces the creates evenly instead of front-loading them.
+	lim := rate.NewLimiter(rate.Limit(cfg.Rate), 1)
+	jobs := make(chan int)
+	var (
+		mu  sync.Mutex
+		ids []string
+		wg  sync.WaitGroup
+	)
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				id, o, err := t.Create(ctx, cfg.request(i, run))
+				mu.Lock()
+				switch {
+				case err != nil:
+					rep.Errors++
+					if rep.FirstError == "" {
+						rep.FirstError = err.Error()
+					}
+				case o == queueFull:
+					rep.QueueFull++
+				case o == rateLimited:
+					rep.RateLimited++
+				default:
+					ids = append(ids, id)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	for i := 0; lim.Wait(genCtx) == nil; i++ {
+		select {
+		case jobs <- i:
+			rep.Attempted++
+		case <-genCtx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	rep.Elapsed = time.Since(start)
+	rep.Created = len(ids)
+
+	settled, err := settle(ctx, t, ids, cfg)
+	if err != nil {
+		return nil, err
+	}
+	rep.add(settled)
+	rep.Unsettled = len(ids) - len(settled)
+	return rep, nil
+}
+
+// settle polls ids until all of them have settled, cfg.SettleTimeout passes
+// or ctx is cancelled, and returns the settled ones.
+func settle(ctx context.Context, t target, ids []string, cfg loadConfig) ([]*domain.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.SettleTimeout)
+	defer cancel()
+	pending := ids
+	var settled []*domain.Notification
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	for len(pending) > 0 {
+		var still []string
+		for chunk := range slices.Chunk(pending, lookupChunk) {
+			found, err := t.Lookup(ctx, chunk)
+			if err != nil {
+				if ctx.Err() != nil {
+					return settled, nil
+				}
+				return settled, fmt.Errorf("look up notifications: %w", err)
+			}
+			done := make(map[string]bool, len(found))
+			for _, n := range found {
+				if n.Settled() {
+					done[n.ID] = true
+					settled = append(settled, n)
+				}
+			}
+			for _, id := range chunk {
+				if !done[id] {
+					still = append(still, id)
+				}
+			}
+		}
+		pending = still
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return settled, nil
+		}
+	}
+	return settled, nil
+}
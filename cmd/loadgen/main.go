@@ -0,0 +1,132 @@
+// Command loadgen creates synthetic notifications at a steady rate, waits for
+// them to settle and reports throughput and delivery latency, for capacity
+// testing. It drives a running server over the HTTP API or, with
+// -target embedded, an in-process service with a mock provider, which needs
+// no database and runs in CI as a smoke benchmark.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: loadgen [flags]
+
+Creates notifications at -rate per second for -duration, polls until every
+one has settled or -settle-timeout passes, then prints the results.
+
+flags:`
+
+// Exit codes. A run that completes but saw create errors or left
+// notifications unsettled exits 1, so a CI smoke run fails on it.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
+
+// run parses the flags, runs the load and returns the exit code. getenv
+// supplies LOADGEN_URL and LOADGEN_API_KEY, the defaults of -url and
+// -api-key.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, usage)
+		fs.PrintDefaults()
+	}
+	targetName := fs.String("target", "http", "http to drive a running server, embedded for an in-process service with a mock provider")
+	baseURL := fs.String("url", envOr(getenv, "LOADGEN_URL", "http://localhost:8080"), "service base URL, http target (LOADGEN_URL)")
+	apiKey := fs.String("api-key", getenv("LOADGEN_API_KEY"), "API key sent as X-API-Key, http target (LOADGEN_API_KEY)")
+	dryRun := fs.Bool("dry-run", true, "create dry runs so the server never calls its provider, http target")
+	timeout := fs.Duration("timeout", 10*time.Second, "time limit for each request, http target")
+
+	var cfg loadConfig
+	fs.Float64Var(&cfg.Rate, "rate", 50, "notifications created per second")
+	fs.DurationVar(&cfg.Duration, "duration", 10*time.Second, "how long to keep creating")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 8, "creates in flight at once")
+	channels := fs.String("channels", "sms=60,email=30,push=10", "channel mix, as relative weights")
+	priorities := fs.String("priorities", "high=10,normal=80,low=10", "priority mix, as relative weights")
+	fs.DurationVar(&cfg.SettleTimeout, "settle-timeout", time.Minute, "how long to wait for notifications to settle after the last create")
+	fs.DurationVar(&cfg.PollInterval, "poll-interval", 500*time.Millisecond, "how often to look up unsettled notifications")
+
+	var ecfg embeddedConfig
+	fs.IntVar(&ecfg.Workers, "workers", 15, "worker goroutines, embedded target")
+	fs.IntVar(&ecfg.ChannelRate, "channel-rate", 100, "sends per second per channel, embedded target")
+	fs.DurationVar(&ecfg.ProviderLatency, "provider-latency", 20*time.Millisecond, "mock provider response time, embedded target")
+	fs.Float64Var(&ecfg.FailureRate, "provider-failure-rate", 0, "share of mock provider calls that fail, from 0 to 1, embedded target")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+		return exitUsage
+	}
+
+	var err error
+	if cfg.Channels, err = parseChannelMix(*channels); err != nil {
+		fmt.Fprintf(stderr, "-channels: %v\n", err)
+		return exitUsage
+	}
+	if cfg.Priorities, err = parsePriorityMix(*priorities); err != nil {
+		fmt.Fprintf(stderr, "-priorities: %v\n", err)
+		return exitUsage
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	var t target
+	switch *targetName {
+	case "http":
+		t = newHTTPTarget(*baseURL, *apiKey, &http.Client{Timeout: *timeout})
+		cfg.DryRun = *dryRun
+	case "embedded":
+		if err := ecfg.validate(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		t = newEmbeddedTarget(ctx, ecfg)
+	default:
+		fmt.Fprintf(stderr, "-target must be http or embedded, got %q\n", *targetName)
+		return exitUsage
+	}
+	defer t.Close()
+
+	rep, err := generate(ctx, t, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "loadgen: %v\n", err)
+		return exitFailed
+	}
+	rep.Print(stdout)
+	if rep.Errors > 0 || rep.Unsettled > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
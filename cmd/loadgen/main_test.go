@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func runLoadgen(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), args, &out, &errOut, func(string) string { return "" })
+	return code, out.String(), errOut.String()
+}
+
+func TestRun_EmbeddedSmoke(t *testing.T) {
+	code, out, errOut := runLoadgen(
+		"-target", "embedded", "-rate", "200", "-duration", "300ms",
+		"-provider-latency", "1ms", "-settle-timeout", "10s", "-poll-interval", "20ms",
+	)
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr %q, stdout:\n%s", code, errOut, out)
+	}
+	for _, want := range []string{"created", "unsettled  0", "latency p99"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_BadFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"bad target", []string{"-target", "grpc"}, "-target must be http or embedded"},
+		{"bad channel", []string{"-channels", "fax=1"}, `unknown name "fax"`},
+		{"no weight", []string{"-priorities", "high=0"}, "no weight above 0"},
+		{"zero rate", []string{"-rate", "0"}, "-rate must be above 0"},
+		{"failure rate", []string{"-target", "embedded", "-provider-failure-rate", "2"}, "-provider-failure-rate must be from 0 to 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, errOut := runLoadgen(tc.args...)
+			if code != exitUsage {
+				t.Fatalf("exit %d, want %d", code, exitUsage)
+			}
+			if !strings.Contains(errOut, tc.want) {
+				t.Errorf("stderr %q lacks %q", errOut, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPTarget_CreateOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    outcome
+		wantID  string
+		wantErr bool
+	}{
+		{"created", http.StatusCreated, `{"id":"n1"}`, created, "n1", false},
+		{"deferred", http.StatusAccepted, `{"id":"n1","queued":false}`, queueFull, "", false},
+		{"strict queue full", http.StatusServiceUnavailable, `{"error":{"code":"queue_full","message":"full"}}`, queueFull, "", false},
+		{"rate limited", http.StatusTooManyRequests, `{"error":{"code":"rate_limited","message":"slow down"}}`, rateLimited, "", false},
+		{"validation", http.StatusBadRequest, `{"error":{"code":"validation_error","message":"bad"}}`, 0, "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotKey string
+			var gotBody domain.CreateNotificationRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotKey = r.Header.Get("X-API-Key")
+				raw, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(raw, &gotBody); err != nil {
+					t.Errorf("decode body: %v", err)
+				}
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+
+			tg := newHTTPTarget(srv.URL, "k1", srv.Client())
+			req := domain.CreateNotificationRequest{Channel: domain.ChannelSMS, Recipient: "+15550100001", Content: "hi", DryRun: true}
+			id, o, err := tg.Create(context.Background(), req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if o != tc.want || id != tc.wantID {
+				t.Errorf("got (%q, %d), want (%q, %d)", id, o, tc.wantID, tc.want)
+			}
+			if gotKey != "k1" || !gotBody.DryRun {
+				t.Errorf("server saw key %q and dry_run %v", gotKey, gotBody.DryRun)
+			}
+		})
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	m, err := parseChannelMix("sms=3, email=1,push=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r, want := range map[float64]domain.Channel{0: domain.ChannelSMS, 0.74: domain.ChannelSMS, 0.75: domain.ChannelEmail, 0.99: domain.ChannelEmail} {
+		if got := m.pick(r); got != want {
+			t.Errorf("pick(%g) = %s, want %s", r, got, want)
+		}
+	}
+	for _, bad := range []string{"sms", "sms=-1", "sms=1,sms=2", "low=1"} {
+		if _, err := parseChannelMix(bad); err == nil {
+			t.Errorf("parseChannelMix(%q) accepted", bad)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(d, p); got != want {
+			t.Errorf("p%g = %s, want %s", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("empty p50 = %s, want 0", got)
+	}
+}
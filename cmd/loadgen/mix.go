@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// mix picks values in proportion to their weights.
+type mix[T comparable] struct {
+	values []T
+	cum    []int // running total of the weights, one per value
+}
+
+// pick returns the value that r, from 0 up to but excluding 1, falls on.
+func (m mix[T]) pick(r float64) T {
+	x := int(r * float64(m.cum[len(m.cum)-1]))
+	for i, c := range m.cum {
+		if x < c {
+			return m.values[i]
+		}
+	}
+	return m.values[len(m.values)-1]
+}
+
+// parseMix parses "a=60,b=40". Weights are relative, so they need not add
+// up to 100; a zero weight leaves the value out.
+func parseMix[T comparable](s string, parse func(string) (T, bool)) (mix[T], error) {
+	var m mix[T]
+	seen := map[T]bool{}
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return m, fmt.Errorf("expected name=weight, got %q", part)
+		}
+		v, ok := parse(strings.TrimSpace(name))
+		if !ok {
+			return m, fmt.Errorf("unknown name %q", name)
+		}
+		if seen[v] {
+			return m, fmt.Errorf("%q is listed twice", name)
+		}
+		seen[v] = true
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			return m, fmt.Errorf("weight of %q must be a whole number of 0 or more, got %q", name, weight)
+		}
+		if w == 0 {
+			continue
+		}
+		total += w
+		m.values = append(m.values, v)
+		m.cum = append(m.cum, total)
+	}
+	if total == 0 {
+		return m, fmt.Errorf("no weight above 0 in %q", s)
+	}
+	return m, nil
+}
+
+func parseChannelMix(s string) (mix[domain.Channel], error) {
+	return parseMix(s, func(name string) (domain.Channel, bool) {
+		ch := domain.Channel(name)
+		return ch, ch.IsValid()
+	})
+}
+
+func parsePriorityMix(s string) (mix[domain.Priority], error) {
+	return parseMix(s, func(name string) (domain.Priority, bool) {
+		p := domain.Priority(name)
+		return p, p.IsValid()
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// report is the outcome of a run.
+type report struct {
+	Attempted   int
+	Created     int
+	QueueFull   int
+	RateLimited int
+	Errors      int
+	FirstError  string
+	Elapsed     time.Duration // spent creating
+
+	Statuses  map[domain.Status]int // settled notifications by final status
+	Unsettled int
+	Latencies []time.Duration // created_at to sent_at of each sent notification
+	first     time.Time       // earliest created_at among the settled
+	last      time.Time       // latest sent_at
+}
+
+// add records settled notifications.
+func (r *report) add(ns []*domain.Notification) {
+	for _, n := range ns {
+		r.Statuses[n.Status]++
+		if r.first.IsZero() || n.CreatedAt.Before(r.first) {
+			r.first = n.CreatedAt
+		}
+		if n.Status != domain.StatusSent || n.SentAt == nil {
+			continue
+		}
+		r.Latencies = append(r.Latencies, n.SentAt.Sub(n.CreatedAt))
+		if n.SentAt.After(r.last) {
+			r.last = *n.SentAt
+		}
+	}
+	slices.Sort(r.Latencies)
+}
+
+// percentile returns the nearest-rank p-th percentile, p from 0 to 100, of
+// sorted; zero when sorted is empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted)) + 0.5)
+	return sorted[min(max(rank-1, 0), len(sorted)-1)]
+}
+
+// Print writes the report as aligned text.
+func (r *report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	perSec := func(n int, d time.Duration) string {
+		if d <= 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f/s", float64(n)/d.Seconds())
+	}
+	fmt.Fprintf(tw, "attempted\t%d\tin %s\n", r.Attempted, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "created\t%d\t%s\n", r.Created, perSec(r.Created, r.Elapsed))
+	fmt.Fprintf(tw, "queue full\t%d\n", r.QueueFull)
+	fmt.Fprintf(tw, "rate limited\t%d\n", r.RateLimited)
+	fmt.Fprintf(tw, "errors\t%d\n", r.Errors)
+	fmt.Fprintln(tw)
+	for _, s := range []domain.Status{domain.StatusSent, domain.StatusFailed, domain.StatusCancelled, domain.StatusExpired} {
+		fmt.Fprintf(tw, "%s\t%d\n", s, r.Statuses[s])
+	}
+	fmt.Fprintf(tw, "unsettled\t%d\n", r.Unsettled)
+	fmt.Fprintf(tw, "delivered\t%d\t%s\n", len(r.Latencies), perSec(len(r.Latencies), r.last.Sub(r.first)))
+	if len(r.Latencies) > 0 {
+		fmt.Fprintln(tw)
+		for _, p := range []float64{50, 90, 99} {
+			fmt.Fprintf(tw, "latency p%g\t%s\n", p, percentile(r.Latencies, p).Round(time.Millisecond))
+		}
+		fmt.Fprintf(tw, "latency max\t%s\n", r.Latencies[len(r.Latencies)-1].Round(time.Millisecond))
+	}
+	tw.Flush()
+	if r.FirstError != "" {
+		fmt.Fprintf(w, "\nfirst error: %s\n", r.FirstError)
+	}
+}
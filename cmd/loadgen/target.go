@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// outcome is how a create request ended.
+type outcome int
+
+const (
+	created     outcome = iota // stored and queued; its settling is tracked
+	queueFull                  // the queue was full: 202 left pending, or 503 under STRICT_ENQUEUE
+	rateLimited                // throttled by the HTTP or tenant rate limits
+)
+
+// target is where load is sent.
+type target interface {
+	// Create creates one notification. The ID is set only for outcome
+	// created; an error means the request failed in any other way.
+	Create(ctx context.Context, req domain.CreateNotificationRequest) (id string, o outcome, err error)
+	// Lookup returns the notifications among ids, at most lookupChunk of them.
+	Lookup(ctx context.Context, ids []string) ([]*domain.Notification, error)
+	Close()
+}
+
+// lookupChunk is the number of IDs looked up per call, the server's default
+// MAX_LOOKUP_IDS.
+const lookupChunk = 100
+
+// httpTarget sends load to a running server.
+type httpTarget struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newHTTPTarget(baseURL, apiKey string, hc *http.Client) *httpTarget {
+	return &httpTarget{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, http: hc}
+}
+
+func (t *httpTarget) Create(ctx context.Context, req domain.CreateNotificationRequest) (string, outcome, error) {
+	var n domain.Notification
+	status, err := t.post(ctx, "/api/v1/notifications", req, &n)
+	var se *statusError
+	switch {
+	case errors.As(err, &se) && se.code == "queue_full":
+		return "", queueFull, nil
+	case errors.As(err, &se) && se.status == http.StatusTooManyRequests:
+		return "", rateLimited, nil
+	case err != nil:
+		return "", 0, err
+	case status == http.StatusAccepted:
+		return "", queueFull, nil
+	}
+	return n.ID, created, nil
+}
+
+func (t *httpTarget) Lookup(ctx context.Context, ids []string) ([]*domain.Notification, error) {
+	var res struct {
+		Notifications []*domain.Notification `json:"notifications"`
+	}
+	if _, err := t.post(ctx, "/api/v1/notifications/lookup", map[string][]string{"ids": ids}, &res); err != nil {
+		return nil, err
+	}
+	return res.Notifications, nil
+}
+
+func (t *httpTarget) Close() { t.http.CloseIdleConnections() }
+
+// statusError is an error response from the service.
+type statusError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *statusError) Error() string { return fmt.Sprintf("%d %s: %s", e.status, e.code, e.msg) }
+
+// post sends body as JSON and decodes a 2xx response into out. Any status of
+// 400 or above is returned as a *statusError.
+func (t *httpTarget) post(ctx context.Context, path string, body, out any) (int, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("encode request: %w", err)
+	}
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	hr.Header.Set("Accept", "application/json")
+	if t.apiKey != "" {
+		hr.Header.Set("X-API-Key", t.apiKey)
+	}
+	resp, err := t.http.Do(hr)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, decodeError(resp.StatusCode, raw)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// decodeError builds the statusError for an error response, keeping a body
+// that is not the service's error shape as the message.
+func decodeError(status int, raw []byte) *statusError {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.Error.Code == "" {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = http.StatusText(status)
+		}
+		return &statusError{status: status, code: "http_error", msg: msg}
+	}
+	return &statusError{status: status, code: body.Error.Code, msg: body.Error.Message}
+}
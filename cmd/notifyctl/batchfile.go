@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// readBatchFile reads a batch from path, or stdin for "-". format is "json",
+// "csv" or empty to go by the file extension.
+//
+// JSON is either the API's request body ({"notifications": [...]}, with any
+// batch-level fields) or a bare array of notifications. CSV has a header row
+// naming the columns, among channel, recipient, content, priority,
+// scheduled_at, max_retries, template_id and dry_run, plus var.NAME for each
+// template variable. Empty cells are left unset, except that priority
+// defaults to normal as it does for send.
+func readBatchFile(path, format string) (*domain.CreateBatchRequest, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = "json"
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			format = "csv"
+		}
+	}
+	switch format {
+	case "json":
+		return parseBatchJSON(raw)
+	case "csv":
+		return parseBatchCSV(bytes.NewReader(raw))
+	}
+	return nil, usagef("-format must be json or csv, got %q", format)
+}
+
+func parseBatchJSON(raw []byte) (*domain.CreateBatchRequest, error) {
+	raw = bytes.TrimSpace(raw)
+	var req domain.CreateBatchRequest
+	var err error
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &req.Notifications)
+	} else {
+		err = json.Unmarshal(raw, &req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON batch: %w", err)
+	}
+	if len(req.Notifications) == 0 {
+		return nil, errors.New("the batch file has no notifications")
+	}
+	return &req, nil
+}
+
+func parseBatchCSV(r io.Reader) (*domain.CreateBatchRequest, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the batch file has no notifications")
+		}
+		return nil, fmt.Errorf("parse CSV batch: %w", err)
+	}
+	for i, col := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(col))
+		if !knownColumn(header[i]) {
+			return nil, fmt.Errorf("parse CSV batch: unknown column %q", col)
+		}
+	}
+
+	var req domain.CreateBatchRequest
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse CSV batch: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		n, err := csvNotification(header, record)
+		if err != nil {
+			return nil, fmt.Errorf("parse CSV batch: line %d: %w", line, err)
+		}
+		req.Notifications = append(req.Notifications, n)
+	}
+	if len(req.Notifications) == 0 {
+		return nil, errors.New("the batch file has no notifications")
+	}
+	return &req, nil
+}
+
+func knownColumn(col string) bool {
+	switch col {
+	case "channel", "recipient", "content", "priority", "scheduled_at", "max_retries", "template_id", "dry_run":
+		return true
+	}
+	return strings.HasPrefix(col, "var.") && len(col) > len("var.")
+}
+
+// csvNotification builds one notification from a CSV record. Values are
+// checked only as far as their type needs; the API validates the rest.
+func csvNotification(header, record []string) (domain.CreateNotificationRequest, error) {
+	n := domain.CreateNotificationRequest{Priority: domain.PriorityNormal}
+	for i, col := range header {
+		v := strings.TrimSpace(record[i])
+		if v == "" {
+			continue
+		}
+		switch col {
+		case "channel":
+			n.Channel = domain.Channel(v)
+		case "recipient":
+			n.Recipient = v
+		case "content":
+			n.Content = v
+		case "priority":
+			n.Priority = domain.Priority(v)
+		case "scheduled_at":
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return n, fmt.Errorf("scheduled_at must be an RFC3339 time, got %q", v)
+			}
+			n.ScheduledAt = &t
+		case "max_retries":
+			m, err := strconv.Atoi(v)
+			if err != nil {
+				return n, fmt.Errorf("max_retries must be a number, got %q", v)
+			}
+			n.MaxRetries = &m
+		case "template_id":
+			n.TemplateID = &v
+		case "dry_run":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return n, fmt.Errorf("dry_run must be true or false, got %q", v)
+			}
+			n.DryRun = b
+		default: // var.NAME
+			if n.Variables == nil {
+				n.Variables = map[string]string{}
+			}
+			n.Variables[strings.TrimPrefix(col, "var.")] = v
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// client calls the service's HTTP API.
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newClient(baseURL, apiKey string, hc *http.Client) *client {
+	return &client{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, http: hc}
+}
+
+// apiError is an error response from the service, in its standard
+// {"error": {...}} shape.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details []domain.FieldError
+}
+
+func (e *apiError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s: %s", e.Status, e.Code, e.Message)
+	for _, d := range e.Details {
+		b.WriteString("\n  ")
+		if d.Index != nil {
+			fmt.Fprintf(&b, "item %d: ", *d.Index)
+		}
+		if d.Field != "" {
+			b.WriteString(d.Field + ": ")
+		}
+		b.WriteString(d.Message)
+	}
+	return b.String()
+}
+
+// request is one API call. Body, when non-nil, is sent as JSON; Out, when
+// non-nil, receives the decoded response body.
+type request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   any
+	Out    any
+}
+
+// response is a successful API response. Body is kept as sent so -o json
+// can print it unchanged.
+type response struct {
+	Status int
+	Body   []byte
+}
+
+// do sends req and returns the response. Any status of 400 or above is
+// returned as an *apiError.
+func (c *client) do(ctx context.Context, req request) (*response, error) {
+	u := c.baseURL + req.Path
+	if len(req.Query) > 0 {
+		u += "?" + req.Query.Encode()
+	}
+	var body io.Reader
+	if req.Body != nil {
+		b, err := json.Marshal(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range req.Header {
+		hr.Header[k] = vs
+	}
+	if req.Body != nil {
+		hr.Header.Set("Content-Type", "application/json")
+	}
+	hr.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		hr.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(hr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, decodeError(resp.StatusCode, raw)
+	}
+	if req.Out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, req.Out); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return &response{Status: resp.StatusCode, Body: raw}, nil
+}
+
+// decodeError builds the apiError for an error response. A body that is not
+// the service's error shape, say from a proxy in between, is kept as the
+// message.
+func decodeError(status int, raw []byte) *apiError {
+	var body struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Message string              `json:"message"`
+			Details []domain.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.Error.Code == "" {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = http.StatusText(status)
+		}
+		return &apiError{Status: status, Code: "http_error", Message: msg}
+	}
+	return &apiError{Status: status, Code: body.Error.Code, Message: body.Error.Message, Details: body.Error.Details}
+}
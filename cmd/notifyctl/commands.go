@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func runSend(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("send", "send -channel C -to RECIPIENT -content TEXT [flags]")
+	channel := fs.String("channel", "", "sms, email or push (required)")
+	to := fs.String("to", "", "recipient (required)")
+	content := fs.String("content", "", "message text (required)")
+	priority := fs.String("priority", string(domain.PriorityNormal), "high, normal or low")
+	schedule := fs.String("schedule", "", "send at this RFC3339 time instead of now")
+	idemKey := fs.String("idempotency-key", "", "X-Idempotency-Key, so a retried send is not delivered twice")
+	dryRun := fs.Bool("dry-run", false, "go through the pipeline without calling the provider")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+
+	req := domain.CreateNotificationRequest{
+		Channel:   domain.Channel(*channel),
+		Recipient: *to,
+		Content:   *content,
+		Priority:  domain.Priority(*priority),
+		DryRun:    *dryRun,
+	}
+	switch {
+	case !req.Channel.IsValid():
+		return usagef("-channel must be sms, email or push, got %q", *channel)
+	case req.Recipient == "":
+		return usagef("-to is required")
+	case req.Content == "":
+		return usagef("-content is required")
+	case !req.Priority.IsValid():
+		return usagef("-priority must be high, normal or low, got %q", *priority)
+	}
+	if *schedule != "" {
+		t, err := time.Parse(time.RFC3339, *schedule)
+		if err != nil {
+			return usagef("-schedule must be an RFC3339 time, got %q", *schedule)
+		}
+		req.ScheduledAt = &t
+	}
+	header := http.Header{}
+	if *idemKey != "" {
+		header.Set("X-Idempotency-Key", *idemKey)
+	}
+
+	var n domain.Notification
+	resp, err := e.c.do(ctx, request{Method: http.MethodPost, Path: "/api/v1/notifications", Header: header, Body: req, Out: &n})
+	if err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.Raw(resp.Body)
+	}
+	if err := e.out.Notification(n); err != nil {
+		return err
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		fmt.Fprintln(e.out.w, "\nan existing notification was returned: a duplicate within the dedup window, or an idempotent replay")
+	case http.StatusAccepted:
+		fmt.Fprintln(e.out.w, "\nstored but not queued, the queue is full; send again with the same -idempotency-key later")
+	}
+	return nil
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("get", "get ID")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one notification ID")
+	}
+	var n domain.Notification
+	resp, err := e.c.do(ctx, request{Method: http.MethodGet, Path: "/api/v1/notifications/" + url.PathEscape(fs.Arg(0)), Out: &n})
+	if err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.Raw(resp.Body)
+	}
+	return e.out.Notification(n)
+}
+
+// notificationPage is the API's paginated list envelope.
+type notificationPage struct {
+	Data       []domain.Notification `json:"data"`
+	Total      int                   `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+	HasNext    bool                  `json:"has_next"`
+	HasPrev    bool                  `json:"has_prev"`
+	Sort       string                `json:"sort"`
+	Order      string                `json:"order"`
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("list", "list [flags]")
+	// The server validates these; unset flags are left out of the query so
+	// its defaults apply.
+	params := []struct{ name, help string }{
+		{"status", "only this status, e.g. failed"},
+		{"channel", "only this channel"},
+		{"dry_run", "true for dry runs only, false for real sends only"},
+		{"from", "created at or after this RFC3339 time"},
+		{"to", "created at or before this RFC3339 time"},
+		{"sort", "created_at, updated_at, scheduled_at or sent_at"},
+		{"order", "asc or desc"},
+		{"page", "page number, from 1"},
+		{"limit", "page size"},
+	}
+	values := make([]*string, len(params))
+	for i, p := range params {
+		values[i] = fs.String(p.name, "", p.help)
+	}
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	query := url.Values{}
+	for i, p := range params {
+		if *values[i] != "" {
+			query.Set(p.name, *values[i])
+		}
+	}
+
+	var page notificationPage
+	resp, err := e.c.do(ctx, request{Method: http.MethodGet, Path: "/api/v1/notifications", Query: query, Out: &page})
+	if err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.Raw(resp.Body)
+	}
+	rows := make([]string, len(page.Data))
+	for i, n := range page.Data {
+		rows[i] = notificationRow(n)
+	}
+	if err := e.out.Table(notificationHeader, rows); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out.w, "\npage %d of %d, %d total\n", page.Page, page.TotalPages, page.Total)
+	return nil
+}
+
+func runCancel(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("cancel", "cancel [-reason TEXT] ID")
+	reason := fs.String("reason", "", "why it was cancelled, kept as cancelled_reason")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one notification ID")
+	}
+	id := fs.Arg(0)
+	var body any
+	if *reason != "" {
+		body = map[string]string{"reason": *reason}
+	}
+	if _, err := e.c.do(ctx, request{Method: http.MethodDelete, Path: "/api/v1/notifications/" + url.PathEscape(id), Body: body}); err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.JSON(map[string]string{"id": id, "status": string(domain.StatusCancelled)})
+	}
+	fmt.Fprintf(e.out.w, "cancelled %s\n", id)
+	return nil
+}
+
+// batchResult is the API's response to a batch creation.
+type batchResult struct {
+	domain.Batch
+	Queued        *int `json:"queued,omitempty"`
+	Deferred      *int `json:"deferred,omitempty"`
+	Notifications []struct {
+		ID          string        `json:"id"`
+		Recipient   string        `json:"recipient"`
+		Status      domain.Status `json:"status"`
+		ScheduledAt *time.Time    `json:"scheduled_at,omitempty"`
+	} `json:"notifications,omitempty"`
+	Errors []domain.BatchItemError `json:"errors,omitempty"`
+}
+
+func runBatch(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("batch", "batch -file PATH [flags]")
+	file := fs.String("file", "", `JSON or CSV file of notifications, "-" for stdin (required)`)
+	format := fs.String("format", "", "json or csv; by default taken from the file extension, else json")
+	allowPartial := fs.Bool("allow-partial", false, "create the valid items even if some are rejected")
+	dryRun := fs.Bool("dry-run", false, "make every item a dry run")
+	idemKey := fs.String("idempotency-key", "", "X-Idempotency-Key, so a retried batch is not created twice")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	if *file == "" {
+		return usagef("-file is required")
+	}
+	req, err := readBatchFile(*file, *format)
+	if err != nil {
+		return err
+	}
+	if *allowPartial {
+		req.AllowPartial = true
+	}
+	if *dryRun {
+		req.DryRun = true
+	}
+	header := http.Header{}
+	if *idemKey != "" {
+		header.Set("X-Idempotency-Key", *idemKey)
+	}
+
+	var res batchResult
+	resp, err := e.c.do(ctx, request{Method: http.MethodPost, Path: "/api/v1/notifications/batch", Header: header, Body: req, Out: &res})
+	if err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.Raw(resp.Body)
+	}
+	fmt.Fprintf(e.out.w, "batch %s: %d created", res.ID, res.Total)
+	if res.Queued != nil {
+		fmt.Fprintf(e.out.w, ", %d queued", *res.Queued)
+	}
+	if res.Deferred != nil && *res.Deferred > 0 {
+		fmt.Fprintf(e.out.w, ", %d left pending (queue full)", *res.Deferred)
+	}
+	fmt.Fprintf(e.out.w, ", %d rejected\n\n", len(res.Errors))
+	rows := make([]string, len(res.Notifications))
+	for i, n := range res.Notifications {
+		rows[i] = fmt.Sprintf("%s\t%s\t%s\t%s", n.ID, n.Recipient, n.Status, formatTime(n.ScheduledAt))
+	}
+	if err := e.out.Table("ID\tRECIPIENT\tSTATUS\tSCHEDULED", rows); err != nil {
+		return err
+	}
+	if len(res.Errors) == 0 {
+		return nil
+	}
+	rows = make([]string, len(res.Errors))
+	for i, be := range res.Errors {
+		rows[i] = fmt.Sprintf("%d\t%s\t%s\t%s", be.Index, be.Field, be.Code, be.Error)
+	}
+	fmt.Fprintln(e.out.w)
+	return e.out.Table("INDEX\tFIELD\tCODE\tERROR", rows)
+}
+
+// stats is the API's metrics snapshot. Sections the server leaves out stay nil.
+type stats struct {
+	QueueDepth            map[string]int     `json:"queue_depth"`
+	QueueOldestAgeSeconds map[string]float64 `json:"queue_oldest_age_seconds"`
+	Workers               *struct {
+		Total int `json:"total"`
+		Busy  int `json:"busy"`
+	} `json:"workers,omitempty"`
+	LastMinute *struct {
+		Sent   int64 `json:"sent"`
+		Failed int64 `json:"failed"`
+	} `json:"last_minute,omitempty"`
+	RateLimiterUtilisation map[string]float64 `json:"rate_limiter_utilisation,omitempty"`
+}
+
+func runStats(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("stats", "stats")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	var s stats
+	resp, err := e.c.do(ctx, request{Method: http.MethodGet, Path: "/api/v1/metrics", Out: &s})
+	if err != nil {
+		return err
+	}
+	if e.out.json {
+		return e.out.Raw(resp.Body)
+	}
+
+	rows := make([]string, 0, 3)
+	for _, p := range []domain.Priority{domain.PriorityHigh, domain.PriorityNormal, domain.PriorityLow} {
+		rows = append(rows, fmt.Sprintf("%s\t%d\t%.1fs", p, s.QueueDepth[string(p)], s.QueueOldestAgeSeconds[string(p)]))
+	}
+	if err := e.out.Table("PRIORITY\tQUEUED\tOLDEST", rows); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out.w, "\nqueued: %d\n", s.QueueDepth["total"])
+	if s.Workers != nil {
+		fmt.Fprintf(e.out.w, "workers: %d of %d busy\n", s.Workers.Busy, s.Workers.Total)
+	}
+	if s.LastMinute != nil {
+		fmt.Fprintf(e.out.w, "last minute: %d sent, %d failed\n", s.LastMinute.Sent, s.LastMinute.Failed)
+	}
+	if s.RateLimiterUtilisation != nil {
+		fmt.Fprintln(e.out.w)
+		rows = rows[:0]
+		for _, c := range []domain.Channel{domain.ChannelSMS, domain.ChannelEmail, domain.ChannelPush} {
+			rows = append(rows, fmt.Sprintf("%s\t%.0f%%", c, s.RateLimiterUtilisation[string(c)]*100))
+		}
+		return e.out.Table("CHANNEL\tRATE LIMIT USED", rows)
+	}
+	return nil
+}
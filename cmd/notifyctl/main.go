@@ -0,0 +1,162 @@
+// Command notifyctl sends and inspects notifications through the service's
+// HTTP API, so operators do not have to hand-craft curl commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: notifyctl [flags] command [command flags]
+
+commands:
+  send      create a notification
+  get ID    show one notification
+  list      list notifications, newest first
+  cancel ID cancel a notification that has not been sent yet
+  batch     create a batch from a JSON or CSV file
+  stats     show the queue, worker and throughput snapshot
+
+Run "notifyctl command -h" for a command's flags.
+
+flags:`
+
+// Exit codes. An error answered by the API and a failure to reach it both
+// exit 1; bad arguments exit 2 before anything is sent.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// command runs one subcommand with the arguments after its name.
+type command func(ctx context.Context, e *env, args []string) error
+
+// env is what every command works with.
+type env struct {
+	c      *client
+	out    *printer
+	stderr io.Writer // flag errors and command help
+}
+
+// flags returns the flag set of the named command; synopsis is the first line
+// of its -h output.
+func (e *env) flags(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: notifyctl %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args into fs. The flag package has already reported a parse
+// error, so the usageError it returns carries no message of its own.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{}
+	}
+	return nil
+}
+
+var commands = map[string]command{
+	"send":   runSend,
+	"get":    runGet,
+	"list":   runList,
+	"cancel": runCancel,
+	"batch":  runBatch,
+	"stats":  runStats,
+}
+
+// usageError reports bad arguments, which exit 2. An empty message means the
+// error has already been printed.
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
+
+// run parses the global flags, runs the named command and returns the exit
+// code. getenv supplies NOTIFYCTL_URL and NOTIFYCTL_API_KEY, the defaults of
+// -url and -api-key.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	fs := flag.NewFlagSet("notifyctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, usage)
+		fs.PrintDefaults()
+	}
+	baseURL := fs.String("url", envOr(getenv, "NOTIFYCTL_URL", "http://localhost:8080"), "service base URL (NOTIFYCTL_URL)")
+	apiKey := fs.String("api-key", getenv("NOTIFYCTL_API_KEY"), "API key sent as X-API-Key (NOTIFYCTL_API_KEY)")
+	output := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit for each request")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		fs.Usage()
+		return exitUsage
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "-o must be table or json, got %q\n", *output)
+		return exitUsage
+	}
+
+	c := newClient(*baseURL, *apiKey, &http.Client{Timeout: *timeout})
+	e := &env{c: c, out: &printer{w: stdout, json: *output == "json"}, stderr: stderr}
+	err := cmd(ctx, e, fs.Args()[1:])
+	var ue *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &ue):
+		if ue.msg != "" {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		}
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return exitFailed
+	}
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// recorded is a request the test server received.
+type recorded struct {
+	method, path, query string
+	header              http.Header
+	body                []byte
+}
+
+// newServer answers every request with status and body, recording it in got.
+func newServer(t *testing.T, status int, body string, got *recorded) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		*got = recorded{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header, body: b}
+		if body != "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// runCLI runs notifyctl against url with NOTIFYCTL_API_KEY set to "ops-key".
+func runCLI(url string, args ...string) (code int, stdout, stderr string) {
+	env := map[string]string{"NOTIFYCTL_URL": url, "NOTIFYCTL_API_KEY": "ops-key"}
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), args, &out, &errOut, func(k string) string { return env[k] })
+	return code, out.String(), errOut.String()
+}
+
+const notificationJSON = `{"id":"n-1","channel":"sms","recipient":"+905551234567","content":"hi","priority":"high",
+"status":"queued","retry_count":0,"max_retries":3,"created_at":"2026-10-17T09:00:00Z","updated_at":"2026-10-17T09:00:00Z"}`
+
+func TestSend(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusCreated, notificationJSON, &got)
+
+	code, out, errOut := runCLI(srv.URL, "send", "-channel", "sms", "-to", "+905551234567", "-content", "hi",
+		"-priority", "high", "-schedule", "2026-10-18T09:00:00Z", "-idempotency-key", "order-42", "-dry-run")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+	if got.method != http.MethodPost || got.path != "/api/v1/notifications" {
+		t.Fatalf("expected POST /api/v1/notifications, got %s %s", got.method, got.path)
+	}
+	if got.header.Get("X-API-Key") != "ops-key" || got.header.Get("X-Idempotency-Key") != "order-42" ||
+		got.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", got.header)
+	}
+	var req domain.CreateNotificationRequest
+	if err := json.Unmarshal(got.body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Channel != domain.ChannelSMS || req.Recipient != "+905551234567" || req.Priority != domain.PriorityHigh ||
+		!req.DryRun || req.ScheduledAt == nil || req.ScheduledAt.Format("2006-01-02") != "2026-10-18" {
+		t.Fatalf("unexpected request body %s", got.body)
+	}
+	if !strings.Contains(out, "n-1") || !strings.Contains(out, "queued") {
+		t.Fatalf("expected the notification in the table, got %q", out)
+	}
+}
+
+func TestSend_QueueFullIsReported(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusAccepted, `{"id":"n-1","status":"pending","queued":false}`, &got)
+	code, out, _ := runCLI(srv.URL, "send", "-channel", "sms", "-to", "+905551234567", "-content", "hi")
+	if code != exitOK || !strings.Contains(out, "not queued") {
+		t.Fatalf("expected exit 0 with a not-queued note, got %d %q", code, out)
+	}
+}
+
+func TestSend_BadFlagsExitTwoWithoutRequest(t *testing.T) {
+	for name, args := range map[string][]string{
+		"missing recipient": {"send", "-channel", "sms", "-content", "hi"},
+		"bad channel":       {"send", "-channel", "fax", "-to", "x", "-content", "hi"},
+		"bad schedule":      {"send", "-channel", "sms", "-to", "x", "-content", "hi", "-schedule", "tomorrow"},
+		"unknown flag":      {"send", "-colour", "red"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got recorded
+			srv := newServer(t, http.StatusCreated, notificationJSON, &got)
+			if code, _, _ := runCLI(srv.URL, args...); code != exitUsage {
+				t.Fatalf("expected exit %d, got %d", exitUsage, code)
+			}
+			if got.method != "" {
+				t.Fatalf("expected no request, got %s %s", got.method, got.path)
+			}
+		})
+	}
+}
+
+func TestGet_APIErrorExitsOne(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusNotFound, `{"error":{"code":"not_found","message":"notification not found"}}`, &got)
+	code, out, errOut := runCLI(srv.URL, "get", "n/1")
+	if code != exitFailed {
+		t.Fatalf("expected exit %d, got %d", exitFailed, code)
+	}
+	if got.path != "/api/v1/notifications/n/1" || !strings.Contains(got.header.Get("X-API-Key"), "ops-key") {
+		t.Fatalf("unexpected request %s %v", got.path, got.header)
+	}
+	if out != "" || !strings.Contains(errOut, "404 not_found: notification not found") {
+		t.Fatalf("expected the API error on stderr, got %q / %q", out, errOut)
+	}
+}
+
+func TestGet_JSONOutputIsTheResponse(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusOK, `{"id":"n-1","status":"sent","provider_message_id":"p-9"}`, &got)
+	code, out, _ := runCLI(srv.URL, "-o", "json", "get", "n-1")
+	if code != exitOK || !strings.Contains(out, `"provider_message_id": "p-9"`) {
+		t.Fatalf("expected the response indented, got %d %q", code, out)
+	}
+}
+
+func TestList(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusOK, `{"data":[`+notificationJSON+`],"total":21,"page":2,"limit":20,"total_pages":2}`, &got)
+	code, out, errOut := runCLI(srv.URL, "list", "-status", "queued", "-channel", "sms", "-page", "2")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+	if got.query != "channel=sms&page=2&status=queued" {
+		t.Fatalf("expected only the set filters in the query, got %q", got.query)
+	}
+	if !strings.Contains(out, "ID") || !strings.Contains(out, "+905551234567") || !strings.Contains(out, "page 2 of 2, 21 total") {
+		t.Fatalf("unexpected table %q", out)
+	}
+}
+
+func TestList_ValidationErrorListsDetails(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusBadRequest, `{"error":{"code":"bad_request","message":"invalid query parameters",
+"details":[{"field":"limit","code":"out_of_range","message":"limit must be between 1 and 100"}]}}`, &got)
+	code, _, errOut := runCLI(srv.URL, "list", "-limit", "500")
+	if code != exitFailed || !strings.Contains(errOut, "limit: limit must be between 1 and 100") {
+		t.Fatalf("expected exit 1 with the details, got %d %q", code, errOut)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusNoContent, "", &got)
+	code, out, errOut := runCLI(srv.URL, "cancel", "-reason", "withdrawn", "n-1")
+	if code != exitOK || out != "cancelled n-1\n" {
+		t.Fatalf("expected a confirmation, got %d %q %s", code, out, errOut)
+	}
+	if got.method != http.MethodDelete || got.path != "/api/v1/notifications/n-1" || string(got.body) != `{"reason":"withdrawn"}` {
+		t.Fatalf("unexpected request %s %s %s", got.method, got.path, got.body)
+	}
+}
+
+func TestBatch_CSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "campaign.csv")
+	csv := "channel,recipient,content,priority,var.name\n" +
+		"sms,+901111111111,,high,Ada\n" +
+		"email,a@b.com,\"Sale, today\",,\n"
+	if err := os.WriteFile(path, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var got recorded
+	srv := newServer(t, http.StatusCreated, `{"id":"b-1","total":2,"queued":2,
+"notifications":[{"id":"n-1","recipient":"+901111111111","status":"queued"},{"id":"n-2","recipient":"a@b.com","status":"queued"}]}`, &got)
+
+	code, out, errOut := runCLI(srv.URL, "batch", "-file", path, "-allow-partial")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+	var req domain.CreateBatchRequest
+	if err := json.Unmarshal(got.body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/api/v1/notifications/batch" || !req.AllowPartial || len(req.Notifications) != 2 {
+		t.Fatalf("unexpected request %s %s", got.path, got.body)
+	}
+	first, second := req.Notifications[0], req.Notifications[1]
+	if first.Priority != domain.PriorityHigh || first.Variables["name"] != "Ada" || first.Content != "" {
+		t.Fatalf("unexpected first item %+v", first)
+	}
+	if second.Content != "Sale, today" || second.Priority != domain.PriorityNormal || second.Variables != nil {
+		t.Fatalf("unexpected second item %+v", second)
+	}
+	if !strings.Contains(out, "batch b-1: 2 created, 2 queued, 0 rejected") || !strings.Contains(out, "n-2") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestParseBatchJSON(t *testing.T) {
+	for name, body := range map[string]string{
+		"request body": `{"notifications":[{"channel":"sms","recipient":"x","content":"hi","priority":"low"}],"allow_partial":true}`,
+		"bare array":   `[{"channel":"sms","recipient":"x","content":"hi","priority":"low"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := parseBatchJSON([]byte(body))
+			if err != nil || len(req.Notifications) != 1 || req.Notifications[0].Priority != domain.PriorityLow {
+				t.Fatalf("unexpected result %+v, %v", req, err)
+			}
+		})
+	}
+	if _, err := parseBatchJSON([]byte(`[]`)); err == nil {
+		t.Fatal("expected an empty batch to be refused")
+	}
+}
+
+func TestParseBatchCSV_Errors(t *testing.T) {
+	tests := map[string]struct{ csv, want string }{
+		"unknown column": {"channel,recipents\nsms,x\n", `unknown column "recipents"`},
+		"bad schedule":   {"channel,scheduled_at\nsms,2026-10-18T09:00:00Z\nsms,soon\n", "line 3: scheduled_at"},
+		"bad retries":    {"channel,max_retries\nsms,many\n", "max_retries must be a number"},
+		"bad dry run":    {"channel,dry_run\nsms,perhaps\n", "dry_run must be true or false"},
+		"header only":    {"channel,recipient\n", "no notifications"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseBatchCSV(strings.NewReader(tt.csv))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	var got recorded
+	srv := newServer(t, http.StatusOK, `{"queue_depth":{"high":1,"normal":42,"low":7,"total":50},
+"queue_oldest_age_seconds":{"high":0.5,"normal":3.2,"low":41.7},"workers":{"total":8,"busy":5},
+"last_minute":{"sent":1250,"failed":3},"rate_limiter_utilisation":{"sms":0.85,"email":0.1,"push":0}}`, &got)
+	code, out, errOut := runCLI(srv.URL, "stats")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, errOut)
+	}
+	for _, want := range []string{"normal    42", "queued: 50", "workers: 5 of 8 busy", "1250 sent, 3 failed", "sms      85%"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	if code, _, errOut := runCLI("http://unused", "frobnicate"); code != exitUsage || !strings.Contains(errOut, "usage:") {
+		t.Fatalf("expected exit %d with usage for an unknown command, got %d %q", exitUsage, code, errOut)
+	}
+	if code, _, _ := runCLI("http://unused"); code != exitUsage {
+		t.Fatalf("expected exit %d without a command, got %d", exitUsage, code)
+	}
+	if code, _, _ := runCLI("http://unused", "-o", "yaml", "stats"); code != exitUsage {
+		t.Fatalf("expected exit %d for an unknown output format, got %d", exitUsage, code)
+	}
+	if code, _, _ := runCLI("http://unused", "get", "-h"); code != exitOK {
+		t.Fatalf("expected exit 0 for command help, got %d", code)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// printer writes command results as aligned tables or, with -o json, as the
+// JSON the API returned.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// Raw writes a JSON response body indented.
+func (p *printer) Raw(body []byte) error {
+	var b bytes.Buffer
+	if err := json.Indent(&b, body, "", "  "); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+	_, err := p.w.Write(b.Bytes())
+	return err
+}
+
+// JSON writes v indented, one document per call.
+func (p *printer) JSON(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Table writes rows under header, columns separated by tabs in each row.
+func (p *printer) Table(header string, rows []string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	for _, r := range rows {
+		fmt.Fprintln(tw, r)
+	}
+	return tw.Flush()
+}
+
+const notificationHeader = "ID\tCHANNEL\tRECIPIENT\tPRIORITY\tSTATUS\tRETRIES\tSCHEDULED\tCREATED"
+
+func notificationRow(n domain.Notification) string {
+	return strings.Join([]string{
+		n.ID,
+		string(n.Channel),
+		n.Recipient,
+		string(n.Priority),
+		string(n.Status),
+		fmt.Sprintf("%d/%d", n.RetryCount, n.MaxRetries),
+		formatTime(n.ScheduledAt),
+		n.CreatedAt.UTC().Format(time.RFC3339),
+	}, "\t")
+}
+
+// Notification writes one notification as a table row, plus its error or
+// cancellation reason if any.
+func (p *printer) Notification(n domain.Notification) error {
+	if err := p.Table(notificationHeader, []string{notificationRow(n)}); err != nil {
+		return err
+	}
+	if n.ErrorMessage != nil {
+		fmt.Fprintf(p.w, "\nerror: %s\n", *n.ErrorMessage)
+	}
+	if n.DryRun {
+		fmt.Fprintln(p.w, "\ndry run: never handed to the provider")
+	}
+	if n.CancelledReason != nil {
+		fmt.Fprintf(p.w, "\ncancelled: %s\n", *n.CancelledReason)
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
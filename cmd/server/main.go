@@ -3,92 +3,474 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/ricirt/event-driven-arch/docs"
 	"github.com/ricirt/event-driven-arch/internal/api"
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	apimw "github.com/ricirt/event-driven-arch/internal/api/middleware"
+	"github.com/ricirt/event-driven-arch/internal/audit"
 	"github.com/ricirt/event-driven-arch/internal/config"
 	"github.com/ricirt/event-driven-arch/internal/db"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/metrics"
+	"github.com/ricirt/event-driven-arch/internal/progress"
 	"github.com/ricirt/event-driven-arch/internal/provider"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
 	"github.com/ricirt/event-driven-arch/internal/repository"
 	"github.com/ricirt/event-driven-arch/internal/service"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
+	"github.com/ricirt/event-driven-arch/internal/version"
 	"github.com/ricirt/event-driven-arch/internal/worker"
 )
 
 func main() {
-	logger, _ := zap.NewProduction()
+	// The level is atomic so a config reload can change it in place.
+	logLevel := zap.NewAtomicLevel()
+	zc := zap.NewProductionConfig()
+	zc.Level = logLevel
+	logger, _ := zc.Build()
 	defer logger.Sync() //nolint:errcheck
 
+	build := version.Get()
+	logger.Info("notification service build",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime),
+		zap.String("go_version", build.GoVersion),
+	)
+
 	// ---- configuration ----
-	cfg, err := config.Load()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables override its keys")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	command := flag.Arg(0)
+	if command != "" && command != "serve" && command != "migrate" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	loadConfig := config.Load
+	if *configFile != "" {
+		loadConfig = func() (*config.Config, error) { return config.LoadFrom(*configFile) }
+	}
+	cfg, err := loadConfig()
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
+	if *configFile != "" {
+		logger.Info("config file loaded", zap.String("path", *configFile))
+	}
+	level, _ := zapcore.ParseLevel(cfg.LogLevel) // validated by loadConfig
+	logLevel.SetLevel(level)
 
-	// ---- database ----
+	if command == "migrate" {
+		code := runMigrate(cfg, flag.Args()[1:], os.Stdout, os.Stderr, logger)
+		logger.Sync() //nolint:errcheck
+		os.Exit(code)
+	}
+
+	// ---- tracing ----
 	ctx := context.Background()
-	pool, err := db.Connect(ctx, cfg)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:    cfg.OTelEndpoint,
+		SampleRatio: cfg.TraceSampleRatio,
+		ServiceName: cfg.OTelServiceName,
+	})
+	if err != nil {
+		logger.Fatal("failed to set up tracing", zap.Error(err))
+	}
+
+	// ---- database ----
+	// SIGINT/SIGTERM while waiting for the database abandons startup.
+	startupCtx, stopStartup := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	pool, err := db.Connect(startupCtx, cfg, logger)
 	if err != nil {
 		logger.Fatal("failed to connect to database", zap.Error(err))
 	}
 	defer pool.Close()
 
-	if err := db.Migrate(cfg.DatabaseURL); err != nil {
+	// One instance migrates at a time; the others wait, then find nothing to do.
+	err = db.WithMigrationLock(startupCtx, cfg.DatabaseURL, cfg.DBMigrationLockTimeout, logger, func() error {
+		if err := db.Migrate(startupCtx, cfg.DatabaseURL, cfg.DBStartupMaxWait, logger); err != nil {
+			return err
+		}
+		logger.Info("database migrations applied")
+
+		if cfg.PartitionNotifications {
+			if err := db.MigratePartitioning(startupCtx, cfg.DatabaseURL, cfg.DBStartupMaxWait, logger); err != nil {
+				return fmt.Errorf("partitioning: %w", err)
+			}
+			logger.Info("notifications table partitioning applied")
+		}
+		return nil
+	})
+	if err != nil {
 		logger.Fatal("failed to run migrations", zap.Error(err))
 	}
-	logger.Info("database migrations applied")
+	stopStartup()
 
 	// ---- core dependencies ----
 	reg := prometheus.NewRegistry()
-	m := metrics.New(reg)
+	m := metrics.New(reg, metrics.Options{
+		DeliveryBuckets:   cfg.DeliveryLatencyBuckets,
+		LatencyBuckets:    cfg.LatencyBuckets,
+		RuntimeCollectors: cfg.MetricsRuntime,
+	})
+	metrics.RegisterPool(reg, pool)
 	q := queue.New()
-	repo := repository.NewPgNotificationRepository(pool)
+	repo := repository.NewPgNotificationRepository(pool, repository.PgOptions{
+		QueryTimeout: cfg.DBQueryTimeout,
+		MaxRetries:   cfg.DBQueryRetries,
+		RetryBackoff: cfg.DBQueryRetryBackoff,
+	})
+	templateRepo := repository.NewPgTemplateRepository(pool)
 	prov := provider.NewWebhookProvider(cfg.ProviderBaseURL, cfg.ProviderTimeout)
-	limiter := ratelimiter.New(cfg.RateLimit)
-	svc := service.NewNotificationService(repo, q, logger)
+	limiter := ratelimiter.New(cfg.RateLimit, m.RateLimiterHook(cfg.RateLimitSlowWait))
+	m.SetRateLimits(limiter.Limits())
+	callbacks := worker.NewCallbackDispatcher(repository.NewPgCallbackRepository(pool), worker.CallbackOptions{
+		Secret:       cfg.CallbackSecret,
+		Timeout:      cfg.CallbackTimeout,
+		MaxAttempts:  cfg.CallbackMaxAttempts,
+		Backoff:      cfg.CallbackBackoff,
+		PollInterval: cfg.CallbackPollInterval,
+		Concurrency:  cfg.CallbackConcurrency,
+	}, logger)
+
+	// Lifecycle events are buffered so a slow broker never holds up a request
+	// or a worker; the buffer is drained until shutdown (see below).
+	var publisher events.Publisher = events.Nop{}
+	var eventsAsync *events.Async
+	if cfg.EventPublisher == "kafka" {
+		kafka := events.NewKafkaPublisher(cfg.KafkaRESTURL, cfg.KafkaTopic, cfg.EventPublishTimeout)
+		eventsAsync = events.NewAsync(kafka, cfg.EventBufferSize, cfg.EventPublishTimeout, m.EventsDropped.Inc, logger)
+		publisher = eventsAsync
+	}
+
+	// API mutations are audited through a buffer of the same kind, so a slow
+	// database delays the audit log rather than the requests.
+	var auditLog *audit.Log
+	if cfg.AuditLog {
+		auditLog = audit.NewLog(repository.NewPgAuditRepository(pool), cfg.AuditBufferSize, cfg.DBQueryTimeout, m.AuditDropped.Inc, logger)
+	}
+
+	// Refuse callback URLs that would make us call ourselves.
+	blockedHosts := cfg.CallbackBlockedHosts
+	if hostname, err := os.Hostname(); err == nil {
+		blockedHosts = append(blockedHosts, hostname)
+	}
+
+	// Long-polling waits are woken by the same terminal transitions that
+	// trigger callbacks.
+	waiters := progress.NewWaiters()
+	onTerminal := func(n *domain.Notification) {
+		callbacks.Notify(n)
+		waiters.Notify(n)
+	}
+
+	// Batch counters are refreshed in debounced rounds; every refresh is
+	// pushed to the clients streaming that batch's progress.
+	progressHub := progress.NewHub()
+	batchCounter := worker.NewBatchCounter(repo, cfg.BatchCountInterval, cfg.ShutdownTimeout, progressHub.Publish, m.OnBatchCompleted, logger)
+
+	svc := service.NewNotificationService(repo, q, logger, service.Options{
+		Validation: domain.ValidationRules{
+			MaxScheduleHorizon:   cfg.MaxScheduleHorizon,
+			ScheduleSkew:         cfg.ScheduleClockSkew,
+			BlockedCallbackHosts: blockedHosts,
+			ContentLimits:        cfg.ContentLimits,
+			DefaultCountryCode:   cfg.DefaultCountryCode,
+		},
+		Templates:     templateRepo,
+		OnTerminal:    onTerminal,
+		DedupWindow:   cfg.DedupWindow,
+		StrictEnqueue: cfg.StrictEnqueue,
+		MaxLookupIDs:  cfg.MaxLookupIDs,
+		OnQueueFull:   m.OnQueueFull,
+		Sandbox:       cfg.SandboxMode,
+
+		OnBatchChanged: batchCounter.Touch,
+
+		DefaultMaxRetries: &cfg.DefaultMaxRetries,
+		// Each channel drains at most RateLimit items per second.
+		DrainRate: cfg.RateLimit * 3,
+		Events:    publisher,
+		CreationLimiter: ratelimiter.NewTenant(cfg.TenantLimits, cfg.TenantLimitOverrides, func(tenant string) {
+			m.CreatesThrottled.WithLabelValues(tenant).Inc()
+		}),
+	})
+	templateSvc := service.NewTemplateService(templateRepo)
 
 	// ---- worker pool ----
+	if cfg.SandboxMode {
+		logger.Warn("SANDBOX_MODE set: notifications are marked sent without calling the provider")
+	}
 	// Context for all background goroutines; cancelled on shutdown signal.
 	workerCtx, cancelWorkers := context.WithCancel(ctx)
 	defer cancelWorkers()
 
 	onSent, onFailed := m.WorkerHooks()
-	pool2 := worker.NewPool(cfg, q, repo, prov, limiter, logger, worker.MetricHooks{
-		OnSent:   onSent,
-		OnFailed: onFailed,
+	pool2 := worker.NewPool(cfg, q, repo, prov, limiter, logger, worker.Hooks{
+		OnSent:         onSent,
+		OnFailed:       onFailed,
+		OnTerminal:     onTerminal,
+		Events:         publisher,
+		OnBatchChanged: batchCounter.Touch,
+		OnRetry:        m.OnRetry,
+		OnDelivered:    m.OnDelivered,
 	})
+	if len(cfg.RecipientLimits) > 0 {
+		onThrottle, onEvict := m.RecipientLimiterHooks()
+		pool2.LimitRecipients(ratelimiter.NewRecipient(cfg.RecipientLimits, cfg.RecipientLimiterMaxKeys, onThrottle, onEvict))
+	}
 	pool2.Start(workerCtx)
 
-	retryW := worker.NewRetryWorker(repo, q, cfg.RetryInterval, logger)
-	go retryW.Run(workerCtx)
+	// The dispatcher outlives the workers so it can persist callbacks they
+	// hand over while finishing their last message.
+	callbackCtx, cancelCallbacks := context.WithCancel(ctx)
+	defer cancelCallbacks()
+	callbacksDone := make(chan struct{})
+	go func() {
+		defer close(callbacksDone)
+		callbacks.Run(callbackCtx)
+	}()
 
-	schedulerW := worker.NewSchedulerWorker(repo, q, cfg.SchedulerInterval, logger)
-	go schedulerW.Run(workerCtx)
+	// The counter, too, flushes once more after the workers stop.
+	batchCounterDone := make(chan struct{})
+	go func() {
+		defer close(batchCounterDone)
+		batchCounter.Run(callbackCtx)
+	}()
+
+	// Like the dispatcher, the event publisher drains after the workers stop.
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		if eventsAsync != nil {
+			eventsAsync.Run(callbackCtx)
+		}
+	}()
+
+	// The audit log drains once the HTTP server has stopped, too.
+	auditDone := make(chan struct{})
+	go func() {
+		defer close(auditDone)
+		if auditLog != nil {
+			auditLog.Run(callbackCtx)
+		}
+	}()
+
+	onDue, onRequeued := m.RetryHooks()
+	retryW := worker.NewRetryWorker(repo, q, cfg.RetryInterval, cfg.PollPageSize, publisher, worker.RetryHooks{
+		OnQueueFull: m.OnQueueFull,
+		OnDue:       onDue,
+		OnRequeued:  onRequeued,
+		OnPoll:      m.PollHook("retry"),
+		OnOverrun:   m.OverrunHook("retry"),
+	}, logger)
+
+	schedulerW := worker.NewSchedulerWorker(repo, q, cfg.SchedulerInterval, cfg.PollPageSize, publisher, worker.SchedulerHooks{
+		OnQueueFull: m.OnQueueFull,
+		OnPoll:      m.PollHook("scheduler"),
+		OnOverrun:   m.OverrunHook("scheduler"),
+		OnOverdue:   m.SetScheduledOverdue,
+	}, logger)
+
+	if cfg.StatusCountInterval > 0 {
+		statusW := worker.NewStatusCountWorker(repo, cfg.StatusCountInterval, m.SetStatusCounts, logger)
+		go statusW.Run(workerCtx)
+	}
+
+	var partitionW *worker.PartitionWorker
+	if cfg.PartitionNotifications {
+		partitionW = worker.NewPartitionWorker(
+			repository.NewPgPartitionRepository(pool),
+			cfg.PartitionMaintenanceEvery,
+			cfg.PartitionPrecreateMonths,
+			cfg.RetentionMonths,
+			logger,
+		)
+	}
+
+	// The pollers run on every instance, or with LEADER_ELECTION only on the
+	// holder of the leader lock; runPollers returns once they have stopped.
+	runPollers := func(ctx context.Context) {
+		var wg sync.WaitGroup
+		run := func(fn func(context.Context)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn(ctx)
+			}()
+		}
+		run(retryW.Run)
+		run(schedulerW.Run)
+		if partitionW != nil {
+			run(partitionW.Run)
+		}
+		wg.Wait()
+	}
+	var leader handler.LeaderChecker
+	pollersDone := make(chan struct{})
+	if cfg.LeaderElection {
+		elector := db.NewElector(cfg.DatabaseURL, cfg.LeaderCheckInterval, m.SetLeader, logger)
+		leader = elector
+		go func() {
+			defer close(pollersDone)
+			elector.Run(workerCtx, runPollers) // returns after the term ends
+		}()
+	} else {
+		go func() {
+			defer close(pollersDone)
+			runPollers(workerCtx)
+		}()
+	}
 
 	// ---- HTTP server ----
-	router := api.NewRouter(svc, q, reg, logger)
+	if len(cfg.APIKeys) == 0 {
+		logger.Warn("API_KEYS not set: authentication disabled, all records visible to every caller")
+	}
+	var provHealth provider.HealthChecker
+	if cfg.ProviderHealthURL != "" {
+		provHealth = provider.NewHTTPHealthCheck(cfg.ProviderHealthURL, cfg.ReadyTimeout)
+	}
+	ready := handler.NewReadinessHandler(pool, q, provHealth, leader, cfg.ReadyQueueMaxPercent, cfg.ReadyTimeout)
+	var httpLimiter *apimw.ClientRateLimiter
+	if cfg.HTTPRateLimit > 0 {
+		httpLimiter = apimw.NewClientRateLimiter(cfg.HTTPRateLimit, cfg.HTTPRateBurst, cfg.HTTPRateMaxClients)
+	}
+	var swagger *handler.SwaggerHandler
+	if cfg.SwaggerEnabled {
+		if swagger, err = handler.NewSwaggerHandler(docs.SwaggerYAML); err != nil {
+			logger.Fatal("failed to load OpenAPI spec", zap.Error(err))
+		}
+	}
+	// ---- config reload (SIGHUP and POST /api/v1/admin/reload) ----
+	reloader := config.NewReloader(cfg, loadConfig, config.ReloadTargets{
+		SetRateLimit: func(perSec int) {
+			limiter.SetRate(perSec)
+			m.SetRateLimits(limiter.Limits())
+		},
+		SetRetryBackoff:      pool2.SetRetryBackoff,
+		SetSchedulerInterval: schedulerW.SetInterval,
+		SetRetryInterval:     retryW.SetInterval,
+		SetLogLevel:          logLevel.SetLevel,
+	}, logger)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("SIGHUP received; reloading config")
+			reloader.Reload() //nolint:errcheck // logged by the reloader
+		}
+	}()
+
+	stats := handler.MetricsSources{Workers: pool2, Throughput: m, Limiters: limiter}
+	router := api.NewRouter(svc, templateSvc, q, reg, logger, api.RouterOptions{
+		Stats:               stats,
+		Ready:               ready,
+		Limiter:             httpLimiter,
+		Hub:                 progressHub,
+		Waiters:             waiters,
+		Swagger:             swagger,
+		Reloader:            reloader,
+		AuditLog:            auditLog,
+		Pprof:               cfg.EnablePprof && cfg.AdminPort == "",
+		CompressMinSize:     cfg.HTTPCompressMinSize,
+		MaxPageSize:         cfg.MaxPageSize,
+		MaxBodyBytes:        cfg.MaxBodyBytes,
+		MaxBatchBodyBytes:   cfg.MaxBatchBodyBytes,
+		HandlerTimeout:      cfg.HandlerTimeout,
+		BatchHandlerTimeout: cfg.BatchHandlerTimeout,
+		APIKeys:             cfg.APIKeys,
+		AdminKeys:           cfg.AdminAPIKeys,
+	})
 	srv := &http.Server{
 		Addr:         ":" + cfg.HTTPPort,
 		Handler:      router,
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 	}
+	serverTLS, err := api.NewTLS(api.TLSOptions{
+		CertFile:         cfg.TLSCertFile,
+		KeyFile:          cfg.TLSKeyFile,
+		AutocertDomains:  cfg.TLSAutocertDomains,
+		AutocertCacheDir: cfg.TLSAutocertCacheDir,
+	})
+	if err != nil {
+		logger.Fatal("failed to set up TLS", zap.Error(err))
+	}
+	// With TLS, HTTP_REDIRECT_PORT serves a plain listener that sends clients to HTTPS.
+	var redirectSrv *http.Server
+	if serverTLS != nil {
+		srv.TLSConfig = serverTLS.Config()
+		if cfg.HTTPRedirectPort != "" {
+			redirectSrv = &http.Server{
+				Addr:         ":" + cfg.HTTPRedirectPort,
+				Handler:      serverTLS.RedirectHandler(cfg.HTTPPort),
+				ReadTimeout:  cfg.ReadTimeout,
+				WriteTimeout: cfg.WriteTimeout,
+			}
+		}
+	}
+	// ADMIN_PORT serves only the profiler, without WriteTimeout so a
+	// ?seconds=N CPU profile can run longer than a normal response.
+	var adminSrv *http.Server
+	if cfg.EnablePprof && cfg.AdminPort != "" {
+		adminSrv = &http.Server{
+			Addr:        ":" + cfg.AdminPort,
+			Handler:     api.NewDebugHandler(),
+			ReadTimeout: cfg.ReadTimeout,
+		}
+	}
+	// Progress streams never go idle on their own; end them so Shutdown can finish.
+	srv.RegisterOnShutdown(progressHub.Close)
+	srv.RegisterOnShutdown(waiters.Close)
 
 	// Start server in a goroutine so it does not block the shutdown listener.
 	go func() {
-		logger.Info("server starting", zap.String("addr", srv.Addr))
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Info("server starting", zap.String("addr", srv.Addr), zap.Bool("tls", serverTLS != nil))
+		var err error
+		if serverTLS != nil {
+			err = srv.ListenAndServeTLS("", "") // certificates come from TLSConfig
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatal("server error", zap.Error(err))
 		}
 	}()
+	if redirectSrv != nil {
+		go func() {
+			logger.Info("HTTPS redirect starting", zap.String("addr", redirectSrv.Addr))
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal("redirect server error", zap.Error(err))
+			}
+		}()
+	}
+	if adminSrv != nil {
+		go func() {
+			logger.Info("admin server starting", zap.String("addr", adminSrv.Addr))
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal("admin server error", zap.Error(err))
+			}
+		}()
+	}
 
 	// ---- graceful shutdown ----
 	quit := make(chan os.Signal, 1)
@@ -103,12 +485,44 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("HTTP server shutdown error", zap.Error(err))
 	}
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("redirect server shutdown error", zap.Error(err))
+		}
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("admin server shutdown error", zap.Error(err))
+		}
+	}
 
-	// 2. Signal all workers to stop processing new queue items.
+	// 2. Signal all workers and pollers to stop.
 	cancelWorkers()
 
-	// 3. Wait for in-flight workers to finish their current message.
+	// 3. Wait for in-flight workers to finish their current message, and for
+	//    the pollers, so nothing enqueues any more.
 	pool2.Wait()
+	<-pollersDone
+
+	// 4. Items still buffered in the queue are claimed as queued; hand them
+	//    back to pending before the database pool closes.
+	releaseCtx, releaseCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if n := worker.ReleaseQueued(releaseCtx, q, repo, logger); n > 0 {
+		logger.Info("released queued notifications to pending", zap.Int("count", n))
+	}
+	releaseCancel()
+
+	// 5. Let the callback dispatcher persist whatever the workers handed it.
+	cancelCallbacks()
+	<-callbacksDone
+	<-batchCounterDone
+	<-eventsDone
+	<-auditDone
+
+	// 6. Flush the spans the drained workers produced.
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("tracing shutdown error", zap.Error(err))
+	}
 
 	logger.Info("server stopped cleanly")
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/db"
+)
+
+const usage = `usage: server [-config file] [command]
+
+commands:
+  serve              run the service (the default)
+  migrate up         apply pending migrations (and the partitioning ones
+                     when NOTIFICATIONS_PARTITIONED is set)
+  migrate down N     roll back the last N migrations
+  migrate version    print the applied migration version
+  migrate force V    mark version V applied and clean, after repairing a
+                     dirty migration by hand`
+
+// Exit codes of the migrate subcommands. exitDirty lets CI tell a schema
+// left dirty by a failed migration from an unreachable database.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+	exitDirty  = 3
+)
+
+// migrateCommand is a parsed migrate subcommand.
+type migrateCommand struct {
+	action string // up, down, version or force
+	n      int    // steps for down, the version for force
+}
+
+// parseMigrateCommand parses the arguments after "migrate".
+func parseMigrateCommand(args []string) (migrateCommand, error) {
+	if len(args) == 0 {
+		return migrateCommand{}, errors.New("migrate needs an action")
+	}
+	cmd := migrateCommand{action: args[0]}
+	switch cmd.action {
+	case "up", "version":
+		if len(args) != 1 {
+			return migrateCommand{}, fmt.Errorf("migrate %s takes no arguments", cmd.action)
+		}
+	case "down", "force":
+		if len(args) != 2 {
+			return migrateCommand{}, fmt.Errorf("migrate %s needs one number", cmd.action)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || (cmd.action == "down" && n == 0) {
+			return migrateCommand{}, fmt.Errorf("migrate %s: %q is not a valid number", cmd.action, args[1])
+		}
+		cmd.n = n
+	default:
+		return migrateCommand{}, fmt.Errorf("unknown migrate action %q", cmd.action)
+	}
+	return cmd, nil
+}
+
+// runMigrate runs a migrate subcommand against cfg's database and returns the
+// process exit code. The version goes to out; progress and errors are logged.
+func runMigrate(cfg *config.Config, args []string, out, errOut io.Writer, logger *zap.Logger) int {
+	cmd, err := parseMigrateCommand(args)
+	if err != nil {
+		fmt.Fprintf(errOut, "%v\n\n%s\n", err, usage)
+		return exitUsage
+	}
+
+	// SIGINT/SIGTERM while waiting for the database gives up.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	m, err := db.NewMigrator(ctx, cfg.DatabaseURL, cfg.DBStartupMaxWait, logger)
+	if err != nil {
+		logger.Error("failed to open migrations", zap.Error(err))
+		return exitFailed
+	}
+	defer m.Close()
+
+	// Changes wait their turn behind any instance migrating at startup.
+	locked := func(fn func() error) error {
+		return db.WithMigrationLock(ctx, cfg.DatabaseURL, cfg.DBMigrationLockTimeout, logger, fn)
+	}
+	switch cmd.action {
+	case "up":
+		err = locked(func() error {
+			if err := m.Up(); err != nil || !cfg.PartitionNotifications {
+				return err
+			}
+			return db.MigratePartitioning(ctx, cfg.DatabaseURL, cfg.DBStartupMaxWait, logger)
+		})
+	case "down":
+		err = locked(func() error { return m.Down(cmd.n) })
+	case "force":
+		err = locked(func() error { return m.Force(cmd.n) })
+	case "version":
+		var v uint
+		var dirty bool
+		if v, dirty, err = m.Version(); err == nil {
+			if dirty {
+				fmt.Fprintf(out, "%d (dirty)\n", v)
+				return exitDirty
+			}
+			fmt.Fprintf(out, "%d\n", v)
+		}
+	}
+	if err != nil {
+		logger.Error("migrate "+cmd.action+" failed", zap.Error(err))
+		return exitFailed
+	}
+	if cmd.action != "version" {
+		v, dirty, _ := m.Version()
+		logger.Info("migrate "+cmd.action+" done", zap.Uint("version", v), zap.Bool("dirty", dirty))
+	}
+	return exitOK
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/config"
+)
+
+func TestParseMigrateCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    migrateCommand
+		wantErr string
+	}{
+		{[]string{"up"}, migrateCommand{action: "up"}, ""},
+		{[]string{"version"}, migrateCommand{action: "version"}, ""},
+		{[]string{"down", "2"}, migrateCommand{action: "down", n: 2}, ""},
+		{[]string{"force", "0"}, migrateCommand{action: "force", n: 0}, ""},
+		{nil, migrateCommand{}, "needs an action"},
+		{[]string{"sideways"}, migrateCommand{}, "unknown migrate action"},
+		{[]string{"up", "3"}, migrateCommand{}, "takes no arguments"},
+		{[]string{"down"}, migrateCommand{}, "needs one number"},
+		{[]string{"down", "0"}, migrateCommand{}, "not a valid number"},
+		{[]string{"force", "-1"}, migrateCommand{}, "not a valid number"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			got, err := parseMigrateCommand(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error mentioning %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("expected %+v, got %+v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestRunMigrate_UsageErrorExitsTwo(t *testing.T) {
+	var out, errOut bytes.Buffer
+	code := runMigrate(&config.Config{}, []string{"down"}, &out, &errOut, zap.NewNop())
+	if code != exitUsage || !strings.Contains(errOut.String(), "usage:") || out.Len() != 0 {
+		t.Fatalf("expected exit %d with usage on stderr, got %d, %q", exitUsage, code, errOut.String())
+	}
+}
@@ -0,0 +1,10 @@
+// Package docs embeds the OpenAPI document maintained alongside the code, so
+// the server can publish it without reading files at runtime.
+package docs
+
+import _ "embed"
+
+// SwaggerYAML is the OpenAPI 3 specification of the HTTP API.
+//
+//go:embed swagger.yaml
+var SwaggerYAML []byte
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// NewDebugHandler serves the net/http/pprof profiles under /debug/pprof/ and
+// expvar under /debug/vars, for the ADMIN_PORT listener. It has no
+// authentication of its own: that port must only be reachable from inside
+// the deployment. NewRouter serves the same routes behind an admin key.
+func NewDebugHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Mount("/debug", chimw.Profiler())
+	return r
+}
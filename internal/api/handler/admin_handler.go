@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/audit"
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+// maxDeadLetterRequeue caps the IDs accepted by one bulk requeue.
+const maxDeadLetterRequeue = 1000
+
+// defaultRequeueOlderThan keeps RequeuePending away from rows a create
+// request is still in the middle of dispatching.
+const defaultRequeueOlderThan = time.Minute
+
+// Reloader re-reads the configuration and applies what it can at runtime;
+// *config.Reloader satisfies it.
+type Reloader interface {
+	Reload() (config.ReloadResult, error)
+}
+
+// AdminHandler serves operator endpoints that act across all owners.
+type AdminHandler struct {
+	svc         *service.NotificationService
+	reloader    Reloader
+	audit       *audit.Log
+	maxPageSize int
+	logger      *zap.Logger
+}
+
+// NewAdminHandler returns the admin handler; reloader and auditLog may be nil
+// when the reload and audit endpoints are not served.
+func NewAdminHandler(svc *service.NotificationService, reloader Reloader, auditLog *audit.Log, maxPageSize int, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{svc: svc, reloader: reloader, audit: auditLog, maxPageSize: maxPageSize, logger: logger}
+}
+
+// Reload handles POST /api/v1/admin/reload
+//
+// Re-reads the configuration, as SIGHUP does, and applies the settings that
+// can change at runtime: RATE_LIMIT_PER_CHANNEL, RETRY_BACKOFF,
+// SCHEDULER_INTERVAL, RETRY_INTERVAL and LOG_LEVEL. Other changed settings are
+// listed as ignored until a restart. A configuration that does not validate
+// is rejected with 422 and nothing changes.
+//
+// @Summary  Reload the configuration
+// @Tags     admin
+// @Produce  json
+// @Success  200  {object}  config.ReloadResult
+// @Failure  401  {object}  errorResponse
+// @Failure  422  {object}  errorResponse  "Configuration invalid; nothing applied"
+// @Router   /api/v1/admin/reload [post]
+func (h *AdminHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	result, err := h.reloader.Reload()
+	if err != nil {
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// requeueResponse reports the outcome of a requeue-pending run.
+type requeueResponse struct {
+	Found    int `json:"found"`
+	Requeued int `json:"requeued"`
+	Skipped  int `json:"skipped"`
+}
+
+// RequeuePending handles POST /api/v1/admin/requeue-pending
+//
+// Pending notifications are persisted but not on the queue, typically because
+// it was full when they were created. This puts up to 1000 of them, oldest
+// first, back on the queue; rows whose queue is still full are reported as
+// skipped and stay pending.
+//
+// @Summary  Requeue stranded pending notifications
+// @Tags     admin
+// @Produce  json
+// @Param    older_than  query     string  false  "Only rows pending for longer than this Go duration (default 1m)"
+// @Param    channel     query     string  false  "Only this channel"
+// @Success  200         {object}  requeueResponse
+// @Failure  400         {object}  errorResponse  "Invalid query parameter"
+// @Failure  401         {object}  errorResponse
+// @Router   /api/v1/admin/requeue-pending [post]
+func (h *AdminHandler) RequeuePending(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	olderThan := defaultRequeueOlderThan
+	var channel *domain.Channel
+	var invalid []domain.FieldError
+
+	if v := q.Get("older_than"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			invalid = append(invalid, domain.FieldError{Field: "older_than", Code: "invalid_duration",
+				Message: "older_than must be a non-negative duration such as 30s or 5m", Err: domain.ErrInvalidFilter})
+		} else {
+			olderThan = d
+		}
+	}
+	if v := q.Get("channel"); v != "" {
+		if ch := domain.Channel(v); ch.IsValid() {
+			channel = &ch
+		} else {
+			invalid = append(invalid, domain.FieldError{Field: "channel", Code: "invalid_channel",
+				Message: domain.ErrInvalidChannel.Error(), Err: domain.ErrInvalidFilter})
+		}
+	}
+	if len(invalid) > 0 {
+		respondBadQuery(w, &domain.ValidationError{Fields: invalid})
+		return
+	}
+
+	result, err := h.svc.RequeuePending(r.Context(), olderThan, channel)
+	if err != nil {
+		h.logger.Error("requeue pending failed", zap.Error(err))
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, requeueResponse{
+		Found: result.Found, Requeued: result.Requeued, Skipped: result.Skipped,
+	})
+}
+
+// ListDeadLetters handles GET /api/v1/admin/dead-letters
+//
+// Dead letters are notifications that failed for good: status failed with no
+// retry scheduled. They are listed most recently dead-lettered first, each
+// with its error_message, attempt count and last attempt time.
+//
+// @Summary  List dead-lettered notifications
+// @Tags     admin
+// @Produce  json
+// @Param    channel  query     string  false  "Filter by channel"
+// @Param    from     query     string  false  "Dead-lettered at or after (RFC3339)"
+// @Param    to       query     string  false  "Dead-lettered at or before (RFC3339)"
+// @Param    page     query     int     false  "Page number (default 1)"
+// @Param    limit    query     int     false  "Items per page (default 20, max MAX_PAGE_SIZE: 100 unless configured)"
+// @Success  200      {object}  pageResponse[domain.DeadLetter]
+// @Failure  400      {object}  errorResponse  "Invalid query parameter"
+// @Failure  401      {object}  errorResponse
+// @Router   /api/v1/admin/dead-letters [get]
+func (h *AdminHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDeadLetterFilter(r, h.maxPageSize)
+	if err != nil {
+		respondBadQuery(w, err)
+		return
+	}
+	letters, total, err := h.svc.ListDeadLetters(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("list dead letters failed", zap.Error(err))
+		respondError(w, http.StatusInternalServerError, "failed to list dead letters")
+		return
+	}
+
+	// The envelope reports the fixed order the repository applies.
+	respondJSON(w, http.StatusOK, newPageResponse(letters, total, domain.ListFilter{
+		Page: filter.Page, Limit: filter.Limit, Sort: domain.SortUpdatedAt, Order: domain.OrderDesc,
+	}))
+}
+
+// GetDeadLetter handles GET /api/v1/admin/dead-letters/{id}
+//
+// @Summary  Get a dead-lettered notification and its delivery attempts
+// @Tags     admin
+// @Produce  json
+// @Param    id   path      string  true  "Notification UUID"
+// @Success  200  {object}  domain.DeadLetter
+// @Failure  401  {object}  errorResponse
+// @Failure  404  {object}  errorResponse  "Unknown or not dead-lettered"
+// @Router   /api/v1/admin/dead-letters/{id} [get]
+func (h *AdminHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	dl, err := h.svc.GetDeadLetter(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, dl)
+}
+
+// RequeueDeadLetter handles POST /api/v1/admin/dead-letters/{id}/requeue
+//
+// The notification's retry count is reset and it goes straight back on the
+// queue.
+//
+// @Summary  Requeue one dead-lettered notification
+// @Tags     admin
+// @Produce  json
+// @Param    id   path      string  true  "Notification UUID"
+// @Success  200  {object}  domain.Notification
+// @Failure  401  {object}  errorResponse
+// @Failure  404  {object}  errorResponse  "Unknown or not dead-lettered"
+// @Failure  503  {object}  errorResponse  "Queue full; the retry worker will pick it up"
+// @Router   /api/v1/admin/dead-letters/{id}/requeue [post]
+func (h *AdminHandler) RequeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	n, err := h.svc.RequeueDeadLetter(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, n)
+}
+
+// requeueDeadLettersRequest lists the dead letters to requeue.
+type requeueDeadLettersRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// requeueDeadLettersResponse sorts the requested IDs by outcome.
+type requeueDeadLettersResponse struct {
+	Requeued []string `json:"requeued"`
+	Deferred []string `json:"deferred"`
+	NotFound []string `json:"not_found"`
+}
+
+// RequeueDeadLetters handles POST /api/v1/admin/dead-letters/requeue
+//
+// Each ID is requeued as by the single-entry action. IDs that could not be
+// queued because the queue was full come back as "deferred"; the retry worker
+// picks them up on its next poll.
+//
+// @Summary  Requeue several dead-lettered notifications
+// @Tags     admin
+// @Accept   json
+// @Produce  json
+// @Param    body  body      requeueDeadLettersRequest  true  "Up to 1000 notification IDs"
+// @Success  200   {object}  requeueDeadLettersResponse
+// @Failure  400   {object}  errorResponse
+// @Failure  401   {object}  errorResponse
+// @Failure  413   {object}  errorResponse
+// @Failure  415   {object}  errorResponse
+// @Failure  422   {object}  errorResponse
+// @Router   /api/v1/admin/dead-letters/requeue [post]
+func (h *AdminHandler) RequeueDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var req requeueDeadLettersRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxDeadLetterRequeue {
+		msg := fmt.Sprintf("ids must list between 1 and %d notification IDs", maxDeadLetterRequeue)
+		respondValidation(w, msg, []domain.FieldError{{Field: "ids", Code: "out_of_range", Message: msg}})
+		return
+	}
+
+	result, err := h.svc.RequeueDeadLetters(r.Context(), req.IDs)
+	if err != nil {
+		h.logger.Error("requeue dead letters failed", zap.Error(err))
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, requeueDeadLettersResponse{
+		Requeued: nonNil(result.Requeued),
+		Deferred: nonNil(result.Deferred),
+		NotFound: nonNil(result.NotFound),
+	})
+}
+
+// auditSort is the fixed order of the audit log listing.
+const auditSort domain.SortField = "occurred_at"
+
+// ListAudit handles GET /api/v1/admin/audit
+//
+// The audit log holds one entry per mutating API request, successful or not:
+// the action, the notification, batch or template it targeted, the API key's
+// owner and fingerprint, the client IP, the correlation ID and the response
+// status. Entries are listed most recent first.
+//
+// @Summary  List audit log entries
+// @Tags     admin
+// @Produce  json
+// @Param    from       query     string  false  "Occurred at or after (RFC3339)"
+// @Param    to         query     string  false  "Occurred at or before (RFC3339)"
+// @Param    owner_id   query     string  false  "Filter by the API key's owner"
+// @Param    action     query     string  false  "Filter by action, e.g. notification.cancel"
+// @Param    target_id  query     string  false  "Filter by target resource ID"
+// @Param    page       query     int     false  "Page number (default 1)"
+// @Param    limit      query     int     false  "Items per page (default 20, max MAX_PAGE_SIZE: 100 unless configured)"
+// @Success  200        {object}  pageResponse[domain.AuditEntry]
+// @Failure  400        {object}  errorResponse  "Invalid query parameter"
+// @Failure  401        {object}  errorResponse
+// @Router   /api/v1/admin/audit [get]
+func (h *AdminHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r, h.maxPageSize)
+	if err != nil {
+		respondBadQuery(w, err)
+		return
+	}
+	entries, total, err := h.audit.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("list audit entries failed", zap.Error(err))
+		respondError(w, http.StatusInternalServerError, "failed to list audit entries")
+		return
+	}
+	respondJSON(w, http.StatusOK, newPageResponse(entries, total, domain.ListFilter{
+		Page: filter.Page, Limit: filter.Limit, Sort: auditSort, Order: domain.OrderDesc,
+	}))
+}
+
+// parseAuditFilter reads the audit list query parameters, with the same
+// paging defaults and limits as the notification list.
+func parseAuditFilter(r *http.Request, maxLimit int) (domain.AuditFilter, error) {
+	q := r.URL.Query()
+	filter := domain.AuditFilter{Page: 1, Limit: min(defaultListLimit, maxLimit)}
+	var invalid []domain.FieldError
+	reject := func(field, code, msg string) {
+		invalid = append(invalid, domain.FieldError{Field: field, Code: code, Message: msg, Err: domain.ErrInvalidFilter})
+	}
+
+	if v := q.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err != nil || p < 1 {
+			reject("page", "out_of_range", "page must be a positive integer")
+		} else {
+			filter.Page = p
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err != nil || l < 1 || l > maxLimit {
+			reject("limit", "out_of_range", fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit))
+		} else {
+			filter.Limit = l
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **string
+	}{{"owner_id", &filter.OwnerID}, {"action", &filter.Action}, {"target_id", &filter.TargetID}} {
+		if v := q.Get(p.name); v != "" {
+			*p.dst = &v
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			reject(p.name, "invalid_timestamp", p.name+" must be an RFC3339 timestamp")
+			continue
+		}
+		*p.dst = &t
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		reject("from", "after_to", "from must not be after to")
+	}
+
+	if len(invalid) > 0 {
+		return filter, &domain.ValidationError{Fields: invalid}
+	}
+	return filter, nil
+}
+
+// parseDeadLetterFilter reads the dead-letter list query parameters, with the
+// same paging defaults and limits as the notification list.
+func parseDeadLetterFilter(r *http.Request, maxLimit int) (domain.DeadLetterFilter, error) {
+	q := r.URL.Query()
+	filter := domain.DeadLetterFilter{Page: 1, Limit: min(defaultListLimit, maxLimit)}
+	var invalid []domain.FieldError
+	reject := func(field, code, msg string) {
+		invalid = append(invalid, domain.FieldError{Field: field, Code: code, Message: msg, Err: domain.ErrInvalidFilter})
+	}
+
+	if v := q.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err != nil || p < 1 {
+			reject("page", "out_of_range", "page must be a positive integer")
+		} else {
+			filter.Page = p
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err != nil || l < 1 || l > maxLimit {
+			reject("limit", "out_of_range", fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit))
+		} else {
+			filter.Limit = l
+		}
+	}
+	if v := q.Get("channel"); v != "" {
+		if ch := domain.Channel(v); ch.IsValid() {
+			filter.Channel = &ch
+		} else {
+			reject("channel", "invalid_channel", domain.ErrInvalidChannel.Error())
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			reject(p.name, "invalid_timestamp", p.name+" must be an RFC3339 timestamp")
+			continue
+		}
+		*p.dst = &t
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		reject("from", "after_to", "from must not be after to")
+	}
+
+	if len(invalid) > 0 {
+		return filter, &domain.ValidationError{Fields: invalid}
+	}
+	return filter, nil
+}
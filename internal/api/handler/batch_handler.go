@@ -2,50 +2,158 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/progress"
 	"github.com/ricirt/event-driven-arch/internal/service"
 )
 
+// progressHeartbeat is how often an idle progress stream sends a comment so
+// proxies do not time the connection out.
+const progressHeartbeat = 15 * time.Second
+
 // BatchHandler handles batch-level endpoints.
 type BatchHandler struct {
 	svc    *service.NotificationService
+	hub    *progress.Hub
 	logger *zap.Logger
 }
 
-func NewBatchHandler(svc *service.NotificationService, logger *zap.Logger) *BatchHandler {
-	return &BatchHandler{svc: svc, logger: logger}
+// NewBatchHandler returns the batch handler. hub feeds the progress stream and
+// may be nil when that route is not registered.
+func NewBatchHandler(svc *service.NotificationService, hub *progress.Hub, logger *zap.Logger) *BatchHandler {
+	return &BatchHandler{svc: svc, hub: hub, logger: logger}
+}
+
+// batchResponse is the batch, what it created and, in partial-accept mode, the
+// rejected items. Exactly one of Notifications and NotificationIDs is set.
+// Batch creation also fills in Queued and Deferred, counting the members
+// placed on the queue and those left pending because it was full; DeferredIDs
+// lists the latter.
+type batchResponse struct {
+	*domain.Batch
+	Queued          *int                    `json:"queued,omitempty"`
+	Deferred        *int                    `json:"deferred,omitempty"`
+	DeferredIDs     []string                `json:"deferred_ids,omitempty"`
+	Notifications   []createdItem           `json:"notifications,omitempty"`
+	NotificationIDs []string                `json:"notification_ids,omitempty"`
+	Errors          []domain.BatchItemError `json:"errors,omitempty"`
+}
+
+// createdItem summarises one notification created by a batch request.
+type createdItem struct {
+	ID          string        `json:"id"`
+	Recipient   string        `json:"recipient"`
+	Status      domain.Status `json:"status"`
+	ScheduledAt *time.Time    `json:"scheduled_at,omitempty"`
 }
 
 // CreateBatch handles POST /api/v1/notifications/batch
 //
+// Partial-accept mode is enabled by ?allow_partial=true or "allow_partial": true
+// in the body; invalid items are then reported in "errors" instead of failing
+// the whole batch.
+//
+// The response lists the created notifications in request order, rejected
+// items left out. ?ids_only=true trims the list to "notification_ids" for very
+// large batches. "queued" and "deferred" count the members that made it onto
+// the queue and those that stay pending because it filled up midway; the
+// latter are listed in "deferred_ids".
+//
+// An X-Idempotency-Key header protects the whole batch: resending it returns
+// the original batch and its members with 200 instead of creating another.
+//
 // @Summary  Create up to 1000 notifications in a single request
 // @Tags     batches
 // @Accept   json
 // @Produce  json
-// @Param    body  body      domain.CreateBatchRequest  true  "Batch payload"
-// @Success  201   {object}  domain.Batch
-// @Failure  422   {object}  map[string]string
+// @Param    allow_partial  query     bool                       false  "Create valid items and report invalid ones"
+// @Param    ids_only       query     bool                       false  "Return only the created notification IDs"
+// @Param    send_if_past   query     bool                       false  "Accept scheduled_at values in the past"
+// @Param    X-Idempotency-Key  header  string                 false  "Idempotency key"
+// @Param    body           body      domain.CreateBatchRequest  true   "Batch payload"
+// @Success  201            {object}  batchResponse
+// @Header   201            {string}  Location             "URL of the new batch"
+// @Success  200            {object}  batchResponse        "Replay: returned the batch created with the same key"
+// @Header   200,201        {string}  X-Idempotency-Key    "Echo of the request's key, when given"
+// @Header   200,201        {string}  X-Idempotent-Replay  "true when the key matched an earlier request"
+// @Failure  400            {object}  errorResponse
+// @Failure  413            {object}  errorResponse  "Body larger than MAX_BATCH_BODY_BYTES"
+// @Failure  415            {object}  errorResponse
+// @Failure  409            {object}  errorResponse  "Key used by another client"
+// @Failure  422            {object}  errorResponse
 // @Router   /api/v1/notifications/batch [post]
 func (h *BatchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
 	var req domain.CreateBatchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid JSON body")
+	if !decodeBatchJSON(w, r, &req) {
+		return
+	}
+	idsOnly := false
+	if !queryBool(w, r, "allow_partial", &req.AllowPartial) ||
+		!queryBool(w, r, "send_if_past", &req.SendIfPast) ||
+		!queryBool(w, r, "ids_only", &idsOnly) {
 		return
 	}
 
-	batch, err := h.svc.CreateBatch(r.Context(), req.Notifications)
+	idempotencyKey := r.Header.Get("X-Idempotency-Key")
+	batch, created, rejected, duplicate, err := h.svc.CreateBatch(r.Context(), req, idempotencyKey)
+	if errors.Is(err, domain.ErrBatchAllRejected) {
+		details := make([]domain.FieldError, len(rejected))
+		for i, item := range rejected {
+			details[i] = domain.FieldError{Field: item.Field, Index: &item.Index, Code: item.Code, Message: item.Error}
+		}
+		respondValidation(w, err.Error(), details)
+		return
+	}
 	if err != nil {
 		h.logger.Warn("create batch failed", zap.Error(err))
 		mapError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, batch)
+	setIdempotencyHeaders(w, idempotencyKey, duplicate)
+
+	resp := batchResponse{Batch: batch, Errors: rejected}
+	// A replay reports the members as they are now, so how many this request
+	// queued is not reported: it queued none.
+	if !duplicate {
+		var queued, deferred int
+		resp.Queued, resp.Deferred = &queued, &deferred
+		for _, n := range created {
+			switch n.Status {
+			case domain.StatusQueued:
+				queued++
+			case domain.StatusPending:
+				deferred++
+				resp.DeferredIDs = append(resp.DeferredIDs, n.ID)
+			}
+		}
+	}
+	if idsOnly {
+		resp.NotificationIDs = make([]string, len(created))
+		for i, n := range created {
+			resp.NotificationIDs[i] = n.ID
+		}
+	} else {
+		resp.Notifications = make([]createdItem, len(created))
+		for i, n := range created {
+			resp.Notifications[i] = createdItem{ID: n.ID, Recipient: n.Recipient, Status: n.Status, ScheduledAt: n.ScheduledAt}
+		}
+	}
+	if duplicate {
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/batches/"+batch.ID)
+	respondJSON(w, http.StatusCreated, resp)
 }
 
 // GetBatch handles GET /api/v1/batches/{id}
@@ -55,7 +163,7 @@ func (h *BatchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param    id   path      string  true  "Batch UUID"
 // @Success  200  {object}  map[string]any
-// @Failure  404  {object}  map[string]string
+// @Failure  404  {object}  errorResponse
 // @Router   /api/v1/batches/{id} [get]
 func (h *BatchHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
@@ -67,6 +175,141 @@ func (h *BatchHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, map[string]any{
 		"batch":         batch,
-		"notifications": notifications,
+		"notifications": nonNil(notifications),
 	})
 }
+
+// Summary handles GET /api/v1/batches/{id}/summary
+//
+// Only the batch and its counters are read, which makes it cheap enough for
+// dashboards that poll. The ETag changes whenever the batch row does; a
+// matching If-None-Match gets 304 Not Modified.
+//
+// @Summary  Get a batch's counters without its notifications
+// @Tags     batches
+// @Produce  json
+// @Param    id             path      string  true   "Batch UUID"
+// @Param    If-None-Match  header    string  false  "ETag of a previous response"
+// @Success  200            {object}  domain.Batch
+// @Success  304
+// @Failure  404            {object}  errorResponse
+// @Router   /api/v1/batches/{id}/summary [get]
+func (h *BatchHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.svc.GetBatchSummary(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+
+	// Weak: the body may be gzipped on the way out.
+	etag := fmt.Sprintf(`W/"%x"`, batch.UpdatedAt.UnixMicro())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondJSON(w, http.StatusOK, batch)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison RFC 9110 prescribes for that header.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// batchProgress is one snapshot of a batch's counters on the progress stream.
+type batchProgress struct {
+	BatchID   string `json:"batch_id"`
+	Total     int    `json:"total"`
+	Pending   int    `json:"pending"`
+	Sent      int    `json:"sent"`
+	Failed    int    `json:"failed"`
+	Cancelled int    `json:"cancelled"`
+	Expired   int    `json:"expired"`
+}
+
+func newBatchProgress(b *domain.Batch) batchProgress {
+	return batchProgress{
+		BatchID: b.ID, Total: b.Total, Pending: b.Pending,
+		Sent: b.Sent, Failed: b.Failed, Cancelled: b.Cancelled, Expired: b.Expired,
+	}
+}
+
+// Progress handles GET /api/v1/batches/{id}/progress
+//
+// The response is a server-sent event stream. Each "progress" event carries
+// the batch counters: one right away, then one whenever the workers refresh
+// them. The server closes the stream after the event with pending = 0.
+//
+// @Summary  Stream batch progress as server-sent events
+// @Tags     batches
+// @Produce  text/event-stream
+// @Param    id   path      string  true  "Batch UUID"
+// @Success  200  {object}  batchProgress
+// @Failure  404  {object}  errorResponse
+// @Router   /api/v1/batches/{id}/progress [get]
+func (h *BatchHandler) Progress(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	// Subscribe before reading the counters so no update slips in between.
+	updates, cancel := h.hub.Subscribe(id)
+	defer cancel()
+
+	batch, err := h.svc.GetBatchSummary(r.Context(), id)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+
+	// The stream outlives the server's write timeout by design.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	send := func(b *domain.Batch) error {
+		data, err := json.Marshal(newBatchProgress(b))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := send(batch); err != nil || batch.Pending == 0 {
+		return
+	}
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case b, ok := <-updates:
+			if !ok {
+				return // shutting down
+			}
+			if err := send(&b); err != nil || b.Pending == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/progress"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+const mixedBatchBody = `{"notifications":[
+	{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"},
+	{"channel":"sms","recipient":"","content":"Hello","priority":"normal"},
+	{"channel":"email","recipient":"a@b.com","content":"Hello","priority":"high"}
+]}`
+
+func newBatchHandler() *handler.BatchHandler {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	return handler.NewBatchHandler(svc, nil, zap.NewNop())
+}
+
+func TestBatchHandler_CreateBatch_RejectsMixedByDefault(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+}
+
+func TestBatchHandler_CreateBatch_AllowPartial(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?allow_partial=true", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	var body struct {
+		Total         int `json:"total"`
+		Notifications []struct {
+			ID        string `json:"id"`
+			Recipient string `json:"recipient"`
+			Status    string `json:"status"`
+		} `json:"notifications"`
+		Errors []struct {
+			Index int    `json:"index"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Total != 2 {
+		t.Fatalf("expected total=2, got %d", body.Total)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Index != 1 || body.Errors[0].Error == "" {
+		t.Fatalf("expected item 1 reported, got %+v", body.Errors)
+	}
+	created := body.Notifications
+	if len(created) != 2 || created[0].ID == "" || created[0].Recipient != "+905551234567" ||
+		created[1].Recipient != "a@b.com" || created[0].Status != string(domain.StatusQueued) {
+		t.Fatalf("expected both created notifications in request order, got %+v", created)
+	}
+}
+
+func TestBatchHandler_CreateBatch_SetsLocation(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?allow_partial=true", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/api/v1/batches/" + body.ID; body.ID == "" || rec.Header().Get("Location") != want {
+		t.Fatalf("expected Location %q, got %q", want, rec.Header().Get("Location"))
+	}
+}
+
+func TestBatchHandler_CreateBatch_RejectedBatchHasNoLocation(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "" {
+		t.Fatalf("expected no Location on a rejected batch, got %q", loc)
+	}
+}
+
+func TestBatchHandler_CreateBatch_IdempotencyKey(t *testing.T) {
+	h := newBatchHandler()
+
+	post := func() *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?allow_partial=true", mixedBatchBody)
+		req.Header.Set("X-Idempotency-Key", "batch-1")
+		rec := httptest.NewRecorder()
+		h.CreateBatch(rec, req)
+		return rec
+	}
+	type response struct {
+		ID            string                `json:"id"`
+		Queued        *int                  `json:"queued"`
+		Notifications []struct{ ID string } `json:"notifications"`
+	}
+
+	rec := post()
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Idempotent-Replay") != "false" {
+		t.Fatalf("expected 201 with replay=false, got %d %v", rec.Code, rec.Header())
+	}
+	var first response
+	if err := json.NewDecoder(rec.Body).Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = post()
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Idempotent-Replay") != "true" || rec.Header().Get("Location") != "" {
+		t.Fatalf("expected 200 with replay=true and no Location, got %d %v", rec.Code, rec.Header())
+	}
+	var second response
+	if err := json.NewDecoder(rec.Body).Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || len(second.Notifications) != len(first.Notifications) || second.Queued != nil {
+		t.Fatalf("expected batch %s replayed without enqueue counts, got %+v", first.ID, second)
+	}
+}
+
+func TestBatchHandler_CreateBatch_ReportsDeferredMembers(t *testing.T) {
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.NewWithCapacity(0, 2, 0),
+		zap.NewNop(), service.Options{})
+	h := handler.NewBatchHandler(svc, nil, zap.NewNop())
+
+	item := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`
+	body := `{"notifications":[` + strings.Repeat(item+",", 4) + item + `]}`
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?ids_only=true", body)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var resp struct {
+		Queued          int      `json:"queued"`
+		Deferred        int      `json:"deferred"`
+		DeferredIDs     []string `json:"deferred_ids"`
+		NotificationIDs []string `json:"notification_ids"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Queued != 2 || resp.Deferred != 3 {
+		t.Fatalf("expected 2 queued and 3 deferred, got %d and %d", resp.Queued, resp.Deferred)
+	}
+	if !slices.Equal(resp.DeferredIDs, resp.NotificationIDs[2:]) {
+		t.Fatalf("expected the last 3 members deferred, got %v of %v", resp.DeferredIDs, resp.NotificationIDs)
+	}
+}
+
+func TestBatchHandler_CreateBatch_IDsOnly(t *testing.T) {
+	h := newBatchHandler()
+
+	body := `{"notifications":[{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}]}`
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?ids_only=true", body)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var resp map[string]json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp["notifications"]; ok {
+		t.Fatal("expected no notifications list with ids_only")
+	}
+	var ids []string
+	if err := json.Unmarshal(resp["notification_ids"], &ids); err != nil || len(ids) != 1 || ids[0] == "" {
+		t.Fatalf("expected one notification ID, got %s", resp["notification_ids"])
+	}
+}
+
+func TestBatchHandler_CreateBatch_InvalidIDsOnly(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch?ids_only=maybe", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestBatchHandler_CreateBatch_AllowPartial_AllRejected(t *testing.T) {
+	h := newBatchHandler()
+
+	body := `{"allow_partial":true,"notifications":[{"channel":"fax","recipient":"x","content":"Hello","priority":"normal"}]}`
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch", body)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details []struct {
+				Field string `json:"field"`
+				Index *int   `json:"index"`
+				Code  string `json:"code"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	d := resp.Error.Details
+	if resp.Error.Code != "validation_failed" || len(d) != 1 || d[0].Index == nil || *d[0].Index != 0 || d[0].Field != "channel" {
+		t.Fatalf("expected one channel error for item 0, got %+v", resp.Error)
+	}
+}
+
+func TestBatchHandler_CreateBatch_ReportsEveryInvalidItem(t *testing.T) {
+	h := newBatchHandler()
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications/batch", mixedBatchBody)
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	var resp struct {
+		Error struct {
+			Details []struct {
+				Field string `json:"field"`
+				Index int    `json:"index"`
+				Code  string `json:"code"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if d := resp.Error.Details; len(d) != 1 || d[0].Index != 1 || d[0].Field == "" || d[0].Code == "" {
+		t.Fatalf("expected item 1 reported with field and code, got %+v", d)
+	}
+}
+
+func TestBatchHandler_GetBatch_EmptyNotificationsIsArray(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: "b1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Get("/api/v1/batches/{id}", handler.NewBatchHandler(svc, nil, zap.NewNop()).GetBatch)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/batches/b1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"notifications":[]`) {
+		t.Fatalf("expected an empty notifications array, got %s", rec.Body)
+	}
+}
+
+func newProgressServer(t *testing.T, hub *progress.Hub) *httptest.Server {
+	t.Helper()
+	repo := repository.NewMockNotificationRepository()
+	batchID := "b1"
+	members := []*domain.Notification{
+		{ID: "n1", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+		{ID: "n2", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+	}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Get("/api/v1/batches/{id}/progress", handler.NewBatchHandler(svc, hub, zap.NewNop()).Progress)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readProgress reads the next "progress" event off an SSE stream.
+func readProgress(t *testing.T, r *bufio.Reader) map[string]int {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended early: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var snap map[string]any
+			if err := json.Unmarshal([]byte(data), &snap); err != nil {
+				t.Fatalf("bad event data %q: %v", data, err)
+			}
+			counts := make(map[string]int)
+			for k, v := range snap {
+				if f, ok := v.(float64); ok {
+					counts[k] = int(f)
+				}
+			}
+			return counts
+		}
+	}
+}
+
+func TestBatchHandler_Progress_StreamsUntilNothingPending(t *testing.T) {
+	hub := progress.NewHub()
+	srv := newProgressServer(t, hub)
+
+	resp, err := http.Get(srv.URL + "/api/v1/batches/b1/progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	if snap := readProgress(t, body); snap["pending"] != 2 || snap["total"] != 2 {
+		t.Fatalf("unexpected initial snapshot: %v", snap)
+	}
+
+	hub.Publish(&domain.Batch{ID: "b1", Total: 2, Pending: 1, Sent: 1})
+	if snap := readProgress(t, body); snap["pending"] != 1 || snap["sent"] != 1 {
+		t.Fatalf("unexpected snapshot: %v", snap)
+	}
+
+	hub.Publish(&domain.Batch{ID: "b1", Total: 2, Sent: 1, Failed: 1})
+	if snap := readProgress(t, body); snap["pending"] != 0 || snap["failed"] != 1 {
+		t.Fatalf("unexpected final snapshot: %v", snap)
+	}
+
+	done := make(chan error, 1)
+	go func() { _, err := io.ReadAll(body); done <- err }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected a clean end of stream, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to close once nothing is pending")
+	}
+	if n := hub.Subscribers("b1"); n != 0 {
+		t.Fatalf("expected the subscription to be released, got %d", n)
+	}
+}
+
+func TestBatchHandler_Progress_UnknownBatch(t *testing.T) {
+	hub := progress.NewHub()
+	srv := newProgressServer(t, hub)
+
+	resp, err := http.Get(srv.URL + "/api/v1/batches/missing/progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+	if n := hub.Subscribers("missing"); n != 0 {
+		t.Fatalf("expected no lingering subscription, got %d", n)
+	}
+}
+
+func TestBatchHandler_Summary_ETag(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	batchID := "b1"
+	members := []*domain.Notification{{ID: "n1", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued}}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Get("/api/v1/batches/{id}/summary", handler.NewBatchHandler(svc, nil, zap.NewNop()).Summary)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/batches/b1/summary", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["id"] != "b1" || body["total"] != float64(1) {
+		t.Fatalf("unexpected summary %v", body)
+	}
+	if _, ok := body["notifications"]; ok {
+		t.Fatal("expected no notifications in the summary")
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected an ETag and Cache-Control: no-cache, got %v", rec.Header())
+	}
+
+	if rec := get(`"other", ` + etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 304, got %d %q", rec.Code, rec.Body)
+	}
+
+	time.Sleep(time.Millisecond) // let updated_at move
+	if _, err := repo.UpdateBatchCounts(context.Background(), batchID); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("expected a fresh 200 after the batch changed, got %d", rec.Code)
+	}
+}
+
+func TestBatchHandler_Summary_UnknownBatch(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/api/v1/batches/{id}/summary", newBatchHandler().Summary)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/batches/missing/summary", nil))
+
+	if rec.Code != http.StatusNotFound || errorCodeOf(t, rec) != "not_found" {
+		t.Fatalf("expected 404 not_found, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// decodeJSON strictly decodes the request body into dst. It answers 415 unless
+// the body is declared as application/json, 413 when the body runs past the
+// route's size limit, and 400 for malformed JSON, for fields dst does not
+// have (so a typo like "prioritiy" is not silently dropped) and for anything
+// after the JSON document. It reports whether dst was filled; on false the
+// response has been written.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if !requireJSON(w, r) {
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		respondDecodeError(w, err)
+		return false
+	}
+	return requireEOF(w, dec)
+}
+
+// decodeOptionalJSON is decodeJSON for a body the request may leave out: an
+// empty body leaves dst as it is, whatever its Content-Type.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := bufio.NewReader(r.Body)
+	if _, err := body.Peek(1); errors.Is(err, io.EOF) {
+		return true
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, r.Body}
+	return decodeJSON(w, r, dst)
+}
+
+// decodeBatchJSON decodes a batch creation body like decodeJSON, but walks
+// the notifications array one item at a time. decodeJSON would buffer the
+// whole document before decoding it, so a body of a thousand large items sat
+// in memory twice; here the decoder only ever holds one item. Reading stops
+// as soon as the array grows past domain.MaxBatchSize, answering 422 without
+// consuming the rest of the body.
+func decodeBatchJSON(w http.ResponseWriter, r *http.Request, dst *domain.CreateBatchRequest) bool {
+	if !requireJSON(w, r) {
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := expectDelim(dec, '{'); err != nil {
+		respondDecodeError(w, err)
+		return false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			respondDecodeError(w, err)
+			return false
+		}
+		// Keys match case-insensitively, as they do for encoding/json.
+		switch key, _ := tok.(string); strings.ToLower(key) {
+		case "notifications":
+			if !decodeBatchItems(w, dec, dst) {
+				return false
+			}
+		case "template_id":
+			err = dec.Decode(&dst.TemplateID)
+		case "scheduled_at":
+			err = dec.Decode(&dst.ScheduledAt)
+		case "send_if_past":
+			err = dec.Decode(&dst.SendIfPast)
+		case "dry_run":
+			err = dec.Decode(&dst.DryRun)
+		case "allow_partial":
+			err = dec.Decode(&dst.AllowPartial)
+		default:
+			err = fmt.Errorf("json: unknown field %q", key)
+		}
+		if err != nil {
+			respondDecodeError(w, err)
+			return false
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		respondDecodeError(w, err)
+		return false
+	}
+	return requireEOF(w, dec)
+}
+
+// decodeBatchItems reads the notifications array of a batch body into dst,
+// replacing any items an earlier "notifications" key supplied.
+func decodeBatchItems(w http.ResponseWriter, dec *json.Decoder, dst *domain.CreateBatchRequest) bool {
+	dst.Notifications = nil
+	tok, err := dec.Token()
+	if err != nil {
+		respondDecodeError(w, err)
+		return false
+	}
+	if tok == nil {
+		return true
+	}
+	if tok != json.Delim('[') {
+		respondError(w, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	for dec.More() {
+		if len(dst.Notifications) == domain.MaxBatchSize {
+			mapError(w, domain.ErrBatchTooLarge)
+			return false
+		}
+		var item domain.CreateNotificationRequest
+		if err := dec.Decode(&item); err != nil {
+			respondDecodeError(w, err)
+			return false
+		}
+		dst.Notifications = append(dst.Notifications, item)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		respondDecodeError(w, err)
+		return false
+	}
+	return true
+}
+
+// expectDelim reads the next token and fails unless it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// requireJSON answers 415 unless the body is declared as application/json.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+	return true
+}
+
+// requireEOF answers 400 when anything follows the JSON document dec read.
+func requireEOF(w http.ResponseWriter, dec *json.Decoder) bool {
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if tooLarge(w, err) {
+			return false
+		}
+		respondError(w, http.StatusBadRequest, "request body must contain a single JSON document")
+		return false
+	}
+	return true
+}
+
+// respondDecodeError answers a failure to decode the body: 413 past the size
+// limit, 400 naming the field for an unknown one, and 400 otherwise.
+func respondDecodeError(w http.ResponseWriter, err error) {
+	if tooLarge(w, err) {
+		return
+	}
+	if field, ok := unknownField(err); ok {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: errorBody{
+			Code:    errorCode(http.StatusBadRequest),
+			Message: fmt.Sprintf("unknown field %q", field),
+			Details: []domain.FieldError{{Field: field, Code: "unknown_field", Message: "field is not recognised"}},
+		}})
+		return
+	}
+	respondError(w, http.StatusBadRequest, "invalid JSON body")
+}
+
+// queryBool sets *dst from the boolean query parameter name, when present. It
+// answers 400 and reports false when the value is not a boolean.
+func queryBool(w http.ResponseWriter, r *http.Request, name string, dst *bool) bool {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, name+" must be a boolean")
+		return false
+	}
+	*dst = b
+	return true
+}
+
+// tooLarge answers 413 when err is the http.MaxBytesReader installed by the
+// router's body size limit, stating the limit; otherwise it writes nothing.
+func tooLarge(w http.ResponseWriter, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+	msg := fmt.Sprintf("request body exceeds the %d-byte limit", maxErr.Limit)
+	respondJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: errorBody{
+		Code:    errorCode(http.StatusRequestEntityTooLarge),
+		Message: msg,
+		Details: []domain.FieldError{{Field: "body", Code: "too_large", Message: msg}},
+	}})
+	return true
+}
+
+// unknownField extracts the field name from the error DisallowUnknownFields
+// produces; encoding/json has no typed error for it.
+func unknownField(err error) (string, bool) {
+	rest, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(rest, `"`), true
+}
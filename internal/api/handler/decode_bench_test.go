@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// batchBody is a batch of n email items with 8 KiB bodies each.
+func batchBody(n int) string {
+	item := fmt.Sprintf(`{"channel":"email","recipient":"a@b.com","content":%q,"priority":"normal"}`,
+		strings.Repeat("x", 8<<10))
+	return `{"notifications":[` + strings.Repeat(item+",", n-1) + item + `]}`
+}
+
+// BenchmarkDecodeBatch compares decoding a batch body as one document with
+// walking its items. Both keep the decoded items, which the service needs
+// all of, but the whole-document decode also buffers the entire raw body:
+// B/op grows by roughly twice the body size on top of the items, while the
+// streaming decoder's buffer stays at about one item.
+func BenchmarkDecodeBatch(b *testing.B) {
+	for _, n := range []int{100, domain.MaxBatchSize} {
+		body := batchBody(n)
+		decoders := []struct {
+			name   string
+			decode func(http.ResponseWriter, *http.Request, *domain.CreateBatchRequest) bool
+		}{
+			{"document", func(w http.ResponseWriter, r *http.Request, dst *domain.CreateBatchRequest) bool {
+				return decodeJSON(w, r, dst)
+			}},
+			{"streaming", decodeBatchJSON},
+		}
+		for _, d := range decoders {
+			b.Run(fmt.Sprintf("%s/items=%d", d.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(body)))
+				for b.Loop() {
+					req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/batch", strings.NewReader(body))
+					req.Header.Set("Content-Type", "application/json")
+					var dst domain.CreateBatchRequest
+					if !d.decode(httptest.NewRecorder(), req, &dst) || len(dst.Notifications) != n {
+						b.Fatal("decode failed")
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+)
+
+// jsonRequest builds a request carrying body as application/json.
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+type decodeErrorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// errorCodeOf returns the top-level code of the error response in rec.
+func errorCodeOf(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	return decodeError(t, rec.Body).Error.Code
+}
+
+func decodeError(t *testing.T, body io.Reader) decodeErrorBody {
+	t.Helper()
+	var resp decodeErrorBody
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDecode_RequiresJSONContentType(t *testing.T) {
+	for _, ct := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		h := newNotificationHandler(queue.New())
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(validBody))
+		if ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("Content-Type %q: expected 415, got %d", ct, rec.Code)
+		}
+		if resp := decodeError(t, rec.Body); resp.Error.Code != "unsupported_media_type" {
+			t.Fatalf("Content-Type %q: unexpected error code %q", ct, resp.Error.Code)
+		}
+	}
+}
+
+func TestDecode_AcceptsCharsetParameter(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications", validBody)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDecode_RejectsUnknownField(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	body := `{"channel":"sms","recipient":"+905551234567","content":"Hello","prioritiy":"high"}`
+	rec := httptest.NewRecorder()
+	h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	resp := decodeError(t, rec.Body)
+	if !strings.Contains(resp.Error.Message, "prioritiy") {
+		t.Fatalf("expected the message to name the field, got %q", resp.Error.Message)
+	}
+	if d := resp.Error.Details; len(d) != 1 || d[0].Field != "prioritiy" || d[0].Code != "unknown_field" {
+		t.Fatalf("unexpected details: %+v", d)
+	}
+}
+
+func TestDecode_RejectsUnknownNestedBatchField(t *testing.T) {
+	h := newBatchHandler()
+	body := `{"notifications":[{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal","sendat":"x"}]}`
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, jsonRequest(http.MethodPost, "/api/v1/notifications/batch", body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if resp := decodeError(t, rec.Body); !strings.Contains(resp.Error.Message, "sendat") {
+		t.Fatalf("expected the message to name the field, got %q", resp.Error.Message)
+	}
+}
+
+func TestDecode_RejectsTrailingData(t *testing.T) {
+	for _, tail := range []string{"garbage", `{"channel":"sms"}`, "]"} {
+		h := newNotificationHandler(queue.New())
+		rec := httptest.NewRecorder()
+		h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", validBody+tail))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("tail %q: expected 400, got %d", tail, rec.Code)
+		}
+	}
+
+	// Trailing whitespace is not garbage.
+	h := newNotificationHandler(queue.New())
+	rec := httptest.NewRecorder()
+	h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", validBody+"\n  "))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 with trailing whitespace, got %d", rec.Code)
+	}
+}
+
+func TestDecode_RejectsMalformedJSON(t *testing.T) {
+	h := newBatchHandler()
+	rec := httptest.NewRecorder()
+	h.CreateBatch(rec, jsonRequest(http.MethodPost, "/api/v1/notifications/batch", `{"notifications":[`))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+// failingReader stands in for the part of a body the handler must not read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read past the batch limit") }
+
+func TestDecodeBatch_StopsReadingPastMaxBatchSize(t *testing.T) {
+	item := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"},`
+	head := `{"notifications":[` + strings.Repeat(item, domain.MaxBatchSize+5)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/batch",
+		io.MultiReader(strings.NewReader(head), failingReader{}))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	newBatchHandler().CreateBatch(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 before the rest of the body was read, got %d: %s", rec.Code, rec.Body)
+	}
+	if resp := decodeError(t, rec.Body); len(resp.Error.Details) != 1 || resp.Error.Details[0].Code != "too_many" {
+		t.Fatalf("expected a too_many detail, got %+v", resp.Error.Details)
+	}
+}
+
+func TestDecodeBatch_BodyShapes(t *testing.T) {
+	item := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`
+	soon := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"batch fields after the items", `{"notifications":[` + item + `],"scheduled_at":"` + soon + `","allow_partial":true}`, http.StatusCreated},
+		{"batch dry run", `{"dry_run":true,"notifications":[` + item + `]}`, http.StatusCreated},
+		{"key case ignored", `{"Notifications":[` + item + `]}`, http.StatusCreated},
+		{"null items", `{"notifications":null}`, http.StatusUnprocessableEntity},
+		{"no items", `{}`, http.StatusUnprocessableEntity},
+		{"items not an array", `{"notifications":{}}`, http.StatusBadRequest},
+		{"not an object", `[` + item + `]`, http.StatusBadRequest},
+		{"unknown top-level field", `{"notifications":[` + item + `],"priority":"high"}`, http.StatusBadRequest},
+		{"trailing data", `{"notifications":[` + item + `]}{}`, http.StatusBadRequest},
+		{"bad batch field", `{"allow_partial":"yes","notifications":[` + item + `]}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newBatchHandler().CreateBatch(rec, jsonRequest(http.MethodPost, "/api/v1/notifications/batch", tc.body))
+			if rec.Code != tc.expected {
+				t.Fatalf("expected %d, got %d: %s", tc.expected, rec.Code, rec.Body)
+			}
+		})
+	}
+}
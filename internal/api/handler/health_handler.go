@@ -1,19 +1,59 @@
 package handler
 
-import "net/http"
+import (
+	"net/http"
 
-// HealthHandler serves the liveness probe endpoint.
-type HealthHandler struct{}
+	"github.com/ricirt/event-driven-arch/internal/version"
+)
 
-func NewHealthHandler() *HealthHandler { return &HealthHandler{} }
+// HealthHandler serves the liveness probe and build info endpoints.
+type HealthHandler struct {
+	build    version.Info
+	defaults serviceDefaults
+}
+
+// NewHealthHandler reports the running build, and with it the max_retries
+// given to notifications created without one.
+func NewHealthHandler(defaultMaxRetries int) *HealthHandler {
+	return &HealthHandler{build: version.Get(), defaults: serviceDefaults{MaxRetries: defaultMaxRetries}}
+}
+
+// serviceDefaults are the values the service fills in for create requests
+// that leave them out, so operators can confirm the effective configuration.
+type serviceDefaults struct {
+	MaxRetries int `json:"max_retries"`
+}
+
+// versionResponse is the build info plus the service defaults.
+type versionResponse struct {
+	version.Info
+	Defaults serviceDefaults `json:"defaults"`
+}
+
+// healthResponse is the liveness answer plus the build that gave it.
+type healthResponse struct {
+	Status string `json:"status"`
+	version.Info
+}
 
 // Health handles GET /health
 //
 // @Summary  Liveness probe
 // @Tags     system
 // @Produce  json
-// @Success  200  {object}  map[string]string
+// @Success  200  {object}  healthResponse
 // @Router   /health [get]
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	respondJSON(w, http.StatusOK, healthResponse{Status: "ok", Info: h.build})
+}
+
+// Version handles GET /version
+//
+// @Summary  Build info
+// @Tags     system
+// @Produce  json
+// @Success  200  {object}  versionResponse
+// @Router   /version [get]
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, versionResponse{Info: h.build, Defaults: h.defaults})
 }
@@ -2,36 +2,81 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/ricirt/event-driven-arch/internal/domain"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 )
 
+// WorkerStats is satisfied by *worker.Pool.
+type WorkerStats interface {
+	Stats() (total, busy int)
+}
+
+// ThroughputStats is satisfied by *metrics.Metrics.
+type ThroughputStats interface {
+	LastMinute(now time.Time) (sent, failed int64)
+}
+
+// LimiterStats is satisfied by *ratelimiter.ChannelLimiters.
+type LimiterStats interface {
+	Utilisation() map[domain.Channel]float64
+}
+
+// MetricsSources feeds the parts of the snapshot beyond the queue. A nil
+// source leaves its section out of the response.
+type MetricsSources struct {
+	Workers    WorkerStats
+	Throughput ThroughputStats
+	Limiters   LimiterStats
+}
+
 // MetricsHandler serves a human-readable JSON queue snapshot.
 // Raw Prometheus metrics (counters, histograms) are available at /metrics
 // via promhttp.Handler and are separate from this endpoint.
 type MetricsHandler struct {
-	q *queue.PriorityQueue
+	q   *queue.PriorityQueue
+	src MetricsSources
 }
 
-func NewMetricsHandler(q *queue.PriorityQueue) *MetricsHandler {
-	return &MetricsHandler{q: q}
+func NewMetricsHandler(q *queue.PriorityQueue, src MetricsSources) *MetricsHandler {
+	return &MetricsHandler{q: q, src: src}
 }
 
 // GetMetrics handles GET /api/v1/metrics
 //
-// @Summary  Real-time queue depth snapshot
+// @Summary  Real-time queue, worker and throughput snapshot
 // @Tags     metrics
 // @Produce  json
 // @Success  200  {object}  map[string]any
 // @Router   /api/v1/metrics [get]
 func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
 	high, normal, low := h.q.Depths()
-	respondJSON(w, http.StatusOK, map[string]any{
+	oldestHigh, oldestNormal, oldestLow := h.q.OldestAges(now)
+	body := map[string]any{
 		"queue_depth": map[string]int{
 			"high":   high,
 			"normal": normal,
 			"low":    low,
 			"total":  high + normal + low,
 		},
-	})
+		"queue_oldest_age_seconds": map[string]float64{
+			"high":   oldestHigh.Seconds(),
+			"normal": oldestNormal.Seconds(),
+			"low":    oldestLow.Seconds(),
+		},
+	}
+	if h.src.Workers != nil {
+		total, busy := h.src.Workers.Stats()
+		body["workers"] = map[string]int{"total": total, "busy": busy}
+	}
+	if h.src.Throughput != nil {
+		sent, failed := h.src.Throughput.LastMinute(now)
+		body["last_minute"] = map[string]int64{"sent": sent, "failed": failed}
+	}
+	if h.src.Limiters != nil {
+		body["rate_limiter_utilisation"] = h.src.Limiters.Utilisation()
+	}
+	respondJSON(w, http.StatusOK, body)
 }
@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+)
+
+type fakeStats struct{}
+
+func (fakeStats) Stats() (total, busy int) { return 8, 3 }
+
+func (fakeStats) LastMinute(time.Time) (sent, failed int64) { return 42, 2 }
+
+func (fakeStats) Utilisation() map[domain.Channel]float64 {
+	return map[domain.Channel]float64{domain.ChannelSMS: 0.5, domain.ChannelEmail: 0, domain.ChannelPush: 1}
+}
+
+func getMetrics(h *handler.MetricsHandler) map[string]any {
+	rec := httptest.NewRecorder()
+	h.GetMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil))
+	var body map[string]any
+	json.NewDecoder(rec.Body).Decode(&body) //nolint:errcheck
+	return body
+}
+
+func TestMetricsHandler_Snapshot(t *testing.T) {
+	q := queue.New()
+	q.Enqueue(queue.Item{NotificationID: "n1", Priority: domain.PriorityHigh, EnqueuedAt: time.Now().Add(-time.Minute)}) //nolint:errcheck
+	h := handler.NewMetricsHandler(q, handler.MetricsSources{Workers: fakeStats{}, Throughput: fakeStats{}, Limiters: fakeStats{}})
+
+	body := getMetrics(h)
+	if depth := body["queue_depth"].(map[string]any); depth["high"] != 1.0 || depth["total"] != 1.0 {
+		t.Fatalf("unexpected queue_depth: %v", depth)
+	}
+	if age := body["queue_oldest_age_seconds"].(map[string]any)["high"].(float64); age < 60 {
+		t.Fatalf("expected the high item to be at least 60s old, got %v", age)
+	}
+	if workers := body["workers"].(map[string]any); workers["total"] != 8.0 || workers["busy"] != 3.0 {
+		t.Fatalf("unexpected workers: %v", workers)
+	}
+	if last := body["last_minute"].(map[string]any); last["sent"] != 42.0 || last["failed"] != 2.0 {
+		t.Fatalf("unexpected last_minute: %v", last)
+	}
+	if util := body["rate_limiter_utilisation"].(map[string]any); util["sms"] != 0.5 || util["push"] != 1.0 {
+		t.Fatalf("unexpected rate_limiter_utilisation: %v", util)
+	}
+}
+
+func TestMetricsHandler_OmitsMissingSources(t *testing.T) {
+	body := getMetrics(handler.NewMetricsHandler(queue.New(), handler.MetricsSources{}))
+	for _, key := range []string{"workers", "last_minute", "rate_limiter_utilisation"} {
+		if _, present := body[key]; present {
+			t.Fatalf("%s must be left out without a source", key)
+		}
+	}
+	if _, present := body["queue_depth"]; !present {
+		t.Fatal("queue_depth must always be reported")
+	}
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,62 +17,150 @@ import (
 	"github.com/ricirt/event-driven-arch/internal/service"
 )
 
+// createResponse decorates a created notification with a queue-placement hint.
+// Queued is only set when the item remained pending because the queue was full.
+type createResponse struct {
+	*domain.Notification
+	Queued *bool `json:"queued,omitempty"`
+}
+
 // NotificationHandler handles single-notification CRUD endpoints.
 type NotificationHandler struct {
-	svc    *service.NotificationService
-	logger *zap.Logger
+	svc         *service.NotificationService
+	maxPageSize int
+	logger      *zap.Logger
 }
 
-func NewNotificationHandler(svc *service.NotificationService, logger *zap.Logger) *NotificationHandler {
-	return &NotificationHandler{svc: svc, logger: logger}
+// NewNotificationHandler builds the handler; maxPageSize caps the limit
+// accepted by List.
+func NewNotificationHandler(svc *service.NotificationService, maxPageSize int, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{svc: svc, maxPageSize: maxPageSize, logger: logger}
 }
 
 // Create handles POST /api/v1/notifications
 //
+// A body with "recipients" instead of "recipient" is a fan-out: it creates an
+// implicit batch and responds with the batch summary instead (201, or 200 when
+// the idempotency key was already used for that fan-out).
+//
 // @Summary     Create a notification
 // @Tags        notifications
 // @Accept      json
 // @Produce     json
 // @Param       X-Idempotency-Key  header    string                          false  "Idempotency key"
+// @Param       send_if_past       query     bool                            false  "Accept a scheduled_at in the past and send it on the next scheduler poll"
 // @Param       body               body      domain.CreateNotificationRequest true   "Notification payload"
 // @Success     201                {object}  domain.Notification
+// @Header      201                {string}  Location                         "URL of the new notification, or batch for a fan-out"
 // @Success     200                {object}  domain.Notification              "Duplicate: returned existing notification"
-// @Failure     422                {object}  map[string]string
-// @Failure     503                {object}  map[string]string
+// @Header      200,201,202        {string}  X-Idempotency-Key                "Echo of the request's key, when given"
+// @Header      200,201,202        {string}  X-Idempotent-Replay              "true when the key matched an earlier request"
+// @Success     202                {object}  domain.Notification              "Persisted but not queued (queued=false)"
+// @Header      202                {integer} Retry-After                      "Seconds until the queue is expected to have room"
+// @Failure     400                {object}  errorResponse                    "Malformed JSON, unknown field or trailing data"
+// @Failure     413                {object}  errorResponse                    "Body larger than MAX_BODY_BYTES"
+// @Failure     415                {object}  errorResponse                    "Body is not application/json"
+// @Failure     422                {object}  errorResponse
+// @Failure     429                {object}  errorResponse                    "Per-tenant creation rate limit exceeded"
+// @Failure     503                {object}  errorResponse                    "Queue full with STRICT_ENQUEUE on"
 // @Router      /api/v1/notifications [post]
 func (h *NotificationHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req domain.CreateNotificationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid JSON body")
+	if !decodeJSON(w, r, &req) || !queryBool(w, r, "send_if_past", &req.SendIfPast) {
 		return
 	}
 
 	idempotencyKey := r.Header.Get("X-Idempotency-Key")
-	n, isDuplicate, err := h.svc.Create(r.Context(), req, idempotencyKey)
+	if len(req.Recipients) > 0 {
+		h.fanOut(w, r, req, idempotencyKey)
+		return
+	}
+
+	n, res, err := h.svc.Create(r.Context(), req, idempotencyKey)
 	if err != nil {
 		h.logger.Warn("create notification failed",
 			zap.String("correlation_id", apimw.GetCorrelationID(r.Context())),
 			zap.Error(err),
 		)
+		if errors.Is(err, domain.ErrQueueFull) {
+			setRetryAfter(w, res.RetryAfter)
+		}
 		mapError(w, err)
 		return
 	}
 
-	status := http.StatusCreated
-	if isDuplicate {
-		status = http.StatusOK
+	setIdempotencyHeaders(w, idempotencyKey, res.Replayed)
+
+	// The queue was full: the row is persisted but still pending. Tell the
+	// client explicitly instead of letting it assume delivery is underway.
+	if res.Deferred {
+		queued := false
+		setRetryAfter(w, res.RetryAfter)
+		respondJSON(w, http.StatusAccepted, createResponse{Notification: n, Queued: &queued})
+		return
+	}
+
+	if res.Duplicate {
+		respondJSON(w, http.StatusOK, n)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/notifications/"+n.ID)
+	respondJSON(w, http.StatusCreated, n)
+}
+
+// fanOut serves Create for requests carrying a recipients list.
+func (h *NotificationHandler) fanOut(w http.ResponseWriter, r *http.Request, req domain.CreateNotificationRequest, idempotencyKey string) {
+	batch, duplicate, err := h.svc.FanOut(r.Context(), req, idempotencyKey)
+	if err != nil {
+		h.logger.Warn("fan-out failed",
+			zap.String("correlation_id", apimw.GetCorrelationID(r.Context())),
+			zap.Error(err),
+		)
+		mapError(w, err)
+		return
+	}
+	setIdempotencyHeaders(w, idempotencyKey, duplicate)
+	if duplicate {
+		respondJSON(w, http.StatusOK, batchResponse{Batch: batch})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/batches/"+batch.ID)
+	respondJSON(w, http.StatusCreated, batchResponse{Batch: batch})
+}
+
+// setIdempotencyHeaders echoes the client's idempotency key and says whether
+// the response replays the request that first used it. Without a key neither
+// header is set.
+func setIdempotencyHeaders(w http.ResponseWriter, key string, replayed bool) {
+	if key == "" {
+		return
+	}
+	w.Header().Set("X-Idempotency-Key", key)
+	w.Header().Set("X-Idempotent-Replay", strconv.FormatBool(replayed))
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounding up.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	if d > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
 	}
-	respondJSON(w, status, n)
 }
 
 // GetByID handles GET /api/v1/notifications/{id}
 //
+// Clients polling for a terminal status can send the last ETag back as
+// If-None-Match and get 304 Not Modified until the notification changes.
+//
 // @Summary  Get a notification by ID
 // @Tags     notifications
 // @Produce  json
-// @Param    id   path      string  true  "Notification UUID"
-// @Success  200  {object}  domain.Notification
-// @Failure  404  {object}  map[string]string
+// @Param    id             path      string  true   "Notification UUID"
+// @Param    If-None-Match  header    string  false  "ETag of a previous response"
+// @Success  200            {object}  domain.Notification
+// @Header   200            {string}  ETag           "Version for If-None-Match, and If-Match on PATCH"
+// @Header   200            {string}  Last-Modified  "updated_at, for If-Unmodified-Since on PATCH"
+// @Success  304
+// @Failure  404            {object}  errorResponse
 // @Router   /api/v1/notifications/{id} [get]
 func (h *NotificationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
@@ -78,9 +169,81 @@ func (h *NotificationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		mapError(w, err)
 		return
 	}
+	setVersionHeaders(w, n)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), notificationETag(n)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	respondJSON(w, http.StatusOK, n)
 }
 
+// GetByProviderMsgID handles GET /api/v1/notifications/by-provider-id/{id}
+//
+// @Summary  Get a notification by the provider's message ID
+// @Tags     notifications
+// @Produce  json
+// @Param    id   path      string  true  "Provider message ID"
+// @Success  200  {object}  domain.Notification
+// @Failure  404  {object}  errorResponse
+// @Router   /api/v1/notifications/by-provider-id/{id} [get]
+func (h *NotificationHandler) GetByProviderMsgID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	n, err := h.svc.GetByProviderMsgID(r.Context(), id)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, n)
+}
+
+// lookupRequest lists the notifications to fetch.
+type lookupRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// lookupResponse holds the found notifications in request order and the IDs
+// that matched none.
+type lookupResponse struct {
+	Notifications []*domain.Notification `json:"notifications"`
+	Missing       []string               `json:"missing"`
+}
+
+// Lookup handles POST /api/v1/notifications/lookup
+//
+// It is a POST only because the ID list can outgrow a query string; nothing
+// is changed. IDs belonging to another tenant are reported as missing.
+//
+// @Summary  Fetch several notifications by ID
+// @Tags     notifications
+// @Accept   json
+// @Produce  json
+// @Param    body  body      lookupRequest  true  "Up to MAX_LOOKUP_IDS (100 unless configured) notification IDs"
+// @Success  200   {object}  lookupResponse
+// @Failure  400   {object}  errorResponse
+// @Failure  413   {object}  errorResponse
+// @Failure  415   {object}  errorResponse
+// @Failure  422   {object}  errorResponse  "No IDs, or more than MAX_LOOKUP_IDS"
+// @Router   /api/v1/notifications/lookup [post]
+func (h *NotificationHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	var req lookupRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	result, err := h.svc.Lookup(r.Context(), req.IDs)
+	if err != nil {
+		if !domain.IsValidationError(err) {
+			h.logger.Error("notification lookup failed", zap.Error(err))
+		}
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, lookupResponse{
+		Notifications: nonNil(result.Notifications),
+		Missing:       nonNil(result.Missing),
+	})
+}
+
 // List handles GET /api/v1/notifications
 //
 // @Summary  List notifications with filtering and pagination
@@ -88,73 +251,340 @@ func (h *NotificationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param    status   query     string  false  "Filter by status"
 // @Param    channel  query     string  false  "Filter by channel"
+// @Param    dry_run  query     bool    false  "true for dry runs only, false for real sends only"
 // @Param    from     query     string  false  "Created after (RFC3339)"
 // @Param    to       query     string  false  "Created before (RFC3339)"
 // @Param    page     query     int     false  "Page number (default 1)"
-// @Param    limit    query     int     false  "Items per page (default 20, max 100)"
-// @Success  200      {object}  map[string]any
+// @Param    limit    query     int     false  "Items per page (default 20, max MAX_PAGE_SIZE: 100 unless configured)"
+// @Param    sort     query     string  false  "created_at (default), updated_at, scheduled_at or sent_at"
+// @Param    order    query     string  false  "asc or desc (default)"
+// @Success  200      {object}  pageResponse[domain.Notification]
+// @Failure  400      {object}  errorResponse  "Invalid query parameter"
 // @Router   /api/v1/notifications [get]
 func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
-	filter := parseListFilter(r)
+	filter, err := parseListFilter(r, h.maxPageSize)
+	if err != nil {
+		respondBadQuery(w, err)
+		return
+	}
 	notifications, total, err := h.svc.List(r.Context(), filter)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to list notifications")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]any{
-		"data":  notifications,
-		"total": total,
-		"page":  filter.Page,
-		"limit": filter.Limit,
-	})
+	respondJSON(w, http.StatusOK, newPageResponse(notifications, total, filter))
+}
+
+// Update handles PATCH /api/v1/notifications/{id}
+//
+// If-Match (the ETag of a GET) or If-Unmodified-Since (its Last-Modified)
+// make the edit conditional, so two operators cannot silently overwrite each
+// other; a stale precondition gets 412.
+//
+// @Summary  Edit the mutable fields of a notification
+// @Tags     notifications
+// @Accept   json
+// @Produce  json
+// @Param    id                   path      string                            true   "Notification UUID"
+// @Param    If-Match             header    string                            false  "ETag of the version being edited"
+// @Param    If-Unmodified-Since  header    string                            false  "HTTP date; ignored when If-Match is present"
+// @Param    send_if_past         query     bool                              false  "Accept a scheduled_at in the past"
+// @Param    body                 body      domain.UpdateNotificationRequest  true   "Fields to change"
+// @Success  200                  {object}  domain.Notification
+// @Header   200                  {string}  ETag  "The new version"
+// @Failure  404                  {object}  errorResponse
+// @Failure  409                  {object}  errorResponse  "Field not editable in the current status"
+// @Failure  412                  {object}  errorResponse  "Modified since the given version"
+// @Failure  422                  {object}  errorResponse
+// @Router   /api/v1/notifications/{id} [patch]
+func (h *NotificationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req domain.UpdateNotificationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !queryBool(w, r, "send_if_past", &req.SendIfPast) {
+		return
+	}
+
+	n, err := h.svc.Update(r.Context(), chi.URLParam(r, "id"), req, parsePrecondition(r))
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	setVersionHeaders(w, n)
+	respondJSON(w, http.StatusOK, n)
+}
+
+// notificationETag is the strong ETag of n's current version: its updated_at
+// in microseconds, which every write moves. The id and status are left out:
+// an ETag only ever validates the URL it came from, which names the id, and a
+// status change is a write like any other, so neither adds a distinction the
+// timestamp lacks.
+func notificationETag(n *domain.Notification) string {
+	return fmt.Sprintf(`"%x"`, n.UpdatedAt.UnixMicro())
+}
+
+// setVersionHeaders exposes n's version for conditional updates.
+func setVersionHeaders(w http.ResponseWriter, n *domain.Notification) {
+	w.Header().Set("ETag", notificationETag(n))
+	w.Header().Set("Last-Modified", n.UpdatedAt.UTC().Format(http.TimeFormat))
+}
+
+// parsePrecondition reads If-Match and If-Unmodified-Since as RFC 9110 has
+// it: If-Match wins when both are sent, "*" matches any existing version, an
+// If-Match that is not a single strong ETag of ours cannot match (If-Match
+// compares strongly, so a weak one never does), and an unparsable
+// If-Unmodified-Since is ignored.
+func parsePrecondition(r *http.Request) domain.UpdatePrecondition {
+	if v := strings.TrimSpace(r.Header.Get("If-Match")); v != "" {
+		if v == "*" {
+			return domain.UpdatePrecondition{}
+		}
+		var version time.Time // the zero time never matches a stored row
+		if len(v) > 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			if micros, err := strconv.ParseInt(v[1:len(v)-1], 16, 64); err == nil {
+				version = time.UnixMicro(micros)
+			}
+		}
+		return domain.UpdatePrecondition{UpdatedAt: &version}
+	}
+	if v := r.Header.Get("If-Unmodified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil {
+			return domain.UpdatePrecondition{UnmodifiedSince: &t}
+		}
+	}
+	return domain.UpdatePrecondition{}
+}
+
+// ChangePriority handles POST /api/v1/notifications/{id}/priority
+//
+// @Summary  Change the priority of a notification that has not been dispatched
+// @Tags     notifications
+// @Accept   json
+// @Produce  json
+// @Param    id    path      string                        true  "Notification UUID"
+// @Param    body  body      domain.ChangePriorityRequest  true  "New priority"
+// @Success  200   {object}  domain.Notification
+// @Failure  404   {object}  errorResponse
+// @Failure  409   {object}  errorResponse
+// @Failure  422   {object}  errorResponse
+// @Failure  503   {object}  errorResponse
+// @Router   /api/v1/notifications/{id}/priority [post]
+func (h *NotificationHandler) ChangePriority(w http.ResponseWriter, r *http.Request) {
+	var req domain.ChangePriorityRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	n, err := h.svc.ChangePriority(r.Context(), chi.URLParam(r, "id"), req.Priority)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, n)
+}
+
+// Submit handles POST /api/v1/notifications/{id}/submit
+//
+// @Summary  Submit a draft notification for delivery
+// @Tags     notifications
+// @Produce  json
+// @Param    id   path      string  true  "Notification UUID"
+// @Success  200  {object}  domain.Notification
+// @Success  202  {object}  domain.Notification  "Submitted but not queued (queued=false)"
+// @Failure  404  {object}  errorResponse
+// @Failure  409  {object}  errorResponse
+// @Failure  422  {object}  errorResponse
+// @Failure  503  {object}  errorResponse
+// @Router   /api/v1/notifications/{id}/submit [post]
+func (h *NotificationHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	n, res, err := h.svc.Submit(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrQueueFull) {
+			setRetryAfter(w, res.RetryAfter)
+		}
+		mapError(w, err)
+		return
+	}
+	if res.Deferred {
+		queued := false
+		setRetryAfter(w, res.RetryAfter)
+		respondJSON(w, http.StatusAccepted, createResponse{Notification: n, Queued: &queued})
+		return
+	}
+	respondJSON(w, http.StatusOK, n)
+}
+
+// Resend handles POST /api/v1/notifications/{id}/resend
+//
+// @Summary  Send a finished notification again as a new notification
+// @Tags     notifications
+// @Produce  json
+// @Param    id   path      string  true  "Notification UUID"
+// @Success  201  {object}  domain.Notification
+// @Success  202  {object}  domain.Notification  "Persisted but not queued (queued=false)"
+// @Failure  404  {object}  errorResponse
+// @Failure  409  {object}  errorResponse
+// @Failure  503  {object}  errorResponse
+// @Router   /api/v1/notifications/{id}/resend [post]
+func (h *NotificationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	n, res, err := h.svc.Resend(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrQueueFull) {
+			setRetryAfter(w, res.RetryAfter)
+		}
+		mapError(w, err)
+		return
+	}
+	if res.Deferred {
+		queued := false
+		setRetryAfter(w, res.RetryAfter)
+		respondJSON(w, http.StatusAccepted, createResponse{Notification: n, Queued: &queued})
+		return
+	}
+	respondJSON(w, http.StatusCreated, n)
+}
+
+// Retry handles POST /api/v1/notifications/{id}/retry
+//
+// @Summary  Retry a failed notification immediately
+// @Tags     notifications
+// @Produce  json
+// @Param    id     path      string  true   "Notification UUID"
+// @Param    reset  query     bool    false  "Reset retry_count, allowing retries beyond max_retries"
+// @Success  200    {object}  domain.Notification
+// @Failure  404    {object}  errorResponse
+// @Failure  409    {object}  errorResponse
+// @Failure  503    {object}  errorResponse
+// @Router   /api/v1/notifications/{id}/retry [post]
+func (h *NotificationHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	reset := false
+	if !queryBool(w, r, "reset", &reset) {
+		return
+	}
+
+	n, err := h.svc.RetryNow(r.Context(), chi.URLParam(r, "id"), reset)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, n)
 }
 
 // Cancel handles DELETE /api/v1/notifications/{id}
 //
+// The body is optional; when present it may carry a reason for the record.
+//
 // @Summary  Cancel a pending notification
 // @Tags     notifications
-// @Param    id   path      string  true  "Notification UUID"
+// @Accept   json
+// @Param    id    path      string                true   "Notification UUID"
+// @Param    body  body      domain.CancelRequest  false  "Cancellation reason"
 // @Success  204
-// @Failure  404  {object}  map[string]string
-// @Failure  409  {object}  map[string]string
+// @Failure  404  {object}  errorResponse
+// @Failure  409  {object}  errorResponse
+// @Failure  422  {object}  errorResponse
 // @Router   /api/v1/notifications/{id} [delete]
 func (h *NotificationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	var req domain.CancelRequest
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+	req.CorrelationID = apimw.GetCorrelationID(r.Context())
+
 	id := chi.URLParam(r, "id")
-	if err := h.svc.Cancel(r.Context(), id); err != nil {
+	if err := h.svc.Cancel(r.Context(), id, req); err != nil {
 		mapError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func parseListFilter(r *http.Request) domain.ListFilter {
+// defaultListLimit is the page size when the request gives no limit.
+const defaultListLimit = 20
+
+// parseListFilter reads the list query parameters. Every invalid parameter is
+// reported, each as one FieldError, rather than silently ignored; in
+// particular a limit above maxLimit is an error, not clamped.
+func parseListFilter(r *http.Request, maxLimit int) (domain.ListFilter, error) {
 	q := r.URL.Query()
-	filter := domain.ListFilter{Page: 1, Limit: 20}
+	filter := domain.ListFilter{Page: 1, Limit: min(defaultListLimit, maxLimit), Sort: domain.SortCreatedAt, Order: domain.OrderDesc}
+	var invalid []domain.FieldError
+	reject := func(field, code, msg string) {
+		invalid = append(invalid, domain.FieldError{Field: field, Code: code, Message: msg, Err: domain.ErrInvalidFilter})
+	}
 
-	if p, err := strconv.Atoi(q.Get("page")); err == nil && p > 0 {
-		filter.Page = p
+	if v := q.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err != nil || p < 1 {
+			reject("page", "out_of_range", "page must be a positive integer")
+		} else {
+			filter.Page = p
+		}
 	}
-	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 100 {
-		filter.Limit = l
+	if v := q.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err != nil || l < 1 || l > maxLimit {
+			reject("limit", "out_of_range", fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit))
+		} else {
+			filter.Limit = l
+		}
 	}
-	if s := q.Get("status"); s != "" {
-		st := domain.Status(s)
-		filter.Status = &st
+	if v := q.Get("status"); v != "" {
+		if st := domain.Status(v); st.IsValid() {
+			filter.Status = &st
+		} else {
+			reject("status", "invalid_status", fmt.Sprintf("status %q is not a known notification status", v))
+		}
+	}
+	if v := q.Get("channel"); v != "" {
+		if ch := domain.Channel(v); ch.IsValid() {
+			filter.Channel = &ch
+		} else {
+			reject("channel", "invalid_channel", domain.ErrInvalidChannel.Error())
+		}
+	}
+	if v := q.Get("dry_run"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			filter.DryRun = &b
+		} else {
+			reject("dry_run", "invalid_bool", "dry_run must be true or false")
+		}
 	}
-	if ch := q.Get("channel"); ch != "" {
-		c := domain.Channel(ch)
-		filter.Channel = &c
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			reject(p.name, "invalid_timestamp", p.name+" must be an RFC3339 timestamp")
+			continue
+		}
+		*p.dst = &t
 	}
-	if f := q.Get("from"); f != "" {
-		if t, err := time.Parse(time.RFC3339, f); err == nil {
-			filter.From = &t
+	if v := q.Get("sort"); v != "" {
+		if f := domain.SortField(v); f.IsValid() {
+			filter.Sort = f
+		} else {
+			reject("sort", "invalid_sort", "sort must be one of created_at, updated_at, scheduled_at, sent_at")
 		}
 	}
-	if to := q.Get("to"); to != "" {
-		if t, err := time.Parse(time.RFC3339, to); err == nil {
-			filter.To = &t
+	if v := q.Get("order"); v != "" {
+		if o := domain.SortOrder(v); o.IsValid() {
+			filter.Order = o
+		} else {
+			reject("order", "invalid_order", "order must be asc or desc")
 		}
 	}
-	return filter
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		reject("from", "after_to", "from must not be after to")
+	}
+
+	if len(invalid) > 0 {
+		return filter, &domain.ValidationError{Fields: invalid}
+	}
+	return filter, nil
 }
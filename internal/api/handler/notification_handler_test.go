@@ -0,0 +1,938 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+const validBody = `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`
+
+func newNotificationHandler(q *queue.PriorityQueue) *handler.NotificationHandler {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	return handler.NewNotificationHandler(svc, 100, zap.NewNop())
+}
+
+func TestNotificationHandler_Create_Queued(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications", validBody)
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Fatal("expected no Retry-After header when queued")
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "queued" {
+		t.Fatalf("expected status=queued, got %v", body["status"])
+	}
+	if _, ok := body["queued"]; ok {
+		t.Fatal("expected no queued hint when the item was queued")
+	}
+}
+
+func TestNotificationHandler_Create_IdempotencyHeaders(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{DedupWindow: time.Hour})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+
+	post := func(key, owner string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/api/v1/notifications", validBody)
+		if key != "" {
+			req.Header.Set("X-Idempotency-Key", key)
+		}
+		req = req.WithContext(domain.WithOwner(req.Context(), owner))
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+
+	rec := post("key-1", "alice")
+	var created domain.Notification
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&created) != nil {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "/api/v1/notifications/"+created.ID {
+		t.Fatalf("expected Location of the new notification, got %q", got)
+	}
+	if rec.Header().Get("X-Idempotency-Key") != "key-1" || rec.Header().Get("X-Idempotent-Replay") != "false" {
+		t.Fatalf("expected the key echoed with replay=false, got %v", rec.Header())
+	}
+
+	rec = post("key-1", "alice")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Idempotent-Replay") != "true" || rec.Header().Get("Location") != "" {
+		t.Fatalf("expected a 200 replay without Location, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Suppressed by the dedup window, not by the key: still 200, but no replay.
+	rec = post("key-2", "alice")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Idempotent-Replay") != "false" {
+		t.Fatalf("expected a 200 dedup hit with replay=false, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Another owner's key conflicts and reveals nothing about the record.
+	rec = post("key-1", "bob")
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Idempotent-Replay") != "" {
+		t.Fatalf("expected 409 without idempotency headers, got %d %v", rec.Code, rec.Header())
+	}
+	if code := errorCodeOf(t, rec); code != "idempotency_conflict" {
+		t.Fatalf("expected code idempotency_conflict, got %q", code)
+	}
+
+	if rec := post("", "alice"); rec.Header().Get("X-Idempotency-Key") != "" || rec.Header().Get("X-Idempotent-Replay") != "" {
+		t.Fatalf("expected no idempotency headers without a key, got %v", rec.Header())
+	}
+}
+
+func TestNotificationHandler_SendIfPast(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+	bh := handler.NewBatchHandler(svc, nil, zap.NewNop())
+	r := chi.NewRouter()
+	r.Post("/notifications", h.Create)
+	r.Post("/notifications/batch", bh.CreateBatch)
+	r.Patch("/notifications/{id}", h.Update)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, jsonRequest(method, target, body))
+		return rec
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	item := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal","scheduled_at":"` + past + `"`
+	tests := []struct {
+		name, method, target, body string
+		expected                   int
+	}{
+		{"create", http.MethodPost, "/notifications", item + `}`, http.StatusUnprocessableEntity},
+		{"create with query flag", http.MethodPost, "/notifications?send_if_past=true", item + `}`, http.StatusCreated},
+		{"create with body flag", http.MethodPost, "/notifications", item + `,"send_if_past":true}`, http.StatusCreated},
+		{"create with bad flag", http.MethodPost, "/notifications?send_if_past=maybe", item + `}`, http.StatusBadRequest},
+		{"batch item", http.MethodPost, "/notifications/batch", `{"notifications":[` + item + `}]}`, http.StatusUnprocessableEntity},
+		{"batch item with batch flag", http.MethodPost, "/notifications/batch", `{"notifications":[` + item + `}],"send_if_past":true}`, http.StatusCreated},
+		{"batch schedule", http.MethodPost, "/notifications/batch", `{"notifications":[` + validBody + `],"scheduled_at":"` + past + `"}`, http.StatusUnprocessableEntity},
+		{"batch schedule with query flag", http.MethodPost, "/notifications/batch?send_if_past=true", `{"notifications":[` + validBody + `],"scheduled_at":"` + past + `"}`, http.StatusCreated},
+	}
+	for _, tc := range tests {
+		rec := do(tc.method, tc.target, tc.body)
+		if rec.Code != tc.expected {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.expected, rec.Code, rec.Body)
+		}
+		if rec.Code == http.StatusUnprocessableEntity {
+			if d := decodeError(t, rec.Body).Error.Details; len(d) != 1 || d[0].Code != "in_past" {
+				t.Fatalf("%s: expected an in_past detail, got %+v", tc.name, d)
+			}
+		}
+	}
+
+	rec := do(http.MethodPost, "/notifications", `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal","draft":true}`)
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do(http.MethodPatch, "/notifications/"+n.ID, `{"scheduled_at":"`+past+`"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reschedule: expected 422, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPatch, "/notifications/"+n.ID+"?send_if_past=true", `{"scheduled_at":"`+past+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("reschedule with flag: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_Update_Preconditions(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	r := chi.NewRouter()
+	r.Post("/notifications", h.Create)
+	r.Get("/notifications/{id}", h.GetByID)
+	r.Patch("/notifications/{id}", h.Update)
+
+	body := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal","draft":true}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodPost, "/notifications", body))
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/"+n.ID, nil))
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("expected ETag and Last-Modified on GET, got %v", rec.Header())
+	}
+
+	patch := func(header, value string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPatch, "/notifications/"+n.ID, `{"max_retries":5}`)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = patch("If-Match", etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("expected 200 with a new ETag, got %d %v", rec.Code, rec.Header())
+	}
+	// An edit always answers with the new representation, never 304.
+	rec = patch("If-None-Match", "*")
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("expected 200 with a body whatever If-None-Match says, got %d", rec.Code)
+	}
+	current := rec.Header().Get("ETag")
+
+	tests := []struct {
+		header, value string
+		expected      int
+	}{
+		{"If-Match", etag, http.StatusPreconditionFailed},           // consumed by the edit above
+		{"If-Match", "W/" + current, http.StatusPreconditionFailed}, // If-Match compares strongly
+		{"If-Match", "*", http.StatusOK},
+		{"If-Unmodified-Since", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusPreconditionFailed},
+		{"If-Unmodified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tc := range tests {
+		rec := patch(tc.header, tc.value)
+		if rec.Code != tc.expected {
+			t.Fatalf("%s: %s: expected %d, got %d %s", tc.header, tc.value, tc.expected, rec.Code, rec.Body)
+		}
+		if rec.Code == http.StatusPreconditionFailed && errorCodeOf(t, rec) != "precondition_failed" {
+			t.Fatalf("%s: %s: expected code precondition_failed", tc.header, tc.value)
+		}
+	}
+}
+
+func TestNotificationHandler_GetByID_IfNoneMatch(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Get("/notifications/{id}", handler.NewNotificationHandler(svc, 100, zap.NewNop()).GetByID)
+	n := &domain.Notification{ID: "n1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello",
+		Priority: domain.PriorityNormal, Status: domain.StatusQueued}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notifications/n1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("expected 200 with a strong ETag, got %d %q", rec.Code, etag)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("expected Cache-Control: no-cache, got %q", cc)
+	}
+
+	rec = get(etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Fatalf("expected an empty 304 carrying the ETag, got %d %q", rec.Code, rec.Body)
+	}
+	if rec := get("W/" + etag); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected If-None-Match to compare weakly, got %d", rec.Code)
+	}
+	if rec := get(`W/"0"`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a mismatched ETag, got %d", rec.Code)
+	}
+
+	time.Sleep(time.Millisecond) // let updated_at move
+	if err := repo.MarkFailed(context.Background(), "n1", "rejected"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("expected a fresh 200 after the status changed, got %d", rec.Code)
+	}
+}
+
+func TestNotificationHandler_Lookup(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	var ids []string
+	for range 2 {
+		rec := httptest.NewRecorder()
+		h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", validBody))
+		var n domain.Notification
+		if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, n.ID)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Lookup(rec, jsonRequest(http.MethodPost, "/api/v1/notifications/lookup",
+		fmt.Sprintf(`{"ids":[%q,"nope",%q]}`, ids[1], ids[0])))
+	var resp struct {
+		Notifications []domain.Notification `json:"notifications"`
+		Missing       []string              `json:"missing"`
+	}
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&resp) != nil {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	if len(resp.Notifications) != 2 || resp.Notifications[0].ID != ids[1] || resp.Notifications[1].ID != ids[0] {
+		t.Fatalf("expected the notifications in request order, got %+v", resp.Notifications)
+	}
+	if len(resp.Missing) != 1 || resp.Missing[0] != "nope" {
+		t.Fatalf("expected [nope] missing, got %v", resp.Missing)
+	}
+
+	tooMany := `{"ids":["` + strings.Repeat(`x","`, 100) + `x"]}`
+	for _, body := range []string{`{"ids":[]}`, tooMany} {
+		rec := httptest.NewRecorder()
+		h.Lookup(rec, jsonRequest(http.MethodPost, "/api/v1/notifications/lookup", body))
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d %s", rec.Code, rec.Body)
+		}
+		if resp := decodeError(t, rec.Body); len(resp.Error.Details) != 1 || resp.Error.Details[0].Field != "ids" {
+			t.Fatalf("expected an ids field error, got %+v", resp.Error)
+		}
+	}
+}
+
+func TestNotificationHandler_Create_QueueFull(t *testing.T) {
+	h := newNotificationHandler(queue.NewWithCapacity(0, 0, 0))
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications", validBody)
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header when the queue is full")
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "pending" {
+		t.Fatalf("expected status=pending, got %v", body["status"])
+	}
+	if body["queued"] != false {
+		t.Fatalf("expected queued=false, got %v", body["queued"])
+	}
+}
+
+// newFailedNotification creates a notification and marks it failed after
+// retryCount attempts (max_retries is the default of 3).
+func newFailedNotification(t *testing.T, q *queue.PriorityQueue, retryCount int) (http.Handler, *repository.MockNotificationRepository, string) {
+	t.Helper()
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+
+	n, _, err := svc.Create(context.Background(), domain.CreateNotificationRequest{
+		Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello", Priority: domain.PriorityNormal,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ScheduleRetry(context.Background(), n.ID, retryCount, time.Now().Add(time.Hour), "provider down"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/notifications/{id}/retry", handler.NewNotificationHandler(svc, 100, zap.NewNop()).Retry)
+	return r, repo, n.ID
+}
+
+func postRetry(h http.Handler, id, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/"+id+"/retry"+query, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNotificationHandler_Retry_RequeuesFailed(t *testing.T) {
+	q := queue.New()
+	h, _, id := newFailedNotification(t, q, 1)
+	_, before, _ := q.Depths()
+
+	rec := postRetry(h, id, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != domain.StatusQueued || n.RetryCount != 1 {
+		t.Fatalf("expected queued with retry_count kept, got %s/%d", n.Status, n.RetryCount)
+	}
+	if n.NextRetryAt == nil || n.NextRetryAt.After(time.Now()) {
+		t.Fatalf("expected next_retry_at reset to now, got %v", n.NextRetryAt)
+	}
+	if _, after, _ := q.Depths(); after != before+1 {
+		t.Fatalf("expected the notification to be enqueued")
+	}
+}
+
+func TestNotificationHandler_Retry_RespectsMaxRetries(t *testing.T) {
+	h, _, id := newFailedNotification(t, queue.New(), 3)
+
+	if rec := postRetry(h, id, ""); rec.Code != http.StatusConflict || errorCodeOf(t, rec) != "retries_exhausted" {
+		t.Fatalf("expected 409 retries_exhausted, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec := postRetry(h, id, "?reset=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with reset=true, got %d", rec.Code)
+	}
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n.RetryCount != 0 {
+		t.Fatalf("expected retry_count reset to 0, got %d", n.RetryCount)
+	}
+}
+
+func TestNotificationHandler_Retry_NonFailedConflicts(t *testing.T) {
+	h, repo, id := newFailedNotification(t, queue.New(), 0)
+	if err := repo.UpdateStatus(context.Background(), id, domain.StatusSent); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := postRetry(h, id, ""); rec.Code != http.StatusConflict || errorCodeOf(t, rec) != "not_retryable" {
+		t.Fatalf("expected 409 not_retryable for a sent notification, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := postRetry(h, "missing", ""); rec.Code != http.StatusNotFound || errorCodeOf(t, rec) != "not_found" {
+		t.Fatalf("expected 404 not_found for an unknown id, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_Retry_QueueFull(t *testing.T) {
+	h, repo, id := newFailedNotification(t, queue.NewWithCapacity(0, 0, 0), 0)
+
+	if rec := postRetry(h, id, ""); rec.Code != http.StatusServiceUnavailable || errorCodeOf(t, rec) != "queue_full" {
+		t.Fatalf("expected 503 queue_full when the queue is full, got %d %s", rec.Code, rec.Body)
+	}
+	n, _ := repo.GetByID(context.Background(), id)
+	if n.Status != domain.StatusFailed {
+		t.Fatalf("expected the row to fall back to failed for the retry worker, got %s", n.Status)
+	}
+}
+
+func TestNotificationHandler_ChangePriority(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Post("/api/v1/notifications/{id}/priority", handler.NewNotificationHandler(svc, 100, zap.NewNop()).ChangePriority)
+
+	n, _, err := svc.Create(context.Background(), domain.CreateNotificationRequest{
+		Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello", Priority: domain.PriorityLow,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/api/v1/notifications/"+n.ID+"/priority", body)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"priority":"high"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := post(`{"priority":"urgent"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unknown priority, got %d", rec.Code)
+	}
+
+	_ = repo.MarkSent(context.Background(), n.ID, "msg-1", time.Now())
+	if rec := post(`{"priority":"normal"}`); rec.Code != http.StatusConflict || errorCodeOf(t, rec) != "priority_locked" {
+		t.Fatalf("expected 409 priority_locked once sent, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_Create_StrictEnqueue(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.NewWithCapacity(0, 0, 0), zap.NewNop(), service.Options{StrictEnqueue: true})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+
+	req := jsonRequest(http.MethodPost, "/api/v1/notifications", validBody)
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable || errorCodeOf(t, rec) != "queue_full" {
+		t.Fatalf("expected 503 queue_full, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header on 503")
+	}
+}
+
+func TestNotificationHandler_Create_RateLimited(t *testing.T) {
+	limiter := ratelimiter.NewTenant(ratelimiter.TenantLimits{RequestsPerMinute: 1}, nil, nil)
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{CreationLimiter: limiter})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", validBody))
+		return rec
+	}
+	if rec := post(); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	rec := post()
+	if rec.Code != http.StatusTooManyRequests || errorCodeOf(t, rec) != "rate_limited" {
+		t.Fatalf("expected 429 rate_limited, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header on 429")
+	}
+}
+
+func TestNotificationHandler_Create_FanOut(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	body := `{"channel":"sms","recipients":["+905551234567","+905551234568"],"content":"Hello","priority":"normal"}`
+
+	post := func() *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/api/v1/notifications", body)
+		req.Header.Set("X-Idempotency-Key", "fan-1")
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+
+	rec := post()
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var batch domain.Batch
+	if err := json.NewDecoder(rec.Body).Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Total != 2 {
+		t.Fatalf("expected a batch of 2, got %+v", batch)
+	}
+	if rec.Header().Get("Location") != "/api/v1/batches/"+batch.ID || rec.Header().Get("X-Idempotent-Replay") != "false" {
+		t.Fatalf("expected the batch Location and replay=false, got %v", rec.Header())
+	}
+
+	if rec := post(); rec.Code != http.StatusOK || rec.Header().Get("X-Idempotent-Replay") != "true" {
+		t.Fatalf("expected 200 for the replayed fan-out, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestNotificationHandler_Submit(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	r := chi.NewRouter()
+	r.Post("/notifications", h.Create)
+	r.Post("/notifications/{id}/submit", h.Submit)
+
+	body := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal","draft":true}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodPost, "/notifications", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var draft domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&draft); err != nil {
+		t.Fatal(err)
+	}
+	if draft.Status != domain.StatusDraft {
+		t.Fatalf("expected status=draft, got %s", draft.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications/"+draft.ID+"/submit", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications/"+draft.ID+"/submit", nil))
+	if rec.Code != http.StatusConflict || errorCodeOf(t, rec) != "not_draft" {
+		t.Fatalf("expected 409 not_draft for a second submit, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_Cancel_WithAndWithoutBody(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	r := chi.NewRouter()
+	r.Post("/notifications", h.Create)
+	r.Delete("/notifications/{id}", h.Cancel)
+	r.Get("/notifications/{id}", h.GetByID)
+
+	create := func() string {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, jsonRequest(http.MethodPost, "/notifications", validBody))
+		var n domain.Notification
+		if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n.ID
+	}
+
+	id := create()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/notifications/"+id, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 without a body, got %d", rec.Code)
+	}
+
+	id = create()
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodDelete, "/notifications/"+id, `{"reason":"sent by mistake"}`))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 with a reason, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/"+id, nil))
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n.CancelledReason == nil || *n.CancelledReason != "sent by mistake" {
+		t.Fatalf("expected the reason in the notification JSON, got %v", n.CancelledReason)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodDelete, "/notifications/"+create(), `{"reason":`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed JSON, got %d", rec.Code)
+	}
+}
+
+func TestNotificationHandler_EditsDecodeStrictly(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+	r := chi.NewRouter()
+	r.Post("/notifications", h.Create)
+	r.Patch("/notifications/{id}", h.Update)
+	r.Post("/notifications/{id}/priority", h.ChangePriority)
+	r.Delete("/notifications/{id}", h.Cancel)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodPost, "/notifications", validBody))
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	// metadata cannot be edited; it is rejected rather than silently dropped.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, jsonRequest(http.MethodPatch, "/notifications/"+n.ID, `{"metadata":{"order":"42"}}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown field, got %d", rec.Code)
+	}
+	if d := decodeError(t, rec.Body).Error.Details; len(d) != 1 || d[0].Field != "metadata" || d[0].Code != "unknown_field" {
+		t.Fatalf("expected metadata reported as unknown, got %+v", d)
+	}
+
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPatch, "/notifications/" + n.ID, `{"priority":"high"}`},
+		{http.MethodPost, "/notifications/" + n.ID + "/priority", `{"priority":"high"}`},
+		{http.MethodDelete, "/notifications/" + n.ID, `{"reason":"sent by mistake"}`},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("%s %s without a Content-Type: expected 415, got %d", tc.method, tc.target, rec.Code)
+		}
+	}
+}
+
+func TestNotificationHandler_Resend(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Post("/notifications/{id}/resend", handler.NewNotificationHandler(svc, 100, zap.NewNop()).Resend)
+
+	resend := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications/"+id+"/resend", nil))
+		return rec
+	}
+
+	orig, _, err := svc.Create(ctx, domain.CreateNotificationRequest{
+		Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello", Priority: domain.PriorityHigh,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := resend(orig.ID); rec.Code != http.StatusConflict || errorCodeOf(t, rec) != "resend_in_flight" {
+		t.Fatalf("expected 409 resend_in_flight while the original is queued, got %d %s", rec.Code, rec.Body)
+	}
+
+	if err := repo.ScheduleRetry(ctx, orig.ID, 1, time.Now().Add(time.Minute), "provider down"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := resend(orig.ID); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while a retry is scheduled, got %d", rec.Code)
+	}
+
+	if err := repo.MarkSent(ctx, orig.ID, "prov-1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	rec := resend(orig.ID)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var n domain.Notification
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n.ID == orig.ID || n.ResendOf == nil || *n.ResendOf != orig.ID {
+		t.Fatalf("expected a new notification linked to %s, got %+v", orig.ID, n)
+	}
+	if n.Recipient != orig.Recipient || n.Content != orig.Content || n.Priority != orig.Priority {
+		t.Fatalf("expected the message to be copied, got %+v", n)
+	}
+	if n.Status != domain.StatusQueued {
+		t.Fatalf("expected the copy to be queued, got %s", n.Status)
+	}
+
+	if rec := resend("missing"); rec.Code != http.StatusNotFound || errorCodeOf(t, rec) != "not_found" {
+		t.Fatalf("expected 404 not_found for an unknown id, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_Resend_CancelledAndFinallyFailed(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	r := chi.NewRouter()
+	r.Post("/notifications/{id}/resend", handler.NewNotificationHandler(svc, 100, zap.NewNop()).Resend)
+
+	for _, finish := range []func(id string) error{
+		func(id string) error { return svc.Cancel(ctx, id, domain.CancelRequest{}) },
+		func(id string) error { return repo.MarkFailed(ctx, id, "invalid number") },
+	} {
+		orig, _, err := svc.Create(ctx, domain.CreateNotificationRequest{
+			Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello", Priority: domain.PriorityNormal,
+			AllowDuplicate: true,
+		}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := finish(orig.ID); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications/"+orig.ID+"/resend", nil))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestNotificationHandler_Create_ValidationDetails(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+
+	body := `{"channel":"sms","recipient":"   ","content":"Hello","priority":"normal"}`
+	rec := httptest.NewRecorder()
+	h.Create(rec, jsonRequest(http.MethodPost, "/api/v1/notifications", body))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Details []struct {
+				Field   string `json:"field"`
+				Index   *int   `json:"index"`
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error.Code != "validation_failed" || resp.Error.Message == "" {
+		t.Fatalf("unexpected envelope: %+v", resp.Error)
+	}
+	d := resp.Error.Details
+	if len(d) != 1 || d[0].Field != "recipient" || d[0].Code != "required" || d[0].Index != nil || d[0].Message == "" {
+		t.Fatalf("unexpected details: %+v", d)
+	}
+}
+
+func TestNotificationHandler_List_EmptyIsArray(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?status=sent", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"data":[],"total":0,"page":1,"limit":20,"total_pages":0,"has_next":false,"has_prev":false,"sort":"created_at","order":"desc"}`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNotificationHandler_List_RejectsInvalidQuery(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"status=bananas", "status"},
+		{"channel=fax", "channel"},
+		{"from=not-a-date", "from"},
+		{"to=2026-13-01", "to"},
+		{"from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z", "from"},
+		{"page=0", "page"},
+		{"page=abc", "page"},
+		{"limit=5000", "limit"},
+		{"limit=0", "limit"},
+		{"sort=recipient", "sort"},
+		{"order=up", "order"},
+		{"dry_run=maybe", "dry_run"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?"+tc.query, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", rec.Code)
+			}
+			var resp struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details []struct {
+						Field string `json:"field"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error.Code != "bad_request" || len(resp.Error.Details) != 1 || resp.Error.Details[0].Field != tc.field {
+				t.Fatalf("expected one %s error, got %+v", tc.field, resp.Error)
+			}
+		})
+	}
+}
+
+func TestNotificationHandler_List_ValidFilters(t *testing.T) {
+	h := newNotificationHandler(queue.New())
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet,
+		"/api/v1/notifications?status=sent&channel=sms&from=2026-03-01T00:00:00Z&to=2026-03-02T00:00:00Z&page=2&limit=100", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_List_FiltersDryRuns(t *testing.T) {
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(), service.Options{})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+	dry := domain.CreateNotificationRequest{
+		Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "QA run", Priority: domain.PriorityNormal, DryRun: true,
+	}
+	live := dry
+	live.Content, live.DryRun = "Your order has shipped", false
+	for _, req := range []domain.CreateNotificationRequest{dry, live} {
+		if _, _, err := svc.Create(context.Background(), req, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for query, want := range map[string]string{"dry_run=true": "QA run", "dry_run=false": "Your order has shipped"} {
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?"+query, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":1`) || !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("%s: expected only %q, got %d %s", query, want, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestNotificationHandler_List_ConfiguredMaxPageSize(t *testing.T) {
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(), service.Options{})
+	h := handler.NewNotificationHandler(svc, 1000, zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?limit=500", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"limit":500`) {
+		t.Fatalf("expected limit=500 to be honoured, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?limit=1001", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "between 1 and 1000") {
+		t.Fatalf("expected 400 stating the allowed range, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNotificationHandler_List_PaginationAndSort(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	h := handler.NewNotificationHandler(svc, 100, zap.NewNop())
+	base := time.Now().UTC()
+	for i, id := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		repo.Create(context.Background(), &domain.Notification{ID: id, CreatedAt: base.Add(time.Duration(i) * time.Minute)}) //nolint:errcheck
+	}
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/notifications?page=2&limit=2&sort=created_at&order=asc", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Total      int    `json:"total"`
+		TotalPages int    `json:"total_pages"`
+		HasNext    bool   `json:"has_next"`
+		HasPrev    bool   `json:"has_prev"`
+		Sort       string `json:"sort"`
+		Order      string `json:"order"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 5 || resp.TotalPages != 3 || !resp.HasNext || !resp.HasPrev {
+		t.Fatalf("unexpected pagination metadata: %+v", resp)
+	}
+	if resp.Sort != "created_at" || resp.Order != "asc" {
+		t.Fatalf("expected the applied sort echoed, got %s %s", resp.Sort, resp.Order)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].ID != "n3" || resp.Data[1].ID != "n4" {
+		t.Fatalf("expected the second page oldest first (n3, n4), got %+v", resp.Data)
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/provider"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+)
+
+// Pinger is satisfied by *pgxpool.Pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LeaderChecker is satisfied by *db.Elector.
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
+// ReadinessHandler serves the readiness probe: unlike /health it fails while
+// the service cannot do useful work, so the orchestrator stops routing to it.
+type ReadinessHandler struct {
+	db              Pinger
+	q               *queue.PriorityQueue
+	prov            provider.HealthChecker
+	leader          LeaderChecker
+	maxQueuePercent int
+	timeout         time.Duration
+}
+
+// NewReadinessHandler checks db and q, plus prov when it is non-nil. A queue
+// tier filled to maxQueuePercent of its capacity or more counts as saturated.
+// timeout bounds each dependency check. leader, when non-nil, is reported as
+// is_leader; a standby is as ready as the leader.
+func NewReadinessHandler(
+	db Pinger,
+	q *queue.PriorityQueue,
+	prov provider.HealthChecker,
+	leader LeaderChecker,
+	maxQueuePercent int,
+	timeout time.Duration,
+) *ReadinessHandler {
+	return &ReadinessHandler{db: db, q: q, prov: prov, leader: leader, maxQueuePercent: maxQueuePercent, timeout: timeout}
+}
+
+// readinessResponse maps every checked component to "ok" or why it failed.
+// IsLeader is set only with leader election on.
+type readinessResponse struct {
+	Status   string            `json:"status"`
+	Checks   map[string]string `json:"checks"`
+	IsLeader *bool             `json:"is_leader,omitempty"`
+}
+
+// Ready handles GET /ready
+//
+// @Summary  Readiness probe
+// @Tags     system
+// @Produce  json
+// @Success  200  {object}  readinessResponse
+// @Failure  503  {object}  readinessResponse  "At least one component is failing"
+// @Router   /ready [get]
+func (h *ReadinessHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]error{
+		"database": h.check(r.Context(), h.db.Ping),
+		"queue":    h.checkQueue(),
+	}
+	if h.prov != nil {
+		checks["provider"] = h.check(r.Context(), h.prov.HealthCheck)
+	}
+
+	resp := readinessResponse{Status: "ready", Checks: make(map[string]string, len(checks))}
+	if h.leader != nil {
+		leader := h.leader.IsLeader()
+		resp.IsLeader = &leader
+	}
+	status := http.StatusOK
+	for name, err := range checks {
+		if err != nil {
+			resp.Checks[name] = err.Error()
+			resp.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+	respondJSON(w, status, resp)
+}
+
+func (h *ReadinessHandler) check(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// checkQueue fails when any priority tier is at or above the fill threshold.
+func (h *ReadinessHandler) checkQueue() error {
+	high, normal, low := h.q.Depths()
+	capHigh, capNormal, capLow := h.q.Capacities()
+	tiers := []struct {
+		name            string
+		depth, capacity int
+	}{
+		{"high", high, capHigh},
+		{"normal", normal, capNormal},
+		{"low", low, capLow},
+	}
+	for _, t := range tiers {
+		if t.capacity > 0 && t.depth*100 >= t.capacity*h.maxQueuePercent {
+			return fmt.Errorf("%s priority queue saturated: %d/%d", t.name, t.depth, t.capacity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+type healthFunc func(ctx context.Context) error
+
+func (f healthFunc) HealthCheck(ctx context.Context) error { return f(ctx) }
+
+func getReady(h *handler.ReadinessHandler) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var body map[string]any
+	json.NewDecoder(rec.Body).Decode(&body) //nolint:errcheck
+	return rec.Code, body
+}
+
+func TestReadinessHandler_Ready(t *testing.T) {
+	ok := pingFunc(func(context.Context) error { return nil })
+	h := handler.NewReadinessHandler(ok, queue.New(), nil, nil, 90, time.Second)
+
+	code, body := getReady(h)
+	if code != http.StatusOK || body["status"] != "ready" {
+		t.Fatalf("expected 200 ready, got %d %v", code, body)
+	}
+	checks := body["checks"].(map[string]any)
+	if checks["database"] != "ok" || checks["queue"] != "ok" {
+		t.Fatalf("unexpected checks: %v", checks)
+	}
+	if _, present := checks["provider"]; present {
+		t.Fatal("provider must not be reported when no health check is configured")
+	}
+}
+
+func TestReadinessHandler_FailingComponents(t *testing.T) {
+	db := pingFunc(func(context.Context) error { return errors.New("connection refused") })
+	prov := healthFunc(func(context.Context) error { return nil })
+	q := queue.NewWithCapacity(10, 10, 10)
+	for i := 0; i < 9; i++ {
+		q.Enqueue(queue.Item{NotificationID: string(rune('a' + i)), Priority: domain.PriorityNormal}) //nolint:errcheck
+	}
+	h := handler.NewReadinessHandler(db, q, prov, nil, 90, time.Second)
+
+	code, body := getReady(h)
+	if code != http.StatusServiceUnavailable || body["status"] != "not_ready" {
+		t.Fatalf("expected 503 not_ready, got %d %v", code, body)
+	}
+	checks := body["checks"].(map[string]any)
+	if checks["database"] != "connection refused" {
+		t.Fatalf("expected database failure, got %v", checks["database"])
+	}
+	if checks["queue"] == "ok" {
+		t.Fatal("expected the 90% full normal tier to count as saturated")
+	}
+	if checks["provider"] != "ok" {
+		t.Fatalf("expected provider ok, got %v", checks["provider"])
+	}
+}
+
+func TestReadinessHandler_CheckTimeout(t *testing.T) {
+	slow := pingFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h := handler.NewReadinessHandler(slow, queue.New(), nil, nil, 90, 10*time.Millisecond)
+
+	if code, _ := getReady(h); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the database ping times out, got %d", code)
+	}
+}
+
+type leaderFunc func() bool
+
+func (f leaderFunc) IsLeader() bool { return f() }
+
+func TestReadinessHandler_ReportsLeadership(t *testing.T) {
+	ok := pingFunc(func(context.Context) error { return nil })
+
+	_, body := getReady(handler.NewReadinessHandler(ok, queue.New(), nil, nil, 90, time.Second))
+	if _, present := body["is_leader"]; present {
+		t.Fatal("is_leader must not be reported without leader election")
+	}
+
+	for _, leader := range []bool{true, false} {
+		h := handler.NewReadinessHandler(ok, queue.New(), nil, leaderFunc(func() bool { return leader }), 90, time.Second)
+		code, body := getReady(h)
+		if code != http.StatusOK {
+			t.Fatalf("expected a standby to be as ready as the leader, got %d", code)
+		}
+		if body["is_leader"] != leader {
+			t.Fatalf("expected is_leader=%v, got %v", leader, body["is_leader"])
+		}
+	}
+}
@@ -14,29 +14,159 @@ func respondJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// errorResponse is the body of every error response. Details lists the
+// rejected fields of a validation error (422) or the invalid query
+// parameters of a 400, and is omitted otherwise.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody.Code keeps the snake_case codes responses carried before codes
+// were tied to domain sentinels, rather than UPPER_CASE ones, so clients that
+// already branch on them keep working. conflict and unavailable are the
+// fallbacks of errorCode for a 409 or 503 no sentinel names.
+type errorBody struct {
+	Code    string              `json:"code" enums:"bad_request,unauthorized,not_found,conflict,idempotency_conflict,template_name_taken,already_cancelled,not_cancellable,not_editable,not_draft,resend_in_flight,priority_locked,not_retryable,retries_exhausted,precondition_failed,payload_too_large,unsupported_media_type,validation_failed,rate_limited,queue_full,unavailable,timeout,internal_error"`
+	Message string              `json:"message"`
+	Details []domain.FieldError `json:"details,omitempty"`
+}
+
+// listResponse is the envelope of unpaginated list endpoints. Build it with
+// newListResponse so an empty result serialises as [] rather than null.
+type listResponse[T any] struct {
+	Data []T `json:"data"`
+}
+
+func newListResponse[T any](data []T) listResponse[T] {
+	return listResponse[T]{Data: nonNil(data)}
+}
+
+// pageResponse is the envelope of paginated list endpoints. Sort and Order
+// echo the ordering that was applied.
+type pageResponse[T any] struct {
+	Data       []T              `json:"data"`
+	Total      int              `json:"total"`
+	Page       int              `json:"page"`
+	Limit      int              `json:"limit"`
+	TotalPages int              `json:"total_pages"`
+	HasNext    bool             `json:"has_next"`
+	HasPrev    bool             `json:"has_prev"`
+	Sort       domain.SortField `json:"sort"`
+	Order      domain.SortOrder `json:"order"`
+}
+
+func newPageResponse[T any](data []T, total int, f domain.ListFilter) pageResponse[T] {
+	totalPages := (total + f.Limit - 1) / f.Limit
+	return pageResponse[T]{
+		Data:       nonNil(data),
+		Total:      total,
+		Page:       f.Page,
+		Limit:      f.Limit,
+		TotalPages: totalPages,
+		HasNext:    f.Page < totalPages,
+		HasPrev:    f.Page > 1,
+		Sort:       f.Sort,
+		Order:      f.Order,
+	}
+}
+
+// nonNil returns s, or an empty slice when s is nil, so JSON gets [] not null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
 func respondError(w http.ResponseWriter, status int, msg string) {
-	respondJSON(w, status, map[string]string{"error": msg})
+	respondJSON(w, status, errorResponse{Error: errorBody{Code: errorCode(status), Message: msg}})
+}
+
+// respondValidation answers 422 with one detail per rejected field.
+func respondValidation(w http.ResponseWriter, msg string, details []domain.FieldError) {
+	respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: errorBody{
+		Code:    errorCode(http.StatusUnprocessableEntity),
+		Message: msg,
+		Details: details,
+	}})
+}
+
+// respondBadQuery answers 400 for invalid query parameters, listing each one
+// like respondValidation does for body fields.
+func respondBadQuery(w http.ResponseWriter, err error) {
+	respondJSON(w, http.StatusBadRequest, errorResponse{Error: errorBody{
+		Code:    errorCode(http.StatusBadRequest),
+		Message: err.Error(),
+		Details: domain.Details(err),
+	}})
+}
+
+// respondDomainError answers status with err's message and the code of the
+// domain sentinel it wraps, falling back to the one for status.
+func respondDomainError(w http.ResponseWriter, status int, err error) {
+	code := domain.ErrorCode(err)
+	if code == "" {
+		code = errorCode(status)
+	}
+	respondJSON(w, status, errorResponse{Error: errorBody{Code: code, Message: err.Error()}})
+}
+
+// errorCode is the machine-readable top-level code for an error status, used
+// when no domain sentinel gives a more specific one.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	default:
+		return "internal_error"
+	}
 }
 
-// mapError translates domain sentinel errors to HTTP status codes.
-// All mapping lives here so individual handlers stay concise.
+// mapError translates domain sentinel errors to HTTP status codes and error
+// codes. All mapping lives here so individual handlers stay concise.
 func mapError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, domain.ErrNotFound):
-		respondError(w, http.StatusNotFound, err.Error())
+		respondDomainError(w, http.StatusNotFound, err)
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		respondDomainError(w, http.StatusPreconditionFailed, err)
 	case errors.Is(err, domain.ErrConflict),
+		errors.Is(err, domain.ErrTemplateNameTaken),
 		errors.Is(err, domain.ErrAlreadyCancelled),
-		errors.Is(err, domain.ErrNotCancellable):
-		respondError(w, http.StatusConflict, err.Error())
-	case errors.Is(err, domain.ErrInvalidChannel),
-		errors.Is(err, domain.ErrInvalidPriority),
-		errors.Is(err, domain.ErrInvalidContent),
-		errors.Is(err, domain.ErrInvalidRecipient),
-		errors.Is(err, domain.ErrBatchTooLarge),
-		errors.Is(err, domain.ErrBatchEmpty):
-		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		errors.Is(err, domain.ErrNotCancellable),
+		errors.Is(err, domain.ErrNotDraft),
+		errors.Is(err, domain.ErrResendInFlight),
+		errors.Is(err, domain.ErrNotEditable),
+		errors.Is(err, domain.ErrPriorityLocked),
+		errors.Is(err, domain.ErrNotRetryable),
+		errors.Is(err, domain.ErrRetriesExhausted):
+		respondDomainError(w, http.StatusConflict, err)
+	case domain.IsValidationError(err):
+		respondValidation(w, err.Error(), domain.Details(err))
+	case errors.Is(err, domain.ErrRateLimited):
+		var rl *domain.RateLimitError
+		if errors.As(err, &rl) {
+			setRetryAfter(w, rl.RetryAfter)
+		}
+		respondDomainError(w, http.StatusTooManyRequests, err)
 	case errors.Is(err, domain.ErrQueueFull):
-		respondError(w, http.StatusServiceUnavailable, err.Error())
+		respondDomainError(w, http.StatusServiceUnavailable, err)
 	default:
 		respondError(w, http.StatusInternalServerError, "internal server error")
 	}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestErrorCode_ListedInEnum(t *testing.T) {
+	field, _ := reflect.TypeOf(errorBody{}).FieldByName("Code")
+	enum := strings.Split(field.Tag.Get("enums"), ",")
+
+	for status := 400; status < 600; status++ {
+		if code := errorCode(status); !slices.Contains(enum, code) {
+			t.Errorf("errorCode(%d) = %q, missing from the enums tag", status, code)
+		}
+	}
+}
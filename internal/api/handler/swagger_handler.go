@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at doc.json, so no
+// UI assets have to be vendored into the binary.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Event-Driven Notification System API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: "doc.json", dom_id: "#swagger-ui" }); };
+  </script>
+</body>
+</html>
+`
+
+// SwaggerHandler publishes the OpenAPI document and a browsable UI for it.
+type SwaggerHandler struct {
+	spec []byte // JSON
+}
+
+// NewSwaggerHandler converts the YAML specification to JSON once, up front,
+// so a malformed document fails at startup rather than on first request.
+func NewSwaggerHandler(specYAML []byte) (*SwaggerHandler, error) {
+	var doc any
+	if err := yaml.Unmarshal(specYAML, &doc); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI spec: %w", err)
+	}
+	spec, err := json.Marshal(jsonCompatible(doc))
+	if err != nil {
+		return nil, fmt.Errorf("encode OpenAPI spec: %w", err)
+	}
+	return &SwaggerHandler{spec: spec}, nil
+}
+
+// Spec handles GET /swagger/doc.json
+func (h *SwaggerHandler) Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.spec) //nolint:errcheck
+}
+
+// UI handles GET /swagger/
+func (h *SwaggerHandler) UI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage)) //nolint:errcheck
+}
+
+// jsonCompatible rewrites the map[any]any nodes YAML produces for non-string
+// keys (such as unquoted status codes) into map[string]any.
+func jsonCompatible(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonCompatible(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = jsonCompatible(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
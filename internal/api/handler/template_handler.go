@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+// TemplateHandler handles CRUD endpoints for content templates.
+type TemplateHandler struct {
+	svc    *service.TemplateService
+	logger *zap.Logger
+}
+
+func NewTemplateHandler(svc *service.TemplateService, logger *zap.Logger) *TemplateHandler {
+	return &TemplateHandler{svc: svc, logger: logger}
+}
+
+// Create handles POST /api/v1/templates
+//
+// @Summary  Create a content template
+// @Tags     templates
+// @Accept   json
+// @Produce  json
+// @Param    body  body      domain.TemplateRequest  true  "Template payload"
+// @Success  201   {object}  domain.Template
+// @Header   201   {string}  Location  "URL of the new template"
+// @Failure  409   {object}  errorResponse
+// @Failure  422   {object}  errorResponse
+// @Router   /api/v1/templates [post]
+func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req domain.TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	t, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		h.logger.Warn("create template failed", zap.Error(err))
+		mapError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/templates/"+t.ID)
+	respondJSON(w, http.StatusCreated, t)
+}
+
+// List handles GET /api/v1/templates
+//
+// @Summary  List content templates
+// @Tags     templates
+// @Produce  json
+// @Success  200  {object}  listResponse[domain.Template]
+// @Router   /api/v1/templates [get]
+func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.svc.List(r.Context())
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, newListResponse(templates))
+}
+
+// GetByID handles GET /api/v1/templates/{id}
+//
+// @Summary  Get a content template
+// @Tags     templates
+// @Produce  json
+// @Param    id   path      string  true  "Template UUID"
+// @Success  200  {object}  domain.Template
+// @Failure  404  {object}  errorResponse
+// @Router   /api/v1/templates/{id} [get]
+func (h *TemplateHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	t, err := h.svc.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, t)
+}
+
+// Update handles PUT /api/v1/templates/{id}
+//
+// @Summary  Replace a content template
+// @Tags     templates
+// @Accept   json
+// @Produce  json
+// @Param    id    path      string                  true  "Template UUID"
+// @Param    body  body      domain.TemplateRequest  true  "Template payload"
+// @Success  200   {object}  domain.Template
+// @Failure  404   {object}  errorResponse
+// @Failure  409   {object}  errorResponse
+// @Failure  422   {object}  errorResponse
+// @Router   /api/v1/templates/{id} [put]
+func (h *TemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req domain.TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	t, err := h.svc.Update(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, t)
+}
+
+// Delete handles DELETE /api/v1/templates/{id}
+//
+// @Summary  Delete a content template
+// @Tags     templates
+// @Param    id   path      string  true  "Template UUID"
+// @Success  204
+// @Failure  404  {object}  errorResponse
+// @Router   /api/v1/templates/{id} [delete]
+func (h *TemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		mapError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/progress"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+const (
+	// defaultWaitTimeout applies when the request gives no timeout.
+	defaultWaitTimeout = 30 * time.Second
+	// maxWaitTimeout bounds how long one request may hold a connection.
+	maxWaitTimeout = 60 * time.Second
+	// waitWriteMargin is the time left after the wait to read the
+	// notification again and write it out.
+	waitWriteMargin = 5 * time.Second
+)
+
+// WaitHandler serves the long-polling alternative to the progress stream for
+// clients that cannot read server-sent events.
+type WaitHandler struct {
+	svc     *service.NotificationService
+	waiters *progress.Waiters
+	logger  *zap.Logger
+}
+
+func NewWaitHandler(svc *service.NotificationService, waiters *progress.Waiters, logger *zap.Logger) *WaitHandler {
+	return &WaitHandler{svc: svc, waiters: waiters, logger: logger}
+}
+
+// waitResponse is the notification as it stood when the wait ended.
+type waitResponse struct {
+	*domain.Notification
+	WaitedMS int64 `json:"waited_ms"`
+}
+
+// Wait handles GET /api/v1/notifications/{id}/wait
+//
+// Blocks until the notification is settled (sent, cancelled, expired, or
+// failed with no retry left) or the timeout elapses, and returns it either
+// way; waited_ms tells how long the request was held. A settled notification
+// is returned at once.
+//
+// @Summary  Wait for a notification to settle
+// @Tags     notifications
+// @Produce  json
+// @Param    id       path      string  true   "Notification UUID"
+// @Param    timeout  query     string  false  "How long to wait, as a Go duration (default 30s, at most 60s)"
+// @Success  200      {object}  waitResponse
+// @Failure  400      {object}  errorResponse
+// @Failure  404      {object}  errorResponse
+// @Router   /api/v1/notifications/{id}/wait [get]
+func (h *WaitHandler) Wait(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	timeout, err := parseWaitTimeout(r)
+	if err != nil {
+		respondBadQuery(w, err)
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	// Subscribe before reading the notification so a transition in between
+	// still wakes us.
+	settled, cancel := h.waiters.Subscribe(id)
+	defer cancel()
+
+	n, err := h.svc.GetByID(r.Context(), id)
+	if err != nil {
+		mapError(w, err)
+		return
+	}
+	if !n.Settled() {
+		// The wait may outlast the server's write timeout; move this
+		// response's deadline past it instead.
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Now().Add(timeout + waitWriteMargin)) //nolint:errcheck
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+		case <-settled: // settled, or the server is shutting down
+		}
+
+		// Read again either way: another replica may have settled it
+		// without this process hearing about it.
+		if n, err = h.svc.GetByID(r.Context(), id); err != nil {
+			mapError(w, err)
+			return
+		}
+	}
+
+	setVersionHeaders(w, n)
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, waitResponse{Notification: n, WaitedMS: time.Since(start).Milliseconds()})
+}
+
+// parseWaitTimeout reads the timeout query parameter.
+func parseWaitTimeout(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("timeout")
+	if v == "" {
+		return defaultWaitTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 || d > maxWaitTimeout {
+		return 0, &domain.ValidationError{Fields: []domain.FieldError{{
+			Field:   "timeout",
+			Code:    "out_of_range",
+			Message: fmt.Sprintf("timeout must be a duration above 0 and at most %s", maxWaitTimeout),
+			Err:     domain.ErrInvalidFilter,
+		}}}
+	}
+	return d, nil
+}
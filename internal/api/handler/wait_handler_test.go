@@ -0,0 +1,148 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/progress"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+type waitBody struct {
+	Status   domain.Status `json:"status"`
+	WaitedMS int64         `json:"waited_ms"`
+}
+
+func newWaitRouter(t *testing.T, status domain.Status) (http.Handler, *repository.MockNotificationRepository, *progress.Waiters) {
+	t.Helper()
+	repo := repository.NewMockNotificationRepository()
+	if err := repo.Create(context.Background(), &domain.Notification{ID: "n1", Status: status}); err != nil {
+		t.Fatal(err)
+	}
+	waiters := progress.NewWaiters()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	h := handler.NewWaitHandler(svc, waiters, zap.NewNop())
+	r := chi.NewRouter()
+	r.Get("/notifications/{id}/wait", h.Wait)
+	return r, repo, waiters
+}
+
+func decodeWait(t *testing.T, rec *httptest.ResponseRecorder) waitBody {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body waitBody
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestWaitHandler_ReturnsWhenSettled(t *testing.T) {
+	r, repo, waiters := newWaitRouter(t, domain.StatusProcessing)
+
+	go func() {
+		// Wake the handler only once it is waiting.
+		for waiters.Waiting("n1") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		repo.MarkSent(context.Background(), "n1", "prov-1", time.Now()) //nolint:errcheck
+		n, _ := repo.GetByID(context.Background(), "n1")
+		waiters.Notify(n)
+	}()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/n1/wait?timeout=5s", nil))
+	body := decodeWait(t, rec)
+	if body.Status != domain.StatusSent {
+		t.Fatalf("expected status sent, got %s", body.Status)
+	}
+	if body.WaitedMS >= 5000 {
+		t.Fatalf("expected to return before the timeout, waited %dms", body.WaitedMS)
+	}
+	if waiters.Waiting("n1") != 0 {
+		t.Fatal("expected the waiter to unsubscribe")
+	}
+}
+
+func TestWaitHandler_TimesOutWithCurrentState(t *testing.T) {
+	r, _, _ := newWaitRouter(t, domain.StatusProcessing)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/n1/wait?timeout=30ms", nil))
+	body := decodeWait(t, rec)
+	if body.Status != domain.StatusProcessing {
+		t.Fatalf("expected status processing, got %s", body.Status)
+	}
+	if body.WaitedMS < 30 {
+		t.Fatalf("expected to wait out the timeout, waited %dms", body.WaitedMS)
+	}
+}
+
+func TestWaitHandler_SettledReturnsAtOnce(t *testing.T) {
+	r, _, _ := newWaitRouter(t, domain.StatusCancelled)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/n1/wait?timeout=60s", nil))
+	if body := decodeWait(t, rec); body.Status != domain.StatusCancelled || body.WaitedMS > 1000 {
+		t.Fatalf("expected an immediate cancelled response, got %+v", body)
+	}
+}
+
+func TestWaitHandler_ClientGoneStopsWaiting(t *testing.T) {
+	r, _, waiters := newWaitRouter(t, domain.StatusProcessing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/notifications/n1/wait?timeout=60s", nil).WithContext(ctx)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	for waiters.Waiting("n1") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the handler to return once the client went away")
+	}
+}
+
+func TestWaitHandler_InvalidTimeout(t *testing.T) {
+	r, _, _ := newWaitRouter(t, domain.StatusProcessing)
+
+	for _, v := range []string{"abc", "0s", "-1s", "61s"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/n1/wait?timeout="+v, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("timeout=%s: expected 400, got %d", v, rec.Code)
+		}
+		if e := decodeError(t, rec.Body); len(e.Error.Details) != 1 || e.Error.Details[0].Field != "timeout" {
+			t.Fatalf("timeout=%s: expected one timeout detail, got %+v", v, e.Error.Details)
+		}
+	}
+}
+
+func TestWaitHandler_NotFound(t *testing.T) {
+	r, _, _ := newWaitRouter(t, domain.StatusProcessing)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notifications/missing/wait?timeout=1s", nil))
+	if rec.Code != http.StatusNotFound || errorCodeOf(t, rec) != "not_found" {
+		t.Fatalf("expected 404 not_found, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// APIKeyAuth returns a middleware that requires a valid API key, supplied as
+// X-API-Key or "Authorization: Bearer <key>", and stores the key's owner ID on
+// the request context for the service layer to scope records by.
+//
+// keys maps each API key to its owner ID. Keys are held only as SHA-256
+// digests, so lookups do not compare raw secrets byte by byte.
+func APIKeyAuth(keys map[string]string) func(http.Handler) http.Handler {
+	owners := make(map[[sha256.Size]byte]string, len(keys))
+	for key, owner := range keys {
+		owners[sha256.Sum256([]byte(key))] = owner
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := requestKey(r)
+			owner, ok := owners[sha256.Sum256([]byte(key))]
+			if key == "" || !ok {
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(domain.WithOwner(r.Context(), owner)))
+		})
+	}
+}
+
+// AdminKeyAuth returns a middleware that admits only the given operator keys,
+// supplied the same way as tenant keys. No owner is set on the context: admin
+// endpoints act across all owners.
+func AdminKeyAuth(keys []string) func(http.Handler) http.Handler {
+	admins := make(map[[sha256.Size]byte]bool, len(keys))
+	for _, key := range keys {
+		admins[sha256.Sum256([]byte(key))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := requestKey(r)
+			if key == "" || !admins[sha256.Sum256([]byte(key))] {
+				unauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestKey returns the API key from X-API-Key or a bearer token.
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return key
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"error":{"code":"unauthorized","message":"missing or invalid API key"}}`)) //nolint:errcheck
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// Compress gzips responses for clients that accept it. A body is buffered
+// until it reaches minSize bytes; shorter ones go out as they are, since
+// compressing them costs more than it saves. Event streams and responses that
+// already carry a Content-Encoding (promhttp negotiates its own) are never
+// touched. Compressible responses get Vary: Accept-Encoding.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter holds the status and the first minSize bytes back until it
+// knows whether the response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	wroteHeader bool // WriteHeader called by the handler
+	decided     bool // headers sent downstream; gz or plain from here on
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+	if !cw.compressible() {
+		cw.sendPlain()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.sendGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what is buffered right away, compressed only if it is already
+// large enough.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if len(cw.buf) >= cw.minSize {
+			cw.sendGzip() //nolint:errcheck
+		} else {
+			cw.sendPlain()
+		}
+	}
+	if cw.gz != nil {
+		cw.gz.Flush() //nolint:errcheck
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush() //nolint:errcheck
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response, as far as its headers and status
+// tell, may be gzipped.
+func (cw *compressWriter) compressible() bool {
+	switch {
+	case cw.status < http.StatusOK,
+		cw.status == http.StatusNoContent,
+		cw.status == http.StatusNotModified:
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType != "text/event-stream"
+}
+
+// sendPlain forwards the headers and anything buffered uncompressed.
+func (cw *compressWriter) sendPlain() {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) > 0 {
+		cw.ResponseWriter.Write(cw.buf) //nolint:errcheck
+		cw.buf = nil
+	}
+}
+
+// sendGzip switches the response to gzip and compresses the buffer.
+func (cw *compressWriter) sendGzip() error {
+	cw.decided = true
+	h := cw.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.gz = gzipWriters.Get().(*gzip.Writer)
+	cw.gz.Reset(cw.ResponseWriter)
+	_, err := cw.gz.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.wroteHeader && len(cw.buf) == 0 {
+			return // the handler wrote nothing; let net/http send its default
+		}
+		cw.sendPlain()
+	}
+	if cw.gz != nil {
+		cw.gz.Close() //nolint:errcheck
+		gzipWriters.Put(cw.gz)
+		cw.gz = nil
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip,
+// honouring q=0 exclusions.
+func acceptsGzip(header string) bool {
+	gzipQ, starQ := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			starQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return starQ > 0
+}
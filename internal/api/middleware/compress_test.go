@@ -0,0 +1,153 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/ricirt/event-driven-arch/internal/api/middleware"
+)
+
+var largeBody = `{"data":[` + strings.Repeat(`{"channel":"sms","status":"sent"},`, 100) + `{}]}`
+
+func jsonHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body) //nolint:errcheck
+	})
+}
+
+func get(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress_GzipsLargeBodies(t *testing.T) {
+	rec := get(middleware.Compress(1024)(jsonHandler(http.StatusOK, largeBody)), "br, gzip")
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected Vary: Accept-Encoding, got %q", rec.Header().Get("Vary"))
+	}
+	if rec.Body.Len() >= len(largeBody) {
+		t.Fatalf("expected a smaller body, got %d >= %d bytes", rec.Body.Len(), len(largeBody))
+	}
+	if got := gunzip(t, rec.Body); got != largeBody {
+		t.Fatal("decompressed body differs from the original")
+	}
+}
+
+func TestCompress_SkipsSmallBodies(t *testing.T) {
+	rec := get(middleware.Compress(1024)(jsonHandler(http.StatusOK, `{"ok":true}`)), "gzip")
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Fatal("expected a small body to be sent uncompressed")
+	}
+	if rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("unexpected body %q", rec.Body)
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("expected Vary even when the body is too small to compress")
+	}
+}
+
+func TestCompress_HonoursAcceptEncoding(t *testing.T) {
+	h := middleware.Compress(0)(jsonHandler(http.StatusOK, largeBody))
+	for _, ae := range []string{"", "identity", "gzip;q=0", "*;q=0", "br, *;q=0"} {
+		if rec := get(h, ae); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != largeBody {
+			t.Fatalf("Accept-Encoding %q: expected an uncompressed body", ae)
+		}
+	}
+	for _, ae := range []string{"gzip;q=0.5", "*", "GZIP"} {
+		if rec := get(h, ae); rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Accept-Encoding %q: expected gzip", ae)
+		}
+	}
+}
+
+func TestCompress_LeavesEventStreamsAlone(t *testing.T) {
+	h := middleware.Compress(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "event: progress\ndata: {}\n\n") //nolint:errcheck
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush through the middleware failed: %v", err)
+		}
+	}))
+	rec := get(h, "gzip")
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Fatal("expected an event stream not to be compressed")
+	}
+	if !rec.Flushed || !strings.HasPrefix(rec.Body.String(), "event: progress") {
+		t.Fatalf("expected the event to be flushed as written, got %q", rec.Body)
+	}
+}
+
+func TestCompress_LeavesEncodedResponsesAlone(t *testing.T) {
+	h := middleware.Compress(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, largeBody) //nolint:errcheck
+	}))
+	if rec := get(h, "gzip"); rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != largeBody {
+		t.Fatal("expected a pre-encoded response to pass through untouched")
+	}
+}
+
+func TestCompress_WithRequestLoggerCapturesStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		body   string
+		gzip   bool
+	}{
+		{http.StatusCreated, largeBody, true},
+		{http.StatusNotFound, `{"error":{"code":"not_found","message":"notification not found"}}`, false},
+		{http.StatusNoContent, "", false},
+	} {
+		core, logs := observer.New(zapcore.InfoLevel)
+		h := middleware.RequestLogger(zap.New(core))(middleware.Compress(1024)(jsonHandler(tc.status, tc.body)))
+		rec := get(h, "gzip")
+
+		if rec.Code != tc.status {
+			t.Fatalf("expected response status %d, got %d", tc.status, rec.Code)
+		}
+		if (rec.Header().Get("Content-Encoding") == "gzip") != tc.gzip {
+			t.Fatalf("status %d: unexpected Content-Encoding %q", tc.status, rec.Header().Get("Content-Encoding"))
+		}
+		entries := logs.FilterMessage("http request").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one request log line, got %d", len(entries))
+		}
+		if got := entries[0].ContextMap()["status"]; got != int64(tc.status) {
+			t.Fatalf("expected logged status %d, got %v", tc.status, got)
+		}
+	}
+}
@@ -5,11 +5,9 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
-)
-
-type contextKey string
 
-const correlationIDKey contextKey = "correlation_id"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
 
 // CorrelationID reads the X-Correlation-ID header from the incoming request.
 // If absent, a new UUID is generated. The value is stored on the request
@@ -21,7 +19,7 @@ func CorrelationID(next http.Handler) http.Handler {
 		if id == "" {
 			id = uuid.New().String()
 		}
-		ctx := context.WithValue(r.Context(), correlationIDKey, id)
+		ctx := domain.WithCorrelationID(r.Context(), id)
 		w.Header().Set("X-Correlation-ID", id)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -30,6 +28,5 @@ func CorrelationID(next http.Handler) http.Handler {
 // GetCorrelationID retrieves the correlation ID stored by the middleware.
 // Returns an empty string if the middleware was not applied.
 func GetCorrelationID(ctx context.Context) string {
-	v, _ := ctx.Value(correlationIDKey).(string)
-	return v
+	return domain.CorrelationIDFromContext(ctx)
 }
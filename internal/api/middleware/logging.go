@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +20,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach Flush and SetWriteDeadline on
+// the underlying writer, which streaming handlers need.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // RequestLogger returns a middleware that emits a structured zap log line
 // for every completed HTTP request, including the correlation ID.
 func RequestLogger(logger *zap.Logger) func(http.Handler) http.Handler {
@@ -29,14 +36,19 @@ func RequestLogger(logger *zap.Logger) func(http.Handler) http.Handler {
 
 			next.ServeHTTP(wrapped, r)
 
-			logger.Info("http request",
+			fields := []zap.Field{
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.Int("status", wrapped.status),
 				zap.Duration("latency", time.Since(start)),
 				zap.String("correlation_id", GetCorrelationID(r.Context())),
 				zap.String("remote_addr", r.RemoteAddr),
-			)
+			}
+			// Lets a log line be looked up in the tracing backend.
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+			}
+			logger.Info("http request", fields...)
 		})
 	}
 }
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ClientRateLimiter throttles HTTP requests with one token bucket per client.
+// A client is its API key when the request carries one, otherwise its IP
+// (run chi's RealIP first so proxies are seen through).
+//
+// At most maxClients buckets are kept; the least recently used one is evicted
+// to make room, so a flood of distinct clients cannot grow memory without
+// bound. An evicted client simply starts again with a full bucket.
+type ClientRateLimiter struct {
+	limit      rate.Limit
+	burst      int
+	maxClients int
+
+	mu      sync.Mutex
+	clients map[string]*list.Element // client key → element holding *clientBucket
+	lru     *list.List               // front = most recently used
+}
+
+type clientBucket struct {
+	key     string
+	limiter *rate.Limiter
+}
+
+// NewClientRateLimiter allows each client perSecond requests per second on
+// average, with bursts of up to burst requests.
+func NewClientRateLimiter(perSecond float64, burst, maxClients int) *ClientRateLimiter {
+	return &ClientRateLimiter{
+		limit:      rate.Limit(perSecond),
+		burst:      burst,
+		maxClients: max(maxClients, 1),
+		clients:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Handler wraps next, answering 429 once the client's bucket is empty. Every
+// response carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// (seconds until the bucket is full again); 429s also carry Retry-After.
+func (l *ClientRateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		lim := l.bucket(clientKey(r))
+		allowed := lim.AllowN(now, 1)
+		tokens := lim.TokensAt(now)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(l.burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(max(int(tokens), 0)))
+		h.Set("RateLimit-Reset", strconv.Itoa(l.secondsUntil(float64(l.burst)-tokens)))
+
+		if !allowed {
+			h.Set("Retry-After", strconv.Itoa(max(l.secondsUntil(1-tokens), 1)))
+			h.Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"code":"rate_limited","message":"too many requests"}}`)) //nolint:errcheck
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secondsUntil is how long, rounded up, the bucket takes to refill n tokens.
+func (l *ClientRateLimiter) secondsUntil(n float64) int {
+	if n <= 0 || l.limit <= 0 {
+		return 0
+	}
+	return int(math.Ceil(n / float64(l.limit)))
+}
+
+// bucket returns the client's limiter, creating it and evicting the least
+// recently used one as needed.
+func (l *ClientRateLimiter) bucket(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.clients[key]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*clientBucket).limiter
+	}
+	for l.lru.Len() >= l.maxClients {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.clients, oldest.Value.(*clientBucket).key)
+	}
+	b := &clientBucket{key: key, limiter: rate.NewLimiter(l.limit, l.burst)}
+	l.clients[key] = l.lru.PushFront(b)
+	return b.limiter
+}
+
+// clientKey identifies the caller by API key (hashed, so raw secrets are not
+// kept in memory), falling back to the remote IP.
+func clientKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + string(sum[:])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr // RealIP stores the bare address
+	}
+	return "ip:" + host
+}
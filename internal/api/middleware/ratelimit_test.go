@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/api/middleware"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func request(h http.Handler, apiKey, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications", nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestClientRateLimiter_RejectsOverBurst(t *testing.T) {
+	h := middleware.NewClientRateLimiter(1, 3, 100).Handler(okHandler)
+
+	for i := 0; i < 3; i++ {
+		rec := request(h, "k", "10.0.0.1:1234")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+		if got, want := rec.Header().Get("RateLimit-Remaining"), fmt.Sprint(2-i); got != want {
+			t.Fatalf("request %d: expected RateLimit-Remaining %s, got %s", i, want, got)
+		}
+	}
+
+	rec := request(h, "k", "10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "3" || rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Reset") == "" {
+		t.Fatalf("missing rate limit headers: %v", rec.Header())
+	}
+}
+
+func TestClientRateLimiter_ClientsAreIsolated(t *testing.T) {
+	const burst = 20
+	h := middleware.NewClientRateLimiter(0.001, burst, 100).Handler(okHandler)
+
+	// A noisy client fires far more requests than its burst concurrently.
+	var noisyOK, quietOK atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if request(h, "noisy", "10.0.0.1:1").Code == http.StatusOK {
+				noisyOK.Add(1)
+			}
+		}()
+	}
+	// Meanwhile quiet clients, by key and by IP, stay within their own burst.
+	for i := 0; i < burst; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if request(h, "quiet", "10.0.0.1:1").Code == http.StatusOK {
+				quietOK.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if request(h, "", "10.0.0.2:1").Code == http.StatusOK {
+				quietOK.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := noisyOK.Load(); n != burst {
+		t.Fatalf("expected the noisy client to get exactly %d requests through, got %d", burst, n)
+	}
+	if n := quietOK.Load(); n != 2*burst {
+		t.Fatalf("expected every quiet request to pass, got %d of %d", n, 2*burst)
+	}
+}
+
+func TestClientRateLimiter_EvictsLeastRecentlyUsed(t *testing.T) {
+	h := middleware.NewClientRateLimiter(0.001, 1, 2).Handler(okHandler)
+
+	request(h, "a", "")
+	request(h, "b", "")
+	if rec := request(h, "a", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a to be throttled, got %d", rec.Code)
+	}
+	// c evicts b, the least recently used bucket; a keeps its empty bucket.
+	request(h, "c", "")
+	if rec := request(h, "a", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a to still be throttled, got %d", rec.Code)
+	}
+	if rec := request(h, "b", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected evicted client b to start with a fresh bucket, got %d", rec.Code)
+	}
+}
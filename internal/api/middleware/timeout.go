@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Timeout bounds each request's context to d. Handlers pass the context down
+// to the database, so a slow query returns early with a context error, which
+// handlers answer with a 5xx. If the deadline has passed by the time the
+// handler starts such a response, or the handler returns without writing one,
+// the client gets a 504 JSON error instead; headers the handler set are
+// dropped with it. A success or client error is kept even when late: the
+// handler finished, and a create that committed must not look failed to a
+// client that would then send it again. The handler runs on the request
+// goroutine, so nothing is written concurrently; d <= 0 disables the timeout.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, ctx: ctx, before: w.Header().Clone()}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			if !tw.wroteHeader && tw.expired() {
+				tw.gatewayTimeout()
+			}
+		})
+	}
+}
+
+// timeoutWriter checks the deadline when the handler starts an error response.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	before http.Header // headers set by outer middleware, kept on a 504
+
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	if code >= http.StatusInternalServerError && tw.expired() {
+		tw.gatewayTimeout()
+		return
+	}
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// Write discards the handler's body once the 504 has been sent.
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		return len(p), nil
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (tw *timeoutWriter) expired() bool {
+	return errors.Is(tw.ctx.Err(), context.DeadlineExceeded)
+}
+
+func (tw *timeoutWriter) gatewayTimeout() {
+	tw.wroteHeader, tw.timedOut = true, true
+	h := tw.Header()
+	clear(h)
+	for k, v := range tw.before {
+		h[k] = v
+	}
+	h.Set("Content-Type", "application/json")
+	tw.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	tw.ResponseWriter.Write([]byte(`{"error":{"code":"timeout","message":"request timed out"}}`)) //nolint:errcheck
+}
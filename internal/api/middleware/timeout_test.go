@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/ricirt/event-driven-arch/internal/api/middleware"
+)
+
+// slowHandler waits for the request context to end, as a handler blocked on a
+// slow query would, then answers as if the query had failed.
+var slowHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+	w.Header().Set("ETag", `"stale"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(`{"error":{"code":"internal_error","message":"failed to list notifications"}}`)) //nolint:errcheck
+})
+
+func TestTimeout_SlowHandlerGets504(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	outer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Correlation-ID", "c1")
+		middleware.Timeout(20*time.Millisecond)(slowHandler).ServeHTTP(w, r)
+	})
+	rec := get(middleware.RequestLogger(zap.New(core))(outer), "")
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); body != `{"error":{"code":"timeout","message":"request timed out"}}` {
+		t.Fatalf("expected only the timeout error in the body, got %s", body)
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("X-Correlation-ID") != "c1" {
+		t.Fatalf("expected the handler's headers dropped and outer ones kept, got %v", rec.Header())
+	}
+	if got := logs.FilterMessage("http request").All()[0].ContextMap()["status"]; got != int64(http.StatusGatewayTimeout) {
+		t.Fatalf("expected logged status 504, got %v", got)
+	}
+}
+
+func TestTimeout_SilentSlowHandlerGets504(t *testing.T) {
+	silent := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() })
+	rec := get(middleware.Timeout(20*time.Millisecond)(silent), "")
+
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), `"timeout"`) {
+		t.Fatalf("expected 504, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTimeout_ResponseStartedInTimeIsKept(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		<-r.Context().Done()
+		w.Write([]byte("late")) //nolint:errcheck
+	})
+	rec := get(middleware.Timeout(20*time.Millisecond)(h), "")
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "late" {
+		t.Fatalf("expected the started response to finish untouched, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTimeout_SuccessJustPastDeadlineIsKept(t *testing.T) {
+	// A create whose insert committed just after the deadline.
+	slowCreate := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"n-1"}`)) //nolint:errcheck
+	})
+	rec := get(middleware.Timeout(20*time.Millisecond)(slowCreate), "")
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":"n-1"}` {
+		t.Fatalf("expected the created response kept, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTimeout_FastHandlerAndDisabled(t *testing.T) {
+	for _, d := range []time.Duration{time.Second, 0} {
+		rec := get(middleware.Timeout(d)(jsonHandler(http.StatusCreated, `{}`)), "")
+		if rec.Code != http.StatusCreated || rec.Body.String() != `{}` {
+			t.Fatalf("timeout %s: expected the handler's response, got %d %s", d, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	apimw "github.com/ricirt/event-driven-arch/internal/api/middleware"
+)
+
+func tracedRouter(t *testing.T) (http.Handler, *tracetest.SpanRecorder) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	r := chi.NewRouter()
+	r.Use(apimw.Tracing)
+	r.Get("/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Error("expected the handler context to carry the server span")
+		}
+		if chi.URLParam(r, "id") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return r, rec
+}
+
+func TestTracing_ContinuesIncomingTrace(t *testing.T) {
+	h, rec := tracedRouter(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/things/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /things/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("unexpected span %q (%s)", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatal("expected the span to continue the caller's trace")
+	}
+	want := map[attribute.Key]attribute.Value{
+		"http.route":                attribute.StringValue("/things/{id}"),
+		"http.response.status_code": attribute.IntValue(http.StatusNoContent),
+	}
+	for _, kv := range span.Attributes() {
+		if v, ok := want[kv.Key]; ok {
+			if kv.Value != v {
+				t.Errorf("%s: expected %v, got %v", kv.Key, v.Emit(), kv.Value.Emit())
+			}
+			delete(want, kv.Key)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("missing attributes: %v", want)
+	}
+}
+
+func TestTracing_ServerErrorMarksSpan(t *testing.T) {
+	h, rec := tracedRouter(t)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/broken", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("expected one failed span, got %+v", spans)
+	}
+	if spans[0].Parent().IsValid() {
+		t.Fatal("expected a new root span without an incoming traceparent")
+	}
+}
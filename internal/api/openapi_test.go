@@ -0,0 +1,120 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/docs"
+	"github.com/ricirt/event-driven-arch/internal/api"
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	apimw "github.com/ricirt/event-driven-arch/internal/api/middleware"
+	"github.com/ricirt/event-driven-arch/internal/audit"
+	"github.com/ricirt/event-driven-arch/internal/progress"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+type pingOK struct{}
+
+func (pingOK) Ping(context.Context) error { return nil }
+
+// newFullRouter registers every optional route.
+func newFullRouter(t *testing.T) http.Handler {
+	t.Helper()
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	swagger, err := handler.NewSwaggerHandler(docs.SwaggerYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+		Ready:     handler.NewReadinessHandler(pingOK{}, q, nil, nil, 90, time.Second),
+		Limiter:   apimw.NewClientRateLimiter(100, 100, 10),
+		Hub:       progress.NewHub(),
+		Waiters:   progress.NewWaiters(),
+		Swagger:   swagger,
+		Reloader:  staticReloader{},
+		AuditLog:  audit.NewLog(repository.NewMockAuditRepository(), 10, time.Second, nil, zap.NewNop()),
+		Pprof:     true,
+		AdminKeys: []string{"admin-secret"},
+	})
+}
+
+func TestRouter_ServesOpenAPISpecCoveringEveryRoute(t *testing.T) {
+	h := newFullRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if spec.OpenAPI == "" || len(spec.Paths) == 0 {
+		t.Fatal("expected an OpenAPI document with paths")
+	}
+
+	// Every registered route must be documented; the docs themselves and the
+	// profiler are exempt.
+	routes := map[string][]string{}
+	err := chi.Walk(h.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/swagger") && !strings.HasPrefix(route, "/debug") {
+			routes[route] = append(routes[route], method)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for route, methods := range routes {
+		// r.Handle mounts answer every method; only GET is meaningful there.
+		if len(methods) >= 9 {
+			methods = []string{http.MethodGet}
+		}
+		for _, method := range methods {
+			if _, ok := spec.Paths[route][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is not documented in docs/swagger.yaml", method, route)
+			}
+		}
+	}
+}
+
+func TestRouter_ServesSwaggerUI(t *testing.T) {
+	h := newFullRouter(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "doc.json") {
+		t.Fatalf("expected the UI page pointing at doc.json, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/swagger/" {
+		t.Fatalf("expected a redirect to /swagger/, got %d", rec.Code)
+	}
+}
+
+func TestRouter_SwaggerDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newAuthRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a swagger handler, got %d", rec.Code)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
@@ -11,53 +12,218 @@ import (
 
 	"github.com/ricirt/event-driven-arch/internal/api/handler"
 	apimw "github.com/ricirt/event-driven-arch/internal/api/middleware"
+	"github.com/ricirt/event-driven-arch/internal/audit"
+	"github.com/ricirt/event-driven-arch/internal/progress"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/service"
 )
 
+// RouterOptions configures NewRouter. Every field is optional: routes whose
+// handler or source is nil are left out, and zero limits fall back to the
+// configuration defaults.
+type RouterOptions struct {
+	// Stats feeds the worker, throughput and rate limiter sections of
+	// GET /api/v1/metrics; sections whose source is nil are left out.
+	Stats handler.MetricsSources
+	// Ready serves /ready.
+	Ready *handler.ReadinessHandler
+	// Limiter throttles every /api/v1 request per client; the probes and
+	// /metrics are exempt.
+	Limiter *apimw.ClientRateLimiter
+	// Hub feeds GET /api/v1/batches/{id}/progress. Waiters likewise feeds,
+	// and gates, the long-polling GET /api/v1/notifications/{id}/wait.
+	Hub     *progress.Hub
+	Waiters *progress.Waiters
+	// Swagger serves the OpenAPI document at /swagger/doc.json and a UI at
+	// /swagger/, outside authentication like the probes.
+	Swagger *handler.SwaggerHandler
+	// Reloader serves POST /api/v1/admin/reload.
+	Reloader handler.Reloader
+	// AuditLog records every mutating request to /api/v1 and /api/v1/admin,
+	// and serves GET /api/v1/admin/audit.
+	AuditLog *audit.Log
+	// Pprof mounts the runtime profiles under /debug/pprof, for admin keys
+	// only; see NewDebugHandler for serving them on a separate port instead.
+	Pprof bool
+
+	// Responses of at least CompressMinSize bytes are gzipped for clients
+	// that accept it; a negative CompressMinSize disables compression.
+	CompressMinSize int
+	// MaxPageSize is the largest limit the list endpoints accept (default 100).
+	MaxPageSize int
+	// Request bodies are capped at MaxBodyBytes (default 1 MiB), except on
+	// the batch endpoint, which allows MaxBatchBodyBytes (default 10 MiB);
+	// larger bodies get 413.
+	MaxBodyBytes      int64
+	MaxBatchBodyBytes int64
+	// API handlers that have not started responding within HandlerTimeout
+	// (BatchHandlerTimeout for batch creation) answer 504; the progress
+	// stream and the wait endpoint are exempt. Zero disables it.
+	HandlerTimeout      time.Duration
+	BatchHandlerTimeout time.Duration
+
+	// APIKeys maps API keys to owner IDs; when non-empty every /api/v1 route
+	// requires a key.
+	APIKeys map[string]string
+	// AdminKeys unlock /api/v1/admin, which acts across all owners and is not
+	// registered at all when AdminKeys is empty. Tenant keys are not accepted
+	// there.
+	AdminKeys []string
+}
+
+func (o RouterOptions) withDefaults() RouterOptions {
+	if o.MaxPageSize <= 0 {
+		o.MaxPageSize = 100
+	}
+	if o.MaxBodyBytes <= 0 {
+		o.MaxBodyBytes = 1 << 20
+	}
+	if o.MaxBatchBodyBytes <= 0 {
+		o.MaxBatchBodyBytes = 10 << 20
+	}
+	return o
+}
+
 // NewRouter wires the chi router, attaches all middleware, and registers
 // every route. It is the single source of truth for the HTTP surface area.
+// /health, /version, /ready and /metrics never require a key.
 func NewRouter(
 	svc *service.NotificationService,
+	templates *service.TemplateService,
 	q *queue.PriorityQueue,
 	reg prometheus.Gatherer,
 	logger *zap.Logger,
+	opts RouterOptions,
 ) http.Handler {
+	opts = opts.withDefaults()
 	r := chi.NewRouter()
+	bodyCap := max(opts.MaxBodyBytes, opts.MaxBatchBodyBytes)
 
 	// --- global middleware (applied to every route) ---
-	r.Use(chimw.Recoverer)         // recover panics, return 500
-	r.Use(chimw.RealIP)            // trust X-Forwarded-For / X-Real-IP
-	r.Use(chimw.RequestSize(1<<20)) // 1 MB max request body
-	r.Use(apimw.CorrelationID)     // X-Correlation-ID inject / echo
+	r.Use(chimw.Recoverer)            // recover panics, return 500
+	r.Use(chimw.RealIP)               // trust X-Forwarded-For / X-Real-IP
+	r.Use(chimw.RequestSize(bodyCap)) // largest body limit; tightened per route
+	r.Use(apimw.CorrelationID)        // X-Correlation-ID inject / echo
+	r.Use(apimw.Tracing)              // server span, continuing an incoming traceparent
 	r.Use(apimw.RequestLogger(logger))
+	if opts.CompressMinSize >= 0 {
+		r.Use(apimw.Compress(opts.CompressMinSize)) // inside the logger, which still sees the status
+	}
 
 	// --- handler instances ---
-	nh := handler.NewNotificationHandler(svc, logger)
-	bh := handler.NewBatchHandler(svc, logger)
-	mh := handler.NewMetricsHandler(q)
-	hh := handler.NewHealthHandler()
+	nh := handler.NewNotificationHandler(svc, opts.MaxPageSize, logger)
+	bh := handler.NewBatchHandler(svc, opts.Hub, logger)
+	th := handler.NewTemplateHandler(templates, logger)
+	mh := handler.NewMetricsHandler(q, opts.Stats)
+	hh := handler.NewHealthHandler(svc.DefaultMaxRetries())
+	ah := handler.NewAdminHandler(svc, opts.Reloader, opts.AuditLog, opts.MaxPageSize, logger)
+	wh := handler.NewWaitHandler(svc, opts.Waiters, logger)
+
+	// audited records the route it wraps under action; a no-op without an audit log.
+	audited := func(action string) func(http.Handler) http.Handler {
+		return apimw.Audit(opts.AuditLog, action)
+	}
 
 	// --- routes ---
 	r.Get("/health", hh.Health)
+	r.Get("/version", hh.Version)
+	if opts.Ready != nil {
+		r.Get("/ready", opts.Ready.Ready)
+	}
+
+	if opts.Swagger != nil {
+		r.Get("/swagger/doc.json", opts.Swagger.Spec)
+		r.Get("/swagger/", opts.Swagger.UI)
+		r.Get("/swagger", http.RedirectHandler("/swagger/", http.StatusMovedPermanently).ServeHTTP)
+	}
 
 	// Raw Prometheus scrape endpoint (for Prometheus server / Grafana)
 	r.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 
+	// Profiles expose internals and cost CPU while they run.
+	if opts.Pprof {
+		r.Route("/debug", func(r chi.Router) {
+			r.Use(apimw.AdminKeyAuth(opts.AdminKeys))
+			r.Mount("/", chimw.Profiler())
+		})
+	}
+
+	// Operator endpoints sit outside the tenant route group so tenant keys
+	// never reach them.
+	if len(opts.AdminKeys) > 0 {
+		r.Route("/api/v1/admin", func(r chi.Router) {
+			if opts.Limiter != nil {
+				r.Use(opts.Limiter.Handler)
+			}
+			r.Use(apimw.AdminKeyAuth(opts.AdminKeys))
+			r.Use(chimw.RequestSize(opts.MaxBodyBytes))
+			r.Use(apimw.Timeout(opts.HandlerTimeout))
+			r.With(audited("notification.requeue_pending")).Post("/requeue-pending", ah.RequeuePending)
+			r.Get("/dead-letters", ah.ListDeadLetters)
+			r.With(audited("notification.requeue_dead_letters")).Post("/dead-letters/requeue", ah.RequeueDeadLetters)
+			r.Get("/dead-letters/{id}", ah.GetDeadLetter)
+			r.With(audited("notification.requeue_dead_letter")).Post("/dead-letters/{id}/requeue", ah.RequeueDeadLetter)
+			if opts.Reloader != nil {
+				r.With(audited("config.reload")).Post("/reload", ah.Reload)
+			}
+			if opts.AuditLog != nil {
+				r.Get("/audit", ah.ListAudit)
+			}
+		})
+	}
+
 	r.Route("/api/v1", func(r chi.Router) {
+		// Throttle before authenticating so floods of bad keys are cheap too.
+		if opts.Limiter != nil {
+			r.Use(opts.Limiter.Handler)
+		}
+		if len(opts.APIKeys) > 0 {
+			r.Use(apimw.APIKeyAuth(opts.APIKeys))
+		}
+
 		// Notifications — note: /batch must be registered before /{id}
-		// so chi does not treat the literal string "batch" as an ID.
-		r.Post("/notifications/batch", bh.CreateBatch)
-		r.Post("/notifications", nh.Create)
-		r.Get("/notifications", nh.List)
-		r.Get("/notifications/{id}", nh.GetByID)
-		r.Delete("/notifications/{id}", nh.Cancel)
-
-		// Batches
-		r.Get("/batches/{id}", bh.GetBatch)
-
-		// JSON metrics snapshot
-		r.Get("/metrics", mh.GetMetrics)
+		// so chi does not treat the literal string "batch" as an ID. It is
+		// the only route allowed the larger batch body limit and timeout.
+		r.With(apimw.Timeout(opts.BatchHandlerTimeout), audited("batch.create")).Post("/notifications/batch", bh.CreateBatch)
+
+		// The progress stream stays open for as long as the batch runs.
+		if opts.Hub != nil {
+			r.Get("/batches/{id}/progress", bh.Progress)
+		}
+		// So does a wait, up to the timeout the client asks for.
+		if opts.Waiters != nil {
+			r.Get("/notifications/{id}/wait", wh.Wait)
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(chimw.RequestSize(opts.MaxBodyBytes))
+			r.Use(apimw.Timeout(opts.HandlerTimeout))
+			r.With(audited("notification.create")).Post("/notifications", nh.Create)
+			r.Get("/notifications", nh.List)
+			r.Post("/notifications/lookup", nh.Lookup)
+			r.Get("/notifications/{id}", nh.GetByID)
+			r.Get("/notifications/by-provider-id/{id}", nh.GetByProviderMsgID)
+			r.With(audited("notification.update")).Patch("/notifications/{id}", nh.Update)
+			r.With(audited("notification.cancel")).Delete("/notifications/{id}", nh.Cancel)
+			r.With(audited("notification.submit")).Post("/notifications/{id}/submit", nh.Submit)
+			r.With(audited("notification.resend")).Post("/notifications/{id}/resend", nh.Resend)
+			r.With(audited("notification.retry")).Post("/notifications/{id}/retry", nh.Retry)
+			r.With(audited("notification.priority")).Post("/notifications/{id}/priority", nh.ChangePriority)
+
+			// Batches
+			r.Get("/batches/{id}", bh.GetBatch)
+			r.Get("/batches/{id}/summary", bh.Summary)
+
+			// Content templates
+			r.With(audited("template.create")).Post("/templates", th.Create)
+			r.Get("/templates", th.List)
+			r.Get("/templates/{id}", th.GetByID)
+			r.With(audited("template.update")).Put("/templates/{id}", th.Update)
+			r.With(audited("template.delete")).Delete("/templates/{id}", th.Delete)
+
+			// JSON metrics snapshot
+			r.Get("/metrics", mh.GetMetrics)
+		})
 	})
 
 	return r
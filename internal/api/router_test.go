@@ -0,0 +1,516 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/api"
+	"github.com/ricirt/event-driven-arch/internal/api/handler"
+	apimw "github.com/ricirt/event-driven-arch/internal/api/middleware"
+	"github.com/ricirt/event-driven-arch/internal/audit"
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/metrics"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/service"
+)
+
+func newAuthRouter() http.Handler {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	keys := map[string]string{"alice-secret": "alice", "bob-secret": "bob"}
+	return api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{APIKeys: keys})
+}
+
+func do(h http.Handler, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouter_APIKeyRequired(t *testing.T) {
+	h := newAuthRouter()
+
+	if rec := do(h, http.MethodGet, "/api/v1/notifications", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/notifications", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unknown key, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/notifications", nil)
+	req.Header.Set("Authorization", "Bearer alice-secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a bearer key, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/health", "/version", "/metrics"} {
+		if rec := do(h, http.MethodGet, path, "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to stay unauthenticated, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestRouter_VersionShowsDefaults(t *testing.T) {
+	q := queue.New()
+	seven := 7
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{DefaultMaxRetries: &seven})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{})
+
+	rec := do(h, http.MethodGet, "/version", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"defaults":{"max_retries":7}`) {
+		t.Fatalf("expected the effective default max_retries, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRouter_CrossTenantAccessIsNotFound(t *testing.T) {
+	h := newAuthRouter()
+
+	rec := do(h, http.MethodPost, "/api/v1/notifications", "alice-secret",
+		`{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	id := extractID(t, rec)
+
+	if rec := do(h, http.MethodGet, "/api/v1/notifications/"+id, "alice-secret", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the owner to read it, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/notifications/"+id, "bob-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodDelete, "/api/v1/notifications/"+id, "bob-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 on cross-tenant cancel, got %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodGet, "/api/v1/notifications", "bob-secret", "")
+	if !strings.Contains(rec.Body.String(), `"total":0`) {
+		t.Fatalf("expected bob's list to be empty, got %s", rec.Body)
+	}
+}
+
+func TestRouter_AuditsMutations(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	auditRepo := repository.NewMockAuditRepository()
+	auditLog := audit.NewLog(auditRepo, 100, time.Second, nil, zap.NewNop())
+	keys := map[string]string{"alice-secret": "alice", "bob-secret": "bob"}
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+		AuditLog:  auditLog,
+		APIKeys:   keys,
+		AdminKeys: []string{"ops-secret"},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications",
+		strings.NewReader(`{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "alice-secret")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Correlation-ID", "corr-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	id := extractID(t, rec)
+
+	do(h, http.MethodGet, "/api/v1/notifications/"+id, "alice-secret", "") // reads are not audited
+	if rec := do(h, http.MethodDelete, "/api/v1/notifications/"+id, "bob-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant's cancel, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodDelete, "/api/v1/notifications/"+id, "alice-secret", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 for the cancel, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	auditLog.Run(ctx) // flushes what the requests recorded
+
+	entries := auditRepo.All()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d", len(entries))
+	}
+	created := entries[0]
+	if created.Action != "notification.create" || created.Status != http.StatusCreated ||
+		deref(created.TargetType) != "notification" || deref(created.TargetID) != id ||
+		deref(created.OwnerID) != "alice" || created.ClientIP != "203.0.113.7" ||
+		deref(created.CorrelationID) != "corr-1" {
+		t.Fatalf("unexpected create entry %+v", created)
+	}
+	if k := deref(created.KeyID); k == "" || strings.Contains(k, "alice-secret") {
+		t.Fatalf("expected a key fingerprint, got %q", k)
+	}
+	if denied := entries[1]; denied.Action != "notification.cancel" || denied.Status != http.StatusNotFound ||
+		deref(denied.OwnerID) != "bob" || deref(denied.TargetID) != id || deref(denied.KeyID) == deref(created.KeyID) {
+		t.Fatalf("expected bob's refused cancel to be recorded, got %+v", denied)
+	}
+
+	rec = do(h, http.MethodGet, "/api/v1/admin/audit?owner_id=alice&target_id="+id, "ops-secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":2`) {
+		t.Fatalf("expected alice's 2 entries, got %d %s", rec.Code, rec.Body)
+	}
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if rec := do(h, http.MethodGet, "/api/v1/admin/audit?from="+future, "ops-secret", ""); !strings.Contains(rec.Body.String(), `"total":0`) {
+		t.Fatalf("expected no entries from the future, got %s", rec.Body)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/admin/audit?from=yesterday", "ops-secret", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad timestamp, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/admin/audit", "alice-secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tenant keys to be refused, got %d", rec.Code)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func TestRouter_NotificationKeepsCorrelationID(t *testing.T) {
+	h := newAuthRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notifications",
+		strings.NewReader(`{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "alice-secret")
+	req.Header.Set("X-Correlation-ID", "corr-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"correlation_id":"corr-1"`) {
+		t.Fatalf("expected 201 quoting the correlation ID, got %d %s", rec.Code, rec.Body)
+	}
+	id := extractID(t, rec)
+
+	// A later request has its own ID; the notification keeps the creating one.
+	rec = do(h, http.MethodGet, "/api/v1/notifications/"+id, "alice-secret", "")
+	if !strings.Contains(rec.Body.String(), `"correlation_id":"corr-1"`) {
+		t.Fatalf("expected the stored correlation ID, got %s", rec.Body)
+	}
+}
+
+func extractID(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.ID == "" {
+		t.Fatalf("no id in response: %v", err)
+	}
+	return body.ID
+}
+
+func TestRouter_RateLimitExemptsProbes(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	limiter := apimw.NewClientRateLimiter(0.001, 1, 10)
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{Limiter: limiter})
+
+	if rec := do(h, http.MethodGet, "/api/v1/notifications", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected first API request to pass, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/notifications", "", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second API request to be throttled, got %d", rec.Code)
+	}
+	for _, path := range []string{"/health", "/metrics"} {
+		if rec := do(h, http.MethodGet, path, "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected %s to be exempt, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestRouter_AdminRoutesNeedAdminKey(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	keys := map[string]string{"alice-secret": "alice"}
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+		APIKeys:   keys,
+		AdminKeys: []string{"ops-secret"},
+	})
+
+	const path = "/api/v1/admin/requeue-pending"
+	if rec := do(h, http.MethodPost, path, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodPost, path, "alice-secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a tenant key, got %d", rec.Code)
+	}
+	rec := do(h, http.MethodPost, path, "ops-secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"requeued":0`) {
+		t.Fatalf("expected 200 for the admin key, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/notifications", "ops-secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the admin key to be refused on tenant routes, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodPost, path+"?older_than=soon", "ops-secret", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad duration, got %d", rec.Code)
+	}
+
+	// Without admin keys the route does not exist.
+	if rec := do(newAuthRouter(), http.MethodPost, path, "alice-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 with admin endpoints disabled, got %d", rec.Code)
+	}
+}
+
+func TestRouter_Pprof(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	keys := map[string]string{"alice-secret": "alice"}
+	newRouter := func(pprof bool) http.Handler {
+		return api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+			Pprof:     pprof,
+			APIKeys:   keys,
+			AdminKeys: []string{"ops-secret"},
+		})
+	}
+
+	const path = "/debug/pprof/"
+	if rec := do(newRouter(false), http.MethodGet, path, "ops-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 with pprof disabled, got %d", rec.Code)
+	}
+	h := newRouter(true)
+	if rec := do(h, http.MethodGet, path, "alice-secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a tenant key, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodGet, path, "ops-secret", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the admin key, got %d", rec.Code)
+	}
+}
+
+func TestDebugHandler_ServesProfiles(t *testing.T) {
+	rec := do(api.NewDebugHandler(), http.MethodGet, "/debug/pprof/goroutine?debug=1", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Fatalf("expected a goroutine profile, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRouter_AdminDeadLetters(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{AdminKeys: []string{"ops-secret"}})
+
+	ctx := context.Background()
+	for _, id := range []string{"dead", "live"} {
+		n := domain.Notification{ID: id, Channel: domain.ChannelSMS, Status: domain.StatusQueued,
+			Priority: domain.PriorityNormal, MaxRetries: 3}
+		if err := repo.Create(ctx, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = repo.MarkFailed(ctx, "dead", "rejected")
+
+	rec := do(h, http.MethodGet, "/api/v1/admin/dead-letters?channel=sms", "ops-secret", "")
+	var page struct {
+		Data []struct {
+			ID           string `json:"id"`
+			AttemptCount int    `json:"attempt_count"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &page) != nil {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	if page.Total != 1 || page.Data[0].ID != "dead" || page.Data[0].AttemptCount != 1 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/admin/dead-letters?from=yesterday", "ops-secret", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad timestamp, got %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodGet, "/api/v1/admin/dead-letters/dead", "ops-secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"error":"rejected"`) {
+		t.Fatalf("expected the attempt history, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodGet, "/api/v1/admin/dead-letters/live", "ops-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a notification that is not dead-lettered, got %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodPost, "/api/v1/admin/dead-letters/requeue", "ops-secret", `{"ids":["dead","live"]}`)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"requeued":["dead"],"deferred":[],"not_found":["live"]}`+"\n" {
+		t.Fatalf("unexpected bulk requeue response %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodPost, "/api/v1/admin/dead-letters/requeue", "ops-secret", `{"ids":[]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an empty id list, got %d", rec.Code)
+	}
+	if rec := do(h, http.MethodPost, "/api/v1/admin/dead-letters/dead/requeue", "ops-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 once requeued, got %d", rec.Code)
+	}
+}
+
+// padJSON pads a JSON document with trailing whitespace to exactly size bytes.
+func padJSON(doc string, size int) string {
+	return doc + strings.Repeat(" ", size-len(doc))
+}
+
+// staticReloader reloads to the same result every time.
+type staticReloader struct {
+	result config.ReloadResult
+	err    error
+}
+
+func (s staticReloader) Reload() (config.ReloadResult, error) { return s.result, s.err }
+
+func TestRouter_AdminReload(t *testing.T) {
+	newRouter := func(r handler.Reloader) http.Handler {
+		q := queue.New()
+		svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+		templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+		return api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+			Reloader:  r,
+			AdminKeys: []string{"ops-secret"},
+		})
+	}
+	const path = "/api/v1/admin/reload"
+
+	h := newRouter(staticReloader{result: config.ReloadResult{Applied: []string{"LOG_LEVEL"}, Ignored: []string{"HTTP_PORT"}}})
+	if rec := do(h, http.MethodPost, path, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", rec.Code)
+	}
+	rec := do(h, http.MethodPost, path, "ops-secret", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"applied":["LOG_LEVEL"],"ignored":["HTTP_PORT"]`) {
+		t.Fatalf("expected the reload result, got %d %s", rec.Code, rec.Body)
+	}
+
+	h = newRouter(staticReloader{err: errors.New("SMS_WORKERS must be a positive integer, got 0")})
+	rec = do(h, http.MethodPost, path, "ops-secret", "")
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "SMS_WORKERS") {
+		t.Fatalf("expected 422 naming the problem, got %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(newRouter(nil), http.MethodPost, path, "ops-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a reloader, got %d", rec.Code)
+	}
+}
+
+func TestRouter_BodySizeLimits(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+		MaxBodyBytes:      1024,
+		MaxBatchBodyBytes: 4096,
+	})
+
+	single := `{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}`
+	batch := `{"notifications":[` + single + `]}`
+	cases := []struct {
+		path  string
+		doc   string
+		size  int
+		want  int
+		limit string
+	}{
+		{"/api/v1/notifications", single, 1023, http.StatusCreated, ""},
+		{"/api/v1/notifications", single, 1025, http.StatusRequestEntityTooLarge, "1024-byte"},
+		// The batch endpoint takes bodies the default limit would reject.
+		{"/api/v1/notifications/batch", batch, 4095, http.StatusCreated, ""},
+		{"/api/v1/notifications/batch", batch, 4097, http.StatusRequestEntityTooLarge, "4096-byte"},
+	}
+	for _, c := range cases {
+		rec := do(h, http.MethodPost, c.path, "", padJSON(c.doc, c.size))
+		if rec.Code != c.want {
+			t.Fatalf("%s with %d bytes: expected %d, got %d %s", c.path, c.size, c.want, rec.Code, rec.Body)
+		}
+		if c.limit == "" {
+			continue
+		}
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected a JSON error body, got %s", rec.Body)
+		}
+		if body.Error.Code != "payload_too_large" || !strings.Contains(body.Error.Message, c.limit) {
+			t.Fatalf("unexpected error %+v", body.Error)
+		}
+	}
+}
+
+// slowRepo blocks List until the request context ends, like a query stuck on
+// a lock.
+type slowRepo struct {
+	*repository.MockNotificationRepository
+}
+
+func (r slowRepo) List(ctx context.Context, f domain.ListFilter) ([]*domain.Notification, int, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestRouter_HandlerTimeout(t *testing.T) {
+	q := queue.New()
+	svc := service.NewNotificationService(slowRepo{repository.NewMockNotificationRepository()}, q, zap.NewNop(), service.Options{})
+	templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+	h := api.NewRouter(svc, templates, q, prometheus.NewRegistry(), zap.NewNop(), api.RouterOptions{
+		HandlerTimeout:      20 * time.Millisecond,
+		BatchHandlerTimeout: time.Second,
+	})
+
+	rec := do(h, http.MethodGet, "/api/v1/notifications", "", "")
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), `"code":"timeout"`) {
+		t.Fatalf("expected 504 for the slow list, got %d %s", rec.Code, rec.Body)
+	}
+
+	// Writes are not slowed down, and the batch route has its own timeout.
+	rec = do(h, http.MethodPost, "/api/v1/notifications/batch", "",
+		`{"notifications":[{"channel":"sms","recipient":"+905551234567","content":"Hello","priority":"normal"}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for the batch, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRouter_MetricsExportsRuntimeCollectors(t *testing.T) {
+	for _, runtime := range []bool{true, false} {
+		reg := prometheus.NewRegistry()
+		metrics.New(reg, metrics.Options{RuntimeCollectors: runtime})
+		q := queue.New()
+		svc := service.NewNotificationService(repository.NewMockNotificationRepository(), q, zap.NewNop(), service.Options{})
+		templates := service.NewTemplateService(repository.NewMockTemplateRepository())
+		h := api.NewRouter(svc, templates, q, reg, zap.NewNop(), api.RouterOptions{})
+
+		rec := do(h, http.MethodGet, "/metrics", "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, family := range []string{"go_goroutines", "go_memstats_alloc_bytes", "process_start_time_seconds"} {
+			if got := strings.Contains(body, "\n"+family+" "); got != runtime {
+				t.Errorf("runtime collectors %v: expected %s present = %v", runtime, family, runtime)
+			}
+		}
+		if !strings.Contains(body, "build_info{") {
+			t.Errorf("runtime collectors %v: expected the application metrics too", runtime)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSOptions choose where the server's certificate comes from: a certificate
+// and key file pair, or Let's Encrypt for AutocertDomains, cached in
+// AutocertCacheDir. Setting neither leaves TLS off.
+type TLSOptions struct {
+	CertFile         string
+	KeyFile          string
+	AutocertDomains  []string
+	AutocertCacheDir string
+}
+
+// TLS terminates HTTPS for the API server.
+type TLS struct {
+	config  *tls.Config
+	manager *autocert.Manager // nil with a certificate file
+}
+
+// NewTLS loads the certificate opts name, so that a missing or unreadable
+// file stops startup instead of the first handshake. It returns nil when opts
+// leave TLS off.
+func NewTLS(opts TLSOptions) (*TLS, error) {
+	switch {
+	case opts.CertFile != "" || opts.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CERT_FILE/TLS_KEY_FILE: %w", err)
+		}
+		cfg := baseTLSConfig()
+		cfg.Certificates = []tls.Certificate{cert}
+		return &TLS{config: cfg}, nil
+	case len(opts.AutocertDomains) > 0:
+		if opts.AutocertCacheDir == "" {
+			return nil, errors.New("TLS_AUTOCERT_CACHE_DIR is required with TLS_AUTOCERT_DOMAINS")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.AutocertDomains...),
+			Cache:      autocert.DirCache(opts.AutocertCacheDir),
+		}
+		cfg := baseTLSConfig()
+		cfg.GetCertificate = m.GetCertificate
+		// Answer TLS-ALPN-01 challenges on the HTTPS listener itself.
+		cfg.NextProtos = append(cfg.NextProtos, "h2", "http/1.1", "acme-tls/1")
+		return &TLS{config: cfg, manager: m}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// baseTLSConfig accepts TLS 1.2 and later. TLS 1.2 is held to forward-secret
+// AEAD suites; TLS 1.3 suites are not configurable and all qualify.
+func baseTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// Config is the configuration for the HTTPS server; hand it to
+// http.Server.TLSConfig and call ListenAndServeTLS("", "").
+func (t *TLS) Config() *tls.Config {
+	return t.config
+}
+
+// RedirectHandler serves the plain HTTP listener: every request is redirected
+// to the same path over HTTPS on httpsPort. With autocert it answers ACME
+// HTTP-01 challenges first.
+func (t *TLS) RedirectHandler(httpsPort string) http.Handler {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		// 308 keeps the method and body, so a POST is not turned into a GET.
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	if t.manager != nil {
+		return t.manager.HTTPHandler(redirect)
+	}
+	return redirect
+}
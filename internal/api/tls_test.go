@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/api"
+)
+
+// writeSelfSigned writes a self-signed certificate for localhost and its key,
+// returning their paths.
+func writeSelfSigned(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLS_ServesWithCertificateFiles(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t)
+	serverTLS, err := api.NewTLS(api.TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverTLS.Config().MinVersion != tls.VersionTLS12 {
+		t.Fatalf("expected TLS 1.2 or later, got min version %x", serverTLS.Config().MinVersion)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	srv.TLS = serverTLS.Config()
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := srv.Client()
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11} //nolint:gosec // test
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("expected a TLS 1.1 client to be refused")
+	}
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // test
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTLS_MissingFilesFailClearly(t *testing.T) {
+	certFile, _ := writeSelfSigned(t)
+	_, err := api.NewTLS(api.TLSOptions{CertFile: certFile, KeyFile: filepath.Join(t.TempDir(), "missing.pem")})
+	if err == nil || !strings.Contains(err.Error(), "TLS_KEY_FILE") {
+		t.Fatalf("expected an error naming the settings, got %v", err)
+	}
+}
+
+func TestNewTLS_OffWithoutOptions(t *testing.T) {
+	if serverTLS, err := api.NewTLS(api.TLSOptions{}); serverTLS != nil || err != nil {
+		t.Fatalf("expected TLS off, got %v, %v", serverTLS, err)
+	}
+}
+
+func TestTLS_RedirectHandler(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t)
+	serverTLS, err := api.NewTLS(api.TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		port, host, want string
+	}{
+		{"443", "notify.example.com", "https://notify.example.com/api/v1/notifications?page=2"},
+		{"443", "notify.example.com:80", "https://notify.example.com/api/v1/notifications?page=2"},
+		{"8443", "notify.example.com:8080", "https://notify.example.com:8443/api/v1/notifications?page=2"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://"+tt.host+"/api/v1/notifications?page=2", nil)
+		rec := httptest.NewRecorder()
+		serverTLS.RedirectHandler(tt.port).ServeHTTP(rec, req)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+			t.Errorf("port %s, host %s: expected 308 to %s, got %d %s", tt.port, tt.host, tt.want, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package audit keeps the append-only record of API mutations. Recording is
+// best-effort like lifecycle events: it must never fail or slow down the
+// request being audited.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// defaultWriteTimeout bounds each write when NewLog is given no timeout.
+const defaultWriteTimeout = 5 * time.Second
+
+// Log buffers audit entries and writes them to the repository in the
+// background. Record never blocks; entries that do not fit in the buffer, or
+// that the repository fails to store, are dropped and reported through
+// onDrop.
+type Log struct {
+	repo    repository.AuditRepository
+	buf     chan domain.AuditEntry
+	timeout time.Duration
+	onDrop  func()
+	logger  *zap.Logger
+}
+
+// NewLog returns a Log writing to repo through a buffer of size entries.
+// timeout bounds each write (5s when not positive); onDrop may be nil.
+func NewLog(repo repository.AuditRepository, size int, timeout time.Duration, onDrop func(), logger *zap.Logger) *Log {
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	if onDrop == nil {
+		onDrop = func() {}
+	}
+	return &Log{repo: repo, buf: make(chan domain.AuditEntry, size), timeout: timeout, onDrop: onDrop, logger: logger}
+}
+
+// Record buffers e, dropping it if the buffer is full.
+func (l *Log) Record(e domain.AuditEntry) {
+	select {
+	case l.buf <- e:
+	default:
+		l.onDrop()
+	}
+}
+
+// Run writes buffered entries until ctx is cancelled, then makes one last
+// attempt at whatever is still buffered.
+func (l *Log) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-l.buf:
+					l.write(e)
+				default:
+					return
+				}
+			}
+		case e := <-l.buf:
+			l.write(e)
+		}
+	}
+}
+
+func (l *Log) write(e domain.AuditEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	if err := l.repo.Create(ctx, &e); err != nil {
+		l.onDrop()
+		l.logger.Warn("dropped audit entry",
+			zap.String("action", e.Action), zap.String("path", e.Path), zap.Error(err))
+	}
+}
+
+// List returns one page of stored entries matching f, most recent first,
+// and the total number of matches.
+func (l *Log) List(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEntry, int, error) {
+	return l.repo.List(ctx, f)
+}
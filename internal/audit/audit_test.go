@@ -0,0 +1,70 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/audit"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// failingRepo rejects every write.
+type failingRepo struct {
+	repository.AuditRepository
+}
+
+func (failingRepo) Create(context.Context, *domain.AuditEntry) error {
+	return errors.New("database down")
+}
+
+// drain runs l until its buffer is empty.
+func drain(l *audit.Log) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.Run(ctx) // returns once the buffer is flushed
+}
+
+func TestLog_DropsWhenBufferFull(t *testing.T) {
+	var dropped int
+	repo := repository.NewMockAuditRepository()
+	l := audit.NewLog(repo, 1, time.Second, func() { dropped++ }, zap.NewNop())
+
+	for range 3 {
+		l.Record(domain.AuditEntry{Action: "notification.create"})
+	}
+	drain(l)
+
+	if dropped != 2 || len(repo.All()) != 1 {
+		t.Fatalf("expected 1 entry written and 2 dropped, got %d and %d", len(repo.All()), dropped)
+	}
+}
+
+func TestLog_RunWritesAndFlushes(t *testing.T) {
+	repo := repository.NewMockAuditRepository()
+	l := audit.NewLog(repo, 10, time.Second, nil, zap.NewNop())
+	for _, action := range []string{"notification.create", "notification.cancel"} {
+		l.Record(domain.AuditEntry{Action: action})
+	}
+	drain(l)
+
+	entries := repo.All()
+	if len(entries) != 2 || entries[0].Action != "notification.create" || entries[1].Action != "notification.cancel" {
+		t.Fatalf("expected both entries in order, got %+v", entries)
+	}
+}
+
+func TestLog_WriteErrorCountsAsDrop(t *testing.T) {
+	var dropped int
+	l := audit.NewLog(failingRepo{}, 10, time.Second, func() { dropped++ }, zap.NewNop())
+	l.Record(domain.AuditEntry{Action: "notification.create"})
+	drain(l)
+
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped entry, got %d", dropped)
+	}
+}
@@ -1,103 +1,829 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
 )
 
-// Config holds all runtime configuration loaded from environment variables.
-// Every field has a sensible default; only DATABASE_URL is required.
+// Config holds all runtime configuration, loaded from environment variables
+// and optionally a YAML file (see LoadFrom). Every field has a sensible
+// default; only DATABASE_URL is required.
 type Config struct {
 	// Server
-	HTTPPort        string
-	ReadTimeout     time.Duration
-	WriteTimeout    time.Duration
-	ShutdownTimeout time.Duration
+	HTTPPort        string        `yaml:"http_port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// HTTPS: either a certificate and key pair, or certificates obtained
+	// from Let's Encrypt for TLSAutocertDomains and kept in
+	// TLSAutocertCacheDir. With neither the server speaks plain HTTP.
+	// HTTPRedirectPort, when set, adds a plain listener that redirects to
+	// HTTPS (and answers ACME HTTP-01 challenges).
+	TLSCertFile         string   `yaml:"tls_cert_file"`
+	TLSKeyFile          string   `yaml:"tls_key_file"`
+	TLSAutocertDomains  []string `yaml:"tls_autocert_domains"`
+	TLSAutocertCacheDir string   `yaml:"tls_autocert_cache_dir"`
+	HTTPRedirectPort    string   `yaml:"http_redirect_port"`
+
+	// EnablePprof serves the net/http/pprof profiles under /debug/pprof: on
+	// AdminPort when it is set, a plain listener that must only be reachable
+	// from inside the deployment, otherwise on HTTPPort behind an admin key.
+	EnablePprof bool   `yaml:"enable_pprof"`
+	AdminPort   string `yaml:"admin_port"`
+
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
 
 	// Database
-	DatabaseURL string
-	DBMaxConns  int32
-	DBMinConns  int32
+	DatabaseURL string `yaml:"database_url"`
+	DBMaxConns  int32  `yaml:"db_max_conns"`
+	DBMinConns  int32  `yaml:"db_min_conns"`
+	// DBStartupMaxWait is how long startup keeps retrying a database that is
+	// not reachable yet before giving up; 0 tries once.
+	DBStartupMaxWait time.Duration `yaml:"db_startup_max_wait"`
+	// DBMigrationLockTimeout is how long an instance waits for another one
+	// to finish migrating before giving up.
+	DBMigrationLockTimeout time.Duration `yaml:"db_migration_lock_timeout"`
+	// DBStatementCacheMode is how statements are sent: prepare (named
+	// prepared statements, cached per connection), describe (unnamed, with
+	// result descriptions cached) or simple (the simple query protocol). Only
+	// describe and simple work behind PgBouncer in transaction pooling mode.
+	DBStatementCacheMode string `yaml:"db_statement_cache_mode"`
+	// DBStatementTimeout makes Postgres cancel any statement on a pool
+	// connection that runs longer; 0 leaves it to the server's setting.
+	DBStatementTimeout time.Duration `yaml:"db_statement_timeout"`
+	// Pool connections are replaced after DBConnMaxLifetime, closed after
+	// DBConnMaxIdleTime unused, and checked every DBHealthcheckPeriod.
+	DBConnMaxLifetime   time.Duration `yaml:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime   time.Duration `yaml:"db_conn_max_idle_time"`
+	DBHealthcheckPeriod time.Duration `yaml:"db_healthcheck_period"`
+
+	// Repository statement execution: per-call timeout and transient-error retries
+	DBQueryTimeout      time.Duration `yaml:"db_query_timeout"`
+	DBQueryRetries      int           `yaml:"db_query_retries"`
+	DBQueryRetryBackoff time.Duration `yaml:"db_query_retry_backoff"`
+	// DBQueryLogging logs every statement at debug level, and failed ones at
+	// warn. Off by default because of the volume.
+	DBQueryLogging bool `yaml:"db_query_logging"`
+
+	// API keys mapped to their owner IDs, parsed from API_KEYS
+	// ("owner1:key1,owner2:key2"). Empty disables authentication.
+	APIKeys map[string]string `yaml:"api_keys"`
+
+	// AdminAPIKeys unlock the /api/v1/admin endpoints, which act across all
+	// owners. With none configured those endpoints are not served at all.
+	AdminAPIKeys []string `yaml:"admin_api_keys"`
+
+	// HTTP rate limiting per client (API key, else IP) on /api/v1:
+	// steady-state requests per second, burst size, and how many client
+	// buckets are kept before the least recently used is evicted.
+	// HTTPRateLimit 0 disables it.
+	HTTPRateLimit      float64 `yaml:"http_rate_limit"`
+	HTTPRateBurst      int     `yaml:"http_rate_burst"`
+	HTTPRateMaxClients int     `yaml:"http_rate_max_clients"`
+
+	// Responses of at least HTTPCompressMinSize bytes are gzipped for clients
+	// that accept it; a negative value disables compression.
+	HTTPCompressMinSize int `yaml:"http_compress_min_size"`
+
+	// MaxPageSize is the largest limit list endpoints accept; larger values
+	// are rejected with 400 rather than clamped.
+	MaxPageSize int `yaml:"max_page_size"`
+
+	// MaxLookupIDs caps the IDs one POST /notifications/lookup accepts.
+	MaxLookupIDs int `yaml:"max_lookup_ids"`
+
+	// Request bodies larger than MaxBodyBytes are rejected with 413; the
+	// batch endpoint, whose payloads legitimately run larger, is capped at
+	// MaxBatchBodyBytes instead.
+	MaxBodyBytes      int64 `yaml:"max_body_bytes"`
+	MaxBatchBodyBytes int64 `yaml:"max_batch_body_bytes"`
+
+	// Handlers on /api/v1 that have not started their response within
+	// HandlerTimeout answer 504; batch creation gets BatchHandlerTimeout.
+	// Both must be below WriteTimeout so the 504 can still be written; 0
+	// disables the timeout.
+	HandlerTimeout      time.Duration `yaml:"handler_timeout"`
+	BatchHandlerTimeout time.Duration `yaml:"batch_handler_timeout"`
+
+	// SwaggerEnabled serves the OpenAPI document at /swagger/doc.json and a
+	// UI at /swagger/; locked-down deployments can turn it off.
+	SwaggerEnabled bool `yaml:"swagger_enabled"`
+
+	// Tracing exports spans over OTLP/HTTP to OTelEndpoint; empty disables
+	// it. TraceSampleRatio is the fraction of new traces kept.
+	OTelEndpoint     string  `yaml:"otel_exporter_otlp_endpoint"`
+	OTelServiceName  string  `yaml:"otel_service_name"`
+	TraceSampleRatio float64 `yaml:"trace_sample_ratio"`
+
+	// Creation rate limits per owner: TenantLimits applies to everyone except
+	// the owners in TenantLimitOverrides, which are given alongside their key
+	// in API_KEYS ("owner:key:requests/notifications", both per minute).
+	TenantLimits         ratelimiter.TenantLimits            `yaml:"tenant_limits"`
+	TenantLimitOverrides map[string]ratelimiter.TenantLimits `yaml:"tenant_limit_overrides"`
 
 	// External provider
-	ProviderBaseURL string
-	ProviderTimeout time.Duration
+	ProviderBaseURL string        `yaml:"provider_base_url"`
+	ProviderTimeout time.Duration `yaml:"provider_timeout"`
+	// Optional provider health endpoint consulted by /ready (GET, 2xx = healthy)
+	ProviderHealthURL string `yaml:"provider_health_url"`
+	// SandboxMode makes every new notification a dry run and keeps workers
+	// from calling the provider at all, for QA and staging environments.
+	SandboxMode bool `yaml:"sandbox_mode"`
+
+	// Readiness probe: per-dependency timeout, and the queue fill percentage
+	// at which a priority tier counts as saturated
+	ReadyTimeout         time.Duration `yaml:"ready_timeout"`
+	ReadyQueueMaxPercent int           `yaml:"ready_queue_max_percent"`
 
 	// Worker counts (one worker pool is shared across all channel types)
-	SMSWorkers   int
-	EmailWorkers int
-	PushWorkers  int
+	SMSWorkers   int `yaml:"sms_workers"`
+	EmailWorkers int `yaml:"email_workers"`
+	PushWorkers  int `yaml:"push_workers"`
 
 	// Rate limiting: maximum requests per second per channel
-	RateLimit int
+	RateLimit int `yaml:"rate_limit_per_channel"`
+	// RateLimitSlowWait is how long a send may wait on its channel's limiter
+	// before the wait is counted as slow.
+	RateLimitSlowWait time.Duration `yaml:"rate_limit_slow_wait"`
+
+	// Sends per recipient by channel, e.g. at most 3 SMS a minute; none by
+	// default. RECIPIENT_LIMITS ("sms:3/1m,email:10/1h") overrides individual
+	// channels. A send over its limit is rescheduled for when one is allowed.
+	RecipientLimits map[domain.Channel]ratelimiter.RecipientLimit `yaml:"recipient_limits"`
+	// RecipientLimiterMaxKeys bounds how many recipients the limits track;
+	// past it the least recently sent to are forgotten.
+	RecipientLimiterMaxKeys int `yaml:"recipient_limiter_max_keys"`
+
+	// StrictEnqueue rejects creates with 503 when the queue is full instead of
+	// accepting them as pending (202 with queued=false).
+	StrictEnqueue bool `yaml:"strict_enqueue"`
+
+	// DefaultMaxRetries is the max_retries of notifications created without
+	// one, between 0 and domain.MaxRetriesLimit.
+	DefaultMaxRetries int `yaml:"default_max_retries"`
 
-	// Retry backoff durations: index 0 = first retry delay, etc.
-	RetryBackoff []time.Duration
+	// Retry backoff durations: index 0 = first retry delay, etc. Retries past
+	// the end of the list wait as long as the last entry.
+	RetryBackoff []time.Duration `yaml:"retry_backoff"`
+
+	// Scheduling validation: furthest allowed scheduled_at, and how far in the
+	// past a scheduled_at may be (client clock skew) before it is rejected.
+	MaxScheduleHorizon time.Duration `yaml:"max_schedule_horizon"`
+	ScheduleClockSkew  time.Duration `yaml:"schedule_clock_skew"`
+
+	// Maximum content length per channel, in characters. CONTENT_LIMITS
+	// ("sms:1600,push:1024") overrides individual channels' defaults.
+	ContentLimits map[domain.Channel]int `yaml:"content_limits"`
+
+	// Calling code, without "+", that national SMS numbers (leading 0) are
+	// normalised to; empty leaves them as written.
+	DefaultCountryCode string `yaml:"default_country_code"`
+
+	// Duplicate-send suppression: identical channel+recipient+content within
+	// this window returns the earlier notification. Zero disables it.
+	DedupWindow time.Duration `yaml:"dedup_window"`
+
+	// Status webhooks (callback_url): HMAC signing secret, per-attempt timeout,
+	// total attempts, first retry delay (doubles per attempt), poll interval,
+	// concurrent deliveries, and hostnames callbacks may not target.
+	CallbackSecret       string        `yaml:"callback_signing_secret"`
+	CallbackTimeout      time.Duration `yaml:"callback_timeout"`
+	CallbackMaxAttempts  int           `yaml:"callback_max_attempts"`
+	CallbackBackoff      time.Duration `yaml:"callback_backoff"`
+	CallbackPollInterval time.Duration `yaml:"callback_poll_interval"`
+	CallbackConcurrency  int           `yaml:"callback_concurrency"`
+	CallbackBlockedHosts []string      `yaml:"callback_blocked_hosts"`
+
+	// Lifecycle events: EventPublisher is "none" (default) or "kafka", which
+	// produces through the Kafka REST Proxy at KafkaRESTURL. Events wait in a
+	// buffer of EventBufferSize and are dropped when it is full.
+	EventPublisher      string        `yaml:"event_publisher"`
+	KafkaRESTURL        string        `yaml:"kafka_rest_url"`
+	KafkaTopic          string        `yaml:"kafka_topic"`
+	EventBufferSize     int           `yaml:"event_buffer_size"`
+	EventPublishTimeout time.Duration `yaml:"event_publish_timeout"`
+
+	// Audit log of API mutations: entries wait in a buffer of AuditBufferSize
+	// and are dropped when it is full.
+	AuditLog        bool `yaml:"audit_log"`
+	AuditBufferSize int  `yaml:"audit_buffer_size"`
 
 	// Background worker poll intervals
-	SchedulerInterval time.Duration
-	RetryInterval     time.Duration
+	SchedulerInterval time.Duration `yaml:"scheduler_interval"`
+	RetryInterval     time.Duration `yaml:"retry_interval"`
+	// LeaderElection runs the scheduler, retry and partition workers on one
+	// instance only, the holder of a Postgres advisory lock; the others stand
+	// by and try to take the lock every LeaderCheckInterval, which is also how
+	// often the leader checks it still holds it.
+	LeaderElection      bool          `yaml:"leader_election"`
+	LeaderCheckInterval time.Duration `yaml:"leader_check_interval"`
+	// PollPageSize is how many due rows the scheduler and retry workers read
+	// at a time; a poll keeps reading pages until the backlog is drained.
+	PollPageSize int `yaml:"poll_page_size"`
+	// BatchCountInterval debounces batch counter updates: the counters of a
+	// batch are recomputed at most once per interval.
+	BatchCountInterval time.Duration `yaml:"batch_count_interval"`
+	// StatusCountInterval is how often the stored notifications are counted
+	// by status for the notifications_by_status gauge; 0 disables it.
+	StatusCountInterval time.Duration `yaml:"status_count_interval"`
+	// DeliveryLatencyBuckets are the upper bounds of the end-to-end delivery
+	// latency histograms, in ascending order.
+	DeliveryLatencyBuckets []time.Duration `yaml:"delivery_latency_buckets"`
+	// LatencyBuckets are the upper bounds, in seconds, of the dequeue-to-ack
+	// processing latency histogram, in ascending order.
+	LatencyBuckets []float64 `yaml:"latency_buckets"`
+	// MetricsRuntime exports the Go runtime (go_*) and process (process_*)
+	// metrics alongside the application's own.
+	MetricsRuntime bool `yaml:"metrics_runtime"`
+
+	// Monthly partitioning of the notifications table (opt-in; flat table by default).
+	// RetentionMonths = 0 keeps every partition forever.
+	PartitionNotifications    bool          `yaml:"notifications_partitioned"`
+	PartitionPrecreateMonths  int           `yaml:"partition_precreate_months"`
+	PartitionMaintenanceEvery time.Duration `yaml:"partition_maintenance_interval"`
+	RetentionMonths           int           `yaml:"notification_retention_months"`
 }
 
+// Load reads the configuration from the environment and validates it. Every
+// problem found, from unparsable values to settings that contradict each
+// other, is reported in one joined error.
 func Load() (*Config, error) {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL is required")
+	return load(defaults())
+}
+
+// LoadFrom reads the YAML file at path, whose keys are the environment
+// variable names in lower case, and then the environment on top of it: a set
+// variable always wins over the file, and settings absent from both keep
+// their defaults. Keys the file does not know are reported as errors.
+func LoadFrom(path string) (*Config, error) {
+	base := defaults()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(base); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
 	}
+	return load(base)
+}
 
+// defaults returns the settings used when neither a config file nor the
+// environment gives a value.
+func defaults() *Config {
+	contentLimits := make(map[domain.Channel]int, len(domain.DefaultContentLimits))
+	for ch, limit := range domain.DefaultContentLimits {
+		contentLimits[ch] = limit
+	}
 	return &Config{
-		HTTPPort:        getEnv("HTTP_PORT", "8080"),
-		ReadTimeout:     getDuration("READ_TIMEOUT", 5*time.Second),
-		WriteTimeout:    getDuration("WRITE_TIMEOUT", 10*time.Second),
-		ShutdownTimeout: getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		HTTPPort:        "8080",
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+
+		TLSAutocertCacheDir: "autocert-cache",
 
-		DatabaseURL: dbURL,
-		DBMaxConns:  int32(getInt("DB_MAX_CONNS", 25)),
-		DBMinConns:  int32(getInt("DB_MIN_CONNS", 5)),
+		LogLevel: "info",
 
-		ProviderBaseURL: getEnv("PROVIDER_BASE_URL", "https://webhook.site/your-uuid-here"),
-		ProviderTimeout: getDuration("PROVIDER_TIMEOUT", 10*time.Second),
+		DBMaxConns: 25,
+		DBMinConns: 5,
 
-		SMSWorkers:   getInt("SMS_WORKERS", 5),
-		EmailWorkers: getInt("EMAIL_WORKERS", 5),
-		PushWorkers:  getInt("PUSH_WORKERS", 5),
+		DBStartupMaxWait:       time.Minute,
+		DBMigrationLockTimeout: 5 * time.Minute,
+		DBStatementCacheMode:   "prepare",
+		DBStatementTimeout:     8 * time.Second,
+		DBConnMaxLifetime:      time.Hour,
+		DBConnMaxIdleTime:      30 * time.Minute,
+		DBHealthcheckPeriod:    time.Minute,
 
-		RateLimit: getInt("RATE_LIMIT_PER_CHANNEL", 100),
+		DBQueryTimeout:      5 * time.Second,
+		DBQueryRetries:      2,
+		DBQueryRetryBackoff: 50 * time.Millisecond,
 
-		RetryBackoff: []time.Duration{
-			getDuration("RETRY_BACKOFF_1", 5*time.Second),
-			getDuration("RETRY_BACKOFF_2", 30*time.Second),
-			getDuration("RETRY_BACKOFF_3", 120*time.Second),
+		APIKeys: map[string]string{},
+		TenantLimits: ratelimiter.TenantLimits{
+			RequestsPerMinute:      600,
+			NotificationsPerMinute: 10000,
 		},
+		TenantLimitOverrides: map[string]ratelimiter.TenantLimits{},
+
+		HTTPRateLimit:      50,
+		HTTPRateBurst:      100,
+		HTTPRateMaxClients: 10000,
+
+		HTTPCompressMinSize: 1024,
+		MaxPageSize:         100,
+		MaxLookupIDs:        100,
+		MaxBodyBytes:        1 << 20,
+		MaxBatchBodyBytes:   10 << 20,
+		HandlerTimeout:      5 * time.Second,
+		BatchHandlerTimeout: 8 * time.Second,
+
+		SwaggerEnabled: true,
+
+		OTelServiceName:  "notification-service",
+		TraceSampleRatio: 1,
+
+		ProviderBaseURL: "https://webhook.site/your-uuid-here",
+		ProviderTimeout: 10 * time.Second,
+
+		ReadyTimeout:         2 * time.Second,
+		ReadyQueueMaxPercent: 90,
+
+		SMSWorkers:   5,
+		EmailWorkers: 5,
+		PushWorkers:  5,
+
+		RateLimit:         100,
+		RateLimitSlowWait: time.Second,
+
+		RecipientLimits:         map[domain.Channel]ratelimiter.RecipientLimit{},
+		RecipientLimiterMaxKeys: 100000,
+
+		DefaultMaxRetries: domain.DefaultMaxRetries,
+		RetryBackoff:      []time.Duration{5 * time.Second, 30 * time.Second, 120 * time.Second},
+
+		MaxScheduleHorizon: 30 * 24 * time.Hour,
+		ScheduleClockSkew:  30 * time.Second,
+		ContentLimits:      contentLimits,
+
+		CallbackTimeout:      5 * time.Second,
+		CallbackMaxAttempts:  8,
+		CallbackBackoff:      5 * time.Second,
+		CallbackPollInterval: 5 * time.Second,
+		CallbackConcurrency:  4,
+		CallbackBlockedHosts: []string{"localhost", "127.0.0.1", "::1"},
+
+		EventPublisher:      "none",
+		KafkaTopic:          "notification-events",
+		EventBufferSize:     10000,
+		EventPublishTimeout: 5 * time.Second,
+
+		AuditLog:        true,
+		AuditBufferSize: 10000,
+
+		SchedulerInterval: 5 * time.Second,
+		RetryInterval:     10 * time.Second,
+		PollPageSize:      500,
+
+		LeaderCheckInterval: 5 * time.Second,
+
+		BatchCountInterval:  500 * time.Millisecond,
+		StatusCountInterval: 30 * time.Second,
+
+		DeliveryLatencyBuckets: defaultDeliveryBuckets,
+		LatencyBuckets:         defaultLatencyBuckets,
+		MetricsRuntime:         true,
+
+		PartitionPrecreateMonths:  3,
+		PartitionMaintenanceEvery: time.Hour,
+	}
+}
+
+// load overlays the environment on base and validates the result.
+func load(base *Config) (*Config, error) {
+	e := &env{}
+
+	apiKeys, tenantOverrides := base.APIKeys, base.TenantLimitOverrides
+	if v := os.Getenv("API_KEYS"); v != "" {
+		var err error
+		apiKeys, tenantOverrides, err = parseAPIKeys(v)
+		e.fail(err)
+	}
+	contentLimits, err := parseContentLimits(os.Getenv("CONTENT_LIMITS"), base.ContentLimits)
+	e.fail(err)
+	recipientLimits, err := parseRecipientLimits(os.Getenv("RECIPIENT_LIMITS"), base.RecipientLimits)
+	e.fail(err)
+	deliveryBuckets, err := parseBuckets("DELIVERY_LATENCY_BUCKETS", os.Getenv("DELIVERY_LATENCY_BUCKETS"), base.DeliveryLatencyBuckets)
+	e.fail(err)
+	latencyBuckets, err := parseSecondsBuckets("LATENCY_BUCKETS", os.Getenv("LATENCY_BUCKETS"), base.LatencyBuckets)
+	e.fail(err)
+	// RETRY_BACKOFF replaces the whole schedule; without it the legacy
+	// RETRY_BACKOFF_n overrides the nth delay the base configures.
+	retryBackoff, err := parseBackoff("RETRY_BACKOFF", os.Getenv("RETRY_BACKOFF"))
+	e.fail(err)
+	if os.Getenv("RETRY_BACKOFF") == "" {
+		retryBackoff = slices.Clone(base.RetryBackoff)
+		for i := range retryBackoff {
+			retryBackoff[i] = e.duration(fmt.Sprintf("RETRY_BACKOFF_%d", i+1), retryBackoff[i])
+		}
+	}
+
+	cfg := &Config{
+		HTTPPort:        e.str("HTTP_PORT", base.HTTPPort),
+		ReadTimeout:     e.duration("READ_TIMEOUT", base.ReadTimeout),
+		WriteTimeout:    e.duration("WRITE_TIMEOUT", base.WriteTimeout),
+		ShutdownTimeout: e.duration("SHUTDOWN_TIMEOUT", base.ShutdownTimeout),
+
+		TLSCertFile:         e.str("TLS_CERT_FILE", base.TLSCertFile),
+		TLSKeyFile:          e.str("TLS_KEY_FILE", base.TLSKeyFile),
+		TLSAutocertDomains:  e.list("TLS_AUTOCERT_DOMAINS", base.TLSAutocertDomains),
+		TLSAutocertCacheDir: e.str("TLS_AUTOCERT_CACHE_DIR", base.TLSAutocertCacheDir),
+		HTTPRedirectPort:    e.str("HTTP_REDIRECT_PORT", base.HTTPRedirectPort),
+
+		EnablePprof: e.bool("ENABLE_PPROF", base.EnablePprof),
+		AdminPort:   e.str("ADMIN_PORT", base.AdminPort),
+
+		LogLevel: e.str("LOG_LEVEL", base.LogLevel),
+
+		DatabaseURL: e.str("DATABASE_URL", base.DatabaseURL),
+		DBMaxConns:  int32(e.int("DB_MAX_CONNS", int(base.DBMaxConns))),
+		DBMinConns:  int32(e.int("DB_MIN_CONNS", int(base.DBMinConns))),
+
+		DBStartupMaxWait:       e.duration("DB_STARTUP_MAX_WAIT", base.DBStartupMaxWait),
+		DBMigrationLockTimeout: e.duration("DB_MIGRATION_LOCK_TIMEOUT", base.DBMigrationLockTimeout),
+		DBStatementCacheMode:   e.str("DB_STATEMENT_CACHE_MODE", base.DBStatementCacheMode),
+		DBStatementTimeout:     e.duration("DB_STATEMENT_TIMEOUT", base.DBStatementTimeout),
+		DBConnMaxLifetime:      e.duration("DB_CONN_MAX_LIFETIME", base.DBConnMaxLifetime),
+		DBConnMaxIdleTime:      e.duration("DB_CONN_MAX_IDLE_TIME", base.DBConnMaxIdleTime),
+		DBHealthcheckPeriod:    e.duration("DB_HEALTHCHECK_PERIOD", base.DBHealthcheckPeriod),
+
+		DBQueryTimeout:      e.duration("DB_QUERY_TIMEOUT", base.DBQueryTimeout),
+		DBQueryRetries:      e.int("DB_QUERY_RETRIES", base.DBQueryRetries),
+		DBQueryRetryBackoff: e.duration("DB_QUERY_RETRY_BACKOFF", base.DBQueryRetryBackoff),
+		DBQueryLogging:      e.bool("DB_QUERY_LOGGING", base.DBQueryLogging),
+
+		APIKeys:      apiKeys,
+		AdminAPIKeys: e.list("ADMIN_API_KEYS", base.AdminAPIKeys),
+		TenantLimits: ratelimiter.TenantLimits{
+			RequestsPerMinute:      e.int("TENANT_REQUESTS_PER_MINUTE", base.TenantLimits.RequestsPerMinute),
+			NotificationsPerMinute: e.int("TENANT_NOTIFICATIONS_PER_MINUTE", base.TenantLimits.NotificationsPerMinute),
+		},
+		TenantLimitOverrides: tenantOverrides,
+
+		HTTPRateLimit:      e.float("HTTP_RATE_LIMIT", base.HTTPRateLimit),
+		HTTPRateBurst:      e.int("HTTP_RATE_BURST", base.HTTPRateBurst),
+		HTTPRateMaxClients: e.int("HTTP_RATE_MAX_CLIENTS", base.HTTPRateMaxClients),
+
+		HTTPCompressMinSize: e.int("HTTP_COMPRESS_MIN_SIZE", base.HTTPCompressMinSize),
+		MaxPageSize:         e.int("MAX_PAGE_SIZE", base.MaxPageSize),
+		MaxLookupIDs:        e.int("MAX_LOOKUP_IDS", base.MaxLookupIDs),
+		MaxBodyBytes:        int64(e.int("MAX_BODY_BYTES", int(base.MaxBodyBytes))),
+		MaxBatchBodyBytes:   int64(e.int("MAX_BATCH_BODY_BYTES", int(base.MaxBatchBodyBytes))),
+		HandlerTimeout:      e.duration("HANDLER_TIMEOUT", base.HandlerTimeout),
+		BatchHandlerTimeout: e.duration("BATCH_HANDLER_TIMEOUT", base.BatchHandlerTimeout),
+
+		SwaggerEnabled: e.bool("SWAGGER_ENABLED", base.SwaggerEnabled),
+
+		OTelEndpoint:     e.str("OTEL_EXPORTER_OTLP_ENDPOINT", base.OTelEndpoint),
+		OTelServiceName:  e.str("OTEL_SERVICE_NAME", base.OTelServiceName),
+		TraceSampleRatio: e.float("TRACE_SAMPLE_RATIO", base.TraceSampleRatio),
+
+		ProviderBaseURL: e.str("PROVIDER_BASE_URL", base.ProviderBaseURL),
+		ProviderTimeout: e.duration("PROVIDER_TIMEOUT", base.ProviderTimeout),
+		SandboxMode:     e.bool("SANDBOX_MODE", base.SandboxMode),
+
+		ProviderHealthURL:    e.str("PROVIDER_HEALTH_URL", base.ProviderHealthURL),
+		ReadyTimeout:         e.duration("READY_TIMEOUT", base.ReadyTimeout),
+		ReadyQueueMaxPercent: e.int("READY_QUEUE_MAX_PERCENT", base.ReadyQueueMaxPercent),
+
+		SMSWorkers:   e.int("SMS_WORKERS", base.SMSWorkers),
+		EmailWorkers: e.int("EMAIL_WORKERS", base.EmailWorkers),
+		PushWorkers:  e.int("PUSH_WORKERS", base.PushWorkers),
+
+		RateLimit:         e.int("RATE_LIMIT_PER_CHANNEL", base.RateLimit),
+		RateLimitSlowWait: e.duration("RATE_LIMIT_SLOW_WAIT", base.RateLimitSlowWait),
+		StrictEnqueue:     e.bool("STRICT_ENQUEUE", base.StrictEnqueue),
+
+		RecipientLimits:         recipientLimits,
+		RecipientLimiterMaxKeys: e.int("RECIPIENT_LIMITER_MAX_KEYS", base.RecipientLimiterMaxKeys),
+
+		DefaultMaxRetries: e.int("DEFAULT_MAX_RETRIES", base.DefaultMaxRetries),
+		RetryBackoff:      retryBackoff,
+
+		MaxScheduleHorizon: e.duration("MAX_SCHEDULE_HORIZON", base.MaxScheduleHorizon),
+		ScheduleClockSkew:  e.duration("SCHEDULE_CLOCK_SKEW", base.ScheduleClockSkew),
+		ContentLimits:      contentLimits,
+
+		DefaultCountryCode: e.str("DEFAULT_COUNTRY_CODE", base.DefaultCountryCode),
+
+		DedupWindow: e.duration("DEDUP_WINDOW", base.DedupWindow),
 
-		SchedulerInterval: getDuration("SCHEDULER_INTERVAL", 5*time.Second),
-		RetryInterval:     getDuration("RETRY_INTERVAL", 10*time.Second),
-	}, nil
+		CallbackSecret:       e.str("CALLBACK_SIGNING_SECRET", base.CallbackSecret),
+		CallbackTimeout:      e.duration("CALLBACK_TIMEOUT", base.CallbackTimeout),
+		CallbackMaxAttempts:  e.int("CALLBACK_MAX_ATTEMPTS", base.CallbackMaxAttempts),
+		CallbackBackoff:      e.duration("CALLBACK_BACKOFF", base.CallbackBackoff),
+		CallbackPollInterval: e.duration("CALLBACK_POLL_INTERVAL", base.CallbackPollInterval),
+		CallbackConcurrency:  e.int("CALLBACK_CONCURRENCY", base.CallbackConcurrency),
+		CallbackBlockedHosts: e.list("CALLBACK_BLOCKED_HOSTS", base.CallbackBlockedHosts),
+
+		EventPublisher:      e.str("EVENT_PUBLISHER", base.EventPublisher),
+		KafkaRESTURL:        e.str("KAFKA_REST_URL", base.KafkaRESTURL),
+		KafkaTopic:          e.str("KAFKA_TOPIC", base.KafkaTopic),
+		EventBufferSize:     e.int("EVENT_BUFFER_SIZE", base.EventBufferSize),
+		EventPublishTimeout: e.duration("EVENT_PUBLISH_TIMEOUT", base.EventPublishTimeout),
+
+		AuditLog:        e.bool("AUDIT_LOG", base.AuditLog),
+		AuditBufferSize: e.int("AUDIT_BUFFER_SIZE", base.AuditBufferSize),
+
+		SchedulerInterval: e.duration("SCHEDULER_INTERVAL", base.SchedulerInterval),
+		RetryInterval:     e.duration("RETRY_INTERVAL", base.RetryInterval),
+		PollPageSize:      e.int("POLL_PAGE_SIZE", base.PollPageSize),
+
+		LeaderElection:      e.bool("LEADER_ELECTION", base.LeaderElection),
+		LeaderCheckInterval: e.duration("LEADER_CHECK_INTERVAL", base.LeaderCheckInterval),
+
+		BatchCountInterval:  e.duration("BATCH_COUNT_INTERVAL", base.BatchCountInterval),
+		StatusCountInterval: e.duration("STATUS_COUNT_INTERVAL", base.StatusCountInterval),
+
+		DeliveryLatencyBuckets: deliveryBuckets,
+		LatencyBuckets:         latencyBuckets,
+		MetricsRuntime:         e.bool("METRICS_RUNTIME", base.MetricsRuntime),
+
+		PartitionNotifications:    e.bool("NOTIFICATIONS_PARTITIONED", base.PartitionNotifications),
+		PartitionPrecreateMonths:  e.int("PARTITION_PRECREATE_MONTHS", base.PartitionPrecreateMonths),
+		PartitionMaintenanceEvery: e.duration("PARTITION_MAINTENANCE_INTERVAL", base.PartitionMaintenanceEvery),
+		RetentionMonths:           e.int("NOTIFICATION_RETENTION_MONTHS", base.RetentionMonths),
+	}
+
+	if err := errors.Join(append(e.errs, cfg.Validate())...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseAPIKeys parses "owner:key" pairs separated by commas into a key → owner
+// map. An entry may end in ":requests/notifications" to override the creation
+// rate limits for that owner; those are returned keyed by owner.
+func parseAPIKeys(v string) (map[string]string, map[string]ratelimiter.TenantLimits, error) {
+	keys := make(map[string]string)
+	overrides := make(map[string]ratelimiter.TenantLimits)
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		owner, key, ok := strings.Cut(entry, ":")
+		if !ok || owner == "" || key == "" {
+			return nil, nil, fmt.Errorf("API_KEYS: entry %q must be owner:key", entry)
+		}
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			if limits, ok := parseTenantLimits(key[i+1:]); ok {
+				if prev, set := overrides[owner]; set && prev != limits {
+					return nil, nil, fmt.Errorf("API_KEYS: conflicting rate limits for owner %q", owner)
+				}
+				overrides[owner] = limits
+				key = key[:i]
+			}
+		}
+		if key == "" {
+			return nil, nil, fmt.Errorf("API_KEYS: entry %q must be owner:key", entry)
+		}
+		if _, dup := keys[key]; dup {
+			return nil, nil, fmt.Errorf("API_KEYS: key for owner %q is already assigned", owner)
+		}
+		keys[key] = owner
+	}
+	return keys, overrides, nil
+}
+
+// parseTenantLimits parses "requests/notifications" per-minute limits.
+func parseTenantLimits(v string) (ratelimiter.TenantLimits, bool) {
+	reqs, notifs, ok := strings.Cut(v, "/")
+	if !ok {
+		return ratelimiter.TenantLimits{}, false
+	}
+	r, err1 := strconv.Atoi(reqs)
+	n, err2 := strconv.Atoi(notifs)
+	if err1 != nil || err2 != nil || r < 0 || n < 0 {
+		return ratelimiter.TenantLimits{}, false
+	}
+	return ratelimiter.TenantLimits{RequestsPerMinute: r, NotificationsPerMinute: n}, true
 }
 
-func getEnv(key, defaultVal string) string {
+// parseContentLimits parses "channel:limit" pairs separated by commas on top
+// of base.
+func parseContentLimits(v string, base map[domain.Channel]int) (map[domain.Channel]int, error) {
+	limits := make(map[domain.Channel]int, len(base))
+	for ch, limit := range base {
+		limits[ch] = limit
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, ":")
+		ch := domain.Channel(strings.TrimSpace(name))
+		if !ok || !ch.IsValid() {
+			return nil, fmt.Errorf("CONTENT_LIMITS: entry %q must be channel:limit with channel sms, email, or push", entry)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("CONTENT_LIMITS: limit for %s must be a positive integer, got %q", ch, value)
+		}
+		limits[ch] = limit
+	}
+	return limits, nil
+}
+
+// parseRecipientLimits parses "channel:count/period" entries separated by
+// commas, such as "sms:3/1m", on top of base.
+func parseRecipientLimits(v string, base map[domain.Channel]ratelimiter.RecipientLimit) (map[domain.Channel]ratelimiter.RecipientLimit, error) {
+	limits := make(map[domain.Channel]ratelimiter.RecipientLimit, len(base))
+	for ch, limit := range base {
+		limits[ch] = limit
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, ":")
+		ch := domain.Channel(strings.TrimSpace(name))
+		if !ok || !ch.IsValid() {
+			return nil, fmt.Errorf("RECIPIENT_LIMITS: entry %q must be channel:count/period with channel sms, email, or push", entry)
+		}
+		count, period, ok := strings.Cut(value, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || n <= 0 {
+			return nil, fmt.Errorf("RECIPIENT_LIMITS: limit for %s must be a positive count/period such as 3/1m, got %q", ch, value)
+		}
+		per, err := time.ParseDuration(strings.TrimSpace(period))
+		if err != nil || per <= 0 {
+			return nil, fmt.Errorf("RECIPIENT_LIMITS: period for %s must be a positive duration, got %q", ch, period)
+		}
+		limits[ch] = ratelimiter.RecipientLimit{Count: n, Per: per}
+	}
+	return limits, nil
+}
+
+// defaultDeliveryBuckets span an immediate send to one held back for hours.
+var defaultDeliveryBuckets = []time.Duration{
+	time.Second, 5 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// parseBuckets parses comma-separated durations in ascending order, as
+// histogram bucket bounds for the variable name.
+func parseBuckets(name, v string, defaultVal []time.Duration) ([]time.Duration, error) {
+	if strings.TrimSpace(v) == "" {
+		return defaultVal, nil
+	}
+	var buckets []time.Duration
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		d, err := time.ParseDuration(entry)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s: %q must be a positive duration", name, entry)
+		}
+		if n := len(buckets); n > 0 && d <= buckets[n-1] {
+			return nil, fmt.Errorf("%s: buckets must be in ascending order, %s follows %s", name, d, buckets[n-1])
+		}
+		buckets = append(buckets, d)
+	}
+	return buckets, nil
+}
+
+// parseBackoff parses a comma-separated retry schedule, such as
+// "5s,30s,2m,10m,1h", for the variable name. Unlike bucket bounds the delays
+// need not grow, but there must be at least one.
+func parseBackoff(name, v string) ([]time.Duration, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var backoff []time.Duration
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		d, err := time.ParseDuration(entry)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s: %q must be a positive duration", name, entry)
+		}
+		backoff = append(backoff, d)
+	}
+	return backoff, nil
+}
+
+// defaultLatencyBuckets extend Prometheus' default buckets past 10s, which
+// slow email sends regularly exceed.
+var defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60}
+
+// parseSecondsBuckets parses comma-separated seconds in ascending order, as
+// histogram bucket bounds for the variable name.
+func parseSecondsBuckets(name, v string, defaultVal []float64) ([]float64, error) {
+	if strings.TrimSpace(v) == "" {
+		return defaultVal, nil
+	}
+	var buckets []float64
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(entry, 64)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("%s: %q must be a positive number of seconds", name, entry)
+		}
+		if n := len(buckets); n > 0 && f <= buckets[n-1] {
+			return nil, fmt.Errorf("%s: buckets must be in ascending order, %v follows %v", name, f, buckets[n-1])
+		}
+		buckets = append(buckets, f)
+	}
+	return buckets, nil
+}
+
+// env reads typed settings from the environment. A value that does not parse
+// is recorded as an error instead of silently becoming the default.
+type env struct {
+	errs []error
+}
+
+// fail records err, if any.
+func (e *env) fail(err error) {
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+}
+
+func (e *env) str(key, defaultVal string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return defaultVal
 }
 
-func getInt(key string, defaultVal int) int {
-	if v := os.Getenv(key); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			return n
-		}
+func (e *env) int(key string, defaultVal int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal
 	}
-	return defaultVal
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be an integer, got %q", key, v))
+		return defaultVal
+	}
+	return n
 }
 
-func getDuration(key string, defaultVal time.Duration) time.Duration {
-	if v := os.Getenv(key); v != "" {
-		if d, err := time.ParseDuration(v); err == nil {
-			return d
+func (e *env) float(key string, defaultVal float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be a number, got %q", key, v))
+		return defaultVal
+	}
+	return f
+}
+
+func (e *env) bool(key string, defaultVal bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be true or false, got %q", key, v))
+		return defaultVal
+	}
+	return b
+}
+
+// list parses a comma-separated list, dropping empty entries.
+func (e *env) list(key string, defaultVal []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
 		}
 	}
-	return defaultVal
+	return list
+}
+
+func (e *env) duration(key string, defaultVal time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.fail(fmt.Errorf("%s must be a duration such as 500ms or 30s, got %q", key, v))
+		return defaultVal
+	}
+	return d
 }
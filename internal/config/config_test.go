@@ -0,0 +1,322 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+)
+
+func loadDefaults(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected the defaults to be valid, got %v", err)
+	}
+	return cfg
+}
+
+func TestLoad_ReportsEveryUnparsableValue(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+	t.Setenv("RATE_LIMIT_PER_CHANNEL", "fast")
+	t.Setenv("SCHEDULER_INTERVAL", "soon")
+	t.Setenv("STRICT_ENQUEUE", "maybe")
+	t.Setenv("TRACE_SAMPLE_RATIO", "half")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"RATE_LIMIT_PER_CHANNEL", "SCHEDULER_INTERVAL", "STRICT_ENQUEUE", "TRACE_SAMPLE_RATIO"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected %s to be reported, got %v", key, err)
+		}
+	}
+}
+
+func TestLoad_JoinsParseAndValidationErrors(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("SMS_WORKERS", "five")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_URL is required") || !strings.Contains(err.Error(), "SMS_WORKERS") {
+		t.Fatalf("expected both problems reported, got %v", err)
+	}
+}
+
+func TestLoad_RetryBackoffList(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+	t.Setenv("RETRY_BACKOFF", "5s, 30s,2m,10m,1h")
+	t.Setenv("RETRY_BACKOFF_1", "1s") // legacy, ignored when the list is set
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour}
+	if !slices.Equal(cfg.RetryBackoff, want) {
+		t.Fatalf("expected %v, got %v", want, cfg.RetryBackoff)
+	}
+}
+
+func TestLoad_RetryBackoffFallsBackToLegacyVariables(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+	t.Setenv("RETRY_BACKOFF_2", "45s")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{5 * time.Second, 45 * time.Second, 120 * time.Second}
+	if !slices.Equal(cfg.RetryBackoff, want) {
+		t.Fatalf("expected %v, got %v", want, cfg.RetryBackoff)
+	}
+}
+
+func TestLoad_RetryBackoffRejectsBadEntries(t *testing.T) {
+	for _, v := range []string{"5s,soon", "5s,,30s", "5s,-1s", " "} {
+		t.Run(v, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+			t.Setenv("RETRY_BACKOFF", v)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RETRY_BACKOFF") {
+				t.Fatalf("expected RETRY_BACKOFF=%q to be rejected, got %v", v, err)
+			}
+		})
+	}
+}
+
+func TestLoad_RecipientLimits(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/notifications")
+	t.Setenv("RECIPIENT_LIMITS", "sms:3/1m, email:10/1h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelSMS:   {Count: 3, Per: time.Minute},
+		domain.ChannelEmail: {Count: 10, Per: time.Hour},
+	}
+	if len(cfg.RecipientLimits) != len(want) || cfg.RecipientLimits[domain.ChannelSMS] != want[domain.ChannelSMS] ||
+		cfg.RecipientLimits[domain.ChannelEmail] != want[domain.ChannelEmail] {
+		t.Fatalf("expected %v, got %v", want, cfg.RecipientLimits)
+	}
+
+	for _, v := range []string{"sms:3", "fax:3/1m", "sms:0/1m", "sms:3/soon", "sms:3/-1m"} {
+		t.Run(v, func(t *testing.T) {
+			t.Setenv("RECIPIENT_LIMITS", v)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RECIPIENT_LIMITS") {
+				t.Fatalf("expected RECIPIENT_LIMITS=%q to be rejected, got %v", v, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	base := loadDefaults(t)
+
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"database url", func(c *Config) { c.DatabaseURL = "" }, "DATABASE_URL is required"},
+		{"log level", func(c *Config) { c.LogLevel = "loud" }, "LOG_LEVEL"},
+		{"tls key missing", func(c *Config) { c.TLSCertFile = "cert.pem" }, "TLS_KEY_FILE"},
+		{"tls two sources", func(c *Config) {
+			c.TLSCertFile, c.TLSKeyFile = "cert.pem", "key.pem"
+			c.TLSAutocertDomains = []string{"notify.example.com"}
+		}, "TLS_AUTOCERT_DOMAINS"},
+		{"autocert cache", func(c *Config) {
+			c.TLSAutocertDomains = []string{"notify.example.com"}
+			c.TLSAutocertCacheDir = ""
+		}, "TLS_AUTOCERT_CACHE_DIR"},
+		{"redirect without tls", func(c *Config) { c.HTTPRedirectPort = "80" }, "HTTP_REDIRECT_PORT needs"},
+		{"redirect port clash", func(c *Config) {
+			c.TLSCertFile, c.TLSKeyFile = "cert.pem", "key.pem"
+			c.HTTPRedirectPort = c.HTTPPort
+		}, "HTTP_REDIRECT_PORT must differ"},
+		{"admin key reused", func(c *Config) {
+			c.APIKeys = map[string]string{"k": "alice"}
+			c.AdminAPIKeys = []string{"k"}
+		}, "ADMIN_API_KEYS"},
+		{"admin port without pprof", func(c *Config) { c.AdminPort = "6060" }, "set ENABLE_PPROF"},
+		{"admin port clash", func(c *Config) {
+			c.EnablePprof = true
+			c.AdminPort = c.HTTPPort
+		}, "ADMIN_PORT must differ"},
+		{"pprof without admin", func(c *Config) {
+			c.EnablePprof = true
+			c.AdminAPIKeys = nil
+		}, "ENABLE_PPROF needs"},
+		{"db max conns", func(c *Config) { c.DBMaxConns = 0 }, "DB_MAX_CONNS"},
+		{"db min conns", func(c *Config) { c.DBMinConns = c.DBMaxConns + 1 }, "DB_MIN_CONNS"},
+		{"db startup wait", func(c *Config) { c.DBStartupMaxWait = -time.Second }, "DB_STARTUP_MAX_WAIT"},
+		{"migration lock timeout", func(c *Config) { c.DBMigrationLockTimeout = 0 }, "DB_MIGRATION_LOCK_TIMEOUT"},
+		{"statement cache mode", func(c *Config) { c.DBStatementCacheMode = "transaction" }, "DB_STATEMENT_CACHE_MODE"},
+		{"statement timeout", func(c *Config) { c.DBStatementTimeout = c.WriteTimeout }, "DB_STATEMENT_TIMEOUT"},
+		{"conn max lifetime", func(c *Config) { c.DBConnMaxLifetime = 0 }, "DB_CONN_MAX_LIFETIME"},
+		{"healthcheck period", func(c *Config) { c.DBHealthcheckPeriod = -time.Second }, "DB_HEALTHCHECK_PERIOD"},
+		{"db query retries", func(c *Config) { c.DBQueryRetries = -1 }, "DB_QUERY_RETRIES"},
+		{"page size", func(c *Config) { c.MaxPageSize = 0 }, "MAX_PAGE_SIZE"},
+		{"lookup ids", func(c *Config) { c.MaxLookupIDs = 0 }, "MAX_LOOKUP_IDS"},
+		{"body bytes", func(c *Config) { c.MaxBodyBytes = 0 }, "MAX_BODY_BYTES"},
+		{"batch body bytes", func(c *Config) { c.MaxBatchBodyBytes = -1 }, "MAX_BATCH_BODY_BYTES"},
+		{"sms workers", func(c *Config) { c.SMSWorkers = 0 }, "SMS_WORKERS"},
+		{"email workers", func(c *Config) { c.EmailWorkers = -2 }, "EMAIL_WORKERS"},
+		{"push workers", func(c *Config) { c.PushWorkers = 0 }, "PUSH_WORKERS"},
+		{"rate limit", func(c *Config) { c.RateLimit = 0 }, "RATE_LIMIT_PER_CHANNEL"},
+		{"callback attempts", func(c *Config) { c.CallbackMaxAttempts = 0 }, "CALLBACK_MAX_ATTEMPTS"},
+		{"callback concurrency", func(c *Config) { c.CallbackConcurrency = 0 }, "CALLBACK_CONCURRENCY"},
+		{"ready percent", func(c *Config) { c.ReadyQueueMaxPercent = 101 }, "READY_QUEUE_MAX_PERCENT"},
+		{"handler timeout", func(c *Config) { c.HandlerTimeout = c.WriteTimeout }, "HANDLER_TIMEOUT must be below WRITE_TIMEOUT"},
+		{"batch handler timeout", func(c *Config) { c.BatchHandlerTimeout = c.WriteTimeout + time.Second }, "BATCH_HANDLER_TIMEOUT"},
+		{"provider timeout", func(c *Config) { c.ProviderTimeout = 0 }, "PROVIDER_TIMEOUT must be positive"},
+		{"shutdown timeout", func(c *Config) { c.ShutdownTimeout = c.ProviderTimeout - time.Second }, "SHUTDOWN_TIMEOUT"},
+		{"retry backoff", func(c *Config) { c.RetryBackoff = []time.Duration{time.Second, 0} }, "RETRY_BACKOFF_2"},
+		{"default max retries", func(c *Config) { c.DefaultMaxRetries = 11 }, "DEFAULT_MAX_RETRIES"},
+		{"negative max retries", func(c *Config) { c.DefaultMaxRetries = -1 }, "DEFAULT_MAX_RETRIES"},
+		{"country code", func(c *Config) { c.DefaultCountryCode = "+90" }, "DEFAULT_COUNTRY_CODE"},
+		{"empty retry backoff", func(c *Config) { c.RetryBackoff = nil }, "RETRY_BACKOFF must list at least one delay"},
+		{"scheduler interval", func(c *Config) { c.SchedulerInterval = 0 }, "SCHEDULER_INTERVAL"},
+		{"retry interval", func(c *Config) { c.RetryInterval = -time.Second }, "RETRY_INTERVAL"},
+		{"poll page size", func(c *Config) { c.PollPageSize = 0 }, "POLL_PAGE_SIZE"},
+		{"callback poll interval", func(c *Config) { c.CallbackPollInterval = 0 }, "CALLBACK_POLL_INTERVAL"},
+		{"partition interval", func(c *Config) {
+			c.PartitionNotifications = true
+			c.PartitionMaintenanceEvery = 0
+		}, "PARTITION_MAINTENANCE_INTERVAL"},
+		{"leader check interval", func(c *Config) {
+			c.LeaderElection = true
+			c.LeaderCheckInterval = 0
+		}, "LEADER_CHECK_INTERVAL"},
+		{"recipient limit", func(c *Config) {
+			c.RecipientLimits = map[domain.Channel]ratelimiter.RecipientLimit{domain.ChannelSMS: {Count: 3}}
+		}, "RECIPIENT_LIMITS"},
+		{"recipient limiter max keys", func(c *Config) {
+			c.RecipientLimits = map[domain.Channel]ratelimiter.RecipientLimit{domain.ChannelSMS: {Count: 3, Per: time.Minute}}
+			c.RecipientLimiterMaxKeys = 0
+		}, "RECIPIENT_LIMITER_MAX_KEYS"},
+		{"status count interval", func(c *Config) { c.StatusCountInterval = -time.Second }, "STATUS_COUNT_INTERVAL"},
+		{"event publisher", func(c *Config) { c.EventPublisher = "sqs" }, "EVENT_PUBLISHER"},
+		{"kafka url", func(c *Config) { c.EventPublisher = "kafka" }, "KAFKA_REST_URL"},
+		{"audit buffer size", func(c *Config) { c.AuditBufferSize = 0 }, "AUDIT_BUFFER_SIZE"},
+		{"sample ratio", func(c *Config) { c.TraceSampleRatio = 1.5 }, "TRACE_SAMPLE_RATIO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *base
+			tt.mutate(&c)
+			err := c.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ListsEveryProblem(t *testing.T) {
+	c := *loadDefaults(t)
+	c.SMSWorkers = 0
+	c.RateLimit = 0
+	c.ShutdownTimeout = time.Second
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(lines), err)
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFrom_EnvironmentOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, `
+database_url: postgres://file/notifications
+rate_limit_per_channel: 40
+scheduler_interval: 2s
+retry_backoff: [1s, 10s]
+api_keys:
+  file-key: alice
+tenant_limits:
+  requests_per_minute: 60
+content_limits:
+  sms: 320
+`)
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("RATE_LIMIT_PER_CHANNEL", "250")
+	t.Setenv("RETRY_BACKOFF_2", "45s")
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit != 250 {
+		t.Errorf("expected the environment to win, got rate limit %d", cfg.RateLimit)
+	}
+	if cfg.DatabaseURL != "postgres://file/notifications" || cfg.SchedulerInterval != 2*time.Second {
+		t.Errorf("expected the file's values where the environment is unset, got %q and %s", cfg.DatabaseURL, cfg.SchedulerInterval)
+	}
+	if len(cfg.RetryBackoff) != 2 || cfg.RetryBackoff[0] != time.Second || cfg.RetryBackoff[1] != 45*time.Second {
+		t.Errorf("unexpected retry backoff %v", cfg.RetryBackoff)
+	}
+	if cfg.APIKeys["file-key"] != "alice" {
+		t.Errorf("expected the file's API keys, got %v", cfg.APIKeys)
+	}
+	if cfg.TenantLimits != (ratelimiter.TenantLimits{RequestsPerMinute: 60, NotificationsPerMinute: 10000}) {
+		t.Errorf("expected the file to override one tenant limit, got %+v", cfg.TenantLimits)
+	}
+	if cfg.ContentLimits[domain.ChannelSMS] != 320 || cfg.ContentLimits[domain.ChannelPush] != domain.DefaultContentLimits[domain.ChannelPush] {
+		t.Errorf("expected the file to override only the sms content limit, got %v", cfg.ContentLimits)
+	}
+}
+
+func TestLoadFrom_PartialFileKeepsDefaults(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://env/notifications")
+	path := writeConfigFile(t, "sms_workers: 2\n")
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := loadDefaults(t)
+	if cfg.SMSWorkers != 2 || cfg.EmailWorkers != want.EmailWorkers || cfg.HTTPPort != want.HTTPPort ||
+		cfg.ShutdownTimeout != want.ShutdownTimeout || len(cfg.RetryBackoff) != len(want.RetryBackoff) {
+		t.Fatalf("expected defaults around the one file setting, got %+v", cfg)
+	}
+}
+
+func TestLoadFrom_EmptyFile(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://env/notifications")
+	if _, err := LoadFrom(writeConfigFile(t, "")); err != nil {
+		t.Fatalf("expected an empty file to mean all defaults, got %v", err)
+	}
+}
+
+func TestLoadFrom_RejectsUnknownKeysAndValidates(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://env/notifications")
+	if _, err := LoadFrom(writeConfigFile(t, "rate_limit: 10\n")); err == nil || !strings.Contains(err.Error(), "rate_limit") {
+		t.Fatalf("expected the misspelt key to be reported, got %v", err)
+	}
+	if _, err := LoadFrom(writeConfigFile(t, "sms_workers: 0\n")); err == nil || !strings.Contains(err.Error(), "SMS_WORKERS") {
+		t.Fatalf("expected file values to be validated, got %v", err)
+	}
+	if _, err := LoadFrom(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected a missing file to be an error")
+	}
+}
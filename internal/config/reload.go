@@ -0,0 +1,113 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ReloadTargets receive the settings that can change while the service runs.
+// A nil target leaves its setting to the next restart.
+type ReloadTargets struct {
+	SetRateLimit         func(perSec int)              // RATE_LIMIT_PER_CHANNEL
+	SetRetryBackoff      func(backoff []time.Duration) // RETRY_BACKOFF
+	SetSchedulerInterval func(d time.Duration)         // SCHEDULER_INTERVAL
+	SetRetryInterval     func(d time.Duration)         // RETRY_INTERVAL
+	SetLogLevel          func(level zapcore.Level)     // LOG_LEVEL
+}
+
+// ReloadResult lists, by key, the settings a reload applied and the changed
+// ones it could not, which wait for a restart.
+type ReloadResult struct {
+	Applied []string `json:"applied"`
+	Ignored []string `json:"ignored"`
+}
+
+// Reloader re-reads the configuration on demand (SIGHUP, or the admin reload
+// endpoint) and applies what it can to the running service.
+type Reloader struct {
+	load    func() (*Config, error)
+	targets ReloadTargets
+	logger  *zap.Logger
+
+	mu      sync.Mutex
+	current *Config
+}
+
+// NewReloader returns a reloader for the service started with current. load
+// must read the configuration the way startup did, file and all.
+func NewReloader(current *Config, load func() (*Config, error), targets ReloadTargets, logger *zap.Logger) *Reloader {
+	return &Reloader{load: load, targets: targets, logger: logger, current: current}
+}
+
+// Reload loads the configuration again and applies the reloadable settings
+// that changed. A configuration that fails to load or validate is rejected
+// whole, and the running settings stay as they are.
+func (r *Reloader) Reload() (ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		r.logger.Error("config reload rejected; keeping the running settings", zap.Error(err))
+		return ReloadResult{}, err
+	}
+
+	cur := *r.current
+	res := ReloadResult{Applied: []string{}, Ignored: []string{}}
+	t := r.targets
+	if next.RateLimit != cur.RateLimit && t.SetRateLimit != nil {
+		t.SetRateLimit(next.RateLimit)
+		cur.RateLimit = next.RateLimit
+		res.Applied = append(res.Applied, "RATE_LIMIT_PER_CHANNEL")
+	}
+	if !slices.Equal(next.RetryBackoff, cur.RetryBackoff) && t.SetRetryBackoff != nil {
+		t.SetRetryBackoff(next.RetryBackoff)
+		cur.RetryBackoff = next.RetryBackoff
+		res.Applied = append(res.Applied, "RETRY_BACKOFF")
+	}
+	if next.SchedulerInterval != cur.SchedulerInterval && t.SetSchedulerInterval != nil {
+		t.SetSchedulerInterval(next.SchedulerInterval)
+		cur.SchedulerInterval = next.SchedulerInterval
+		res.Applied = append(res.Applied, "SCHEDULER_INTERVAL")
+	}
+	if next.RetryInterval != cur.RetryInterval && t.SetRetryInterval != nil {
+		t.SetRetryInterval(next.RetryInterval)
+		cur.RetryInterval = next.RetryInterval
+		res.Applied = append(res.Applied, "RETRY_INTERVAL")
+	}
+	if next.LogLevel != cur.LogLevel && t.SetLogLevel != nil {
+		level, _ := zapcore.ParseLevel(next.LogLevel) // validated by load
+		t.SetLogLevel(level)
+		cur.LogLevel = next.LogLevel
+		res.Applied = append(res.Applied, "LOG_LEVEL")
+	}
+	res.Ignored = changedSettings(&cur, next)
+	r.current = &cur
+
+	if len(res.Applied) > 0 {
+		r.logger.Info("config reloaded", zap.Strings("applied", res.Applied))
+	}
+	if len(res.Ignored) > 0 {
+		r.logger.Warn("config changes need a restart to take effect; ignored", zap.Strings("ignored", res.Ignored))
+	}
+	return res, nil
+}
+
+// changedSettings returns the keys, in upper case, of the settings that
+// differ between a and b.
+func changedSettings(a, b *Config) []string {
+	changed := []string{}
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, strings.ToUpper(va.Type().Field(i).Tag.Get("yaml")))
+		}
+	}
+	return changed
+}
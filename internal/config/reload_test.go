@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordingTargets remembers what a reload handed to each target.
+type recordingTargets struct {
+	rate             int
+	backoff          []time.Duration
+	scheduler, retry time.Duration
+	level            zapcore.Level
+	calls            int
+}
+
+func (r *recordingTargets) targets() ReloadTargets {
+	return ReloadTargets{
+		SetRateLimit:         func(n int) { r.rate = n; r.calls++ },
+		SetRetryBackoff:      func(b []time.Duration) { r.backoff = b; r.calls++ },
+		SetSchedulerInterval: func(d time.Duration) { r.scheduler = d; r.calls++ },
+		SetRetryInterval:     func(d time.Duration) { r.retry = d; r.calls++ },
+		SetLogLevel:          func(l zapcore.Level) { r.level = l; r.calls++ },
+	}
+}
+
+func TestReloader_AppliesEachReloadableSetting(t *testing.T) {
+	tests := []struct {
+		key    string
+		mutate func(c *Config)
+		check  func(r *recordingTargets) bool
+	}{
+		{"RATE_LIMIT_PER_CHANNEL", func(c *Config) { c.RateLimit = 7 }, func(r *recordingTargets) bool { return r.rate == 7 }},
+		{"RETRY_BACKOFF", func(c *Config) { c.RetryBackoff = []time.Duration{time.Second} },
+			func(r *recordingTargets) bool { return slices.Equal(r.backoff, []time.Duration{time.Second}) }},
+		{"SCHEDULER_INTERVAL", func(c *Config) { c.SchedulerInterval = time.Minute }, func(r *recordingTargets) bool { return r.scheduler == time.Minute }},
+		{"RETRY_INTERVAL", func(c *Config) { c.RetryInterval = time.Minute }, func(r *recordingTargets) bool { return r.retry == time.Minute }},
+		{"LOG_LEVEL", func(c *Config) { c.LogLevel = "debug" }, func(r *recordingTargets) bool { return r.level == zapcore.DebugLevel }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			current := loadDefaults(t)
+			next := *current
+			tt.mutate(&next)
+			rec := &recordingTargets{}
+			r := NewReloader(current, func() (*Config, error) { c := next; return &c, nil }, rec.targets(), zap.NewNop())
+
+			res, err := r.Reload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.Applied, []string{tt.key}) || len(res.Ignored) != 0 || rec.calls != 1 || !tt.check(rec) {
+				t.Fatalf("unexpected result %+v, targets %+v", res, rec)
+			}
+
+			// Nothing changed since: a second reload applies nothing.
+			if res, _ := r.Reload(); len(res.Applied) != 0 || rec.calls != 1 {
+				t.Fatalf("expected an unchanged reload to apply nothing, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestReloader_ReportsNonReloadableChanges(t *testing.T) {
+	current := loadDefaults(t)
+	next := *current
+	next.HTTPPort = "9090"
+	next.DatabaseURL = "postgres://elsewhere/notifications"
+	next.RateLimit = 5
+	rec := &recordingTargets{}
+	r := NewReloader(current, func() (*Config, error) { return &next, nil }, rec.targets(), zap.NewNop())
+
+	res, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Applied, []string{"RATE_LIMIT_PER_CHANNEL"}) || !slices.Equal(res.Ignored, []string{"HTTP_PORT", "DATABASE_URL"}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestReloader_RejectsInvalidConfig(t *testing.T) {
+	current := loadDefaults(t)
+	rec := &recordingTargets{}
+	r := NewReloader(current, func() (*Config, error) { return nil, errors.New("SMS_WORKERS must be a positive integer") }, rec.targets(), zap.NewNop())
+
+	if _, err := r.Reload(); err == nil || rec.calls != 0 {
+		t.Fatalf("expected the reload to fail without touching anything, got %v and %d calls", err, rec.calls)
+	}
+}
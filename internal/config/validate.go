@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// setting names a duration for an error message.
+type setting struct {
+	name string
+	d    time.Duration
+}
+
+// Validate checks the settings against each other and against the ranges the
+// rest of the service relies on. All problems are returned joined, so a
+// misconfigured deployment is told about every one of them at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DatabaseURL != "", "DATABASE_URL is required")
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(c.TLSCertFile == "" || len(c.TLSAutocertDomains) == 0, "TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are alternatives; set one")
+	check(len(c.TLSAutocertDomains) == 0 || c.TLSAutocertCacheDir != "", "TLS_AUTOCERT_CACHE_DIR is required with TLS_AUTOCERT_DOMAINS")
+	if c.HTTPRedirectPort != "" {
+		check(c.TLSCertFile != "" || len(c.TLSAutocertDomains) > 0, "HTTP_REDIRECT_PORT needs TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS")
+		check(c.HTTPRedirectPort != c.HTTPPort, "HTTP_REDIRECT_PORT must differ from HTTP_PORT")
+	}
+	if c.AdminPort != "" {
+		check(c.EnablePprof, "ADMIN_PORT only serves the profiler; set ENABLE_PPROF too")
+		check(c.AdminPort != c.HTTPPort && c.AdminPort != c.HTTPRedirectPort,
+			"ADMIN_PORT must differ from HTTP_PORT and HTTP_REDIRECT_PORT")
+	} else if c.EnablePprof {
+		check(len(c.AdminAPIKeys) > 0, "ENABLE_PPROF needs ADMIN_PORT or ADMIN_API_KEYS")
+	}
+	_, err := zapcore.ParseLevel(c.LogLevel)
+	check(err == nil, "LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	for _, key := range c.AdminAPIKeys {
+		if _, taken := c.APIKeys[key]; taken {
+			errs = append(errs, errors.New("ADMIN_API_KEYS: key is also listed in API_KEYS"))
+			break
+		}
+	}
+	check(c.DBMaxConns > 0, "DB_MAX_CONNS must be a positive integer, got %d", c.DBMaxConns)
+	check(c.DBMinConns >= 0 && c.DBMinConns <= c.DBMaxConns,
+		"DB_MIN_CONNS must be between 0 and DB_MAX_CONNS (%d), got %d", c.DBMaxConns, c.DBMinConns)
+	check(c.DBStartupMaxWait >= 0, "DB_STARTUP_MAX_WAIT must not be negative, got %s", c.DBStartupMaxWait)
+	check(c.DBMigrationLockTimeout > 0, "DB_MIGRATION_LOCK_TIMEOUT must be positive, got %s", c.DBMigrationLockTimeout)
+	check(c.DBStatementCacheMode == "prepare" || c.DBStatementCacheMode == "describe" || c.DBStatementCacheMode == "simple",
+		"DB_STATEMENT_CACHE_MODE must be prepare, describe or simple, got %q", c.DBStatementCacheMode)
+	check(c.DBStatementTimeout >= 0, "DB_STATEMENT_TIMEOUT must not be negative, got %s", c.DBStatementTimeout)
+	check(c.DBStatementTimeout <= 0 || c.WriteTimeout <= 0 || c.DBStatementTimeout < c.WriteTimeout,
+		"DB_STATEMENT_TIMEOUT must be below WRITE_TIMEOUT (%s), got %s", c.WriteTimeout, c.DBStatementTimeout)
+	for _, v := range []setting{
+		{"DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTime},
+		{"DB_HEALTHCHECK_PERIOD", c.DBHealthcheckPeriod},
+	} {
+		check(v.d > 0, "%s must be positive, got %s", v.name, v.d)
+	}
+	check(c.DBQueryRetries >= 0, "DB_QUERY_RETRIES must not be negative, got %d", c.DBQueryRetries)
+
+	for _, v := range []struct {
+		name string
+		n    int64
+	}{
+		{"MAX_PAGE_SIZE", int64(c.MaxPageSize)},
+		{"MAX_LOOKUP_IDS", int64(c.MaxLookupIDs)},
+		{"MAX_BODY_BYTES", c.MaxBodyBytes},
+		{"MAX_BATCH_BODY_BYTES", c.MaxBatchBodyBytes},
+		{"SMS_WORKERS", int64(c.SMSWorkers)},
+		{"EMAIL_WORKERS", int64(c.EmailWorkers)},
+		{"PUSH_WORKERS", int64(c.PushWorkers)},
+		{"RATE_LIMIT_PER_CHANNEL", int64(c.RateLimit)},
+		{"CALLBACK_MAX_ATTEMPTS", int64(c.CallbackMaxAttempts)},
+		{"CALLBACK_CONCURRENCY", int64(c.CallbackConcurrency)},
+		{"POLL_PAGE_SIZE", int64(c.PollPageSize)},
+	} {
+		check(v.n > 0, "%s must be a positive integer, got %d", v.name, v.n)
+	}
+	for ch, limit := range c.RecipientLimits {
+		check(ch.IsValid(), "RECIPIENT_LIMITS: unknown channel %q", ch)
+		check(limit.Count > 0 && limit.Per > 0,
+			"RECIPIENT_LIMITS: limit for %s must have a positive count and period, got %d/%s", ch, limit.Count, limit.Per)
+	}
+	if len(c.RecipientLimits) > 0 {
+		check(c.RecipientLimiterMaxKeys > 0,
+			"RECIPIENT_LIMITER_MAX_KEYS must be a positive integer, got %d", c.RecipientLimiterMaxKeys)
+	}
+	check(c.ReadyQueueMaxPercent > 0 && c.ReadyQueueMaxPercent <= 100,
+		"READY_QUEUE_MAX_PERCENT must be between 1 and 100, got %d", c.ReadyQueueMaxPercent)
+
+	for _, v := range []setting{{"HANDLER_TIMEOUT", c.HandlerTimeout}, {"BATCH_HANDLER_TIMEOUT", c.BatchHandlerTimeout}} {
+		check(c.WriteTimeout <= 0 || v.d < c.WriteTimeout, "%s must be below WRITE_TIMEOUT (%s), got %s", v.name, c.WriteTimeout, v.d)
+	}
+	check(c.ProviderTimeout > 0, "PROVIDER_TIMEOUT must be positive, got %s", c.ProviderTimeout)
+	check(c.ShutdownTimeout >= c.ProviderTimeout,
+		"SHUTDOWN_TIMEOUT (%s) must not be shorter than PROVIDER_TIMEOUT (%s), or in-flight sends are cut off", c.ShutdownTimeout, c.ProviderTimeout)
+	check(c.DefaultMaxRetries >= 0 && c.DefaultMaxRetries <= domain.MaxRetriesLimit,
+		"DEFAULT_MAX_RETRIES must be between 0 and %d, got %d", domain.MaxRetriesLimit, c.DefaultMaxRetries)
+	check(c.DefaultCountryCode == "" || validCountryCode(c.DefaultCountryCode),
+		"DEFAULT_COUNTRY_CODE must be 1 to 3 digits without a leading 0 or +, got %q", c.DefaultCountryCode)
+	check(len(c.RetryBackoff) > 0, "RETRY_BACKOFF must list at least one delay")
+	for i, d := range c.RetryBackoff {
+		check(d > 0, "RETRY_BACKOFF_%d must be positive, got %s", i+1, d)
+	}
+
+	// These drive tickers, which cannot run at a zero interval.
+	intervals := []setting{
+		{"SCHEDULER_INTERVAL", c.SchedulerInterval},
+		{"RETRY_INTERVAL", c.RetryInterval},
+		{"CALLBACK_POLL_INTERVAL", c.CallbackPollInterval},
+	}
+	if c.PartitionNotifications {
+		intervals = append(intervals, setting{"PARTITION_MAINTENANCE_INTERVAL", c.PartitionMaintenanceEvery})
+	}
+	if c.LeaderElection {
+		intervals = append(intervals, setting{"LEADER_CHECK_INTERVAL", c.LeaderCheckInterval})
+	}
+	for _, v := range intervals {
+		check(v.d > 0, "%s must be positive, got %s", v.name, v.d)
+	}
+	check(c.StatusCountInterval >= 0, "STATUS_COUNT_INTERVAL must not be negative, got %s", c.StatusCountInterval)
+
+	switch c.EventPublisher {
+	case "none":
+	case "kafka":
+		check(c.KafkaRESTURL != "", "KAFKA_REST_URL is required when EVENT_PUBLISHER=kafka")
+	default:
+		errs = append(errs, fmt.Errorf("EVENT_PUBLISHER must be none or kafka, got %q", c.EventPublisher))
+	}
+	if c.AuditLog {
+		check(c.AuditBufferSize > 0, "AUDIT_BUFFER_SIZE must be a positive integer, got %d", c.AuditBufferSize)
+	}
+	check(c.TraceSampleRatio >= 0 && c.TraceSampleRatio <= 1,
+		"TRACE_SAMPLE_RATIO must be a number between 0 and 1, got %v", c.TraceSampleRatio)
+
+	return errors.Join(errs...)
+}
+
+// validCountryCode reports whether code looks like an E.164 calling code.
+func validCountryCode(code string) bool {
+	if len(code) < 1 || len(code) > 3 || code[0] == '0' {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// leaderLockKey is the advisory lock key held by the leader, the one
+// instance that runs the background pollers when leader election is on.
+const leaderLockKey int64 = 0x6e6f746966790002
+
+// leaderConn is the part of *pgx.Conn the elector needs.
+type leaderConn interface {
+	lockConn
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// Elector campaigns for leadership among the instances sharing a database.
+// The leader is whichever instance holds the leader advisory lock; the lock
+// is held on a connection of its own, so it is released by Postgres as soon
+// as that session ends, and another instance takes over on its next attempt.
+type Elector struct {
+	connect   func(ctx context.Context) (leaderConn, error)
+	retry     time.Duration // between attempts to take the lock, or to reconnect
+	keepalive time.Duration // between pings of the leader's connection
+	onChange  func(leader bool)
+	logger    *zap.Logger
+	leader    atomic.Bool
+}
+
+// NewElector returns an elector connecting to databaseURL. interval is how
+// often a standby tries to take the lock and how often the leader pings its
+// connection to make sure it still holds it. onChange, when set, is told
+// about every change of leadership of this instance.
+func NewElector(databaseURL string, interval time.Duration, onChange func(leader bool), logger *zap.Logger) *Elector {
+	if onChange == nil {
+		onChange = func(bool) {}
+	}
+	return &Elector{
+		connect: func(ctx context.Context) (leaderConn, error) {
+			return pgx.Connect(ctx, databaseURL)
+		},
+		retry:     interval,
+		keepalive: interval,
+		onChange:  onChange,
+		logger:    logger,
+	}
+}
+
+// IsLeader reports whether this instance holds the leader lock.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is cancelled. Each time this instance is elected,
+// lead is called in a goroutine of its own with a context that is cancelled
+// when leadership is lost; the lock is only given up after lead returns.
+//
+// A leader that loses its connection steps down at its next ping, but
+// Postgres may already have handed the lock to another instance by then, so
+// what lead runs must tolerate a short overlap.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for {
+		conn, err := e.connect(ctx)
+		if err == nil {
+			e.campaign(ctx, conn, lead)
+			conn.Close(context.Background()) //nolint:errcheck // the session is over either way
+		} else if ctx.Err() == nil {
+			e.logger.Warn("leader election: cannot connect; retrying", zap.Error(err), zap.Duration("retry_in", e.retry))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.retry):
+		}
+	}
+}
+
+// campaign tries to take the lock on conn until it succeeds, then keeps the
+// connection alive while leading. It returns when ctx is cancelled or conn
+// fails, after stepping down if it led.
+func (e *Elector) campaign(ctx context.Context, conn leaderConn, lead func(context.Context)) {
+	var stepDown func()
+	defer func() {
+		if stepDown == nil {
+			return
+		}
+		stepDown()
+		if ctx.Err() == nil {
+			return // conn failed; its session, and the lock with it, are gone
+		}
+		unlockCtx, cancel := context.WithTimeout(context.Background(), e.keepalive)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, leaderLockKey); err != nil {
+			// Closing the connection releases it anyway.
+			e.logger.Warn("failed to release the leader lock", zap.Error(err))
+		}
+	}()
+
+	wait := e.retry
+	for {
+		if stepDown == nil {
+			var acquired bool
+			if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, leaderLockKey).Scan(&acquired); err != nil {
+				if ctx.Err() == nil {
+					e.logger.Warn("leader election: lock attempt failed; reconnecting", zap.Error(err))
+				}
+				return
+			}
+			if acquired {
+				stepDown = e.startTerm(ctx, lead)
+				wait = e.keepalive
+			}
+		} else if err := e.ping(ctx, conn); err != nil {
+			if ctx.Err() == nil {
+				e.logger.Error("leader lost its database connection; stepping down", zap.Error(err))
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// startTerm makes this instance the leader and runs lead. The returned
+// function ends the term, waiting for lead to return.
+func (e *Elector) startTerm(ctx context.Context, lead func(context.Context)) func() {
+	termCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	e.setLeader(true)
+	go func() {
+		defer close(done)
+		lead(termCtx)
+	}()
+	return func() {
+		cancel()
+		<-done
+		e.setLeader(false)
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.leader.Store(leader)
+	if leader {
+		e.logger.Info("elected leader; starting the background pollers")
+	} else {
+		e.logger.Info("no longer the leader; background pollers stopped")
+	}
+	e.onChange(leader)
+}
+
+func (e *Elector) ping(ctx context.Context, conn leaderConn) error {
+	ctx, cancel := context.WithTimeout(ctx, e.keepalive)
+	defer cancel()
+	return conn.Ping(ctx)
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap"
+)
+
+// newTestElector hands out conns in order, then cancels ctx and fails.
+func newTestElector(cancel context.CancelFunc, conns ...pgxmock.PgxConnIface) (*Elector, *[]bool) {
+	var changes []bool
+	e := &Elector{
+		retry:     5 * time.Millisecond,
+		keepalive: time.Hour,
+		onChange:  func(leader bool) { changes = append(changes, leader) },
+		logger:    zap.NewNop(),
+	}
+	e.connect = func(context.Context) (leaderConn, error) {
+		if len(conns) == 0 {
+			cancel()
+			return nil, errors.New("no more connections")
+		}
+		c := conns[0]
+		conns = conns[1:]
+		return c, nil
+	}
+	return e, &changes
+}
+
+func TestElector_LeadsUntilCancelledThenReleases(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(leaderLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(leaderLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(leaderLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectClose()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e, changes := newTestElector(cancel, mock)
+	var leaderDuringTerm, stopped bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(termCtx context.Context) {
+			leaderDuringTerm = e.IsLeader()
+			cancel()
+			<-termCtx.Done()
+			stopped = true
+		})
+	}()
+	<-done
+
+	if !leaderDuringTerm || !stopped {
+		t.Fatalf("expected lead to run as leader until cancelled, got leader=%v stopped=%v", leaderDuringTerm, stopped)
+	}
+	if e.IsLeader() {
+		t.Fatal("expected to have stepped down")
+	}
+	if len(*changes) != 2 || !(*changes)[0] || (*changes)[1] {
+		t.Fatalf("expected onChange(true) then onChange(false), got %v", *changes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestElector_StepsDownWhenPingFailsAndReconnects(t *testing.T) {
+	first, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(leaderLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	first.ExpectPing().WillReturnError(errors.New("connection reset"))
+	first.ExpectClose() // no unlock: the session, and the lock with it, are gone
+
+	second, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(leaderLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	second.ExpectClose()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, changes := newTestElector(cancel, first, second)
+	e.keepalive = 5 * time.Millisecond
+	terms := 0
+	e.Run(ctx, func(termCtx context.Context) {
+		terms++
+		<-termCtx.Done()
+	})
+
+	if terms != 1 || e.IsLeader() {
+		t.Fatalf("expected one term ended by the failed ping, got %d terms, leader=%v", terms, e.IsLeader())
+	}
+	if len(*changes) != 2 {
+		t.Fatalf("expected to be elected and step down once, got %v", *changes)
+	}
+	// The second connection found the lock taken; its next attempt, which
+	// the mock does not expect, failed and sent the elector to reconnect.
+	for i, m := range []pgxmock.PgxConnIface{first, second} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Fatalf("conn %d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// migrationLockKey is the Postgres advisory lock key every instance holds
+// while it migrates. Any fixed value works as long as nothing else uses it.
+const migrationLockKey int64 = 0x6e6f746966790001
+
+// WithMigrationLock runs fn while holding the migration advisory lock, so
+// replicas and init containers starting together migrate one at a time
+// instead of racing each other into a dirty schema. Waiting for another
+// instance is logged and gives up after timeout. The lock is held on a
+// connection of its own, opened to databaseURL.
+func WithMigrationLock(ctx context.Context, databaseURL string, timeout time.Duration, logger *zap.Logger, fn func() error) error {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return fmt.Errorf("migration lock: connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+	return withAdvisoryLock(ctx, conn, timeout, logger, fn)
+}
+
+// lockConn is the part of *pgx.Conn the advisory lock needs.
+type lockConn interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func withAdvisoryLock(ctx context.Context, conn lockConn, timeout time.Duration, logger *zap.Logger, fn func() error) error {
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockKey).Scan(&acquired); err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	if !acquired {
+		logger.Info("another instance is migrating; waiting for it", zap.Duration("timeout", timeout))
+		start := time.Now()
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		_, err := conn.Exec(waitCtx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("migration lock: still held by another instance after %s", timeout)
+			}
+			return fmt.Errorf("migration lock: %w", err)
+		}
+		logger.Info("migration lock acquired", zap.Duration("waited", time.Since(start)))
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			// Closing the connection releases it anyway.
+			logger.Warn("failed to release the migration lock", zap.Error(err))
+		}
+	}()
+	return fn()
+}
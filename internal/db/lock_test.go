@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithAdvisoryLock_FreeLockRunsAndReleases(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(migrationLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	ran := false
+	if err := withAdvisoryLock(context.Background(), mock, time.Second, zap.NewNop(), func() error {
+		ran = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("expected fn to run")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithAdvisoryLock_WaitsForHolderAndLogs(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(migrationLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	core, logs := observer.New(zap.InfoLevel)
+	if err := withAdvisoryLock(context.Background(), mock, time.Second, zap.New(core), func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if logs.FilterMessageSnippet("waiting for it").Len() != 1 {
+		t.Fatalf("expected the wait to be logged, got %v", logs.All())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithAdvisoryLock_GivesUpAfterTimeout(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).WithArgs(migrationLockKey).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1)).
+		WillDelayFor(200 * time.Millisecond)
+
+	err = withAdvisoryLock(context.Background(), mock, 20*time.Millisecond, zap.NewNop(), func() error {
+		t.Fatal("fn must not run without the lock")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "still held by another instance") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestMigrate_ConcurrentInstances starts two migrations against one database
+// at once, as two replicas booting together would. Run it with
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/db/
+//
+// against a scratch database: it migrates it to the latest version.
+func TestMigrate_ConcurrentInstances(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Chdir("../..") // migrations/ is resolved from the working directory
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	logger := zaptest.NewLogger(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = WithMigrationLock(ctx, databaseURL, time.Minute, logger, func() error {
+				return Migrate(ctx, databaseURL, 0, logger)
+			})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("instance %d: %v", i, err)
+		}
+	}
+
+	m, err := NewMigrator(ctx, databaseURL, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	version, dirty, err := m.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version == 0 || dirty {
+		t.Fatalf("expected a clean, migrated schema, got version %d dirty=%v", version, dirty)
+	}
+}
+
+// indexTable matches the table an index definition is on, which differs
+// between the flat and the partitioned notifications table.
+var indexTable = regexp.MustCompile(` ON (ONLY )?\S+ USING `)
+
+// notificationIndexes returns the definitions of the non-unique indexes on
+// table, by name, with the table name normalised.
+func notificationIndexes(t *testing.T, ctx context.Context, conn *pgx.Conn, table string) map[string]string {
+	t.Helper()
+	rows, err := conn.Query(ctx, `
+		SELECT indexname, indexdef FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = $1
+		  AND indexdef NOT LIKE 'CREATE UNIQUE INDEX %'`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := make(map[string]string)
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			t.Fatal(err)
+		}
+		defs[name] = indexTable.ReplaceAllString(def, " ON notifications USING ")
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return defs
+}
+
+// TestMigratePartitioning_KeepsIndexes partitions notifications and folds it
+// back, checking that no index of the flat table is lost either way. Like the
+// test above it needs a scratch TEST_DATABASE_URL, and leaves it migrated and
+// unpartitioned.
+func TestMigratePartitioning_KeepsIndexes(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Chdir("../..")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	logger := zaptest.NewLogger(t)
+
+	if err := Migrate(ctx, databaseURL, 0, logger); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewPartitioningMigrator(ctx, databaseURL, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if version, _, err := m.Version(); err != nil {
+		t.Fatal(err)
+	} else if version > 0 {
+		if err := m.Down(int(version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	flat := notificationIndexes(t, ctx, conn, "notifications")
+	if len(flat) == 0 {
+		t.Fatal("expected the flat table to have indexes")
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	partitioned := notificationIndexes(t, ctx, conn, "notifications")
+	if _, ok := partitioned["idx_notifications_idempotency_key"]; !ok {
+		t.Error("expected the idempotency key lookup index on the partitioned table")
+	}
+	delete(partitioned, "idx_notifications_idempotency_key")
+	if !maps.Equal(partitioned, flat) {
+		t.Errorf("partitioning changed the indexes:\nflat:        %v\npartitioned: %v",
+			slices.Sorted(maps.Keys(flat)), slices.Sorted(maps.Keys(partitioned)))
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	if restored := notificationIndexes(t, ctx, conn, "notifications"); !maps.Equal(restored, flat) {
+		t.Errorf("rolling back changed the indexes:\nbefore: %v\nafter:  %v",
+			slices.Sorted(maps.Keys(flat)), slices.Sorted(maps.Keys(restored)))
+	}
+}
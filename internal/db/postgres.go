@@ -2,43 +2,188 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
-// Connect creates a pgxpool connection pool and verifies connectivity.
-func Connect(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
-	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+// queryExecModes maps DB_STATEMENT_CACHE_MODE to how pgx sends statements.
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"prepare":  pgx.QueryExecModeCacheStatement,
+	"describe": pgx.QueryExecModeCacheDescribe,
+	"simple":   pgx.QueryExecModeSimpleProtocol,
+}
+
+// Connect creates a pgxpool connection pool and verifies connectivity,
+// retrying for up to cfg.DBStartupMaxWait while the database is not yet
+// reachable. With cfg.DBQueryLogging every statement is also logged to logger.
+func Connect(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*pgxpool.Pool, error) {
+	poolCfg, err := poolConfig(cfg, logger)
 	if err != nil {
-		return nil, fmt.Errorf("parse database URL: %w", err)
+		return nil, err
 	}
 
-	poolCfg.MaxConns = cfg.DBMaxConns
-	poolCfg.MinConns = cfg.DBMinConns
-
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("create connection pool: %w", err)
 	}
 
-	if err := pool.Ping(ctx); err != nil {
+	if err := untilReady(ctx, "ping database", cfg.DBStartupMaxWait, logger, pool.Ping); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("ping database: %w", err)
+		return nil, err
 	}
 
 	return pool, nil
 }
 
+// poolConfig applies cfg's pool settings on top of those in the database URL.
+func poolConfig(cfg *config.Config, logger *zap.Logger) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse database URL: %w", err)
+	}
+
+	poolCfg.MaxConns = cfg.DBMaxConns
+	poolCfg.MinConns = cfg.DBMinConns
+	poolCfg.MaxConnLifetime = cfg.DBConnMaxLifetime
+	poolCfg.MaxConnIdleTime = cfg.DBConnMaxIdleTime
+	poolCfg.HealthCheckPeriod = cfg.DBHealthcheckPeriod
+	if cfg.DBStatementTimeout > 0 {
+		// Enforced by the server, so a runaway statement stops even when the
+		// client that sent it has gone away.
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10)
+	}
+	mode, ok := queryExecModes[cfg.DBStatementCacheMode]
+	if !ok {
+		return nil, fmt.Errorf("unknown statement cache mode %q", cfg.DBStatementCacheMode)
+	}
+	poolCfg.ConnConfig.DefaultQueryExecMode = mode
+	// Every query becomes a child span of whatever request or job issued it.
+	poolCfg.ConnConfig.Tracer = tracing.PgxTracer{}
+	if cfg.DBQueryLogging {
+		poolCfg.ConnConfig.Tracer = multitracer.New(tracing.PgxTracer{}, NewQueryLogger(logger))
+	}
+	return poolCfg, nil
+}
+
 // Migrate runs all pending up-migrations from the migrations/ directory.
-// It is idempotent: already-applied migrations are skipped.
-func Migrate(databaseURL string) error {
+// It is idempotent: already-applied migrations are skipped. Connecting is
+// retried for up to maxWait, like Connect; a failing migration is not.
+func Migrate(ctx context.Context, databaseURL string, maxWait time.Duration, logger *zap.Logger) error {
+	m, err := NewMigrator(ctx, databaseURL, maxWait, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up()
+}
+
+// MigratePartitioning applies the opt-in migrations in migrations/partitioning/
+// that convert notifications into a monthly range-partitioned table. They are
+// versioned in their own table so they never interleave with the main sequence.
+// Must run after Migrate.
+func MigratePartitioning(ctx context.Context, databaseURL string, maxWait time.Duration, logger *zap.Logger) error {
+	m, err := NewPartitioningMigrator(ctx, databaseURL, maxWait, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up()
+}
+
+// Migrator moves one migration sequence up and down and reports its version.
+// Close it when done.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator opens the main sequence in migrations/, retrying the
+// connection for up to maxWait.
+func NewMigrator(ctx context.Context, databaseURL string, maxWait time.Duration, logger *zap.Logger) (*Migrator, error) {
+	return newMigrator(ctx, "file://migrations", migrationURL(databaseURL), maxWait, logger)
+}
+
+// NewPartitioningMigrator opens the opt-in sequence in migrations/partitioning/.
+func NewPartitioningMigrator(ctx context.Context, databaseURL string, maxWait time.Duration, logger *zap.Logger) (*Migrator, error) {
+	return newMigrator(ctx, "file://migrations/partitioning",
+		withQueryParam(migrationURL(databaseURL), "x-migrations-table", "schema_migrations_partitioning"), maxWait, logger)
+}
+
+func newMigrator(ctx context.Context, sourceURL, databaseURL string, maxWait time.Duration, logger *zap.Logger) (*Migrator, error) {
+	var m *migrate.Migrate
+	err := untilReady(ctx, "create migrator", maxWait, logger, func(context.Context) error {
+		var err error
+		m, err = migrate.New(sourceURL, databaseURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{m: m}, nil
+}
+
+// Up applies every pending migration; none pending is not an error.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("run migrations: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back the last n migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("roll back migrations: step count must be positive, got %d", n)
+	}
+	if err := m.m.Steps(-n); err != nil {
+		return fmt.Errorf("roll back migrations: %w", err)
+	}
+	return nil
+}
+
+// Version reports the applied version, 0 when none is, and whether a failed
+// migration left it dirty.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Force records version as applied and clean without running anything: the
+// way out of a dirty state once the schema has been repaired by hand.
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("force migration version: %w", err)
+	}
+	return nil
+}
+
+// Close releases the migrator's database connection.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// migrationURL rewrites a postgres connection string for golang-migrate.
+func migrationURL(databaseURL string) string {
 	// golang-migrate's pgx/v5 driver expects the scheme "pgx5://".
 	// Support both "postgres://" and "postgresql://" connection string forms.
 	var rest string
@@ -50,17 +195,13 @@ func Migrate(databaseURL string) error {
 	default:
 		rest = databaseURL
 	}
-	migrationURL := "pgx5://" + rest
-
-	m, err := migrate.New("file://migrations", migrationURL)
-	if err != nil {
-		return fmt.Errorf("create migrator: %w", err)
-	}
-	defer m.Close()
+	return "pgx5://" + rest
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("run migrations: %w", err)
+func withQueryParam(rawURL, key, value string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
 	}
-
-	return nil
-}
\ No newline at end of file
+	return rawURL + sep + key + "=" + value
+}
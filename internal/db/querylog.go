@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// QueryLogger logs every SQL statement with its duration and row count at
+// debug level, and statements that fail at warn. Queries issued while
+// serving an HTTP request carry its correlation ID. Arguments are never
+// logged: they hold recipients and message content.
+type QueryLogger struct {
+	logger *zap.Logger
+}
+
+// NewQueryLogger returns a pgx.QueryTracer that logs to logger.
+func NewQueryLogger(logger *zap.Logger) *QueryLogger {
+	return &QueryLogger{logger: logger}
+}
+
+// queryStart is what TraceQueryStart hands TraceQueryEnd through the context.
+type queryStart struct {
+	sql string
+	at  time.Time
+}
+
+type queryStartKey struct{}
+
+func (l *QueryLogger) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, at: time.Now()})
+}
+
+func (l *QueryLogger) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok || (data.Err == nil && !l.logger.Core().Enabled(zapcore.DebugLevel)) {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("sql", compactSQL(start.sql)),
+		zap.Duration("duration", time.Since(start.at)),
+	}
+	if id := domain.CorrelationIDFromContext(ctx); id != "" {
+		fields = append(fields, zap.String("correlation_id", id))
+	}
+	if data.Err != nil {
+		l.logger.Warn("query failed", append(fields, zap.Error(data.Err))...)
+		return
+	}
+	l.logger.Debug("query", append(fields, zap.Int64("rows", data.CommandTag.RowsAffected()))...)
+}
+
+// compactSQL collapses the indentation of multi-line statements onto one line.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+var _ pgx.QueryTracer = (*QueryLogger)(nil)
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func TestQueryLogger_LogsStatementAtDebug(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewQueryLogger(zap.New(core))
+
+	ctx := domain.WithCorrelationID(context.Background(), "req-42")
+	ctx = l.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+		SQL:  "\n\t\tUPDATE notifications\n\t\tSET status = $1\n\t\tWHERE id = $2",
+		Args: []any{"sent", "n-1"},
+	})
+	l.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 1")})
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Level != zapcore.DebugLevel {
+		t.Fatalf("expected one debug entry, got %+v", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["sql"] != "UPDATE notifications SET status = $1 WHERE id = $2" {
+		t.Errorf("unexpected sql %q", fields["sql"])
+	}
+	if fields["rows"] != int64(1) || fields["correlation_id"] != "req-42" || fields["duration"] == nil {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	for _, v := range fields {
+		if v == "n-1" {
+			t.Errorf("expected arguments not to be logged, got %v", fields)
+		}
+	}
+}
+
+func TestQueryLogger_LogsFailuresAtWarn(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewQueryLogger(zap.New(core))
+
+	ctx := l.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	l.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	ctx = l.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 2"})
+	l.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("deadlock detected")})
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Level != zapcore.WarnLevel || entries[0].ContextMap()["sql"] != "SELECT 2" {
+		t.Fatalf("expected only the failure, at warn, got %+v", entries)
+	}
+	if _, ok := entries[0].ContextMap()["correlation_id"]; ok {
+		t.Errorf("expected no correlation ID outside a request, got %v", entries[0].ContextMap())
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Backoff between startup attempts: it doubles from the first delay up to the
+// cap.
+const (
+	startupFirstDelay = 500 * time.Millisecond
+	startupMaxDelay   = 10 * time.Second
+)
+
+// untilReady calls attempt until it succeeds, ctx ends, or maxWait has passed
+// since the first try, logging each failure along with the wait before the
+// next. A maxWait of 0 allows a single attempt. Databases started alongside
+// the service (docker-compose, a Kubernetes pod) often come up after it; this
+// rides that out instead of crash-looping.
+func untilReady(ctx context.Context, what string, maxWait time.Duration, logger *zap.Logger, attempt func(context.Context) error) error {
+	deadline := time.Now().Add(maxWait)
+	delay := startupFirstDelay
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: %w", what, ctx.Err())
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%s: gave up after %d attempts: %w", what, n, err)
+		}
+		delay = min(delay, remaining)
+		logger.Warn("database not ready; retrying",
+			zap.String("step", what), zap.Int("attempt", n), zap.Duration("retry_in", delay), zap.Error(err))
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%s: %w", what, ctx.Err())
+		case <-t.C:
+		}
+		delay = min(delay*2, startupMaxDelay)
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestUntilReady_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := untilReady(context.Background(), "ping database", time.Minute, zap.NewNop(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d", err, calls)
+	}
+}
+
+func TestUntilReady_GivesUpAfterMaxWait(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := untilReady(context.Background(), "ping database", 700*time.Millisecond, zap.NewNop(), func(context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") || !strings.Contains(err.Error(), "ping database") {
+		t.Fatalf("expected the last error, got %v", err)
+	}
+	// 500ms, then the 200ms left: three attempts, no longer than the budget.
+	if calls != 3 || time.Since(start) > 2*time.Second {
+		t.Fatalf("expected 3 attempts within the wait, got %d in %s", calls, time.Since(start))
+	}
+}
+
+func TestUntilReady_ZeroWaitTriesOnce(t *testing.T) {
+	calls := 0
+	err := untilReady(context.Background(), "ping database", 0, zap.NewNop(), func(context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single failed attempt, got %v after %d", err, calls)
+	}
+}
+
+func TestUntilReady_StopsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := untilReady(ctx, "ping database", time.Hour, zap.NewNop(), func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Fatalf("expected a prompt cancellation, got %v after %s", err, time.Since(start))
+	}
+}
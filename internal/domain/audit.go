@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// AuditEntry records one API mutation: what was done to which resource, by
+// whom, when and from where. Entries are append-only.
+//
+// OwnerID is the owner of the API key used (nil with authentication off and
+// on admin routes) and KeyID a short fingerprint of the key itself, so keys
+// sharing an owner can be told apart without storing them. TargetID is the
+// resource the route acted on, or the one a create made; routes acting on
+// several resources leave it unset.
+type AuditEntry struct {
+	ID            int64     `json:"id"`
+	Action        string    `json:"action"`
+	TargetType    *string   `json:"target_type,omitempty"`
+	TargetID      *string   `json:"target_id,omitempty"`
+	OwnerID       *string   `json:"owner_id,omitempty"`
+	KeyID         *string   `json:"key_id,omitempty"`
+	ClientIP      string    `json:"client_ip"`
+	CorrelationID *string   `json:"correlation_id,omitempty"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Status        int       `json:"status"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// AuditFilter narrows the audit log listing. From and To bound OccurredAt.
+type AuditFilter struct {
+	From     *time.Time
+	To       *time.Time
+	OwnerID  *string
+	Action   *string
+	TargetID *string
+	Page     int
+	Limit    int
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CallbackStatus tracks delivery of a status webhook.
+type CallbackStatus string
+
+const (
+	CallbackPending   CallbackStatus = "pending"
+	CallbackDelivered CallbackStatus = "delivered"
+	CallbackFailed    CallbackStatus = "failed"
+)
+
+// MaxCallbackURLLength caps callback_url.
+const MaxCallbackURLLength = 2048
+
+// Callback is one status webhook owed to a client: the payload is fixed when
+// the notification reaches a terminal status and retried until delivered.
+type Callback struct {
+	ID             string          `json:"id"`
+	NotificationID string          `json:"notification_id"`
+	URL            string          `json:"url"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         CallbackStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// CallbackPayload is the JSON body posted to a notification's callback_url.
+// Content and recipient are deliberately left out; clients already have them.
+type CallbackPayload struct {
+	Event          string     `json:"event"`
+	NotificationID string     `json:"notification_id"`
+	BatchID        *string    `json:"batch_id,omitempty"`
+	Status         Status     `json:"status"`
+	Channel        Channel    `json:"channel"`
+	ProviderMsgID  *string    `json:"provider_message_id,omitempty"`
+	ErrorMessage   *string    `json:"error_message,omitempty"`
+	RetryCount     int        `json:"retry_count"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	OccurredAt     time.Time  `json:"occurred_at"`
+}
+
+// IsTerminal reports whether no further delivery attempts will be made for a
+// notification in this status (failed here means retries are exhausted).
+func (s Status) IsTerminal() bool {
+	return s == StatusSent || s == StatusFailed || s == StatusCancelled || s == StatusExpired
+}
+
+// validateCallbackURL accepts absolute http(s) URLs whose host is not in
+// blockedHosts, which lists our own hostnames so a client cannot make the
+// service call itself in a loop.
+func validateCallbackURL(raw string, blockedHosts []string) error {
+	if len(raw) > MaxCallbackURLLength {
+		return ErrInvalidCallbackURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidCallbackURL
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, blocked := range blockedHosts {
+		if host == strings.ToLower(blocked) {
+			return ErrCallbackLoop
+		}
+	}
+	return nil
+}
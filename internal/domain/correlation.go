@@ -0,0 +1,19 @@
+package domain
+
+import "context"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying the request's correlation ID.
+// The correlation middleware sets it so layers below the HTTP handlers, such
+// as lifecycle events, can quote it.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID, or "" when ctx did not
+// come from an HTTP request.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// AttemptOutcome is how a single provider send ended.
+type AttemptOutcome string
+
+const (
+	AttemptSent   AttemptOutcome = "sent"
+	AttemptFailed AttemptOutcome = "failed"
+)
+
+// DeliveryAttempt is one provider send of a notification. Attempt counts from
+// 1 and restarts when a retry resets the notification's retry count.
+type DeliveryAttempt struct {
+	Attempt       int            `json:"attempt"`
+	Outcome       AttemptOutcome `json:"outcome"`
+	Error         *string        `json:"error,omitempty"`
+	ProviderMsgID *string        `json:"provider_msg_id,omitempty"`
+	AttemptedAt   time.Time      `json:"attempted_at"`
+}
+
+// DeadLetter is a notification that failed for good: status failed with no
+// retry scheduled. Attempts is only filled in when a single entry is fetched.
+type DeadLetter struct {
+	*Notification
+	AttemptCount  int               `json:"attempt_count"`
+	LastAttemptAt *time.Time        `json:"last_attempt_at,omitempty"`
+	Attempts      []DeliveryAttempt `json:"attempts,omitempty"`
+}
+
+// DeadLetterFilter narrows the dead-letter listing. From and To bound when
+// the notification was dead-lettered.
+type DeadLetterFilter struct {
+	Channel *Channel
+	From    *time.Time
+	To      *time.Time
+	Page    int
+	Limit   int
+}
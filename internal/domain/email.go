@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxEmailSubjectLength caps email subjects, in characters.
+	MaxEmailSubjectLength = 255
+	// MaxEmailAttachments caps the number of attachments per email.
+	MaxEmailAttachments = 10
+	// MaxEmailAttachmentBytes caps the decoded size of all attachments of one
+	// email; base64 inflates it by a third, which still fits the 1 MB body limit.
+	MaxEmailAttachmentBytes = 512 << 10
+)
+
+// Email holds the email-only parts of a notification. Content stays the
+// plain-text body; HTMLBody, when set, is its HTML alternative.
+type Email struct {
+	Subject     string            `json:"subject"`
+	HTMLBody    string            `json:"html_body,omitempty"`
+	Attachments []EmailAttachment `json:"attachments,omitempty"`
+}
+
+// EmailAttachment is a file attached to an email. Content is standard base64.
+type EmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content"`
+}
+
+// validate checks the subject, the HTML body against the email content limit,
+// and that attachments are well-formed base64 within the size cap.
+func (e *Email) validate(rules ValidationRules) error {
+	if strings.TrimSpace(e.Subject) == "" || utf8.RuneCountInString(e.Subject) > MaxEmailSubjectLength {
+		return ErrInvalidEmailSubject
+	}
+	if e.HTMLBody != "" {
+		if err := rules.CheckContent(ChannelEmail, e.HTMLBody); err != nil {
+			return fmt.Errorf("html_body: %w", err)
+		}
+	}
+	if len(e.Attachments) > MaxEmailAttachments {
+		return ErrAttachmentsTooLarge
+	}
+	total := 0
+	for i, a := range e.Attachments {
+		if strings.TrimSpace(a.Filename) == "" {
+			return fmt.Errorf("%w: attachment %d has no filename", ErrInvalidAttachment, i)
+		}
+		n, err := decodedLen(a.Content)
+		if err != nil {
+			return fmt.Errorf("%w: attachment %d is not valid base64", ErrInvalidAttachment, i)
+		}
+		total += n
+		if total > MaxEmailAttachmentBytes {
+			return ErrAttachmentsTooLarge
+		}
+	}
+	return nil
+}
+
+// decodedLen validates s as standard base64 and returns its decoded length.
+func decodedLen(s string) (int, error) {
+	if s == "" {
+		return 0, base64.CorruptInputError(0)
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	return len(b), err
+}
@@ -0,0 +1,79 @@
+package domain_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func TestCreateNotificationRequest_ValidateEmail(t *testing.T) {
+	attachment := func(size int) domain.EmailAttachment {
+		return domain.EmailAttachment{
+			Filename:    "invoice.pdf",
+			ContentType: "application/pdf",
+			Content:     base64.StdEncoding.EncodeToString(make([]byte, size)),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		channel domain.Channel
+		email   *domain.Email
+		wantErr error
+	}{
+		{"no email object", domain.ChannelEmail, nil, nil},
+		{"subject only", domain.ChannelEmail, &domain.Email{Subject: "Hi"}, nil},
+		{"html and attachment", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", HTMLBody: "<p>Hello</p>", Attachments: []domain.EmailAttachment{attachment(1024)},
+		}, nil},
+		{"non-email channel", domain.ChannelSMS, &domain.Email{Subject: "Hi"}, domain.ErrEmailOnlyField},
+		{"missing subject", domain.ChannelEmail, &domain.Email{Subject: "  "}, domain.ErrInvalidEmailSubject},
+		{"subject too long", domain.ChannelEmail, &domain.Email{Subject: strings.Repeat("s", 256)}, domain.ErrInvalidEmailSubject},
+		{"html too long", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", HTMLBody: strings.Repeat("x", 100001),
+		}, domain.ErrContentTooLong},
+		{"attachment without filename", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", Attachments: []domain.EmailAttachment{{Content: "aGk="}},
+		}, domain.ErrInvalidAttachment},
+		{"attachment not base64", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", Attachments: []domain.EmailAttachment{{Filename: "a.txt", Content: "not base64!"}},
+		}, domain.ErrInvalidAttachment},
+		{"empty attachment", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", Attachments: []domain.EmailAttachment{{Filename: "a.txt"}},
+		}, domain.ErrInvalidAttachment},
+		{"attachments over size cap", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", Attachments: []domain.EmailAttachment{attachment(300 << 10), attachment(300 << 10)},
+		}, domain.ErrAttachmentsTooLarge},
+		{"too many attachments", domain.ChannelEmail, &domain.Email{
+			Subject: "Hi", Attachments: make([]domain.EmailAttachment, domain.MaxEmailAttachments+1),
+		}, domain.ErrAttachmentsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := domain.CreateNotificationRequest{
+				Channel:   tt.channel,
+				Recipient: "user@example.com",
+				Content:   "Hello",
+				Priority:  domain.PriorityNormal,
+				Email:     tt.email,
+			}
+			if tt.channel == domain.ChannelSMS {
+				r.Recipient = "+905551234567"
+			}
+			err := r.Validate()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
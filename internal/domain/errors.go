@@ -1,19 +1,111 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // Sentinel errors used throughout the application.
 // Handlers translate these to HTTP status codes via a single mapError function.
 var (
-	ErrNotFound         = errors.New("not found")
-	ErrConflict         = errors.New("conflict: idempotency key already exists")
-	ErrInvalidChannel   = errors.New("invalid channel: must be sms, email, or push")
-	ErrInvalidPriority  = errors.New("invalid priority: must be high, normal, or low")
-	ErrInvalidRecipient = errors.New("recipient must not be empty")
-	ErrInvalidContent   = errors.New("content must be between 1 and 4096 characters")
-	ErrBatchTooLarge    = errors.New("batch exceeds maximum of 1000 notifications")
-	ErrBatchEmpty       = errors.New("batch must contain at least one notification")
-	ErrAlreadyCancelled = errors.New("notification is already cancelled")
-	ErrNotCancellable   = errors.New("notification cannot be cancelled in its current status")
-	ErrQueueFull        = errors.New("queue is at capacity, try again later")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict: idempotency key already exists")
+	ErrInvalidChannel     = errors.New("invalid channel: must be sms, email, or push")
+	ErrInvalidPriority    = errors.New("invalid priority: must be high, normal, or low")
+	ErrInvalidRecipient   = errors.New("recipient must not be empty")
+	ErrRecipientTooLong   = errors.New("recipient must be at most 512 characters")
+	ErrInvalidRecipients  = errors.New("recipients cannot be combined with recipient or used inside a batch")
+	ErrInvalidContent     = errors.New("content must not be empty")
+	ErrContentTooLong     = errors.New("content too long")
+	ErrBatchTooLarge      = errors.New("batch exceeds maximum of 1000 notifications")
+	ErrBatchEmpty         = errors.New("batch must contain at least one notification")
+	ErrBatchAllRejected   = errors.New("no notification in the batch passed validation")
+	ErrAlreadyCancelled   = errors.New("notification is already cancelled")
+	ErrNotCancellable     = errors.New("notification cannot be cancelled in its current status")
+	ErrNotEditable        = errors.New("notification cannot be edited in its current status")
+	ErrPreconditionFailed = errors.New("notification was modified since the version given in If-Match or If-Unmodified-Since")
+	ErrNotDraft           = errors.New("only draft notifications can be submitted")
+	ErrResendInFlight     = errors.New("notification is still in flight and cannot be resent yet")
+	ErrPriorityLocked     = errors.New("priority can only be changed before the notification is dispatched")
+	ErrNotRetryable       = errors.New("only failed notifications can be retried")
+	ErrRetriesExhausted   = errors.New("notification has used all its retries; retry with reset=true")
+	ErrEmptyUpdate        = errors.New("update must change at least one of content, priority, scheduled_at, or max_retries")
+	ErrQueueFull          = errors.New("queue is at capacity, try again later")
+	ErrScheduledInPast    = errors.New("scheduled_at must be in the future")
+	ErrScheduleTooFar     = errors.New("scheduled_at is beyond the maximum scheduling horizon")
+	ErrInvalidMaxRetries  = errors.New("max_retries must be between 0 and 10")
+
+	ErrInvalidCancelReason = errors.New("cancel reason must be at most 500 characters")
+
+	ErrInvalidScheduledAt = errors.New("scheduled_at must be a real timestamp, not the zero value or the Unix epoch")
+
+	ErrRateLimited = errors.New("creation rate limit exceeded, try again later")
+
+	ErrInvalidFilter = errors.New("invalid list filter")
+
+	ErrInvalidLookup = errors.New("ids must list at least one notification ID and no more than the lookup limit")
+
+	ErrEmailOnlyField      = errors.New("email is only allowed for the email channel")
+	ErrInvalidEmailSubject = errors.New("email.subject is required and must be at most 255 characters")
+	ErrInvalidAttachment   = errors.New("invalid email attachment")
+	ErrAttachmentsTooLarge = errors.New("email attachments exceed 10 files or 512 KiB in total")
+
+	ErrInvalidExpiry      = errors.New("expires_at must be in the future and after scheduled_at; ttl_seconds must be positive")
+	ErrExpiryAndTTL       = errors.New("specify either expires_at or ttl_seconds, not both")
+	ErrNotExpirable       = errors.New("notification can no longer expire in its current status")
+	ErrInvalidSendWindow  = errors.New("send_window_start and send_window_end must be HH:MM with the end after the start")
+	ErrUnknownTimezone    = errors.New("timezone is not a known IANA time zone")
+	ErrInvalidCallbackURL = errors.New("callback_url must be an absolute http or https URL of at most 2048 characters")
+	ErrCallbackLoop       = errors.New("callback_url must not point at this service")
+
+	ErrInvalidTemplateName     = errors.New("template name must be between 1 and 100 characters")
+	ErrInvalidTemplate         = errors.New("template body is not a valid template")
+	ErrTemplateNameTaken       = errors.New("conflict: a template with this name already exists")
+	ErrUnknownTemplate         = errors.New("template_id does not reference an existing template")
+	ErrMissingTemplateVariable = errors.New("template variables are missing")
+	ErrContentAndTemplate      = errors.New("specify either content or template_id, not both")
 )
+
+// errorCodes maps the sentinels a request can fail with to the stable code
+// reported alongside their message. Validation sentinels are not listed:
+// theirs are per field, in fieldCodes.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrNotFound, "not_found"},
+	{ErrConflict, "idempotency_conflict"},
+	{ErrTemplateNameTaken, "template_name_taken"},
+	{ErrPreconditionFailed, "precondition_failed"},
+	{ErrAlreadyCancelled, "already_cancelled"},
+	{ErrNotCancellable, "not_cancellable"},
+	{ErrNotEditable, "not_editable"},
+	{ErrNotDraft, "not_draft"},
+	{ErrResendInFlight, "resend_in_flight"},
+	{ErrPriorityLocked, "priority_locked"},
+	{ErrNotRetryable, "not_retryable"},
+	{ErrRetriesExhausted, "retries_exhausted"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrQueueFull, "queue_full"},
+}
+
+// ErrorCode returns the stable code of the sentinel err is or wraps, or ""
+// when it wraps none of them.
+func ErrorCode(err error) string {
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+	return ""
+}
+
+// RateLimitError is returned when a caller exceeds its creation rate limit.
+// It matches ErrRateLimited and carries how long the caller should wait.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return ErrRateLimited.Error() }
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
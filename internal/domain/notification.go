@@ -1,6 +1,13 @@
 package domain
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 // Channel is the delivery channel for a notification.
 type Channel string
@@ -47,39 +54,96 @@ const (
 	StatusFailed     Status = "failed"
 	StatusCancelled  Status = "cancelled"
 	StatusScheduled  Status = "scheduled"
+	StatusExpired    Status = "expired"
+	StatusDraft      Status = "draft" // stored, but not dispatched until submitted
 )
 
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusPending, StatusQueued, StatusProcessing, StatusSent,
+		StatusFailed, StatusCancelled, StatusScheduled, StatusExpired, StatusDraft:
+		return true
+	}
+	return false
+}
+
+// IsCancellable reports whether a notification in this status may still be
+// cancelled: it has not been claimed by a worker or reached an end state.
+func (s Status) IsCancellable() bool {
+	switch s {
+	case StatusDraft, StatusPending, StatusQueued, StatusScheduled, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// StatusCount is how many notifications of one channel are in one status.
+type StatusCount struct {
+	Status  Status
+	Channel Channel
+	Count   int
+}
+
 // Notification is the core domain entity.
 type Notification struct {
-	ID             string     `json:"id"`
-	BatchID        *string    `json:"batch_id,omitempty"`
-	Channel        Channel    `json:"channel"`
-	Recipient      string     `json:"recipient"`
-	Content        string     `json:"content"`
-	Priority       Priority   `json:"priority"`
-	Status         Status     `json:"status"`
-	IdempotencyKey *string    `json:"idempotency_key,omitempty"`
-	RetryCount     int        `json:"retry_count"`
-	MaxRetries     int        `json:"max_retries"`
-	NextRetryAt    *time.Time `json:"next_retry_at,omitempty"`
-	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
-	SentAt         *time.Time `json:"sent_at,omitempty"`
-	ProviderMsgID  *string    `json:"provider_message_id,omitempty"`
-	ErrorMessage   *string    `json:"error_message,omitempty"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	ID              string     `json:"id"`
+	BatchID         *string    `json:"batch_id,omitempty"`
+	Channel         Channel    `json:"channel"`
+	Recipient       string     `json:"recipient"`
+	Content         string     `json:"content"`
+	Priority        Priority   `json:"priority"`
+	Status          Status     `json:"status"`
+	IdempotencyKey  *string    `json:"idempotency_key,omitempty"`
+	RetryCount      int        `json:"retry_count"`
+	MaxRetries      int        `json:"max_retries"`
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	SentAt          *time.Time `json:"sent_at,omitempty"`
+	ProviderMsgID   *string    `json:"provider_message_id,omitempty"`
+	ErrorMessage    *string    `json:"error_message,omitempty"`
+	TemplateID      *string    `json:"template_id,omitempty"`
+	OwnerID         *string    `json:"owner_id,omitempty"`
+	CallbackURL     *string    `json:"callback_url,omitempty"`
+	SendWindowStart *string    `json:"send_window_start,omitempty"`
+	SendWindowEnd   *string    `json:"send_window_end,omitempty"`
+	Timezone        *string    `json:"timezone,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Email           *Email     `json:"email,omitempty"`
+	ResendOf        *string    `json:"resend_of,omitempty"`
+	CorrelationID   *string    `json:"correlation_id,omitempty"` // of the creating request
+	DryRun          bool       `json:"dry_run,omitempty"`        // never handed to the provider
+	DedupHash       string     `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Set when the notification was cancelled through the API. CancelledBy is
+	// the canceller's owner ID (nil with authentication off) and
+	// CancelCorrelationID the correlation ID of the cancelling request.
+	CancelledReason     *string    `json:"cancelled_reason,omitempty"`
+	CancelledAt         *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy         *string    `json:"cancelled_by,omitempty"`
+	CancelCorrelationID *string    `json:"cancel_correlation_id,omitempty"`
 }
 
-// Batch groups multiple notifications created together.
+// Batch groups multiple notifications created together. ScheduledAt is the
+// batch-level send time given to members that did not set their own.
+// IdempotencyKey is set when the batch or fan-out request carried one.
 type Batch struct {
-	ID        string    `json:"id"`
-	Total     int       `json:"total"`
-	Pending   int       `json:"pending"`
-	Sent      int       `json:"sent"`
-	Failed    int       `json:"failed"`
-	Cancelled int       `json:"cancelled"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string     `json:"id"`
+	OwnerID        *string    `json:"owner_id,omitempty"`
+	IdempotencyKey *string    `json:"idempotency_key,omitempty"`
+	ScheduledAt    *time.Time `json:"scheduled_at,omitempty"`
+	Total          int        `json:"total"`
+	Pending        int        `json:"pending"`
+	Sent           int        `json:"sent"`
+	Failed         int        `json:"failed"`
+	Cancelled      int        `json:"cancelled"`
+	Expired        int        `json:"expired"`
+	// CompletedAt is when pending first reached zero. It stays set if a
+	// member is requeued later.
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 // CreateNotificationRequest is the inbound payload for a single notification.
@@ -89,35 +153,455 @@ type CreateNotificationRequest struct {
 	Content     string     `json:"content"`
 	Priority    Priority   `json:"priority"`
 	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	MaxRetries  *int       `json:"max_retries,omitempty"`
+
+	// SendIfPast accepts a scheduled_at that has already passed, beyond the
+	// clock skew tolerance, instead of rejecting it; the notification is then
+	// sent on the scheduler's next poll.
+	SendIfPast bool `json:"send_if_past,omitempty"`
+
+	// Recipients, instead of Recipient, fans the same message out to several
+	// recipients as an implicit batch; see NotificationService.FanOut.
+	Recipients []string `json:"recipients,omitempty"`
+
+	// TemplateID replaces Content: the service renders the template with
+	// Variables at create time and validates the result as regular content.
+	TemplateID *string           `json:"template_id,omitempty"`
+	Variables  map[string]string `json:"variables,omitempty"`
+
+	// AllowDuplicate bypasses the dedup window for intentional repeats.
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+
+	// Draft stores the notification with status draft; it is not queued or
+	// scheduled until submitted.
+	Draft bool `json:"draft,omitempty"`
+
+	// DryRun sends the notification through the whole pipeline, except that
+	// the worker marks it sent instead of calling the provider.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// CallbackURL receives a signed status webhook once the notification is
+	// sent, finally fails, or is cancelled.
+	CallbackURL *string `json:"callback_url,omitempty"`
+
+	// SendWindowStart/End ("HH:MM") restrict delivery to a daily window in
+	// Timezone (default UTC). Outside it, delivery waits for the next opening.
+	SendWindowStart *string `json:"send_window_start,omitempty"`
+	SendWindowEnd   *string `json:"send_window_end,omitempty"`
+	Timezone        *string `json:"timezone,omitempty"`
+
+	// ExpiresAt or TTLSeconds (relative to creation) set a deadline after
+	// which the notification is marked expired instead of being delivered.
+	// ValidateWith folds TTLSeconds into ExpiresAt.
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds *int       `json:"ttl_seconds,omitempty"`
+
+	// Email adds a subject, HTML body and attachments; only valid for the
+	// email channel.
+	Email *Email `json:"email,omitempty"`
+}
+
+const (
+	// MaxRecipientLength caps recipients; generous enough for push tokens.
+	MaxRecipientLength = 512
+	// MaxBatchSize is the most notifications one batch may hold.
+	MaxBatchSize = 1000
+	// MaxRetriesLimit is the largest per-request max_retries accepted.
+	MaxRetriesLimit = 10
+	// DefaultMaxRetries applies when a request does not specify max_retries.
+	DefaultMaxRetries = 3
+)
+
+// DefaultContentLimits caps content length per channel, in characters. SMS
+// providers reject anything over 1600 (ten concatenated segments).
+var DefaultContentLimits = map[Channel]int{
+	ChannelSMS:   1600,
+	ChannelPush:  1024,
+	ChannelEmail: 100000,
 }
 
+// ValidationRules holds the configurable limits applied during validation.
+type ValidationRules struct {
+	// MaxScheduleHorizon is how far into the future scheduled_at may be.
+	MaxScheduleHorizon time.Duration
+	// ScheduleSkew tolerates client clocks running slightly behind ours:
+	// a scheduled_at at most this far in the past is still accepted.
+	ScheduleSkew time.Duration
+	// BlockedCallbackHosts are hostnames callback_url may not target,
+	// normally this service's own names and loopback addresses.
+	BlockedCallbackHosts []string
+	// ContentLimits caps content length per channel; channels missing from
+	// the map use DefaultContentLimits.
+	ContentLimits map[Channel]int
+	// DefaultCountryCode is the calling code, without "+", given to national
+	// SMS numbers such as 0555 123 45 67; empty leaves those as written.
+	DefaultCountryCode string
+}
+
+// DefaultValidationRules are used by Validate and whenever config leaves a rule unset.
+var DefaultValidationRules = ValidationRules{
+	MaxScheduleHorizon:   30 * 24 * time.Hour,
+	ScheduleSkew:         30 * time.Second,
+	BlockedCallbackHosts: []string{"localhost", "127.0.0.1", "::1"},
+	ContentLimits:        DefaultContentLimits,
+}
+
+// Validate checks the request against DefaultValidationRules.
 func (r *CreateNotificationRequest) Validate() error {
+	return r.ValidateWith(DefaultValidationRules)
+}
+
+// ValidateWith checks the request against the given rules and reports the
+// first failure as a *ValidationError. It also puts the recipient in canonical
+// form (see NormalizeRecipient), so callers persist the cleaned value.
+func (r *CreateNotificationRequest) ValidateWith(rules ValidationRules) error {
+	return AsValidationError(r.validate(rules))
+}
+
+func (r *CreateNotificationRequest) validate(rules ValidationRules) error {
 	if !r.Channel.IsValid() {
 		return ErrInvalidChannel
 	}
 	if !r.Priority.IsValid() {
 		return ErrInvalidPriority
 	}
+	if len(r.Recipients) > 0 {
+		return ErrInvalidRecipients
+	}
+	r.Recipient = NormalizeRecipient(r.Channel, r.Recipient, rules.DefaultCountryCode)
 	if r.Recipient == "" {
 		return ErrInvalidRecipient
 	}
-	if r.Content == "" || len(r.Content) > 4096 {
+	if len(r.Recipient) > MaxRecipientLength {
+		return ErrRecipientTooLong
+	}
+	if err := rules.CheckContent(r.Channel, r.Content); err != nil {
+		return err
+	}
+	if r.MaxRetries != nil && (*r.MaxRetries < 0 || *r.MaxRetries > MaxRetriesLimit) {
+		return ErrInvalidMaxRetries
+	}
+	if r.Email != nil {
+		if r.Channel != ChannelEmail {
+			return ErrEmailOnlyField
+		}
+		if err := r.Email.validate(rules); err != nil {
+			return err
+		}
+	}
+	if r.SendWindowStart != nil || r.SendWindowEnd != nil || r.Timezone != nil {
+		w := SendWindow{Start: deref(r.SendWindowStart), End: deref(r.SendWindowEnd), Timezone: deref(r.Timezone)}
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.CallbackURL != nil {
+		if err := validateCallbackURL(*r.CallbackURL, rules.BlockedCallbackHosts); err != nil {
+			return err
+		}
+	}
+	if r.ScheduledAt != nil {
+		if err := rules.checkSchedule(*r.ScheduledAt, r.SendIfPast); err != nil {
+			return err
+		}
+	}
+	return r.normalizeExpiry(time.Now())
+}
+
+// normalizeExpiry turns TTLSeconds into ExpiresAt and rejects deadlines that
+// have already passed or fall before the scheduled send time.
+func (r *CreateNotificationRequest) normalizeExpiry(now time.Time) error {
+	if r.TTLSeconds != nil {
+		if r.ExpiresAt != nil {
+			return ErrExpiryAndTTL
+		}
+		if *r.TTLSeconds <= 0 {
+			return ErrInvalidExpiry
+		}
+		at := now.Add(time.Duration(*r.TTLSeconds) * time.Second)
+		r.ExpiresAt = &at
+		r.TTLSeconds = nil
+	}
+	if r.ExpiresAt == nil {
+		return nil
+	}
+	if !r.ExpiresAt.After(now) || (r.ScheduledAt != nil && !r.ExpiresAt.After(*r.ScheduledAt)) {
+		return ErrInvalidExpiry
+	}
+	return nil
+}
+
+// Settled reports whether n has reached its final outcome: a terminal status,
+// except a failure that still has a retry scheduled.
+func (n *Notification) Settled() bool {
+	return n.Status.IsTerminal() && (n.Status != StatusFailed || n.NextRetryAt == nil)
+}
+
+// IsExpired reports whether the notification's deadline has passed at now.
+func (n *Notification) IsExpired(now time.Time) bool {
+	return n.ExpiresAt != nil && !now.Before(*n.ExpiresAt)
+}
+
+// CheckContent rejects empty content and content longer than the channel's
+// limit. Length is counted in characters, not bytes.
+func (rules ValidationRules) CheckContent(ch Channel, content string) error {
+	if content == "" {
+		return ErrInvalidContent
+	}
+	limit, ok := rules.ContentLimits[ch]
+	if !ok {
+		limit = DefaultContentLimits[ch]
+	}
+	if utf8.RuneCountInString(content) > limit {
+		return fmt.Errorf("%w: %s allows at most %d characters", ErrContentTooLong, ch, limit)
+	}
+	return nil
+}
+
+// checkSchedule rejects zero or epoch scheduled_at values (usually an unset
+// field serialised by the client), values in the past (beyond the skew
+// tolerance) unless sendIfPast, and values past the scheduling horizon.
+func (rules ValidationRules) checkSchedule(at time.Time, sendIfPast bool) error {
+	if at.Unix() <= 0 {
+		return ErrInvalidScheduledAt
+	}
+	now := time.Now()
+	if !sendIfPast && at.Before(now.Add(-rules.ScheduleSkew)) {
+		return ErrScheduledInPast
+	}
+	if at.After(now.Add(rules.MaxScheduleHorizon)) {
+		return ErrScheduleTooFar
+	}
+	return nil
+}
+
+// SendWindow returns the notification's delivery window, if it has one.
+func (n *Notification) SendWindow() (SendWindow, bool) {
+	if n.SendWindowStart == nil || n.SendWindowEnd == nil {
+		return SendWindow{}, false
+	}
+	return SendWindow{Start: *n.SendWindowStart, End: *n.SendWindowEnd, Timezone: deref(n.Timezone)}, true
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// DedupHash fingerprints a send by owner, channel, recipient and content. Two
+// notifications with the same hash would deliver the same message to the same
+// person on behalf of the same client. owner is empty when unauthenticated.
+// Dry runs hash apart from real sends, so a test never suppresses the real
+// message; real sends hash as they always have.
+func DedupHash(owner string, channel Channel, recipient, content string, dryRun bool) string {
+	h := sha256.New()
+	for _, part := range []string{owner, string(channel), recipient, content} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if dryRun {
+		h.Write([]byte("dry_run"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UpdateNotificationRequest is the PATCH payload. Nil fields are left
+// unchanged; which of the others may change depends on the status.
+type UpdateNotificationRequest struct {
+	Content     *string    `json:"content,omitempty"`
+	Priority    *Priority  `json:"priority,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	MaxRetries  *int       `json:"max_retries,omitempty"`
+
+	// SendIfPast accepts a scheduled_at that has already passed, as on
+	// creation. It changes nothing by itself.
+	SendIfPast bool `json:"send_if_past,omitempty"`
+}
+
+// Fields names the fields the request sets, in JSON spelling.
+func (r *UpdateNotificationRequest) Fields() []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"content", r.Content != nil},
+		{"priority", r.Priority != nil},
+		{"scheduled_at", r.ScheduledAt != nil},
+		{"max_retries", r.MaxRetries != nil},
+	} {
+		if f.set {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// ValidateWith checks the supplied fields with the same rules as creation,
+// except the per-channel content limit: the channel is not part of the
+// request, so callers apply CheckContent once they have loaded it.
+func (r *UpdateNotificationRequest) ValidateWith(rules ValidationRules) error {
+	if len(r.Fields()) == 0 {
+		return ErrEmptyUpdate
+	}
+	if r.Priority != nil && !r.Priority.IsValid() {
+		return ErrInvalidPriority
+	}
+	if r.MaxRetries != nil && (*r.MaxRetries < 0 || *r.MaxRetries > MaxRetriesLimit) {
+		return ErrInvalidMaxRetries
+	}
+	if r.Content != nil && *r.Content == "" {
 		return ErrInvalidContent
 	}
+	if r.ScheduledAt != nil {
+		return rules.checkSchedule(*r.ScheduledAt, r.SendIfPast)
+	}
 	return nil
 }
 
+// UpdatePrecondition guards an update against clobbering a concurrent one.
+// The zero value imposes nothing.
+type UpdatePrecondition struct {
+	// UpdatedAt, from an If-Match ETag, must equal the stored updated_at to
+	// the microsecond.
+	UpdatedAt *time.Time
+	// UnmodifiedSince, from If-Unmodified-Since, must not be before the
+	// stored updated_at truncated to whole seconds, the header's resolution.
+	UnmodifiedSince *time.Time
+}
+
+// IsZero reports whether p imposes no condition.
+func (p UpdatePrecondition) IsZero() bool {
+	return p.UpdatedAt == nil && p.UnmodifiedSince == nil
+}
+
+// Holds reports whether a record last updated at updatedAt satisfies p.
+func (p UpdatePrecondition) Holds(updatedAt time.Time) bool {
+	if p.UpdatedAt != nil && p.UpdatedAt.UnixMicro() != updatedAt.UnixMicro() {
+		return false
+	}
+	if p.UnmodifiedSince != nil && updatedAt.Truncate(time.Second).After(*p.UnmodifiedSince) {
+		return false
+	}
+	return true
+}
+
+// ChangePriorityRequest is the payload for POST /notifications/{id}/priority.
+type ChangePriorityRequest struct {
+	Priority Priority `json:"priority"`
+}
+
+// MaxCancelReasonLength caps the free-text reason given when cancelling.
+const MaxCancelReasonLength = 500
+
+// CancelRequest is the optional body of DELETE /notifications/{id}.
+// CorrelationID is filled in by the handler, not the client.
+type CancelRequest struct {
+	Reason        string `json:"reason,omitempty"`
+	CorrelationID string `json:"-"`
+}
+
+// Validate trims the reason and checks its length.
+func (r *CancelRequest) Validate() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if utf8.RuneCountInString(r.Reason) > MaxCancelReasonLength {
+		return ErrInvalidCancelReason
+	}
+	return nil
+}
+
+// Cancellation is what the repository records when a notification is cancelled.
+type Cancellation struct {
+	Reason        *string
+	By            *string
+	CorrelationID *string
+	At            time.Time
+}
+
 // CreateBatchRequest wraps a slice of notification requests.
+// TemplateID, when set, applies to every item that has neither content nor
+// its own template_id; such items only need to carry their variables.
+//
+// ScheduledAt likewise applies to every item without its own scheduled_at, so
+// a whole campaign can be scheduled at once.
+//
+// SendIfPast accepts past scheduled_at values, the batch's and every item's,
+// and DryRun makes every item a dry run.
+//
+// By default one invalid item rejects the whole batch. With AllowPartial the
+// valid items are created and the invalid ones are reported as BatchItemErrors.
+//
+// The API decodes this by hand to stream the items (see the handler's
+// decodeBatchJSON), so a new batch-level field must be added there as well.
 type CreateBatchRequest struct {
 	Notifications []CreateNotificationRequest `json:"notifications"`
+	TemplateID    *string                     `json:"template_id,omitempty"`
+	ScheduledAt   *time.Time                  `json:"scheduled_at,omitempty"`
+	SendIfPast    bool                        `json:"send_if_past,omitempty"`
+	DryRun        bool                        `json:"dry_run,omitempty"`
+	AllowPartial  bool                        `json:"allow_partial,omitempty"`
+}
+
+// ValidateWith checks the batch-level fields; items are validated one by one.
+func (r *CreateBatchRequest) ValidateWith(rules ValidationRules) error {
+	if r.ScheduledAt != nil {
+		return rules.checkSchedule(*r.ScheduledAt, r.SendIfPast)
+	}
+	return nil
+}
+
+// BatchItemError reports why the item at Index of a partially accepted batch
+// was rejected; Field and Code are as in FieldError.
+type BatchItemError struct {
+	Index int    `json:"index"`
+	Field string `json:"field,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
 }
 
 // ListFilter holds query parameters for paginated notification listing.
+// A zero Sort or Order means created_at, newest first.
 type ListFilter struct {
+	OwnerID *string
 	Status  *Status
 	Channel *Channel
+	DryRun  *bool
 	From    *time.Time
 	To      *time.Time
 	Page    int
 	Limit   int
+	Sort    SortField
+	Order   SortOrder
+}
+
+// SortField is a column notifications may be listed by.
+type SortField string
+
+const (
+	SortCreatedAt   SortField = "created_at"
+	SortUpdatedAt   SortField = "updated_at"
+	SortScheduledAt SortField = "scheduled_at"
+	SortSentAt      SortField = "sent_at"
+)
+
+func (f SortField) IsValid() bool {
+	switch f {
+	case SortCreatedAt, SortUpdatedAt, SortScheduledAt, SortSentAt:
+		return true
+	}
+	return false
+}
+
+// SortOrder is the direction of a listing.
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"
+	OrderDesc SortOrder = "desc"
+)
+
+func (o SortOrder) IsValid() bool {
+	return o == OrderAsc || o == OrderDesc
 }
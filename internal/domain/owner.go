@@ -0,0 +1,29 @@
+package domain
+
+import "context"
+
+type ownerKey struct{}
+
+// WithOwner returns a context carrying the authenticated caller's owner ID.
+// The API-key middleware sets it; the service scopes reads and writes by it.
+func WithOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, ownerID)
+}
+
+// OwnerFromContext returns the caller's owner ID. ok is false when the request
+// was not authenticated (authentication disabled, or internal callers such as
+// workers), in which case no ownership scoping applies.
+func OwnerFromContext(ctx context.Context) (ownerID string, ok bool) {
+	ownerID, ok = ctx.Value(ownerKey{}).(string)
+	return ownerID, ok
+}
+
+// OwnedBy reports whether a record with the given owner is visible to a
+// caller whose context is ctx.
+func OwnedBy(ctx context.Context, owner *string) bool {
+	caller, ok := OwnerFromContext(ctx)
+	if !ok {
+		return true
+	}
+	return owner != nil && *owner == caller
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+)
+
+// E.164 numbers carry at most 15 digits after the "+"; the shortest ones in
+// use have 7.
+const (
+	minPhoneDigits = 7
+	maxPhoneDigits = 15
+)
+
+// NormalizeRecipient returns the canonical form of recipient on channel, so
+// one address is stored, deduplicated and rate limited the same however the
+// client wrote it:
+//
+//   - sms: E.164, "+" followed by the digits. Spaces, dashes, dots, slashes
+//     and brackets are dropped and an international 00 prefix becomes "+". A
+//     national number with a leading 0 trunk prefix is given countryCode
+//     instead, when one is set. Anything that does not come out as a plausible
+//     E.164 number, including bare digits with neither prefix, is only trimmed.
+//   - email: trimmed and lowercased.
+//   - push, and any other channel: trimmed.
+func NormalizeRecipient(ch Channel, recipient, countryCode string) string {
+	recipient = strings.TrimSpace(recipient)
+	switch ch {
+	case ChannelSMS:
+		return normalizePhone(recipient, countryCode)
+	case ChannelEmail:
+		return strings.ToLower(recipient)
+	default:
+		return recipient
+	}
+}
+
+// normalizePhone implements NormalizeRecipient for sms. It returns s
+// unchanged when s is not recognisably a phone number.
+func normalizePhone(s, countryCode string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+		case unicode.IsSpace(r) || strings.ContainsRune("-./()", r):
+		default:
+			return s
+		}
+	}
+	digits := b.String()
+	switch {
+	case strings.HasPrefix(s, "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0") && countryCode != "":
+		digits = countryCode + digits[1:]
+	default:
+		return s
+	}
+	if len(digits) < minPhoneDigits || len(digits) > maxPhoneDigits || digits[0] == '0' {
+		return s
+	}
+	return "+" + digits
+}
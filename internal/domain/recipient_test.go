@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func TestNormalizeRecipient(t *testing.T) {
+	tests := []struct {
+		name        string
+		channel     domain.Channel
+		recipient   string
+		countryCode string
+		expected    string
+	}{
+		{"sms canonical", domain.ChannelSMS, "+905551234567", "", "+905551234567"},
+		{"sms spaced", domain.ChannelSMS, "+90 555 123 45 67", "", "+905551234567"},
+		{"sms punctuated", domain.ChannelSMS, " +1 (415) 555-0132 ", "", "+14155550132"},
+		{"sms dotted", domain.ChannelSMS, "+44.20.7946.0958", "", "+442079460958"},
+		{"sms international prefix", domain.ChannelSMS, "0090 555 123 45 67", "", "+905551234567"},
+		{"sms national with country code", domain.ChannelSMS, "05551234567", "90", "+905551234567"},
+		{"sms national spaced", domain.ChannelSMS, "0555 123 45 67", "90", "+905551234567"},
+		{"sms national without country code", domain.ChannelSMS, "05551234567", "", "05551234567"},
+		{"sms bare digits", domain.ChannelSMS, "905551234567", "90", "905551234567"},
+		{"sms too long", domain.ChannelSMS, "+90 5551 2345 6789 01", "", "+90 5551 2345 6789 01"},
+		{"sms too short", domain.ChannelSMS, "+90 555", "", "+90 555"},
+		{"sms letters", domain.ChannelSMS, "+90 555 CALL NOW", "", "+90 555 CALL NOW"},
+		{"sms plus inside", domain.ChannelSMS, "90+5551234567", "", "90+5551234567"},
+		{"email", domain.ChannelEmail, "  Alice.Smith@Example.COM ", "", "alice.smith@example.com"},
+		{"push", domain.ChannelPush, "\tDeviceToken-ABC123\n", "", "DeviceToken-ABC123"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := domain.NormalizeRecipient(tc.channel, tc.recipient, tc.countryCode); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
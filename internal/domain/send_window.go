@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // embed the zone database so timezones resolve in minimal images
+)
+
+// SendWindow restricts delivery to a daily local-time window, e.g. 08:00–22:00
+// in the recipient's timezone. Start is inclusive, End exclusive.
+type SendWindow struct {
+	Start    string // "HH:MM"
+	End      string // "HH:MM"
+	Timezone string // IANA name; empty means UTC
+}
+
+// Validate rejects malformed times, windows whose end is not after their start,
+// and unknown timezones.
+func (w SendWindow) Validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return ErrInvalidSendWindow
+	}
+	end, err := parseClock(w.End)
+	if err != nil || end <= start {
+		return ErrInvalidSendWindow
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return ErrUnknownTimezone
+	}
+	return nil
+}
+
+// NextOpening returns now if now falls inside the window, otherwise the next
+// time the window opens — later today, or tomorrow when the window has already
+// closed (the quiet period crosses midnight). The window must be valid.
+func (w SendWindow) NextOpening(now time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return now, true
+	}
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if minute >= start && minute < end {
+		return now, true
+	}
+
+	day := local.Day()
+	if minute >= end {
+		day++ // closed for today; open again tomorrow
+	}
+	// time.Date normalises day overflow and DST gaps.
+	return time.Date(local.Year(), local.Month(), day, start/60, start%60, 0, 0, loc), false
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("parse %q: %w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
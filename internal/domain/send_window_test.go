@@ -0,0 +1,67 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func TestSendWindow_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		window      domain.SendWindow
+		expectedErr error
+	}{
+		{"valid", domain.SendWindow{Start: "08:00", End: "22:00", Timezone: "Europe/Istanbul"}, nil},
+		{"utc by default", domain.SendWindow{Start: "08:00", End: "22:00"}, nil},
+		{"inverted", domain.SendWindow{Start: "22:00", End: "08:00"}, domain.ErrInvalidSendWindow},
+		{"empty", domain.SendWindow{Start: "09:00", End: "09:00"}, domain.ErrInvalidSendWindow},
+		{"missing end", domain.SendWindow{Start: "09:00"}, domain.ErrInvalidSendWindow},
+		{"bad clock", domain.SendWindow{Start: "25:00", End: "26:00"}, domain.ErrInvalidSendWindow},
+		{"unknown timezone", domain.SendWindow{Start: "08:00", End: "22:00", Timezone: "Mars/Olympus"}, domain.ErrUnknownTimezone},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.window.Validate(); !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSendWindow_NextOpening(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := domain.SendWindow{Start: "08:00", End: "22:00", Timezone: "Europe/Istanbul"}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, istanbul)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantOpen bool
+		want     time.Time
+	}{
+		{"inside", at(10, 12, 0), true, at(10, 12, 0)},
+		{"at start", at(10, 8, 0), true, at(10, 8, 0)},
+		{"before opening", at(10, 6, 30), false, at(10, 8, 0)},
+		{"at end", at(10, 22, 0), false, at(11, 8, 0)},
+		{"late evening crosses midnight", at(10, 23, 30), false, at(11, 8, 0)},
+		{"month end crosses midnight", at(31, 23, 0), false, time.Date(2026, time.April, 1, 8, 0, 0, 0, istanbul)},
+		// 21:30 UTC is 00:30 the next day in Istanbul (UTC+3).
+		{"evaluated in recipient timezone", time.Date(2026, time.March, 10, 21, 30, 0, 0, time.UTC), false, at(11, 8, 0)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, open := window.NextOpening(tc.now)
+			if open != tc.wantOpen || !got.Equal(tc.want) {
+				t.Fatalf("got (%s, %v), want (%s, %v)", got, open, tc.want, tc.wantOpen)
+			}
+		})
+	}
+}
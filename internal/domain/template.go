@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template is a named, server-side content template. Bodies use Go
+// text/template syntax with variables referenced as {{.name}}.
+type Template struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TemplateRequest is the inbound payload for creating or replacing a template.
+type TemplateRequest struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// MaxTemplateNameLength caps template names.
+const MaxTemplateNameLength = 100
+
+func (r *TemplateRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > MaxTemplateNameLength {
+		return ErrInvalidTemplateName
+	}
+	if r.Body == "" {
+		return ErrInvalidTemplate
+	}
+	if _, err := parseTemplate(r.Name, r.Body); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+// Render executes the template body with the given variables.
+// Referencing a variable that was not supplied is an error rather than
+// silently rendering "<no value>".
+func (t *Template) Render(vars map[string]string) (string, error) {
+	tmpl, err := parseTemplate(t.Name, t.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMissingTemplateVariable, err)
+	}
+	return buf.String(), nil
+}
+
+func parseTemplate(name, body string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(body)
+}
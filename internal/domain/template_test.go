@@ -0,0 +1,57 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl := domain.Template{Name: "otp", Body: "Hi {{.name}}, your code is {{.code}}."}
+
+	t.Run("all variables supplied", func(t *testing.T) {
+		got, err := tmpl.Render(map[string]string{"name": "Ada", "code": "1234"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "Hi Ada, your code is 1234." {
+			t.Fatalf("unexpected render: %q", got)
+		}
+	})
+
+	t.Run("missing variable", func(t *testing.T) {
+		_, err := tmpl.Render(map[string]string{"name": "Ada"})
+		if !errors.Is(err, domain.ErrMissingTemplateVariable) {
+			t.Fatalf("expected ErrMissingTemplateVariable, got %v", err)
+		}
+	})
+
+	t.Run("nil variables", func(t *testing.T) {
+		_, err := tmpl.Render(nil)
+		if !errors.Is(err, domain.ErrMissingTemplateVariable) {
+			t.Fatalf("expected ErrMissingTemplateVariable, got %v", err)
+		}
+	})
+}
+
+func TestTemplateRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         domain.TemplateRequest
+		expectedErr error
+	}{
+		{"valid", domain.TemplateRequest{Name: "otp", Body: "code {{.code}}"}, nil},
+		{"empty name", domain.TemplateRequest{Name: "  ", Body: "x"}, domain.ErrInvalidTemplateName},
+		{"empty body", domain.TemplateRequest{Name: "otp"}, domain.ErrInvalidTemplate},
+		{"unparsable body", domain.TemplateRequest{Name: "otp", Body: "code {{.code"}, domain.ErrInvalidTemplate},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.req.Validate()
+			if !errors.Is(err, tc.expectedErr) || (tc.expectedErr == nil && err != nil) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
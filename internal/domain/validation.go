@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError describes why one request field was rejected. Index is the
+// position of the offending item in a batch request, nil otherwise. Code is a
+// stable, machine-readable reason; Message is meant for humans.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Index   *int   `json:"index,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Err is the sentinel behind the failure, so errors.Is keeps working.
+	Err error `json:"-"`
+}
+
+// ValidationError reports one or more rejected fields. It matches every
+// wrapped sentinel under errors.Is, so callers can keep checking for, say,
+// ErrInvalidRecipient.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+		if f.Index != nil {
+			msgs[i] = fmt.Sprintf("item %d: %s", *f.Index, f.Message)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// fieldCodes maps validation sentinels to the field they concern and their
+// code. Order matters only where sentinels wrap each other, which none do.
+var fieldCodes = []struct {
+	err   error
+	field string
+	code  string
+}{
+	{ErrInvalidChannel, "channel", "invalid_channel"},
+	{ErrInvalidPriority, "priority", "invalid_priority"},
+	{ErrInvalidRecipient, "recipient", "required"},
+	{ErrRecipientTooLong, "recipient", "too_long"},
+	{ErrInvalidRecipients, "recipients", "invalid_recipients"},
+	{ErrInvalidContent, "content", "required"},
+	{ErrContentTooLong, "content", "too_long"},
+	{ErrInvalidMaxRetries, "max_retries", "out_of_range"},
+	{ErrScheduledInPast, "scheduled_at", "in_past"},
+	{ErrScheduleTooFar, "scheduled_at", "beyond_horizon"},
+	{ErrInvalidScheduledAt, "scheduled_at", "invalid_timestamp"},
+	{ErrEmptyUpdate, "", "empty_update"},
+	{ErrInvalidCancelReason, "reason", "too_long"},
+	{ErrEmailOnlyField, "email", "email_channel_only"},
+	{ErrInvalidEmailSubject, "email.subject", "invalid"},
+	{ErrInvalidAttachment, "email.attachments", "invalid"},
+	{ErrAttachmentsTooLarge, "email.attachments", "too_large"},
+	{ErrInvalidExpiry, "expires_at", "invalid_expiry"},
+	{ErrExpiryAndTTL, "ttl_seconds", "expiry_and_ttl"},
+	{ErrInvalidSendWindow, "send_window", "invalid"},
+	{ErrUnknownTimezone, "timezone", "unknown_timezone"},
+	{ErrInvalidCallbackURL, "callback_url", "invalid_url"},
+	{ErrCallbackLoop, "callback_url", "callback_loop"},
+	{ErrInvalidTemplateName, "name", "invalid"},
+	{ErrInvalidTemplate, "body", "invalid_template"},
+	{ErrUnknownTemplate, "template_id", "unknown_template"},
+	{ErrMissingTemplateVariable, "variables", "missing_variable"},
+	{ErrContentAndTemplate, "content", "content_and_template"},
+	{ErrBatchTooLarge, "notifications", "too_many"},
+	{ErrBatchEmpty, "notifications", "required"},
+	{ErrBatchAllRejected, "notifications", "all_rejected"},
+	{ErrInvalidLookup, "ids", "out_of_range"},
+}
+
+// IsValidationError reports whether err is, or wraps, a validation failure:
+// a *ValidationError or one of the sentinels that have a field code.
+func IsValidationError(err error) bool {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return true
+	}
+	for _, fc := range fieldCodes {
+		if errors.Is(err, fc.err) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFieldError describes err, which should wrap a validation sentinel, as a
+// FieldError. index, when non-nil, is the batch item it belongs to.
+func NewFieldError(err error, index *int) FieldError {
+	fe := FieldError{Index: index, Code: "invalid", Message: err.Error(), Err: err}
+	for _, fc := range fieldCodes {
+		if errors.Is(err, fc.err) {
+			fe.Field, fe.Code = fc.field, fc.code
+			break
+		}
+	}
+	return fe
+}
+
+// AsValidationError returns err as a *ValidationError, converting a bare
+// sentinel into a single-field one; nil and non-validation errors pass
+// through unchanged.
+func AsValidationError(err error) error {
+	var ve *ValidationError
+	if err == nil || errors.As(err, &ve) || !IsValidationError(err) {
+		return err
+	}
+	return &ValidationError{Fields: []FieldError{NewFieldError(err, nil)}}
+}
+
+// Details returns the field errors of err, converting a bare sentinel into a
+// single entry.
+func Details(err error) []FieldError {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Fields
+	}
+	return []FieldError{NewFieldError(err, nil)}
+}
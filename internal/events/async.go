@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Async decouples callers from a slow or unavailable broker. Publish only
+// places the event in a bounded buffer and never blocks or fails; Run hands
+// buffered events to the wrapped publisher. Events that do not fit in the
+// buffer, or that the wrapped publisher rejects, are dropped and reported
+// through onDrop.
+type Async struct {
+	next    Publisher
+	buf     chan Event
+	timeout time.Duration
+	onDrop  func()
+	logger  *zap.Logger
+}
+
+// NewAsync wraps next with a buffer of size events. timeout bounds each call
+// to next; onDrop may be nil.
+func NewAsync(next Publisher, size int, timeout time.Duration, onDrop func(), logger *zap.Logger) *Async {
+	if onDrop == nil {
+		onDrop = func() {}
+	}
+	return &Async{next: next, buf: make(chan Event, size), timeout: timeout, onDrop: onDrop, logger: logger}
+}
+
+// Publish buffers e, dropping it if the buffer is full. It always returns nil.
+func (a *Async) Publish(_ context.Context, e Event) error {
+	select {
+	case a.buf <- e:
+	default:
+		a.onDrop()
+	}
+	return nil
+}
+
+// Run delivers buffered events until ctx is cancelled, then makes one
+// last attempt at whatever is still buffered.
+func (a *Async) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-a.buf:
+					a.deliver(e)
+				default:
+					return
+				}
+			}
+		case e := <-a.buf:
+			a.deliver(e)
+		}
+	}
+}
+
+func (a *Async) deliver(e Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	if err := a.next.Publish(ctx, e); err != nil {
+		a.onDrop()
+		a.logger.Warn("dropped lifecycle event",
+			zap.String("type", string(e.Type)),
+			zap.String("notification_id", e.NotificationID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,62 @@
+// Package events publishes notification lifecycle events for downstream
+// consumers. Publishing is best-effort: it must never fail or slow down the
+// operation that caused the transition.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// Type names a lifecycle transition.
+type Type string
+
+const (
+	TypeCreated        Type = "created"
+	TypeQueued         Type = "queued"
+	TypeSent           Type = "sent"
+	TypeFailed         Type = "failed"
+	TypeRetryScheduled Type = "retry_scheduled"
+	TypeCancelled      Type = "cancelled"
+)
+
+// Event is one lifecycle transition of a notification. CorrelationID is the
+// ID of the API request that caused it; transitions made by the delivery
+// worker carry that of the request that created the notification.
+type Event struct {
+	Type           Type           `json:"type"`
+	NotificationID string         `json:"notification_id"`
+	BatchID        *string        `json:"batch_id,omitempty"`
+	Channel        domain.Channel `json:"channel"`
+	Status         domain.Status  `json:"status"`
+	CorrelationID  string         `json:"correlation_id,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	OccurredAt     time.Time      `json:"occurred_at"`
+}
+
+// New builds an event of type t for n, as of now. The correlation ID is read
+// from ctx.
+func New(ctx context.Context, t Type, n *domain.Notification) Event {
+	return Event{
+		Type:           t,
+		NotificationID: n.ID,
+		BatchID:        n.BatchID,
+		Channel:        n.Channel,
+		Status:         n.Status,
+		CorrelationID:  domain.CorrelationIDFromContext(ctx),
+		CreatedAt:      n.CreatedAt,
+		OccurredAt:     time.Now().UTC(),
+	}
+}
+
+// Publisher delivers events to a broker.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Nop discards every event; it is the default when no broker is configured.
+type Nop struct{}
+
+func (Nop) Publish(context.Context, Event) error { return nil }
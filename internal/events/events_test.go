@@ -0,0 +1,138 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []events.Event
+	err    error
+}
+
+func (r *recorder) Publish(_ context.Context, e events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func (r *recorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestNew_CarriesCorrelationID(t *testing.T) {
+	ctx := domain.WithCorrelationID(context.Background(), "req-1")
+	n := &domain.Notification{ID: "n1", Channel: domain.ChannelSMS, Status: domain.StatusPending}
+
+	e := events.New(ctx, events.TypeCreated, n)
+	if e.CorrelationID != "req-1" || e.NotificationID != "n1" || e.Status != domain.StatusPending {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	if e.OccurredAt.IsZero() {
+		t.Fatal("expected OccurredAt to be set")
+	}
+}
+
+func TestAsync_DropsWhenBufferFull(t *testing.T) {
+	var dropped int
+	a := events.NewAsync(&recorder{}, 1, time.Second, func() { dropped++ }, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		if err := a.Publish(context.Background(), events.Event{NotificationID: "n"}); err != nil {
+			t.Fatalf("Publish must not fail: %v", err)
+		}
+	}
+	if dropped != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", dropped)
+	}
+}
+
+func TestAsync_RunDeliversAndFlushes(t *testing.T) {
+	rec := &recorder{}
+	a := events.NewAsync(rec, 10, time.Second, nil, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		a.Publish(context.Background(), events.Event{NotificationID: "n"}) //nolint:errcheck
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.Run(ctx) // returns once the buffer is flushed
+
+	if rec.len() != 3 {
+		t.Fatalf("expected 3 delivered events, got %d", rec.len())
+	}
+}
+
+func TestAsync_DeliveryErrorCountsAsDrop(t *testing.T) {
+	var dropped int
+	rec := &recorder{err: errors.New("broker down")}
+	a := events.NewAsync(rec, 10, time.Second, func() { dropped++ }, zap.NewNop())
+	a.Publish(context.Background(), events.Event{NotificationID: "n"}) //nolint:errcheck
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.Run(ctx)
+
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", dropped)
+	}
+}
+
+func TestKafkaPublisher_PostsKeyedRecord(t *testing.T) {
+	var gotPath, gotType string
+	var body struct {
+		Records []struct {
+			Key   string       `json:"key"`
+			Value events.Event `json:"value"`
+		} `json:"records"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	p := events.NewKafkaPublisher(srv.URL+"/", "notification-events", time.Second)
+	err := p.Publish(context.Background(), events.Event{Type: events.TypeSent, NotificationID: "n1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/topics/notification-events" {
+		t.Fatalf("unexpected path %q", gotPath)
+	}
+	if gotType != "application/vnd.kafka.json.v2+json" {
+		t.Fatalf("unexpected content type %q", gotType)
+	}
+	if len(body.Records) != 1 || body.Records[0].Key != "n1" || body.Records[0].Value.Type != events.TypeSent {
+		t.Fatalf("unexpected records: %+v", body.Records)
+	}
+}
+
+func TestKafkaPublisher_NonOKIsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p := events.NewKafkaPublisher(srv.URL, "t", time.Second)
+	if err := p.Publish(context.Background(), events.Event{NotificationID: "n1"}); err == nil {
+		t.Fatal("expected error for non-200 response")
+	}
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaPublisher produces events to a Kafka topic through a Kafka REST Proxy
+// (v2 API), keyed by notification ID so each notification's events stay
+// ordered within a partition.
+type KafkaPublisher struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewKafkaPublisher returns a publisher posting to the REST proxy at baseURL.
+func NewKafkaPublisher(baseURL, topic string, timeout time.Duration) *KafkaPublisher {
+	return &KafkaPublisher{
+		endpoint:   strings.TrimRight(baseURL, "/") + "/topics/" + url.PathEscape(topic),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: []kafkaRecord{{Key: e.NotificationID, Value: e}}})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected kafka proxy status: %d", resp.StatusCode)
+	}
+	return nil
+}
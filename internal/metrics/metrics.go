@@ -1,11 +1,16 @@
 package metrics
 
 import (
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/version"
 )
 
 // Metrics groups all Prometheus instruments used across the application.
@@ -17,29 +22,91 @@ type Metrics struct {
 	QueueDepthHigh      prometheus.Gauge
 	QueueDepthNormal    prometheus.Gauge
 	QueueDepthLow       prometheus.Gauge
+	EventsDropped       prometheus.Counter
+	AuditDropped        prometheus.Counter
+	CreatesThrottled    *prometheus.CounterVec
+	EnqueueFailed       *prometheus.CounterVec
+	Retries             *prometheus.CounterVec
+	RetryWait           *prometheus.HistogramVec
+	RetriesDue          prometheus.Gauge
+	ScheduledOverdue    prometheus.Gauge
+	IsLeader            prometheus.Gauge
+	RateLimitWait       *prometheus.HistogramVec
+	RateLimitSlowWaits  *prometheus.CounterVec
+	RateLimit           *prometheus.GaugeVec
+	RecipientLimited    *prometheus.CounterVec
+	RecipientEvictions  *prometheus.CounterVec
+	ByStatus            *prometheus.GaugeVec
+	BatchesCompleted    *prometheus.CounterVec
+	BatchCompletion     prometheus.Histogram
+	BatchFailedItems    prometheus.Histogram
+	DeliveryLatency     *prometheus.HistogramVec
+	ScheduledLatency    *prometheus.HistogramVec
+	Polls               *prometheus.CounterVec
+	PollErrors          *prometheus.CounterVec
+	PollFound           *prometheus.GaugeVec
+	PollDuration        *prometheus.HistogramVec
+	PollLastSuccess     *prometheus.GaugeVec
+	PollTicksSkipped    *prometheus.CounterVec
+
+	// SentLastMinute and FailedLastMinute back the JSON snapshot's
+	// last_minute figures; they are not exported to Prometheus.
+	SentLastMinute   *RollingCounter
+	FailedLastMinute *RollingCounter
+
+	statusMu   sync.Mutex
+	statusSeen map[[2]string]bool // status, channel pairs last set on ByStatus
 }
 
+// Options tunes instruments whose buckets depend on the deployment.
+type Options struct {
+	// DeliveryBuckets bound the end-to-end delivery latency histograms
+	// (DELIVERY_LATENCY_BUCKETS); empty uses a seconds-to-a-day default.
+	DeliveryBuckets []time.Duration
+	// LatencyBuckets bound notification_processing_seconds, in seconds
+	// (LATENCY_BUCKETS); empty uses Prometheus' default buckets.
+	LatencyBuckets []float64
+	// RuntimeCollectors adds the standard go_* and process_* collectors,
+	// which the default registry would have carried (METRICS_RUNTIME).
+	RuntimeCollectors bool
+}
+
+// defaultDeliveryBuckets match config's DELIVERY_LATENCY_BUCKETS default.
+var defaultDeliveryBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 3 * 3600, 6 * 3600, 12 * 3600, 24 * 3600}
+
 // New registers all instruments with the given Prometheus registerer and
 // returns the populated Metrics struct.
 // Using a custom registry (instead of prometheus.DefaultRegisterer) keeps
 // tests isolated and avoids global state.
-func New(reg prometheus.Registerer) *Metrics {
+func New(reg prometheus.Registerer, opts Options) *Metrics {
+	latencyBuckets := opts.LatencyBuckets
+	if len(latencyBuckets) == 0 {
+		latencyBuckets = prometheus.DefBuckets
+	}
+	deliveryBuckets := defaultDeliveryBuckets
+	if len(opts.DeliveryBuckets) > 0 {
+		deliveryBuckets = make([]float64, len(opts.DeliveryBuckets))
+		for i, d := range opts.DeliveryBuckets {
+			deliveryBuckets[i] = d.Seconds()
+		}
+	}
+
 	m := &Metrics{
 		NotificationsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "notifications_sent_total",
 			Help: "Total number of successfully delivered notifications.",
-		}, []string{"channel"}),
+		}, []string{"channel", "priority"}),
 
 		NotificationsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "notifications_failed_total",
 			Help: "Total number of permanently failed notifications (retries exhausted).",
-		}, []string{"channel"}),
+		}, []string{"channel", "priority"}),
 
 		NotificationLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "notification_processing_seconds",
 			Help:    "End-to-end processing latency from dequeue to provider ack.",
-			Buckets: prometheus.DefBuckets,
-		}, []string{"channel"}),
+			Buckets: latencyBuckets,
+		}, []string{"channel", "priority"}),
 
 		QueueDepthHigh: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "queue_depth_high",
@@ -53,16 +120,178 @@ func New(reg prometheus.Registerer) *Metrics {
 			Name: "queue_depth_low",
 			Help: "Current number of items in the low-priority queue.",
 		}),
+		EventsDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "lifecycle_events_dropped_total",
+			Help: "Lifecycle events dropped because the buffer was full or the broker rejected them.",
+		}),
+		AuditDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "audit_entries_dropped_total",
+			Help: "Audit log entries dropped because the buffer was full or the database write failed.",
+		}),
+		CreatesThrottled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "creates_throttled_total",
+			Help: "Create requests rejected with 429 by the per-tenant rate limit.",
+		}, []string{"tenant"}),
+		EnqueueFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "notifications_enqueue_failed_total",
+			Help: "Enqueue attempts rejected because the priority's queue was full.",
+		}, []string{"priority", "source"}),
+		Retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "notifications_retries_total",
+			Help: "Retry activity by retry number (from 1): retries scheduled, retries that delivered, and last retries that failed too.",
+		}, []string{"channel", "attempt", "outcome"}),
+		RetryWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "notification_retry_wait_seconds",
+			Help:    "Time from a failed attempt to the retry worker putting the notification back on the queue.",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+		}, []string{"channel"}),
+		RetriesDue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "notifications_retries_due",
+			Help: "Retries past their next_retry_at found by the latest retry poll (at most one poll's batch).",
+		}),
+		ScheduledOverdue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "notifications_scheduled_overdue",
+			Help: "Scheduled notifications past their scheduled_at and not yet queued, counted after the latest scheduler poll.",
+		}),
+		IsLeader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "is_leader",
+			Help: "1 while this instance holds the leader lock and runs the background pollers, else 0; only set with LEADER_ELECTION.",
+		}),
+		RateLimitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rate_limiter_wait_seconds",
+			Help:    "Time a send waited on its channel's rate limiter before going to the provider.",
+			Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"channel"}),
+		RateLimitSlowWaits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limiter_slow_waits_total",
+			Help: "Sends that waited on the rate limiter for longer than RATE_LIMIT_SLOW_WAIT.",
+		}, []string{"channel"}),
+		RateLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "rate_limiter_limit_per_second",
+			Help: "Configured sends per second for each channel.",
+		}, []string{"channel"}),
+		RecipientLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "recipient_limited_total",
+			Help: "Sends rescheduled because their recipient reached its RECIPIENT_LIMITS limit.",
+		}, []string{"channel"}),
+		RecipientEvictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "recipient_limiter_evictions_total",
+			Help: "Recipients forgotten to stay within RECIPIENT_LIMITER_MAX_KEYS; active ones still had sends counting against their limit.",
+		}, []string{"channel", "active"}),
+		ByStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "notifications_by_status",
+			Help: "Stored notifications by status and channel, as of the latest periodic count.",
+		}, []string{"status", "channel"}),
+		BatchesCompleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "batch_completed_total",
+			Help: "Batches whose last pending member settled, by whether any member failed.",
+		}, []string{"with_failures"}),
+		BatchCompletion: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "batch_completion_seconds",
+			Help:    "Time from a batch being created to its last pending member settling.",
+			Buckets: []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 3 * 3600, 6 * 3600, 12 * 3600, 24 * 3600},
+		}),
+		BatchFailedItems: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "batch_failed_items",
+			Help:    "Failed members of each batch, recorded when the batch completes.",
+			Buckets: []float64{0, 1, 5, 10, 50, 100, 500, 1000},
+		}),
+		DeliveryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "notification_delivery_seconds",
+			Help:    "End-to-end latency from a notification being created to it being sent, including time pending, scheduled and queued.",
+			Buckets: deliveryBuckets,
+		}, []string{"channel", "priority"}),
+		ScheduledLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "notification_scheduled_delivery_seconds",
+			Help:    "Latency from a scheduled notification's scheduled_at to it being sent.",
+			Buckets: deliveryBuckets,
+		}, []string{"channel", "priority"}),
+		Polls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "poller_polls_total",
+			Help: "Polls made by the background pollers (scheduler, retry), failed ones included.",
+		}, []string{"poller"}),
+		PollErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "poller_poll_errors_total",
+			Help: "Polls that failed to query the database.",
+		}, []string{"poller"}),
+		PollFound: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poller_items_found",
+			Help: "Due items found by the poller's latest successful poll.",
+		}, []string{"poller"}),
+		PollDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "poller_poll_duration_seconds",
+			Help:    "Time a poll took, from the query to enqueueing the last item found.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"poller"}),
+		PollLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "poller_last_success_timestamp_seconds",
+			Help: "Unix time the poller's latest successful poll finished.",
+		}, []string{"poller"}),
+		PollTicksSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "poller_ticks_skipped_total",
+			Help: "Poll ticks skipped because the poll before them ran past them.",
+		}, []string{"poller"}),
+
+		SentLastMinute:   NewRollingCounter(time.Minute),
+		FailedLastMinute: NewRollingCounter(time.Minute),
 	}
 
+	// build_info is always 1; its labels say which build is running, so it
+	// can be joined onto other series or graphed across a rollout.
+	build := version.Get()
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Always 1; labelled with the version, commit, build time and Go version of the running build.",
+		ConstLabels: prometheus.Labels{
+			"version":    build.Version,
+			"commit":     build.Commit,
+			"build_time": build.BuildTime,
+			"go_version": build.GoVersion,
+		},
+	})
+	buildInfo.Set(1)
+
 	reg.MustRegister(
+		buildInfo,
 		m.NotificationsSent,
 		m.NotificationsFailed,
 		m.NotificationLatency,
 		m.QueueDepthHigh,
 		m.QueueDepthNormal,
 		m.QueueDepthLow,
+		m.EventsDropped,
+		m.AuditDropped,
+		m.CreatesThrottled,
+		m.EnqueueFailed,
+		m.Retries,
+		m.RetryWait,
+		m.RetriesDue,
+		m.ScheduledOverdue,
+		m.IsLeader,
+		m.RateLimitWait,
+		m.RateLimitSlowWaits,
+		m.RateLimit,
+		m.RecipientLimited,
+		m.RecipientEvictions,
+		m.ByStatus,
+		m.BatchesCompleted,
+		m.BatchCompletion,
+		m.BatchFailedItems,
+		m.DeliveryLatency,
+		m.ScheduledLatency,
+		m.Polls,
+		m.PollErrors,
+		m.PollFound,
+		m.PollDuration,
+		m.PollLastSuccess,
+		m.PollTicksSkipped,
 	)
+	if opts.RuntimeCollectors {
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
 
 	return m
 }
@@ -70,15 +299,162 @@ func New(reg prometheus.Registerer) *Metrics {
 // WorkerHooks returns the metric callback functions expected by worker.MetricHooks.
 // Centralises the prometheus observation calls so worker.go stays import-free.
 func (m *Metrics) WorkerHooks() (
-	onSent func(domain.Channel, time.Duration),
-	onFailed func(domain.Channel),
+	onSent func(domain.Channel, domain.Priority, time.Duration),
+	onFailed func(domain.Channel, domain.Priority),
 ) {
-	onSent = func(ch domain.Channel, latency time.Duration) {
-		m.NotificationsSent.WithLabelValues(string(ch)).Inc()
-		m.NotificationLatency.WithLabelValues(string(ch)).Observe(latency.Seconds())
+	onSent = func(ch domain.Channel, prio domain.Priority, latency time.Duration) {
+		m.NotificationsSent.WithLabelValues(string(ch), string(prio)).Inc()
+		m.SentLastMinute.Inc(time.Now())
+		m.NotificationLatency.WithLabelValues(string(ch), string(prio)).Observe(latency.Seconds())
 	}
-	onFailed = func(ch domain.Channel) {
-		m.NotificationsFailed.WithLabelValues(string(ch)).Inc()
+	onFailed = func(ch domain.Channel, prio domain.Priority) {
+		m.NotificationsFailed.WithLabelValues(string(ch), string(prio)).Inc()
+		m.FailedLastMinute.Inc(time.Now())
 	}
 	return
 }
+
+// OnDelivered records the end-to-end latency of a sent notification; it is
+// the worker's Hooks.OnDelivered. Scheduled notifications are also measured
+// from their scheduled_at, which is when they were due to go out.
+func (m *Metrics) OnDelivered(n *domain.Notification) {
+	if n.SentAt == nil {
+		return
+	}
+	ch, prio := string(n.Channel), string(n.Priority)
+	m.DeliveryLatency.WithLabelValues(ch, prio).Observe(n.SentAt.Sub(n.CreatedAt).Seconds())
+	if n.ScheduledAt != nil {
+		m.ScheduledLatency.WithLabelValues(ch, prio).Observe(max(n.SentAt.Sub(*n.ScheduledAt), 0).Seconds())
+	}
+}
+
+// OnQueueFull counts an enqueue rejected by a full queue; it is the
+// queue.FullHook handed to the service and the polling workers.
+func (m *Metrics) OnQueueFull(priority domain.Priority, source queue.Source) {
+	m.EnqueueFailed.WithLabelValues(string(priority), string(source)).Inc()
+}
+
+// OnRetry counts retry activity; it is the worker's Hooks.OnRetry.
+func (m *Metrics) OnRetry(channel domain.Channel, attempt int, outcome string) {
+	m.Retries.WithLabelValues(string(channel), strconv.Itoa(attempt), outcome).Inc()
+}
+
+// RetryHooks returns the retry worker's OnDue and OnRequeued callbacks,
+// which feed the due gauge and the wait histogram.
+func (m *Metrics) RetryHooks() (
+	onDue func(count int),
+	onRequeued func(channel domain.Channel, wait time.Duration),
+) {
+	onDue = func(count int) {
+		m.RetriesDue.Set(float64(count))
+	}
+	onRequeued = func(ch domain.Channel, wait time.Duration) {
+		m.RetryWait.WithLabelValues(string(ch)).Observe(wait.Seconds())
+	}
+	return
+}
+
+// SetScheduledOverdue records the scheduler's overdue backlog; it is the
+// scheduler's OnOverdue.
+func (m *Metrics) SetScheduledOverdue(count int) {
+	m.ScheduledOverdue.Set(float64(count))
+}
+
+// SetLeader records whether this instance is the leader; it is the
+// elector's onChange.
+func (m *Metrics) SetLeader(leader bool) {
+	if leader {
+		m.IsLeader.Set(1)
+	} else {
+		m.IsLeader.Set(0)
+	}
+}
+
+// PollHook returns the OnPoll callback of the named background poller.
+func (m *Metrics) PollHook(poller string) func(found int, elapsed time.Duration, err error) {
+	return func(found int, elapsed time.Duration, err error) {
+		m.Polls.WithLabelValues(poller).Inc()
+		m.PollDuration.WithLabelValues(poller).Observe(elapsed.Seconds())
+		if err != nil {
+			m.PollErrors.WithLabelValues(poller).Inc()
+			return
+		}
+		m.PollFound.WithLabelValues(poller).Set(float64(found))
+		m.PollLastSuccess.WithLabelValues(poller).SetToCurrentTime()
+	}
+}
+
+// OverrunHook returns the OnOverrun callback of the named background poller.
+func (m *Metrics) OverrunHook(poller string) func(skipped int) {
+	return func(skipped int) {
+		m.PollTicksSkipped.WithLabelValues(poller).Add(float64(skipped))
+	}
+}
+
+// RateLimiterHook returns the rate limiter's onWait callback. Waits longer
+// than slow are also counted as slow; slow <= 0 counts none.
+func (m *Metrics) RateLimiterHook(slow time.Duration) func(domain.Channel, time.Duration) {
+	return func(ch domain.Channel, waited time.Duration) {
+		m.RateLimitWait.WithLabelValues(string(ch)).Observe(waited.Seconds())
+		if slow > 0 && waited > slow {
+			m.RateLimitSlowWaits.WithLabelValues(string(ch)).Inc()
+		}
+	}
+}
+
+// SetRateLimits publishes each channel's configured rate.
+func (m *Metrics) SetRateLimits(limits map[domain.Channel]float64) {
+	for ch, perSec := range limits {
+		m.RateLimit.WithLabelValues(string(ch)).Set(perSec)
+	}
+}
+
+// RecipientLimiterHooks returns the recipient limiter's onThrottle and
+// onEvict callbacks.
+func (m *Metrics) RecipientLimiterHooks() (
+	onThrottle func(domain.Channel),
+	onEvict func(domain.Channel, bool),
+) {
+	onThrottle = func(ch domain.Channel) {
+		m.RecipientLimited.WithLabelValues(string(ch)).Inc()
+	}
+	onEvict = func(ch domain.Channel, active bool) {
+		m.RecipientEvictions.WithLabelValues(string(ch), strconv.FormatBool(active)).Inc()
+	}
+	return onThrottle, onEvict
+}
+
+// OnBatchCompleted records a completed batch; it is the BatchCounter's
+// onComplete callback.
+func (m *Metrics) OnBatchCompleted(b *domain.Batch) {
+	m.BatchesCompleted.WithLabelValues(strconv.FormatBool(b.Failed > 0)).Inc()
+	if b.CompletedAt != nil {
+		m.BatchCompletion.Observe(b.CompletedAt.Sub(b.CreatedAt).Seconds())
+	}
+	m.BatchFailedItems.Observe(float64(b.Failed))
+}
+
+// SetStatusCounts replaces the notifications_by_status series with counts;
+// pairs that no longer have any notifications are dropped.
+func (m *Metrics) SetStatusCounts(counts []domain.StatusCount) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	seen := make(map[[2]string]bool, len(counts))
+	for _, c := range counts {
+		key := [2]string{string(c.Status), string(c.Channel)}
+		seen[key] = true
+		m.ByStatus.WithLabelValues(key[0], key[1]).Set(float64(c.Count))
+	}
+	for key := range m.statusSeen {
+		if !seen[key] {
+			m.ByStatus.DeleteLabelValues(key[0], key[1])
+		}
+	}
+	m.statusSeen = seen
+}
+
+// LastMinute returns the notifications sent and permanently failed in the
+// minute up to now.
+func (m *Metrics) LastMinute(now time.Time) (sent, failed int64) {
+	return m.SentLastMinute.Count(now), m.FailedLastMinute.Count(now)
+}
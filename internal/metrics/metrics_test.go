@@ -0,0 +1,149 @@
+package metrics_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/metrics"
+)
+
+func TestMetrics_SetStatusCounts(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg, metrics.Options{})
+
+	m.SetStatusCounts([]domain.StatusCount{
+		{Status: domain.StatusPending, Channel: domain.ChannelSMS, Count: 4},
+		{Status: domain.StatusFailed, Channel: domain.ChannelEmail, Count: 2},
+	})
+	m.SetStatusCounts([]domain.StatusCount{
+		{Status: domain.StatusPending, Channel: domain.ChannelSMS, Count: 1},
+	})
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series []string
+	for _, f := range families {
+		if f.GetName() != "notifications_by_status" {
+			continue
+		}
+		for _, s := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range s.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["status"] != "pending" || labels["channel"] != "sms" || s.GetGauge().GetValue() != 1 {
+				t.Fatalf("unexpected series %v = %v", labels, s.GetGauge().GetValue())
+			}
+			series = append(series, labels["status"])
+		}
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected the failed/email series to be dropped, got %v", series)
+	}
+}
+
+func TestMetrics_OnDelivered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg, metrics.Options{DeliveryBuckets: []time.Duration{time.Minute, time.Hour}})
+
+	created := time.Now().Add(-2 * time.Hour)
+	scheduled := created.Add(90 * time.Minute)
+	sent := created.Add(2 * time.Hour)
+	m.OnDelivered(&domain.Notification{Channel: domain.ChannelSMS, Priority: domain.PriorityHigh, CreatedAt: created, SentAt: &sent})
+	m.OnDelivered(&domain.Notification{Channel: domain.ChannelSMS, Priority: domain.PriorityHigh, CreatedAt: created, ScheduledAt: &scheduled, SentAt: &sent})
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]uint64)
+	for _, f := range families {
+		if f.GetName() != "notification_delivery_seconds" && f.GetName() != "notification_scheduled_delivery_seconds" {
+			continue
+		}
+		for _, s := range f.GetMetric() {
+			h := s.GetHistogram()
+			got[f.GetName()] = h.GetSampleCount()
+			if f.GetName() == "notification_scheduled_delivery_seconds" && h.GetSampleSum() != (30*time.Minute).Seconds() {
+				t.Fatalf("expected the scheduled latency measured from scheduled_at, got %vs", h.GetSampleSum())
+			}
+			if len(h.GetBucket()) != 2 {
+				t.Fatalf("expected the configured buckets on %s, got %d", f.GetName(), len(h.GetBucket()))
+			}
+		}
+	}
+	if got["notification_delivery_seconds"] != 2 || got["notification_scheduled_delivery_seconds"] != 1 {
+		t.Fatalf("unexpected sample counts: %v", got)
+	}
+}
+
+func TestMetrics_PollHook(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg, metrics.Options{})
+	poll := m.PollHook("scheduler")
+
+	poll(3, 20*time.Millisecond, nil)
+	poll(0, time.Second, errors.New("connection refused"))
+
+	values := make(map[string]float64)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		for _, s := range f.GetMetric() {
+			switch {
+			case s.GetCounter() != nil:
+				values[f.GetName()] = s.GetCounter().GetValue()
+			case s.GetGauge() != nil:
+				values[f.GetName()] = s.GetGauge().GetValue()
+			}
+		}
+	}
+	if values["poller_polls_total"] != 2 || values["poller_poll_errors_total"] != 1 {
+		t.Fatalf("expected 2 polls and 1 error, got %v", values)
+	}
+	if values["poller_items_found"] != 3 || values["poller_last_success_timestamp_seconds"] == 0 {
+		t.Fatalf("expected the failed poll to leave the last success in place, got %v", values)
+	}
+}
+
+func TestMetrics_WorkerHooksLabelPriority(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg, metrics.Options{LatencyBuckets: []float64{1, 30, 60}})
+	onSent, onFailed := m.WorkerHooks()
+
+	onSent(domain.ChannelEmail, domain.PriorityHigh, 25*time.Second)
+	onFailed(domain.ChannelEmail, domain.PriorityLow)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priorities := make(map[string]string)
+	for _, f := range families {
+		for _, s := range f.GetMetric() {
+			for _, l := range s.GetLabel() {
+				if l.GetName() == "priority" {
+					priorities[f.GetName()] = l.GetValue()
+				}
+			}
+			if f.GetName() == "notification_processing_seconds" {
+				buckets := s.GetHistogram().GetBucket()
+				if len(buckets) != 3 || buckets[0].GetCumulativeCount() != 0 || buckets[1].GetCumulativeCount() != 1 {
+					t.Fatalf("expected a 25s send in the 30s bucket, got %v", buckets)
+				}
+			}
+		}
+	}
+	if priorities["notifications_sent_total"] != "high" || priorities["notification_processing_seconds"] != "high" ||
+		priorities["notifications_failed_total"] != "low" {
+		t.Fatalf("unexpected priority labels: %v", priorities)
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatter is satisfied by *pgxpool.Pool.
+type PoolStatter interface {
+	Stat() *pgxpool.Stat
+}
+
+// poolCollector reads the connection pool's statistics at scrape time, so
+// the figures are never staler than the scrape and no goroutine polls them.
+type poolCollector struct {
+	pool PoolStatter
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceled        *prometheus.Desc
+}
+
+// RegisterPool exports pool's connection statistics on reg.
+func RegisterPool(reg prometheus.Registerer, pool PoolStatter) {
+	reg.MustRegister(&poolCollector{
+		pool: pool,
+		acquired: prometheus.NewDesc("db_pool_acquired_conns",
+			"Connections currently checked out of the pool.", nil, nil),
+		idle: prometheus.NewDesc("db_pool_idle_conns",
+			"Idle connections in the pool.", nil, nil),
+		total: prometheus.NewDesc("db_pool_total_conns",
+			"Connections in the pool: acquired, idle and being established.", nil, nil),
+		max: prometheus.NewDesc("db_pool_max_conns",
+			"Largest size the pool may grow to (DB_MAX_CONNS).", nil, nil),
+		acquires: prometheus.NewDesc("db_pool_acquires_total",
+			"Successful connection acquisitions.", nil, nil),
+		acquireDuration: prometheus.NewDesc("db_pool_acquire_duration_seconds_total",
+			"Total time spent acquiring connections, including waits on a full pool.", nil, nil),
+		emptyAcquires: prometheus.NewDesc("db_pool_empty_acquires_total",
+			"Acquisitions that had to wait because no idle connection was available.", nil, nil),
+		canceled: prometheus.NewDesc("db_pool_canceled_acquires_total",
+			"Acquisitions abandoned because their context ended first.", nil, nil),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquires
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquires
+	ch <- c.canceled
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
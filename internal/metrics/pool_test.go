@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ricirt/event-driven-arch/internal/metrics"
+)
+
+func TestRegisterPool(t *testing.T) {
+	// The pool connects lazily, so no database is needed to read its stats.
+	pool, err := pgxpool.New(context.Background(), "postgres://app@127.0.0.1:1/app?pool_max_conns=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	reg := prometheus.NewRegistry()
+	metrics.RegisterPool(reg, pool)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]float64)
+	for _, f := range families {
+		m := f.GetMetric()[0]
+		if g := m.GetGauge(); g != nil {
+			got[f.GetName()] = g.GetValue()
+		} else {
+			got[f.GetName()] = m.GetCounter().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"db_pool_acquired_conns":                 0,
+		"db_pool_idle_conns":                     0,
+		"db_pool_total_conns":                    0,
+		"db_pool_max_conns":                      7,
+		"db_pool_acquires_total":                 0,
+		"db_pool_acquire_duration_seconds_total": 0,
+		"db_pool_empty_acquires_total":           0,
+		"db_pool_canceled_acquires_total":        0,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d metrics, got %v", len(want), got)
+	}
+	for name, v := range want {
+		if g, ok := got[name]; !ok || g != v {
+			t.Errorf("%s: expected %v, got %v (present: %v)", name, v, g, ok)
+		}
+	}
+}
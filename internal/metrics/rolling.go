@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// RollingCounter counts events over a sliding window in one-second buckets,
+// a ring indexed by Unix second. It answers "how many in the last minute"
+// for the JSON snapshot, which a Prometheus counter cannot without a query.
+type RollingCounter struct {
+	mu      sync.Mutex
+	seconds []int64 // Unix second each bucket currently counts
+	counts  []int64
+}
+
+// NewRollingCounter returns a counter over window, rounded down to whole
+// seconds and at least one.
+func NewRollingCounter(window time.Duration) *RollingCounter {
+	n := max(int(window/time.Second), 1)
+	return &RollingCounter{seconds: make([]int64, n), counts: make([]int64, n)}
+}
+
+// Inc records one event at now.
+func (c *RollingCounter) Inc(now time.Time) {
+	sec := now.Unix()
+	i := int(sec % int64(len(c.counts)))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seconds[i] != sec {
+		c.seconds[i], c.counts[i] = sec, 0
+	}
+	c.counts[i]++
+}
+
+// Count returns the events recorded in the window ending at now.
+func (c *RollingCounter) Count(now time.Time) int64 {
+	sec := now.Unix()
+	window := int64(len(c.counts))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total int64
+	for i, s := range c.seconds {
+		if s <= sec && sec-s < window {
+			total += c.counts[i]
+		}
+	}
+	return total
+}
@@ -0,0 +1,32 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/metrics"
+)
+
+func TestRollingCounter(t *testing.T) {
+	c := metrics.NewRollingCounter(time.Minute)
+	start := time.Unix(1_700_000_000, 0)
+
+	c.Inc(start)
+	c.Inc(start.Add(10 * time.Second))
+	c.Inc(start.Add(10 * time.Second))
+	if got := c.Count(start.Add(30 * time.Second)); got != 3 {
+		t.Fatalf("expected 3 within the window, got %d", got)
+	}
+	if got := c.Count(start.Add(65 * time.Second)); got != 2 {
+		t.Fatalf("expected the first event to have expired, got %d", got)
+	}
+
+	// Wrapping onto the first event's bucket replaces its count.
+	c.Inc(start.Add(time.Minute))
+	if got := c.Count(start.Add(time.Minute)); got != 3 {
+		t.Fatalf("expected 3 after wrapping, got %d", got)
+	}
+	if got := c.Count(start.Add(3 * time.Minute)); got != 0 {
+		t.Fatalf("expected nothing after the window, got %d", got)
+	}
+}
@@ -0,0 +1,160 @@
+// Package progress fans in-process updates out to the clients waiting on
+// them: batch counter snapshots for the progress stream, and settled
+// notifications for long-polling waits. Neither needs the database polled.
+package progress
+
+import (
+	"sync"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// registry is a subscription registry keyed by ID. publish never blocks:
+// every subscriber has a one-slot buffer holding the latest value, and a value
+// the client has not read yet is replaced by the newer one. A slow client
+// therefore skips intermediate values but cannot hold up delivery.
+type registry[T any] struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan T]struct{}
+	closed bool
+}
+
+func newRegistry[T any]() registry[T] {
+	return registry[T]{subs: make(map[string]map[chan T]struct{})}
+}
+
+func (r *registry[T]) subscribe(key string) (<-chan T, func()) {
+	ch := make(chan T, 1)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if r.subs[key] == nil {
+		r.subs[key] = make(map[chan T]struct{})
+	}
+	r.subs[key][ch] = struct{}{}
+	r.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if _, ok := r.subs[key][ch]; !ok {
+				return // already closed by close
+			}
+			delete(r.subs[key], ch)
+			if len(r.subs[key]) == 0 {
+				delete(r.subs, key)
+			}
+		})
+	}
+}
+
+func (r *registry[T]) publish(key string, v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ch := range r.subs[key] {
+		select {
+		case ch <- v:
+			continue
+		default:
+		}
+		// Full: discard the stale value and store the new one. publish
+		// holds the lock, so nobody else can refill the slot in between.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- v
+	}
+}
+
+func (r *registry[T]) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, chans := range r.subs {
+		for ch := range chans {
+			close(ch)
+		}
+	}
+	r.subs = make(map[string]map[chan T]struct{})
+}
+
+func (r *registry[T]) subscribers(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.subs[key])
+}
+
+// Hub fans batch counter snapshots out to the clients streaming a batch's
+// progress, keeping only the latest snapshot for a slow client.
+type Hub struct {
+	reg registry[domain.Batch]
+}
+
+func NewHub() *Hub {
+	return &Hub{reg: newRegistry[domain.Batch]()}
+}
+
+// Subscribe registers interest in batchID. The returned cancel func must be
+// called once the caller stops reading; it is safe to call more than once.
+// The channel is closed when the hub is, so readers should treat a closed
+// channel as the end of the stream.
+func (h *Hub) Subscribe(batchID string) (<-chan domain.Batch, func()) {
+	return h.reg.subscribe(batchID)
+}
+
+// Publish hands a copy of b to every subscriber of b.ID.
+func (h *Hub) Publish(b *domain.Batch) {
+	h.reg.publish(b.ID, *b)
+}
+
+// Close ends every subscription; main calls it on shutdown so open streams
+// do not hold the HTTP server up. Later subscriptions get a closed channel.
+func (h *Hub) Close() {
+	h.reg.close()
+}
+
+// Subscribers returns how many clients are watching batchID.
+func (h *Hub) Subscribers(batchID string) int {
+	return h.reg.subscribers(batchID)
+}
+
+// Waiters wakes the requests waiting for a notification to settle. Only
+// transitions made by this process are seen, so a waiter should read the
+// notification again when its own deadline passes.
+type Waiters struct {
+	reg registry[struct{}]
+}
+
+func NewWaiters() *Waiters {
+	return &Waiters{reg: newRegistry[struct{}]()}
+}
+
+// Subscribe registers interest in notificationID; the channel receives a
+// value when it settles and is closed on shutdown. cancel must be called
+// once the caller stops waiting.
+func (w *Waiters) Subscribe(notificationID string) (<-chan struct{}, func()) {
+	return w.reg.subscribe(notificationID)
+}
+
+// Notify wakes everyone waiting on n. It matches the OnTerminal hooks of the
+// workers and the service and never blocks.
+func (w *Waiters) Notify(n *domain.Notification) {
+	w.reg.publish(n.ID, struct{}{})
+}
+
+// Close wakes every waiter for shutdown.
+func (w *Waiters) Close() {
+	w.reg.close()
+}
+
+// Waiting returns how many requests are waiting on notificationID.
+func (w *Waiters) Waiting(notificationID string) int {
+	return w.reg.subscribers(notificationID)
+}
@@ -0,0 +1,86 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/progress"
+)
+
+func TestHub_SlowSubscriberGetsLatestSnapshot(t *testing.T) {
+	h := progress.NewHub()
+	updates, cancel := h.Subscribe("b1")
+	defer cancel()
+
+	// Nobody reads in between; Publish must neither block nor queue.
+	for pending := 3; pending >= 1; pending-- {
+		h.Publish(&domain.Batch{ID: "b1", Pending: pending})
+	}
+	h.Publish(&domain.Batch{ID: "other", Pending: 9})
+
+	if got := <-updates; got.Pending != 1 {
+		t.Fatalf("expected the latest snapshot (pending 1), got %d", got.Pending)
+	}
+	select {
+	case got := <-updates:
+		t.Fatalf("expected no further snapshots, got %+v", got)
+	default:
+	}
+}
+
+func TestHub_CancelUnsubscribes(t *testing.T) {
+	h := progress.NewHub()
+	_, cancel := h.Subscribe("b1")
+	_, cancel2 := h.Subscribe("b1")
+	defer cancel2()
+	if n := h.Subscribers("b1"); n != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", n)
+	}
+
+	cancel()
+	cancel() // idempotent
+	if n := h.Subscribers("b1"); n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+}
+
+func TestHub_CloseEndsSubscriptions(t *testing.T) {
+	h := progress.NewHub()
+	updates, cancel := h.Subscribe("b1")
+
+	h.Close()
+	cancel() // must not panic after Close
+
+	if _, ok := <-updates; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+	late, _ := h.Subscribe("b1")
+	if _, ok := <-late; ok {
+		t.Fatal("expected subscriptions after Close to be closed")
+	}
+}
+
+func TestWaiters_NotifyWakesOnlyThatNotification(t *testing.T) {
+	w := progress.NewWaiters()
+	settled, cancel := w.Subscribe("n1")
+	defer cancel()
+
+	w.Notify(&domain.Notification{ID: "other"})
+	select {
+	case <-settled:
+		t.Fatal("expected no wake-up for another notification")
+	default:
+	}
+
+	// Notify never blocks, even when the waiter has not read the last one.
+	w.Notify(&domain.Notification{ID: "n1"})
+	w.Notify(&domain.Notification{ID: "n1"})
+	if _, ok := <-settled; !ok {
+		t.Fatal("expected a wake-up, not a closed channel")
+	}
+
+	cancel()
+	if n := w.Waiting("n1"); n != 0 {
+		t.Fatalf("expected no waiters after cancel, got %d", n)
+	}
+}
@@ -11,6 +11,10 @@ type SendRequest struct {
 	To      string `json:"to"`
 	Channel string `json:"channel"`
 	Content string `json:"content"`
+
+	// Email carries the subject, HTML body and attachments of email
+	// notifications; omitted for other channels.
+	Email *domain.Email `json:"email,omitempty"`
 }
 
 // SendResponse maps the provider's 202 Accepted response body.
@@ -20,9 +24,19 @@ type SendResponse struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// SandboxMessageID is the provider message ID of dry runs, which the worker
+// marks sent without calling the provider.
+const SandboxMessageID = "sandbox"
+
 // Provider abstracts delivery to an external notification service.
 // Mocking this interface in tests gives full control over provider behaviour
 // without making real HTTP calls.
 type Provider interface {
 	Send(ctx context.Context, n *domain.Notification) (*SendResponse, error)
 }
+
+// HealthChecker reports whether a provider is reachable. The readiness probe
+// consults it when one is configured.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
@@ -8,7 +8,12 @@ import (
 	"net/http"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
 // WebhookProvider delivers notifications by POSTing to webhook.site.
@@ -29,11 +34,25 @@ func NewWebhookProvider(baseURL string, timeout time.Duration) *WebhookProvider
 
 // Send posts the notification to the configured webhook URL and
 // expects a 202 Accepted response with a JSON body containing messageId.
-func (p *WebhookProvider) Send(ctx context.Context, n *domain.Notification) (*SendResponse, error) {
+//
+// The call runs in a client span recording the provider's HTTP status; the
+// trace context and the notification's correlation ID are forwarded in the
+// request headers.
+func (p *WebhookProvider) Send(ctx context.Context, n *domain.Notification) (resp *SendResponse, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "provider.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("notification.id", n.ID),
+			attribute.String("notification.channel", string(n.Channel)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	body, err := json.Marshal(SendRequest{
 		To:      n.Recipient,
 		Channel: string(n.Channel),
 		Content: n.Content,
+		Email:   n.Email,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
@@ -44,24 +63,66 @@ func (p *WebhookProvider) Send(ctx context.Context, n *domain.Notification) (*Se
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if n.CorrelationID != nil {
+		req.Header.Set("X-Correlation-ID", *n.CorrelationID)
+	}
+	tracing.InjectHTTP(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := p.httpClient.Do(req)
+	httpResp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer httpResp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", httpResp.StatusCode))
 
-	if resp.StatusCode != http.StatusAccepted {
-		return nil, fmt.Errorf("unexpected provider status: %d", resp.StatusCode)
+	if httpResp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("unexpected provider status: %d", httpResp.StatusCode)
 	}
 
 	var sendResp SendResponse
-	if err := json.NewDecoder(resp.Body).Decode(&sendResp); err != nil {
+	if err := json.NewDecoder(httpResp.Body).Decode(&sendResp); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	span.SetAttributes(attribute.String("provider.message_id", sendResp.MessageID))
 
 	return &sendResp, nil
 }
 
-// compile-time check that WebhookProvider implements Provider
-var _ Provider = (*WebhookProvider)(nil)
+// HTTPHealthCheck probes a provider's health endpoint with GET and treats
+// any 2xx response as healthy.
+type HTTPHealthCheck struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewHTTPHealthCheck(url string, timeout time.Duration) *HTTPHealthCheck {
+	return &HTTPHealthCheck{
+		url: url,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (c *HTTPHealthCheck) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected provider health status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// compile-time checks
+var (
+	_ Provider      = (*WebhookProvider)(nil)
+	_ HealthChecker = (*HTTPHealthCheck)(nil)
+)
@@ -1,6 +1,10 @@
 package queue
 
-import "github.com/ricirt/event-driven-arch/internal/domain"
+import (
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
 
 // Item is the minimal data placed on the queue.
 // Workers fetch the full Notification from the DB using the ID,
@@ -9,4 +13,36 @@ type Item struct {
 	NotificationID string
 	Channel        domain.Channel
 	Priority       domain.Priority
+
+	// TraceParent is the W3C traceparent of the enqueuing span, so the worker
+	// can continue the trace; empty when tracing is off.
+	TraceParent string
+
+	// EnqueuedAt is when the item entered its current tier. The queue sets it
+	// unless the caller did.
+	EnqueuedAt time.Time
+}
+
+// Source names who enqueued an item, for the enqueue failure metric.
+type Source string
+
+const (
+	SourceAPI       Source = "api"       // single creates and manual requeues
+	SourceBatch     Source = "batch"     // batch creates
+	SourceScheduler Source = "scheduler" // scheduled notifications falling due
+	SourceRetry     Source = "retry"     // automatic retries
+)
+
+// FullHook is told about every Enqueue that failed with ErrQueueFull. It
+// must not block.
+type FullHook func(priority domain.Priority, source Source)
+
+// entryKey identifies a queue entry independently of its trace context.
+type entryKey struct {
+	id       string
+	priority domain.Priority
+}
+
+func keyOf(item Item) entryKey {
+	return entryKey{id: item.NotificationID, priority: item.Priority}
 }
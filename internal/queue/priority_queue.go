@@ -3,6 +3,8 @@ package queue
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
 )
@@ -18,17 +20,39 @@ import (
 // Workers dequeue via the double-select pattern, which guarantees that
 // high-priority items are always served before normal or low ones, while
 // still allowing fair competition between normal and low when high is empty.
+//
+// Channels cannot be edited in place, so Reprioritize enqueues a fresh copy and
+// tombstones the old entry; Dequeue discards tombstoned entries when it reaches
+// them. Depths therefore counts those stale entries until they are drained.
 type PriorityQueue struct {
 	high   chan Item
 	normal chan Item
 	low    chan Item
+
+	mu         sync.Mutex
+	live       map[string]domain.Priority // notification ID → priority of its live entry
+	tombstones map[entryKey]int           // stale entries still sitting in a channel
+
+	// enqueuedAt mirrors each tier's channel with its entries' EnqueuedAt,
+	// oldest first, since a channel's head cannot be peeked at.
+	enqueuedAt map[domain.Priority][]time.Time
 }
 
 func New() *PriorityQueue {
+	return NewWithCapacity(1000, 5000, 2000)
+}
+
+// NewWithCapacity creates a queue with explicit per-tier buffer sizes.
+// A capacity of zero yields a tier that rejects every Enqueue unless a worker
+// is already blocked in Dequeue, which tests use to exercise the queue-full path.
+func NewWithCapacity(high, normal, low int) *PriorityQueue {
 	return &PriorityQueue{
-		high:   make(chan Item, 1000),
-		normal: make(chan Item, 5000),
-		low:    make(chan Item, 2000),
+		high:       make(chan Item, high),
+		normal:     make(chan Item, normal),
+		low:        make(chan Item, low),
+		live:       make(map[string]domain.Priority),
+		tombstones: make(map[entryKey]int),
+		enqueuedAt: make(map[domain.Priority][]time.Time),
 	}
 }
 
@@ -36,6 +60,60 @@ func New() *PriorityQueue {
 // It is non-blocking: if the target channel is full, ErrQueueFull is returned
 // immediately rather than blocking the caller (the HTTP handler).
 func (q *PriorityQueue) Enqueue(item Item) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.push(item); err != nil {
+		return err
+	}
+	q.live[item.NotificationID] = item.Priority
+	return nil
+}
+
+// Reprioritize moves the queued entry for item.NotificationID to
+// item.Priority. It returns false when the notification is not waiting in the
+// queue (for example because a worker already took it). If the target tier is
+// full, ErrQueueFull is returned and the existing entry is left untouched.
+func (q *PriorityQueue) Reprioritize(item Item) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	current, ok := q.live[item.NotificationID]
+	if !ok {
+		return false, nil
+	}
+	if current == item.Priority {
+		return true, nil
+	}
+	if err := q.push(item); err != nil {
+		return false, err
+	}
+	q.tombstones[entryKey{id: item.NotificationID, priority: current}]++
+	q.live[item.NotificationID] = item.Priority
+	return true, nil
+}
+
+// Queued reports whether the notification is waiting in the queue. Once a
+// worker has taken it, it is no longer queued.
+func (q *PriorityQueue) Queued(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.live[id]
+	return ok
+}
+
+// push performs the non-blocking send for Enqueue and Reprioritize. Callers
+// hold q.mu.
+func (q *PriorityQueue) push(item Item) error {
+	if item.EnqueuedAt.IsZero() {
+		item.EnqueuedAt = time.Now()
+	}
+	if err := q.send(item); err != nil {
+		return err
+	}
+	q.enqueuedAt[item.Priority] = append(q.enqueuedAt[item.Priority], item.EnqueuedAt)
+	return nil
+}
+
+func (q *PriorityQueue) send(item Item) error {
 	switch item.Priority {
 	case domain.PriorityHigh:
 		select {
@@ -73,7 +151,20 @@ func (q *PriorityQueue) Enqueue(item Item) error {
 //     starvation while still letting the worker sleep instead of spinning.
 //
 // Returns (Item{}, false) when ctx is cancelled (graceful shutdown signal).
+// Tombstoned entries left behind by Reprioritize are skipped.
 func (q *PriorityQueue) Dequeue(ctx context.Context) (Item, bool) {
+	for {
+		item, ok := q.receive(ctx)
+		if !ok {
+			return Item{}, false
+		}
+		if q.claim(item) {
+			return item, true
+		}
+	}
+}
+
+func (q *PriorityQueue) receive(ctx context.Context) (Item, bool) {
 	// Step 1: drain high before entering a fair wait.
 	select {
 	case item := <-q.high:
@@ -94,8 +185,76 @@ func (q *PriorityQueue) Dequeue(ctx context.Context) (Item, bool) {
 	}
 }
 
+// claim reports whether a received item is live, consuming its tombstone if
+// it is not. The check runs under the lock, so a Reprioritize racing with the
+// receive either sees the entry still live (and tombstones it here) or finds
+// it already claimed and leaves it alone.
+//
+// Every received entry, live or not, also leaves the head of enqueuedAt.
+func (q *PriorityQueue) claim(item Item) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if times := q.enqueuedAt[item.Priority]; len(times) > 0 {
+		q.enqueuedAt[item.Priority] = times[1:]
+	}
+	key := keyOf(item)
+	if n := q.tombstones[key]; n > 0 {
+		if n == 1 {
+			delete(q.tombstones, key)
+		} else {
+			q.tombstones[key] = n - 1
+		}
+		return false
+	}
+	if q.live[item.NotificationID] == item.Priority {
+		delete(q.live, item.NotificationID)
+	}
+	return true
+}
+
+// Drain empties the queue and returns the live items it held, highest tier
+// first. Shutdown uses it, once nothing dequeues any more, to hand buffered
+// items back to the database instead of losing them with the process.
+func (q *PriorityQueue) Drain() []Item {
+	var items []Item
+	for _, ch := range []chan Item{q.high, q.normal, q.low} {
+	tier:
+		for {
+			select {
+			case item := <-ch:
+				if q.claim(item) {
+					items = append(items, item)
+				}
+			default:
+				break tier
+			}
+		}
+	}
+	return items
+}
+
 // Depths returns the current number of items waiting in each priority tier.
 // Used by the metrics handler for the queue-depth snapshot.
 func (q *PriorityQueue) Depths() (high, normal, low int) {
 	return len(q.high), len(q.normal), len(q.low)
 }
+
+// OldestAges returns how long the head of each priority tier has been waiting
+// at now, zero for an empty tier. Like Depths it counts entries tombstoned by
+// Reprioritize until they are drained.
+func (q *PriorityQueue) OldestAges(now time.Time) (high, normal, low time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	age := func(p domain.Priority) time.Duration {
+		if times := q.enqueuedAt[p]; len(times) > 0 {
+			return max(now.Sub(times[0]), 0)
+		}
+		return 0
+	}
+	return age(domain.PriorityHigh), age(domain.PriorityNormal), age(domain.PriorityLow)
+}
+
+// Capacities returns the buffer size of each priority tier.
+func (q *PriorityQueue) Capacities() (high, normal, low int) {
+	return cap(q.high), cap(q.normal), cap(q.low)
+}
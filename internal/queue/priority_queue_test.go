@@ -156,3 +156,161 @@ func TestPriorityQueue_Depths(t *testing.T) {
 		t.Fatalf("unexpected depths: high=%d normal=%d low=%d", high, normal, low)
 	}
 }
+
+func TestPriorityQueue_OldestAges(t *testing.T) {
+	q := queue.New()
+	now := time.Now()
+
+	for i, id := range []string{"n1", "n2"} {
+		it := item(id, domain.PriorityNormal)
+		it.EnqueuedAt = now.Add(time.Duration(i-2) * time.Minute)
+		_ = q.Enqueue(it)
+	}
+	_ = q.Enqueue(item("l", domain.PriorityLow)) // stamped by the queue
+
+	high, normal, low := q.OldestAges(now)
+	if high != 0 || normal != 2*time.Minute || low != 0 {
+		t.Fatalf("unexpected ages: high=%s normal=%s low=%s", high, normal, low)
+	}
+
+	// Normal and low compete fairly, so "l" may come out first.
+	got, _ := q.Dequeue(context.Background())
+	if got.NotificationID == "l" {
+		got, _ = q.Dequeue(context.Background())
+	}
+	if got.NotificationID != "n1" {
+		t.Fatalf("expected n1 first of the normal tier, got %s", got.NotificationID)
+	}
+	if _, normal, _ := q.OldestAges(now); normal != time.Minute {
+		t.Fatalf("expected the next normal item to be a minute old, got %s", normal)
+	}
+}
+
+func TestPriorityQueue_ReprioritizeTombstonesOldEntry(t *testing.T) {
+	q := queue.New()
+	ctx := context.Background()
+
+	_ = q.Enqueue(item("a", domain.PriorityLow))
+	_ = q.Enqueue(item("b", domain.PriorityNormal))
+
+	moved, err := q.Reprioritize(item("a", domain.PriorityHigh))
+	if err != nil || !moved {
+		t.Fatalf("expected the item to move, got moved=%v err=%v", moved, err)
+	}
+
+	first, _ := q.Dequeue(ctx)
+	second, _ := q.Dequeue(ctx)
+	if first.NotificationID != "a" || first.Priority != domain.PriorityHigh || second.NotificationID != "b" {
+		t.Fatalf("unexpected order: %+v then %+v", first, second)
+	}
+
+	// The stale low entry must be skipped, not handed out a second time.
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if got, ok := q.Dequeue(shortCtx); ok {
+		t.Fatalf("expected the tombstoned entry to be skipped, got %+v", got)
+	}
+}
+
+func TestPriorityQueue_ReprioritizeBackAndForth(t *testing.T) {
+	q := queue.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_ = q.Enqueue(item("a", domain.PriorityNormal))
+	_, _ = q.Reprioritize(item("a", domain.PriorityHigh))
+	_, _ = q.Reprioritize(item("a", domain.PriorityNormal))
+
+	got, ok := q.Dequeue(ctx)
+	if !ok || got.Priority != domain.PriorityNormal {
+		t.Fatalf("expected the live normal entry, got %+v (ok=%v)", got, ok)
+	}
+	if extra, ok := q.Dequeue(ctx); ok {
+		t.Fatalf("expected exactly one delivery, got an extra %+v", extra)
+	}
+}
+
+func TestPriorityQueue_ReprioritizeIgnoresTraceContext(t *testing.T) {
+	q := queue.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	traced := item("a", domain.PriorityLow)
+	traced.TraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	_ = q.Enqueue(traced)
+	_, _ = q.Reprioritize(item("a", domain.PriorityHigh))
+
+	if got, ok := q.Dequeue(ctx); !ok || got.Priority != domain.PriorityHigh {
+		t.Fatalf("expected the live high entry, got %+v (ok=%v)", got, ok)
+	}
+	if extra, ok := q.Dequeue(ctx); ok {
+		t.Fatalf("expected the traced stale entry to be skipped, got %+v", extra)
+	}
+}
+
+func TestPriorityQueue_ReprioritizeNotQueued(t *testing.T) {
+	q := queue.New()
+	ctx := context.Background()
+
+	_ = q.Enqueue(item("a", domain.PriorityNormal))
+	_, _ = q.Dequeue(ctx)
+
+	moved, err := q.Reprioritize(item("a", domain.PriorityHigh))
+	if err != nil || moved {
+		t.Fatalf("expected a dequeued item not to move, got moved=%v err=%v", moved, err)
+	}
+	if high, _, _ := q.Depths(); high != 0 {
+		t.Fatalf("expected nothing enqueued, got high=%d", high)
+	}
+}
+
+func TestPriorityQueue_ReprioritizeFullTierKeepsEntry(t *testing.T) {
+	q := queue.NewWithCapacity(0, 10, 10)
+	ctx := context.Background()
+
+	_ = q.Enqueue(item("a", domain.PriorityNormal))
+	if _, err := q.Reprioritize(item("a", domain.PriorityHigh)); err != domain.ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	got, ok := q.Dequeue(ctx)
+	if !ok || got.NotificationID != "a" || got.Priority != domain.PriorityNormal {
+		t.Fatalf("expected the original entry to remain live, got %+v", got)
+	}
+}
+
+func TestPriorityQueue_Queued(t *testing.T) {
+	q := queue.New()
+	ctx := context.Background()
+
+	_ = q.Enqueue(item("a", domain.PriorityNormal))
+	if !q.Queued("a") || q.Queued("b") {
+		t.Fatal("expected only a to be queued")
+	}
+	_, _ = q.Reprioritize(item("a", domain.PriorityHigh))
+	_, _ = q.Dequeue(ctx)
+	if q.Queued("a") {
+		t.Fatal("expected a dequeued item not to be queued")
+	}
+}
+
+func TestPriorityQueue_Drain(t *testing.T) {
+	q := queue.New()
+	_ = q.Enqueue(item("low", domain.PriorityLow))
+	_ = q.Enqueue(item("moved", domain.PriorityNormal))
+	_ = q.Enqueue(item("high", domain.PriorityHigh))
+	if _, err := q.Reprioritize(item("moved", domain.PriorityHigh)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, it := range q.Drain() {
+		got = append(got, it.NotificationID)
+	}
+
+	if len(got) != 3 || got[0] != "high" || got[1] != "moved" || got[2] != "low" {
+		t.Fatalf("expected each live item once, highest tier first, got %v", got)
+	}
+	if h, n, l := q.Depths(); h+n+l != 0 || q.Queued("moved") {
+		t.Fatal("expected the queue to be empty after Drain")
+	}
+}
@@ -2,6 +2,7 @@ package ratelimiter
 
 import (
 	"context"
+	"time"
 
 	"golang.org/x/time/rate"
 
@@ -14,19 +15,25 @@ import (
 // beyond the configured per-second maximum.
 type ChannelLimiters struct {
 	limiters map[domain.Channel]*rate.Limiter
+	onWait   func(ch domain.Channel, waited time.Duration)
 }
 
 // New creates a ChannelLimiters with ratePerSec tokens per second per channel.
-func New(ratePerSec int) *ChannelLimiters {
+// onWait, when set, is told how long every granted Wait blocked.
+func New(ratePerSec int, onWait func(ch domain.Channel, waited time.Duration)) *ChannelLimiters {
 	r := rate.Limit(ratePerSec)
 	burst := ratePerSec // burst == rate: prevents any "saved up" burst above the limit
 
+	if onWait == nil {
+		onWait = func(domain.Channel, time.Duration) {}
+	}
 	return &ChannelLimiters{
 		limiters: map[domain.Channel]*rate.Limiter{
 			domain.ChannelSMS:   rate.NewLimiter(r, burst),
 			domain.ChannelEmail: rate.NewLimiter(r, burst),
 			domain.ChannelPush:  rate.NewLimiter(r, burst),
 		},
+		onWait: onWait,
 	}
 }
 
@@ -34,5 +41,43 @@ func New(ratePerSec int) *ChannelLimiters {
 // Called by each worker immediately before sending to the provider.
 // Returns a non-nil error only if ctx is cancelled while waiting.
 func (cl *ChannelLimiters) Wait(ctx context.Context, ch domain.Channel) error {
-	return cl.limiters[ch].Wait(ctx)
+	start := time.Now()
+	if err := cl.limiters[ch].Wait(ctx); err != nil {
+		return err
+	}
+	cl.onWait(ch, time.Since(start))
+	return nil
+}
+
+// SetRate changes every channel's rate, and burst with it, to ratePerSec.
+// Safe to call while workers are waiting; waits already in progress finish
+// at the rate they started with.
+func (cl *ChannelLimiters) SetRate(ratePerSec int) {
+	for _, l := range cl.limiters {
+		l.SetLimit(rate.Limit(ratePerSec))
+		l.SetBurst(ratePerSec)
+	}
+}
+
+// Limits returns each channel's configured rate in tokens per second.
+func (cl *ChannelLimiters) Limits() map[domain.Channel]float64 {
+	out := make(map[domain.Channel]float64, len(cl.limiters))
+	for ch, l := range cl.limiters {
+		out[ch] = float64(l.Limit())
+	}
+	return out
+}
+
+// Utilisation reports how much of each channel's burst is currently spent,
+// from 0 (bucket full, idle) to 1 (bucket empty, workers are being held back).
+func (cl *ChannelLimiters) Utilisation() map[domain.Channel]float64 {
+	out := make(map[domain.Channel]float64, len(cl.limiters))
+	for ch, l := range cl.limiters {
+		if l.Burst() <= 0 {
+			out[ch] = 1
+			continue
+		}
+		out[ch] = min(max(1-l.Tokens()/float64(l.Burst()), 0), 1)
+	}
+	return out
 }
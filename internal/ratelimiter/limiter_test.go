@@ -0,0 +1,79 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+)
+
+func TestChannelLimiters_ReportsWaits(t *testing.T) {
+	var waits []time.Duration
+	cl := ratelimiter.New(20, func(ch domain.Channel, waited time.Duration) {
+		if ch != domain.ChannelSMS {
+			t.Errorf("unexpected channel %s", ch)
+		}
+		waits = append(waits, waited)
+	})
+	ctx := context.Background()
+
+	// The burst of 20 is granted at once; the 21st send waits for a refill.
+	for i := 0; i < 21; i++ {
+		if err := cl.Wait(ctx, domain.ChannelSMS); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(waits) != 21 {
+		t.Fatalf("expected 21 reported waits, got %d", len(waits))
+	}
+	if waits[0] > 10*time.Millisecond {
+		t.Fatalf("expected the first send not to wait, waited %s", waits[0])
+	}
+	if waits[20] < 20*time.Millisecond {
+		t.Fatalf("expected the 21st send to wait for a token, waited %s", waits[20])
+	}
+
+	// A wait cut short by its context is not reported.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := cl.Wait(cancelled, domain.ChannelSMS); err == nil {
+		t.Fatal("expected an error from a cancelled wait")
+	}
+	if len(waits) != 21 {
+		t.Fatalf("expected the cancelled wait not to be reported, got %d waits", len(waits))
+	}
+}
+
+func TestChannelLimiters_Limits(t *testing.T) {
+	limits := ratelimiter.New(50, nil).Limits()
+	for _, ch := range []domain.Channel{domain.ChannelSMS, domain.ChannelEmail, domain.ChannelPush} {
+		if limits[ch] != 50 {
+			t.Fatalf("expected %s limited to 50/s, got %v", ch, limits[ch])
+		}
+	}
+}
+
+func TestChannelLimiters_SetRate(t *testing.T) {
+	cl := ratelimiter.New(1, nil)
+	ctx := context.Background()
+	if err := cl.Wait(ctx, domain.ChannelPush); err != nil {
+		t.Fatal(err)
+	}
+
+	cl.SetRate(1000)
+	for ch, limit := range cl.Limits() {
+		if limit != 1000 {
+			t.Fatalf("expected %s at 1000/s, got %v", ch, limit)
+		}
+	}
+	// At the old rate the next token was a second away.
+	start := time.Now()
+	if err := cl.Wait(ctx, domain.ChannelPush); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Fatalf("expected the new rate to apply at once, waited %s", waited)
+	}
+}
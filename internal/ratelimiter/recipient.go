@@ -0,0 +1,175 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// RecipientLimit caps how many notifications one recipient is sent on a
+// channel within any window of Per, e.g. 3 per minute.
+type RecipientLimit struct {
+	Count int           `yaml:"count"`
+	Per   time.Duration `yaml:"per"`
+}
+
+// RecipientLimiter enforces RecipientLimits per channel and recipient. Each
+// recipient keeps the times of its last Count sends, a sliding window rather
+// than a token bucket, so "3 per minute" is never 4 in any minute.
+//
+// Memory is bounded: at most maxKeys recipients are tracked, and the least
+// recently used one is forgotten to make room for another. A recipient
+// forgotten while its window still held sends may be sent more than its
+// limit; onEvict reports such evictions as active.
+type RecipientLimiter struct {
+	limits     map[domain.Channel]RecipientLimit
+	maxKeys    int
+	onThrottle func(ch domain.Channel)
+	onEvict    func(ch domain.Channel, active bool)
+
+	mu   sync.Mutex
+	keys map[recipientKey]*list.Element
+	lru  *list.List // front = most recently used
+}
+
+type recipientKey struct {
+	channel   domain.Channel
+	recipient string
+}
+
+type recipientSends struct {
+	key  recipientKey
+	sent []time.Time // oldest first, at most the limit's Count
+}
+
+// NewRecipient returns a limiter applying limits to each channel's
+// recipients; channels without a limit are not limited, nor tracked.
+// onThrottle, when set, is called for every rejected Allow, and onEvict for
+// every recipient forgotten to stay within maxKeys.
+func NewRecipient(
+	limits map[domain.Channel]RecipientLimit,
+	maxKeys int,
+	onThrottle func(ch domain.Channel),
+	onEvict func(ch domain.Channel, active bool),
+) *RecipientLimiter {
+	if onThrottle == nil {
+		onThrottle = func(domain.Channel) {}
+	}
+	if onEvict == nil {
+		onEvict = func(domain.Channel, bool) {}
+	}
+	return &RecipientLimiter{
+		limits:     limits,
+		maxKeys:    max(maxKeys, 1),
+		onThrottle: onThrottle,
+		onEvict:    onEvict,
+		keys:       make(map[recipientKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Allow charges one send to recipient on ch. When the recipient has used up
+// its window nothing is charged, and retryAfter says how long until the
+// oldest send in the window ages out.
+func (rl *RecipientLimiter) Allow(ch domain.Channel, recipient string) (retryAfter time.Duration, ok bool) {
+	retryAfter, ok = rl.allow(ch, recipient, time.Now())
+	if !ok {
+		rl.onThrottle(ch)
+	}
+	return retryAfter, ok
+}
+
+// Wait blocks until a send to recipient on ch is allowed, and charges it.
+// Returns a non-nil error only if ctx is cancelled while waiting.
+func (rl *RecipientLimiter) Wait(ctx context.Context, ch domain.Channel, recipient string) error {
+	for {
+		retryAfter, ok := rl.allow(ch, recipient, time.Now())
+		if ok {
+			return nil
+		}
+		t := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Release refunds the latest send charged to recipient on ch, for a send
+// that was allowed but then abandoned before it went out.
+func (rl *RecipientLimiter) Release(ch domain.Channel, recipient string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	el, ok := rl.keys[recipientKey{ch, recipient}]
+	if !ok {
+		return
+	}
+	s := el.Value.(*recipientSends)
+	if len(s.sent) > 0 {
+		s.sent = s.sent[:len(s.sent)-1]
+	}
+}
+
+// Len returns the number of recipients currently tracked.
+func (rl *RecipientLimiter) Len() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.lru.Len()
+}
+
+func (rl *RecipientLimiter) allow(ch domain.Channel, recipient string, now time.Time) (time.Duration, bool) {
+	limit, ok := rl.limits[ch]
+	if !ok || limit.Count <= 0 || limit.Per <= 0 {
+		return 0, true
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	s := rl.sends(recipientKey{ch, recipient})
+
+	// Drop sends that have aged out of the window.
+	cutoff := now.Add(-limit.Per)
+	i := 0
+	for i < len(s.sent) && !s.sent[i].After(cutoff) {
+		i++
+	}
+	s.sent = s.sent[i:]
+
+	if len(s.sent) >= limit.Count {
+		return s.sent[len(s.sent)-limit.Count].Sub(cutoff), false
+	}
+	s.sent = append(s.sent, now)
+	return 0, true
+}
+
+// sends returns key's entry, creating it and evicting the least recently
+// used ones if needed. Callers must hold rl.mu.
+func (rl *RecipientLimiter) sends(key recipientKey) *recipientSends {
+	if el, ok := rl.keys[key]; ok {
+		rl.lru.MoveToFront(el)
+		return el.Value.(*recipientSends)
+	}
+	for rl.lru.Len() >= rl.maxKeys {
+		oldest := rl.lru.Back()
+		evicted := oldest.Value.(*recipientSends)
+		rl.lru.Remove(oldest)
+		delete(rl.keys, evicted.key)
+		rl.onEvict(evicted.key.channel, rl.active(evicted))
+	}
+	s := &recipientSends{key: key}
+	rl.keys[key] = rl.lru.PushFront(s)
+	return s
+}
+
+// active reports whether s still holds sends within its window.
+func (rl *RecipientLimiter) active(s *recipientSends) bool {
+	if len(s.sent) == 0 {
+		return false
+	}
+	return time.Since(s.sent[len(s.sent)-1]) < rl.limits[s.key.channel].Per
+}
@@ -0,0 +1,200 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+)
+
+var threePerHour = map[domain.Channel]ratelimiter.RecipientLimit{
+	domain.ChannelSMS: {Count: 3, Per: time.Hour},
+}
+
+func TestRecipientLimiter_AllowsCountPerWindow(t *testing.T) {
+	var throttled []domain.Channel
+	rl := ratelimiter.NewRecipient(threePerHour, 100, func(ch domain.Channel) { throttled = append(throttled, ch) }, nil)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); !ok {
+			t.Fatalf("send %d should be allowed", i+1)
+		}
+	}
+	wait, ok := rl.Allow(domain.ChannelSMS, "+15550100001")
+	if ok || wait < 59*time.Minute || wait > time.Hour {
+		t.Fatalf("expected the fourth send throttled until the first ages out, got ok=%v wait=%s", ok, wait)
+	}
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100002"); !ok {
+		t.Fatal("another recipient must have its own window")
+	}
+	if _, ok := rl.Allow(domain.ChannelEmail, "+15550100001"); !ok {
+		t.Fatal("a channel without a limit must not be limited")
+	}
+	if len(throttled) != 1 || throttled[0] != domain.ChannelSMS {
+		t.Fatalf("expected one sms throttle, got %v", throttled)
+	}
+	if n := rl.Len(); n != 2 {
+		t.Fatalf("expected only the two sms recipients tracked, got %d", n)
+	}
+}
+
+func TestRecipientLimiter_WindowSlides(t *testing.T) {
+	rl := ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelPush: {Count: 2, Per: 100 * time.Millisecond},
+	}, 10, nil, nil)
+
+	rl.Allow(domain.ChannelPush, "device-1")
+	time.Sleep(50 * time.Millisecond)
+	rl.Allow(domain.ChannelPush, "device-1")
+
+	// The window is full until the first send, 50ms ago, ages out.
+	wait, ok := rl.Allow(domain.ChannelPush, "device-1")
+	if ok || wait > 50*time.Millisecond {
+		t.Fatalf("expected a wait of at most 50ms, got ok=%v wait=%s", ok, wait)
+	}
+	time.Sleep(wait)
+	if _, ok := rl.Allow(domain.ChannelPush, "device-1"); !ok {
+		t.Fatal("expected a send once the oldest aged out")
+	}
+	if _, ok := rl.Allow(domain.ChannelPush, "device-1"); ok {
+		t.Fatal("expected the second send of the window still to count")
+	}
+}
+
+func TestRecipientLimiter_ConcurrentAllowNeverExceedsLimit(t *testing.T) {
+	rl := ratelimiter.NewRecipient(threePerHour, 100, nil, nil)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); ok {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 3 {
+		t.Fatalf("expected exactly 3 of 1000 concurrent sends allowed, got %d", n)
+	}
+}
+
+func TestRecipientLimiter_ConcurrentRecipientsStayBounded(t *testing.T) {
+	const maxKeys = 64
+	var evictions atomic.Int32
+	rl := ratelimiter.NewRecipient(threePerHour, maxKeys, nil,
+		func(domain.Channel, bool) { evictions.Add(1) })
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				rl.Allow(domain.ChannelSMS, fmt.Sprintf("+1555%03d%04d", g, i))
+				if n := rl.Len(); n > maxKeys {
+					t.Errorf("tracking %d recipients, above the bound of %d", n, maxKeys)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := rl.Len(); n != maxKeys {
+		t.Fatalf("expected %d recipients tracked, got %d", maxKeys, n)
+	}
+	if n := evictions.Load(); n != 1600-maxKeys {
+		t.Fatalf("expected %d evictions, got %d", 1600-maxKeys, n)
+	}
+}
+
+func TestRecipientLimiter_EvictsLeastRecentlyUsed(t *testing.T) {
+	type eviction struct {
+		ch     domain.Channel
+		active bool
+	}
+	var evicted []eviction
+	rl := ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelSMS:   {Count: 1, Per: time.Hour},
+		domain.ChannelEmail: {Count: 1, Per: 50 * time.Millisecond},
+	}, 2, nil, func(ch domain.Channel, active bool) { evicted = append(evicted, eviction{ch, active}) })
+
+	rl.Allow(domain.ChannelEmail, "a@example.com")
+	rl.Allow(domain.ChannelSMS, "+15550100001")
+	time.Sleep(60 * time.Millisecond)
+
+	// a@example.com is the least recently used, and its window is over.
+	rl.Allow(domain.ChannelSMS, "+15550100002")
+	if len(evicted) != 1 || evicted[0] != (eviction{domain.ChannelEmail, false}) {
+		t.Fatalf("expected the idle email recipient evicted, got %v", evicted)
+	}
+
+	// Touching +15550100001 makes +15550100002 the next to go, while its
+	// send still counts.
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); ok {
+		t.Fatal("expected +15550100001 still throttled")
+	}
+	rl.Allow(domain.ChannelSMS, "+15550100003")
+	if len(evicted) != 2 || evicted[1] != (eviction{domain.ChannelSMS, true}) {
+		t.Fatalf("expected an active sms recipient evicted, got %v", evicted)
+	}
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); ok {
+		t.Fatal("expected the recently used recipient kept and still throttled")
+	}
+	// The evicted recipient starts over: this is what an active eviction costs.
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100002"); !ok {
+		t.Fatal("expected the evicted recipient to be forgotten")
+	}
+}
+
+func TestRecipientLimiter_Wait(t *testing.T) {
+	var throttled int
+	rl := ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelSMS: {Count: 1, Per: 50 * time.Millisecond},
+	}, 10, func(domain.Channel) { throttled++ }, nil)
+	ctx := context.Background()
+
+	if err := rl.Wait(ctx, domain.ChannelSMS, "+15550100001"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := rl.Wait(ctx, domain.ChannelSMS, "+15550100001"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Fatalf("expected the second send to wait for the window, waited %s", waited)
+	}
+	if throttled != 0 {
+		t.Fatalf("expected waits not to count as throttled, got %d", throttled)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := rl.Wait(cancelled, domain.ChannelSMS, "+15550100001"); err == nil {
+		t.Fatal("expected an error from a cancelled wait")
+	}
+}
+
+func TestRecipientLimiter_ReleaseRefundsLatestSend(t *testing.T) {
+	rl := ratelimiter.NewRecipient(threePerHour, 100, nil, nil)
+
+	rl.Release(domain.ChannelSMS, "+15550100001") // nothing charged yet: a no-op
+	for i := 0; i < 3; i++ {
+		rl.Allow(domain.ChannelSMS, "+15550100001")
+	}
+	rl.Release(domain.ChannelSMS, "+15550100001")
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); !ok {
+		t.Fatal("expected the refunded send to free up the window")
+	}
+	if _, ok := rl.Allow(domain.ChannelSMS, "+15550100001"); ok {
+		t.Fatal("expected only one send refunded")
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TenantLimits caps how fast one tenant may create notifications. A batch
+// counts as one request and as many notifications as it has items. Zero
+// disables the respective limit.
+type TenantLimits struct {
+	RequestsPerMinute      int `yaml:"requests_per_minute"`
+	NotificationsPerMinute int `yaml:"notifications_per_minute"`
+}
+
+// TenantLimiters holds a pair of token buckets per tenant, created on first
+// use. Buckets start full, so a tenant may spend a whole minute's allowance
+// at once before being throttled.
+type TenantLimiters struct {
+	defaults   TenantLimits
+	overrides  map[string]TenantLimits
+	onThrottle func(tenant string)
+
+	mu      sync.Mutex
+	tenants map[string]*tenantBuckets
+}
+
+type tenantBuckets struct {
+	requests      *rate.Limiter
+	notifications *rate.Limiter
+}
+
+// NewTenant returns limiters applying defaults to every tenant except those
+// in overrides. onThrottle, when set, is called for every rejected request.
+func NewTenant(defaults TenantLimits, overrides map[string]TenantLimits, onThrottle func(tenant string)) *TenantLimiters {
+	if onThrottle == nil {
+		onThrottle = func(string) {}
+	}
+	return &TenantLimiters{
+		defaults:   defaults,
+		overrides:  overrides,
+		onThrottle: onThrottle,
+		tenants:    make(map[string]*tenantBuckets),
+	}
+}
+
+// Allow charges one request and n notifications to tenant. When either
+// bucket is short nothing is charged, and retryAfter says how long until the
+// call would succeed. A request larger than the tenant's whole per-minute
+// allowance can never succeed; it is rejected with a one-minute retryAfter.
+func (tl *TenantLimiters) Allow(tenant string, n int) (retryAfter time.Duration, ok bool) {
+	b := tl.buckets(tenant)
+	now := time.Now()
+
+	req := b.requests.ReserveN(now, 1)
+	notif := b.notifications.ReserveN(now, n)
+	if req.OK() && notif.OK() {
+		wait := max(req.DelayFrom(now), notif.DelayFrom(now))
+		if wait == 0 {
+			return 0, true
+		}
+		retryAfter = wait
+	} else {
+		retryAfter = time.Minute
+	}
+	req.CancelAt(now)
+	notif.CancelAt(now)
+	tl.onThrottle(tenant)
+	return retryAfter, false
+}
+
+func (tl *TenantLimiters) buckets(tenant string) *tenantBuckets {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if b, ok := tl.tenants[tenant]; ok {
+		return b
+	}
+	limits, ok := tl.overrides[tenant]
+	if !ok {
+		limits = tl.defaults
+	}
+	b := &tenantBuckets{
+		requests:      perMinute(limits.RequestsPerMinute),
+		notifications: perMinute(limits.NotificationsPerMinute),
+	}
+	tl.tenants[tenant] = b
+	return b
+}
+
+// perMinute returns a bucket refilling limit tokens per minute with a burst
+// of limit, or an unlimited one when limit is zero.
+func perMinute(limit int) *rate.Limiter {
+	if limit <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(float64(limit)/60), limit)
+}
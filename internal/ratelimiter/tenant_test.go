@@ -0,0 +1,62 @@
+package ratelimiter_test
+
+import (
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+)
+
+func TestTenantLimiters_RequestsPerMinute(t *testing.T) {
+	var throttled []string
+	tl := ratelimiter.NewTenant(ratelimiter.TenantLimits{RequestsPerMinute: 2}, nil,
+		func(tenant string) { throttled = append(throttled, tenant) })
+
+	for i := 0; i < 2; i++ {
+		if _, ok := tl.Allow("a", 1); !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	wait, ok := tl.Allow("a", 1)
+	if ok || wait <= 0 {
+		t.Fatalf("expected third request to be throttled with a wait, got ok=%v wait=%s", ok, wait)
+	}
+	if _, ok := tl.Allow("b", 1); !ok {
+		t.Fatal("another tenant must have its own bucket")
+	}
+	if len(throttled) != 1 || throttled[0] != "a" {
+		t.Fatalf("expected one throttle for tenant a, got %v", throttled)
+	}
+}
+
+func TestTenantLimiters_NotificationsCountBatchSize(t *testing.T) {
+	tl := ratelimiter.NewTenant(ratelimiter.TenantLimits{NotificationsPerMinute: 10}, nil, nil)
+
+	if _, ok := tl.Allow("a", 8); !ok {
+		t.Fatal("batch of 8 should be allowed")
+	}
+	if _, ok := tl.Allow("a", 5); ok {
+		t.Fatal("batch of 5 should exceed the remaining allowance")
+	}
+	// The rejected batch must not have consumed anything.
+	if _, ok := tl.Allow("a", 2); !ok {
+		t.Fatal("batch of 2 should still fit")
+	}
+	if _, ok := tl.Allow("b", 11); ok {
+		t.Fatal("a batch larger than the whole allowance can never be allowed")
+	}
+}
+
+func TestTenantLimiters_Overrides(t *testing.T) {
+	tl := ratelimiter.NewTenant(ratelimiter.TenantLimits{RequestsPerMinute: 1},
+		map[string]ratelimiter.TenantLimits{"vip": {}}, nil)
+
+	for i := 0; i < 5; i++ {
+		if _, ok := tl.Allow("vip", 100); !ok {
+			t.Fatal("zero limits must mean unlimited")
+		}
+	}
+	tl.Allow("a", 1)
+	if _, ok := tl.Allow("a", 1); ok {
+		t.Fatal("default limit should apply to other tenants")
+	}
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// AuditRepository stores the append-only audit log of API mutations.
+type AuditRepository interface {
+	// Create appends e and sets its ID.
+	Create(ctx context.Context, e *domain.AuditEntry) error
+	// List returns one page of entries matching f, most recent first, and the
+	// total number of matches.
+	List(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEntry, int, error)
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// CallbackRepository persists outbound status webhooks so that deliveries
+// survive restarts.
+type CallbackRepository interface {
+	Create(ctx context.Context, cb *domain.Callback) error
+	// ClaimDue returns up to limit pending callbacks whose next attempt is due
+	// and pushes their next_attempt_at to leaseUntil, so a concurrent poller
+	// (here or on another instance) does not deliver them at the same time.
+	ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*domain.Callback, error)
+	MarkDelivered(ctx context.Context, id string, attempts int) error
+	ScheduleRetry(ctx context.Context, id string, attempts int, next time.Time, errMsg string) error
+	MarkFailed(ctx context.Context, id string, attempts int, errMsg string) error
+}
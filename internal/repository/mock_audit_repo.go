@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// MockAuditRepository is an in-memory AuditRepository for unit tests.
+type MockAuditRepository struct {
+	mu      sync.Mutex
+	entries []*domain.AuditEntry
+}
+
+func NewMockAuditRepository() *MockAuditRepository {
+	return &MockAuditRepository{}
+}
+
+func (m *MockAuditRepository) Create(_ context.Context, e *domain.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = int64(len(m.entries) + 1)
+	clone := *e
+	m.entries = append(m.entries, &clone)
+	return nil
+}
+
+func (m *MockAuditRepository) List(_ context.Context, f domain.AuditFilter) ([]*domain.AuditEntry, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []*domain.AuditEntry
+	for _, e := range slices.Backward(m.entries) {
+		switch {
+		case f.From != nil && e.OccurredAt.Before(*f.From),
+			f.To != nil && e.OccurredAt.After(*f.To),
+			f.OwnerID != nil && (e.OwnerID == nil || *e.OwnerID != *f.OwnerID),
+			f.Action != nil && e.Action != *f.Action,
+			f.TargetID != nil && (e.TargetID == nil || *e.TargetID != *f.TargetID):
+			continue
+		}
+		clone := *e
+		matched = append(matched, &clone)
+	}
+	total := len(matched)
+	start := min((f.Page-1)*f.Limit, total)
+	return matched[start:min(start+f.Limit, total)], total, nil
+}
+
+// All returns a snapshot of every stored entry in insertion order, for
+// assertions.
+func (m *MockAuditRepository) All() []*domain.AuditEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]*domain.AuditEntry, len(m.entries))
+	for i, e := range m.entries {
+		clone := *e
+		result[i] = &clone
+	}
+	return result
+}
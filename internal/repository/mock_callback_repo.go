@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// MockCallbackRepository is an in-memory CallbackRepository for unit tests.
+type MockCallbackRepository struct {
+	mu        sync.Mutex
+	callbacks map[string]*domain.Callback
+}
+
+func NewMockCallbackRepository() *MockCallbackRepository {
+	return &MockCallbackRepository{callbacks: make(map[string]*domain.Callback)}
+}
+
+func (m *MockCallbackRepository) Create(_ context.Context, cb *domain.Callback) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clone := *cb
+	m.callbacks[cb.ID] = &clone
+	return nil
+}
+
+func (m *MockCallbackRepository) ClaimDue(_ context.Context, limit int, leaseUntil time.Time) ([]*domain.Callback, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var due []*domain.Callback
+	for _, cb := range m.callbacks {
+		if cb.Status == domain.CallbackPending && !cb.NextAttemptAt.After(now) {
+			due = append(due, cb)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	result := make([]*domain.Callback, len(due))
+	for i, cb := range due {
+		cb.NextAttemptAt = leaseUntil
+		clone := *cb
+		result[i] = &clone
+	}
+	return result, nil
+}
+
+func (m *MockCallbackRepository) MarkDelivered(_ context.Context, id string, attempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cb, ok := m.callbacks[id]; ok {
+		cb.Status = domain.CallbackDelivered
+		cb.Attempts = attempts
+		cb.LastError = nil
+	}
+	return nil
+}
+
+func (m *MockCallbackRepository) ScheduleRetry(_ context.Context, id string, attempts int, next time.Time, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cb, ok := m.callbacks[id]; ok {
+		cb.Attempts = attempts
+		cb.NextAttemptAt = next
+		cb.LastError = &errMsg
+	}
+	return nil
+}
+
+func (m *MockCallbackRepository) MarkFailed(_ context.Context, id string, attempts int, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cb, ok := m.callbacks[id]; ok {
+		cb.Status = domain.CallbackFailed
+		cb.Attempts = attempts
+		cb.LastError = &errMsg
+	}
+	return nil
+}
+
+// All returns a snapshot of every stored callback, for assertions.
+func (m *MockCallbackRepository) All() []*domain.Callback {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]*domain.Callback, 0, len(m.callbacks))
+	for _, cb := range m.callbacks {
+		clone := *cb
+		result = append(result, &clone)
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ type MockNotificationRepository struct {
 	mu            sync.RWMutex
 	notifications map[string]*domain.Notification
 	batches       map[string]*domain.Batch
+	attempts      map[string][]domain.DeliveryAttempt
 
 	// Optional error overrides — set in tests to simulate failure paths.
 	CreateErr              error
@@ -25,6 +27,7 @@ func NewMockNotificationRepository() *MockNotificationRepository {
 	return &MockNotificationRepository{
 		notifications: make(map[string]*domain.Notification),
 		batches:       make(map[string]*domain.Batch),
+		attempts:      make(map[string][]domain.DeliveryAttempt),
 	}
 }
 
@@ -75,15 +78,106 @@ func (m *MockNotificationRepository) GetByIdempotencyKey(_ context.Context, key
 	return nil, domain.ErrNotFound
 }
 
-func (m *MockNotificationRepository) List(_ context.Context, _ domain.ListFilter) ([]*domain.Notification, int, error) {
+func (m *MockNotificationRepository) GetByIDs(_ context.Context, ids []string) ([]*domain.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*domain.Notification
+	for _, id := range ids {
+		if n, ok := m.notifications[id]; ok {
+			clone := *n
+			result = append(result, &clone)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockNotificationRepository) GetByProviderMsgID(_ context.Context, providerMsgID string) (*domain.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var newest *domain.Notification
+	for _, n := range m.notifications {
+		if n.ProviderMsgID == nil || *n.ProviderMsgID != providerMsgID {
+			continue
+		}
+		if newest == nil || n.CreatedAt.After(newest.CreatedAt) {
+			newest = n
+		}
+	}
+	if newest == nil {
+		return nil, domain.ErrNotFound
+	}
+	clone := *newest
+	return &clone, nil
+}
+
+func (m *MockNotificationRepository) FindRecentByDedupHash(_ context.Context, hash string, since time.Time) (*domain.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var newest *domain.Notification
+	for _, n := range m.notifications {
+		if n.DedupHash != hash || n.CreatedAt.Before(since) || n.Status == domain.StatusCancelled || n.Status == domain.StatusDraft {
+			continue
+		}
+		if newest == nil || n.CreatedAt.After(newest.CreatedAt) {
+			newest = n
+		}
+	}
+	if newest == nil {
+		return nil, domain.ErrNotFound
+	}
+	clone := *newest
+	return &clone, nil
+}
+
+// List applies only the owner filter; other filters and pagination are ignored.
+func (m *MockNotificationRepository) List(_ context.Context, filter domain.ListFilter) ([]*domain.Notification, int, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	result := make([]*domain.Notification, 0, len(m.notifications))
 	for _, n := range m.notifications {
+		if filter.OwnerID != nil && (n.OwnerID == nil || *n.OwnerID != *filter.OwnerID) {
+			continue
+		}
+		if filter.DryRun != nil && n.DryRun != *filter.DryRun {
+			continue
+		}
 		clone := *n
 		result = append(result, &clone)
 	}
-	return result, len(result), nil
+	sortNotifications(result, filter.Sort, filter.Order)
+	total := len(result)
+	if filter.Limit > 0 {
+		start := min(max(filter.Page-1, 0)*filter.Limit, total)
+		result = result[start:min(start+filter.Limit, total)]
+	}
+	return result, total, nil
+}
+
+// sortNotifications mirrors listOrderBy: nil timestamps last, id breaks ties.
+func sortNotifications(ns []*domain.Notification, field domain.SortField, order domain.SortOrder) {
+	key := func(n *domain.Notification) *time.Time {
+		switch field {
+		case domain.SortUpdatedAt:
+			return &n.UpdatedAt
+		case domain.SortScheduledAt:
+			return n.ScheduledAt
+		case domain.SortSentAt:
+			return n.SentAt
+		default:
+			return &n.CreatedAt
+		}
+	}
+	sort.SliceStable(ns, func(i, j int) bool {
+		a, b := key(ns[i]), key(ns[j])
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		case !a.Equal(*b):
+			return a.Before(*b) == (order == domain.OrderAsc)
+		default:
+			return (ns[i].ID < ns[j].ID) == (order == domain.OrderAsc)
+		}
+	})
 }
 
 func (m *MockNotificationRepository) UpdateStatus(_ context.Context, id string, status domain.Status) error {
@@ -102,6 +196,9 @@ func (m *MockNotificationRepository) MarkSent(_ context.Context, id, providerMsg
 		n.Status = domain.StatusSent
 		n.ProviderMsgID = &providerMsgID
 		n.SentAt = &sentAt
+		m.recordAttempt(id, domain.DeliveryAttempt{
+			Attempt: n.RetryCount + 1, Outcome: domain.AttemptSent, ProviderMsgID: &providerMsgID, AttemptedAt: sentAt,
+		})
 	}
 	return nil
 }
@@ -112,7 +209,24 @@ func (m *MockNotificationRepository) MarkFailed(_ context.Context, id, errMsg st
 	if n, ok := m.notifications[id]; ok {
 		n.Status = domain.StatusFailed
 		n.ErrorMessage = &errMsg
+		n.NextRetryAt = nil
+		n.UpdatedAt = time.Now().UTC()
+		m.recordAttempt(id, domain.DeliveryAttempt{
+			Attempt: n.RetryCount + 1, Outcome: domain.AttemptFailed, Error: &errMsg, AttemptedAt: n.UpdatedAt,
+		})
+	}
+	return nil
+}
+
+func (m *MockNotificationRepository) MarkExpired(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status == domain.StatusSent || n.Status == domain.StatusCancelled || n.Status == domain.StatusExpired {
+		return domain.ErrNotExpirable
 	}
+	n.Status = domain.StatusExpired
+	n.NextRetryAt = nil
 	return nil
 }
 
@@ -124,43 +238,343 @@ func (m *MockNotificationRepository) ScheduleRetry(_ context.Context, id string,
 		n.NextRetryAt = &nextRetry
 		n.ErrorMessage = &errMsg
 		n.Status = domain.StatusFailed
+		m.recordAttempt(id, domain.DeliveryAttempt{
+			Attempt: retryCount, Outcome: domain.AttemptFailed, Error: &errMsg, AttemptedAt: time.Now().UTC(),
+		})
+	}
+	return nil
+}
+
+// recordAttempt mirrors the delivery_attempts rows the pg repository writes.
+// Callers hold m.mu.
+func (m *MockNotificationRepository) recordAttempt(id string, a domain.DeliveryAttempt) {
+	m.attempts[id] = append(m.attempts[id], a)
+}
+
+func (m *MockNotificationRepository) ListDeadLetters(_ context.Context, f domain.DeadLetterFilter) ([]*domain.DeadLetter, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*domain.DeadLetter
+	for _, n := range m.notifications {
+		if n.Status != domain.StatusFailed || n.NextRetryAt != nil {
+			continue
+		}
+		if (f.Channel != nil && n.Channel != *f.Channel) ||
+			(f.From != nil && n.UpdatedAt.Before(*f.From)) ||
+			(f.To != nil && n.UpdatedAt.After(*f.To)) {
+			continue
+		}
+		dl := m.deadLetter(n)
+		dl.Attempts = nil
+		result = append(result, dl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.After(result[j].UpdatedAt) })
+	total := len(result)
+	if f.Limit > 0 {
+		start := min(max(f.Page-1, 0)*f.Limit, total)
+		result = result[start:min(start+f.Limit, total)]
 	}
+	return result, total, nil
+}
+
+func (m *MockNotificationRepository) GetDeadLetter(_ context.Context, id string) (*domain.DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusFailed || n.NextRetryAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	return m.deadLetter(n), nil
+}
+
+// deadLetter copies n with its attempts. Callers hold m.mu.
+func (m *MockNotificationRepository) deadLetter(n *domain.Notification) *domain.DeadLetter {
+	clone := *n
+	dl := &domain.DeadLetter{Notification: &clone, Attempts: append([]domain.DeliveryAttempt(nil), m.attempts[n.ID]...)}
+	dl.AttemptCount = len(dl.Attempts)
+	if dl.AttemptCount > 0 {
+		last := dl.Attempts[dl.AttemptCount-1].AttemptedAt
+		dl.LastAttemptAt = &last
+	}
+	return dl
+}
+
+func (m *MockNotificationRepository) Update(_ context.Context, n *domain.Notification, pre domain.UpdatePrecondition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.notifications[n.ID]
+	if ok && !pre.Holds(existing.UpdatedAt) {
+		return domain.ErrPreconditionFailed
+	}
+	if !ok || (existing.Status == domain.StatusDraft) != (n.Status == domain.StatusDraft) ||
+		(existing.Status == domain.StatusFailed) != (n.Status == domain.StatusFailed) {
+		return domain.ErrNotEditable
+	}
+	switch existing.Status {
+	case domain.StatusDraft, domain.StatusPending, domain.StatusScheduled, domain.StatusFailed:
+	default:
+		return domain.ErrNotEditable
+	}
+	n.UpdatedAt = time.Now().UTC()
+	clone := *n
+	m.notifications[n.ID] = &clone
 	return nil
 }
 
-func (m *MockNotificationRepository) Cancel(_ context.Context, id string) error {
+func (m *MockNotificationRepository) Submit(_ context.Context, id string, status domain.Status) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if n, ok := m.notifications[id]; ok {
-		n.Status = domain.StatusCancelled
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusDraft {
+		return domain.ErrNotDraft
+	}
+	n.Status = status
+	return nil
+}
+
+func (m *MockNotificationRepository) UpdatePriority(_ context.Context, id string, priority domain.Priority) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok {
+		return domain.ErrPriorityLocked
+	}
+	switch n.Status {
+	case domain.StatusDraft, domain.StatusPending, domain.StatusScheduled, domain.StatusQueued, domain.StatusFailed:
+		n.Priority = priority
+		return nil
+	}
+	return domain.ErrPriorityLocked
+}
+
+func (m *MockNotificationRepository) Reschedule(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || (n.Status != domain.StatusQueued && n.Status != domain.StatusScheduled && n.Status != domain.StatusFailed) {
+		return domain.ErrNotFound
 	}
+	n.Status = domain.StatusScheduled
+	n.ScheduledAt = &at
 	return nil
 }
 
-func (m *MockNotificationRepository) FindDueRetries(_ context.Context) ([]*domain.Notification, error) {
-	return nil, nil
+func (m *MockNotificationRepository) RequeueFailed(_ context.Context, id string, resetCount bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusFailed || (!resetCount && n.RetryCount >= n.MaxRetries) {
+		return domain.ErrNotRetryable
+	}
+	now := time.Now().UTC()
+	n.Status = domain.StatusQueued
+	n.NextRetryAt = &now
+	if resetCount {
+		n.RetryCount = 0
+	}
+	return nil
 }
 
-func (m *MockNotificationRepository) FindDueScheduled(_ context.Context) ([]*domain.Notification, error) {
-	return nil, nil
+func (m *MockNotificationRepository) Cancel(_ context.Context, id string, c domain.Cancellation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || !n.Status.IsCancellable() {
+		return domain.ErrNotCancellable
+	}
+	n.Status = domain.StatusCancelled
+	n.CancelledReason = c.Reason
+	n.CancelledAt = &c.At
+	n.CancelledBy = c.By
+	n.CancelCorrelationID = c.CorrelationID
+	return nil
 }
 
-func (m *MockNotificationRepository) CreateBatch(_ context.Context, batchID string, notifications []*domain.Notification) (*domain.Batch, error) {
+func (m *MockNotificationRepository) ClaimDueRetries(_ context.Context, limit int) ([]*domain.Notification, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	batch := &domain.Batch{
-		ID:        batchID,
-		Total:     len(notifications),
-		Pending:   len(notifications),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+	now := time.Now()
+	due := m.findDueLocked(limit, func(n *domain.Notification) *time.Time {
+		if n.Status != domain.StatusFailed || n.RetryCount > n.MaxRetries || n.NextRetryAt == nil ||
+			n.NextRetryAt.After(now) || n.IsExpired(now) {
+			return nil
+		}
+		return n.NextRetryAt
+	})
+	for _, n := range due {
+		m.notifications[n.ID].Status = domain.StatusQueued
+		n.Status = domain.StatusQueued
+	}
+	return due, nil
+}
+
+func (m *MockNotificationRepository) CountByStatus(_ context.Context) ([]domain.StatusCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	index := make(map[domain.StatusCount]int) // keyed with Count 0
+	var counts []domain.StatusCount
+	for _, n := range m.notifications {
+		key := domain.StatusCount{Status: n.Status, Channel: n.Channel}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Count++
 	}
-	m.batches[batchID] = batch
+	return counts, nil
+}
+
+func (m *MockNotificationRepository) FindDueScheduled(_ context.Context, limit int) ([]*domain.Notification, error) {
+	now := time.Now()
+	return m.findDue(limit, func(n *domain.Notification) *time.Time {
+		if n.Status != domain.StatusScheduled || n.ScheduledAt == nil || n.ScheduledAt.After(now) {
+			return nil
+		}
+		return n.ScheduledAt
+	}), nil
+}
+
+// findDue returns copies of up to limit notifications for which due returns
+// a time, earliest first.
+func (m *MockNotificationRepository) CountOverdueScheduled(_ context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, n := range m.notifications {
+		if n.Status == domain.StatusScheduled && n.ScheduledAt != nil && !n.ScheduledAt.After(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MockNotificationRepository) findDue(limit int, due func(*domain.Notification) *time.Time) []*domain.Notification {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.findDueLocked(limit, due)
+}
+
+// findDueLocked is findDue for callers that already hold m.mu.
+func (m *MockNotificationRepository) findDueLocked(limit int, due func(*domain.Notification) *time.Time) []*domain.Notification {
+	var result []*domain.Notification
+	for _, n := range m.notifications {
+		if due(n) != nil {
+			clone := *n
+			result = append(result, &clone)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return due(result[i]).Before(*due(result[j])) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (m *MockNotificationRepository) FindStalePending(_ context.Context, before time.Time, channel *domain.Channel, limit int) ([]*domain.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*domain.Notification
+	for _, n := range m.notifications {
+		if n.Status != domain.StatusPending || !n.UpdatedAt.Before(before) {
+			continue
+		}
+		if channel != nil && n.Channel != *channel {
+			continue
+		}
+		clone := *n
+		result = append(result, &clone)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *MockNotificationRepository) ClaimPending(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusPending {
+		return domain.ErrNotFound
+	}
+	n.Status = domain.StatusQueued
+	n.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MockNotificationRepository) ClaimScheduled(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusScheduled {
+		return domain.ErrNotFound
+	}
+	n.Status = domain.StatusQueued
+	n.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MockNotificationRepository) ClaimForProcessing(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusQueued {
+		return domain.ErrNotFound
+	}
+	n.Status = domain.StatusProcessing
+	n.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MockNotificationRepository) ReleaseToPending(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || (n.Status != domain.StatusQueued && n.Status != domain.StatusProcessing) {
+		return domain.ErrNotFound
+	}
+	n.Status = domain.StatusPending
+	n.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MockNotificationRepository) RevertQueued(_ context.Context, id string, status domain.Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notifications[id]
+	if !ok || n.Status != domain.StatusQueued {
+		return domain.ErrNotFound
+	}
+	n.Status = status
+	n.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *MockNotificationRepository) CreateBatch(_ context.Context, batch *domain.Batch, notifications []*domain.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if batch.IdempotencyKey != nil {
+		for _, existing := range m.batches {
+			if existing.IdempotencyKey != nil && *existing.IdempotencyKey == *batch.IdempotencyKey {
+				return domain.ErrConflict
+			}
+		}
+	}
+	batch.Total = len(notifications)
+	batch.Pending = len(notifications)
+	batch.CreatedAt = time.Now().UTC()
+	batch.UpdatedAt = batch.CreatedAt
+	stored := *batch
+	m.batches[batch.ID] = &stored
 	for _, n := range notifications {
 		clone := *n
 		m.notifications[n.ID] = &clone
 	}
-	return batch, nil
+	return nil
 }
 
 func (m *MockNotificationRepository) GetBatch(_ context.Context, batchID string) (*domain.Batch, []*domain.Notification, error) {
@@ -181,6 +595,70 @@ func (m *MockNotificationRepository) GetBatch(_ context.Context, batchID string)
 	return &batchClone, notifications, nil
 }
 
-func (m *MockNotificationRepository) UpdateBatchCounts(_ context.Context, _ string) error {
+func (m *MockNotificationRepository) GetBatchByID(_ context.Context, batchID string) (*domain.Batch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.batches[batchID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	clone := *b
+	return &clone, nil
+}
+
+func (m *MockNotificationRepository) GetBatchByIdempotencyKey(_ context.Context, key string) (*domain.Batch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, b := range m.batches {
+		if b.IdempotencyKey != nil && *b.IdempotencyKey == key {
+			clone := *b
+			return &clone, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *MockNotificationRepository) UpdateBatchCounts(_ context.Context, batchID string) (*domain.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[batchID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	b.Pending, b.Sent, b.Failed, b.Cancelled, b.Expired = 0, 0, 0, 0, 0
+	for _, n := range m.notifications {
+		if n.BatchID == nil || *n.BatchID != batchID {
+			continue
+		}
+		switch n.Status {
+		case domain.StatusSent:
+			b.Sent++
+		case domain.StatusFailed:
+			if n.NextRetryAt != nil {
+				b.Pending++
+			} else {
+				b.Failed++
+			}
+		case domain.StatusCancelled:
+			b.Cancelled++
+		case domain.StatusExpired:
+			b.Expired++
+		default:
+			b.Pending++
+		}
+	}
+	b.UpdatedAt = time.Now().UTC()
+	clone := *b
+	return &clone, nil
+}
+
+func (m *MockNotificationRepository) MarkBatchCompleted(_ context.Context, batchID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[batchID]
+	if !ok || b.CompletedAt != nil || b.Pending != 0 {
+		return domain.ErrNotFound
+	}
+	b.CompletedAt = &at
 	return nil
 }
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// MockTemplateRepository is an in-memory TemplateRepository for unit tests.
+type MockTemplateRepository struct {
+	mu        sync.RWMutex
+	templates map[string]*domain.Template
+}
+
+func NewMockTemplateRepository() *MockTemplateRepository {
+	return &MockTemplateRepository{templates: make(map[string]*domain.Template)}
+}
+
+func (m *MockTemplateRepository) Create(_ context.Context, t *domain.Template) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.templates {
+		if existing.Name == t.Name {
+			return domain.ErrTemplateNameTaken
+		}
+	}
+	clone := *t
+	m.templates[t.ID] = &clone
+	return nil
+}
+
+func (m *MockTemplateRepository) GetByID(_ context.Context, id string) (*domain.Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.templates[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	clone := *t
+	return &clone, nil
+}
+
+func (m *MockTemplateRepository) List(_ context.Context) ([]*domain.Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]*domain.Template, 0, len(m.templates))
+	for _, t := range m.templates {
+		clone := *t
+		result = append(result, &clone)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *MockTemplateRepository) Update(_ context.Context, t *domain.Template) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.templates[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	for id, other := range m.templates {
+		if id != t.ID && other.Name == t.Name {
+			return domain.ErrTemplateNameTaken
+		}
+	}
+	t.UpdatedAt = time.Now().UTC()
+	existing.Name, existing.Body, existing.UpdatedAt = t.Name, t.Body, t.UpdatedAt
+	return nil
+}
+
+func (m *MockTemplateRepository) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.templates[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(m.templates, id)
+	return nil
+}
@@ -13,17 +13,109 @@ import (
 type NotificationRepository interface {
 	Create(ctx context.Context, n *domain.Notification) error
 	GetByID(ctx context.Context, id string) (*domain.Notification, error)
+	// GetByIDs returns the notifications among ids that exist, in no
+	// particular order; unknown IDs are simply absent.
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Notification, error)
 	GetByIdempotencyKey(ctx context.Context, key string) (*domain.Notification, error)
+	GetByProviderMsgID(ctx context.Context, providerMsgID string) (*domain.Notification, error)
+	// FindRecentByDedupHash returns the newest non-cancelled notification with
+	// the given dedup hash created at or after since, or ErrNotFound.
+	FindRecentByDedupHash(ctx context.Context, hash string, since time.Time) (*domain.Notification, error)
 	List(ctx context.Context, filter domain.ListFilter) ([]*domain.Notification, int, error)
 	UpdateStatus(ctx context.Context, id string, status domain.Status) error
 	MarkSent(ctx context.Context, id string, providerMsgID string, sentAt time.Time) error
 	MarkFailed(ctx context.Context, id string, errMsg string) error
+	// MarkExpired moves a notification past its deadline to expired. Sent,
+	// cancelled and already expired rows are left alone with ErrNotExpirable.
+	MarkExpired(ctx context.Context, id string) error
 	ScheduleRetry(ctx context.Context, id string, retryCount int, nextRetry time.Time, errMsg string) error
-	Cancel(ctx context.Context, id string) error
-	FindDueRetries(ctx context.Context) ([]*domain.Notification, error)
-	FindDueScheduled(ctx context.Context) ([]*domain.Notification, error)
+	// Update persists content, priority, scheduled_at, max_retries and status
+	// edits. It only applies while the row is draft, pending, scheduled or
+	// failed, and neither a draft nor a failed row changes status, so a
+	// concurrent dispatch, submit or retry cannot be overwritten. A row that
+	// no longer matches yields ErrPreconditionFailed when pre is set (the
+	// trigger-maintained updated_at moves on every change) and ErrNotEditable
+	// otherwise.
+	Update(ctx context.Context, n *domain.Notification, pre domain.UpdatePrecondition) error
+	// Submit moves a draft to status, returning ErrNotDraft if the row is no
+	// longer a draft.
+	Submit(ctx context.Context, id string, status domain.Status) error
+	// UpdatePriority changes the stored priority while the row is draft, pending,
+	// scheduled, queued, or failed and returns ErrPriorityLocked otherwise.
+	UpdatePriority(ctx context.Context, id string, priority domain.Priority) error
+	// Reschedule hands a notification back to the scheduler worker for delivery
+	// at at. ErrNotFound is returned when it is no longer queued, scheduled or
+	// failed, so a cancellation in the meantime is not undone.
+	Reschedule(ctx context.Context, id string, at time.Time) error
+	// RequeueFailed atomically moves a failed notification to queued with
+	// next_retry_at set to now, zeroing retry_count when resetCount is true.
+	// Without reset it only applies while retries remain. ErrNotRetryable is
+	// returned when the row no longer qualifies.
+	RequeueFailed(ctx context.Context, id string, resetCount bool) error
+	// Cancel moves a notification to cancelled and records c alongside it. It
+	// only applies while the row is draft, pending, queued, scheduled or
+	// failed, so a worker that has meanwhile claimed or delivered it wins, and
+	// returns ErrNotCancellable otherwise.
+	Cancel(ctx context.Context, id string, c domain.Cancellation) error
+	// ClaimDueRetries moves up to limit failed notifications whose retry is
+	// due to queued and returns them, the longest overdue first. A claimed row
+	// is not returned again, even if the caller never writes to it; UpdatedAt
+	// is left at the time the retry was scheduled.
+	ClaimDueRetries(ctx context.Context, limit int) ([]*domain.Notification, error)
+	// FindDueScheduled returns up to limit scheduled notifications whose time
+	// has come, the longest overdue first.
+	FindDueScheduled(ctx context.Context, limit int) ([]*domain.Notification, error)
+	// CountOverdueScheduled counts the scheduled notifications whose time has
+	// come but that are not queued yet: the scheduler's backlog.
+	CountOverdueScheduled(ctx context.Context) (int, error)
+	// FindStalePending returns up to limit pending notifications, oldest
+	// first, whose status has not changed since before. A nil channel
+	// matches every channel.
+	FindStalePending(ctx context.Context, before time.Time, channel *domain.Channel, limit int) ([]*domain.Notification, error)
+	// ClaimPending atomically moves a pending notification to queued.
+	// ErrNotFound is returned when it is no longer pending.
+	ClaimPending(ctx context.Context, id string) error
+	// ClaimScheduled atomically moves a scheduled notification to queued.
+	// ErrNotFound is returned when it is no longer scheduled.
+	ClaimScheduled(ctx context.Context, id string) error
+	// ClaimForProcessing atomically moves a notification a worker took from
+	// the queue to processing. ErrNotFound is returned when it was cancelled,
+	// expired or claimed meanwhile. Everything that enqueues claims the row
+	// as queued first, so only queued rows qualify.
+	ClaimForProcessing(ctx context.Context, id string) error
+	// ReleaseToPending moves a notification a worker took but did not send,
+	// still queued or processing, back to pending, where RequeuePending can
+	// find it. ErrNotFound is returned when it has moved on meanwhile.
+	ReleaseToPending(ctx context.Context, id string) error
+	// RevertQueued moves a notification claimed as queued, but turned away
+	// by a full queue, back to status, pending or failed. ErrNotFound is
+	// returned when it is no longer queued, e.g. cancelled meanwhile.
+	RevertQueued(ctx context.Context, id string, status domain.Status) error
+	// CountByStatus counts all notifications by status and channel. Pairs
+	// with no notifications are left out.
+	CountByStatus(ctx context.Context) ([]domain.StatusCount, error)
 
-	CreateBatch(ctx context.Context, batchID string, notifications []*domain.Notification) (*domain.Batch, error)
+	// ListDeadLetters pages through failed notifications with no retry
+	// scheduled, most recently dead-lettered first, with their attempt
+	// counts. GetDeadLetter returns one with its full attempt history, or
+	// ErrNotFound when the notification is not dead-lettered.
+	ListDeadLetters(ctx context.Context, f domain.DeadLetterFilter) ([]*domain.DeadLetter, int, error)
+	GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error)
+
+	// CreateBatch stores batch and its notifications in one transaction,
+	// filling in the batch's counters and timestamps. A batch idempotency key
+	// that is already taken yields ErrConflict.
+	CreateBatch(ctx context.Context, batch *domain.Batch, notifications []*domain.Notification) error
 	GetBatch(ctx context.Context, batchID string) (*domain.Batch, []*domain.Notification, error)
-	UpdateBatchCounts(ctx context.Context, batchID string) error
+	// GetBatchByID reads only the batch row, without its notifications.
+	GetBatchByID(ctx context.Context, batchID string) (*domain.Batch, error)
+	GetBatchByIdempotencyKey(ctx context.Context, key string) (*domain.Batch, error)
+	// UpdateBatchCounts recomputes the batch's counters from its notifications
+	// and returns the refreshed batch. Concurrent calls for one batch are
+	// serialized, so the last to finish writes the newest counts.
+	UpdateBatchCounts(ctx context.Context, batchID string) (*domain.Batch, error)
+	// MarkBatchCompleted sets completed_at to at on a batch whose counters
+	// show nothing pending. ErrNotFound is returned when the batch is
+	// unknown, still has pending members, or was already completed.
+	MarkBatchCompleted(ctx context.Context, batchID string, at time.Time) error
 }
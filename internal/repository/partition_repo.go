@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// PartitionRepository manages the monthly range partitions of the notifications
+// table. It is only used when partitioning is enabled in config.
+// Months are identified by their first instant in UTC.
+type PartitionRepository interface {
+	EnsurePartition(ctx context.Context, month time.Time) error
+	ListPartitions(ctx context.Context) ([]time.Time, error)
+	DropPartition(ctx context.Context, month time.Time) error
+}
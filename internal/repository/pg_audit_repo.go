@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+type pgAuditRepository struct {
+	pool PgxPool
+}
+
+// NewPgAuditRepository returns an AuditRepository backed by PostgreSQL.
+func NewPgAuditRepository(pool PgxPool) AuditRepository {
+	return &pgAuditRepository{pool: pool}
+}
+
+func (r *pgAuditRepository) Create(ctx context.Context, e *domain.AuditEntry) error {
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO audit_log
+			(action, target_type, target_id, owner_id, key_id, client_ip,
+			 correlation_id, method, path, status, occurred_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING id`,
+		e.Action, e.TargetType, e.TargetID, e.OwnerID, e.KeyID, e.ClientIP,
+		e.CorrelationID, e.Method, e.Path, e.Status, e.OccurredAt,
+	).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	return nil
+}
+
+const auditWhere = `
+		WHERE ($1::timestamptz IS NULL OR occurred_at >= $1)
+		  AND ($2::timestamptz IS NULL OR occurred_at <= $2)
+		  AND ($3::text IS NULL OR owner_id = $3)
+		  AND ($4::text IS NULL OR action = $4)
+		  AND ($5::text IS NULL OR target_id = $5)`
+
+func (r *pgAuditRepository) List(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEntry, int, error) {
+	args := []any{f.From, f.To, f.OwnerID, f.Action, f.TargetID}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log"+auditWhere, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count audit entries: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, action, target_type, target_id, owner_id, key_id, client_ip,
+		       correlation_id, method, path, status, occurred_at
+		FROM audit_log`+auditWhere+`
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, (f.Page-1)*f.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*domain.AuditEntry
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+func scanAuditEntry(row pgx.Row) (*domain.AuditEntry, error) {
+	var e domain.AuditEntry
+	err := row.Scan(
+		&e.ID, &e.Action, &e.TargetType, &e.TargetID, &e.OwnerID, &e.KeyID, &e.ClientIP,
+		&e.CorrelationID, &e.Method, &e.Path, &e.Status, &e.OccurredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
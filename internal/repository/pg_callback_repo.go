@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+type pgCallbackRepository struct {
+	pool PgxPool
+}
+
+// NewPgCallbackRepository returns a CallbackRepository backed by PostgreSQL.
+func NewPgCallbackRepository(pool PgxPool) CallbackRepository {
+	return &pgCallbackRepository{pool: pool}
+}
+
+func (r *pgCallbackRepository) Create(ctx context.Context, cb *domain.Callback) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO callbacks
+			(id, notification_id, url, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		cb.ID, cb.NotificationID, cb.URL, cb.Payload, cb.Status, cb.Attempts,
+		cb.NextAttemptAt, cb.CreatedAt, cb.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert callback: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCallbackRepository) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*domain.Callback, error) {
+	rows, err := r.pool.Query(ctx, `
+		UPDATE callbacks SET next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM callbacks
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, notification_id, url, payload, status, attempts,
+		          next_attempt_at, last_error, created_at, updated_at`,
+		leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim due callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*domain.Callback
+	for rows.Next() {
+		cb, err := scanCallback(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan callback: %w", err)
+		}
+		result = append(result, cb)
+	}
+	return result, rows.Err()
+}
+
+func (r *pgCallbackRepository) MarkDelivered(ctx context.Context, id string, attempts int) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE callbacks SET status = 'delivered', attempts = $1, last_error = NULL
+		WHERE id = $2`, attempts, id)
+	if err != nil {
+		return fmt.Errorf("mark callback delivered: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCallbackRepository) ScheduleRetry(ctx context.Context, id string, attempts int, next time.Time, errMsg string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE callbacks SET attempts = $1, next_attempt_at = $2, last_error = $3
+		WHERE id = $4`, attempts, next, errMsg, id)
+	if err != nil {
+		return fmt.Errorf("schedule callback retry: %w", err)
+	}
+	return nil
+}
+
+func (r *pgCallbackRepository) MarkFailed(ctx context.Context, id string, attempts int, errMsg string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE callbacks SET status = 'failed', attempts = $1, last_error = $2
+		WHERE id = $3`, attempts, errMsg, id)
+	if err != nil {
+		return fmt.Errorf("mark callback failed: %w", err)
+	}
+	return nil
+}
+
+func scanCallback(row pgx.Row) (*domain.Callback, error) {
+	var cb domain.Callback
+	err := row.Scan(
+		&cb.ID, &cb.NotificationID, &cb.URL, &cb.Payload, &cb.Status, &cb.Attempts,
+		&cb.NextAttemptAt, &cb.LastError, &cb.CreatedAt, &cb.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cb, nil
+}
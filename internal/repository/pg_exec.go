@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PgxPool is the subset of *pgxpool.Pool used by the repository.
+// Accepting an interface lets tests substitute pgxmock for a live database.
+type PgxPool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// PgOptions tunes how the pgx repository executes statements.
+// Zero durations fall back to the defaults below.
+type PgOptions struct {
+	// QueryTimeout bounds every repository call so a single slow statement
+	// (e.g. lock contention on batches) cannot stall a worker indefinitely.
+	QueryTimeout time.Duration
+	// MaxRetries is the number of extra attempts made for idempotent reads and
+	// updates that fail with a transient error. Zero disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry; it grows linearly.
+	RetryBackoff time.Duration
+}
+
+const (
+	defaultQueryTimeout = 5 * time.Second
+	defaultRetryBackoff = 50 * time.Millisecond
+)
+
+func (o PgOptions) withDefaults() PgOptions {
+	if o.QueryTimeout <= 0 {
+		o.QueryTimeout = defaultQueryTimeout
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = defaultRetryBackoff
+	}
+	return o
+}
+
+// once runs fn a single time under the per-query timeout.
+// Used for statements that are not safe to repeat, such as inserts, and
+// updates conditional on the status they change: repeated after a reset that
+// lost the reply to a commit, those match nothing and report a write that
+// happened as refused.
+func (r *pgNotificationRepository) once(ctx context.Context, fn func(ctx context.Context) error) error {
+	qctx, cancel := context.WithTimeout(ctx, r.opts.QueryTimeout)
+	defer cancel()
+	return fn(qctx)
+}
+
+// retry runs an idempotent statement under the per-query timeout, repeating it
+// with a short linear backoff while it fails with a transient error.
+func (r *pgNotificationRepository) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = r.once(ctx, fn)
+		if err == nil || attempt >= r.opts.MaxRetries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt+1) * r.opts.RetryBackoff):
+		}
+	}
+}
+
+// isTransient reports whether err is worth retrying: serialization failures,
+// deadlocks, and connection errors raised before the statement reached the server
+// or by the connection being reset underneath it.
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01": // deadlock_detected
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	return pgconn.SafeToRetry(err)
+}
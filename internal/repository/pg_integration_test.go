@@ -0,0 +1,132 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/db"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// TestPgRepository_StatementCacheModes runs the repository against a real
+// database once per DB_STATEMENT_CACHE_MODE, so an argument pgx can only
+// encode with the extended protocol shows up before it reaches a deployment
+// behind PgBouncer. Run it with
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/repository/
+//
+// against a scratch database: it is migrated and rows are left behind.
+func TestPgRepository_StatementCacheModes(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Chdir("../..") // migrations/ is resolved from the working directory
+
+	ctx := context.Background()
+	if err := db.Migrate(ctx, databaseURL, 0, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{"prepare", "describe", "simple"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", databaseURL)
+			t.Setenv("DB_STATEMENT_CACHE_MODE", mode)
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pool, err := db.Connect(ctx, cfg, zaptest.NewLogger(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+			repo := repository.NewPgNotificationRepository(pool, repository.PgOptions{QueryTimeout: 5 * time.Second})
+
+			// Twice, so the second pass runs on cached statements.
+			for range 2 {
+				exerciseRepository(t, repo)
+			}
+		})
+	}
+}
+
+func exerciseRepository(t *testing.T, repo repository.NotificationRepository) {
+	t.Helper()
+	ctx := context.Background()
+	owner := uuid.New().String()
+	key := uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	sms := &domain.Notification{
+		ID: uuid.New().String(), Channel: domain.ChannelSMS, Recipient: "+905551234567",
+		Content: "hello", Priority: domain.PriorityNormal, Status: domain.StatusPending,
+		IdempotencyKey: &key, MaxRetries: 3, OwnerID: &owner, CreatedAt: now, UpdatedAt: now,
+	}
+	email := &domain.Notification{
+		ID: uuid.New().String(), Channel: domain.ChannelEmail, Recipient: "user@example.com",
+		Content: "hello", Priority: domain.PriorityNormal, Status: domain.StatusPending,
+		MaxRetries: 3, OwnerID: &owner, Email: &domain.Email{Subject: "Welcome"}, CreatedAt: now, UpdatedAt: now,
+	}
+	for _, n := range []*domain.Notification{sms, email} {
+		if err := repo.Create(ctx, n); err != nil {
+			t.Fatalf("create %s: %v", n.Channel, err)
+		}
+	}
+
+	got, err := repo.GetByID(ctx, email.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email == nil || got.Email.Subject != "Welcome" {
+		t.Fatalf("expected the email to round-trip, got %+v", got.Email)
+	}
+	if got, err := repo.GetByIdempotencyKey(ctx, key); err != nil || got.ID != sms.ID {
+		t.Fatalf("expected %s by idempotency key, got %v, %v", sms.ID, got, err)
+	}
+	if many, err := repo.GetByIDs(ctx, []string{sms.ID, email.ID}); err != nil || len(many) != 2 {
+		t.Fatalf("expected both by ID, got %d, %v", len(many), err)
+	}
+
+	if err := repo.UpdateStatus(ctx, sms.ID, domain.StatusQueued); err != nil {
+		t.Fatal(err)
+	}
+	msgID := "provider-" + sms.ID
+	if err := repo.MarkSent(ctx, sms.ID, msgID, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetByProviderMsgID(ctx, msgID); err != nil || got.Status != domain.StatusSent {
+		t.Fatalf("expected a sent notification by provider ID, got %v, %v", got, err)
+	}
+
+	list, total, err := repo.List(ctx, domain.ListFilter{OwnerID: &owner, Page: 1, Limit: 10})
+	if err != nil || total != 2 || len(list) != 2 {
+		t.Fatalf("expected the owner's 2 notifications, got %d of %d, %v", len(list), total, err)
+	}
+	if _, err := repo.FindDueScheduled(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CountByStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := &domain.Batch{ID: uuid.New().String(), OwnerID: &owner}
+	member := &domain.Notification{
+		ID: uuid.New().String(), BatchID: &batch.ID, Channel: domain.ChannelPush, Recipient: "device-token",
+		Content: "hello", Priority: domain.PriorityLow, Status: domain.StatusPending,
+		MaxRetries: 3, OwnerID: &owner, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := repo.CreateBatch(ctx, batch, []*domain.Notification{member}); err != nil {
+		t.Fatal(err)
+	}
+}
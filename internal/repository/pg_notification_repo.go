@@ -2,35 +2,46 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
 )
 
+// notificationColumns is the column list read by scanNotification, in order.
+const notificationColumns = `
+		id, batch_id, channel, recipient, content, priority, status,
+		idempotency_key, retry_count, max_retries, next_retry_at,
+		scheduled_at, sent_at, provider_msg_id, error_message,
+		template_id, owner_id, callback_url,
+		send_window_start, send_window_end, timezone, expires_at, email, resend_of,
+		correlation_id, dry_run, created_at, updated_at,
+		cancelled_reason, cancelled_at, cancelled_by, cancel_correlation_id`
+
 type pgNotificationRepository struct {
-	pool *pgxpool.Pool
+	pool PgxPool
+	opts PgOptions
 }
 
 // NewPgNotificationRepository returns a NotificationRepository backed by PostgreSQL.
-func NewPgNotificationRepository(pool *pgxpool.Pool) NotificationRepository {
-	return &pgNotificationRepository{pool: pool}
+// Every call runs under opts.QueryTimeout; idempotent reads and updates are
+// retried on transient errors (see pg_exec.go).
+func NewPgNotificationRepository(pool PgxPool, opts PgOptions) NotificationRepository {
+	return &pgNotificationRepository{pool: pool, opts: opts.withDefaults()}
 }
 
 func (r *pgNotificationRepository) Create(ctx context.Context, n *domain.Notification) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO notifications
-			(id, batch_id, channel, recipient, content, priority, status,
-			 idempotency_key, retry_count, max_retries, scheduled_at, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
-		n.ID, n.BatchID, n.Channel, n.Recipient, n.Content, n.Priority, n.Status,
-		n.IdempotencyKey, n.RetryCount, n.MaxRetries, n.ScheduledAt, n.CreatedAt, n.UpdatedAt,
-	)
+	// Not retried: a connection reset after the server committed would turn
+	// the second attempt into a spurious idempotency conflict.
+	err := r.once(ctx, func(ctx context.Context) error {
+		return insertNotification(ctx, r.pool, n)
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "idempotency_key") {
 			return domain.ErrConflict
@@ -41,33 +52,34 @@ func (r *pgNotificationRepository) Create(ctx context.Context, n *domain.Notific
 }
 
 func (r *pgNotificationRepository) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
-		FROM notifications WHERE id = $1`, id)
-
-	n, err := scanNotification(row)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, domain.ErrNotFound
-	}
-	return n, err
+	return r.getOne(ctx, `SELECT`+notificationColumns+` FROM notifications WHERE id = $1`, id)
+}
+
+func (r *pgNotificationRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.Notification, error) {
+	return r.getMany(ctx, `SELECT`+notificationColumns+` FROM notifications WHERE id = ANY($1)`, ids)
 }
 
 func (r *pgNotificationRepository) GetByIdempotencyKey(ctx context.Context, key string) (*domain.Notification, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
-		FROM notifications WHERE idempotency_key = $1`, key)
-
-	n, err := scanNotification(row)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, domain.ErrNotFound
-	}
-	return n, err
+	return r.getOne(ctx, `SELECT`+notificationColumns+` FROM notifications WHERE idempotency_key = $1`, key)
+}
+
+// GetByProviderMsgID returns the notification the provider acknowledged with
+// the given message ID. Providers occasionally reuse IDs, so the newest row wins.
+func (r *pgNotificationRepository) GetByProviderMsgID(ctx context.Context, providerMsgID string) (*domain.Notification, error) {
+	return r.getOne(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications WHERE provider_msg_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1`, providerMsgID)
+}
+
+func (r *pgNotificationRepository) FindRecentByDedupHash(ctx context.Context, hash string, since time.Time) (*domain.Notification, error) {
+	return r.getOne(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications
+		WHERE dedup_hash = $1 AND created_at >= $2 AND status NOT IN ('cancelled','draft')
+		ORDER BY created_at DESC
+		LIMIT 1`, hash, since)
 }
 
 func (r *pgNotificationRepository) List(ctx context.Context, f domain.ListFilter) ([]*domain.Notification, int, error) {
@@ -77,7 +89,10 @@ func (r *pgNotificationRepository) List(ctx context.Context, f domain.ListFilter
 	// Count total matching rows for pagination metadata.
 	var total int
 	countQuery := "SELECT COUNT(*) FROM notifications" + where
-	if err := r.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+	err := r.retry(ctx, func(ctx context.Context) error {
+		return r.pool.QueryRow(ctx, countQuery, args...).Scan(&total)
+	})
+	if err != nil {
 		return nil, 0, fmt.Errorf("count notifications: %w", err)
 	}
 
@@ -87,189 +102,777 @@ func (r *pgNotificationRepository) List(ctx context.Context, f domain.ListFilter
 	offsetPlaceholder := fmt.Sprintf("$%d", len(args))
 
 	query := fmt.Sprintf(`
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
+		SELECT%s
 		FROM notifications%s
-		ORDER BY created_at DESC
-		LIMIT %s OFFSET %s`, where, limitPlaceholder, offsetPlaceholder)
+		ORDER BY %s
+		LIMIT %s OFFSET %s`, notificationColumns, where, listOrderBy(f), limitPlaceholder, offsetPlaceholder)
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	notifications, err := r.getMany(ctx, query, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("list notifications: %w", err)
 	}
-	defer rows.Close()
-
-	var notifications []*domain.Notification
-	for rows.Next() {
-		n, err := scanNotification(rows)
-		if err != nil {
-			return nil, 0, err
-		}
-		notifications = append(notifications, n)
-	}
-	return notifications, total, rows.Err()
+	return notifications, total, nil
 }
 
 func (r *pgNotificationRepository) UpdateStatus(ctx context.Context, id string, status domain.Status) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE notifications SET status = $1 WHERE id = $2`, status, id)
-	return err
+	return r.exec(ctx, `UPDATE notifications SET status = $1 WHERE id = $2`, status, id)
 }
 
+// MarkSent, MarkFailed and ScheduleRetry each end a provider send, so they
+// record it in delivery_attempts in the same statement. They run once, as a
+// retry after a lost reply would record the attempt twice.
+
 func (r *pgNotificationRepository) MarkSent(ctx context.Context, id, providerMsgID string, sentAt time.Time) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE notifications
-		SET status = 'sent', provider_msg_id = $1, sent_at = $2, error_message = NULL
-		WHERE id = $3`, providerMsgID, sentAt, id)
-	return err
+	return r.execOnce(ctx, `
+		WITH n AS (
+			UPDATE notifications
+			SET status = 'sent', provider_msg_id = $1, sent_at = $2, error_message = NULL
+			WHERE id = $3
+			RETURNING id, retry_count
+		)
+		INSERT INTO delivery_attempts (notification_id, attempt, outcome, provider_msg_id, attempted_at)
+		SELECT id, retry_count + 1, 'sent', $1, $2 FROM n`, providerMsgID, sentAt, id)
 }
 
 func (r *pgNotificationRepository) MarkFailed(ctx context.Context, id, errMsg string) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE notifications
-		SET status = 'failed', error_message = $1, next_retry_at = NULL
-		WHERE id = $2`, errMsg, id)
-	return err
+	return r.execOnce(ctx, `
+		WITH n AS (
+			UPDATE notifications
+			SET status = 'failed', error_message = $1, next_retry_at = NULL
+			WHERE id = $2
+			RETURNING id, retry_count
+		)
+		INSERT INTO delivery_attempts (notification_id, attempt, outcome, error)
+		SELECT id, retry_count + 1, 'failed', $1 FROM n`, errMsg, id)
+}
+
+// MarkExpired never touches sent rows, nor ones already in another terminal status.
+func (r *pgNotificationRepository) MarkExpired(ctx context.Context, id string) error {
+	var tag pgconn.CommandTag
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications
+			SET status = 'expired', next_retry_at = NULL
+			WHERE id = $1 AND status NOT IN ('sent','cancelled','expired')`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("mark notification expired: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotExpirable
+	}
+	return nil
 }
 
 func (r *pgNotificationRepository) ScheduleRetry(ctx context.Context, id string, retryCount int, nextRetry time.Time, errMsg string) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE notifications
-		SET status = 'failed', retry_count = $1, next_retry_at = $2, error_message = $3
-		WHERE id = $4`, retryCount, nextRetry, errMsg, id)
-	return err
+	return r.execOnce(ctx, `
+		WITH n AS (
+			UPDATE notifications
+			SET status = 'failed', retry_count = $1, next_retry_at = $2, error_message = $3
+			WHERE id = $4
+			RETURNING id, retry_count
+		)
+		INSERT INTO delivery_attempts (notification_id, attempt, outcome, error)
+		SELECT id, retry_count, 'failed', $3 FROM n`, retryCount, nextRetry, errMsg, id)
 }
 
-func (r *pgNotificationRepository) Cancel(ctx context.Context, id string) error {
-	_, err := r.pool.Exec(ctx,
-		`UPDATE notifications SET status = 'cancelled' WHERE id = $1`, id)
-	return err
+// Update runs once: repeated after a lost reply, its own write would have
+// moved updated_at past pre and the edit would be reported as refused.
+func (r *pgNotificationRepository) Update(ctx context.Context, n *domain.Notification, pre domain.UpdatePrecondition) error {
+	err := r.once(ctx, func(ctx context.Context) error {
+		return r.pool.QueryRow(ctx, `
+			UPDATE notifications
+			SET content = $1, priority = $2, scheduled_at = $3, status = $4, dedup_hash = $5, max_retries = $6,
+			    updated_at = NOW()
+			WHERE id = $7 AND status IN ('draft','pending','scheduled','failed')
+			  AND (status = 'draft') = ($4 = 'draft')
+			  AND (status = 'failed') = ($4 = 'failed')
+			  AND ($8::timestamptz IS NULL OR updated_at = $8)
+			  AND ($9::timestamptz IS NULL OR date_trunc('second', updated_at) <= $9)
+			RETURNING updated_at`,
+			n.Content, n.Priority, n.ScheduledAt, n.Status, n.DedupHash, n.MaxRetries, n.ID,
+			pre.UpdatedAt, pre.UnmodifiedSince,
+		).Scan(&n.UpdatedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if !pre.IsZero() {
+			return domain.ErrPreconditionFailed
+		}
+		return domain.ErrNotEditable
+	}
+	if err != nil {
+		return fmt.Errorf("update notification: %w", err)
+	}
+	return nil
 }
 
-func (r *pgNotificationRepository) FindDueRetries(ctx context.Context) ([]*domain.Notification, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
-		FROM notifications
-		WHERE status = 'failed'
-		  AND retry_count < max_retries
-		  AND next_retry_at <= NOW()
-		LIMIT 500`)
+func (r *pgNotificationRepository) Submit(ctx context.Context, id string, status domain.Status) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = $1, updated_at = NOW()
+			WHERE id = $2 AND status = 'draft'`,
+			status, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("submit notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotDraft
+	}
+	return nil
+}
+
+func (r *pgNotificationRepository) UpdatePriority(ctx context.Context, id string, priority domain.Priority) error {
+	var tag pgconn.CommandTag
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET priority = $1
+			WHERE id = $2 AND status IN ('draft','pending','scheduled','queued','failed')`,
+			priority, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("update notification priority: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrPriorityLocked
+	}
+	return nil
+}
+
+func (r *pgNotificationRepository) RequeueFailed(ctx context.Context, id string, resetCount bool) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications
+			SET status = 'queued',
+			    next_retry_at = NOW(),
+			    retry_count = CASE WHEN $2 THEN 0 ELSE retry_count END
+			WHERE id = $1 AND status = 'failed' AND ($2 OR retry_count < max_retries)`,
+			id, resetCount)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("requeue failed notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotRetryable
+	}
+	return nil
+}
+
+func (r *pgNotificationRepository) Reschedule(ctx context.Context, id string, at time.Time) error {
+	var tag pgconn.CommandTag
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = 'scheduled', scheduled_at = $1
+			WHERE id = $2 AND status IN ('queued','scheduled','failed')`, at, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("reschedule notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// Cancel runs once: repeated after a lost reply to its commit, it would find
+// the row already cancelled and report the cancel as refused.
+func (r *pgNotificationRepository) Cancel(ctx context.Context, id string, c domain.Cancellation) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications
+			SET status = 'cancelled', cancelled_reason = $2, cancelled_at = $3,
+			    cancelled_by = $4, cancel_correlation_id = $5
+			WHERE id = $1 AND status IN ('draft','pending','queued','scheduled','failed')`,
+			id, c.Reason, c.At, c.By, c.CorrelationID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cancel notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotCancellable
+	}
+	return nil
+}
+
+// ClaimDueRetries moves due retries to queued in the statement that selects
+// them, skipping rows another replica has locked, so a row is handed out once
+// however the caller's later writes go. It runs once: repeating it after a
+// lost reply would claim a second page and strand the first.
+//
+// retry_count of a row awaiting a retry is the number of that retry, so the
+// last one allowed has retry_count = max_retries.
+func (r *pgNotificationRepository) ClaimDueRetries(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	var notifications []*domain.Notification
+	err := r.once(ctx, func(ctx context.Context) error {
+		rows, err := r.pool.Query(ctx, `
+			WITH due AS (
+				SELECT id AS due_id, updated_at AS failed_at
+				FROM notifications
+				WHERE status = 'failed'
+				  AND retry_count <= max_retries
+				  AND next_retry_at <= NOW()
+				  AND (expires_at IS NULL OR expires_at > NOW())
+				ORDER BY next_retry_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			), claimed AS (
+				UPDATE notifications SET status = 'queued'
+				FROM due WHERE id = due_id
+				RETURNING`+notificationColumns+`, failed_at
+			)
+			SELECT`+notificationColumns+`, failed_at
+			FROM claimed
+			ORDER BY next_retry_at`, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			// updated_at is reported as it was before the claim, when the
+			// retry was scheduled.
+			var failedAt time.Time
+			n, err := scanNotification(extraColumns{rows, []any{&failedAt}})
+			if err != nil {
+				return err
+			}
+			n.UpdatedAt = failedAt
+			notifications = append(notifications, n)
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		return nil, fmt.Errorf("find due retries: %w", err)
+		return nil, fmt.Errorf("claim due retries: %w", err)
 	}
-	defer rows.Close()
-	return scanNotifications(rows)
+	return notifications, nil
 }
 
-func (r *pgNotificationRepository) FindDueScheduled(ctx context.Context) ([]*domain.Notification, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
+func (r *pgNotificationRepository) CountByStatus(ctx context.Context) ([]domain.StatusCount, error) {
+	var counts []domain.StatusCount
+	err := r.retry(ctx, func(ctx context.Context) error {
+		rows, err := r.pool.Query(ctx, `
+			SELECT status, channel, COUNT(*)
+			FROM notifications
+			GROUP BY status, channel`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		counts = counts[:0]
+		for rows.Next() {
+			var c domain.StatusCount
+			if err := rows.Scan(&c.Status, &c.Channel, &c.Count); err != nil {
+				return err
+			}
+			counts = append(counts, c)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("count by status: %w", err)
+	}
+	return counts, nil
+}
+
+func (r *pgNotificationRepository) FindDueScheduled(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	notifications, err := r.getMany(ctx, `
+		SELECT`+notificationColumns+`
 		FROM notifications
 		WHERE status = 'scheduled'
 		  AND scheduled_at <= NOW()
-		LIMIT 500`)
+		ORDER BY scheduled_at
+		LIMIT $1`, limit)
 	if err != nil {
 		return nil, fmt.Errorf("find due scheduled: %w", err)
 	}
-	defer rows.Close()
-	return scanNotifications(rows)
+	return notifications, nil
 }
 
-func (r *pgNotificationRepository) CreateBatch(ctx context.Context, batchID string, notifications []*domain.Notification) (*domain.Batch, error) {
-	tx, err := r.pool.Begin(ctx)
+func (r *pgNotificationRepository) CountOverdueScheduled(ctx context.Context) (int, error) {
+	var count int
+	err := r.retry(ctx, func(ctx context.Context) error {
+		return r.pool.QueryRow(ctx, `
+			SELECT COUNT(*) FROM notifications
+			WHERE status = 'scheduled' AND scheduled_at <= NOW()`).Scan(&count)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("begin transaction: %w", err)
+		return 0, fmt.Errorf("count overdue scheduled: %w", err)
 	}
-	defer tx.Rollback(ctx) //nolint:errcheck
+	return count, nil
+}
 
-	batch := &domain.Batch{
-		ID:        batchID,
-		Total:     len(notifications),
-		Pending:   len(notifications),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+func (r *pgNotificationRepository) FindStalePending(ctx context.Context, before time.Time, channel *domain.Channel, limit int) ([]*domain.Notification, error) {
+	notifications, err := r.getMany(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications
+		WHERE status = 'pending'
+		  AND updated_at < $1
+		  AND ($2::text IS NULL OR channel = $2)
+		ORDER BY created_at ASC
+		LIMIT $3`, before, channel, limit)
+	if err != nil {
+		return nil, fmt.Errorf("find stale pending: %w", err)
 	}
+	return notifications, nil
+}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO batches (id, total, pending, sent, failed, cancelled, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
-		batch.ID, batch.Total, batch.Pending, 0, 0, 0, batch.CreatedAt, batch.UpdatedAt,
-	)
+func (r *pgNotificationRepository) ClaimPending(ctx context.Context, id string) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = 'queued'
+			WHERE id = $1 AND status = 'pending'`, id)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("insert batch: %w", err)
+		return fmt.Errorf("claim pending notification: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ClaimScheduled runs once: a retry after a lost reply would find the row
+// already queued, and the scheduler would skip it and strand it there.
+func (r *pgNotificationRepository) ClaimScheduled(ctx context.Context, id string) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = 'queued'
+			WHERE id = $1 AND status = 'scheduled'`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("claim scheduled notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ClaimForProcessing runs once: a retry after a lost reply would find the row
+// already processing and drop an item this worker holds the claim on.
+func (r *pgNotificationRepository) ClaimForProcessing(ctx context.Context, id string) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = 'processing'
+			WHERE id = $1 AND status = 'queued'`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("claim notification for processing: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgNotificationRepository) ReleaseToPending(ctx context.Context, id string) error {
+	var tag pgconn.CommandTag
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = 'pending'
+			WHERE id = $1 AND status IN ('queued','processing')`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("release notification to pending: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgNotificationRepository) RevertQueued(ctx context.Context, id string, status domain.Status) error {
+	var tag pgconn.CommandTag
+	err := r.once(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE notifications SET status = $1
+			WHERE id = $2 AND status = 'queued'`, status, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("revert queued notification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// deadLetterWhere selects dead letters; $1..$3 are the channel, from and to
+// filters, each ignored when NULL.
+const deadLetterWhere = `
+		WHERE status = 'failed' AND next_retry_at IS NULL
+		  AND ($1::text IS NULL OR channel = $1)
+		  AND ($2::timestamptz IS NULL OR updated_at >= $2)
+		  AND ($3::timestamptz IS NULL OR updated_at <= $3)`
+
+// deadLetterAttempts adds the attempt count and time of the last attempt.
+const deadLetterAttempts = `
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*), MAX(attempted_at)
+			FROM delivery_attempts WHERE notification_id = notifications.id
+		) a(attempt_count, last_attempt_at) ON TRUE`
+
+func (r *pgNotificationRepository) ListDeadLetters(ctx context.Context, f domain.DeadLetterFilter) ([]*domain.DeadLetter, int, error) {
+	args := []any{f.Channel, f.From, f.To}
+
+	var total int
+	err := r.retry(ctx, func(ctx context.Context) error {
+		return r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM notifications"+deadLetterWhere, args...).Scan(&total)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("count dead letters: %w", err)
+	}
+
+	var letters []*domain.DeadLetter
+	err = r.retry(ctx, func(ctx context.Context) error {
+		rows, err := r.pool.Query(ctx, `
+			SELECT`+notificationColumns+`, attempt_count, last_attempt_at
+			FROM notifications`+deadLetterAttempts+deadLetterWhere+`
+			ORDER BY updated_at DESC
+			LIMIT $4 OFFSET $5`, append(args, f.Limit, (f.Page-1)*f.Limit)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		letters = letters[:0]
+		for rows.Next() {
+			dl, err := scanDeadLetter(rows)
+			if err != nil {
+				return err
+			}
+			letters = append(letters, dl)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("list dead letters: %w", err)
+	}
+	return letters, total, nil
+}
+
+func (r *pgNotificationRepository) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	var dl *domain.DeadLetter
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		dl, err = scanDeadLetter(r.pool.QueryRow(ctx, `
+			SELECT`+notificationColumns+`, attempt_count, last_attempt_at
+			FROM notifications`+deadLetterAttempts+`
+			WHERE id = $1 AND status = 'failed' AND next_retry_at IS NULL`, id))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get dead letter: %w", err)
+	}
+
+	err = r.retry(ctx, func(ctx context.Context) error {
+		rows, err := r.pool.Query(ctx, `
+			SELECT attempt, outcome, error, provider_msg_id, attempted_at
+			FROM delivery_attempts WHERE notification_id = $1
+			ORDER BY attempted_at ASC, id ASC`, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		dl.Attempts = dl.Attempts[:0]
+		for rows.Next() {
+			var a domain.DeliveryAttempt
+			if err := rows.Scan(&a.Attempt, &a.Outcome, &a.Error, &a.ProviderMsgID, &a.AttemptedAt); err != nil {
+				return err
+			}
+			dl.Attempts = append(dl.Attempts, a)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get delivery attempts: %w", err)
+	}
+	return dl, nil
+}
+
+func (r *pgNotificationRepository) CreateBatch(ctx context.Context, batch *domain.Batch, notifications []*domain.Notification) error {
+	batch.Total = len(notifications)
+	batch.Pending = len(notifications)
+	batch.CreatedAt = time.Now().UTC()
+	batch.UpdatedAt = batch.CreatedAt
+
+	// The whole transaction shares one timeout and is not retried.
+	err := r.once(ctx, func(ctx context.Context) error {
+		tx, err := r.pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback(ctx) //nolint:errcheck
 
-	for _, n := range notifications {
 		_, err = tx.Exec(ctx, `
-			INSERT INTO notifications
-				(id, batch_id, channel, recipient, content, priority, status,
-				 idempotency_key, retry_count, max_retries, scheduled_at, created_at, updated_at)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
-			n.ID, n.BatchID, n.Channel, n.Recipient, n.Content, n.Priority, n.Status,
-			n.IdempotencyKey, n.RetryCount, n.MaxRetries, n.ScheduledAt, n.CreatedAt, n.UpdatedAt,
+			INSERT INTO batches (id, owner_id, idempotency_key, scheduled_at, total, pending, sent, failed, cancelled, expired, created_at, updated_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
+			batch.ID, batch.OwnerID, batch.IdempotencyKey, batch.ScheduledAt, batch.Total, batch.Pending, 0, 0, 0, 0, batch.CreatedAt, batch.UpdatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("insert batch notification: %w", err)
+			if strings.Contains(err.Error(), "idempotency_key") {
+				return domain.ErrConflict
+			}
+			return fmt.Errorf("insert batch: %w", err)
 		}
+
+		for _, n := range notifications {
+			if err := insertNotification(ctx, tx, n); err != nil {
+				return fmt.Errorf("insert batch notification: %w", err)
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit batch: %w", err)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *pgNotificationRepository) GetBatch(ctx context.Context, batchID string) (*domain.Batch, []*domain.Notification, error) {
+	b, err := r.getBatch(ctx, `WHERE id = $1`, batchID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("commit batch: %w", err)
+	notifications, err := r.getMany(ctx, `
+		SELECT`+notificationColumns+`
+		FROM notifications WHERE batch_id = $1 ORDER BY created_at ASC`, batchID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get batch notifications: %w", err)
 	}
+	return b, notifications, nil
+}
 
-	return batch, nil
+func (r *pgNotificationRepository) GetBatchByID(ctx context.Context, batchID string) (*domain.Batch, error) {
+	return r.getBatch(ctx, `WHERE id = $1`, batchID)
 }
 
-func (r *pgNotificationRepository) GetBatch(ctx context.Context, batchID string) (*domain.Batch, []*domain.Notification, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, total, pending, sent, failed, cancelled, created_at, updated_at
-		FROM batches WHERE id = $1`, batchID)
+func (r *pgNotificationRepository) GetBatchByIdempotencyKey(ctx context.Context, key string) (*domain.Batch, error) {
+	return r.getBatch(ctx, `WHERE idempotency_key = $1`, key)
+}
 
+// getBatch reads a single batch row matching where, translating
+// pgx.ErrNoRows to domain.ErrNotFound.
+func (r *pgNotificationRepository) getBatch(ctx context.Context, where string, args ...any) (*domain.Batch, error) {
 	var b domain.Batch
-	err := row.Scan(&b.ID, &b.Total, &b.Pending, &b.Sent, &b.Failed, &b.Cancelled, &b.CreatedAt, &b.UpdatedAt)
+	err := r.retry(ctx, func(ctx context.Context) error {
+		return r.pool.QueryRow(ctx, `
+			SELECT id, owner_id, idempotency_key, scheduled_at, total, pending, sent, failed, cancelled, expired, completed_at, created_at, updated_at
+			FROM batches `+where, args...,
+		).Scan(&b.ID, &b.OwnerID, &b.IdempotencyKey, &b.ScheduledAt, &b.Total, &b.Pending, &b.Sent, &b.Failed, &b.Cancelled, &b.Expired, &b.CompletedAt, &b.CreatedAt, &b.UpdatedAt)
+	})
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil, domain.ErrNotFound
+		return nil, domain.ErrNotFound
 	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("get batch: %w", err)
+		return nil, fmt.Errorf("get batch: %w", err)
 	}
+	return &b, nil
+}
 
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, batch_id, channel, recipient, content, priority, status,
-		       idempotency_key, retry_count, max_retries, next_retry_at,
-		       scheduled_at, sent_at, provider_msg_id, error_message,
-		       created_at, updated_at
-		FROM notifications WHERE batch_id = $1 ORDER BY created_at ASC`, batchID)
+// UpdateBatchCounts counts failed rows awaiting a retry as pending.
+//
+// The batch row is locked before counting. A statement counts with the
+// snapshot it started with, so an UPDATE that had to wait for a concurrent
+// recount would otherwise overwrite that recount's newer figures with older
+// ones. Here the counting statement starts once the lock is held and sees
+// every change committed before it.
+func (r *pgNotificationRepository) UpdateBatchCounts(ctx context.Context, batchID string) (*domain.Batch, error) {
+	var b domain.Batch
+	err := r.retry(ctx, func(ctx context.Context) error {
+		tx, err := r.pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback(ctx) //nolint:errcheck
+
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM batches WHERE id = $1 FOR UPDATE`, batchID); err != nil {
+			return err
+		}
+		err = tx.QueryRow(ctx, `
+			UPDATE batches b
+			SET pending = c.pending, sent = c.sent, failed = c.failed,
+			    cancelled = c.cancelled, expired = c.expired
+			FROM (
+				SELECT
+					COUNT(*) FILTER (WHERE status IN ('draft','pending','queued','processing','scheduled') OR (status = 'failed' AND next_retry_at IS NOT NULL)) AS pending,
+					COUNT(*) FILTER (WHERE status = 'sent') AS sent,
+					COUNT(*) FILTER (WHERE status = 'failed' AND next_retry_at IS NULL) AS failed,
+					COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled,
+					COUNT(*) FILTER (WHERE status = 'expired') AS expired
+				FROM notifications WHERE batch_id = $1
+			) c
+			WHERE b.id = $1
+			RETURNING b.id, b.owner_id, b.idempotency_key, b.scheduled_at, b.total, b.pending, b.sent, b.failed, b.cancelled, b.expired, b.completed_at, b.created_at, b.updated_at`, batchID,
+		).Scan(&b.ID, &b.OwnerID, &b.IdempotencyKey, &b.ScheduledAt, &b.Total, &b.Pending, &b.Sent, &b.Failed, &b.Cancelled, &b.Expired, &b.CompletedAt, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("get batch notifications: %w", err)
+		return nil, fmt.Errorf("update batch counts: %w", err)
 	}
-	defer rows.Close()
+	return &b, nil
+}
 
-	notifications, err := scanNotifications(rows)
-	return &b, notifications, err
+// MarkBatchCompleted only matches a batch whose stored pending count is zero,
+// so of several replicas flushing the same batch exactly one succeeds.
+func (r *pgNotificationRepository) MarkBatchCompleted(ctx context.Context, batchID string, at time.Time) error {
+	var tag pgconn.CommandTag
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		tag, err = r.pool.Exec(ctx, `
+			UPDATE batches SET completed_at = $1
+			WHERE id = $2 AND completed_at IS NULL AND pending = 0`, at, batchID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("mark batch completed: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
 }
 
-func (r *pgNotificationRepository) UpdateBatchCounts(ctx context.Context, batchID string) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE batches b
-		SET
-			pending   = (SELECT COUNT(*) FROM notifications WHERE batch_id = b.id AND status IN ('pending','queued','processing','scheduled')),
-			sent      = (SELECT COUNT(*) FROM notifications WHERE batch_id = b.id AND status = 'sent'),
-			failed    = (SELECT COUNT(*) FROM notifications WHERE batch_id = b.id AND status = 'failed'),
-			cancelled = (SELECT COUNT(*) FROM notifications WHERE batch_id = b.id AND status = 'cancelled')
-		WHERE id = $1`, batchID)
+// ---- helpers ----
+
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// insertNotification writes a new notification row; shared by Create and CreateBatch.
+func insertNotification(ctx context.Context, db execer, n *domain.Notification) error {
+	// Encoded here rather than by pgx, which under the simple protocol does
+	// not know the column is jsonb and cannot encode the struct.
+	var email json.RawMessage
+	if n.Email != nil {
+		var err error
+		if email, err = json.Marshal(n.Email); err != nil {
+			return fmt.Errorf("encode email: %w", err)
+		}
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO notifications
+			(id, batch_id, channel, recipient, content, priority, status,
+			 idempotency_key, retry_count, max_retries, scheduled_at, template_id,
+			 owner_id, callback_url, send_window_start, send_window_end, timezone,
+			 expires_at, email, resend_of, correlation_id, dry_run, dedup_hash, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25)`,
+		n.ID, n.BatchID, n.Channel, n.Recipient, n.Content, n.Priority, n.Status,
+		n.IdempotencyKey, n.RetryCount, n.MaxRetries, n.ScheduledAt, n.TemplateID,
+		n.OwnerID, n.CallbackURL, n.SendWindowStart, n.SendWindowEnd, n.Timezone,
+		n.ExpiresAt, email, n.ResendOf, n.CorrelationID, n.DryRun, n.DedupHash, n.CreatedAt, n.UpdatedAt,
+	)
 	return err
 }
 
-// ---- helpers ----
+// getOne runs a single-row notification query with retries,
+// translating pgx.ErrNoRows to domain.ErrNotFound.
+func (r *pgNotificationRepository) getOne(ctx context.Context, query string, args ...any) (*domain.Notification, error) {
+	var n *domain.Notification
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		n, err = scanNotification(r.pool.QueryRow(ctx, query, args...))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return n, err
+}
+
+// getMany runs a multi-row notification query with retries. Rows are fully
+// scanned inside the attempt so a mid-stream reset retries the whole read.
+func (r *pgNotificationRepository) getMany(ctx context.Context, query string, args ...any) ([]*domain.Notification, error) {
+	var notifications []*domain.Notification
+	err := r.retry(ctx, func(ctx context.Context) error {
+		rows, err := r.pool.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		notifications, err = scanNotifications(rows)
+		return err
+	})
+	return notifications, err
+}
+
+// exec runs an idempotent statement with retries.
+func (r *pgNotificationRepository) exec(ctx context.Context, query string, args ...any) error {
+	return r.retry(ctx, func(ctx context.Context) error {
+		_, err := r.pool.Exec(ctx, query, args...)
+		return err
+	})
+}
+
+// execOnce runs a statement that is not safe to repeat, without retries.
+func (r *pgNotificationRepository) execOnce(ctx context.Context, query string, args ...any) error {
+	return r.once(ctx, func(ctx context.Context) error {
+		_, err := r.pool.Exec(ctx, query, args...)
+		return err
+	})
+}
+
+// scanDeadLetter reads notificationColumns followed by the attempt count and
+// last attempt time.
+func scanDeadLetter(row pgx.Row) (*domain.DeadLetter, error) {
+	var dl domain.DeadLetter
+	n, err := scanNotification(extraColumns{row, []any{&dl.AttemptCount, &dl.LastAttemptAt}})
+	if err != nil {
+		return nil, err
+	}
+	dl.Notification = n
+	return &dl, nil
+}
+
+// extraColumns scans columns selected after notificationColumns into extra.
+type extraColumns struct {
+	pgx.Row
+	extra []any
+}
+
+func (r extraColumns) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
 
 // scanNotification reads a single notification row from any pgx row type.
 func scanNotification(row pgx.Row) (*domain.Notification, error) {
@@ -279,7 +882,10 @@ func scanNotification(row pgx.Row) (*domain.Notification, error) {
 		&n.Priority, &n.Status, &n.IdempotencyKey,
 		&n.RetryCount, &n.MaxRetries, &n.NextRetryAt,
 		&n.ScheduledAt, &n.SentAt, &n.ProviderMsgID, &n.ErrorMessage,
-		&n.CreatedAt, &n.UpdatedAt,
+		&n.TemplateID, &n.OwnerID, &n.CallbackURL,
+		&n.SendWindowStart, &n.SendWindowEnd, &n.Timezone, &n.ExpiresAt, &n.Email, &n.ResendOf,
+		&n.CorrelationID, &n.DryRun, &n.CreatedAt, &n.UpdatedAt,
+		&n.CancelledReason, &n.CancelledAt, &n.CancelledBy, &n.CancelCorrelationID,
 	)
 	if err != nil {
 		return nil, err
@@ -299,6 +905,21 @@ func scanNotifications(rows pgx.Rows) ([]*domain.Notification, error) {
 	return result, rows.Err()
 }
 
+// listOrderBy renders the ORDER BY clause for f. Only whitelisted columns
+// reach the SQL; id breaks ties so pages are stable, and rows without a
+// value for a nullable column always come last.
+func listOrderBy(f domain.ListFilter) string {
+	col := "created_at"
+	if f.Sort.IsValid() {
+		col = string(f.Sort)
+	}
+	dir := "DESC"
+	if f.Order == domain.OrderAsc {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, id %s", col, dir, dir)
+}
+
 // buildListWhere builds a parameterised WHERE clause from a ListFilter.
 func buildListWhere(f domain.ListFilter) (string, []any) {
 	var conditions []string
@@ -309,12 +930,18 @@ func buildListWhere(f domain.ListFilter) (string, []any) {
 		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
 	}
 
+	if f.OwnerID != nil {
+		add("owner_id = $%d", *f.OwnerID)
+	}
 	if f.Status != nil {
 		add("status = $%d", *f.Status)
 	}
 	if f.Channel != nil {
 		add("channel = $%d", *f.Channel)
 	}
+	if f.DryRun != nil {
+		add("dry_run = $%d", *f.DryRun)
+	}
 	if f.From != nil {
 		add("created_at >= $%d", *f.From)
 	}
@@ -0,0 +1,666 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+var (
+	errSerialization = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	errDeadlock      = &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+	// errConnReset may arrive after the server committed the statement.
+	errConnReset = fmt.Errorf("read: %w", syscall.ECONNRESET)
+)
+
+func newMockRepo(t *testing.T, retries int) (repository.NotificationRepository, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	repo := repository.NewPgNotificationRepository(mock, repository.PgOptions{
+		QueryTimeout: time.Second,
+		MaxRetries:   retries,
+		RetryBackoff: time.Millisecond,
+	})
+	return repo, mock
+}
+
+func TestPgRepository_UpdateStatus_RetriesTransientErrors(t *testing.T) {
+	repo, mock := newMockRepo(t, 2)
+
+	mock.ExpectExec("UPDATE notifications SET status").
+		WithArgs(domain.StatusQueued, "n-1").
+		WillReturnError(errSerialization)
+	mock.ExpectExec("UPDATE notifications SET status").
+		WithArgs(domain.StatusQueued, "n-1").
+		WillReturnError(errDeadlock)
+	mock.ExpectExec("UPDATE notifications SET status").
+		WithArgs(domain.StatusQueued, "n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := repo.UpdateStatus(context.Background(), "n-1", domain.StatusQueued); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_UpdateStatus_GivesUpAfterMaxRetries(t *testing.T) {
+	repo, mock := newMockRepo(t, 1)
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec("UPDATE notifications SET status").
+			WithArgs(domain.StatusQueued, "n-1").
+			WillReturnError(errSerialization)
+	}
+
+	err := repo.UpdateStatus(context.Background(), "n-1", domain.StatusQueued)
+	if !errors.Is(err, errSerialization) {
+		t.Fatalf("expected serialization error after exhausting retries, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// columns are the names notificationColumns selects, in order.
+var columns = []string{
+	"id", "batch_id", "channel", "recipient", "content", "priority", "status",
+	"idempotency_key", "retry_count", "max_retries", "next_retry_at",
+	"scheduled_at", "sent_at", "provider_msg_id", "error_message",
+	"template_id", "owner_id", "callback_url",
+	"send_window_start", "send_window_end", "timezone", "expires_at", "email", "resend_of",
+	"correlation_id", "dry_run", "created_at", "updated_at",
+	"cancelled_reason", "cancelled_at", "cancelled_by", "cancel_correlation_id",
+}
+
+func TestPgRepository_GetByID_RetriesThenScans(t *testing.T) {
+	repo, mock := newMockRepo(t, 2)
+	now := time.Now().UTC()
+	correlationID := "corr-1"
+
+	mock.ExpectQuery("FROM notifications WHERE id").
+		WithArgs("n-1").
+		WillReturnError(errSerialization)
+	mock.ExpectQuery("FROM notifications WHERE id").
+		WithArgs("n-1").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(
+			"n-1", nil, domain.ChannelSMS, "+905551234567", "hi", domain.PriorityHigh, domain.StatusQueued,
+			nil, 0, 3, nil,
+			nil, nil, nil, nil,
+			nil, nil, nil,
+			nil, nil, nil, nil, nil, nil,
+			&correlationID, false, now, now,
+			nil, nil, nil, nil,
+		))
+
+	n, err := repo.GetByID(context.Background(), "n-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.ID != "n-1" || n.Status != domain.StatusQueued {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+	if n.CorrelationID == nil || *n.CorrelationID != "corr-1" {
+		t.Fatalf("expected correlation ID corr-1, got %v", n.CorrelationID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_GetByIDs_OneQuery(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+	now := time.Now().UTC()
+
+	mock.ExpectQuery("FROM notifications WHERE id = ANY\\(\\$1\\)").
+		WithArgs([]string{"n-1", "n-2"}).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(
+			"n-2", nil, domain.ChannelSMS, "+905551234567", "hi", domain.PriorityHigh, domain.StatusSent,
+			nil, 0, 3, nil,
+			nil, nil, nil, nil,
+			nil, nil, nil,
+			nil, nil, nil, nil, nil, nil,
+			nil, true, now, now,
+			nil, nil, nil, nil,
+		))
+
+	found, err := repo.GetByIDs(context.Background(), []string{"n-1", "n-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "n-2" || !found[0].DryRun {
+		t.Fatalf("expected only n-2, a dry run, got %+v", found)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_NonTransientErrorIsNotRetried(t *testing.T) {
+	repo, mock := newMockRepo(t, 3)
+
+	mock.ExpectExec("UPDATE notifications SET status").
+		WithArgs(domain.StatusQueued, "n-1").
+		WillReturnError(&pgconn.PgError{Code: "23505", Message: "unique violation"})
+
+	if err := repo.UpdateStatus(context.Background(), "n-1", domain.StatusQueued); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_CreateIsNotRetried(t *testing.T) {
+	repo, mock := newMockRepo(t, 3)
+	now := time.Now().UTC()
+
+	args := make([]any, 25)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	mock.ExpectExec("INSERT INTO notifications").WithArgs(args...).WillReturnError(errSerialization)
+
+	err := repo.Create(context.Background(), &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "hi",
+		Priority: domain.PriorityNormal, Status: domain.StatusPending, MaxRetries: 3,
+		CreatedAt: now, UpdatedAt: now,
+	})
+	if !errors.Is(err, errSerialization) {
+		t.Fatalf("expected the first error to be returned without retrying, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_AttemptWritesAreNotRetried(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name string
+		args []any
+		call func(repository.NotificationRepository) error
+	}{
+		{"sent", []any{"msg-1", now, "n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.MarkSent(context.Background(), "n-1", "msg-1", now)
+			}},
+		{"failed", []any{"timeout", "n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.MarkFailed(context.Background(), "n-1", "timeout")
+			}},
+		{"retry scheduled", []any{1, now, "timeout", "n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.ScheduleRetry(context.Background(), "n-1", 1, now, "timeout")
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newMockRepo(t, 3)
+			mock.ExpectExec("INSERT INTO delivery_attempts").WithArgs(tt.args...).WillReturnError(errConnReset)
+
+			// The reset may have come after the commit; repeating the
+			// statement would record the attempt a second time.
+			if err := tt.call(repo); !errors.Is(err, syscall.ECONNRESET) {
+				t.Fatalf("expected the reset returned without retrying, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestPgRepository_QueryTimeoutApplied(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+	repo := repository.NewPgNotificationRepository(mock, repository.PgOptions{QueryTimeout: 20 * time.Millisecond})
+
+	mock.ExpectExec("UPDATE notifications SET status").
+		WithArgs(domain.StatusQueued, "n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1)).
+		WillDelayFor(time.Second)
+
+	start := time.Now()
+	if err := repo.UpdateStatus(context.Background(), "n-1", domain.StatusQueued); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the query timeout to cut the call short, took %s", elapsed)
+	}
+}
+
+func TestPgRepository_Update_NotEditable(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery("UPDATE notifications").
+		WithArgs("hi", domain.PriorityHigh, pgxmock.AnyArg(), domain.StatusPending, "", 3, "n-1", pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"updated_at"}))
+
+	err := repo.Update(context.Background(), &domain.Notification{
+		ID: "n-1", Content: "hi", Priority: domain.PriorityHigh, Status: domain.StatusPending, MaxRetries: 3,
+	}, domain.UpdatePrecondition{})
+	if !errors.Is(err, domain.ErrNotEditable) {
+		t.Fatalf("expected ErrNotEditable when no row matched, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_Update_StalePrecondition(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+	version := time.Now().UTC()
+
+	mock.ExpectQuery("updated_at = \\$8").
+		WithArgs("hi", domain.PriorityHigh, pgxmock.AnyArg(), domain.StatusPending, "", 3, "n-1", &version, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"updated_at"}))
+
+	err := repo.Update(context.Background(), &domain.Notification{
+		ID: "n-1", Content: "hi", Priority: domain.PriorityHigh, Status: domain.StatusPending, MaxRetries: 3,
+	}, domain.UpdatePrecondition{UpdatedAt: &version})
+	if !errors.Is(err, domain.ErrPreconditionFailed) {
+		t.Fatalf("expected ErrPreconditionFailed when the row moved on, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_Update_IsNotRetried(t *testing.T) {
+	repo, mock := newMockRepo(t, 3)
+	version := time.Now().UTC()
+
+	mock.ExpectQuery("updated_at = \\$8").
+		WithArgs("hi", domain.PriorityHigh, pgxmock.AnyArg(), domain.StatusPending, "", 3, "n-1", &version, pgxmock.AnyArg()).
+		WillReturnError(errConnReset)
+
+	err := repo.Update(context.Background(), &domain.Notification{
+		ID: "n-1", Content: "hi", Priority: domain.PriorityHigh, Status: domain.StatusPending, MaxRetries: 3,
+	}, domain.UpdatePrecondition{UpdatedAt: &version})
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected the reset returned without retrying, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_RequeueFailed_NoMatchingRow(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("UPDATE notifications").
+		WithArgs("n-1", false).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.RequeueFailed(context.Background(), "n-1", false); !errors.Is(err, domain.ErrNotRetryable) {
+		t.Fatalf("expected ErrNotRetryable, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_ClaimPending_NoLongerPending(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("UPDATE notifications SET status = 'queued'").
+		WithArgs("n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.ClaimPending(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_RevertQueued_NoLongerQueued(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("SET status = \\$1\\s+WHERE id = \\$2 AND status = 'queued'").
+		WithArgs(domain.StatusPending, "n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.RevertQueued(context.Background(), "n-1", domain.StatusPending); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A write conditional on the status it changes would match nothing when
+// repeated after a reset that lost its committed reply, and report the write
+// as refused, so it is never retried.
+func TestPgRepository_ConditionalWritesAreNotRetried(t *testing.T) {
+	cancelledAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		call  func(repository.NotificationRepository) error
+	}{
+		{"claim pending", "SET status = 'queued'", []any{"n-1"},
+			func(r repository.NotificationRepository) error { return r.ClaimPending(context.Background(), "n-1") }},
+		{"revert queued", "SET status = \\$1", []any{domain.StatusPending, "n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.RevertQueued(context.Background(), "n-1", domain.StatusPending)
+			}},
+		{"submit", "WHERE id = \\$2 AND status = 'draft'", []any{domain.StatusPending, "n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.Submit(context.Background(), "n-1", domain.StatusPending)
+			}},
+		{"requeue failed", "SET status = 'queued'", []any{"n-1", false},
+			func(r repository.NotificationRepository) error {
+				return r.RequeueFailed(context.Background(), "n-1", false)
+			}},
+		{"cancel", "SET status = 'cancelled'", []any{"n-1", (*string)(nil), cancelledAt, (*string)(nil), (*string)(nil)},
+			func(r repository.NotificationRepository) error {
+				return r.Cancel(context.Background(), "n-1", domain.Cancellation{At: cancelledAt})
+			}},
+		{"claim for processing", "SET status = 'processing'", []any{"n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.ClaimForProcessing(context.Background(), "n-1")
+			}},
+		{"claim scheduled", "WHERE id = \\$1 AND status = 'scheduled'", []any{"n-1"},
+			func(r repository.NotificationRepository) error {
+				return r.ClaimScheduled(context.Background(), "n-1")
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newMockRepo(t, 3)
+			mock.ExpectExec(tt.query).WithArgs(tt.args...).WillReturnError(errConnReset)
+
+			if err := tt.call(repo); !errors.Is(err, syscall.ECONNRESET) {
+				t.Fatalf("expected the reset returned without retrying, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestPgRepository_ClaimForProcessing_CancelledRowIsLeftAlone(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("SET status = 'processing'\\s+WHERE id = \\$1 AND status = 'queued'").
+		WithArgs("n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.ClaimForProcessing(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_ClaimScheduled_NoLongerScheduled(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("SET status = 'queued'\\s+WHERE id = \\$1 AND status = 'scheduled'").
+		WithArgs("n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.ClaimScheduled(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_Reschedule_CancelledRowIsLeftAlone(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+	at := time.Now().Add(time.Hour)
+
+	mock.ExpectExec("SET status = 'scheduled', scheduled_at = \\$1\\s+WHERE id = \\$2 AND status IN \\('queued','scheduled','failed'\\)").
+		WithArgs(at, "n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.Reschedule(context.Background(), "n-1", at); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_ReleaseToPending_OnlyUnsentRows(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("SET status = 'pending'\\s+WHERE id = \\$1 AND status IN \\('queued','processing'\\)").
+		WithArgs("n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.ReleaseToPending(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_MarkBatchCompleted_AlreadyCompleted(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+	at := time.Now().UTC()
+
+	mock.ExpectExec("completed_at IS NULL AND pending = 0").
+		WithArgs(at, "b-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.MarkBatchCompleted(context.Background(), "b-1", at); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_GetDeadLetter_NotDeadLettered(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery("status = 'failed' AND next_retry_at IS NULL").
+		WithArgs("n-1").
+		WillReturnError(pgx.ErrNoRows)
+
+	if _, err := repo.GetDeadLetter(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_MarkExpired_SentRowIsLeftAlone(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("status NOT IN \\('sent','cancelled','expired'\\)").
+		WithArgs("n-1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := repo.MarkExpired(context.Background(), "n-1"); !errors.Is(err, domain.ErrNotExpirable) {
+		t.Fatalf("expected ErrNotExpirable, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_Cancel_DeliveredRowIsLeftAlone(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectExec("status IN \\('draft','pending','queued','scheduled','failed'\\)").
+		WithArgs("n-1", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err := repo.Cancel(context.Background(), "n-1", domain.Cancellation{At: time.Now()})
+	if !errors.Is(err, domain.ErrNotCancellable) {
+		t.Fatalf("expected ErrNotCancellable, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_UpdateBatchCounts_LocksBeforeCounting(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM batches WHERE id = \\$1 FOR UPDATE").
+		WithArgs("b-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("UPDATE batches b\\s+SET pending = c.pending").
+		WithArgs("b-1").
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "owner_id", "idempotency_key", "scheduled_at", "total", "pending", "sent", "failed",
+			"cancelled", "expired", "completed_at", "created_at", "updated_at",
+		}).AddRow("b-1", nil, nil, nil, 3, 0, 2, 1, 0, 0, nil, now, now))
+	mock.ExpectCommit()
+
+	b, err := repo.UpdateBatchCounts(context.Background(), "b-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Pending+b.Sent+b.Failed+b.Cancelled+b.Expired != b.Total {
+		t.Fatalf("expected counters adding up to the total, got %+v", b)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_List_OrdersByRequestedSort(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`ORDER BY scheduled_at ASC NULLS LAST, id ASC`).
+		WithArgs(10, 10).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+
+	_, _, err := repo.List(context.Background(), domain.ListFilter{
+		Page: 2, Limit: 10, Sort: domain.SortScheduledAt, Order: domain.OrderAsc,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_FindDue_OldestFirstWithLimit(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery(`ORDER BY next_retry_at\s+LIMIT \$1`).WithArgs(200).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`ORDER BY scheduled_at\s+LIMIT \$1`).WithArgs(200).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+
+	if _, err := repo.ClaimDueRetries(context.Background(), 200); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.FindDueScheduled(context.Background(), 200); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_ClaimDueRetries_FlipsStatusInOneStatementOnce(t *testing.T) {
+	repo, mock := newMockRepo(t, 3)
+	now := time.Now().UTC()
+	failedAt := now.Add(-time.Hour)
+
+	mock.ExpectQuery(`retry_count <= max_retries(.|\n)*FOR UPDATE SKIP LOCKED(.|\n)*SET status = 'queued'`).WithArgs(10).
+		WillReturnRows(pgxmock.NewRows(append(columns, "failed_at")).AddRow(
+			"n-1", nil, domain.ChannelSMS, "+905551234567", "hi", domain.PriorityHigh, domain.StatusQueued,
+			nil, 1, 3, &failedAt,
+			nil, nil, nil, nil,
+			nil, nil, nil,
+			nil, nil, nil, nil, nil, nil,
+			nil, false, failedAt, now,
+			nil, nil, nil, nil,
+			failedAt,
+		))
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs(10).WillReturnError(errSerialization)
+
+	claimed, err := repo.ClaimDueRetries(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].Status != domain.StatusQueued || !claimed[0].UpdatedAt.Equal(failedAt) {
+		t.Fatalf("expected n-1 claimed with its pre-claim updated_at, got %+v", claimed)
+	}
+	// A claim is not safe to repeat, so even a transient error is returned.
+	if _, err := repo.ClaimDueRetries(context.Background(), 10); !errors.Is(err, errSerialization) {
+		t.Fatalf("expected the serialization error unretried, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_CountOverdueScheduled(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery(`status = 'scheduled' AND scheduled_at <= NOW\(\)`).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(7))
+
+	count, err := repo.CountOverdueScheduled(context.Background())
+	if err != nil || count != 7 {
+		t.Fatalf("expected 7, got %d, %v", count, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPgRepository_CountByStatus(t *testing.T) {
+	repo, mock := newMockRepo(t, 0)
+
+	mock.ExpectQuery("GROUP BY status, channel").
+		WillReturnRows(pgxmock.NewRows([]string{"status", "channel", "count"}).
+			AddRow(domain.StatusPending, domain.ChannelSMS, 12).
+			AddRow(domain.StatusFailed, domain.ChannelEmail, 3))
+
+	counts, err := repo.CountByStatus(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []domain.StatusCount{
+		{Status: domain.StatusPending, Channel: domain.ChannelSMS, Count: 12},
+		{Status: domain.StatusFailed, Channel: domain.ChannelEmail, Count: 3},
+	}
+	if !slices.Equal(counts, want) {
+		t.Fatalf("expected %+v, got %+v", want, counts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
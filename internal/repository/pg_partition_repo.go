@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// partitionPrefix + YYYYMM names each monthly partition, matching the
+// naming used by migrations/partitioning.
+const partitionPrefix = "notifications_p"
+
+type pgPartitionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPgPartitionRepository returns a PartitionRepository backed by PostgreSQL.
+func NewPgPartitionRepository(pool *pgxpool.Pool) PartitionRepository {
+	return &pgPartitionRepository{pool: pool}
+}
+
+func (r *pgPartitionRepository) EnsurePartition(ctx context.Context, month time.Time) error {
+	from := monthStart(month)
+	to := from.AddDate(0, 1, 0)
+	// DDL cannot take bind parameters; the identifier is quoted by pgx and the
+	// bounds are formatted from time values, never from user input.
+	_, err := r.pool.Exec(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s PARTITION OF notifications FOR VALUES FROM ('%s') TO ('%s')`,
+		pgx.Identifier{partitionName(from)}.Sanitize(),
+		from.Format(time.RFC3339), to.Format(time.RFC3339),
+	))
+	if err != nil {
+		return fmt.Errorf("create partition %s: %w", partitionName(from), err)
+	}
+	return nil
+}
+
+func (r *pgPartitionRepository) ListPartitions(ctx context.Context) ([]time.Time, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'notifications'::regclass
+		ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var months []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		// The DEFAULT partition and anything created by hand are left alone.
+		if month, ok := parsePartitionName(name); ok {
+			months = append(months, month)
+		}
+	}
+	return months, rows.Err()
+}
+
+// DropPartition drops a month's partition together with the idempotency keys
+// claimed by its rows, so the side table does not outgrow the retention window.
+func (r *pgPartitionRepository) DropPartition(ctx context.Context, month time.Time) error {
+	from := monthStart(month)
+	name := partitionName(from)
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM notification_idempotency_keys
+		WHERE created_at >= $1 AND created_at < $2`, from, from.AddDate(0, 1, 0)); err != nil {
+		return fmt.Errorf("delete idempotency keys for %s: %w", name, err)
+	}
+	if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return fmt.Errorf("drop partition %s: %w", name, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit drop partition %s: %w", name, err)
+	}
+	return nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.Format("200601")
+}
+
+func parsePartitionName(name string) (time.Time, bool) {
+	if len(name) != len(partitionPrefix)+6 || name[:len(partitionPrefix)] != partitionPrefix {
+		return time.Time{}, false
+	}
+	month, err := time.Parse("200601", name[len(partitionPrefix):])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return month, true
+}
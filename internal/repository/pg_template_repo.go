@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+type pgTemplateRepository struct {
+	pool PgxPool
+}
+
+// NewPgTemplateRepository returns a TemplateRepository backed by PostgreSQL.
+func NewPgTemplateRepository(pool PgxPool) TemplateRepository {
+	return &pgTemplateRepository{pool: pool}
+}
+
+func (r *pgTemplateRepository) Create(ctx context.Context, t *domain.Template) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO templates (id, name, body, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5)`,
+		t.ID, t.Name, t.Body, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "templates_name_key") {
+			return domain.ErrTemplateNameTaken
+		}
+		return fmt.Errorf("insert template: %w", err)
+	}
+	return nil
+}
+
+func (r *pgTemplateRepository) GetByID(ctx context.Context, id string) (*domain.Template, error) {
+	var t domain.Template
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, name, body, created_at, updated_at
+		FROM templates WHERE id = $1`, id,
+	).Scan(&t.ID, &t.Name, &t.Body, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get template: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *pgTemplateRepository) List(ctx context.Context) ([]*domain.Template, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, name, body, created_at, updated_at
+		FROM templates ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []*domain.Template{}
+	for rows.Next() {
+		var t domain.Template
+		if err := rows.Scan(&t.ID, &t.Name, &t.Body, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, &t)
+	}
+	return templates, rows.Err()
+}
+
+func (r *pgTemplateRepository) Update(ctx context.Context, t *domain.Template) error {
+	err := r.pool.QueryRow(ctx, `
+		UPDATE templates SET name = $1, body = $2
+		WHERE id = $3
+		RETURNING updated_at`, t.Name, t.Body, t.ID,
+	).Scan(&t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "templates_name_key") {
+			return domain.ErrTemplateNameTaken
+		}
+		return fmt.Errorf("update template: %w", err)
+	}
+	return nil
+}
+
+func (r *pgTemplateRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete template: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+)
+
+// TemplateRepository defines persistence operations for content templates.
+type TemplateRepository interface {
+	Create(ctx context.Context, t *domain.Template) error
+	GetByID(ctx context.Context, id string) (*domain.Template, error)
+	List(ctx context.Context) ([]*domain.Template, error)
+	Update(ctx context.Context, t *domain.Template) error
+	Delete(ctx context.Context, id string) error
+}
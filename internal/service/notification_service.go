@@ -4,14 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
 // NotificationService coordinates the repository and queue.
@@ -19,16 +23,143 @@ import (
 // HTTP handlers and workers depend on this service, not on each other.
 type NotificationService struct {
 	repo   repository.NotificationRepository
-	q      *queue.PriorityQueue
+	q      Queue
 	logger *zap.Logger
+	opts   Options
+}
+
+// Queue is the part of *queue.PriorityQueue the service relies on; tests
+// substitute fakes to exercise back-pressure paths.
+type Queue interface {
+	Enqueue(item queue.Item) error
+	Reprioritize(item queue.Item) (bool, error)
+	Depths() (high, normal, low int)
+}
+
+// CreateResult describes what Create did besides returning the notification.
+type CreateResult struct {
+	// Duplicate is set when an existing notification was returned instead of
+	// a new one (idempotency key or dedup window).
+	Duplicate bool
+	// Replayed narrows Duplicate to a match on the idempotency key.
+	Replayed bool
+	// Deferred is set when the notification is persisted but could not be
+	// queued because the queue was full; it stays pending. RetryAfter
+	// estimates when the queue will have room again.
+	Deferred   bool
+	RetryAfter time.Duration
+}
+
+const (
+	// defaultDrainRate is the assumed queue throughput, in items per second,
+	// when Options.DrainRate is unset.
+	defaultDrainRate = 100
+	// defaultMaxLookupIDs caps Lookup when Options.MaxLookupIDs is unset.
+	defaultMaxLookupIDs = 100
+	// minRetryAfter and maxRetryAfter bound the Retry-After estimate.
+	minRetryAfter = time.Second
+	maxRetryAfter = time.Minute
+)
+
+// Options carries the configurable business rules and optional collaborators
+// injected by main. Zero values fall back to the domain defaults.
+type Options struct {
+	Validation domain.ValidationRules
+
+	// Templates resolves template_id on create requests. When nil, any
+	// request referencing a template fails with ErrUnknownTemplate.
+	Templates repository.TemplateRepository
+
+	// OnTerminal, when set, is told about notifications the service moves to
+	// a terminal status (cancellation); main wires it to the callback dispatcher.
+	OnTerminal func(n *domain.Notification)
+
+	// DedupWindow, when positive, makes Create return the earlier notification
+	// instead of creating a new one if the same channel, recipient and content
+	// was sent within the window. Zero disables the check.
+	DedupWindow time.Duration
+
+	// StrictEnqueue makes Create fail with ErrQueueFull when the queue is
+	// full instead of keeping the notification pending and reporting it as
+	// deferred. The row is still persisted, so a retry with the same
+	// idempotency key picks it up rather than creating another one.
+	StrictEnqueue bool
+
+	// DrainRate is how many queued items per second the workers are expected
+	// to deliver; it turns queue depth into a Retry-After estimate.
+	DrainRate int
+
+	// Events receives created, queued and cancelled lifecycle events. It must
+	// not block; nil discards them.
+	Events events.Publisher
+
+	// CreationLimiter, when set, throttles creates per owner; calls over the
+	// limit fail with a *domain.RateLimitError.
+	CreationLimiter CreationLimiter
+
+	// MaxLookupIDs is the most IDs one Lookup call accepts.
+	MaxLookupIDs int
+
+	// DefaultMaxRetries applies to create requests that do not set
+	// max_retries. Nil means domain.DefaultMaxRetries; 0 is a valid choice.
+	DefaultMaxRetries *int
+
+	// OnQueueFull, when set, is told about every enqueue the queue rejected
+	// as full; main wires it to the enqueue failure counter.
+	OnQueueFull queue.FullHook
+
+	// Sandbox makes every notification created a dry run, whatever the
+	// request says.
+	Sandbox bool
+
+	// OnBatchChanged, when set, receives the batch ID of every batch member
+	// the service cancels; main wires it to the debounced batch counter.
+	// Without it the batch's counters are recounted directly.
+	OnBatchChanged func(batchID string)
+}
+
+// CreationLimiter meters how fast each owner may create notifications. Allow
+// charges one request carrying n notifications to owner, or reports how long
+// to wait before the request would be accepted.
+type CreationLimiter interface {
+	Allow(owner string, n int) (retryAfter time.Duration, ok bool)
 }
 
 func NewNotificationService(
 	repo repository.NotificationRepository,
-	q *queue.PriorityQueue,
+	q Queue,
 	logger *zap.Logger,
+	opts Options,
 ) *NotificationService {
-	return &NotificationService{repo: repo, q: q, logger: logger}
+	if opts.Validation.MaxScheduleHorizon <= 0 {
+		opts.Validation.MaxScheduleHorizon = domain.DefaultValidationRules.MaxScheduleHorizon
+	}
+	if opts.Validation.ScheduleSkew <= 0 {
+		opts.Validation.ScheduleSkew = domain.DefaultValidationRules.ScheduleSkew
+	}
+	if opts.Validation.BlockedCallbackHosts == nil {
+		opts.Validation.BlockedCallbackHosts = domain.DefaultValidationRules.BlockedCallbackHosts
+	}
+	if opts.DrainRate <= 0 {
+		opts.DrainRate = defaultDrainRate
+	}
+	if opts.Events == nil {
+		opts.Events = events.Nop{}
+	}
+	if opts.MaxLookupIDs <= 0 {
+		opts.MaxLookupIDs = defaultMaxLookupIDs
+	}
+	if opts.DefaultMaxRetries == nil {
+		d := domain.DefaultMaxRetries
+		opts.DefaultMaxRetries = &d
+	}
+	return &NotificationService{repo: repo, q: q, logger: logger, opts: opts}
+}
+
+// DefaultMaxRetries is the max_retries given to notifications created
+// without one.
+func (s *NotificationService) DefaultMaxRetries() int {
+	return *s.opts.DefaultMaxRetries
 }
 
 // Create validates, persists, and enqueues a single notification.
@@ -36,131 +167,974 @@ func NewNotificationService(
 // Idempotency: if an X-Idempotency-Key header was supplied and a notification
 // with that key already exists, the existing record is returned as-is.
 // The caller can distinguish a repeat response by the HTTP status code
-// (200 for existing, 201 for newly created).
+// (200 for existing, 201 for newly created). Two requests racing with the same
+// key get the same record too: the one whose insert loses to the unique key
+// returns the winner's notification, which the winner dispatches.
+//
+// Duplicate suppression: with a dedup window configured, a request matching a
+// recent notification's channel, recipient and content also returns the earlier
+// record as a duplicate unless req.AllowDuplicate is set. The check is
+// best-effort; two identical requests racing each other may both be created.
+//
+// The returned notification always carries its authoritative status: queued
+// when it was placed on the queue, scheduled for future sends, or pending when
+// the queue was full. The last case is reported as CreateResult.Deferred, or
+// as ErrQueueFull (with RetryAfter still set) under Options.StrictEnqueue.
+// Replaying the idempotency key of a deferred notification tries the queue
+// again, unless it was cancelled or dispatched since it was looked up.
+//
+// A draft request (req.Draft) is stored with status draft and skips both the
+// dedup window and the queue until Submit is called.
 func (s *NotificationService) Create(
 	ctx context.Context,
 	req domain.CreateNotificationRequest,
 	idempotencyKey string,
-) (*domain.Notification, bool, error) {
-	if err := req.Validate(); err != nil {
-		return nil, false, err
+) (*domain.Notification, CreateResult, error) {
+	if err := s.renderTemplate(ctx, &req, nil); err != nil {
+		return nil, CreateResult{}, err
+	}
+	if err := req.ValidateWith(s.opts.Validation); err != nil {
+		return nil, CreateResult{}, err
 	}
-
 	// --- idempotency check ---
+	// Before the throttle: a replay is not a new notification, and is owed
+	// the existing one even while the tenant is throttled.
 	if idempotencyKey != "" {
-		existing, err := s.repo.GetByIdempotencyKey(ctx, idempotencyKey)
-		if err != nil && !errors.Is(err, domain.ErrNotFound) {
-			return nil, false, fmt.Errorf("idempotency lookup: %w", err)
+		existing, err := s.byIdempotencyKey(ctx, idempotencyKey)
+		if err != nil {
+			return nil, CreateResult{}, err
 		}
 		if existing != nil {
-			return existing, true, nil // true = was a duplicate
+			if existing.Status == domain.StatusPending && existing.ScheduledAt == nil {
+				return s.dispatch(ctx, existing, CreateResult{Duplicate: true, Replayed: true})
+			}
+			return existing, CreateResult{Duplicate: true, Replayed: true}, nil
 		}
 	}
+	if err := s.throttle(ctx, 1); err != nil {
+		return nil, CreateResult{}, err
+	}
 
-	n := s.buildNotification(req, idempotencyKey, nil)
+	n := s.buildNotification(ctx, req, idempotencyKey, nil, ownerOf(ctx))
+
+	// --- dedup window ---
+	if s.opts.DedupWindow > 0 && !req.AllowDuplicate && !req.Draft {
+		recent, err := s.repo.FindRecentByDedupHash(ctx, n.DedupHash, n.CreatedAt.Add(-s.opts.DedupWindow))
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, CreateResult{}, fmt.Errorf("dedup lookup: %w", err)
+		}
+		if recent != nil {
+			s.logger.Info("suppressed duplicate send",
+				zap.String("id", recent.ID), zap.String("channel", string(n.Channel)))
+			return recent, CreateResult{Duplicate: true}, nil
+		}
+	}
 
 	if err := s.repo.Create(ctx, n); err != nil {
-		return nil, false, fmt.Errorf("persist notification: %w", err)
+		if errors.Is(err, domain.ErrConflict) && idempotencyKey != "" {
+			// A concurrent request with the same key inserted first. Its
+			// record is the answer; that request dispatches it, so this one
+			// must not enqueue it a second time.
+			existing, lookupErr := s.byIdempotencyKey(ctx, idempotencyKey)
+			if lookupErr != nil {
+				return nil, CreateResult{}, lookupErr
+			}
+			if existing != nil {
+				return existing, CreateResult{Duplicate: true, Replayed: true}, nil
+			}
+		}
+		return nil, CreateResult{}, fmt.Errorf("persist notification: %w", err)
+	}
+	s.publish(ctx, events.TypeCreated, n)
+	if n.Status == domain.StatusDraft {
+		return n, CreateResult{}, nil
 	}
 
-	s.enqueue(ctx, n)
-	return n, false, nil
+	return s.dispatch(ctx, n, CreateResult{})
+}
+
+// byIdempotencyKey returns the notification created with key, or nil when
+// there is none. A key held by another owner is reported as ErrConflict:
+// keys are globally unique, and another owner's record is never revealed.
+func (s *NotificationService) byIdempotencyKey(ctx context.Context, key string) (*domain.Notification, error) {
+	existing, err := s.repo.GetByIdempotencyKey(ctx, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("idempotency lookup: %w", err)
+	}
+	if !domain.OwnedBy(ctx, existing.OwnerID) {
+		return nil, domain.ErrConflict
+	}
+	return existing, nil
+}
+
+// dispatch enqueues a persisted notification for Create and fills in the
+// deferral fields of res when the queue turns it away.
+func (s *NotificationService) dispatch(
+	ctx context.Context,
+	n *domain.Notification,
+	res CreateResult,
+) (*domain.Notification, CreateResult, error) {
+	if n.ScheduledAt != nil || s.enqueue(ctx, n, queue.SourceAPI) {
+		return n, res, nil
+	}
+	res.Deferred = true
+	res.RetryAfter = s.retryAfter(n.Priority)
+	if s.opts.StrictEnqueue {
+		return nil, res, domain.ErrQueueFull
+	}
+	return n, res, nil
+}
+
+// retryAfter estimates how long the queue needs to drain the items that would
+// be served before a new one of the given priority.
+func (s *NotificationService) retryAfter(p domain.Priority) time.Duration {
+	high, normal, low := s.q.Depths()
+	ahead := high
+	switch p {
+	case domain.PriorityNormal:
+		ahead += normal
+	case domain.PriorityLow:
+		ahead += normal + low
+	}
+	d := time.Duration(ahead) * time.Second / time.Duration(s.opts.DrainRate)
+	return min(max(d, minRetryAfter), maxRetryAfter)
 }
 
 // CreateBatch validates and creates up to 1000 notifications in a single
 // transaction, then enqueues the non-scheduled ones.
+//
+// A batch-level template_id applies to every item carrying neither content nor
+// its own template; the template is fetched once and rendered per item. A
+// batch-level scheduled_at is validated once and given to every item without
+// its own, so those items are created scheduled and fire together.
+//
+// With req.AllowPartial, items failing validation are skipped and returned as
+// item errors instead of rejecting the batch; the batch total counts only the
+// accepted items. If every item is rejected, ErrBatchAllRejected is returned
+// together with the item errors.
+//
+// The created notifications are returned in request order, rejected items
+// left out, each with its authoritative status: queued, scheduled, or pending
+// when the queue was full by the time its turn came.
+//
+// The idempotency key, if supplied, protects the whole batch: a replay
+// returns the existing batch and its members, as they are now, with
+// duplicate set to true and nothing enqueued. Item errors of the original
+// request are not kept and so are not replayed.
 func (s *NotificationService) CreateBatch(
 	ctx context.Context,
-	requests []domain.CreateNotificationRequest,
-) (*domain.Batch, error) {
+	req domain.CreateBatchRequest,
+	idempotencyKey string,
+) (batch *domain.Batch, created []*domain.Notification, rejected []domain.BatchItemError, duplicate bool, err error) {
+	return s.createBatch(ctx, req, idempotencyKey)
+}
+
+// FanOut sends one message to every address in req.Recipients by expanding
+// the request into an implicit batch, subject to the CreateBatch limits and
+// per-item validation. Recipients are normalised and deduplicated, keeping the
+// first occurrence, so two spellings of one number count once; item errors
+// index into that deduplicated list.
+//
+// The idempotency key, if supplied, protects the whole fan-out: a replay
+// returns the existing batch with duplicate set to true.
+func (s *NotificationService) FanOut(
+	ctx context.Context,
+	req domain.CreateNotificationRequest,
+	idempotencyKey string,
+) (*domain.Batch, bool, error) {
+	if strings.TrimSpace(req.Recipient) != "" || len(req.Recipients) == 0 {
+		return nil, false, domain.ErrInvalidRecipients
+	}
+
+	seen := make(map[string]bool, len(req.Recipients))
+	items := make([]domain.CreateNotificationRequest, 0, len(req.Recipients))
+	for _, to := range req.Recipients {
+		to = domain.NormalizeRecipient(req.Channel, to, s.opts.Validation.DefaultCountryCode)
+		if seen[to] {
+			continue
+		}
+		seen[to] = true
+		item := req
+		item.Recipient, item.Recipients = to, nil
+		items = append(items, item)
+	}
+
+	batch, _, _, duplicate, err := s.createBatch(ctx, domain.CreateBatchRequest{Notifications: items}, idempotencyKey)
+	return batch, duplicate, err
+}
+
+// createBatch backs CreateBatch and FanOut; a non-empty idempotencyKey is
+// stored on the batch, or replays the batch that already holds it.
+func (s *NotificationService) createBatch(
+	ctx context.Context,
+	req domain.CreateBatchRequest,
+	idempotencyKey string,
+) (*domain.Batch, []*domain.Notification, []domain.BatchItemError, bool, error) {
+	if idempotencyKey != "" {
+		existing, members, err := s.batchByIdempotencyKey(ctx, idempotencyKey)
+		if err != nil || existing != nil {
+			return existing, members, nil, existing != nil, err
+		}
+	}
+
+	requests := req.Notifications
 	if len(requests) == 0 {
-		return nil, domain.ErrBatchEmpty
+		return nil, nil, nil, false, domain.ErrBatchEmpty
+	}
+	if len(requests) > domain.MaxBatchSize {
+		return nil, nil, nil, false, domain.ErrBatchTooLarge
 	}
-	if len(requests) > 1000 {
-		return nil, domain.ErrBatchTooLarge
+	if err := req.ValidateWith(s.opts.Validation); err != nil {
+		return nil, nil, nil, false, err
+	}
+	if err := s.throttle(ctx, len(requests)); err != nil {
+		return nil, nil, nil, false, err
 	}
 
-	batchID := uuid.New().String()
+	batch := &domain.Batch{ID: uuid.New().String(), OwnerID: ownerOf(ctx)}
+	if idempotencyKey != "" {
+		batch.IdempotencyKey = &idempotencyKey
+	}
+	if req.ScheduledAt != nil {
+		at := req.ScheduledAt.UTC()
+		batch.ScheduledAt = &at
+	}
 	now := time.Now().UTC()
 
-	notifications := make([]*domain.Notification, len(requests))
-	for i, req := range requests {
-		if err := req.Validate(); err != nil {
-			return nil, fmt.Errorf("item %d: %w", i, err)
+	templates := map[string]*domain.Template{}
+	notifications := make([]*domain.Notification, 0, len(requests))
+	var rejected []domain.BatchItemError
+	var invalid []domain.FieldError
+	for i, item := range requests {
+		if item.TemplateID == nil && item.Content == "" {
+			item.TemplateID = req.TemplateID
+		}
+		if item.ScheduledAt == nil {
+			item.ScheduledAt = batch.ScheduledAt
+		}
+		item.SendIfPast = item.SendIfPast || req.SendIfPast
+		item.DryRun = item.DryRun || req.DryRun
+		err := s.renderTemplate(ctx, &item, templates)
+		if err == nil {
+			err = item.ValidateWith(s.opts.Validation)
+		}
+		if err != nil {
+			// Lookup failures are infrastructure errors, not a property of the item.
+			if errors.Is(err, errTemplateLookup) || !domain.IsValidationError(err) {
+				return nil, nil, nil, false, fmt.Errorf("item %d: %w", i, err)
+			}
+			for _, f := range domain.Details(err) {
+				f.Index = &i
+				invalid = append(invalid, f)
+				if req.AllowPartial {
+					rejected = append(rejected, domain.BatchItemError{Index: i, Field: f.Field, Code: f.Code, Error: f.Message})
+				}
+			}
+			continue
 		}
-		notifications[i] = s.buildNotification(req, "", &batchID)
-		notifications[i].CreatedAt = now
-		notifications[i].UpdatedAt = now
+
+		n := s.buildNotification(ctx, item, "", &batch.ID, batch.OwnerID)
+		n.CreatedAt = now
+		n.UpdatedAt = now
+		notifications = append(notifications, n)
 	}
 
-	batch, err := s.repo.CreateBatch(ctx, batchID, notifications)
-	if err != nil {
-		return nil, fmt.Errorf("persist batch: %w", err)
+	// Without partial mode every invalid item is reported at once.
+	if !req.AllowPartial && len(invalid) > 0 {
+		return nil, nil, nil, false, &domain.ValidationError{Fields: invalid}
+	}
+	if len(notifications) == 0 {
+		return nil, nil, rejected, false, domain.ErrBatchAllRejected
+	}
+
+	if err := s.repo.CreateBatch(ctx, batch, notifications); err != nil {
+		if errors.Is(err, domain.ErrConflict) && idempotencyKey != "" {
+			// A concurrent request with the same key inserted first; as in
+			// Create, its batch is the answer and it enqueues the members.
+			existing, members, lookupErr := s.batchByIdempotencyKey(ctx, idempotencyKey)
+			if lookupErr != nil || existing != nil {
+				return existing, members, nil, existing != nil, lookupErr
+			}
+		}
+		return nil, nil, nil, false, fmt.Errorf("persist batch: %w", err)
 	}
 
+	deferred := 0
 	for _, n := range notifications {
-		if n.ScheduledAt == nil {
-			s.enqueue(ctx, n)
+		s.publish(ctx, events.TypeCreated, n)
+		if n.Status == domain.StatusPending && !s.enqueue(ctx, n, queue.SourceBatch) {
+			deferred++
 		}
 	}
+	if deferred > 0 {
+		s.logger.Warn("queue full: batch members left pending",
+			zap.String("batch_id", batch.ID), zap.Int("deferred", deferred), zap.Int("total", len(notifications)))
+	}
 
-	return batch, nil
+	return batch, notifications, rejected, false, nil
 }
 
-// Cancel marks a notification as cancelled if it is still in a cancellable state.
-func (s *NotificationService) Cancel(ctx context.Context, id string) error {
-	n, err := s.repo.GetByID(ctx, id)
+// batchByIdempotencyKey returns the batch created with key and its members,
+// or nil when there is none. Like byIdempotencyKey, a key held by another
+// owner is reported as ErrConflict.
+func (s *NotificationService) batchByIdempotencyKey(
+	ctx context.Context,
+	key string,
+) (*domain.Batch, []*domain.Notification, error) {
+	existing, err := s.repo.GetBatchByIdempotencyKey(ctx, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("idempotency lookup: %w", err)
+	}
+	if !domain.OwnedBy(ctx, existing.OwnerID) {
+		return nil, nil, domain.ErrConflict
+	}
+	batch, members, err := s.repo.GetBatch(ctx, existing.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("idempotency lookup: %w", err)
+	}
+	return batch, members, nil
+}
+
+// Cancel marks a notification as cancelled if it is still in a cancellable
+// state, recording the optional reason, the caller's owner ID and the request's
+// correlation ID. The repository re-checks the status as it updates, so a
+// worker claiming or delivering the notification in the meantime wins.
+func (s *NotificationService) Cancel(ctx context.Context, id string, req domain.CancelRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	n, err := s.getOwned(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	switch n.Status {
-	case domain.StatusCancelled:
+	if err := cancelError(n.Status); err != nil {
+		return err
+	}
+
+	c := domain.Cancellation{
+		By:            ownerOf(ctx),
+		CorrelationID: nonEmpty(req.CorrelationID),
+		Reason:        nonEmpty(req.Reason),
+		At:            time.Now().UTC(),
+	}
+	if err := s.repo.Cancel(ctx, id, c); err != nil {
+		if !errors.Is(err, domain.ErrNotCancellable) {
+			return err
+		}
+		// The status moved on since it was read; report what it is now.
+		cur, getErr := s.repo.GetByID(ctx, id)
+		if getErr != nil {
+			return getErr
+		}
+		if err := cancelError(cur.Status); err != nil {
+			return err
+		}
+		return domain.ErrNotCancellable
+	}
+	n.Status = domain.StatusCancelled
+	n.CancelledReason, n.CancelledAt, n.CancelledBy, n.CancelCorrelationID = c.Reason, &c.At, c.By, c.CorrelationID
+	s.publish(ctx, events.TypeCancelled, n)
+	s.batchChanged(ctx, n)
+	if s.opts.OnTerminal != nil {
+		s.opts.OnTerminal(n)
+	}
+	return nil
+}
+
+// cancelError is why a notification in status cannot be cancelled, or nil.
+func cancelError(status domain.Status) error {
+	switch {
+	case status == domain.StatusCancelled:
 		return domain.ErrAlreadyCancelled
-	case domain.StatusProcessing, domain.StatusSent:
+	case !status.IsCancellable():
 		return domain.ErrNotCancellable
 	}
+	return nil
+}
 
-	return s.repo.Cancel(ctx, id)
+// editableFields lists what Update may change in each status. Queued rows are
+// left to ChangePriority, which also moves them within the in-memory queue.
+var editableFields = map[domain.Status][]string{
+	domain.StatusDraft:     {"content", "priority", "scheduled_at", "max_retries"},
+	domain.StatusPending:   {"content", "priority", "scheduled_at", "max_retries"},
+	domain.StatusScheduled: {"content", "priority", "scheduled_at", "max_retries"},
+	domain.StatusFailed:    {"priority", "max_retries"},
 }
 
-func (s *NotificationService) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
+// Update edits a notification that has not been delivered. Draft, pending and
+// scheduled notifications accept every field of req; failed ones only
+// priority and max_retries, which governs whether the retry worker tries
+// again. Anything else yields ErrNotEditable. Setting scheduled_at on a
+// pending notification turns it into a scheduled one, leaving dispatch to the
+// scheduler worker; a draft stays a draft until submitted.
+//
+// pre, when set, must hold for the stored row or ErrPreconditionFailed is
+// returned; it is checked again atomically by the repository.
+func (s *NotificationService) Update(
+	ctx context.Context,
+	id string,
+	req domain.UpdateNotificationRequest,
+	pre domain.UpdatePrecondition,
+) (*domain.Notification, error) {
+	if err := req.ValidateWith(s.opts.Validation); err != nil {
+		return nil, err
+	}
+
+	n, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !pre.Holds(n.UpdatedAt) {
+		return nil, domain.ErrPreconditionFailed
+	}
+	allowed, ok := editableFields[n.Status]
+	if !ok {
+		return nil, domain.ErrNotEditable
+	}
+	for _, f := range req.Fields() {
+		if !slices.Contains(allowed, f) {
+			return nil, fmt.Errorf("%w: %s cannot be changed while %s", domain.ErrNotEditable, f, n.Status)
+		}
+	}
+	if req.MaxRetries != nil && *req.MaxRetries < n.RetryCount {
+		return nil, fmt.Errorf("%w; %d retries are already used", domain.ErrInvalidMaxRetries, n.RetryCount)
+	}
+
+	if req.Content != nil {
+		if err := s.opts.Validation.CheckContent(n.Channel, *req.Content); err != nil {
+			return nil, err
+		}
+		n.Content = *req.Content
+	}
+	n.DedupHash = domain.DedupHash(deref(n.OwnerID), n.Channel, n.Recipient, n.Content, n.DryRun)
+	if req.Priority != nil {
+		n.Priority = *req.Priority
+	}
+	if req.ScheduledAt != nil {
+		if n.ExpiresAt != nil && !n.ExpiresAt.After(*req.ScheduledAt) {
+			return nil, domain.ErrInvalidExpiry
+		}
+		at := req.ScheduledAt.UTC()
+		n.ScheduledAt = &at
+		if n.Status != domain.StatusDraft {
+			n.Status = domain.StatusScheduled
+		}
+	}
+	if req.MaxRetries != nil {
+		n.MaxRetries = *req.MaxRetries
+	}
+
+	if err := s.repo.Update(ctx, n, pre); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Submit releases a draft into the normal flow: it becomes scheduled when it
+// carries a scheduled_at and is queued otherwise, exactly as if it had just
+// been created, including the deferral reporting of CreateResult. Anything but
+// a draft yields ErrNotDraft, and a draft past its expires_at ErrInvalidExpiry.
+func (s *NotificationService) Submit(ctx context.Context, id string) (*domain.Notification, CreateResult, error) {
+	n, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, CreateResult{}, err
+	}
+	if n.Status != domain.StatusDraft {
+		return nil, CreateResult{}, domain.ErrNotDraft
+	}
+	if n.IsExpired(time.Now()) {
+		return nil, CreateResult{}, domain.ErrInvalidExpiry
+	}
+
+	status := domain.StatusPending
+	if n.ScheduledAt != nil {
+		status = domain.StatusScheduled
+	}
+	if err := s.repo.Submit(ctx, id, status); err != nil {
+		return nil, CreateResult{}, err
+	}
+	n.Status = status
+	return s.dispatch(ctx, n, CreateResult{})
+}
+
+// Resend creates a new notification with the channel, recipient, content,
+// priority, email parts and callback URL of an earlier one, linked to it via
+// resend_of, and dispatches it like Create (the dedup window is skipped, the
+// repeat being intentional). Originals that may still be delivered — pending,
+// queued, processing, scheduled, or failed with a retry ahead — yield
+// ErrResendInFlight.
+func (s *NotificationService) Resend(ctx context.Context, id string) (*domain.Notification, CreateResult, error) {
+	orig, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, CreateResult{}, err
+	}
+	switch orig.Status {
+	case domain.StatusSent, domain.StatusCancelled, domain.StatusDraft, domain.StatusExpired:
+	case domain.StatusFailed:
+		if orig.NextRetryAt != nil && orig.RetryCount <= orig.MaxRetries {
+			return nil, CreateResult{}, domain.ErrResendInFlight
+		}
+	default:
+		return nil, CreateResult{}, domain.ErrResendInFlight
+	}
+	if err := s.throttle(ctx, 1); err != nil {
+		return nil, CreateResult{}, err
+	}
+
+	n := s.buildNotification(ctx, domain.CreateNotificationRequest{
+		Channel:     orig.Channel,
+		Recipient:   orig.Recipient,
+		Content:     orig.Content,
+		Priority:    orig.Priority,
+		MaxRetries:  &orig.MaxRetries,
+		CallbackURL: orig.CallbackURL,
+		Email:       orig.Email,
+		DryRun:      orig.DryRun,
+	}, "", nil, orig.OwnerID)
+	n.ResendOf = &orig.ID
+
+	if err := s.repo.Create(ctx, n); err != nil {
+		return nil, CreateResult{}, fmt.Errorf("persist notification: %w", err)
+	}
+	s.publish(ctx, events.TypeCreated, n)
+	return s.dispatch(ctx, n, CreateResult{})
+}
+
+// ChangePriority moves a notification that has not been dispatched yet to a
+// new priority. Draft, pending, scheduled and failed rows only need the stored value
+// changed; a queued row is also moved to the new tier of the in-memory queue.
+// Processing and terminal rows yield ErrPriorityLocked.
+//
+// The queue is updated before the row so that a full target tier
+// (ErrQueueFull) leaves both untouched.
+func (s *NotificationService) ChangePriority(ctx context.Context, id string, priority domain.Priority) (*domain.Notification, error) {
+	if !priority.IsValid() {
+		return nil, domain.ErrInvalidPriority
+	}
+
+	n, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Status {
+	case domain.StatusDraft, domain.StatusPending, domain.StatusScheduled, domain.StatusFailed:
+	case domain.StatusQueued:
+		// Not finding the item means a worker already took it; the row
+		// update below then either still applies or reports ErrPriorityLocked.
+		if _, err := s.q.Reprioritize(queue.Item{
+			NotificationID: n.ID,
+			Channel:        n.Channel,
+			Priority:       priority,
+			TraceParent:    tracing.Inject(ctx),
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, domain.ErrPriorityLocked
+	}
+
+	if err := s.repo.UpdatePriority(ctx, id, priority); err != nil {
+		return nil, err
+	}
+	n.Priority = priority
+	return n, nil
+}
+
+// RetryNow re-dispatches a failed notification immediately instead of waiting
+// for next_retry_at. Without resetCount it refuses notifications that have
+// exhausted max_retries; with it, retry_count starts over from zero.
+//
+// If the queue is full the row is returned to failed with next_retry_at = now,
+// so the retry worker picks it up on its next poll, and ErrQueueFull is returned.
+func (s *NotificationService) RetryNow(ctx context.Context, id string, resetCount bool) (*domain.Notification, error) {
+	n, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Status != domain.StatusFailed {
+		return nil, domain.ErrNotRetryable
+	}
+	if !resetCount && n.RetryCount >= n.MaxRetries {
+		return nil, domain.ErrRetriesExhausted
+	}
+
+	// Claiming the row as queued first keeps the retry worker from
+	// enqueueing it a second time.
+	if err := s.repo.RequeueFailed(ctx, id, resetCount); err != nil {
+		return nil, err
+	}
+
+	span, traceParent := tracing.StartEnqueue(ctx, n.ID)
+	err = s.q.Enqueue(queue.Item{
+		NotificationID: n.ID,
+		Channel:        n.Channel,
+		Priority:       n.Priority,
+		TraceParent:    traceParent,
+	})
+	tracing.End(span, err)
+	if err != nil {
+		s.queueFull(err, n.Priority, queue.SourceAPI)
+		if err := s.repo.RevertQueued(ctx, id, domain.StatusFailed); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			s.logger.Error("failed to revert status to failed", zap.String("id", id), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	n.Status = domain.StatusQueued
+	s.publish(ctx, events.TypeQueued, n)
 	return s.repo.GetByID(ctx, id)
 }
 
+// RequeueResult reports what RequeuePending did.
+type RequeueResult struct {
+	// Found is the number of stranded pending notifications considered.
+	Found int
+	// Requeued were claimed as queued and put on the queue.
+	Requeued int
+	// Skipped stay pending because their priority's queue was full.
+	Skipped int
+}
+
+// maxRequeue caps how many notifications one RequeuePending call handles.
+const maxRequeue = 1000
+
+// RequeuePending enqueues pending notifications that have sat unchanged for
+// longer than olderThan, e.g. because the queue was full when they were
+// created. A pending row is never on the queue, so each one is claimed as
+// queued before it is enqueued, exactly as on create. A nil channel matches
+// every channel. It acts on all owners and is meant for operators only.
+//
+// Once a priority's queue reports full, the remaining rows of that priority
+// are skipped without another attempt.
+func (s *NotificationService) RequeuePending(ctx context.Context, olderThan time.Duration, channel *domain.Channel) (RequeueResult, error) {
+	stale, err := s.repo.FindStalePending(ctx, time.Now().UTC().Add(-olderThan), channel, maxRequeue)
+	if err != nil {
+		return RequeueResult{}, err
+	}
+
+	result := RequeueResult{Found: len(stale)}
+	full := make(map[domain.Priority]bool)
+	for _, n := range stale {
+		if full[n.Priority] {
+			result.Skipped++
+			continue
+		}
+		if err := s.repo.ClaimPending(ctx, n.ID); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				continue // dispatched or cancelled since the lookup
+			}
+			return result, err
+		}
+
+		span, traceParent := tracing.StartEnqueue(ctx, n.ID)
+		err := s.q.Enqueue(queue.Item{
+			NotificationID: n.ID,
+			Channel:        n.Channel,
+			Priority:       n.Priority,
+			TraceParent:    traceParent,
+		})
+		tracing.End(span, err)
+		if err != nil {
+			s.queueFull(err, n.Priority, queue.SourceAPI)
+			full[n.Priority] = true
+			result.Skipped++
+			if err := s.repo.RevertQueued(ctx, n.ID, domain.StatusPending); err != nil && !errors.Is(err, domain.ErrNotFound) {
+				s.logger.Error("failed to revert status to pending", zap.String("id", n.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		n.Status = domain.StatusQueued
+		s.publish(ctx, events.TypeQueued, n)
+		result.Requeued++
+	}
+
+	s.logger.Info("requeued stranded pending notifications",
+		zap.Int("found", result.Found), zap.Int("requeued", result.Requeued), zap.Int("skipped", result.Skipped))
+	return result, nil
+}
+
+// ListDeadLetters pages through notifications that failed for good, across
+// all owners. It is meant for operators only.
+func (s *NotificationService) ListDeadLetters(ctx context.Context, filter domain.DeadLetterFilter) ([]*domain.DeadLetter, int, error) {
+	return s.repo.ListDeadLetters(ctx, filter)
+}
+
+// GetDeadLetter returns a dead-lettered notification with its attempt history.
+func (s *NotificationService) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	return s.repo.GetDeadLetter(ctx, id)
+}
+
+// RequeueDeadLetter gives a dead-lettered notification a fresh set of retries
+// and queues it. ErrNotFound is returned for notifications that are not dead
+// letters; ErrQueueFull means the retry worker will pick it up instead.
+func (s *NotificationService) RequeueDeadLetter(ctx context.Context, id string) (*domain.Notification, error) {
+	if _, err := s.repo.GetDeadLetter(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.RetryNow(ctx, id, true)
+}
+
+// DeadLetterRequeueResult sorts the IDs given to RequeueDeadLetters by outcome.
+type DeadLetterRequeueResult struct {
+	Requeued []string
+	// Deferred could not be queued because the queue was full; the retry
+	// worker picks them up on its next poll.
+	Deferred []string
+	// NotFound are unknown or not dead-lettered.
+	NotFound []string
+}
+
+// RequeueDeadLetters applies RequeueDeadLetter to each of ids.
+func (s *NotificationService) RequeueDeadLetters(ctx context.Context, ids []string) (DeadLetterRequeueResult, error) {
+	var result DeadLetterRequeueResult
+	for _, id := range ids {
+		_, err := s.RequeueDeadLetter(ctx, id)
+		switch {
+		case err == nil:
+			result.Requeued = append(result.Requeued, id)
+		case errors.Is(err, domain.ErrQueueFull):
+			result.Deferred = append(result.Deferred, id)
+		case errors.Is(err, domain.ErrNotFound), errors.Is(err, domain.ErrNotRetryable):
+			result.NotFound = append(result.NotFound, id)
+		default:
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (s *NotificationService) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
+	return s.getOwned(ctx, id)
+}
+
+// LookupResult is the outcome of a Lookup: the caller's notifications in
+// request order, and the requested IDs that matched none of them.
+type LookupResult struct {
+	Notifications []*domain.Notification
+	Missing       []string
+}
+
+// Lookup fetches several of the caller's notifications in one query.
+// Repeated IDs are answered once; IDs of other owners count as missing, as
+// GetByID would report them not found.
+func (s *NotificationService) Lookup(ctx context.Context, ids []string) (LookupResult, error) {
+	if len(ids) == 0 || len(ids) > s.opts.MaxLookupIDs {
+		msg := fmt.Sprintf("ids must list between 1 and %d notification IDs", s.opts.MaxLookupIDs)
+		return LookupResult{}, &domain.ValidationError{Fields: []domain.FieldError{
+			{Field: "ids", Code: "out_of_range", Message: msg, Err: domain.ErrInvalidLookup},
+		}}
+	}
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	found, err := s.repo.GetByIDs(ctx, unique)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	byID := make(map[string]*domain.Notification, len(found))
+	for _, n := range found {
+		if domain.OwnedBy(ctx, n.OwnerID) {
+			byID[n.ID] = n
+		}
+	}
+
+	var result LookupResult
+	for _, id := range unique {
+		if n, ok := byID[id]; ok {
+			result.Notifications = append(result.Notifications, n)
+		} else {
+			result.Missing = append(result.Missing, id)
+		}
+	}
+	return result, nil
+}
+
+// GetByProviderMsgID resolves a provider message ID (as quoted in delivery
+// receipts or provider support tickets) to our notification.
+func (s *NotificationService) GetByProviderMsgID(ctx context.Context, providerMsgID string) (*domain.Notification, error) {
+	n, err := s.repo.GetByProviderMsgID(ctx, providerMsgID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.OwnedBy(ctx, n.OwnerID) {
+		return nil, domain.ErrNotFound
+	}
+	return n, nil
+}
+
+// List returns the caller's notifications; filter.OwnerID is always taken from
+// the authenticated context.
 func (s *NotificationService) List(ctx context.Context, filter domain.ListFilter) ([]*domain.Notification, int, error) {
+	filter.OwnerID = ownerOf(ctx)
 	return s.repo.List(ctx, filter)
 }
 
 func (s *NotificationService) GetBatch(ctx context.Context, batchID string) (*domain.Batch, []*domain.Notification, error) {
-	return s.repo.GetBatch(ctx, batchID)
+	batch, notifications, err := s.repo.GetBatch(ctx, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !domain.OwnedBy(ctx, batch.OwnerID) {
+		return nil, nil, domain.ErrNotFound
+	}
+	return batch, notifications, nil
+}
+
+// GetBatchSummary returns the batch and its counters without loading the
+// member notifications.
+func (s *NotificationService) GetBatchSummary(ctx context.Context, batchID string) (*domain.Batch, error) {
+	batch, err := s.repo.GetBatchByID(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.OwnedBy(ctx, batch.OwnerID) {
+		return nil, domain.ErrNotFound
+	}
+	return batch, nil
 }
 
 // ---- private helpers ----
 
+// getOwned loads a notification, reporting other owners' records as not found
+// so callers cannot probe for IDs that are not theirs.
+func (s *NotificationService) getOwned(ctx context.Context, id string) (*domain.Notification, error) {
+	n, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.OwnedBy(ctx, n.OwnerID) {
+		return nil, domain.ErrNotFound
+	}
+	return n, nil
+}
+
+// ownerOf returns the authenticated owner to stamp on new records, or nil
+// when authentication is disabled.
+func ownerOf(ctx context.Context) *string {
+	if owner, ok := domain.OwnerFromContext(ctx); ok {
+		return &owner
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// errTemplateLookup marks template store failures so partial batches abort on
+// them rather than blaming the item.
+var errTemplateLookup = errors.New("template lookup")
+
+// renderTemplate resolves req.TemplateID and renders it into req.Content so the
+// result is validated like any hand-written content. cache, when non-nil,
+// avoids refetching the same template for every item of a batch.
+func (s *NotificationService) renderTemplate(
+	ctx context.Context,
+	req *domain.CreateNotificationRequest,
+	cache map[string]*domain.Template,
+) error {
+	if req.TemplateID == nil {
+		return nil
+	}
+	if req.Content != "" {
+		return domain.ErrContentAndTemplate
+	}
+
+	t, ok := cache[*req.TemplateID]
+	if !ok {
+		if s.opts.Templates == nil {
+			return domain.ErrUnknownTemplate
+		}
+		var err error
+		t, err = s.opts.Templates.GetByID(ctx, *req.TemplateID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.ErrUnknownTemplate
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", errTemplateLookup, err)
+		}
+		if cache != nil {
+			cache[*req.TemplateID] = t
+		}
+	}
+
+	content, err := t.Render(req.Variables)
+	if err != nil {
+		return err
+	}
+	req.Content = content
+	return nil
+}
+
 func (s *NotificationService) buildNotification(
+	ctx context.Context,
 	req domain.CreateNotificationRequest,
 	idempotencyKey string,
 	batchID *string,
+	ownerID *string,
 ) *domain.Notification {
 	now := time.Now().UTC()
 	status := domain.StatusPending
-	if req.ScheduledAt != nil {
+	switch {
+	case req.Draft:
+		status = domain.StatusDraft
+	case req.ScheduledAt != nil:
 		status = domain.StatusScheduled
 	}
 
+	maxRetries := *s.opts.DefaultMaxRetries
+	if req.MaxRetries != nil {
+		maxRetries = *req.MaxRetries
+	}
+	dryRun := req.DryRun || s.opts.Sandbox
+
 	n := &domain.Notification{
-		ID:         uuid.New().String(),
-		BatchID:    batchID,
-		Channel:    req.Channel,
-		Recipient:  req.Recipient,
-		Content:    req.Content,
-		Priority:   req.Priority,
-		Status:     status,
-		MaxRetries: 3,
-		ScheduledAt: req.ScheduledAt,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:              uuid.New().String(),
+		BatchID:         batchID,
+		Channel:         req.Channel,
+		Recipient:       req.Recipient,
+		Content:         req.Content,
+		Priority:        req.Priority,
+		Status:          status,
+		MaxRetries:      maxRetries,
+		ScheduledAt:     req.ScheduledAt,
+		TemplateID:      req.TemplateID,
+		OwnerID:         ownerID,
+		CallbackURL:     req.CallbackURL,
+		SendWindowStart: req.SendWindowStart,
+		SendWindowEnd:   req.SendWindowEnd,
+		Timezone:        req.Timezone,
+		ExpiresAt:       req.ExpiresAt,
+		Email:           req.Email,
+		CorrelationID:   nonEmpty(domain.CorrelationIDFromContext(ctx)),
+		DryRun:          dryRun,
+		DedupHash:       domain.DedupHash(deref(ownerID), req.Channel, req.Recipient, req.Content, dryRun),
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
 	if idempotencyKey != "" {
@@ -170,29 +1144,95 @@ func (s *NotificationService) buildNotification(
 	return n
 }
 
-// enqueue places the notification on the queue and updates its status to queued.
-// If the queue is full the notification remains in status=pending; the retry
-// worker will not re-enqueue pending items, so for robustness a separate
-// recovery mechanism (or operator alert on queue_depth gauges) is warranted
-// in production. For this scope we log a warning.
-func (s *NotificationService) enqueue(ctx context.Context, n *domain.Notification) {
+// enqueue claims the pending notification as queued and places it on the queue.
+//
+// The row is claimed before the item becomes visible to workers so that a
+// reader never sees pending for something already on the queue, and a fast
+// worker's processing status is never overwritten by a late queued update.
+// The claim only takes a row that is still pending: one cancelled or
+// dispatched meanwhile is left alone, n is given its current status, and true
+// is returned as nothing was deferred. If the queue is full the row is
+// reverted to pending and false is returned; callers surface that to clients
+// instead of pretending delivery is underway.
+func (s *NotificationService) enqueue(ctx context.Context, n *domain.Notification, source queue.Source) bool {
 	if n.ScheduledAt != nil {
-		return // scheduler worker handles these
+		return false // scheduler worker handles these
+	}
+
+	if err := s.repo.ClaimPending(ctx, n.ID); err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			s.logger.Error("failed to claim notification as queued", zap.String("id", n.ID), zap.Error(err))
+			return false
+		}
+		cur, err := s.repo.GetByID(ctx, n.ID)
+		if err != nil {
+			s.logger.Error("failed to read notification that moved on", zap.String("id", n.ID), zap.Error(err))
+			return true
+		}
+		*n = *cur
+		return true
 	}
 
-	if err := s.q.Enqueue(queue.Item{
+	span, traceParent := tracing.StartEnqueue(ctx, n.ID)
+	err := s.q.Enqueue(queue.Item{
 		NotificationID: n.ID,
 		Channel:        n.Channel,
 		Priority:       n.Priority,
-	}); err != nil {
+		TraceParent:    traceParent,
+	})
+	tracing.End(span, err)
+	if err != nil {
+		s.queueFull(err, n.Priority, source)
 		s.logger.Warn("queue full: notification will remain pending",
 			zap.String("id", n.ID), zap.Error(err))
-		return
+		if err := s.repo.RevertQueued(ctx, n.ID, domain.StatusPending); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			s.logger.Error("failed to revert status to pending", zap.String("id", n.ID), zap.Error(err))
+		}
+		return false
 	}
 
-	if err := s.repo.UpdateStatus(ctx, n.ID, domain.StatusQueued); err != nil {
-		s.logger.Error("failed to update status to queued", zap.String("id", n.ID), zap.Error(err))
+	n.Status = domain.StatusQueued
+	s.publish(ctx, events.TypeQueued, n)
+	return true
+}
+
+// batchChanged reports that n, if it belongs to a batch, has settled, so the
+// batch's counters and completion catch up, as the worker does for sends.
+func (s *NotificationService) batchChanged(ctx context.Context, n *domain.Notification) {
+	if n.BatchID == nil {
 		return
 	}
-	n.Status = domain.StatusQueued
+	if s.opts.OnBatchChanged != nil {
+		s.opts.OnBatchChanged(*n.BatchID)
+		return
+	}
+	if _, err := s.repo.UpdateBatchCounts(ctx, *n.BatchID); err != nil {
+		s.logger.Warn("failed to update batch counts", zap.String("batch_id", *n.BatchID), zap.Error(err))
+	}
+}
+
+// queueFull reports err to Options.OnQueueFull if it is ErrQueueFull.
+func (s *NotificationService) queueFull(err error, priority domain.Priority, source queue.Source) {
+	if s.opts.OnQueueFull != nil && errors.Is(err, domain.ErrQueueFull) {
+		s.opts.OnQueueFull(priority, source)
+	}
+}
+
+// publish hands a lifecycle event for n to the configured publisher.
+func (s *NotificationService) publish(ctx context.Context, t events.Type, n *domain.Notification) {
+	if err := s.opts.Events.Publish(ctx, events.New(ctx, t, n)); err != nil {
+		s.logger.Warn("failed to publish lifecycle event",
+			zap.String("type", string(t)), zap.String("id", n.ID), zap.Error(err))
+	}
+}
+
+// throttle charges a create of n notifications to the caller's owner.
+func (s *NotificationService) throttle(ctx context.Context, n int) error {
+	if s.opts.CreationLimiter == nil {
+		return nil
+	}
+	if wait, ok := s.opts.CreationLimiter.Allow(deref(ownerOf(ctx)), n); !ok {
+		return &domain.RateLimitError{RetryAfter: wait}
+	}
+	return nil
 }
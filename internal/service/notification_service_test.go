@@ -2,11 +2,18 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/repository"
 	"github.com/ricirt/event-driven-arch/internal/service"
@@ -15,7 +22,7 @@ import (
 func newService() (*service.NotificationService, *repository.MockNotificationRepository, *queue.PriorityQueue) {
 	repo := repository.NewMockNotificationRepository()
 	q := queue.New()
-	svc := service.NewNotificationService(repo, q, zap.NewNop())
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
 	return svc, repo, q
 }
 
@@ -30,12 +37,12 @@ func TestNotificationService_Create(t *testing.T) {
 	svc, _, q := newService()
 	ctx := context.Background()
 
-	n, isDuplicate, err := svc.Create(ctx, validReq, "")
+	n, res, err := svc.Create(ctx, validReq, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if isDuplicate {
-		t.Fatal("expected isDuplicate=false for a new notification")
+	if res.Duplicate || res.Deferred {
+		t.Fatalf("expected a new, queued notification, got %+v", res)
 	}
 	if n.ID == "" {
 		t.Fatal("expected a non-empty ID")
@@ -50,13 +57,43 @@ func TestNotificationService_Create(t *testing.T) {
 	}
 }
 
+func TestNotificationService_Create_DefaultMaxRetries(t *testing.T) {
+	five, zero := 5, 0
+	tests := []struct {
+		name    string
+		def     *int
+		request *int
+		want    int
+	}{
+		{"built-in default", nil, nil, domain.DefaultMaxRetries},
+		{"configured default", &five, nil, 5},
+		{"configured zero", &zero, nil, 0},
+		{"request wins", &zero, &five, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+				service.Options{DefaultMaxRetries: tt.def})
+			req := validReq
+			req.MaxRetries = tt.request
+			n, _, err := svc.Create(context.Background(), req, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.MaxRetries != tt.want {
+				t.Fatalf("expected max_retries %d, got %d", tt.want, n.MaxRetries)
+			}
+		})
+	}
+}
+
 func TestNotificationService_Create_InvalidRequest(t *testing.T) {
 	svc, _, _ := newService()
 
 	bad := validReq
 	bad.Channel = "fax"
 	_, _, err := svc.Create(context.Background(), bad, "")
-	if err != domain.ErrInvalidChannel {
+	if !errors.Is(err, domain.ErrInvalidChannel) {
 		t.Fatalf("expected ErrInvalidChannel, got %v", err)
 	}
 }
@@ -66,23 +103,79 @@ func TestNotificationService_Create_IdempotencyReturnsDuplicate(t *testing.T) {
 	ctx := context.Background()
 
 	key := "idem-key-123"
-	first, isDup, err := svc.Create(ctx, validReq, key)
-	if err != nil || isDup {
-		t.Fatalf("first call: err=%v isDup=%v", err, isDup)
+	first, res, err := svc.Create(ctx, validReq, key)
+	if err != nil || res.Duplicate {
+		t.Fatalf("first call: err=%v duplicate=%v", err, res.Duplicate)
 	}
 
-	second, isDup, err := svc.Create(ctx, validReq, key)
+	second, res, err := svc.Create(ctx, validReq, key)
 	if err != nil {
 		t.Fatalf("second call: unexpected error: %v", err)
 	}
-	if !isDup {
-		t.Fatal("expected isDuplicate=true for repeated idempotency key")
+	if !res.Duplicate {
+		t.Fatal("expected a duplicate for repeated idempotency key")
 	}
 	if second.ID != first.ID {
 		t.Fatal("expected same notification ID on duplicate")
 	}
 }
 
+// keyBarrierRepo holds the first two idempotency lookups until both have
+// missed, so two creates with the same key both go on to insert.
+type keyBarrierRepo struct {
+	*repository.MockNotificationRepository
+	lookups atomic.Int32
+	missed  sync.WaitGroup
+}
+
+func (r *keyBarrierRepo) GetByIdempotencyKey(ctx context.Context, key string) (*domain.Notification, error) {
+	n, err := r.MockNotificationRepository.GetByIdempotencyKey(ctx, key)
+	if r.lookups.Add(1) <= 2 {
+		r.missed.Done()
+		r.missed.Wait()
+	}
+	return n, err
+}
+
+func TestNotificationService_Create_ConcurrentIdempotentCreatesShareOneRecord(t *testing.T) {
+	repo := &keyBarrierRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	repo.missed.Add(2)
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+
+	type outcome struct {
+		n   *domain.Notification
+		res service.CreateResult
+		err error
+	}
+	outcomes := make([]outcome, 2)
+	var wg sync.WaitGroup
+	for i := range outcomes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, res, err := svc.Create(context.Background(), validReq, "idem-race")
+			outcomes[i] = outcome{n, res, err}
+		}()
+	}
+	wg.Wait()
+
+	for i, o := range outcomes {
+		if o.err != nil {
+			t.Fatalf("create %d: unexpected error: %v", i, o.err)
+		}
+	}
+	if outcomes[0].n.ID != outcomes[1].n.ID {
+		t.Fatalf("expected both creates to return the same notification, got %s and %s", outcomes[0].n.ID, outcomes[1].n.ID)
+	}
+	if outcomes[0].res.Duplicate == outcomes[1].res.Duplicate {
+		t.Fatalf("expected exactly one duplicate, got %+v and %+v", outcomes[0].res, outcomes[1].res)
+	}
+	if _, queued, _ := q.Depths(); queued != 1 {
+		t.Fatalf("expected the notification enqueued once, got %d", queued)
+	}
+}
+
 func TestNotificationService_Cancel_States(t *testing.T) {
 	ctx := context.Background()
 
@@ -96,6 +189,7 @@ func TestNotificationService_Cancel_States(t *testing.T) {
 		{"already cancelled", domain.StatusCancelled, domain.ErrAlreadyCancelled},
 		{"processing cannot be cancelled", domain.StatusProcessing, domain.ErrNotCancellable},
 		{"sent cannot be cancelled", domain.StatusSent, domain.ErrNotCancellable},
+		{"expired cannot be cancelled", domain.StatusExpired, domain.ErrNotCancellable},
 	}
 
 	for _, tc := range tests {
@@ -105,7 +199,7 @@ func TestNotificationService_Cancel_States(t *testing.T) {
 			n, _, _ := svc.Create(ctx, validReq, "")
 			_ = repo.UpdateStatus(ctx, n.ID, tc.status)
 
-			err := svc.Cancel(ctx, n.ID)
+			err := svc.Cancel(ctx, n.ID, domain.CancelRequest{})
 			if err != tc.expectedErr {
 				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
 			}
@@ -113,9 +207,74 @@ func TestNotificationService_Cancel_States(t *testing.T) {
 	}
 }
 
+// racingRepo runs race right after the service first reads a notification,
+// as a worker or a second cancel would between its status check and update.
+type racingRepo struct {
+	*repository.MockNotificationRepository
+	race func(ctx context.Context, id string)
+}
+
+func (r *racingRepo) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
+	n, err := r.MockNotificationRepository.GetByID(ctx, id)
+	if race := r.race; err == nil && race != nil {
+		r.race = nil
+		race(ctx, id)
+	}
+	return n, err
+}
+
+func TestNotificationService_Cancel_LosesRaceWithWorker(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		race        func(repo *repository.MockNotificationRepository) func(ctx context.Context, id string)
+		expectedErr error
+		want        domain.Status
+	}{
+		{"delivered meanwhile", func(repo *repository.MockNotificationRepository) func(context.Context, string) {
+			return func(ctx context.Context, id string) { _ = repo.MarkSent(ctx, id, "provider-1", time.Now()) }
+		}, domain.ErrNotCancellable, domain.StatusSent},
+		{"claimed meanwhile", func(repo *repository.MockNotificationRepository) func(context.Context, string) {
+			return func(ctx context.Context, id string) { _ = repo.UpdateStatus(ctx, id, domain.StatusProcessing) }
+		}, domain.ErrNotCancellable, domain.StatusProcessing},
+		{"cancelled meanwhile", func(repo *repository.MockNotificationRepository) func(context.Context, string) {
+			return func(ctx context.Context, id string) {
+				_ = repo.Cancel(ctx, id, domain.Cancellation{At: time.Now()})
+			}
+		}, domain.ErrAlreadyCancelled, domain.StatusCancelled},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := repository.NewMockNotificationRepository()
+			repo := &racingRepo{MockNotificationRepository: mock}
+			var terminal int
+			svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{
+				OnTerminal: func(*domain.Notification) { terminal++ },
+			})
+			n, _, err := svc.Create(ctx, validReq, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repo.race = tc.race(mock)
+			if err := svc.Cancel(ctx, n.ID, domain.CancelRequest{}); !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+			got, _ := mock.GetByID(ctx, n.ID)
+			if got.Status != tc.want {
+				t.Fatalf("expected the row to stay %s, got %s", tc.want, got.Status)
+			}
+			if terminal != 0 {
+				t.Fatalf("expected no terminal report for a lost cancel, got %d", terminal)
+			}
+		})
+	}
+}
+
 func TestNotificationService_Cancel_NotFound(t *testing.T) {
 	svc, _, _ := newService()
-	err := svc.Cancel(context.Background(), "nonexistent-id")
+	err := svc.Cancel(context.Background(), "nonexistent-id", domain.CancelRequest{})
 	if err != domain.ErrNotFound {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
@@ -129,7 +288,7 @@ func TestNotificationService_CreateBatch(t *testing.T) {
 		requests[i] = validReq
 	}
 
-	batch, err := svc.CreateBatch(context.Background(), requests)
+	batch, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{Notifications: requests}, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -138,6 +297,116 @@ func TestNotificationService_CreateBatch(t *testing.T) {
 	}
 }
 
+func TestNotificationService_FanOut(t *testing.T) {
+	svc, _, q := newService()
+	ctx := context.Background()
+
+	req := validReq
+	req.Recipient = ""
+	req.Recipients = []string{"+905551234567", " +905551234568 ", "+90 555 123 45 67"}
+
+	batch, duplicate, err := svc.FanOut(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duplicate {
+		t.Fatal("expected a new fan-out")
+	}
+	if batch.Total != 2 {
+		t.Fatalf("expected duplicate recipients to be dropped, got total=%d", batch.Total)
+	}
+
+	_, notifications, err := svc.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]bool{}
+	for _, n := range notifications {
+		got[n.Recipient] = true
+		if n.Content != req.Content || n.Channel != req.Channel {
+			t.Fatalf("expected members to share the message, got %+v", n)
+		}
+	}
+	if !got["+905551234567"] || !got["+905551234568"] {
+		t.Fatalf("unexpected recipients: %v", got)
+	}
+	if _, normal, _ := q.Depths(); normal != 2 {
+		t.Fatalf("expected both members to be queued, got %d", normal)
+	}
+}
+
+func TestNotificationService_FanOut_Rejected(t *testing.T) {
+	svc, _, _ := newService()
+
+	both := validReq
+	both.Recipients = []string{"+905551234568"}
+	if _, _, err := svc.FanOut(context.Background(), both, ""); !errors.Is(err, domain.ErrInvalidRecipients) {
+		t.Fatalf("expected ErrInvalidRecipients with recipient and recipients, got %v", err)
+	}
+
+	bad := validReq
+	bad.Recipient = ""
+	bad.Recipients = []string{"+905551234567", ""}
+	if _, _, err := svc.FanOut(context.Background(), bad, ""); !errors.Is(err, domain.ErrInvalidRecipient) {
+		t.Fatalf("expected an empty recipient to reject the fan-out, got %v", err)
+	}
+}
+
+func TestNotificationService_FanOut_Idempotent(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	req := validReq
+	req.Recipient = ""
+	req.Recipients = []string{"+905551234567", "+905551234568"}
+
+	first, _, err := svc.FanOut(ctx, req, "fan-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, duplicate, err := svc.FanOut(ctx, req, "fan-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !duplicate || second.ID != first.ID {
+		t.Fatalf("expected the replay to return batch %s, got %s (duplicate=%v)", first.ID, second.ID, duplicate)
+	}
+
+	other := domain.WithOwner(ctx, "someone-else")
+	if _, _, err := svc.FanOut(other, req, "fan-1"); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict for another owner's key, got %v", err)
+	}
+}
+
+func TestNotificationService_CreateBatch_Idempotent(t *testing.T) {
+	svc, _, q := newService()
+	ctx := context.Background()
+	req := domain.CreateBatchRequest{Notifications: []domain.CreateNotificationRequest{validReq, validReq}}
+
+	first, created, _, duplicate, err := svc.CreateBatch(ctx, req, "batch-1")
+	if err != nil || duplicate {
+		t.Fatalf("expected a new batch, got duplicate=%v err=%v", duplicate, err)
+	}
+	_, depth, _ := q.Depths()
+
+	second, members, _, duplicate, err := svc.CreateBatch(ctx, req, "batch-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !duplicate || second.ID != first.ID || len(members) != len(created) {
+		t.Fatalf("expected the replay to return batch %s with %d members, got %s with %d (duplicate=%v)",
+			first.ID, len(created), second.ID, len(members), duplicate)
+	}
+	if _, after, _ := q.Depths(); after != depth {
+		t.Fatalf("expected the replay to enqueue nothing, queue went from %d to %d", depth, after)
+	}
+
+	other := domain.WithOwner(ctx, "someone-else")
+	if _, _, _, _, err := svc.CreateBatch(other, req, "batch-1"); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict for another owner's key, got %v", err)
+	}
+}
+
 func TestNotificationService_CreateBatch_TooLarge(t *testing.T) {
 	svc, _, _ := newService()
 
@@ -146,7 +415,7 @@ func TestNotificationService_CreateBatch_TooLarge(t *testing.T) {
 		requests[i] = validReq
 	}
 
-	_, err := svc.CreateBatch(context.Background(), requests)
+	_, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{Notifications: requests}, "")
 	if err != domain.ErrBatchTooLarge {
 		t.Fatalf("expected ErrBatchTooLarge, got %v", err)
 	}
@@ -154,12 +423,185 @@ func TestNotificationService_CreateBatch_TooLarge(t *testing.T) {
 
 func TestNotificationService_CreateBatch_Empty(t *testing.T) {
 	svc, _, _ := newService()
-	_, err := svc.CreateBatch(context.Background(), nil)
+	_, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{}, "")
 	if err != domain.ErrBatchEmpty {
 		t.Fatalf("expected ErrBatchEmpty, got %v", err)
 	}
 }
 
+func TestNotificationService_CreateBatch_BatchLevelSchedule(t *testing.T) {
+	svc, _, q := newService()
+	ctx := context.Background()
+
+	campaign := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	own := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	requests := []domain.CreateNotificationRequest{validReq, validReq, validReq}
+	requests[1].ScheduledAt = &own
+
+	batch, _, _, _, err := svc.CreateBatch(ctx, domain.CreateBatchRequest{Notifications: requests, ScheduledAt: &campaign}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, members, err := svc.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ScheduledAt == nil || !got.ScheduledAt.Equal(campaign) {
+		t.Fatalf("expected the batch to report scheduled_at=%s, got %v", campaign, got.ScheduledAt)
+	}
+	inherited, kept := 0, 0
+	for _, n := range members {
+		if n.Status != domain.StatusScheduled || n.ScheduledAt == nil {
+			t.Fatalf("expected every member scheduled, got %s", n.Status)
+		}
+		switch {
+		case n.ScheduledAt.Equal(campaign):
+			inherited++
+		case n.ScheduledAt.Equal(own):
+			kept++
+		}
+	}
+	if inherited != 2 || kept != 1 {
+		t.Fatalf("expected 2 members on the batch schedule and 1 on its own, got %d/%d", inherited, kept)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatal("expected nothing enqueued for a scheduled batch")
+	}
+}
+
+func TestNotificationService_CreateBatch_InvalidBatchSchedule(t *testing.T) {
+	svc, _, _ := newService()
+
+	past := time.Now().Add(-time.Hour)
+	_, _, rejected, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq},
+		ScheduledAt:   &past,
+		AllowPartial:  true,
+	}, "")
+	if !errors.Is(err, domain.ErrScheduledInPast) {
+		t.Fatalf("expected ErrScheduledInPast, got %v", err)
+	}
+	if rejected != nil {
+		t.Fatalf("expected the batch to be rejected as a whole, got item errors %+v", rejected)
+	}
+}
+
+func TestNotificationService_CreateBatch_ItemScheduleReportsIndex(t *testing.T) {
+	svc, _, _ := newService()
+
+	requests := []domain.CreateNotificationRequest{validReq, validReq, validReq}
+	far := time.Now().Add(365 * 24 * time.Hour)
+	requests[2].ScheduledAt = &far
+	_, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{Notifications: requests}, "")
+	if !errors.Is(err, domain.ErrScheduleTooFar) {
+		t.Fatalf("expected ErrScheduleTooFar, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "item 2:") {
+		t.Fatalf("expected the offending index in the error, got %q", err)
+	}
+}
+
+// mixedBatch returns five items where indexes 1 and 3 are invalid.
+func mixedBatch() []domain.CreateNotificationRequest {
+	requests := make([]domain.CreateNotificationRequest, 5)
+	for i := range requests {
+		requests[i] = validReq
+	}
+	requests[1].Recipient = ""
+	requests[3].Channel = "fax"
+	return requests
+}
+
+func TestNotificationService_CreateBatch_AllOrNothingByDefault(t *testing.T) {
+	svc, repo, _ := newService()
+
+	_, _, rejected, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{Notifications: mixedBatch()}, "")
+	if !errors.Is(err, domain.ErrInvalidRecipient) {
+		t.Fatalf("expected ErrInvalidRecipient, got %v", err)
+	}
+	if rejected != nil {
+		t.Fatalf("expected no item errors outside partial mode, got %v", rejected)
+	}
+	if _, total, _ := repo.List(context.Background(), domain.ListFilter{Page: 1, Limit: 100}); total != 0 {
+		t.Fatalf("expected nothing persisted, got %d notifications", total)
+	}
+}
+
+func TestNotificationService_CreateBatch_AggregatesItemErrors(t *testing.T) {
+	svc, _, _ := newService()
+
+	_, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{Notifications: mixedBatch()}, "")
+	var ve *domain.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if len(ve.Fields) != 2 {
+		t.Fatalf("expected both invalid items reported, got %+v", ve.Fields)
+	}
+	want := []struct {
+		index int
+		field string
+	}{{1, "recipient"}, {3, "channel"}}
+	for i, w := range want {
+		f := ve.Fields[i]
+		if f.Index == nil || *f.Index != w.index || f.Field != w.field {
+			t.Fatalf("field %d: expected item %d %s, got %+v", i, w.index, w.field, f)
+		}
+	}
+	if !errors.Is(err, domain.ErrInvalidChannel) {
+		t.Fatal("expected the aggregate to match each wrapped sentinel")
+	}
+}
+
+func TestNotificationService_CreateBatch_AllowPartial(t *testing.T) {
+	svc, _, _ := newService()
+
+	batch, created, rejected, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{
+		Notifications: mixedBatch(),
+		AllowPartial:  true,
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if batch.Total != 3 {
+		t.Fatalf("expected total=3 accepted items, got %d", batch.Total)
+	}
+	if len(rejected) != 2 || rejected[0].Index != 1 || rejected[1].Index != 3 {
+		t.Fatalf("expected items 1 and 3 rejected, got %+v", rejected)
+	}
+	if rejected[0].Error != domain.ErrInvalidRecipient.Error() || rejected[1].Error != domain.ErrInvalidChannel.Error() {
+		t.Fatalf("unexpected item errors: %+v", rejected)
+	}
+	if len(created) != 3 || created[0].ID == "" || created[0].BatchID == nil || *created[0].BatchID != batch.ID {
+		t.Fatalf("expected the 3 created notifications returned, got %+v", created)
+	}
+
+	_, members, err := svc.GetBatch(context.Background(), batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expected 3 persisted notifications, got %d", len(members))
+	}
+}
+
+func TestNotificationService_CreateBatch_AllowPartial_AllRejected(t *testing.T) {
+	svc, _, _ := newService()
+
+	requests := mixedBatch()[1:2]
+	_, _, rejected, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{
+		Notifications: requests,
+		AllowPartial:  true,
+	}, "")
+	if !errors.Is(err, domain.ErrBatchAllRejected) {
+		t.Fatalf("expected ErrBatchAllRejected, got %v", err)
+	}
+	if len(rejected) != 1 || rejected[0].Index != 0 {
+		t.Fatalf("expected the single item reported, got %+v", rejected)
+	}
+}
+
 func TestNotificationService_GetByID(t *testing.T) {
 	svc, _, _ := newService()
 	ctx := context.Background()
@@ -182,3 +624,1480 @@ func TestNotificationService_GetByID_NotFound(t *testing.T) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestNotificationService_Create_QueueFullStaysPending(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.NewWithCapacity(0, 0, 0) // every Enqueue reports full
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Status != domain.StatusPending {
+		t.Fatalf("expected returned status=pending, got %s", n.Status)
+	}
+
+	stored, err := repo.GetByID(ctx, n.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Status != domain.StatusPending {
+		t.Fatalf("expected stored status=pending, got %s", stored.Status)
+	}
+}
+
+// fullQueue rejects every Enqueue while reporting the given tier depths.
+type fullQueue struct {
+	high, normal, low int
+}
+
+func (q *fullQueue) Enqueue(queue.Item) error              { return domain.ErrQueueFull }
+func (q *fullQueue) Reprioritize(queue.Item) (bool, error) { return false, domain.ErrQueueFull }
+func (q *fullQueue) Depths() (high, normal, low int)       { return q.high, q.normal, q.low }
+
+func TestNotificationService_Create_QueueFullIsDeferred(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := &fullQueue{high: 100, normal: 900, low: 5000}
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{DrainRate: 50})
+
+	n, res, err := svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Deferred || res.Duplicate {
+		t.Fatalf("expected a deferred, non-duplicate result, got %+v", res)
+	}
+	// 1000 high+normal items ahead at 50/s; the low tier does not count.
+	if res.RetryAfter != 20*time.Second {
+		t.Fatalf("expected Retry-After of 20s, got %s", res.RetryAfter)
+	}
+	if n.Status != domain.StatusPending {
+		t.Fatalf("expected status=pending, got %s", n.Status)
+	}
+}
+
+// cancelAfterRepo cancels a notification right after the named repository
+// call returns, as a DELETE racing the service would.
+type cancelAfterRepo struct {
+	*repository.MockNotificationRepository
+	after string
+}
+
+func (r *cancelAfterRepo) cancel(ctx context.Context, call, id string) {
+	if r.after == call {
+		_ = r.MockNotificationRepository.Cancel(ctx, id, domain.Cancellation{At: time.Now()})
+	}
+}
+
+func (r *cancelAfterRepo) Create(ctx context.Context, n *domain.Notification) error {
+	err := r.MockNotificationRepository.Create(ctx, n)
+	r.cancel(ctx, "Create", n.ID)
+	return err
+}
+
+func (r *cancelAfterRepo) ClaimPending(ctx context.Context, id string) error {
+	err := r.MockNotificationRepository.ClaimPending(ctx, id)
+	r.cancel(ctx, "ClaimPending", id)
+	return err
+}
+
+func (r *cancelAfterRepo) RequeueFailed(ctx context.Context, id string, resetCount bool) error {
+	err := r.MockNotificationRepository.RequeueFailed(ctx, id, resetCount)
+	r.cancel(ctx, "RequeueFailed", id)
+	return err
+}
+
+func (r *cancelAfterRepo) GetByIdempotencyKey(ctx context.Context, key string) (*domain.Notification, error) {
+	n, err := r.MockNotificationRepository.GetByIdempotencyKey(ctx, key)
+	if err == nil {
+		r.cancel(ctx, "GetByIdempotencyKey", n.ID)
+	}
+	return n, err
+}
+
+func (r *cancelAfterRepo) Submit(ctx context.Context, id string, status domain.Status) error {
+	err := r.MockNotificationRepository.Submit(ctx, id, status)
+	r.cancel(ctx, "Submit", id)
+	return err
+}
+
+func TestNotificationService_Create_CancelBeforeEnqueueStands(t *testing.T) {
+	repo := &cancelAfterRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), after: "Create"}
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+
+	n, res, err := svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Status != domain.StatusCancelled || res.Deferred {
+		t.Fatalf("expected the cancelled notification returned, not deferred, got %s and %+v", n.Status, res)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatalf("expected nothing enqueued, got %d/%d/%d", high, normal, low)
+	}
+	if stored, _ := repo.GetByID(context.Background(), n.ID); stored.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", stored.Status)
+	}
+}
+
+func TestNotificationService_Create_QueueFullRevertKeepsCancel(t *testing.T) {
+	repo := &cancelAfterRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), after: "ClaimPending"}
+	svc := service.NewNotificationService(repo, &fullQueue{}, zap.NewNop(), service.Options{})
+
+	n, _, err := svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored, _ := repo.GetByID(context.Background(), n.ID); stored.Status != domain.StatusCancelled {
+		t.Fatalf("expected the revert to pending to leave the cancel alone, got %s", stored.Status)
+	}
+}
+
+func TestNotificationService_Create_ReplayAfterCancelIsNotDispatched(t *testing.T) {
+	repo := &cancelAfterRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), after: "GetByIdempotencyKey"}
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+	// Deferred by a full queue earlier, so a replay would dispatch it.
+	key := "idem-1"
+	if err := repo.Create(ctx, &domain.Notification{ID: "n-1", Channel: domain.ChannelSMS, Recipient: validReq.Recipient,
+		Content: validReq.Content, Priority: domain.PriorityNormal, Status: domain.StatusPending, IdempotencyKey: &key}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cancel lands between the replay's lookup and its dispatch.
+	n, res, err := svc.Create(ctx, validReq, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Replayed || res.Deferred || n.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancelled notification replayed, got %s and %+v", n.Status, res)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatalf("expected nothing enqueued, got %d/%d/%d", high, normal, low)
+	}
+	if stored, _ := repo.GetByID(ctx, n.ID); stored.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", stored.Status)
+	}
+}
+
+func TestNotificationService_QueueFullIsReported(t *testing.T) {
+	type report struct {
+		priority domain.Priority
+		source   queue.Source
+	}
+	var got []report
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), &fullQueue{}, zap.NewNop(), service.Options{
+		OnQueueFull: func(p domain.Priority, src queue.Source) { got = append(got, report{p, src}) },
+	})
+	ctx := context.Background()
+
+	if _, _, err := svc.Create(ctx, validReq, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	high := validReq
+	high.Priority = domain.PriorityHigh
+	batch := domain.CreateBatchRequest{Notifications: []domain.CreateNotificationRequest{high, high}}
+	if _, _, _, _, err := svc.CreateBatch(ctx, batch, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []report{
+		{domain.PriorityNormal, queue.SourceAPI},
+		{domain.PriorityHigh, queue.SourceBatch},
+		{domain.PriorityHigh, queue.SourceBatch},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected reports %v, got %v", want, got)
+	}
+}
+
+func TestNotificationService_Create_RetryAfterIsBounded(t *testing.T) {
+	tests := []struct {
+		name  string
+		queue *fullQueue
+		want  time.Duration
+	}{
+		{"nearly empty", &fullQueue{normal: 1}, time.Second},
+		{"huge backlog", &fullQueue{normal: 1_000_000}, time.Minute},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := service.NewNotificationService(repository.NewMockNotificationRepository(), tc.queue, zap.NewNop(), service.Options{})
+			_, res, _ := svc.Create(context.Background(), validReq, "")
+			if res.RetryAfter != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, res.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestNotificationService_Create_StrictEnqueue(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, &fullQueue{}, zap.NewNop(), service.Options{StrictEnqueue: true})
+
+	n, res, err := svc.Create(context.Background(), validReq, "")
+	if !errors.Is(err, domain.ErrQueueFull) || n != nil {
+		t.Fatalf("expected ErrQueueFull and no notification, got %v / %v", err, n)
+	}
+	if res.RetryAfter <= 0 {
+		t.Fatal("expected a Retry-After estimate alongside ErrQueueFull")
+	}
+}
+
+func TestNotificationService_Create_IdempotentReplayRetriesQueue(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	ctx := context.Background()
+
+	deferred := service.NewNotificationService(repo, &fullQueue{}, zap.NewNop(), service.Options{})
+	first, res, err := deferred.Create(ctx, validReq, "replay-key")
+	if err != nil || !res.Deferred {
+		t.Fatalf("expected a deferred create, got %+v / %v", res, err)
+	}
+
+	// Same repository, queue with room again.
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	second, res, err := svc.Create(ctx, validReq, "replay-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Duplicate || res.Deferred || second.ID != first.ID {
+		t.Fatalf("expected the original notification back, now queued; got %+v", res)
+	}
+	if second.Status != domain.StatusQueued {
+		t.Fatalf("expected status=queued, got %s", second.Status)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 1 {
+		t.Fatal("expected the replay to enqueue the notification")
+	}
+}
+
+func TestNotificationService_GetByProviderMsgID_ReturnsNewest(t *testing.T) {
+	svc, repo, _ := newService()
+	ctx := context.Background()
+
+	older, _, _ := svc.Create(ctx, validReq, "")
+	time.Sleep(time.Millisecond) // distinct created_at
+	newer, _, _ := svc.Create(ctx, validReq, "")
+
+	now := time.Now().UTC()
+	_ = repo.MarkSent(ctx, older.ID, "prov-1", now)
+	_ = repo.MarkSent(ctx, newer.ID, "prov-1", now)
+
+	got, err := svc.GetByProviderMsgID(ctx, "prov-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != newer.ID {
+		t.Fatalf("expected newest notification %s, got %s", newer.ID, got.ID)
+	}
+}
+
+func TestNotificationService_GetByProviderMsgID_NotFound(t *testing.T) {
+	svc, _, _ := newService()
+	_, err := svc.GetByProviderMsgID(context.Background(), "unknown")
+	if err != domain.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNotificationService_Create_MaxRetries(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.MaxRetries != domain.DefaultMaxRetries {
+		t.Fatalf("expected default max_retries=%d, got %d", domain.DefaultMaxRetries, n.MaxRetries)
+	}
+
+	req := validReq
+	five := 5
+	req.MaxRetries = &five
+	n, _, err = svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.MaxRetries != 5 {
+		t.Fatalf("expected max_retries=5, got %d", n.MaxRetries)
+	}
+}
+
+func TestNotificationService_Create_ConfiguredScheduleHorizon(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{
+		Validation: domain.ValidationRules{MaxScheduleHorizon: time.Hour},
+	})
+
+	req := validReq
+	at := time.Now().Add(2 * time.Hour)
+	req.ScheduledAt = &at
+	if _, _, err := svc.Create(context.Background(), req, ""); !errors.Is(err, domain.ErrScheduleTooFar) {
+		t.Fatalf("expected ErrScheduleTooFar, got %v", err)
+	}
+}
+
+func newServiceWithTemplate(t *testing.T, body string) (*service.NotificationService, string) {
+	t.Helper()
+	templates := repository.NewMockTemplateRepository()
+	tmpl, err := service.NewTemplateService(templates).Create(context.Background(),
+		domain.TemplateRequest{Name: "otp", Body: body})
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{Templates: templates})
+	return svc, tmpl.ID
+}
+
+func TestNotificationService_Create_FromTemplate(t *testing.T) {
+	svc, templateID := newServiceWithTemplate(t, "Your code is {{.code}}")
+
+	req := validReq
+	req.Content = ""
+	req.TemplateID = &templateID
+	req.Variables = map[string]string{"code": "4242"}
+
+	n, _, err := svc.Create(context.Background(), req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Content != "Your code is 4242" {
+		t.Fatalf("unexpected rendered content: %q", n.Content)
+	}
+	if n.TemplateID == nil || *n.TemplateID != templateID {
+		t.Fatal("expected template_id to be recorded")
+	}
+}
+
+func TestNotificationService_Create_TemplateErrors(t *testing.T) {
+	svc, templateID := newServiceWithTemplate(t, "Your code is {{.code}}")
+	unknown := "no-such-template"
+
+	tests := []struct {
+		name        string
+		templateID  *string
+		content     string
+		vars        map[string]string
+		expectedErr error
+	}{
+		{"missing variable", &templateID, "", nil, domain.ErrMissingTemplateVariable},
+		{"unknown template", &unknown, "", map[string]string{"code": "1"}, domain.ErrUnknownTemplate},
+		{"content and template", &templateID, "hello", map[string]string{"code": "1"}, domain.ErrContentAndTemplate},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := validReq
+			req.Content = tc.content
+			req.TemplateID = tc.templateID
+			req.Variables = tc.vars
+			_, _, err := svc.Create(context.Background(), req, "")
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNotificationService_CreateBatch_FromTemplate(t *testing.T) {
+	svc, templateID := newServiceWithTemplate(t, "Hi {{.name}}")
+
+	items := []domain.CreateNotificationRequest{validReq, validReq, validReq}
+	for i, name := range []string{"Ada", "Grace", "Linus"} {
+		items[i].Content = ""
+		items[i].Variables = map[string]string{"name": name}
+	}
+
+	batch, _, _, _, err := svc.CreateBatch(context.Background(), domain.CreateBatchRequest{
+		Notifications: items,
+		TemplateID:    &templateID,
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, members, err := svc.GetBatch(context.Background(), batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, n := range members {
+		got[n.Content] = true
+	}
+	for _, want := range []string{"Hi Ada", "Hi Grace", "Hi Linus"} {
+		if !got[want] {
+			t.Fatalf("expected a member rendered as %q, got %v", want, got)
+		}
+	}
+
+	items[1].Variables = nil
+	_, _, _, _, err = svc.CreateBatch(context.Background(), domain.CreateBatchRequest{
+		Notifications: items,
+		TemplateID:    &templateID,
+	}, "")
+	if !errors.Is(err, domain.ErrMissingTemplateVariable) {
+		t.Fatalf("expected ErrMissingTemplateVariable, got %v", err)
+	}
+}
+
+func TestNotificationService_Update_Reschedule(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	req := validReq
+	future := time.Now().Add(time.Hour)
+	req.ScheduledAt = &future
+	n, _, err := svc.Create(ctx, req, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+	high := domain.PriorityHigh
+	content := "Moved"
+	updated, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{
+		ScheduledAt: &later, Priority: &high, Content: &content,
+	}, domain.UpdatePrecondition{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !updated.ScheduledAt.Equal(later) || updated.Priority != high || updated.Content != content {
+		t.Fatalf("edits not applied: %+v", updated)
+	}
+	if updated.IdempotencyKey == nil || *updated.IdempotencyKey != "key-1" {
+		t.Fatal("expected the idempotency key to be preserved")
+	}
+
+	stored, _ := svc.GetByID(ctx, n.ID)
+	if stored.Content != content || stored.Status != domain.StatusScheduled {
+		t.Fatalf("edits not persisted: %+v", stored)
+	}
+}
+
+func TestNotificationService_Update_PendingBecomesScheduled(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.NewWithCapacity(0, 0, 0), zap.NewNop(), service.Options{})
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != domain.StatusPending {
+		t.Fatalf("expected pending, got %s", n.Status)
+	}
+
+	at := time.Now().Add(time.Hour)
+	updated, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{ScheduledAt: &at}, domain.UpdatePrecondition{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Status != domain.StatusScheduled {
+		t.Fatalf("expected scheduled, got %s", updated.Status)
+	}
+}
+
+func TestNotificationService_Update_Rejections(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	queued, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := validReq
+	future := time.Now().Add(time.Hour)
+	req.ScheduledAt = &future
+	scheduled, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	content := "edit"
+	tests := []struct {
+		name        string
+		id          string
+		req         domain.UpdateNotificationRequest
+		expectedErr error
+	}{
+		{"queued is not editable", queued.ID, domain.UpdateNotificationRequest{Content: &content}, domain.ErrNotEditable},
+		{"past schedule", scheduled.ID, domain.UpdateNotificationRequest{ScheduledAt: &past}, domain.ErrScheduledInPast},
+		{"empty update", scheduled.ID, domain.UpdateNotificationRequest{}, domain.ErrEmptyUpdate},
+		{"unknown id", "nonexistent-id", domain.UpdateNotificationRequest{Content: &content}, domain.ErrNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.Update(ctx, tc.id, tc.req, domain.UpdatePrecondition{})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNotificationService_Update_EditableFieldsByStatus(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	content, high, retries := "edit", domain.PriorityHigh, 5
+	fields := map[string]domain.UpdateNotificationRequest{
+		"content":      {Content: &content},
+		"priority":     {Priority: &high},
+		"scheduled_at": {ScheduledAt: &future},
+		"max_retries":  {MaxRetries: &retries},
+	}
+	all := []string{"content", "priority", "scheduled_at", "max_retries"}
+	tests := []struct {
+		status   domain.Status
+		editable []string
+	}{
+		{domain.StatusDraft, all},
+		{domain.StatusPending, all},
+		{domain.StatusScheduled, all},
+		{domain.StatusFailed, []string{"priority", "max_retries"}},
+		{domain.StatusQueued, nil},
+		{domain.StatusProcessing, nil},
+		{domain.StatusSent, nil},
+		{domain.StatusCancelled, nil},
+		{domain.StatusExpired, nil},
+	}
+	for _, tc := range tests {
+		for field, req := range fields {
+			t.Run(string(tc.status)+"/"+field, func(t *testing.T) {
+				repo := repository.NewMockNotificationRepository()
+				svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+				ctx := context.Background()
+				n := domain.Notification{ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567",
+					Content: "Hello", Priority: domain.PriorityNormal, Status: tc.status, MaxRetries: 3}
+				if err := repo.Create(ctx, &n); err != nil {
+					t.Fatal(err)
+				}
+
+				_, err := svc.Update(ctx, n.ID, req, domain.UpdatePrecondition{})
+				if slices.Contains(tc.editable, field) {
+					if err != nil {
+						t.Fatalf("expected %s to be editable while %s, got %v", field, tc.status, err)
+					}
+				} else if !errors.Is(err, domain.ErrNotEditable) {
+					t.Fatalf("expected ErrNotEditable for %s while %s, got %v", field, tc.status, err)
+				}
+			})
+		}
+	}
+}
+
+func TestNotificationService_Update_MaxRetriesBelowUsed(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	ctx := context.Background()
+	n := domain.Notification{ID: "n-1", Status: domain.StatusFailed, RetryCount: 2, MaxRetries: 3}
+	if err := repo.Create(ctx, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	one, five := 1, 5
+	if _, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{MaxRetries: &one}, domain.UpdatePrecondition{}); !errors.Is(err, domain.ErrInvalidMaxRetries) {
+		t.Fatalf("expected ErrInvalidMaxRetries, got %v", err)
+	}
+	updated, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{MaxRetries: &five}, domain.UpdatePrecondition{})
+	if err != nil || updated.MaxRetries != 5 || updated.Status != domain.StatusFailed {
+		t.Fatalf("expected max_retries raised on the failed row, got %+v, %v", updated, err)
+	}
+}
+
+func TestNotificationService_Update_Preconditions(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 17, 9, 30, 15, 500_000_000, time.UTC)
+	stale := updatedAt.Add(-time.Millisecond)
+	sameSecond := updatedAt.Truncate(time.Second)
+	secondBefore := sameSecond.Add(-time.Second)
+	tests := []struct {
+		name        string
+		pre         domain.UpdatePrecondition
+		expectedErr error
+	}{
+		{"none", domain.UpdatePrecondition{}, nil},
+		{"matching version", domain.UpdatePrecondition{UpdatedAt: &updatedAt}, nil},
+		{"stale version", domain.UpdatePrecondition{UpdatedAt: &stale}, domain.ErrPreconditionFailed},
+		{"unmodified since the same second", domain.UpdatePrecondition{UnmodifiedSince: &sameSecond}, nil},
+		{"modified since", domain.UpdatePrecondition{UnmodifiedSince: &secondBefore}, domain.ErrPreconditionFailed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := repository.NewMockNotificationRepository()
+			svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+			ctx := context.Background()
+			n := domain.Notification{ID: "n-1", Channel: domain.ChannelSMS, Content: "Hello", Status: domain.StatusPending, UpdatedAt: updatedAt}
+			if err := repo.Create(ctx, &n); err != nil {
+				t.Fatal(err)
+			}
+
+			content := "edit"
+			_, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{Content: &content}, tc.pre)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+			if stored, _ := repo.GetByID(ctx, n.ID); (stored.Content == content) != (tc.expectedErr == nil) {
+				t.Fatalf("expected the edit applied only when the precondition holds, content %q", stored.Content)
+			}
+		})
+	}
+}
+
+func TestNotificationService_Create_NormalizesRecipient(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{
+		DedupWindow: time.Minute,
+		Validation:  domain.ValidationRules{DefaultCountryCode: "90"},
+	})
+	ctx := context.Background()
+
+	req := validReq
+	req.Recipient = "+90 555 123 45 67"
+	first, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Recipient != "+905551234567" {
+		t.Fatalf("expected the canonical number to be stored, got %q", first.Recipient)
+	}
+
+	// The national spelling of the same number hits the dedup window.
+	req.Recipient = "0555 123 45 67"
+	second, res, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Duplicate || second.ID != first.ID {
+		t.Fatalf("expected %s to be returned as a duplicate, got %s", first.ID, second.ID)
+	}
+
+	email := validReq
+	email.Channel, email.Recipient = domain.ChannelEmail, " Alice@Example.COM"
+	n, _, err := svc.Create(ctx, email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Recipient != "alice@example.com" {
+		t.Fatalf("expected a lowercased address, got %q", n.Recipient)
+	}
+}
+
+func TestNotificationService_Create_RecordsCorrelationID(t *testing.T) {
+	svc, repo, _ := newService()
+
+	n, _, err := svc.Create(domain.WithCorrelationID(context.Background(), "corr-1"), validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := repo.GetByID(context.Background(), n.ID)
+	if stored.CorrelationID == nil || *stored.CorrelationID != "corr-1" {
+		t.Fatalf("expected correlation ID corr-1 to be stored, got %v", stored.CorrelationID)
+	}
+
+	batch, _, _, _, err := svc.CreateBatch(domain.WithCorrelationID(context.Background(), "corr-2"),
+		domain.CreateBatchRequest{Notifications: []domain.CreateNotificationRequest{validReq}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, members, _ := repo.GetBatch(context.Background(), batch.ID)
+	if len(members) != 1 || members[0].CorrelationID == nil || *members[0].CorrelationID != "corr-2" {
+		t.Fatalf("expected the batch member to carry corr-2, got %+v", members)
+	}
+
+	// Outside an HTTP request there is nothing to record.
+	n, _, err = svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.CorrelationID != nil {
+		t.Fatalf("expected no correlation ID, got %q", *n.CorrelationID)
+	}
+}
+
+func TestNotificationService_Create_DedupWindow(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{DedupWindow: time.Minute})
+	ctx := context.Background()
+
+	first, _, err := svc.Create(ctx, validReq, "key-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh idempotency key does not bypass the window.
+	second, res, err := svc.Create(ctx, validReq, "key-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Duplicate || second.ID != first.ID {
+		t.Fatal("expected the earlier notification to be returned as a duplicate")
+	}
+
+	other := validReq
+	other.Content = "A different message"
+	if _, res, _ := svc.Create(ctx, other, ""); res.Duplicate {
+		t.Fatal("expected different content not to be treated as a duplicate")
+	}
+
+	override := validReq
+	override.AllowDuplicate = true
+	third, res, err := svc.Create(ctx, override, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Duplicate || third.ID == first.ID {
+		t.Fatal("expected allow_duplicate to create a new notification")
+	}
+}
+
+func TestNotificationService_Create_DryRunDoesNotDedupRealSend(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{DedupWindow: time.Minute})
+	ctx := context.Background()
+
+	dry := validReq
+	dry.DryRun = true
+	test, _, err := svc.Create(ctx, dry, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !test.DryRun {
+		t.Fatal("expected the notification to be stored as a dry run")
+	}
+	live, res, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Duplicate || live.ID == test.ID || live.DryRun {
+		t.Fatal("expected the real send not to be suppressed by the dry run")
+	}
+}
+
+func TestNotificationService_SandboxMakesEverythingDryRun(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{Sandbox: true})
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, _, _, _, err := svc.CreateBatch(ctx, domain.CreateBatchRequest{Notifications: []domain.CreateNotificationRequest{validReq}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, members, _ := repo.GetBatch(ctx, batch.ID)
+	if !n.DryRun || len(members) != 1 || !members[0].DryRun {
+		t.Fatalf("expected every notification to be a dry run, got %v and %+v", n.DryRun, members)
+	}
+
+	// Outside sandbox mode a batch-level dry_run covers every item.
+	svc = service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{})
+	batch, _, _, _, err = svc.CreateBatch(ctx, domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq}, DryRun: true,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, members, _ = repo.GetBatch(ctx, batch.ID)
+	if len(members) != 1 || !members[0].DryRun {
+		t.Fatalf("expected the batch member to be a dry run, got %+v", members)
+	}
+}
+
+func TestNotificationService_Create_DedupIgnoresCancelledAndDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{DedupWindow: time.Minute})
+	first, _, _ := svc.Create(ctx, validReq, "")
+	if err := svc.Cancel(ctx, first.ID, domain.CancelRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, res, _ := svc.Create(ctx, validReq, ""); res.Duplicate {
+		t.Fatal("expected a cancelled notification not to suppress a resend")
+	}
+
+	disabled, _, _ := newService()
+	_, _, _ = disabled.Create(ctx, validReq, "")
+	if _, res, _ := disabled.Create(ctx, validReq, ""); res.Duplicate {
+		t.Fatal("expected no dedup without a configured window")
+	}
+}
+
+func TestNotificationService_OwnerScoping(t *testing.T) {
+	svc, _, _ := newService()
+	alice := domain.WithOwner(context.Background(), "alice")
+	bob := domain.WithOwner(context.Background(), "bob")
+
+	n, _, err := svc.Create(alice, validReq, "alice-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.OwnerID == nil || *n.OwnerID != "alice" {
+		t.Fatalf("expected owner alice, got %v", n.OwnerID)
+	}
+	batch, _, _, _, err := svc.CreateBatch(alice, domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.GetByID(alice, n.ID); err != nil {
+		t.Fatalf("owner should see their notification: %v", err)
+	}
+	if _, err := svc.GetByID(bob, n.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another owner, got %v", err)
+	}
+	if err := svc.Cancel(bob, n.ID, domain.CancelRequest{}); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected cancel by another owner to be ErrNotFound, got %v", err)
+	}
+	if _, _, err := svc.GetBatch(bob, batch.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another owner's batch, got %v", err)
+	}
+	if _, _, err := svc.Create(bob, validReq, "alice-key"); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected another owner's idempotency key to conflict, got %v", err)
+	}
+
+	if _, total, _ := svc.List(bob, domain.ListFilter{Page: 1, Limit: 20}); total != 0 {
+		t.Fatalf("expected bob to list nothing, got %d", total)
+	}
+	if _, total, _ := svc.List(alice, domain.ListFilter{Page: 1, Limit: 20}); total != 2 {
+		t.Fatalf("expected alice to list her 2 notifications, got %d", total)
+	}
+
+	// Unauthenticated internal callers are not scoped.
+	if _, err := svc.GetByID(context.Background(), n.ID); err != nil {
+		t.Fatalf("expected unscoped access without an owner, got %v", err)
+	}
+}
+
+func TestNotificationService_Cancel_RecordsReason(t *testing.T) {
+	svc, repo, _ := newService()
+	ctx := domain.WithOwner(context.Background(), "ops")
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Cancel(ctx, n.ID, domain.CancelRequest{Reason: "  campaign withdrawn ", CorrelationID: "corr-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := repo.GetByID(ctx, n.ID)
+	if stored.CancelledReason == nil || *stored.CancelledReason != "campaign withdrawn" {
+		t.Fatalf("expected the trimmed reason to be stored, got %v", stored.CancelledReason)
+	}
+	if stored.CancelledBy == nil || *stored.CancelledBy != "ops" {
+		t.Fatalf("expected cancelled_by=ops, got %v", stored.CancelledBy)
+	}
+	if stored.CancelCorrelationID == nil || *stored.CancelCorrelationID != "corr-1" || stored.CancelledAt == nil {
+		t.Fatalf("expected correlation ID and cancelled_at to be stored, got %+v", stored)
+	}
+}
+
+func TestNotificationService_Cancel_ReasonTooLong(t *testing.T) {
+	svc, _, _ := newService()
+
+	n, _, err := svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := domain.CancelRequest{Reason: strings.Repeat("x", domain.MaxCancelReasonLength+1)}
+	if err := svc.Cancel(context.Background(), n.ID, req); !errors.Is(err, domain.ErrInvalidCancelReason) {
+		t.Fatalf("expected ErrInvalidCancelReason, got %v", err)
+	}
+}
+
+func TestNotificationService_Cancel_NotifiesTerminal(t *testing.T) {
+	var got []*domain.Notification
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{OnTerminal: func(n *domain.Notification) { got = append(got, n) }})
+	ctx := context.Background()
+
+	req := validReq
+	url := "https://client.example/hook"
+	req.CallbackURL = &url
+	n, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Cancel(ctx, n.ID, domain.CancelRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Status != domain.StatusCancelled || *got[0].CallbackURL != url {
+		t.Fatalf("expected one cancelled hand-off carrying the callback URL, got %+v", got)
+	}
+}
+
+func TestNotificationService_Cancel_RecountsBatch(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	batch, members, _, _, err := svc.CreateBatch(ctx, domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq, validReq},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Cancel(ctx, members[0].ID, domain.CancelRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, err := svc.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cancelled != 1 || got.Pending != 1 {
+		t.Fatalf("expected the cancel to be counted, got %+v", got)
+	}
+}
+
+func TestNotificationService_Cancel_TouchesBatch(t *testing.T) {
+	var touched []string
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{OnBatchChanged: func(id string) { touched = append(touched, id) }})
+	ctx := context.Background()
+
+	batch, members, _, _, err := svc.CreateBatch(ctx, domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{members[0].ID, single.ID} {
+		if err := svc.Cancel(ctx, id, domain.CancelRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(touched) != 1 || touched[0] != batch.ID {
+		t.Fatalf("expected only the batch member's cancel to touch its batch, got %v", touched)
+	}
+}
+
+func TestNotificationService_Create_TTLSetsExpiresAt(t *testing.T) {
+	svc, _, _ := newService()
+
+	req := validReq
+	ttl := 600
+	req.TTLSeconds = &ttl
+	before := time.Now()
+
+	n, _, err := svc.Create(context.Background(), req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.ExpiresAt == nil {
+		t.Fatal("expected expires_at to be set from ttl_seconds")
+	}
+	if d := n.ExpiresAt.Sub(before); d < 10*time.Minute || d > 10*time.Minute+5*time.Second {
+		t.Fatalf("expected expires_at ~10m from now, got %s", d)
+	}
+}
+
+func TestNotificationService_Create_StoresEmail(t *testing.T) {
+	svc, repo, _ := newService()
+
+	req := domain.CreateNotificationRequest{
+		Channel:   domain.ChannelEmail,
+		Recipient: "user@example.com",
+		Content:   "Your invoice is attached.",
+		Priority:  domain.PriorityNormal,
+		Email: &domain.Email{
+			Subject:     "Invoice",
+			HTMLBody:    "<p>Your invoice is attached.</p>",
+			Attachments: []domain.EmailAttachment{{Filename: "invoice.txt", Content: "aGk="}},
+		},
+	}
+	n, _, err := svc.Create(context.Background(), req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, _ := repo.GetByID(context.Background(), n.ID)
+	if stored.Email == nil || stored.Email.Subject != "Invoice" || len(stored.Email.Attachments) != 1 {
+		t.Fatalf("expected the email object to be stored, got %+v", stored.Email)
+	}
+}
+
+func TestNotificationService_Update_RescheduleBeyondExpiry(t *testing.T) {
+	svc, _, _ := newService()
+	ctx := context.Background()
+
+	req := validReq
+	at := time.Now().Add(time.Hour)
+	expires := time.Now().Add(2 * time.Hour)
+	req.ScheduledAt, req.ExpiresAt = &at, &expires
+	n, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	later := time.Now().Add(3 * time.Hour)
+	_, err = svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{ScheduledAt: &later}, domain.UpdatePrecondition{})
+	if !errors.Is(err, domain.ErrInvalidExpiry) {
+		t.Fatalf("expected ErrInvalidExpiry, got %v", err)
+	}
+}
+
+func TestNotificationService_ChangePriority_MovesQueuedItem(t *testing.T) {
+	svc, repo, q := newService()
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := svc.ChangePriority(ctx, n.ID, domain.PriorityHigh)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Priority != domain.PriorityHigh {
+		t.Fatalf("expected priority=high, got %s", updated.Priority)
+	}
+	if stored, _ := repo.GetByID(ctx, n.ID); stored.Priority != domain.PriorityHigh {
+		t.Fatalf("expected stored priority=high, got %s", stored.Priority)
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	item, ok := q.Dequeue(dctx)
+	if !ok || item.NotificationID != n.ID || item.Priority != domain.PriorityHigh {
+		t.Fatalf("expected the item to be served from the high tier, got %+v", item)
+	}
+	if extra, ok := q.Dequeue(dctx); ok {
+		t.Fatalf("expected the old normal entry to be tombstoned, got %+v", extra)
+	}
+}
+
+func TestNotificationService_ChangePriority_States(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		status      domain.Status
+		expectedErr error
+	}{
+		{"pending", domain.StatusPending, nil},
+		{"scheduled", domain.StatusScheduled, nil},
+		{"failed", domain.StatusFailed, nil},
+		{"processing", domain.StatusProcessing, domain.ErrPriorityLocked},
+		{"sent", domain.StatusSent, domain.ErrPriorityLocked},
+		{"cancelled", domain.StatusCancelled, domain.ErrPriorityLocked},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, repo, _ := newService()
+
+			n, _, _ := svc.Create(ctx, validReq, "")
+			_ = repo.UpdateStatus(ctx, n.ID, tc.status)
+
+			_, err := svc.ChangePriority(ctx, n.ID, domain.PriorityHigh)
+			if err != tc.expectedErr {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNotificationService_ChangePriority_Invalid(t *testing.T) {
+	svc, _, _ := newService()
+
+	n, _, _ := svc.Create(context.Background(), validReq, "")
+	if _, err := svc.ChangePriority(context.Background(), n.ID, "urgent"); err != domain.ErrInvalidPriority {
+		t.Fatalf("expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestNotificationService_Draft_NotQueuedUntilSubmitted(t *testing.T) {
+	svc, repo, q := newService()
+	ctx := context.Background()
+
+	req := validReq
+	req.Draft = true
+	n, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Status != domain.StatusDraft {
+		t.Fatalf("expected status=draft, got %s", n.Status)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatal("expected a draft not to be queued")
+	}
+
+	content := "Approved message"
+	if _, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{Content: &content}, domain.UpdatePrecondition{}); err != nil {
+		t.Fatalf("expected drafts to be editable, got %v", err)
+	}
+
+	submitted, res, err := svc.Submit(ctx, n.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if submitted.Status != domain.StatusQueued || res.Deferred {
+		t.Fatalf("expected the submitted draft to be queued, got %s (deferred=%v)", submitted.Status, res.Deferred)
+	}
+	if _, normal, _ := q.Depths(); normal != 1 {
+		t.Fatalf("expected one queued item, got %d", normal)
+	}
+	stored, _ := repo.GetByID(ctx, n.ID)
+	if stored.Content != content {
+		t.Fatalf("expected the edited content to be sent, got %q", stored.Content)
+	}
+
+	if _, _, err := svc.Submit(ctx, n.ID); !errors.Is(err, domain.ErrNotDraft) {
+		t.Fatalf("expected ErrNotDraft on a second submit, got %v", err)
+	}
+}
+
+func TestNotificationService_Draft_ScheduledSubmit(t *testing.T) {
+	svc, _, q := newService()
+	ctx := context.Background()
+
+	req := validReq
+	req.Draft = true
+	n, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	at := time.Now().Add(time.Hour)
+	updated, err := svc.Update(ctx, n.ID, domain.UpdateNotificationRequest{ScheduledAt: &at}, domain.UpdatePrecondition{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Status != domain.StatusDraft {
+		t.Fatalf("expected a rescheduled draft to stay draft, got %s", updated.Status)
+	}
+
+	submitted, _, err := svc.Submit(ctx, n.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if submitted.Status != domain.StatusScheduled {
+		t.Fatalf("expected status=scheduled, got %s", submitted.Status)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatal("expected a scheduled submit to be left to the scheduler")
+	}
+}
+
+func TestNotificationService_Draft_SkipsDedupWindow(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	req := validReq
+	req.Draft = true
+	draft, _, err := svc.Create(ctx, req, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	n, res, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Duplicate || n.ID == draft.ID {
+		t.Fatal("expected a draft not to suppress a real send")
+	}
+}
+
+func TestNotificationService_Submit_NotDraft(t *testing.T) {
+	svc, _, _ := newService()
+
+	n, _, err := svc.Create(context.Background(), validReq, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.Submit(context.Background(), n.ID); !errors.Is(err, domain.ErrNotDraft) {
+		t.Fatalf("expected ErrNotDraft, got %v", err)
+	}
+}
+
+func TestNotificationService_Submit_CancelBeforeEnqueueStands(t *testing.T) {
+	repo := &cancelAfterRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), after: "Submit"}
+	q := queue.New()
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+	draft := validReq
+	draft.Draft = true
+	d, _, err := svc.Create(ctx, draft, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cancel lands between the draft's release and its enqueue.
+	n, res, err := svc.Submit(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.Status != domain.StatusCancelled || res.Deferred {
+		t.Fatalf("expected the cancelled notification returned, not deferred, got %s and %+v", n.Status, res)
+	}
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatalf("expected nothing enqueued, got %d/%d/%d", high, normal, low)
+	}
+	if stored, _ := repo.GetByID(ctx, d.ID); stored.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", stored.Status)
+	}
+}
+
+type recordingPublisher struct{ types []events.Type }
+
+func (p *recordingPublisher) Publish(_ context.Context, e events.Event) error {
+	p.types = append(p.types, e.Type)
+	return nil
+}
+
+func TestNotificationService_PublishesLifecycleEvents(t *testing.T) {
+	pub := &recordingPublisher{}
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{Events: pub})
+	ctx := context.Background()
+
+	n, _, err := svc.Create(ctx, validReq, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Cancel(ctx, n.ID, domain.CancelRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []events.Type{events.TypeCreated, events.TypeQueued, events.TypeCancelled}
+	if len(pub.types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, pub.types)
+	}
+	for i := range want {
+		if pub.types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, pub.types)
+		}
+	}
+}
+
+// countingLimiter allows up to budget notifications in total.
+type countingLimiter struct {
+	budget int
+	owners []string
+}
+
+func (l *countingLimiter) Allow(owner string, n int) (time.Duration, bool) {
+	l.owners = append(l.owners, owner)
+	if n > l.budget {
+		return 30 * time.Second, false
+	}
+	l.budget -= n
+	return 0, true
+}
+
+func TestNotificationService_CreationLimiter(t *testing.T) {
+	limiter := &countingLimiter{budget: 3}
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{CreationLimiter: limiter})
+	ctx := domain.WithOwner(context.Background(), "tenant-a")
+
+	if _, _, err := svc.Create(ctx, validReq, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The batch is charged its size, which exceeds the remaining budget of 2.
+	_, _, _, _, err := svc.CreateBatch(ctx, domain.CreateBatchRequest{
+		Notifications: []domain.CreateNotificationRequest{validReq, validReq, validReq},
+	}, "")
+	var rl *domain.RateLimitError
+	if !errors.Is(err, domain.ErrRateLimited) || !errors.As(err, &rl) || rl.RetryAfter != 30*time.Second {
+		t.Fatalf("expected RateLimitError with RetryAfter=30s, got %v", err)
+	}
+	if _, total, _ := svc.List(ctx, domain.ListFilter{Page: 1, Limit: 10}); total != 1 {
+		t.Fatalf("expected only the first notification to be persisted, got %d", total)
+	}
+	if limiter.owners[0] != "tenant-a" {
+		t.Fatalf("expected the limiter to be keyed by owner, got %q", limiter.owners[0])
+	}
+}
+
+func TestNotificationService_CreationLimiterSkipsReplays(t *testing.T) {
+	limiter := &countingLimiter{budget: 1}
+	svc := service.NewNotificationService(repository.NewMockNotificationRepository(), queue.New(), zap.NewNop(),
+		service.Options{CreationLimiter: limiter})
+	ctx := domain.WithOwner(context.Background(), "tenant-a")
+
+	first, _, err := svc.Create(ctx, validReq, "key-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The budget is spent, yet replaying the key returns the existing
+	// notification without being charged.
+	replayed, res, err := svc.Create(ctx, validReq, "key-1")
+	if err != nil || !res.Replayed || replayed.ID != first.ID {
+		t.Fatalf("expected the replay to return %s, got %v, %+v, %v", first.ID, replayed, res, err)
+	}
+	if len(limiter.owners) != 1 {
+		t.Fatalf("expected only the first create charged, got %d charges", len(limiter.owners))
+	}
+	if _, _, err := svc.Create(ctx, validReq, "key-2"); !errors.Is(err, domain.ErrRateLimited) {
+		t.Fatalf("expected a new key to be throttled, got %v", err)
+	}
+}
+
+func TestNotificationService_RequeuePending(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.NewWithCapacity(10, 10, 1) // one low-priority slot
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+
+	old := time.Now().UTC().Add(-time.Hour)
+	for i, n := range []domain.Notification{
+		{ID: "high", Priority: domain.PriorityHigh, Channel: domain.ChannelSMS, Status: domain.StatusPending},
+		{ID: "low-1", Priority: domain.PriorityLow, Channel: domain.ChannelSMS, Status: domain.StatusPending},
+		{ID: "low-2", Priority: domain.PriorityLow, Channel: domain.ChannelSMS, Status: domain.StatusPending},
+		{ID: "low-3", Priority: domain.PriorityLow, Channel: domain.ChannelSMS, Status: domain.StatusPending},
+		{ID: "email", Priority: domain.PriorityHigh, Channel: domain.ChannelEmail, Status: domain.StatusPending},
+		{ID: "queued", Priority: domain.PriorityHigh, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+	} {
+		n.CreatedAt = old.Add(time.Duration(i) * time.Second)
+		n.UpdatedAt = n.CreatedAt
+		if err := repo.Create(ctx, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fresh := domain.Notification{ID: "fresh", Priority: domain.PriorityHigh, Channel: domain.ChannelSMS,
+		Status: domain.StatusPending, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
+	if err := repo.Create(ctx, &fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	sms := domain.ChannelSMS
+	res, err := svc.RequeuePending(ctx, time.Minute, &sms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != (service.RequeueResult{Found: 4, Requeued: 2, Skipped: 2}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	want := map[string]domain.Status{
+		"high": domain.StatusQueued, "low-1": domain.StatusQueued,
+		"low-2": domain.StatusPending, "low-3": domain.StatusPending,
+		"email": domain.StatusPending, "fresh": domain.StatusPending,
+	}
+	for id, status := range want {
+		if n, _ := repo.GetByID(ctx, id); n.Status != status {
+			t.Errorf("%s: expected %s, got %s", id, status, n.Status)
+		}
+	}
+	if high, _, low := q.Depths(); high != 1 || low != 1 {
+		t.Fatalf("expected one high and one low item queued, got %d and %d", high, low)
+	}
+}
+
+func TestNotificationService_RequeueDeadLetters(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.NewWithCapacity(10, 10, 1) // one low-priority slot
+	svc := service.NewNotificationService(repo, q, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+
+	for _, id := range []string{"dead-1", "dead-2", "retrying", "sent"} {
+		n := domain.Notification{ID: id, Priority: domain.PriorityLow, Channel: domain.ChannelSMS,
+			Status: domain.StatusQueued, MaxRetries: 3}
+		if err := repo.Create(ctx, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = repo.ScheduleRetry(ctx, "dead-1", 1, time.Now().Add(time.Second), "timeout")
+	_ = repo.MarkFailed(ctx, "dead-1", "timeout")
+	_ = repo.MarkFailed(ctx, "dead-2", "rejected")
+	_ = repo.ScheduleRetry(ctx, "retrying", 1, time.Now().Add(time.Minute), "timeout")
+	_ = repo.MarkSent(ctx, "sent", "msg-1", time.Now())
+
+	dl, err := svc.GetDeadLetter(ctx, "dead-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dl.AttemptCount != 2 || len(dl.Attempts) != 2 || dl.Attempts[1].Attempt != 2 {
+		t.Fatalf("expected two recorded attempts, got %+v", dl.Attempts)
+	}
+
+	res, err := svc.RequeueDeadLetters(ctx, []string{"dead-1", "dead-2", "retrying", "sent", "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Requeued, []string{"dead-1"}) ||
+		!slices.Equal(res.Deferred, []string{"dead-2"}) ||
+		!slices.Equal(res.NotFound, []string{"retrying", "sent", "missing"}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if n, _ := repo.GetByID(ctx, "dead-1"); n.Status != domain.StatusQueued || n.RetryCount != 0 {
+		t.Fatalf("expected dead-1 queued with its retry count reset, got %s/%d", n.Status, n.RetryCount)
+	}
+	if n, _ := repo.GetByID(ctx, "dead-2"); n.Status != domain.StatusFailed {
+		t.Fatalf("expected dead-2 left failed, got %s", n.Status)
+	}
+}
+
+func TestNotificationService_RetryNow_QueueFullRevertKeepsCancel(t *testing.T) {
+	repo := &cancelAfterRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), after: "RequeueFailed"}
+	svc := service.NewNotificationService(repo, &fullQueue{}, zap.NewNop(), service.Options{})
+	ctx := context.Background()
+	n := domain.Notification{ID: "n-1", Priority: domain.PriorityNormal, Channel: domain.ChannelSMS,
+		Status: domain.StatusQueued, MaxRetries: 3}
+	if err := repo.Create(ctx, &n); err != nil {
+		t.Fatal(err)
+	}
+	_ = repo.MarkFailed(ctx, n.ID, "timeout")
+
+	if _, err := svc.RetryNow(ctx, n.ID, false); !errors.Is(err, domain.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if stored, _ := repo.GetByID(ctx, n.ID); stored.Status != domain.StatusCancelled {
+		t.Fatalf("expected the revert to failed to leave the cancel alone, got %s", stored.Status)
+	}
+}
+
+func TestNotificationService_Lookup(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	svc := service.NewNotificationService(repo, queue.New(), zap.NewNop(), service.Options{MaxLookupIDs: 5})
+	ctx := domain.WithOwner(context.Background(), "alice")
+
+	alice, bob := "alice", "bob"
+	for _, n := range []domain.Notification{
+		{ID: "a1", OwnerID: &alice}, {ID: "a2", OwnerID: &alice}, {ID: "b1", OwnerID: &bob},
+	} {
+		if err := repo.Create(ctx, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := svc.Lookup(ctx, []string{"a2", "missing", "b1", "a1", "a2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for _, n := range res.Notifications {
+		ids = append(ids, n.ID)
+	}
+	if !slices.Equal(ids, []string{"a2", "a1"}) || !slices.Equal(res.Missing, []string{"missing", "b1"}) {
+		t.Fatalf("expected [a2 a1] found and [missing b1] missing, got %v and %v", ids, res.Missing)
+	}
+
+	for _, ids := range [][]string{nil, {"1", "2", "3", "4", "5", "6"}} {
+		if _, err := svc.Lookup(ctx, ids); !errors.Is(err, domain.ErrInvalidLookup) {
+			t.Fatalf("%d ids: expected ErrInvalidLookup, got %v", len(ids), err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// TemplateService manages named content templates.
+// Rendering happens in NotificationService at create time.
+type TemplateService struct {
+	repo repository.TemplateRepository
+}
+
+func NewTemplateService(repo repository.TemplateRepository) *TemplateService {
+	return &TemplateService{repo: repo}
+}
+
+// Create validates the template (including that its body parses) and stores it.
+func (s *TemplateService) Create(ctx context.Context, req domain.TemplateRequest) (*domain.Template, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	t := &domain.Template{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Body:      req.Body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TemplateService) GetByID(ctx context.Context, id string) (*domain.Template, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *TemplateService) List(ctx context.Context) ([]*domain.Template, error) {
+	return s.repo.List(ctx)
+}
+
+// Update replaces a template's name and body. Notifications already rendered
+// from the previous body are unaffected.
+func (s *TemplateService) Update(ctx context.Context, id string, req domain.TemplateRequest) (*domain.Template, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	t, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Name, t.Body = req.Name, req.Body
+	if err := s.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TemplateService) Delete(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,114 @@
+// Package tracing wires OpenTelemetry into the service. Until Setup is given
+// an exporter endpoint the global tracer provider stays the no-op default, so
+// every span started through Tracer costs next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ricirt/event-driven-arch"
+
+// propagator carries trace context across HTTP requests and the queue. It is
+// fixed to W3C Trace Context rather than read from the global so that queue
+// items round-trip the same way whether or not Setup ran.
+var propagator = propagation.TraceContext{}
+
+// Config selects where spans go.
+type Config struct {
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318.
+	// Empty keeps tracing disabled.
+	Endpoint string
+	// SampleRatio is the fraction of new traces recorded, in [0, 1]. Traces
+	// continued from an incoming request follow the caller's decision.
+	SampleRatio float64
+	ServiceName string
+}
+
+// Setup installs the global tracer provider and propagator. The returned
+// func flushes buffered spans and must be called on shutdown.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the service's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject returns the W3C traceparent of the span in ctx, or "" when there is
+// none, for carrying the trace across the queue.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Extract returns ctx continuing the trace described by traceparent; an empty
+// or malformed value leaves ctx unchanged.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// InjectHTTP writes the trace context of ctx into outgoing request headers.
+func InjectHTTP(ctx context.Context, h propagation.HeaderCarrier) {
+	propagator.Inject(ctx, h)
+}
+
+// ExtractHTTP returns ctx continuing the trace of an incoming request.
+func ExtractHTTP(ctx context.Context, h propagation.HeaderCarrier) context.Context {
+	return propagator.Extract(ctx, h)
+}
+
+// StartEnqueue starts the producer span around putting a notification on the
+// queue. It returns the span and the traceparent to store on the queue item,
+// which parents the worker's span to this one.
+func StartEnqueue(ctx context.Context, notificationID string) (trace.Span, string) {
+	ctx, span := Tracer().Start(ctx, "queue.enqueue",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("notification.id", notificationID)),
+	)
+	return span, Inject(ctx)
+}
+
+// RecordError marks span as failed with err; a nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
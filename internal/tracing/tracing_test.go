@@ -0,0 +1,138 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ricirt/event-driven-arch/internal/tracing"
+)
+
+// record installs a provider that keeps every ended span for the test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSetup_WithoutEndpointIsNoop(t *testing.T) {
+	before := otel.GetTracerProvider()
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Fatal("expected the global tracer provider to be left alone")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestStartEnqueue_TraceParentParentsConsumer(t *testing.T) {
+	rec := record(t)
+	ctx, request := tracing.Tracer().Start(context.Background(), "request")
+
+	enqueue, traceParent := tracing.StartEnqueue(ctx, "n-1")
+	tracing.End(enqueue, nil)
+	request.End()
+	if traceParent == "" {
+		t.Fatal("expected a traceparent for the queue item")
+	}
+
+	// The worker side only has the string the queue carried.
+	_, consumer := tracing.Tracer().Start(tracing.Extract(context.Background(), traceParent), "worker.process")
+	consumer.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	enq, work := spans[0], spans[2]
+	if enq.Name() != "queue.enqueue" || enq.SpanKind() != trace.SpanKindProducer {
+		t.Fatalf("unexpected enqueue span %q (%s)", enq.Name(), enq.SpanKind())
+	}
+	if enq.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Fatal("expected the enqueue span to be a child of the request span")
+	}
+	if work.Parent().SpanID() != enq.SpanContext().SpanID() || work.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Fatal("expected the consumer span to continue the trace under the enqueue span")
+	}
+}
+
+func TestExtract_EmptyOrMalformedLeavesContext(t *testing.T) {
+	for _, tp := range []string{"", "not-a-traceparent"} {
+		ctx := tracing.Extract(context.Background(), tp)
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			t.Errorf("traceparent %q: expected no span context", tp)
+		}
+	}
+}
+
+func TestInjectHTTP_RoundTrip(t *testing.T) {
+	record(t)
+	ctx, span := tracing.Tracer().Start(context.Background(), "client")
+	defer span.End()
+
+	h := http.Header{}
+	tracing.InjectHTTP(ctx, propagation.HeaderCarrier(h))
+	if h.Get("Traceparent") == "" {
+		t.Fatal("expected a traceparent header")
+	}
+
+	got := trace.SpanContextFromContext(tracing.ExtractHTTP(context.Background(), propagation.HeaderCarrier(h)))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("expected the extracted context to match the injected span")
+	}
+}
+
+func TestPgxTracer_SpanPerStatement(t *testing.T) {
+	rec := record(t)
+	var tr tracing.PgxTracer
+
+	ctx := tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL: "\n\tupdate notifications SET status = $1 WHERE id = $2",
+	})
+	tr.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 1")})
+
+	ctx = tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tr.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "postgres UPDATE" {
+		t.Errorf("expected span named after the operation, got %q", spans[0].Name())
+	}
+	if v, ok := attr(spans[0], "db.rows_affected"); !ok || v.AsInt64() != 1 {
+		t.Errorf("expected db.rows_affected 1, got %v", v)
+	}
+	if spans[1].Name() != "postgres SELECT" || spans[1].Status().Code != codes.Error {
+		t.Errorf("expected failed SELECT span, got %q %v", spans[1].Name(), spans[1].Status())
+	}
+}
@@ -0,0 +1,53 @@
+// Package version reports which build of the service is running. The values
+// are stamped at link time, e.g.
+//
+//	go build -ldflags "-X github.com/ricirt/event-driven-arch/internal/version.Version=v1.4.0 \
+//	  -X github.com/ricirt/event-driven-arch/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/ricirt/event-driven-arch/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// The Makefile and Dockerfile do this; a plain go build leaves the defaults.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set via -ldflags -X.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the stamped build info. When Commit or BuildTime were not
+// stamped it falls back to the VCS details go build embeds, then to
+// "unknown".
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,26 @@
+package version_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/ricirt/event-driven-arch/internal/version"
+)
+
+func TestGet_ReportsStampedValues(t *testing.T) {
+	defer func(v, c, b string) { version.Version, version.Commit, version.BuildTime = v, c, b }(
+		version.Version, version.Commit, version.BuildTime)
+	version.Version, version.Commit, version.BuildTime = "v1.2.3", "abc123", "2026-10-17T09:30:00Z"
+
+	want := version.Info{Version: "v1.2.3", Commit: "abc123", BuildTime: "2026-10-17T09:30:00Z", GoVersion: runtime.Version()}
+	if got := version.Get(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestGet_UnstampedBuildIsNeverBlank(t *testing.T) {
+	info := version.Get()
+	if info.Version != "dev" || info.Commit == "" || info.BuildTime == "" {
+		t.Fatalf("expected dev with a commit and build time filled in, got %+v", info)
+	}
+}
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// batchCountTimeout bounds each UpdateBatchCounts call made by a flush.
+const batchCountTimeout = 5 * time.Second
+
+// BatchCounter debounces batch counter updates. Workers Touch a batch whenever
+// one of its notifications settles; every interval the counters of each
+// touched batch are recomputed once, however many of its notifications
+// settled in between, and the fresh batch is handed to onUpdate.
+//
+// The first flush that finds nothing pending marks the batch completed and
+// hands it to onComplete. The mark is a conditional update, so with several
+// replicas flushing the same batch only one of them reports the completion.
+type BatchCounter struct {
+	repo       repository.NotificationRepository
+	interval   time.Duration
+	grace      time.Duration
+	onUpdate   func(b *domain.Batch)
+	onComplete func(b *domain.Batch)
+	logger     *zap.Logger
+
+	mu    sync.Mutex
+	dirty map[string]struct{}
+
+	// flushing makes Flush the single updater of this process: a Flush
+	// called while Run's is in progress waits instead of recounting the
+	// same batches alongside it.
+	flushing sync.Mutex
+}
+
+// NewBatchCounter returns a counter flushing every interval (default 500ms).
+// At shutdown the last flush gets up to grace (default 5s) to finish.
+// onUpdate and onComplete, when set, must not block; main wires them to
+// progress.Hub.Publish and the batch lifecycle metrics.
+func NewBatchCounter(
+	repo repository.NotificationRepository,
+	interval time.Duration,
+	grace time.Duration,
+	onUpdate func(b *domain.Batch),
+	onComplete func(b *domain.Batch),
+	logger *zap.Logger,
+) *BatchCounter {
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	if grace <= 0 {
+		grace = batchCountTimeout
+	}
+	if onUpdate == nil {
+		onUpdate = func(*domain.Batch) {}
+	}
+	if onComplete == nil {
+		onComplete = func(*domain.Batch) {}
+	}
+	return &BatchCounter{
+		repo:       repo,
+		interval:   interval,
+		grace:      grace,
+		onUpdate:   onUpdate,
+		onComplete: onComplete,
+		logger:     logger,
+		dirty:      make(map[string]struct{}),
+	}
+}
+
+// Touch marks batchID for the next flush. It never blocks on the database.
+func (c *BatchCounter) Touch(batchID string) {
+	c.mu.Lock()
+	c.dirty[batchID] = struct{}{}
+	c.mu.Unlock()
+}
+
+// Run flushes touched batches every interval until ctx is cancelled, then
+// flushes once more, within the grace period, so the last settled
+// notifications are counted. Batches whose update was cut short by the
+// cancellation are among them. Run returns only when that flush is done, so
+// the database pool may be closed after it.
+func (c *BatchCounter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.grace)
+			c.Flush(drainCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			c.Flush(ctx)
+		}
+	}
+}
+
+// Flush recomputes the counters of every touched batch. A batch whose update
+// fails stays touched for the next flush.
+func (c *BatchCounter) Flush(ctx context.Context) {
+	c.flushing.Lock()
+	defer c.flushing.Unlock()
+
+	c.mu.Lock()
+	ids := c.dirty
+	c.dirty = make(map[string]struct{})
+	c.mu.Unlock()
+
+	for id := range ids {
+		updateCtx, cancel := context.WithTimeout(ctx, batchCountTimeout)
+		b, err := c.repo.UpdateBatchCounts(updateCtx, id)
+		if err != nil {
+			cancel()
+			if !errors.Is(err, domain.ErrNotFound) {
+				c.Touch(id)
+			}
+			if ctx.Err() == nil {
+				c.logger.Warn("failed to update batch counts", zap.String("batch_id", id), zap.Error(err))
+			}
+			continue
+		}
+		if b.Pending == 0 && b.CompletedAt == nil {
+			c.complete(updateCtx, b)
+		}
+		cancel()
+		c.onUpdate(b)
+	}
+}
+
+// complete marks b completed and reports it, unless another flush got there
+// first.
+func (c *BatchCounter) complete(ctx context.Context, b *domain.Batch) {
+	now := time.Now().UTC()
+	if err := c.repo.MarkBatchCompleted(ctx, b.ID, now); err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			c.logger.Warn("failed to mark batch completed", zap.String("batch_id", b.ID), zap.Error(err))
+		}
+		return
+	}
+	b.CompletedAt = &now
+	c.onComplete(b)
+}
@@ -0,0 +1,314 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+const (
+	// callbackLease is how long a claimed callback is hidden from other pollers
+	// while it is being delivered; it must exceed the HTTP timeout.
+	callbackLease = time.Minute
+	// maxCallbackBackoff caps the exponential retry delay.
+	maxCallbackBackoff = time.Hour
+	// maxCallbackErrorLen truncates error messages copied into payloads.
+	maxCallbackErrorLen = 1024
+	// maxCallbackResponseBytes is how much of a response body is read and discarded.
+	maxCallbackResponseBytes = 64 << 10
+	// callbackClaimLimit is the number of due callbacks claimed per poll.
+	callbackClaimLimit = 100
+)
+
+// CallbackOptions configures the CallbackDispatcher. Zero values fall back to
+// the defaults noted on each field.
+type CallbackOptions struct {
+	// Secret signs every payload with HMAC-SHA256. Empty sends unsigned callbacks.
+	Secret string
+	// Timeout bounds each HTTP attempt (default 5s, at most callbackLease/2).
+	Timeout time.Duration
+	// MaxAttempts is the total number of delivery attempts (default 8).
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt; it doubles per
+	// attempt up to one hour (default 5s).
+	Backoff time.Duration
+	// PollInterval is how often persisted callbacks are checked (default 5s).
+	PollInterval time.Duration
+	// Concurrency caps in-flight deliveries (default 4).
+	Concurrency int
+	// BufferSize is the capacity of the hand-off channel (default 1000).
+	BufferSize int
+}
+
+func (o CallbackOptions) withDefaults() CallbackOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Timeout > callbackLease/2 {
+		o.Timeout = callbackLease / 2
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 8
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 5 * time.Second
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 5 * time.Second
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 4
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = 1000
+	}
+	return o
+}
+
+// CallbackDispatcher posts signed status webhooks to notifications'
+// callback_url once they reach a terminal status.
+//
+// Workers and the service hand notifications over through Notify, which only
+// does a non-blocking channel send; HTTP never runs on the delivery path.
+// Every callback is persisted before its first attempt and retried with
+// exponential backoff from the callbacks table, so deliveries survive restarts.
+// Delivery is at-least-once.
+type CallbackDispatcher struct {
+	repo   repository.CallbackRepository
+	client *http.Client
+	opts   CallbackOptions
+	events chan *domain.Callback
+	logger *zap.Logger
+}
+
+func NewCallbackDispatcher(repo repository.CallbackRepository, opts CallbackOptions, logger *zap.Logger) *CallbackDispatcher {
+	opts = opts.withDefaults()
+	return &CallbackDispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// Redirects are not followed: the validated callback_url is the only
+			// destination we agreed to call.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		opts:   opts,
+		events: make(chan *domain.Callback, opts.BufferSize),
+		logger: logger,
+	}
+}
+
+// Notify queues a status webhook for n if it has a callback_url and is in a
+// terminal status. It never blocks: when the buffer is full the callback is
+// persisted directly and left to the poller.
+func (d *CallbackDispatcher) Notify(n *domain.Notification) {
+	if n.CallbackURL == nil || !n.Status.IsTerminal() {
+		return
+	}
+	cb, err := newCallback(n)
+	if err != nil {
+		d.logger.Error("failed to build callback", zap.String("notification_id", n.ID), zap.Error(err))
+		return
+	}
+
+	select {
+	case d.events <- cb:
+	default:
+		d.persist(cb)
+	}
+}
+
+// Run delivers handed-off callbacks and polls for due retries until ctx is
+// cancelled, then waits for in-flight deliveries and persists anything still
+// buffered.
+func (d *CallbackDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	sem := make(chan struct{}, d.opts.Concurrency)
+	var wg sync.WaitGroup
+	deliver := func(cb *domain.Callback) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			d.attempt(ctx, cb)
+		}()
+	}
+
+	d.logger.Info("callback dispatcher started", zap.Duration("interval", d.opts.PollInterval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			d.drain()
+			d.logger.Info("callback dispatcher stopping")
+			return
+
+		case cb := <-d.events:
+			// Persist as already claimed so the poller leaves it to us.
+			cb.NextAttemptAt = time.Now().UTC().Add(callbackLease)
+			if err := d.repo.Create(ctx, cb); err != nil {
+				d.logger.Error("failed to persist callback",
+					zap.String("notification_id", cb.NotificationID), zap.Error(err))
+				continue
+			}
+			deliver(cb)
+
+		case <-ticker.C:
+			due, err := d.repo.ClaimDue(ctx, callbackClaimLimit, time.Now().UTC().Add(callbackLease))
+			if err != nil {
+				d.logger.Error("callback poll error", zap.Error(err))
+				continue
+			}
+			for _, cb := range due {
+				deliver(cb)
+			}
+		}
+	}
+}
+
+// attempt makes one delivery attempt and records the outcome.
+func (d *CallbackDispatcher) attempt(ctx context.Context, cb *domain.Callback) {
+	attempts := cb.Attempts + 1
+	err := d.post(ctx, cb)
+	if ctx.Err() != nil {
+		return // shutting down; the lease expires and the poller retries later
+	}
+
+	log := d.logger.With(zap.String("callback_id", cb.ID), zap.String("notification_id", cb.NotificationID))
+	var recordErr error
+	switch {
+	case err == nil:
+		recordErr = d.repo.MarkDelivered(ctx, cb.ID, attempts)
+	case attempts >= d.opts.MaxAttempts:
+		log.Warn("callback permanently failed", zap.Int("attempts", attempts), zap.Error(err))
+		recordErr = d.repo.MarkFailed(ctx, cb.ID, attempts, err.Error())
+	default:
+		next := time.Now().UTC().Add(d.backoff(attempts))
+		log.Info("callback attempt failed; retry scheduled", zap.Int("attempts", attempts), zap.Time("next_attempt_at", next), zap.Error(err))
+		recordErr = d.repo.ScheduleRetry(ctx, cb.ID, attempts, next, err.Error())
+	}
+	if recordErr != nil {
+		log.Error("failed to record callback attempt", zap.Error(recordErr))
+	}
+}
+
+func (d *CallbackDispatcher) post(ctx context.Context, cb *domain.Callback) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(cb.Payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Callback-ID", cb.ID)
+	if d.opts.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Notification-Timestamp", ts)
+		req.Header.Set("X-Notification-Signature", "sha256="+signCallback(d.opts.Secret, ts, cb.Payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxCallbackResponseBytes)) //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *CallbackDispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.Backoff
+	for i := 1; i < attempts && delay < maxCallbackBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxCallbackBackoff)
+}
+
+// persist stores a callback for the poller without attempting delivery.
+func (d *CallbackDispatcher) persist(cb *domain.Callback) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+	cb.NextAttemptAt = time.Now().UTC()
+	if err := d.repo.Create(ctx, cb); err != nil {
+		d.logger.Error("failed to persist callback",
+			zap.String("notification_id", cb.NotificationID), zap.Error(err))
+	}
+}
+
+// drain persists callbacks still buffered at shutdown.
+func (d *CallbackDispatcher) drain() {
+	for {
+		select {
+		case cb := <-d.events:
+			d.persist(cb)
+		default:
+			return
+		}
+	}
+}
+
+// signCallback returns the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers
+// recompute it to verify the payload and reject stale timestamps.
+func signCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newCallback(n *domain.Notification) (*domain.Callback, error) {
+	now := time.Now().UTC()
+	payload := domain.CallbackPayload{
+		Event:          "notification." + string(n.Status),
+		NotificationID: n.ID,
+		BatchID:        n.BatchID,
+		Status:         n.Status,
+		Channel:        n.Channel,
+		ProviderMsgID:  n.ProviderMsgID,
+		RetryCount:     n.RetryCount,
+		SentAt:         n.SentAt,
+		OccurredAt:     now,
+	}
+	if n.ErrorMessage != nil {
+		msg := *n.ErrorMessage
+		if len(msg) > maxCallbackErrorLen {
+			msg = msg[:maxCallbackErrorLen]
+		}
+		payload.ErrorMessage = &msg
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Callback{
+		ID:             uuid.New().String(),
+		NotificationID: n.ID,
+		URL:            *n.CallbackURL,
+		Payload:        body,
+		Status:         domain.CallbackPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
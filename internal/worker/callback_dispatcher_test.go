@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+func terminalNotification(url string) *domain.Notification {
+	msgID := "prov-1"
+	return &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Status: domain.StatusSent,
+		ProviderMsgID: &msgID, CallbackURL: &url,
+	}
+}
+
+// waitFor polls the repository until cond holds for its single callback.
+func waitFor(t *testing.T, repo *repository.MockCallbackRepository, cond func(*domain.Callback) bool) *domain.Callback {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if all := repo.All(); len(all) == 1 && cond(all[0]) {
+			return all[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("condition not reached; callbacks: %+v", repo.All())
+	return nil
+}
+
+func TestCallbackDispatcher_DeliversSignedPayload(t *testing.T) {
+	type received struct {
+		body      []byte
+		timestamp string
+		signature string
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{body, r.Header.Get("X-Notification-Timestamp"), r.Header.Get("X-Notification-Signature")}
+	}))
+	defer srv.Close()
+
+	repo := repository.NewMockCallbackRepository()
+	d := NewCallbackDispatcher(repo, CallbackOptions{Secret: "s3cret", PollInterval: 10 * time.Millisecond}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Notify(terminalNotification(srv.URL))
+
+	var req received
+	select {
+	case req = <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+	if want := "sha256=" + signCallback("s3cret", req.timestamp, req.body); req.signature != want {
+		t.Fatalf("signature mismatch: got %q want %q", req.signature, want)
+	}
+	var payload domain.CallbackPayload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != "notification.sent" || payload.NotificationID != "n-1" || *payload.ProviderMsgID != "prov-1" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	waitFor(t, repo, func(cb *domain.Callback) bool {
+		return cb.Status == domain.CallbackDelivered && cb.Attempts == 1
+	})
+}
+
+func TestCallbackDispatcher_RetriesThenFails(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	repo := repository.NewMockCallbackRepository()
+	d := NewCallbackDispatcher(repo, CallbackOptions{
+		MaxAttempts:  3,
+		Backoff:      time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Notify(terminalNotification(srv.URL))
+
+	cb := waitFor(t, repo, func(cb *domain.Callback) bool { return cb.Status == domain.CallbackFailed })
+	if cb.Attempts != 3 || hits.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got attempts=%d hits=%d", cb.Attempts, hits.Load())
+	}
+	if cb.LastError == nil {
+		t.Fatal("expected the last error to be recorded")
+	}
+}
+
+func TestCallbackDispatcher_NotifyFiltersAndNeverBlocks(t *testing.T) {
+	repo := repository.NewMockCallbackRepository()
+	d := NewCallbackDispatcher(repo, CallbackOptions{BufferSize: 1}, zap.NewNop())
+
+	d.Notify(&domain.Notification{ID: "no-url", Status: domain.StatusSent})
+	queued := terminalNotification("https://client.example/hook")
+	queued.Status = domain.StatusQueued
+	d.Notify(queued)
+	if len(d.events) != 0 || len(repo.All()) != 0 {
+		t.Fatal("expected notifications without a URL or terminal status to be ignored")
+	}
+
+	// Run is not started: the first fills the buffer, the second must be
+	// persisted for the poller instead of blocking.
+	d.Notify(terminalNotification("https://client.example/hook"))
+	d.Notify(terminalNotification("https://client.example/hook"))
+	if len(d.events) != 1 || len(repo.All()) != 1 {
+		t.Fatalf("expected one buffered and one persisted callback, got %d/%d", len(d.events), len(repo.All()))
+	}
+}
+
+func TestCallbackDispatcher_Backoff(t *testing.T) {
+	d := NewCallbackDispatcher(nil, CallbackOptions{Backoff: time.Second}, zap.NewNop())
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Hour} {
+		if got := d.backoff(attempts); got != want {
+			t.Fatalf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
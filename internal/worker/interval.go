@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// interval is a poll interval that can be changed while its worker runs.
+// Set wakes the worker so the new interval applies at once instead of after
+// the next, possibly distant, tick.
+type interval struct {
+	d       atomic.Int64
+	changed chan struct{}
+}
+
+func newInterval(d time.Duration) *interval {
+	iv := &interval{changed: make(chan struct{}, 1)}
+	iv.d.Store(int64(d))
+	return iv
+}
+
+func (iv *interval) get() time.Duration {
+	return time.Duration(iv.d.Load())
+}
+
+func (iv *interval) set(d time.Duration) {
+	iv.d.Store(int64(d))
+	select {
+	case iv.changed <- struct{}{}:
+	default: // a wake-up is already pending
+	}
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// PartitionWorker keeps the monthly partitions of the notifications table in
+// shape: it pre-creates the next few months so inserts never land in the
+// DEFAULT partition, and drops whole months that fall outside the retention
+// window — far cheaper than DELETE, which leaves bloat behind.
+type PartitionWorker struct {
+	repo            repository.PartitionRepository
+	interval        time.Duration
+	precreateMonths int
+	retentionMonths int
+	logger          *zap.Logger
+}
+
+// NewPartitionWorker constructs a PartitionWorker. retentionMonths = 0 disables dropping.
+func NewPartitionWorker(
+	repo repository.PartitionRepository,
+	interval time.Duration,
+	precreateMonths int,
+	retentionMonths int,
+	logger *zap.Logger,
+) *PartitionWorker {
+	return &PartitionWorker{
+		repo: repo, interval: interval,
+		precreateMonths: precreateMonths, retentionMonths: retentionMonths,
+		logger: logger,
+	}
+}
+
+// Run performs maintenance immediately (so a fresh deploy is covered) and then
+// every interval. Stops cleanly when ctx is cancelled.
+func (pw *PartitionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+
+	pw.logger.Info("partition worker started",
+		zap.Duration("interval", pw.interval),
+		zap.Int("precreate_months", pw.precreateMonths),
+		zap.Int("retention_months", pw.retentionMonths),
+	)
+	pw.maintain(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			pw.logger.Info("partition worker stopping")
+			return
+		case <-ticker.C:
+			pw.maintain(ctx, time.Now())
+		}
+	}
+}
+
+func (pw *PartitionWorker) maintain(ctx context.Context, now time.Time) {
+	for _, month := range upcomingMonths(now, pw.precreateMonths) {
+		if err := pw.repo.EnsurePartition(ctx, month); err != nil {
+			pw.logger.Error("failed to create partition", zap.Time("month", month), zap.Error(err))
+		}
+	}
+
+	if pw.retentionMonths <= 0 {
+		return
+	}
+
+	existing, err := pw.repo.ListPartitions(ctx)
+	if err != nil {
+		pw.logger.Error("failed to list partitions", zap.Error(err))
+		return
+	}
+	for _, month := range expiredMonths(existing, now, pw.retentionMonths) {
+		if err := pw.repo.DropPartition(ctx, month); err != nil {
+			pw.logger.Error("failed to drop partition", zap.Time("month", month), zap.Error(err))
+			continue
+		}
+		pw.logger.Info("dropped expired partition", zap.Time("month", month))
+	}
+}
+
+// upcomingMonths returns the current month plus the next ahead months.
+func upcomingMonths(now time.Time, ahead int) []time.Time {
+	now = now.UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	months := make([]time.Time, 0, ahead+1)
+	for i := 0; i <= ahead; i++ {
+		months = append(months, current.AddDate(0, i, 0))
+	}
+	return months
+}
+
+// expiredMonths returns the partitions whose entire range is older than the
+// retention window. The current month counts as the first retained month, so
+// retention=1 keeps only the current month.
+func expiredMonths(existing []time.Time, now time.Time, retentionMonths int) []time.Time {
+	now = now.UTC()
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(retentionMonths - 1), 0)
+	var expired []time.Time
+	for _, month := range existing {
+		if month.Before(cutoff) {
+			expired = append(expired, month)
+		}
+	}
+	return expired
+}
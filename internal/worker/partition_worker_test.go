@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestUpcomingMonths_CrossesYearBoundary(t *testing.T) {
+	now := time.Date(2026, time.November, 17, 13, 0, 0, 0, time.UTC)
+
+	got := upcomingMonths(now, 3)
+	want := []time.Time{
+		month(2026, time.November),
+		month(2026, time.December),
+		month(2027, time.January),
+		month(2027, time.February),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d months, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("month %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestExpiredMonths(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	existing := []time.Time{
+		month(2025, time.December),
+		month(2026, time.January),
+		month(2026, time.February),
+		month(2026, time.March),
+		month(2026, time.April),
+	}
+
+	// Retain three months: March, February, January.
+	got := expiredMonths(existing, now, 3)
+	if len(got) != 1 || !got[0].Equal(month(2025, time.December)) {
+		t.Fatalf("expected only 2025-12 to expire, got %v", got)
+	}
+
+	// Retain one month: only the current month survives (future months too).
+	got = expiredMonths(existing, now, 1)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 expired months, got %v", got)
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OverrunHook is told how many ticks a poll skipped by running past them.
+type OverrunHook func(skipped int)
+
+// runPoller calls poll at once and then every iv until ctx is cancelled, so
+// work that came due while the process was down is picked up on startup
+// rather than an interval later. Ticks stay on the schedule set by the first
+// poll instead of drifting by however long each poll takes. A poll that runs
+// past one or more ticks skips them; the skip is logged and handed to
+// onOverrun. Changing iv restarts the schedule from now.
+func runPoller(ctx context.Context, name string, iv *interval, poll func(context.Context), onOverrun OverrunHook, logger *zap.Logger) {
+	logger.Info(name+" started", zap.Duration("interval", iv.get()))
+
+	next := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(name + " stopping")
+			return
+		case <-iv.changed:
+			next = time.Now().Add(iv.get())
+		case <-timer.C:
+			poll(ctx)
+			var skipped int
+			next, skipped = nextTick(next, iv.get(), time.Now())
+			if skipped > 0 && ctx.Err() == nil {
+				logger.Warn(name+" poll overran its interval; skipping ticks",
+					zap.Int("skipped", skipped), zap.Duration("interval", iv.get()))
+				if onOverrun != nil {
+					onOverrun(skipped)
+				}
+			}
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// nextTick returns the tick after prev on a schedule of every d, and how many
+// ticks had already passed by now and are skipped.
+func nextTick(prev time.Time, d time.Duration, now time.Time) (next time.Time, skipped int) {
+	next = prev.Add(d)
+	if !now.After(next) {
+		return next, 0
+	}
+	skipped = int(now.Sub(next)/d) + 1
+	return next.Add(time.Duration(skipped) * d), skipped
+}
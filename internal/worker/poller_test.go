@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRunPoller_PollsAtStartup(t *testing.T) {
+	polled := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runPoller(ctx, "test poller", newInterval(time.Hour), func(context.Context) {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+	}, nil, zap.NewNop())
+
+	select {
+	case <-polled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a poll at startup, not an hour later")
+	}
+}
+
+func TestRunPoller_OverrunSkipsTicks(t *testing.T) {
+	skips := make(chan int, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first := true
+	go runPoller(ctx, "test poller", newInterval(20*time.Millisecond), func(context.Context) {
+		if first {
+			first = false
+			time.Sleep(70 * time.Millisecond) // runs past the ticks at 20, 40 and 60ms
+		}
+	}, func(skipped int) {
+		select {
+		case skips <- skipped:
+		default:
+		}
+	}, zap.NewNop())
+
+	select {
+	case got := <-skips:
+		if got < 3 { // more if the sleep itself overran
+			t.Fatalf("expected at least 3 skipped ticks, got %d", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the overrun to be reported")
+	}
+}
+
+func TestNextTick(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name        string
+		now         time.Duration // after start
+		wantNext    time.Duration // after start
+		wantSkipped int
+	}{
+		{"poll finished early", 3 * time.Second, 10 * time.Second, 0},
+		{"poll finished on the tick", 10 * time.Second, 10 * time.Second, 0},
+		{"poll overran one tick", 15 * time.Second, 20 * time.Second, 1},
+		{"poll overran three ticks", 35 * time.Second, 40 * time.Second, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next, skipped := nextTick(start, 10*time.Second, start.Add(tc.now))
+			if !next.Equal(start.Add(tc.wantNext)) || skipped != tc.wantSkipped {
+				t.Fatalf("expected next at +%s with %d skipped, got +%s with %d", tc.wantNext, tc.wantSkipped, next.Sub(start), skipped)
+			}
+		})
+	}
+}
@@ -2,32 +2,62 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/config"
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/provider"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
 	"github.com/ricirt/event-driven-arch/internal/repository"
 )
 
-// MetricHooks carries the metric callback functions injected by main.
+// Hooks carries the callback functions injected by main.
 // Using a struct keeps the pool constructor signature clean.
-type MetricHooks struct {
-	OnSent   func(channel domain.Channel, latency time.Duration)
-	OnFailed func(channel domain.Channel)
+type Hooks struct {
+	OnSent   func(channel domain.Channel, priority domain.Priority, latency time.Duration)
+	OnFailed func(channel domain.Channel, priority domain.Priority)
+	// OnTerminal receives notifications that reached sent or permanently
+	// failed; main wires it to CallbackDispatcher.Notify. It must not block.
+	OnTerminal func(n *domain.Notification)
+	// Events receives sent, failed and retry_scheduled lifecycle events. It
+	// must not block.
+	Events events.Publisher
+	// OnBatchChanged receives the batch ID of every settled notification that
+	// belongs to one; main wires it to BatchCounter.Touch. It must not block.
+	// When nil the worker updates the counters itself before taking its next
+	// item, one query per call.
+	OnBatchChanged func(batchID string)
+	// OnRetry counts retry activity: attempt numbers the retry, from 1, and
+	// outcome is RetryScheduled, RetrySent or RetryExhausted.
+	OnRetry func(channel domain.Channel, attempt int, outcome string)
+	// OnDelivered receives every sent notification, with SentAt set, for
+	// end-to-end latency measurement. It must not block.
+	OnDelivered func(n *domain.Notification)
 }
 
+// Outcomes reported to Hooks.OnRetry.
+const (
+	RetryScheduled = "scheduled" // a retry was scheduled after a failure
+	RetrySent      = "sent"      // a retry delivered the notification
+	RetryExhausted = "exhausted" // the last retry failed too
+)
+
 // Pool manages the lifecycle of all workers.
 // All workers share the same priority queue — the queue's double-select
 // pattern handles priority ordering internally.
 type Pool struct {
 	workers []*Worker
 	wg      sync.WaitGroup
+	busy    atomic.Int32 // workers currently processing an item
+	backoff atomic.Pointer[[]time.Duration]
 }
 
 // NewPool creates (SMS + Email + Push) workers as configured.
@@ -40,22 +70,50 @@ func NewPool(
 	prov provider.Provider,
 	limiter *ratelimiter.ChannelLimiters,
 	logger *zap.Logger,
-	hooks MetricHooks,
+	hooks Hooks,
 ) *Pool {
 	total := cfg.SMSWorkers + cfg.EmailWorkers + cfg.PushWorkers
-	workers := make([]*Worker, total)
+	p := &Pool{workers: make([]*Worker, total)}
+	p.SetRetryBackoff(cfg.RetryBackoff)
 
-	for i := range workers {
-		workers[i] = NewWorker(
+	for i := range p.workers {
+		p.workers[i] = NewWorker(
 			i, q, repo, prov, limiter,
 			cfg.RetryBackoff,
 			logger.With(zap.Int("worker_id", i)),
-			hooks.OnSent,
-			hooks.OnFailed,
+			hooks,
 		)
+		p.workers[i].busy = &p.busy
+		p.workers[i].sendTimeout = cfg.ProviderTimeout
+		p.workers[i].sandbox = cfg.SandboxMode
+		p.workers[i].backoff = &p.backoff
+	}
+
+	return p
+}
+
+// LimitRecipients makes every worker consult rl before sending, and
+// reschedule sends it refuses. Call it before Start.
+func (p *Pool) LimitRecipients(rl *ratelimiter.RecipientLimiter) {
+	if rl == nil {
+		return
+	}
+	for _, w := range p.workers {
+		w.recipients = rl
 	}
+}
+
+// SetRetryBackoff replaces the retry delays used for failures from now on;
+// retries already scheduled keep their time.
+func (p *Pool) SetRetryBackoff(backoff []time.Duration) {
+	backoff = slices.Clone(backoff)
+	p.backoff.Store(&backoff)
+}
 
-	return &Pool{workers: workers}
+// Stats returns the number of workers and how many of them are processing
+// an item right now.
+func (p *Pool) Stats() (total, busy int) {
+	return len(p.workers), int(p.busy.Load())
 }
 
 // Start launches all workers as goroutines.
@@ -76,3 +134,23 @@ func (p *Pool) Start(ctx context.Context) {
 func (p *Pool) Wait() {
 	p.wg.Wait()
 }
+
+// ReleaseQueued drains q and moves every notification it still held back to
+// pending, where RequeuePending finds it; left queued, nothing would ever
+// dispatch it again. Call it once the workers and pollers have stopped. It
+// returns how many notifications were released.
+func ReleaseQueued(ctx context.Context, q *queue.PriorityQueue, repo repository.NotificationRepository, logger *zap.Logger) int {
+	released := 0
+	for _, item := range q.Drain() {
+		switch err := repo.ReleaseToPending(ctx, item.NotificationID); {
+		case err == nil:
+			released++
+		case errors.Is(err, domain.ErrNotFound):
+			// Cancelled or expired meanwhile; nothing to hand back.
+		default:
+			logger.Error("failed to release queued notification",
+				zap.String("id", item.NotificationID), zap.Error(err))
+		}
+	}
+	return released
+}
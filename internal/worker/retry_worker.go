@@ -2,80 +2,143 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
 // RetryWorker polls the database for failed notifications whose
-// next_retry_at is in the past and re-enqueues them.
+// next_retry_at is in the past and re-enqueues them. Each poll claims the
+// rows it finds by moving them to queued in the same statement, so a row is
+// never picked up twice, and a row already waiting in the queue is skipped.
 //
 // This DB-backed approach means retries survive server restarts:
 // scheduled retry times are persisted, not held in memory.
 type RetryWorker struct {
 	repo     repository.NotificationRepository
 	q        *queue.PriorityQueue
-	interval time.Duration
+	interval *interval
+	pageSize int
+	events   events.Publisher
+	hooks    RetryHooks
 	logger   *zap.Logger
 }
 
+// RetryHooks are the retry worker's metric callbacks; each one is optional.
+type RetryHooks struct {
+	// OnQueueFull is told about retries the queue rejected as full.
+	OnQueueFull queue.FullHook
+	// OnDue receives how many due retries each poll found.
+	OnDue func(count int)
+	// OnRequeued receives how long each requeued retry waited since it failed.
+	OnRequeued func(channel domain.Channel, wait time.Duration)
+	// OnPoll is told about every poll.
+	OnPoll PollHook
+	// OnOverrun is told about ticks skipped by a poll that overran.
+	OnOverrun OverrunHook
+}
+
 func NewRetryWorker(
 	repo repository.NotificationRepository,
 	q *queue.PriorityQueue,
 	interval time.Duration,
+	pageSize int,
+	pub events.Publisher,
+	hooks RetryHooks,
 	logger *zap.Logger,
 ) *RetryWorker {
-	return &RetryWorker{repo: repo, q: q, interval: interval, logger: logger}
+	return &RetryWorker{repo: repo, q: q, interval: newInterval(interval), pageSize: pageSize, events: pub, hooks: hooks, logger: logger}
 }
 
-// Run ticks every interval and re-enqueues any due retries.
+// Run re-enqueues any due retries at once and then every interval.
 // Stops cleanly when ctx is cancelled.
 func (rw *RetryWorker) Run(ctx context.Context) {
-	ticker := time.NewTicker(rw.interval)
-	defer ticker.Stop()
+	runPoller(ctx, "retry worker", rw.interval, rw.poll, rw.hooks.OnOverrun, rw.logger)
+}
 
-	rw.logger.Info("retry worker started", zap.Duration("interval", rw.interval))
+// SetInterval changes how often the worker polls, effective immediately.
+func (rw *RetryWorker) SetInterval(d time.Duration) {
+	rw.interval.set(d)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			rw.logger.Info("retry worker stopping")
+// poll re-enqueues due retries a page at a time, oldest first, until none
+// are left, the queue fills up, or maxPollPages pages have been read.
+func (rw *RetryWorker) poll(ctx context.Context) {
+	start := time.Now()
+	found, requeued := 0, 0
+	for page := 0; page < maxPollPages; page++ {
+		notifications, err := rw.repo.ClaimDueRetries(ctx, rw.pageSize)
+		if err != nil {
+			reportPoll(ctx, rw.hooks.OnPoll, found, start, err)
+			rw.logger.Error("retry poll error", zap.Error(err))
 			return
-		case <-ticker.C:
-			rw.poll(ctx)
+		}
+		found += len(notifications)
+		n, full := rw.requeue(ctx, notifications)
+		requeued += n
+		if full || len(notifications) < rw.pageSize || ctx.Err() != nil {
+			break
 		}
 	}
-}
+	if rw.hooks.OnDue != nil {
+		rw.hooks.OnDue(found)
+	}
 
-func (rw *RetryWorker) poll(ctx context.Context) {
-	notifications, err := rw.repo.FindDueRetries(ctx)
-	if err != nil {
-		rw.logger.Error("retry poll error", zap.Error(err))
-		return
+	reportPoll(ctx, rw.hooks.OnPoll, found, start, nil)
+	if requeued > 0 {
+		rw.logger.Info("re-enqueued due retries", zap.Int("count", requeued), zap.Int("found", found))
 	}
+}
 
+// requeue enqueues one page of claimed retries and reports how many made it,
+// and whether the queue turned any away as full. A retry the queue rejects is
+// put back to failed so a later poll claims it again, unless it has left
+// queued meanwhile, e.g. cancelled.
+func (rw *RetryWorker) requeue(ctx context.Context, notifications []*domain.Notification) (requeued int, full bool) {
 	for _, n := range notifications {
-		if err := rw.q.Enqueue(queue.Item{
+		if rw.q.Queued(n.ID) {
+			rw.logger.Warn("retry already queued, skipping",
+				zap.String("id", n.ID))
+			continue
+		}
+
+		span, traceParent := tracing.StartEnqueue(ctx, n.ID)
+		err := rw.q.Enqueue(queue.Item{
 			NotificationID: n.ID,
 			Channel:        n.Channel,
 			Priority:       n.Priority,
-		}); err != nil {
+			TraceParent:    traceParent,
+		})
+		tracing.End(span, err)
+		if err != nil {
+			if errors.Is(err, domain.ErrQueueFull) {
+				full = true
+				if rw.hooks.OnQueueFull != nil {
+					rw.hooks.OnQueueFull(n.Priority, queue.SourceRetry)
+				}
+			}
 			rw.logger.Warn("could not re-enqueue retry",
 				zap.String("id", n.ID), zap.Error(err))
+			if err := rw.repo.RevertQueued(ctx, n.ID, domain.StatusFailed); err != nil && !errors.Is(err, domain.ErrNotFound) {
+				rw.logger.Error("failed to revert status to failed",
+					zap.String("id", n.ID), zap.Error(err))
+			}
 			continue
 		}
 
-		if err := rw.repo.UpdateStatus(ctx, n.ID, domain.StatusQueued); err != nil {
-			rw.logger.Error("failed to update status after re-enqueue",
-				zap.String("id", n.ID), zap.Error(err))
+		// The row was last updated when its retry was scheduled.
+		if rw.hooks.OnRequeued != nil {
+			rw.hooks.OnRequeued(n.Channel, time.Since(n.UpdatedAt))
 		}
+		rw.events.Publish(ctx, events.New(ctx, events.TypeQueued, n)) //nolint:errcheck // best-effort
+		requeued++
 	}
-
-	if len(notifications) > 0 {
-		rw.logger.Info("re-enqueued due retries", zap.Int("count", len(notifications)))
-	}
+	return requeued, full
 }
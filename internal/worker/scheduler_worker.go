@@ -2,80 +2,179 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
 // SchedulerWorker polls the database for notifications whose scheduled_at
 // has passed and enqueues them for immediate processing.
 //
 // Notifications created with a future scheduled_at are stored with
-// status=scheduled and bypass the queue until their time arrives.
+// status=scheduled and bypass the queue until their time arrives. Due ones
+// are taken oldest first, so after downtime the longest overdue go out
+// before those that only just came due; what the queue cannot take stays
+// scheduled for the next tick.
 type SchedulerWorker struct {
 	repo     repository.NotificationRepository
 	q        *queue.PriorityQueue
-	interval time.Duration
+	interval *interval
+	pageSize int
+	events   events.Publisher
+	hooks    SchedulerHooks
 	logger   *zap.Logger
 }
 
+// PollHook receives the outcome of every poll a background poller made: how
+// many items it found, how long the poll took, and the error that ended it,
+// if any. Polls cut short by shutdown are not reported.
+type PollHook func(found int, elapsed time.Duration, err error)
+
+// SchedulerHooks are the scheduler's metric callbacks; each one is optional.
+type SchedulerHooks struct {
+	// OnQueueFull is told about due notifications the queue rejected as full.
+	OnQueueFull queue.FullHook
+	// OnPoll is told about every poll.
+	OnPoll PollHook
+	// OnOverrun is told about ticks skipped by a poll that overran.
+	OnOverrun OverrunHook
+	// OnOverdue receives how many scheduled notifications were still overdue
+	// after each successful poll.
+	OnOverdue func(count int)
+}
+
 func NewSchedulerWorker(
 	repo repository.NotificationRepository,
 	q *queue.PriorityQueue,
 	interval time.Duration,
+	pageSize int,
+	pub events.Publisher,
+	hooks SchedulerHooks,
 	logger *zap.Logger,
 ) *SchedulerWorker {
-	return &SchedulerWorker{repo: repo, q: q, interval: interval, logger: logger}
+	return &SchedulerWorker{repo: repo, q: q, interval: newInterval(interval), pageSize: pageSize, events: pub, hooks: hooks, logger: logger}
 }
 
-// Run ticks every interval and enqueues any notifications that are now due.
-// Stops cleanly when ctx is cancelled.
+// Run enqueues any notifications that are now due at once and then every
+// interval. Stops cleanly when ctx is cancelled.
 func (sw *SchedulerWorker) Run(ctx context.Context) {
-	ticker := time.NewTicker(sw.interval)
-	defer ticker.Stop()
+	runPoller(ctx, "scheduler worker", sw.interval, sw.poll, sw.hooks.OnOverrun, sw.logger)
+}
 
-	sw.logger.Info("scheduler worker started", zap.Duration("interval", sw.interval))
+// SetInterval changes how often the worker polls, effective immediately.
+func (sw *SchedulerWorker) SetInterval(d time.Duration) {
+	sw.interval.set(d)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			sw.logger.Info("scheduler worker stopping")
+// poll enqueues due notifications a page at a time, oldest first, until none
+// are left, the queue fills up, or maxPollPages pages have been read.
+func (sw *SchedulerWorker) poll(ctx context.Context) {
+	start := time.Now()
+	found, enqueued := 0, 0
+	for page := 0; page < maxPollPages; page++ {
+		notifications, err := sw.repo.FindDueScheduled(ctx, sw.pageSize)
+		if err != nil {
+			reportPoll(ctx, sw.hooks.OnPoll, found, start, err)
+			sw.logger.Error("scheduler poll error", zap.Error(err))
 			return
-		case <-ticker.C:
-			sw.poll(ctx)
 		}
+		found += len(notifications)
+		n, full := sw.enqueue(ctx, notifications)
+		enqueued += n
+		if full || len(notifications) < sw.pageSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	reportPoll(ctx, sw.hooks.OnPoll, found, start, nil)
+	if enqueued > 0 {
+		sw.logger.Info("enqueued due scheduled notifications", zap.Int("count", enqueued), zap.Int("found", found))
 	}
+	sw.reportOverdue(ctx)
 }
 
-func (sw *SchedulerWorker) poll(ctx context.Context) {
-	notifications, err := sw.repo.FindDueScheduled(ctx)
+// reportOverdue hands the backlog left after a poll to OnOverdue.
+func (sw *SchedulerWorker) reportOverdue(ctx context.Context) {
+	if sw.hooks.OnOverdue == nil || ctx.Err() != nil {
+		return
+	}
+	count, err := sw.repo.CountOverdueScheduled(ctx)
 	if err != nil {
-		sw.logger.Error("scheduler poll error", zap.Error(err))
+		sw.logger.Error("failed to count overdue scheduled notifications", zap.Error(err))
 		return
 	}
+	sw.hooks.OnOverdue(count)
+}
 
+// enqueue queues one page of due notifications and reports how many made it,
+// and whether the queue turned any away as full. Each row is claimed, moved
+// from scheduled to queued, before it goes on the queue, so a worker that
+// takes it at once never has its progress overwritten; a row cancelled or
+// claimed since the page was read is skipped, and one the queue rejects is
+// handed back to scheduled.
+func (sw *SchedulerWorker) enqueue(ctx context.Context, notifications []*domain.Notification) (enqueued int, full bool) {
 	for _, n := range notifications {
-		if err := sw.q.Enqueue(queue.Item{
+		if sw.q.Queued(n.ID) {
+			sw.logger.Warn("scheduled notification already queued, skipping",
+				zap.String("id", n.ID))
+			continue
+		}
+		if err := sw.repo.ClaimScheduled(ctx, n.ID); err != nil {
+			if !errors.Is(err, domain.ErrNotFound) {
+				sw.logger.Error("failed to claim scheduled notification",
+					zap.String("id", n.ID), zap.Error(err))
+			}
+			continue // cancelled or rescheduled since the lookup
+		}
+
+		span, traceParent := tracing.StartEnqueue(ctx, n.ID)
+		err := sw.q.Enqueue(queue.Item{
 			NotificationID: n.ID,
 			Channel:        n.Channel,
 			Priority:       n.Priority,
-		}); err != nil {
+			TraceParent:    traceParent,
+		})
+		tracing.End(span, err)
+		if err != nil {
+			if errors.Is(err, domain.ErrQueueFull) {
+				full = true
+				if sw.hooks.OnQueueFull != nil {
+					sw.hooks.OnQueueFull(n.Priority, queue.SourceScheduler)
+				}
+			}
 			sw.logger.Warn("could not enqueue scheduled notification",
 				zap.String("id", n.ID), zap.Error(err))
+			// Conditional, so a cancel since the claim stands.
+			if err := sw.repo.Reschedule(ctx, n.ID, *n.ScheduledAt); err != nil && !errors.Is(err, domain.ErrNotFound) {
+				sw.logger.Error("failed to revert status to scheduled",
+					zap.String("id", n.ID), zap.Error(err))
+			}
 			continue
 		}
 
-		if err := sw.repo.UpdateStatus(ctx, n.ID, domain.StatusQueued); err != nil {
-			sw.logger.Error("failed to update status after scheduling",
-				zap.String("id", n.ID), zap.Error(err))
-		}
+		n.Status = domain.StatusQueued
+		sw.events.Publish(ctx, events.New(ctx, events.TypeQueued, n)) //nolint:errcheck // best-effort
+		enqueued++
 	}
+	return enqueued, full
+}
 
-	if len(notifications) > 0 {
-		sw.logger.Info("enqueued due scheduled notifications", zap.Int("count", len(notifications)))
+// maxPollPages caps the pages one poll reads, so rows that keep coming back
+// (a status update that fails, say) cannot hold a poller in a single tick.
+const maxPollPages = 100
+
+// reportPoll hands a poll that began at start to hook, unless hook is unset
+// or ctx ended: a poll interrupted by shutdown says nothing about the poller.
+func reportPoll(ctx context.Context, hook PollHook, found int, start time.Time, err error) {
+	if hook == nil || ctx.Err() != nil {
+		return
 	}
+	hook(found, time.Since(start), err)
 }
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// StatusCountWorker periodically counts the stored notifications by status
+// and channel, the backlog the in-memory queue depth cannot show: pending rows
+// that never made it onto the queue, failures awaiting a retry, and so on.
+//
+// Each count runs in the background. While one is still running on a slow
+// database, further ticks are skipped instead of piling queries up.
+type StatusCountWorker struct {
+	repo     repository.NotificationRepository
+	interval time.Duration
+	set      func(counts []domain.StatusCount)
+	logger   *zap.Logger
+
+	running atomic.Bool
+}
+
+// NewStatusCountWorker returns a worker handing every count to set; main
+// wires it to the notifications_by_status gauge.
+func NewStatusCountWorker(
+	repo repository.NotificationRepository,
+	interval time.Duration,
+	set func(counts []domain.StatusCount),
+	logger *zap.Logger,
+) *StatusCountWorker {
+	return &StatusCountWorker{repo: repo, interval: interval, set: set, logger: logger}
+}
+
+// Run counts immediately and then every interval. Stops cleanly when ctx is
+// cancelled; a count still running then ends with ctx.
+func (sw *StatusCountWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(sw.interval)
+	defer ticker.Stop()
+
+	sw.logger.Info("status count worker started", zap.Duration("interval", sw.interval))
+	sw.tick(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			sw.logger.Info("status count worker stopping")
+			return
+		case <-ticker.C:
+			sw.tick(ctx)
+		}
+	}
+}
+
+// tick starts a count unless the previous one has not finished. It reports
+// whether a count was started.
+func (sw *StatusCountWorker) tick(ctx context.Context) bool {
+	if !sw.running.CompareAndSwap(false, true) {
+		sw.logger.Warn("previous status count still running; skipping this one")
+		return false
+	}
+	go func() {
+		defer sw.running.Store(false)
+		sw.count(ctx)
+	}()
+	return true
+}
+
+func (sw *StatusCountWorker) count(ctx context.Context) {
+	counts, err := sw.repo.CountByStatus(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			sw.logger.Error("failed to count notifications by status", zap.Error(err))
+		}
+		return
+	}
+	sw.set(counts)
+}
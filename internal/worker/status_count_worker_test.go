@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+)
+
+// slowCountRepo holds every CountByStatus until release is closed.
+type slowCountRepo struct {
+	*repository.MockNotificationRepository
+	calls   chan struct{}
+	release chan struct{}
+}
+
+func (r *slowCountRepo) CountByStatus(ctx context.Context) ([]domain.StatusCount, error) {
+	r.calls <- struct{}{}
+	<-r.release
+	return r.MockNotificationRepository.CountByStatus(ctx)
+}
+
+func TestStatusCountWorker_SkipsWhileCountRunning(t *testing.T) {
+	repo := &slowCountRepo{
+		MockNotificationRepository: repository.NewMockNotificationRepository(),
+		calls:                      make(chan struct{}, 10),
+		release:                    make(chan struct{}),
+	}
+	repo.Create(context.Background(), &domain.Notification{ID: "n-1", Channel: domain.ChannelSMS, Status: domain.StatusPending}) //nolint:errcheck
+
+	got := make(chan []domain.StatusCount, 1)
+	sw := NewStatusCountWorker(repo, time.Hour, func(c []domain.StatusCount) { got <- c }, zap.NewNop())
+
+	if !sw.tick(context.Background()) {
+		t.Fatal("expected the first tick to start a count")
+	}
+	<-repo.calls
+	if sw.tick(context.Background()) {
+		t.Fatal("expected a tick during a running count to be skipped")
+	}
+
+	close(repo.release)
+	counts := <-got
+	want := domain.StatusCount{Status: domain.StatusPending, Channel: domain.ChannelSMS, Count: 1}
+	if len(counts) != 1 || counts[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, counts)
+	}
+
+	// Once the count is done the next tick runs again.
+	deadline := time.Now().Add(time.Second)
+	for !sw.tick(context.Background()) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a tick after the count finished to start another")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	<-got
+	if len(repo.calls) != 1 {
+		t.Fatalf("expected exactly two counts in total, got %d", 1+len(repo.calls))
+	}
+}
@@ -2,15 +2,21 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
 	"github.com/ricirt/event-driven-arch/internal/provider"
 	"github.com/ricirt/event-driven-arch/internal/queue"
 	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
 	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
 )
 
 // Worker is a single goroutine that continuously pulls items from the priority
@@ -21,16 +27,45 @@ type Worker struct {
 	q       *queue.PriorityQueue
 	repo    repository.NotificationRepository
 	prov    provider.Provider
-	limiter *ratelimiter.ChannelLimiters
-	backoff []time.Duration
+	limiter channelLimiter
+	backoff *atomic.Pointer[[]time.Duration] // shared by the pool, see Pool.SetRetryBackoff
 	logger  *zap.Logger
 
-	// Hooks for metrics — injected by the pool so the worker stays metrics-agnostic.
-	onSent    func(channel domain.Channel, latency time.Duration)
-	onFailed  func(channel domain.Channel)
+	// sendTimeout bounds a provider call, which shutdown no longer cuts off;
+	// zero leaves it to the provider's own timeout.
+	sendTimeout time.Duration
+
+	// sandbox treats every notification as a dry run (SANDBOX_MODE).
+	sandbox bool
+
+	// recipients caps sends per recipient; nil when no limits are set.
+	recipients recipientLimiter
+
+	// Hooks — injected by the pool so the worker stays metrics- and callback-agnostic.
+	onSent      func(channel domain.Channel, priority domain.Priority, latency time.Duration)
+	onFailed    func(channel domain.Channel, priority domain.Priority)
+	onTerminal  func(n *domain.Notification)
+	onBatch     func(batchID string)
+	onRetry     func(channel domain.Channel, attempt int, outcome string)
+	onDelivered func(n *domain.Notification)
+	events      events.Publisher
+
+	// busy is the pool's in-flight counter; nil for a worker built on its own.
+	busy *atomic.Int32
+}
+
+// channelLimiter is the part of ratelimiter.ChannelLimiters a worker uses.
+type channelLimiter interface {
+	Wait(ctx context.Context, ch domain.Channel) error
+}
+
+// recipientLimiter is the part of ratelimiter.RecipientLimiter a worker uses.
+type recipientLimiter interface {
+	Allow(ch domain.Channel, recipient string) (retryAfter time.Duration, ok bool)
+	Release(ch domain.Channel, recipient string)
 }
 
-// NewWorker constructs a worker. onSent and onFailed are optional (nil = no-op).
+// NewWorker constructs a worker. Every hook is optional (nil = no-op).
 func NewWorker(
 	id int,
 	q *queue.PriorityQueue,
@@ -39,20 +74,35 @@ func NewWorker(
 	limiter *ratelimiter.ChannelLimiters,
 	backoff []time.Duration,
 	logger *zap.Logger,
-	onSent func(domain.Channel, time.Duration),
-	onFailed func(domain.Channel),
+	hooks Hooks,
 ) *Worker {
-	if onSent == nil {
-		onSent = func(domain.Channel, time.Duration) {}
+	if hooks.OnSent == nil {
+		hooks.OnSent = func(domain.Channel, domain.Priority, time.Duration) {}
+	}
+	if hooks.OnFailed == nil {
+		hooks.OnFailed = func(domain.Channel, domain.Priority) {}
+	}
+	if hooks.OnTerminal == nil {
+		hooks.OnTerminal = func(*domain.Notification) {}
 	}
-	if onFailed == nil {
-		onFailed = func(domain.Channel) {}
+	if hooks.OnRetry == nil {
+		hooks.OnRetry = func(domain.Channel, int, string) {}
 	}
-	return &Worker{
+	if hooks.OnDelivered == nil {
+		hooks.OnDelivered = func(*domain.Notification) {}
+	}
+	if hooks.Events == nil {
+		hooks.Events = events.Nop{}
+	}
+	w := &Worker{
 		id: id, q: q, repo: repo, prov: prov,
-		limiter: limiter, backoff: backoff, logger: logger,
-		onSent: onSent, onFailed: onFailed,
+		limiter: limiter, backoff: new(atomic.Pointer[[]time.Duration]), logger: logger,
+		onSent: hooks.OnSent, onFailed: hooks.OnFailed, onTerminal: hooks.OnTerminal,
+		onBatch: hooks.OnBatchChanged, onRetry: hooks.OnRetry, onDelivered: hooks.OnDelivered,
+		events: hooks.Events,
 	}
+	w.backoff.Store(&backoff)
+	return w
 }
 
 // Run blocks until ctx is cancelled, processing one queue item per iteration.
@@ -64,11 +114,27 @@ func (w *Worker) Run(ctx context.Context) {
 			w.logger.Info("worker stopping", zap.Int("id", w.id))
 			return
 		}
+		if w.busy != nil {
+			w.busy.Add(1)
+		}
 		w.process(ctx, item)
+		if w.busy != nil {
+			w.busy.Add(-1)
+		}
 	}
 }
 
 func (w *Worker) process(ctx context.Context, item queue.Item) {
+	// Continue the trace of whoever enqueued the item.
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, item.TraceParent), "worker.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("notification.id", item.NotificationID),
+			attribute.String("notification.channel", string(item.Channel)),
+		),
+	)
+	defer span.End()
+
 	start := time.Now()
 	log := w.logger.With(
 		zap.String("notification_id", item.NotificationID),
@@ -77,37 +143,116 @@ func (w *Worker) process(ctx context.Context, item queue.Item) {
 
 	n, err := w.repo.GetByID(ctx, item.NotificationID)
 	if err != nil {
+		if ctx.Err() != nil {
+			w.release(ctx, item.NotificationID, log)
+			return
+		}
 		log.Error("failed to fetch notification", zap.Error(err))
 		return
 	}
 
+	// Carry the creating request's correlation ID across the queue, into the
+	// log lines, lifecycle events and query logs below.
+	if n.CorrelationID != nil {
+		ctx = domain.WithCorrelationID(ctx, *n.CorrelationID)
+		log = log.With(zap.String("correlation_id", *n.CorrelationID))
+	}
+
 	// A cancellation between enqueue and processing time is valid; skip silently.
 	if n.Status == domain.StatusCancelled {
 		log.Debug("notification was cancelled before processing")
 		return
 	}
 
-	if err := w.repo.UpdateStatus(ctx, n.ID, domain.StatusProcessing); err != nil {
-		log.Error("failed to mark as processing", zap.Error(err))
-		return
+	// Outside the recipient's delivery window: hand it back to the scheduler
+	// for the next opening instead of sending now.
+	if window, ok := n.SendWindow(); ok {
+		if next, open := window.NextOpening(time.Now()); !open {
+			if err := w.repo.Reschedule(ctx, n.ID, next.UTC()); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					log.Info("notification cancelled or expired before rescheduling; not sending")
+					return
+				}
+				log.Error("failed to reschedule outside send window", zap.Error(err))
+				return
+			}
+			log.Info("outside send window; rescheduled", zap.Time("scheduled_at", next))
+			return
+		}
+	}
+
+	// Over the recipient's limit: hand it back to the scheduler for when the
+	// limit allows another send. Dry runs reach no one and are not counted.
+	charged := false
+	if w.recipients != nil && !n.DryRun && !w.sandbox {
+		if retryAfter, ok := w.recipients.Allow(n.Channel, n.Recipient); !ok {
+			next := time.Now().Add(retryAfter)
+			if err := w.repo.Reschedule(ctx, n.ID, next.UTC()); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					log.Info("notification cancelled or expired before rescheduling; not sending")
+					return
+				}
+				log.Error("failed to reschedule over recipient limit", zap.Error(err))
+				return
+			}
+			log.Info("recipient limit reached; rescheduled", zap.Time("scheduled_at", next))
+			return
+		}
+		charged = true
+	}
+	// A send given up on before it reaches the provider hands its charge back.
+	refund := func() {
+		if charged {
+			w.recipients.Release(n.Channel, n.Recipient)
+		}
 	}
 
 	// Block here until the per-channel rate limiter grants a token.
 	if err := w.limiter.Wait(ctx, n.Channel); err != nil {
 		// ctx cancelled while waiting — worker is shutting down.
+		refund()
+		w.release(ctx, n.ID, log)
+		return
+	}
+
+	// Claimed only now: under throttling the wait can take many seconds, and
+	// the row stays cancellable until this update moves it to processing.
+	if err := w.repo.ClaimForProcessing(ctx, n.ID); err != nil {
+		refund()
+		if errors.Is(err, domain.ErrNotFound) {
+			log.Info("notification cancelled or expired while waiting for the rate limiter; not sending")
+			return
+		}
+		if ctx.Err() != nil {
+			w.release(ctx, n.ID, log)
+			return
+		}
+		log.Error("failed to mark as processing", zap.Error(err))
 		return
 	}
 
-	resp, err := w.prov.Send(ctx, n)
+	// Claimed: from here the item runs to completion. Shutdown waits for an
+	// in-flight send rather than aborting it, and its outcome is recorded.
+	ctx = context.WithoutCancel(ctx)
+
+	// Checked as late as possible: a stale OTP is worse than none at all.
+	if n.IsExpired(time.Now()) {
+		refund()
+		w.expire(ctx, n)
+		return
+	}
+
+	resp, err := w.send(ctx, n)
 	elapsed := time.Since(start)
 
 	if err != nil {
+		tracing.RecordError(span, err)
 		log.Warn("provider send failed",
 			zap.Error(err),
 			zap.Int("retry_count", n.RetryCount),
 		)
 		w.handleFailure(ctx, n, err)
-		w.onFailed(n.Channel)
+		w.onFailed(n.Channel, n.Priority)
 		return
 	}
 
@@ -117,19 +262,36 @@ func (w *Worker) process(ctx context.Context, item queue.Item) {
 		return
 	}
 
-	// Update batch counters asynchronously if this notification belongs to a batch.
-	if n.BatchID != nil {
-		go func() {
-			if err := w.repo.UpdateBatchCounts(context.Background(), *n.BatchID); err != nil {
-				log.Warn("failed to update batch counts", zap.Error(err))
-			}
-		}()
-	}
+	n.Status = domain.StatusSent
+	n.ProviderMsgID = &resp.MessageID
+	n.SentAt = &now
+	w.batchChanged(ctx, n)
+	w.onTerminal(n)
+	w.publish(ctx, events.TypeSent, n)
 
-	w.onSent(n.Channel, elapsed)
+	w.onSent(n.Channel, n.Priority, elapsed)
+	w.onDelivered(n)
+	if n.RetryCount > 0 {
+		w.onRetry(n.Channel, n.RetryCount, RetrySent)
+	}
 	log.Info("notification sent", zap.String("provider_msg_id", resp.MessageID), zap.Duration("latency", elapsed))
 }
 
+// send hands n to the provider, within sendTimeout when one is set. A dry
+// run, and in sandbox mode every notification, gets a sandbox result
+// instead, so it is marked sent without anything being delivered.
+func (w *Worker) send(ctx context.Context, n *domain.Notification) (*provider.SendResponse, error) {
+	if n.DryRun || w.sandbox {
+		return &provider.SendResponse{MessageID: provider.SandboxMessageID, Status: "sandbox"}, nil
+	}
+	if w.sendTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.sendTimeout)
+		defer cancel()
+	}
+	return w.prov.Send(ctx, n)
+}
+
 // handleFailure either schedules a retry (if retries remain) or marks the
 // notification as permanently failed.
 //
@@ -139,23 +301,119 @@ func (w *Worker) process(ctx context.Context, item queue.Item) {
 //	attempt 1 → backoff[1]  (default 30 s)
 //	attempt 2 → backoff[2]  (default 120 s)
 //	attempt N ≥ len(backoff) → last backoff entry (clamped)
+//
+// A notification with no retries allowed (MaxRetries of zero or less, say
+// from a hand-edited row) fails outright, as does every failure while the
+// backoff schedule is empty.
 func (w *Worker) handleFailure(ctx context.Context, n *domain.Notification, sendErr error) {
-	if n.RetryCount >= n.MaxRetries {
-		if err := w.repo.MarkFailed(ctx, n.ID, sendErr.Error()); err != nil {
+	delay, ok := retryDelay(*w.backoff.Load(), n.RetryCount)
+	if !ok && n.RetryCount < n.MaxRetries {
+		w.logger.Error("no retry backoff configured; failing instead of retrying", zap.String("id", n.ID))
+	}
+	if !ok || n.MaxRetries <= 0 || n.RetryCount >= n.MaxRetries {
+		errMsg := sendErr.Error()
+		if err := w.repo.MarkFailed(ctx, n.ID, errMsg); err != nil {
 			w.logger.Error("failed to mark notification as failed",
 				zap.String("id", n.ID), zap.Error(err))
+			return
+		}
+		n.Status = domain.StatusFailed
+		n.ErrorMessage = &errMsg
+		w.batchChanged(ctx, n)
+		w.onTerminal(n)
+		w.publish(ctx, events.TypeFailed, n)
+		if n.RetryCount > 0 {
+			w.onRetry(n.Channel, n.RetryCount, RetryExhausted)
 		}
 		return
 	}
 
-	idx := n.RetryCount
-	if idx >= len(w.backoff) {
-		idx = len(w.backoff) - 1
+	nextRetry := time.Now().UTC().Add(delay)
+
+	// No point waiting for a retry that would only find the notification expired.
+	if n.IsExpired(nextRetry) {
+		w.expire(ctx, n)
+		return
 	}
-	nextRetry := time.Now().UTC().Add(w.backoff[idx])
 
 	if err := w.repo.ScheduleRetry(ctx, n.ID, n.RetryCount+1, nextRetry, sendErr.Error()); err != nil {
 		w.logger.Error("failed to schedule retry",
 			zap.String("id", n.ID), zap.Error(err))
+		return
+	}
+	n.Status = domain.StatusFailed
+	n.RetryCount++
+	n.NextRetryAt = &nextRetry
+	w.publish(ctx, events.TypeRetryScheduled, n)
+	w.onRetry(n.Channel, n.RetryCount, RetryScheduled)
+}
+
+// retryDelay returns the backoff before retry attempt+1, clamping attempt to
+// the schedule. It reports false when the schedule is empty.
+func retryDelay(backoff []time.Duration, attempt int) (time.Duration, bool) {
+	if len(backoff) == 0 {
+		return 0, false
+	}
+	return backoff[min(max(attempt, 0), len(backoff)-1)], true
+}
+
+// publish hands a lifecycle event for n to the configured publisher.
+func (w *Worker) publish(ctx context.Context, t events.Type, n *domain.Notification) {
+	if err := w.events.Publish(ctx, events.New(ctx, t, n)); err != nil {
+		w.logger.Warn("failed to publish lifecycle event",
+			zap.String("type", string(t)), zap.String("id", n.ID), zap.Error(err))
+	}
+}
+
+// batchChanged reports that n, if it belongs to a batch, has settled. Without
+// an OnBatchChanged hook the counters are updated here, inside the item's
+// processing, so Pool.Wait also waits for the update. It outlives shutdown
+// by up to batchCountTimeout, like the send it follows.
+func (w *Worker) batchChanged(ctx context.Context, n *domain.Notification) {
+	if n.BatchID == nil {
+		return
+	}
+	if w.onBatch != nil {
+		w.onBatch(*n.BatchID)
+		return
+	}
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), batchCountTimeout)
+	defer cancel()
+	if _, err := w.repo.UpdateBatchCounts(updateCtx, *n.BatchID); err != nil {
+		w.logger.Warn("failed to update batch counts", zap.String("batch_id", *n.BatchID), zap.Error(err))
+	}
+}
+
+// releaseTimeout bounds the update that hands back a notification whose
+// processing shutdown interrupted.
+const releaseTimeout = 5 * time.Second
+
+// release puts a notification shutdown took from a worker before it was
+// claimed back to pending, so it is not left queued or processing with nothing
+// holding it. The update outlives ctx by up to releaseTimeout.
+func (w *Worker) release(ctx context.Context, id string, log *zap.Logger) {
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	switch err := w.repo.ReleaseToPending(releaseCtx, id); {
+	case err == nil:
+		log.Info("processing interrupted by shutdown; released to pending")
+	case errors.Is(err, domain.ErrNotFound):
+		// Cancelled or expired meanwhile; nothing to hand back.
+	default:
+		log.Error("failed to release notification interrupted by shutdown", zap.Error(err))
+	}
+}
+
+// expire marks a notification whose deadline has passed as expired and
+// reports it like any other terminal outcome.
+func (w *Worker) expire(ctx context.Context, n *domain.Notification) {
+	log := w.logger.With(zap.String("notification_id", n.ID))
+	if err := w.repo.MarkExpired(ctx, n.ID); err != nil {
+		log.Error("failed to mark notification as expired", zap.Error(err))
+		return
 	}
+	n.Status = domain.StatusExpired
+	w.batchChanged(ctx, n)
+	w.onTerminal(n)
+	log.Info("notification expired before delivery", zap.Timep("expires_at", n.ExpiresAt))
 }
@@ -0,0 +1,1472 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/ricirt/event-driven-arch/internal/config"
+	"github.com/ricirt/event-driven-arch/internal/domain"
+	"github.com/ricirt/event-driven-arch/internal/events"
+	"github.com/ricirt/event-driven-arch/internal/provider"
+	"github.com/ricirt/event-driven-arch/internal/queue"
+	"github.com/ricirt/event-driven-arch/internal/ratelimiter"
+	"github.com/ricirt/event-driven-arch/internal/repository"
+	"github.com/ricirt/event-driven-arch/internal/tracing"
+)
+
+// stubProvider counts sends and fails them when err is set.
+type stubProvider struct {
+	sends int
+	err   error
+	span  trace.SpanContext // of the last send's context
+
+	correlationID string // of the last send's context
+}
+
+func (p *stubProvider) Send(ctx context.Context, _ *domain.Notification) (*provider.SendResponse, error) {
+	p.sends++
+	p.span = trace.SpanContextFromContext(ctx)
+	p.correlationID = domain.CorrelationIDFromContext(ctx)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &provider.SendResponse{MessageID: "msg-1"}, nil
+}
+
+func newTestWorker(repo repository.NotificationRepository, prov provider.Provider, terminal *[]domain.Status) *Worker {
+	return NewWorker(1, queue.New(), repo, prov, ratelimiter.New(100, nil),
+		[]time.Duration{time.Hour}, zap.NewNop(),
+		Hooks{OnTerminal: func(n *domain.Notification) { *terminal = append(*terminal, n.Status) }})
+}
+
+func createNotification(t *testing.T, repo repository.NotificationRepository, expiresAt time.Time) *domain.Notification {
+	t.Helper()
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Your code is 123456",
+		Priority: domain.PriorityHigh, Status: domain.StatusQueued, MaxRetries: 3, ExpiresAt: &expiresAt,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWorker_ExpiredNotificationIsNotSent(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	n := createNotification(t, repo, time.Now().Add(-time.Second))
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if prov.sends != 0 {
+		t.Fatalf("expected no provider call, got %d", prov.sends)
+	}
+	got, _ := repo.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusExpired {
+		t.Fatalf("expected status expired, got %s", got.Status)
+	}
+	if len(terminal) != 1 || terminal[0] != domain.StatusExpired {
+		t.Fatalf("expected one expired terminal event, got %v", terminal)
+	}
+}
+
+// slowLimiter stands in for a throttled limiter: duringWait runs while the
+// worker is blocked in Wait, before the token is granted.
+type slowLimiter struct {
+	duringWait func()
+}
+
+func (l slowLimiter) Wait(context.Context, domain.Channel) error {
+	l.duringWait()
+	return nil
+}
+
+func TestWorker_CancelledDuringRateLimitWaitIsNotSent(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+
+	var cancelErr error
+	w.limiter = slowLimiter{duringWait: func() {
+		cancelErr = repo.Cancel(context.Background(), n.ID, domain.Cancellation{At: time.Now()})
+	}}
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if cancelErr != nil {
+		t.Fatalf("expected the cancel during the wait to succeed, got %v", cancelErr)
+	}
+	if prov.sends != 0 {
+		t.Fatalf("expected no provider call after the cancel, got %d", prov.sends)
+	}
+	got, _ := repo.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the notification to stay cancelled, got %s", got.Status)
+	}
+	if len(terminal) != 0 {
+		t.Fatalf("expected no terminal event from the worker, got %v", terminal)
+	}
+}
+
+// ctxRepo fails reads and claims once their context is done, as the
+// database would.
+type ctxRepo struct {
+	*repository.MockNotificationRepository
+}
+
+func (r ctxRepo) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.MockNotificationRepository.GetByID(ctx, id)
+}
+
+func (r ctxRepo) ClaimForProcessing(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.MockNotificationRepository.ClaimForProcessing(ctx, id)
+}
+
+// cancellingLimiter ends the worker's context mid-wait and fails with it.
+type cancellingLimiter struct {
+	cancel context.CancelFunc
+}
+
+func (l cancellingLimiter) Wait(ctx context.Context, _ domain.Channel) error {
+	l.cancel()
+	return ctx.Err()
+}
+
+func TestWorker_ShutdownReleasesUnsentNotification(t *testing.T) {
+	for _, stage := range []string{"fetch", "rate limit wait"} {
+		t.Run(stage, func(t *testing.T) {
+			repo := ctxRepo{repository.NewMockNotificationRepository()}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var terminal []domain.Status
+			var failed int
+			w := NewWorker(1, queue.New(), repo, &stubProvider{}, ratelimiter.New(100, nil),
+				[]time.Duration{time.Hour}, zap.NewNop(), Hooks{
+					OnTerminal: func(n *domain.Notification) { terminal = append(terminal, n.Status) },
+					OnFailed:   func(domain.Channel, domain.Priority) { failed++ },
+				})
+			n := createNotification(t, repo, time.Now().Add(time.Hour))
+
+			switch stage {
+			case "fetch":
+				cancel()
+			case "rate limit wait":
+				w.limiter = cancellingLimiter{cancel: cancel}
+			}
+			w.process(ctx, queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+			got, err := repo.GetByID(context.Background(), n.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != domain.StatusPending || got.RetryCount != 0 {
+				t.Fatalf("expected the notification back to pending with no attempt spent, got %s (retry_count=%d)", got.Status, got.RetryCount)
+			}
+			if len(terminal) != 0 || failed != 0 {
+				t.Fatalf("expected no outcome reported, got terminal=%v failed=%d", terminal, failed)
+			}
+		})
+	}
+}
+
+// slowProvider holds every send until release is closed, failing it if its
+// context ends first.
+type slowProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p slowProvider) Send(ctx context.Context, _ *domain.Notification) (*provider.SendResponse, error) {
+	close(p.started)
+	select {
+	case <-p.release:
+		return &provider.SendResponse{MessageID: "msg-1"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestPool_ShutdownLetsInFlightSendFinish(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := slowProvider{started: make(chan struct{}), release: make(chan struct{})}
+	q := queue.New()
+	cfg := &config.Config{SMSWorkers: 1, RetryBackoff: []time.Duration{time.Hour}, ProviderTimeout: time.Minute}
+	p := NewPool(cfg, q, repo, prov, ratelimiter.New(100, nil), zap.NewNop(), Hooks{})
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+	if err := q.Enqueue(queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Start(ctx)
+	<-prov.started
+	cancel() // shutdown begins mid-send
+	time.AfterFunc(20*time.Millisecond, func() { close(prov.release) })
+	p.Wait()
+
+	got, err := repo.GetByID(context.Background(), n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.StatusSent {
+		t.Fatalf("expected the in-flight send to complete and be marked sent, got %s", got.Status)
+	}
+}
+
+func TestWorker_FailureWithoutRetriesFailsOutright(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		backoff    []time.Duration
+		maxRetries int
+		retryCount int
+	}{
+		{"no retries allowed", []time.Duration{time.Hour}, 0, 0},
+		{"negative max retries", []time.Duration{time.Hour}, -1, 0},
+		{"retry count past max", []time.Duration{time.Hour}, 2, 5},
+		{"empty backoff", nil, 3, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := repository.NewMockNotificationRepository()
+			w := NewWorker(1, queue.New(), repo, &stubProvider{err: errors.New("provider down")}, ratelimiter.New(100, nil),
+				tc.backoff, zap.NewNop(), Hooks{})
+			n := &domain.Notification{
+				ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello",
+				Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: tc.maxRetries, RetryCount: tc.retryCount,
+			}
+			if err := repo.Create(context.Background(), n); err != nil {
+				t.Fatal(err)
+			}
+
+			w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+			got, err := repo.GetByID(context.Background(), n.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != domain.StatusFailed || got.NextRetryAt != nil {
+				t.Fatalf("expected failed with no retry scheduled, got %s (next_retry_at=%v)", got.Status, got.NextRetryAt)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	backoff := []time.Duration{time.Second, time.Minute}
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{-1, time.Second},
+		{0, time.Second},
+		{1, time.Minute},
+		{7, time.Minute},
+	} {
+		if got, ok := retryDelay(backoff, tc.attempt); !ok || got != tc.want {
+			t.Errorf("attempt %d: expected %s, got %s (ok=%v)", tc.attempt, tc.want, got, ok)
+		}
+	}
+	if _, ok := retryDelay(nil, 0); ok {
+		t.Error("expected no delay from an empty schedule")
+	}
+}
+
+func TestWorker_RetryPastDeadlineExpires(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{err: errors.New("provider down")}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	// The next retry is an hour away, long after the deadline.
+	n := createNotification(t, repo, time.Now().Add(time.Minute))
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	got, _ := repo.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusExpired || got.NextRetryAt != nil {
+		t.Fatalf("expected expired without a pending retry, got %s (next_retry_at=%v)", got.Status, got.NextRetryAt)
+	}
+	if len(terminal) != 1 || terminal[0] != domain.StatusExpired {
+		t.Fatalf("expected one expired terminal event, got %v", terminal)
+	}
+}
+
+// countingRepo counts UpdateBatchCounts calls.
+type countingRepo struct {
+	*repository.MockNotificationRepository
+	updates int
+}
+
+func (r *countingRepo) UpdateBatchCounts(ctx context.Context, batchID string) (*domain.Batch, error) {
+	r.updates++
+	return r.MockNotificationRepository.UpdateBatchCounts(ctx, batchID)
+}
+
+func TestBatchCounter_CoalescesTouches(t *testing.T) {
+	repo := &countingRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	batchID := "b1"
+	members := []*domain.Notification{
+		{ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+		{ID: "n-2", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+	}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+	repo.MarkSent(context.Background(), "n-1", "msg-1", time.Now()) //nolint:errcheck
+
+	var got []domain.Batch
+	c := NewBatchCounter(repo, time.Hour, 0, func(b *domain.Batch) { got = append(got, *b) }, nil, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		c.Touch(batchID)
+	}
+	c.Flush(context.Background())
+
+	if repo.updates != 1 {
+		t.Fatalf("expected 1 counter update, got %d", repo.updates)
+	}
+	if len(got) != 1 || got[0].Sent != 1 || got[0].Pending != 1 {
+		t.Fatalf("unexpected snapshots: %+v", got)
+	}
+
+	c.Flush(context.Background())
+	if repo.updates != 1 {
+		t.Fatalf("expected an untouched batch not to be updated again, got %d updates", repo.updates)
+	}
+}
+
+func TestBatchCounter_ReportsCompletionOnce(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	batchID := "b1"
+	members := []*domain.Notification{
+		{ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+		{ID: "n-2", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued},
+	}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+
+	var completed []domain.Batch
+	c := NewBatchCounter(repo, time.Hour, 0, nil, func(b *domain.Batch) { completed = append(completed, *b) }, zap.NewNop())
+
+	repo.MarkSent(context.Background(), "n-1", "msg-1", time.Now()) //nolint:errcheck
+	c.Touch(batchID)
+	c.Flush(context.Background())
+	if len(completed) != 0 {
+		t.Fatalf("expected no completion while a member is pending, got %+v", completed)
+	}
+
+	repo.MarkSent(context.Background(), "n-2", "msg-2", time.Now()) //nolint:errcheck
+	c.Touch(batchID)
+	c.Flush(context.Background())
+	if len(completed) != 1 || completed[0].CompletedAt == nil || completed[0].Sent != 2 {
+		t.Fatalf("expected one completion with both members sent, got %+v", completed)
+	}
+
+	c.Touch(batchID)
+	c.Flush(context.Background())
+	if len(completed) != 1 {
+		t.Fatalf("expected a completed batch not to be reported again, got %d completions", len(completed))
+	}
+}
+
+// interruptedRepo holds the first UpdateBatchCounts until its context ends,
+// as a flush cut short by shutdown would be, and counts calls made after
+// closed is set, when a real pool would already be closed.
+type interruptedRepo struct {
+	*repository.MockNotificationRepository
+	started   chan struct{}
+	once      sync.Once
+	closed    atomic.Bool
+	lateCalls atomic.Int32
+}
+
+func (r *interruptedRepo) UpdateBatchCounts(ctx context.Context, batchID string) (*domain.Batch, error) {
+	if r.closed.Load() {
+		r.lateCalls.Add(1)
+		return nil, errors.New("closed pool")
+	}
+	first := false
+	r.once.Do(func() { first = true; close(r.started) })
+	if first {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return r.MockNotificationRepository.UpdateBatchCounts(ctx, batchID)
+}
+
+func TestBatchCounter_RunDrainsBeforeReturning(t *testing.T) {
+	repo := &interruptedRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), started: make(chan struct{})}
+	batchID := "b1"
+	members := []*domain.Notification{{ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Status: domain.StatusQueued}}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+	repo.MarkSent(context.Background(), "n-1", "msg-1", time.Now()) //nolint:errcheck
+
+	var mu sync.Mutex
+	var got []domain.Batch
+	c := NewBatchCounter(repo, time.Millisecond, time.Second, func(b *domain.Batch) {
+		mu.Lock()
+		got = append(got, *b)
+		mu.Unlock()
+	}, nil, zap.NewNop())
+	c.Touch(batchID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	<-repo.started
+	cancel() // shutdown begins while the update is in flight
+	<-done
+	repo.closed.Store(true) // main closes the pool once Run has returned
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0].Sent != 1 || got[0].Pending != 0 {
+		t.Fatalf("expected the interrupted batch recounted before Run returned, got %+v", got)
+	}
+	if n := repo.lateCalls.Load(); n != 0 {
+		t.Fatalf("expected no update after the pool closed, got %d", n)
+	}
+}
+
+func TestWorker_DefaultBatchUpdateFinishesWithItem(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	w := NewWorker(1, queue.New(), repo, &stubProvider{}, ratelimiter.New(100, nil),
+		[]time.Duration{time.Hour}, zap.NewNop(), Hooks{})
+	batchID := "b1"
+	members := []*domain.Notification{{
+		ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Recipient: "+905551234567",
+		Content: "Hello", Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3,
+	}}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shutdown has begun by the time the item settles; the count still lands.
+	ctx, cancel := context.WithCancel(context.Background())
+	w.limiter = slowLimiter{duringWait: cancel}
+	w.process(ctx, queue.Item{NotificationID: "n-1", Channel: domain.ChannelSMS})
+
+	b, err := repo.GetBatchByID(context.Background(), batchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Sent != 1 || b.Pending != 0 {
+		t.Fatalf("expected the counters updated when process returned, got %+v", b)
+	}
+}
+
+// flakyProvider fails the notifications whose content is "fail". It keeps no
+// state, so concurrent workers can share it.
+type flakyProvider struct{}
+
+func (flakyProvider) Send(_ context.Context, n *domain.Notification) (*provider.SendResponse, error) {
+	if n.Content == "fail" {
+		return nil, errors.New("provider rejected")
+	}
+	return &provider.SendResponse{MessageID: "msg-" + n.ID}, nil
+}
+
+func TestBatchCounter_ReconcilesConcurrentCompletions(t *testing.T) {
+	const size, workers = 200, 8
+	repo := repository.NewMockNotificationRepository()
+	batchID := "b1"
+	members := make([]*domain.Notification, size)
+	for i := range members {
+		content := "hello"
+		if i%5 == 0 {
+			content = "fail"
+		}
+		members[i] = &domain.Notification{
+			ID: fmt.Sprintf("n-%d", i), BatchID: &batchID, Channel: domain.ChannelSMS, Recipient: "+905551234567",
+			Content: content, Priority: domain.PriorityNormal, Status: domain.StatusQueued,
+		}
+	}
+	if err := repo.CreateBatch(context.Background(), &domain.Batch{ID: batchID}, members); err != nil {
+		t.Fatal(err)
+	}
+
+	var completions atomic.Int32
+	counter := NewBatchCounter(repo, time.Millisecond, 0, nil, func(*domain.Batch) { completions.Add(1) }, zap.NewNop())
+	counterCtx, stopCounter := context.WithCancel(context.Background())
+	counterDone := make(chan struct{})
+	go func() {
+		defer close(counterDone)
+		counter.Run(counterCtx)
+	}()
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		worker := NewWorker(w, queue.New(), repo, flakyProvider{}, nil, []time.Duration{time.Hour}, zap.NewNop(),
+			Hooks{OnBatchChanged: counter.Touch})
+		worker.limiter = slowLimiter{duringWait: func() {}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < size; i += workers {
+				worker.process(context.Background(), queue.Item{NotificationID: members[i].ID, Channel: domain.ChannelSMS})
+			}
+		}()
+	}
+	// Cancellations race the workers; each member ends up either way.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 3; i < size; i += 7 {
+			if err := repo.Cancel(context.Background(), members[i].ID, domain.Cancellation{At: time.Now()}); err == nil {
+				counter.Touch(batchID)
+			}
+		}
+	}()
+	wg.Wait()
+	stopCounter()
+	<-counterDone
+
+	b, err := repo.GetBatchByID(context.Background(), batchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Pending != 0 || b.Pending+b.Sent+b.Failed+b.Cancelled+b.Expired != b.Total || b.Total != size {
+		t.Fatalf("expected counters adding up to %d with none pending, got %+v", size, b)
+	}
+	if b.Failed == 0 || b.Cancelled == 0 || b.Sent == 0 {
+		t.Fatalf("expected sent, failed and cancelled members, got %+v", b)
+	}
+	if n := completions.Load(); n != 1 {
+		t.Fatalf("expected the completion reported once, got %d", n)
+	}
+}
+
+func TestWorker_SentBatchMemberTouchesBatch(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	var touched []string
+	w := NewWorker(1, queue.New(), repo, &stubProvider{}, ratelimiter.New(100, nil),
+		[]time.Duration{time.Hour}, zap.NewNop(),
+		Hooks{OnBatchChanged: func(id string) { touched = append(touched, id) }})
+	batchID := "b1"
+	n := &domain.Notification{
+		ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Recipient: "+905551234567",
+		Content: "Hello", Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if len(touched) != 1 || touched[0] != batchID {
+		t.Fatalf("expected batch %q touched once, got %v", batchID, touched)
+	}
+}
+
+func TestWorker_ExpiredBatchMemberTouchesBatch(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	var touched []string
+	w := NewWorker(1, queue.New(), repo, prov, ratelimiter.New(100, nil),
+		[]time.Duration{time.Hour}, zap.NewNop(),
+		Hooks{OnBatchChanged: func(id string) { touched = append(touched, id) }})
+	batchID := "b1"
+	past := time.Now().Add(-time.Minute)
+	n := &domain.Notification{
+		ID: "n-1", BatchID: &batchID, Channel: domain.ChannelSMS, Recipient: "+905551234567",
+		Content: "Hello", Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3,
+		ExpiresAt: &past,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if prov.sends != 0 {
+		t.Fatal("expected the expired member not to be sent")
+	}
+	if len(touched) != 1 || touched[0] != batchID {
+		t.Fatalf("expected batch %q touched once, got %v", batchID, touched)
+	}
+}
+
+func TestWorker_BatchSettlesWhenLastMemberExhaustsRetries(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.New()
+	w := NewWorker(1, q, repo, &stubProvider{err: errors.New("provider down")}, ratelimiter.New(100, nil),
+		[]time.Duration{0}, zap.NewNop(), Hooks{})
+	rw := NewRetryWorker(repo, q, time.Hour, 10, events.Nop{}, RetryHooks{}, zap.NewNop())
+	batch := &domain.Batch{ID: "b1", Total: 1}
+	n := &domain.Notification{
+		ID: "n-1", BatchID: &batch.ID, Channel: domain.ChannelSMS, Recipient: "+905551234567",
+		Content: "Hello", Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 1,
+	}
+	if err := repo.CreateBatch(context.Background(), batch, []*domain.Notification{n}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+	rw.poll(context.Background())
+	if _, queued, _ := q.Depths(); queued != 1 {
+		t.Fatalf("expected the last retry to be claimed, got %d queued", queued)
+	}
+	item, _ := q.Dequeue(context.Background())
+	w.process(context.Background(), item)
+
+	got, _, err := repo.GetBatch(context.Background(), batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Pending != 0 || got.Failed != 1 {
+		t.Fatalf("expected the exhausted member counted as failed, got %+v", got)
+	}
+}
+
+func TestWorker_ContinuesTraceFromQueueItem(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+
+	enqueue, traceParent := tracing.StartEnqueue(context.Background(), n.ID)
+	enqueue.End()
+	w.process(context.Background(), queue.Item{
+		NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority, TraceParent: traceParent,
+	})
+
+	spans := rec.Ended()
+	if len(spans) != 2 || spans[1].Name() != "worker.process" {
+		t.Fatalf("expected enqueue and worker spans, got %d", len(spans))
+	}
+	work := spans[1]
+	if work.Parent().SpanID() != enqueue.SpanContext().SpanID() {
+		t.Fatal("expected the worker span to be a child of the enqueue span")
+	}
+	if prov.span.SpanID() != work.SpanContext().SpanID() {
+		t.Fatal("expected the provider to be called within the worker span")
+	}
+}
+
+func TestWorker_CarriesCorrelationID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	w := NewWorker(1, queue.New(), repo, prov, ratelimiter.New(100, nil),
+		[]time.Duration{time.Hour}, zap.New(core), Hooks{})
+	correlationID := "corr-1"
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "hi",
+		Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3, CorrelationID: &correlationID,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if prov.correlationID != "corr-1" {
+		t.Fatalf("expected the send context to carry corr-1, got %q", prov.correlationID)
+	}
+	sent := logs.FilterMessage("notification sent").All()
+	if len(sent) != 1 || sent[0].ContextMap()["correlation_id"] != "corr-1" {
+		t.Fatalf("expected the sent log line to carry corr-1, got %+v", sent)
+	}
+}
+
+func TestWorker_DryRunNeverReachesProvider(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		dryRun, sandbox bool
+	}{
+		{"dry run", true, false},
+		{"sandbox mode", false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := repository.NewMockNotificationRepository()
+			prov := &stubProvider{}
+			var terminal []domain.Status
+			w := newTestWorker(repo, prov, &terminal)
+			w.sandbox = tc.sandbox
+			n := &domain.Notification{
+				ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "hi",
+				Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3, DryRun: tc.dryRun,
+			}
+			if err := repo.Create(context.Background(), n); err != nil {
+				t.Fatal(err)
+			}
+
+			w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+			if prov.sends != 0 {
+				t.Fatalf("expected no provider call, got %d", prov.sends)
+			}
+			got, _ := repo.GetByID(context.Background(), n.ID)
+			if got.Status != domain.StatusSent || got.ProviderMsgID == nil || *got.ProviderMsgID != provider.SandboxMessageID {
+				t.Fatalf("expected sent with the sandbox message ID, got %s %v", got.Status, got.ProviderMsgID)
+			}
+			if len(terminal) != 1 || terminal[0] != domain.StatusSent {
+				t.Fatalf("expected one sent terminal event, got %v", terminal)
+			}
+		})
+	}
+}
+
+// requeue moves a notification back to queued, as the retry worker's claim
+// does before it puts a due retry on the queue.
+func requeue(t *testing.T, repo repository.NotificationRepository, id string) {
+	t.Helper()
+	if err := repo.UpdateStatus(context.Background(), id, domain.StatusQueued); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorker_ReportsRetryOutcomes(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{err: errors.New("provider down")}
+	var got []string
+	w := NewWorker(1, queue.New(), repo, prov, ratelimiter.New(100, nil),
+		[]time.Duration{time.Millisecond}, zap.NewNop(),
+		Hooks{OnRetry: func(ch domain.Channel, attempt int, outcome string) {
+			got = append(got, fmt.Sprintf("%s/%d/%s", ch, attempt, outcome))
+		}})
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+	item := queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority}
+
+	w.process(context.Background(), item) // first attempt fails: retry 1 scheduled
+	requeue(t, repo, n.ID)
+	w.process(context.Background(), item) // retry 1 fails: retry 2 scheduled
+	prov.err = nil
+	requeue(t, repo, n.ID)
+	w.process(context.Background(), item) // retry 2 delivers
+
+	want := []string{"sms/1/scheduled", "sms/2/scheduled", "sms/2/sent"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWorker_ReportsExhaustedRetriesOnly(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{err: errors.New("provider down")}
+	var got []string
+	w := NewWorker(1, queue.New(), repo, prov, ratelimiter.New(100, nil),
+		[]time.Duration{time.Millisecond}, zap.NewNop(),
+		Hooks{OnRetry: func(_ domain.Channel, attempt int, outcome string) {
+			got = append(got, fmt.Sprintf("%d/%s", attempt, outcome))
+		}})
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+	repo.ScheduleRetry(context.Background(), n.ID, 3, time.Now(), "earlier failure") //nolint:errcheck
+	requeue(t, repo, n.ID)
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	if want := []string{"3/exhausted"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRetryWorker_ReportsDueAndWait(t *testing.T) {
+	failedAt := time.Now().Add(-time.Minute)
+	repo := repository.NewMockNotificationRepository()
+	for _, id := range []string{"n-1", "n-2"} {
+		n := &domain.Notification{
+			ID: id, Channel: domain.ChannelEmail, Priority: domain.PriorityLow,
+			Status: domain.StatusFailed, RetryCount: 1, MaxRetries: 3, UpdatedAt: failedAt, NextRetryAt: &failedAt,
+		}
+		if err := repo.Create(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var due int
+	var waits []time.Duration
+	var full []queue.Source
+	rw := NewRetryWorker(repo, queue.NewWithCapacity(0, 0, 1), time.Hour, 10, events.Nop{}, RetryHooks{
+		OnQueueFull: func(_ domain.Priority, src queue.Source) { full = append(full, src) },
+		OnDue:       func(count int) { due = count },
+		OnRequeued: func(ch domain.Channel, wait time.Duration) {
+			if ch != domain.ChannelEmail {
+				t.Errorf("unexpected channel %s", ch)
+			}
+			waits = append(waits, wait)
+		},
+	}, zap.NewNop())
+	rw.poll(context.Background())
+
+	if due != 2 {
+		t.Fatalf("expected 2 due retries, got %d", due)
+	}
+	// One low-priority slot: the first is requeued, the second finds the queue full.
+	if len(waits) != 1 || waits[0] < time.Minute {
+		t.Fatalf("expected one wait of at least a minute, got %v", waits)
+	}
+	if len(full) != 1 || full[0] != queue.SourceRetry {
+		t.Fatalf("expected one queue-full report from retry, got %v", full)
+	}
+}
+
+// flakyScheduledRepo fails to find due scheduled notifications while err is
+// set.
+type flakyScheduledRepo struct {
+	*repository.MockNotificationRepository
+	err error
+}
+
+func (r *flakyScheduledRepo) FindDueScheduled(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.MockNotificationRepository.FindDueScheduled(ctx, limit)
+}
+
+func TestSchedulerWorker_ReportsPolls(t *testing.T) {
+	repo := &flakyScheduledRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	past := time.Now().Add(-time.Minute)
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Priority: domain.PriorityNormal,
+		Status: domain.StatusScheduled, ScheduledAt: &past,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	type poll struct {
+		found int
+		err   error
+	}
+	var polls []poll
+	sw := NewSchedulerWorker(repo, queue.New(), time.Hour, 10, events.Nop{}, SchedulerHooks{
+		OnPoll: func(found int, _ time.Duration, err error) { polls = append(polls, poll{found, err}) },
+	}, zap.NewNop())
+
+	repo.err = errors.New("connection refused")
+	sw.poll(context.Background())
+	repo.err = nil
+	sw.poll(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sw.poll(ctx)
+
+	if len(polls) != 2 {
+		t.Fatalf("expected 2 reported polls (shutdown poll skipped), got %+v", polls)
+	}
+	if polls[0].err == nil || polls[1].err != nil || polls[1].found != 1 {
+		t.Fatalf("expected a failed poll then one finding 1 item, got %+v", polls)
+	}
+}
+
+func TestSchedulerWorker_OldestFirstAndLeavesOverflowScheduled(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	for i := 0; i < 5; i++ {
+		due := time.Now().Add(-time.Duration(5-i) * time.Hour) // n-0 is the most overdue
+		n := &domain.Notification{
+			ID: fmt.Sprintf("n-%d", i), Channel: domain.ChannelSMS, Priority: domain.PriorityNormal,
+			Status: domain.StatusScheduled, ScheduledAt: &due,
+		}
+		if err := repo.Create(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := queue.NewWithCapacity(0, 2, 0)
+	overdue := -1
+	sw := NewSchedulerWorker(repo, q, time.Hour, 10, events.Nop{}, SchedulerHooks{
+		OnOverdue: func(count int) { overdue = count },
+	}, zap.NewNop())
+
+	sw.poll(context.Background())
+
+	for _, want := range []string{"n-0", "n-1"} {
+		if item, _ := q.Dequeue(context.Background()); item.NotificationID != want {
+			t.Fatalf("expected %s next, got %s", want, item.NotificationID)
+		}
+	}
+	if overdue != 3 {
+		t.Fatalf("expected 3 still overdue, got %d", overdue)
+	}
+	for _, id := range []string{"n-2", "n-3", "n-4"} {
+		got, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != domain.StatusScheduled || got.ScheduledAt == nil || time.Since(*got.ScheduledAt) < time.Hour {
+			t.Fatalf("expected %s left scheduled at its original time, got %s at %v", id, got.Status, got.ScheduledAt)
+		}
+	}
+}
+
+// claimOrderRepo records, for each scheduled notification claimed, whether it
+// was already on the queue, and cancels every due notification it finds, as a
+// DELETE landing after the lookup would, when cancelFound is set.
+type claimOrderRepo struct {
+	*repository.MockNotificationRepository
+	q             *queue.PriorityQueue
+	cancelFound   bool
+	queuedAtClaim []bool
+}
+
+func (r *claimOrderRepo) FindDueScheduled(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	due, err := r.MockNotificationRepository.FindDueScheduled(ctx, limit)
+	if r.cancelFound {
+		for _, n := range due {
+			r.MockNotificationRepository.Cancel(ctx, n.ID, domain.Cancellation{At: time.Now()}) //nolint:errcheck // mock never fails
+		}
+	}
+	return due, err
+}
+
+func (r *claimOrderRepo) ClaimScheduled(ctx context.Context, id string) error {
+	r.queuedAtClaim = append(r.queuedAtClaim, r.q.Queued(id))
+	return r.MockNotificationRepository.ClaimScheduled(ctx, id)
+}
+
+func createDueScheduled(t *testing.T, repo repository.NotificationRepository) *domain.Notification {
+	t.Helper()
+	past := time.Now().Add(-time.Minute)
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Priority: domain.PriorityNormal,
+		Status: domain.StatusScheduled, ScheduledAt: &past,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSchedulerWorker_ClaimsBeforeEnqueueing(t *testing.T) {
+	q := queue.New()
+	repo := &claimOrderRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), q: q}
+	n := createDueScheduled(t, repo)
+	sw := NewSchedulerWorker(repo, q, time.Hour, 10, events.Nop{}, SchedulerHooks{}, zap.NewNop())
+
+	sw.poll(context.Background())
+
+	if len(repo.queuedAtClaim) != 1 || repo.queuedAtClaim[0] {
+		t.Fatalf("expected one claim made before enqueueing, got %v", repo.queuedAtClaim)
+	}
+	// A worker taking the item straight away finds it claimable, and nothing
+	// the scheduler does afterwards moves it back.
+	item, _ := q.Dequeue(context.Background())
+	if err := repo.ClaimForProcessing(context.Background(), item.NotificationID); err != nil {
+		t.Fatalf("expected the queued row claimable by a worker, got %v", err)
+	}
+	sw.poll(context.Background())
+	if got, _ := repo.GetByID(context.Background(), n.ID); got.Status != domain.StatusProcessing {
+		t.Fatalf("expected the row left processing, got %s", got.Status)
+	}
+}
+
+func TestSchedulerWorker_SkipsRowsCancelledSinceLookup(t *testing.T) {
+	q := queue.New()
+	repo := &claimOrderRepo{MockNotificationRepository: repository.NewMockNotificationRepository(), q: q, cancelFound: true}
+	n := createDueScheduled(t, repo)
+	sw := NewSchedulerWorker(repo, q, time.Hour, 10, events.Nop{}, SchedulerHooks{}, zap.NewNop())
+
+	sw.poll(context.Background())
+
+	if high, normal, low := q.Depths(); high+normal+low != 0 {
+		t.Fatalf("expected nothing enqueued, got %d/%d/%d", high, normal, low)
+	}
+	if got, _ := repo.GetByID(context.Background(), n.ID); got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", got.Status)
+	}
+}
+
+// pageCountingRepo counts ClaimDueRetries calls.
+type pageCountingRepo struct {
+	*repository.MockNotificationRepository
+	pages int
+}
+
+func (r *pageCountingRepo) ClaimDueRetries(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	r.pages++
+	return r.MockNotificationRepository.ClaimDueRetries(ctx, limit)
+}
+
+func createDueRetries(t *testing.T, repo repository.NotificationRepository, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		due := time.Now().Add(-time.Duration(count-i) * time.Minute) // n-0 is the oldest
+		n := &domain.Notification{
+			ID: fmt.Sprintf("n-%d", i), Channel: domain.ChannelSMS, Priority: domain.PriorityNormal,
+			Status: domain.StatusFailed, RetryCount: 1, MaxRetries: 3, NextRetryAt: &due,
+		}
+		if err := repo.Create(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRetryWorker_DrainsBacklogInPages(t *testing.T) {
+	repo := &pageCountingRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	createDueRetries(t, repo, 25)
+	q := queue.New()
+	var due int
+	rw := NewRetryWorker(repo, q, time.Hour, 10, events.Nop{}, RetryHooks{OnDue: func(n int) { due = n }}, zap.NewNop())
+
+	rw.poll(context.Background())
+
+	if _, queued, _ := q.Depths(); repo.pages != 3 || due != 25 || queued != 25 {
+		t.Fatalf("expected 3 pages draining all 25 retries, got %d pages, %d due, %d queued", repo.pages, due, queued)
+	}
+	if item, _ := q.Dequeue(context.Background()); item.NotificationID != "n-0" {
+		t.Fatalf("expected the oldest retry first, got %s", item.NotificationID)
+	}
+}
+
+func TestRetryWorker_StopsPagingWhenQueueFull(t *testing.T) {
+	repo := &pageCountingRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	createDueRetries(t, repo, 25)
+	rw := NewRetryWorker(repo, queue.NewWithCapacity(0, 5, 0), time.Hour, 10, events.Nop{}, RetryHooks{}, zap.NewNop())
+
+	rw.poll(context.Background())
+
+	if repo.pages != 1 {
+		t.Fatalf("expected paging to stop at the full queue, read %d pages", repo.pages)
+	}
+	left, _ := repo.ClaimDueRetries(context.Background(), 100)
+	if len(left) != 20 {
+		t.Fatalf("expected 20 retries left for the next poll, got %d", len(left))
+	}
+}
+
+// lostClaimRepo claims due retries but then loses the write that moved them
+// to queued, the way a failed status update after enqueueing used to, so the
+// next poll finds the same rows due again.
+type lostClaimRepo struct {
+	*repository.MockNotificationRepository
+}
+
+func (r *lostClaimRepo) ClaimDueRetries(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	claimed, err := r.MockNotificationRepository.ClaimDueRetries(ctx, limit)
+	for _, n := range claimed {
+		r.MockNotificationRepository.UpdateStatus(ctx, n.ID, domain.StatusFailed) //nolint:errcheck // mock never fails
+	}
+	return claimed, err
+}
+
+// cancelAfterClaimRepo cancels every retry it claims, the way a client's
+// cancel landing between the claim and the enqueue would.
+type cancelAfterClaimRepo struct {
+	*repository.MockNotificationRepository
+}
+
+func (r *cancelAfterClaimRepo) ClaimDueRetries(ctx context.Context, limit int) ([]*domain.Notification, error) {
+	claimed, err := r.MockNotificationRepository.ClaimDueRetries(ctx, limit)
+	for _, n := range claimed {
+		r.MockNotificationRepository.Cancel(ctx, n.ID, domain.Cancellation{At: time.Now()}) //nolint:errcheck // mock never fails
+	}
+	return claimed, err
+}
+
+func TestRetryWorker_QueueFullRevertKeepsCancel(t *testing.T) {
+	repo := &cancelAfterClaimRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	createDueRetries(t, repo, 1)
+	rw := NewRetryWorker(repo, queue.NewWithCapacity(0, 0, 0), time.Hour, 10, events.Nop{}, RetryHooks{}, zap.NewNop())
+
+	rw.poll(context.Background())
+
+	got, _ := repo.GetByID(context.Background(), "n-0")
+	if got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", got.Status)
+	}
+}
+
+func TestRetryWorker_LostStatusWriteDoesNotDoubleEnqueue(t *testing.T) {
+	repo := &lostClaimRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	createDueRetries(t, repo, 3)
+	q := queue.New()
+	var requeued int
+	rw := NewRetryWorker(repo, q, time.Hour, 10, events.Nop{}, RetryHooks{
+		OnRequeued: func(domain.Channel, time.Duration) { requeued++ },
+	}, zap.NewNop())
+
+	rw.poll(context.Background())
+	rw.poll(context.Background())
+
+	if _, queued, _ := q.Depths(); queued != 3 || requeued != 3 {
+		t.Fatalf("expected each retry enqueued once, got %d queued, %d requeued", queued, requeued)
+	}
+}
+
+func TestRetryWorker_ClaimedRetriesAreNotFoundAgain(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	createDueRetries(t, repo, 3)
+	q := queue.New()
+	var due []int
+	rw := NewRetryWorker(repo, q, time.Hour, 10, events.Nop{}, RetryHooks{
+		OnDue: func(n int) { due = append(due, n) },
+	}, zap.NewNop())
+
+	rw.poll(context.Background())
+	rw.poll(context.Background())
+
+	if !slices.Equal(due, []int{3, 0}) {
+		t.Fatalf("expected the second poll to find nothing due, got %v", due)
+	}
+	got, err := repo.GetByID(context.Background(), "n-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.StatusQueued {
+		t.Fatalf("expected the claimed row queued, got %s", got.Status)
+	}
+}
+
+func TestPool_SetRetryBackoffAppliesToNextFailure(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{err: errors.New("provider down")}
+	cfg := &config.Config{SMSWorkers: 1, RetryBackoff: []time.Duration{time.Hour}}
+	p := NewPool(cfg, queue.New(), repo, prov, ratelimiter.New(100, nil), zap.NewNop(), Hooks{})
+
+	p.SetRetryBackoff([]time.Duration{time.Minute})
+	cfg.RetryBackoff[0] = 2 * time.Hour // the pool keeps its own copy
+
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello",
+		Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	p.workers[0].process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	got, err := repo.GetByID(context.Background(), n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NextRetryAt == nil || time.Until(*got.NextRetryAt) > 2*time.Minute {
+		t.Fatalf("expected a retry about a minute out, got %v", got.NextRetryAt)
+	}
+}
+
+func TestWorker_MaxRetriesBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		failures   int
+		reports    int    // retry outcomes reported
+		wantLast   string // the last of them
+	}{
+		{"zero fails on the first error", 0, 1, 0, ""},
+		{"ten keeps retrying past the backoff list", 10, 10, 10, RetryScheduled},
+		{"ten gives up on the eleventh error", 10, 11, 11, RetryExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMockNotificationRepository()
+			var outcomes []string
+			w := NewWorker(1, queue.New(), repo, &stubProvider{err: errors.New("provider down")}, ratelimiter.New(100, nil),
+				[]time.Duration{time.Minute, time.Hour}, zap.NewNop(),
+				Hooks{OnRetry: func(_ domain.Channel, _ int, outcome string) { outcomes = append(outcomes, outcome) }})
+			n := &domain.Notification{
+				ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello",
+				Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: tt.maxRetries,
+			}
+			if err := repo.Create(context.Background(), n); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.failures; i++ {
+				requeue(t, repo, n.ID)
+				w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+			}
+
+			got, _ := repo.GetByID(context.Background(), n.ID)
+			if got.Status != domain.StatusFailed || got.RetryCount != min(tt.failures, tt.maxRetries) {
+				t.Fatalf("unexpected state: status %s, retry %d of %d", got.Status, got.RetryCount, got.MaxRetries)
+			}
+			last := ""
+			if len(outcomes) > 0 {
+				last = outcomes[len(outcomes)-1]
+			}
+			if len(outcomes) != tt.reports || last != tt.wantLast {
+				t.Fatalf("unexpected retry outcomes %v", outcomes)
+			}
+			if tt.wantLast == RetryScheduled && time.Until(*got.NextRetryAt) < 59*time.Minute {
+				t.Fatalf("expected retries past the list to reuse its last delay, got %v", got.NextRetryAt)
+			}
+		})
+	}
+}
+
+func TestWorker_FollowsLongBackoffSchedule(t *testing.T) {
+	backoff := []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour}
+	repo := repository.NewMockNotificationRepository()
+	var outcomes []string
+	w := NewWorker(1, queue.New(), repo, &stubProvider{err: errors.New("provider down")}, ratelimiter.New(100, nil),
+		backoff, zap.NewNop(), Hooks{OnRetry: func(_ domain.Channel, _ int, outcome string) { outcomes = append(outcomes, outcome) }})
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Hello",
+		Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: len(backoff),
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	item := queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority}
+
+	for i, want := range backoff {
+		before := time.Now()
+		requeue(t, repo, n.ID)
+		w.process(context.Background(), item)
+		got, err := repo.GetByID(context.Background(), n.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RetryCount != i+1 || got.NextRetryAt == nil {
+			t.Fatalf("failure %d: expected retry %d to be scheduled, got %+v", i+1, i+1, got)
+		}
+		if wait := got.NextRetryAt.Sub(before); wait < want || wait > want+time.Minute {
+			t.Fatalf("failure %d: expected a %s wait, got %s", i+1, want, wait)
+		}
+	}
+
+	requeue(t, repo, n.ID)
+	w.process(context.Background(), item)
+	got, _ := repo.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusFailed || got.RetryCount != len(backoff) {
+		t.Fatalf("expected the sixth failure to exhaust retries, got %+v", got)
+	}
+	if len(outcomes) != 6 || outcomes[4] != RetryScheduled || outcomes[5] != RetryExhausted {
+		t.Fatalf("unexpected retry outcomes %v", outcomes)
+	}
+}
+
+func TestSchedulerWorker_SetIntervalAppliesImmediately(t *testing.T) {
+	polled := make(chan struct{}, 1)
+	repo := &flakyScheduledRepo{MockNotificationRepository: repository.NewMockNotificationRepository()}
+	sw := NewSchedulerWorker(repo, queue.New(), time.Hour, 10, events.Nop{}, SchedulerHooks{
+		OnPoll: func(int, time.Duration, error) {
+			select {
+			case polled <- struct{}{}:
+			default:
+			}
+		},
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sw.Run(ctx)
+	<-polled // the startup poll
+
+	sw.SetInterval(10 * time.Millisecond)
+	select {
+	case <-polled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a poll soon after shortening the interval from an hour")
+	}
+}
+
+func TestWorker_OverRecipientLimitIsRescheduled(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	w.recipients = ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelSMS: {Count: 1, Per: time.Hour},
+	}, 10, nil, nil)
+
+	first := createNotification(t, repo, time.Now().Add(2*time.Hour))
+	second := *first
+	second.ID = "n-2"
+	dryRun := *first
+	dryRun.ID, dryRun.DryRun = "n-3", true
+	for _, n := range []*domain.Notification{&second, &dryRun} {
+		if err := repo.Create(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	for _, n := range []*domain.Notification{first, &second, &dryRun} {
+		w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+	}
+
+	if prov.sends != 1 {
+		t.Fatalf("expected only the first send to reach the provider, got %d", prov.sends)
+	}
+	got, _ := repo.GetByID(context.Background(), second.ID)
+	if got.Status != domain.StatusScheduled || got.ScheduledAt == nil {
+		t.Fatalf("expected the second send rescheduled, got %s", got.Status)
+	}
+	if at := got.ScheduledAt.Sub(start); at < 59*time.Minute || at > time.Hour+time.Second {
+		t.Fatalf("expected it rescheduled for when the window frees up, got %s from now", at)
+	}
+	if got.RetryCount != 0 {
+		t.Fatalf("expected the deferral not to use up a retry, got retry_count %d", got.RetryCount)
+	}
+	if got, _ := repo.GetByID(context.Background(), dryRun.ID); got.Status != domain.StatusSent {
+		t.Fatalf("expected the dry run sent regardless of the limit, got %s", got.Status)
+	}
+	if len(terminal) != 2 {
+		t.Fatalf("expected terminal events for the two sent only, got %v", terminal)
+	}
+}
+
+// cancelAfterFetchRepo cancels every notification right after the worker
+// fetches it, as a DELETE landing between the fetch and the next write would.
+type cancelAfterFetchRepo struct {
+	*repository.MockNotificationRepository
+}
+
+func (r cancelAfterFetchRepo) GetByID(ctx context.Context, id string) (*domain.Notification, error) {
+	n, err := r.MockNotificationRepository.GetByID(ctx, id)
+	if err == nil {
+		err = r.MockNotificationRepository.Cancel(ctx, id, domain.Cancellation{At: time.Now()})
+	}
+	return n, err
+}
+
+func TestWorker_CancelledOutsideSendWindowStaysCancelled(t *testing.T) {
+	repo := cancelAfterFetchRepo{repository.NewMockNotificationRepository()}
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	// A window an hour long that the current hour is not in.
+	start, end := "13:00", "14:00"
+	if time.Now().UTC().Hour() >= 12 {
+		start, end = "01:00", "02:00"
+	}
+	n := &domain.Notification{
+		ID: "n-1", Channel: domain.ChannelSMS, Recipient: "+905551234567", Content: "Your order shipped",
+		Priority: domain.PriorityNormal, Status: domain.StatusQueued, MaxRetries: 3,
+		SendWindowStart: &start, SendWindowEnd: &end,
+	}
+	if err := repo.Create(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	got, _ := repo.MockNotificationRepository.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand instead of being rescheduled, got %s", got.Status)
+	}
+	if prov.sends != 0 {
+		t.Fatalf("expected no provider call, got %d", prov.sends)
+	}
+}
+
+func TestWorker_CancelledOverRecipientLimitStaysCancelled(t *testing.T) {
+	repo := cancelAfterFetchRepo{repository.NewMockNotificationRepository()}
+	prov := &stubProvider{}
+	var terminal []domain.Status
+	w := newTestWorker(repo, prov, &terminal)
+	w.recipients = ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+		domain.ChannelSMS: {Count: 1, Per: time.Hour},
+	}, 10, nil, nil)
+	n := createNotification(t, repo, time.Now().Add(time.Hour))
+	// An earlier send used up the recipient's window.
+	w.recipients.Allow(n.Channel, n.Recipient)
+
+	w.process(context.Background(), queue.Item{NotificationID: n.ID, Channel: n.Channel, Priority: n.Priority})
+
+	got, _ := repo.MockNotificationRepository.GetByID(context.Background(), n.ID)
+	if got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand instead of being rescheduled, got %s", got.Status)
+	}
+	if prov.sends != 0 {
+		t.Fatalf("expected no provider call, got %d", prov.sends)
+	}
+}
+
+func TestWorker_AbandonedSendIsNotChargedToRecipient(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		cancel    bool // cancel the first notification while it waits for the rate limiter
+	}{
+		{"claim lost", time.Now().Add(time.Hour), true},
+		{"expired", time.Now().Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMockNotificationRepository()
+			prov := &stubProvider{}
+			var terminal []domain.Status
+			w := newTestWorker(repo, prov, &terminal)
+			w.recipients = ratelimiter.NewRecipient(map[domain.Channel]ratelimiter.RecipientLimit{
+				domain.ChannelSMS: {Count: 1, Per: time.Hour},
+			}, 10, nil, nil)
+			first := createNotification(t, repo, tt.expiresAt)
+			second := *first
+			second.ID, second.ExpiresAt = "n-2", nil
+			if err := repo.Create(context.Background(), &second); err != nil {
+				t.Fatal(err)
+			}
+
+			w.limiter = slowLimiter{duringWait: func() {
+				if tt.cancel {
+					repo.Cancel(context.Background(), first.ID, domain.Cancellation{At: time.Now()}) //nolint:errcheck // mock never fails
+				}
+			}}
+			w.process(context.Background(), queue.Item{NotificationID: first.ID, Channel: first.Channel, Priority: first.Priority})
+			w.limiter = slowLimiter{duringWait: func() {}}
+			w.process(context.Background(), queue.Item{NotificationID: second.ID, Channel: second.Channel, Priority: second.Priority})
+
+			if prov.sends != 1 {
+				t.Fatalf("expected only the second notification sent, got %d sends", prov.sends)
+			}
+			if got, _ := repo.GetByID(context.Background(), second.ID); got.Status != domain.StatusSent {
+				t.Fatalf("expected the second notification sent within the limit, got %s", got.Status)
+			}
+		})
+	}
+}
+
+func TestReleaseQueued_HandsBufferedItemsBackToPending(t *testing.T) {
+	repo := repository.NewMockNotificationRepository()
+	q := queue.New()
+	ctx := context.Background()
+	for _, id := range []string{"n-1", "n-2"} {
+		n := &domain.Notification{
+			ID: id, Channel: domain.ChannelSMS, Priority: domain.PriorityNormal, Status: domain.StatusQueued,
+		}
+		if err := repo.Create(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Enqueue(queue.Item{NotificationID: id, Channel: n.Channel, Priority: n.Priority}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Cancel(ctx, "n-2", domain.Cancellation{At: time.Now()}) //nolint:errcheck // mock never fails
+
+	if n := ReleaseQueued(ctx, q, repo, zap.NewNop()); n != 1 {
+		t.Fatalf("expected one notification released, got %d", n)
+	}
+	if got, _ := repo.GetByID(ctx, "n-1"); got.Status != domain.StatusPending {
+		t.Fatalf("expected n-1 back to pending, got %s", got.Status)
+	}
+	if got, _ := repo.GetByID(ctx, "n-2"); got.Status != domain.StatusCancelled {
+		t.Fatalf("expected the cancel to stand, got %s", got.Status)
+	}
+	if _, normal, _ := q.Depths(); normal != 0 {
+		t.Fatalf("expected the queue drained, got %d left", normal)
+	}
+}
@@ -0,0 +1,4 @@
+-- Delivery receipts and support tickets reference the provider's message ID.
+-- Partial index: most rows never get one until they are sent.
+CREATE INDEX idx_notifications_provider_msg_id ON notifications(provider_msg_id, created_at DESC)
+    WHERE provider_msg_id IS NOT NULL;
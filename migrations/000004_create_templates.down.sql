@@ -0,0 +1,3 @@
+ALTER TABLE notifications DROP COLUMN IF EXISTS template_id;
+DROP TRIGGER IF EXISTS trg_templates_updated_at ON templates;
+DROP TABLE IF EXISTS templates;
@@ -0,0 +1,17 @@
+-- Named server-side content templates rendered with Go text/template syntax.
+
+CREATE TABLE templates (
+    id         TEXT        PRIMARY KEY,
+    name       TEXT        NOT NULL UNIQUE,
+    body       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trg_templates_updated_at
+    BEFORE UPDATE ON templates
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Notifications rendered from a template keep a reference for traceability.
+-- No foreign key: templates may be deleted while their notifications live on.
+ALTER TABLE notifications ADD COLUMN template_id TEXT;
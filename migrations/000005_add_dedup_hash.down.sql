@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_notifications_dedup_hash;
+ALTER TABLE notifications DROP COLUMN IF EXISTS dedup_hash;
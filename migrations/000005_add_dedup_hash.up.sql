@@ -0,0 +1,6 @@
+-- Hash of channel + recipient + content, used to suppress repeated sends within
+-- DEDUP_WINDOW even when clients generate fresh idempotency keys.
+ALTER TABLE notifications ADD COLUMN dedup_hash TEXT;
+
+CREATE INDEX idx_notifications_dedup_hash ON notifications(dedup_hash, created_at DESC)
+    WHERE dedup_hash IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_owner_created_at;
+ALTER TABLE batches       DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS owner_id;
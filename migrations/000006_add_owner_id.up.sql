@@ -0,0 +1,7 @@
+-- Owner of each record: the API-key owner that created it. NULL for rows
+-- created while authentication was disabled.
+ALTER TABLE notifications ADD COLUMN owner_id TEXT;
+ALTER TABLE batches       ADD COLUMN owner_id TEXT;
+
+-- Owner-scoped listing (newest first)
+CREATE INDEX idx_notifications_owner_created_at ON notifications(owner_id, created_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS callbacks;
+ALTER TABLE notifications DROP COLUMN IF EXISTS callback_url;
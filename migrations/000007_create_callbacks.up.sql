@@ -0,0 +1,28 @@
+-- Optional per-notification status webhook.
+ALTER TABLE notifications ADD COLUMN callback_url TEXT;
+
+-- Outbound status callbacks. Rows persist so deliveries survive restarts.
+-- No foreign key to notifications: the table may be partitioned.
+CREATE TABLE callbacks (
+    id              TEXT        PRIMARY KEY,
+    notification_id TEXT        NOT NULL,
+    url             TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending'
+                                CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts        SMALLINT    NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_callbacks_notification_id ON callbacks(notification_id);
+
+-- Partial index: the dispatcher only polls undelivered callbacks
+CREATE INDEX idx_callbacks_due ON callbacks(next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE TRIGGER trg_callbacks_updated_at
+    BEFORE UPDATE ON callbacks
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
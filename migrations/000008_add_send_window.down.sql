@@ -0,0 +1,4 @@
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS send_window_end,
+    DROP COLUMN IF EXISTS send_window_start;
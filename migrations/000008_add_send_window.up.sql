@@ -0,0 +1,5 @@
+-- Optional daily delivery window in the recipient's timezone (quiet hours).
+ALTER TABLE notifications
+    ADD COLUMN send_window_start TEXT,
+    ADD COLUMN send_window_end   TEXT,
+    ADD COLUMN timezone          TEXT;
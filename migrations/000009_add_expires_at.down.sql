@@ -0,0 +1,7 @@
+-- Postgres cannot drop an enum value, so 'expired' stays in
+-- notification_status; rows using it are folded back into 'failed'.
+UPDATE notifications SET status = 'failed' WHERE status = 'expired';
+
+ALTER TABLE batches DROP COLUMN IF EXISTS expired;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS expires_at;
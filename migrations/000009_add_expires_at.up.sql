@@ -0,0 +1,10 @@
+-- Optional delivery deadline. Notifications still undelivered at expires_at
+-- end in the terminal status 'expired' instead of being sent late.
+--
+-- The new enum value cannot be referenced in the transaction that adds it,
+-- so no index or default below mentions 'expired'.
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'expired';
+
+ALTER TABLE notifications ADD COLUMN expires_at TIMESTAMPTZ;
+
+ALTER TABLE batches ADD COLUMN expired INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Batch-level send time, applied to members created without their own scheduled_at.
+ALTER TABLE batches ADD COLUMN scheduled_at TIMESTAMPTZ;
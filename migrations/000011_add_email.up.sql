@@ -0,0 +1,2 @@
+-- Subject, HTML body and attachments of email notifications.
+ALTER TABLE notifications ADD COLUMN email JSONB;
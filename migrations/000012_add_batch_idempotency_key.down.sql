@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_batches_idempotency_key;
+ALTER TABLE batches DROP COLUMN IF EXISTS idempotency_key;
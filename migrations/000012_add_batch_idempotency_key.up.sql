@@ -0,0 +1,3 @@
+-- Idempotency key of a fan-out request, protecting the whole implicit batch.
+ALTER TABLE batches ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX idx_batches_idempotency_key ON batches(idempotency_key) WHERE idempotency_key IS NOT NULL;
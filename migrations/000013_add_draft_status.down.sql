@@ -0,0 +1,3 @@
+-- Postgres cannot drop an enum value, so 'draft' stays in
+-- notification_status; unsubmitted drafts are cancelled.
+UPDATE notifications SET status = 'cancelled' WHERE status = 'draft';
@@ -0,0 +1,2 @@
+-- Drafts are stored but never dispatched until submitted.
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'draft';
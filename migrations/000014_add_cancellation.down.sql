@@ -0,0 +1,5 @@
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS cancel_correlation_id,
+    DROP COLUMN IF EXISTS cancelled_by,
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancelled_reason;
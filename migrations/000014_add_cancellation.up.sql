@@ -0,0 +1,6 @@
+-- Who cancelled a notification, when, and why.
+ALTER TABLE notifications
+    ADD COLUMN cancelled_reason      TEXT,
+    ADD COLUMN cancelled_at          TIMESTAMPTZ,
+    ADD COLUMN cancelled_by          TEXT,
+    ADD COLUMN cancel_correlation_id TEXT;
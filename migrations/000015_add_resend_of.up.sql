@@ -0,0 +1,3 @@
+-- Links a resent notification to the one it copies. No foreign key, so the
+-- table stays partitionable.
+ALTER TABLE notifications ADD COLUMN resend_of TEXT;
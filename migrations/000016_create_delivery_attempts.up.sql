@@ -0,0 +1,14 @@
+-- One row per provider send, successful or not, so operators can see how a
+-- dead-lettered notification got there. No foreign key to notifications: the
+-- table may be partitioned.
+CREATE TABLE delivery_attempts (
+    id              BIGSERIAL   PRIMARY KEY,
+    notification_id TEXT        NOT NULL,
+    attempt         SMALLINT    NOT NULL,
+    outcome         TEXT        NOT NULL CHECK (outcome IN ('sent', 'failed')),
+    error           TEXT,
+    provider_msg_id TEXT,
+    attempted_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_delivery_attempts_notification ON delivery_attempts(notification_id, attempted_at);
@@ -0,0 +1 @@
+ALTER TABLE batches DROP COLUMN IF EXISTS completed_at;
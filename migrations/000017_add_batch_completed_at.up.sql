@@ -0,0 +1,2 @@
+-- When the batch's pending count first reached zero; set once by the batch counter.
+ALTER TABLE batches ADD COLUMN completed_at TIMESTAMPTZ;
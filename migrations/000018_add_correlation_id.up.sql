@@ -0,0 +1,3 @@
+-- Correlation ID of the API request that created the notification, so the
+-- worker can log it and pass it on to the provider.
+ALTER TABLE notifications ADD COLUMN correlation_id TEXT;
@@ -0,0 +1,31 @@
+-- Append-only record of API mutations for security review. Rows are never
+-- updated or deleted by the application; the trigger below stops anyone else
+-- doing so short of dropping it.
+CREATE TABLE audit_log (
+    id             BIGSERIAL   PRIMARY KEY,
+    action         TEXT        NOT NULL,
+    target_type    TEXT,
+    target_id      TEXT,
+    owner_id       TEXT,
+    key_id         TEXT,
+    client_ip      TEXT        NOT NULL,
+    correlation_id TEXT,
+    method         TEXT        NOT NULL,
+    path           TEXT        NOT NULL,
+    status         SMALLINT    NOT NULL,
+    occurred_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_occurred_at ON audit_log(occurred_at);
+CREATE INDEX idx_audit_log_target ON audit_log(target_id, occurred_at) WHERE target_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION audit_log_immutable()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_log_immutable
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
@@ -0,0 +1,3 @@
+-- Dry runs go through the whole pipeline but the worker never hands them to
+-- the provider; it marks them sent with provider_msg_id 'sandbox'.
+ALTER TABLE notifications ADD COLUMN dry_run BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_retry;
+CREATE INDEX idx_notifications_retry ON notifications(next_retry_at)
+    WHERE status = 'failed' AND retry_count < max_retries;
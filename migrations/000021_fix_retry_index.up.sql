@@ -0,0 +1,5 @@
+-- A row awaiting a retry carries that retry's number in retry_count, so the
+-- last retry allowed has retry_count = max_retries and must stay indexed.
+DROP INDEX IF EXISTS idx_notifications_retry;
+CREATE INDEX idx_notifications_retry ON notifications(next_retry_at)
+    WHERE status = 'failed' AND retry_count <= max_retries;
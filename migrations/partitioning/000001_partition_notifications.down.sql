@@ -0,0 +1,37 @@
+-- Folds every partition back into a single flat notifications table.
+
+ALTER TABLE notifications RENAME TO notifications_partitioned;
+ALTER INDEX notifications_pkey RENAME TO notifications_partitioned_pkey;
+
+CREATE TABLE notifications (LIKE notifications_partitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+ALTER TABLE notifications ADD PRIMARY KEY (id);
+ALTER TABLE notifications ADD CONSTRAINT notifications_idempotency_key_key UNIQUE (idempotency_key);
+ALTER TABLE notifications
+    ADD CONSTRAINT notifications_batch_id_fkey FOREIGN KEY (batch_id) REFERENCES batches(id) ON DELETE SET NULL;
+
+INSERT INTO notifications SELECT * FROM notifications_partitioned;
+
+-- Rebuild the partitioned table's non-unique indexes, except the idempotency
+-- key lookup that the unique constraint above replaces.
+DO $$
+DECLARE
+    defs TEXT[];
+    def  TEXT;
+BEGIN
+    SELECT array_agg(indexdef) INTO defs FROM pg_indexes
+    WHERE schemaname = current_schema() AND tablename = 'notifications_partitioned'
+      AND indexdef NOT LIKE 'CREATE UNIQUE INDEX %'
+      AND indexname <> 'idx_notifications_idempotency_key';
+    DROP TABLE notifications_partitioned CASCADE;
+    FOREACH def IN ARRAY COALESCE(defs, '{}') LOOP
+        EXECUTE regexp_replace(def, ' ON (ONLY )?\S+ USING ', ' ON notifications USING ');
+    END LOOP;
+END
+$$;
+
+DROP TABLE IF EXISTS notification_idempotency_keys;
+DROP FUNCTION IF EXISTS claim_idempotency_key();
+
+CREATE TRIGGER trg_notifications_updated_at
+    BEFORE UPDATE ON notifications
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,94 @@
+-- Converts notifications into a table range-partitioned by created_at with
+-- one partition per calendar month. Only applied when NOTIFICATIONS_PARTITIONED
+-- is enabled; the flat table from the main migrations remains the default.
+--
+-- Partitioned tables can only enforce uniqueness on columns that include the
+-- partition key, so idempotency keys move to a side table kept in sync by a
+-- trigger. The repository keeps inserting into notifications unchanged.
+
+ALTER TABLE notifications RENAME TO notifications_flat;
+ALTER INDEX notifications_pkey RENAME TO notifications_flat_pkey;
+ALTER INDEX notifications_idempotency_key_key RENAME TO notifications_flat_idempotency_key_key;
+
+CREATE TABLE notifications (LIKE notifications_flat INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+    PARTITION BY RANGE (created_at);
+
+ALTER TABLE notifications ADD PRIMARY KEY (id, created_at);
+ALTER TABLE notifications
+    ADD CONSTRAINT notifications_batch_id_fkey FOREIGN KEY (batch_id) REFERENCES batches(id) ON DELETE SET NULL;
+
+-- Catch-all for rows outside any pre-created month, so inserts never fail
+-- when the maintenance worker has fallen behind.
+CREATE TABLE notifications_default PARTITION OF notifications DEFAULT;
+
+-- Monthly partitions covering existing data plus the next three months.
+DO $$
+DECLARE
+    month_start DATE := date_trunc('month', COALESCE((SELECT MIN(created_at) FROM notifications_flat), NOW()))::date;
+    last_month  DATE := (date_trunc('month', NOW()) + INTERVAL '3 months')::date;
+BEGIN
+    WHILE month_start <= last_month LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF notifications FOR VALUES FROM (%L) TO (%L)',
+            'notifications_p' || to_char(month_start, 'YYYYMM'),
+            month_start,
+            (month_start + INTERVAL '1 month')::date
+        );
+        month_start := (month_start + INTERVAL '1 month')::date;
+    END LOOP;
+END
+$$;
+
+CREATE TABLE notification_idempotency_keys (
+    idempotency_key TEXT        PRIMARY KEY,
+    notification_id TEXT        NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL
+);
+
+-- Raises unique_violation on notification_idempotency_keys_pkey for a repeated
+-- key, which the repository already maps to ErrConflict.
+CREATE OR REPLACE FUNCTION claim_idempotency_key()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.idempotency_key IS NOT NULL THEN
+        INSERT INTO notification_idempotency_keys (idempotency_key, notification_id, created_at)
+        VALUES (NEW.idempotency_key, NEW.id, NEW.created_at);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO notifications SELECT * FROM notifications_flat;
+
+INSERT INTO notification_idempotency_keys (idempotency_key, notification_id, created_at)
+SELECT idempotency_key, id, created_at FROM notifications_flat WHERE idempotency_key IS NOT NULL;
+
+-- Rebuild every non-unique index of the flat table on the partitioned one,
+-- so indexes added by later main migrations carry over without being listed
+-- here. Unique indexes cannot be: they would have to include created_at.
+DO $$
+DECLARE
+    defs TEXT[];
+    def  TEXT;
+BEGIN
+    SELECT array_agg(indexdef) INTO defs FROM pg_indexes
+    WHERE schemaname = current_schema() AND tablename = 'notifications_flat'
+      AND indexdef NOT LIKE 'CREATE UNIQUE INDEX %';
+    DROP TABLE notifications_flat;
+    FOREACH def IN ARRAY COALESCE(defs, '{}') LOOP
+        EXECUTE regexp_replace(def, ' ON (ONLY )?\S+ USING ', ' ON notifications USING ');
+    END LOOP;
+END
+$$;
+
+-- Stands in for the flat table's unique constraint when looking keys up.
+CREATE INDEX idx_notifications_idempotency_key ON notifications(idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+CREATE TRIGGER trg_notifications_idempotency_key
+    BEFORE INSERT ON notifications
+    FOR EACH ROW EXECUTE FUNCTION claim_idempotency_key();
+
+CREATE TRIGGER trg_notifications_updated_at
+    BEFORE UPDATE ON notifications
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();